@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+// usage documents the commands the server binary runs instead of serving requests
+const usage = `Usage:
+  server                          start the server
+  server promote-admin <username> make a user an administrator of the instance`
+
+// runCommand runs the command in args against the database and returns the exit code
+func runCommand(users repositories.UsersRepository, args []string) int {
+	switch args[0] {
+	case "promote-admin":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, usage)
+			return 2
+		}
+		return promoteAdmin(users, args[1])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s\n", args[0], usage)
+		return 2
+	}
+}
+
+// promoteAdmin makes a user an administrator, which is how the first one is created
+func promoteAdmin(users repositories.UsersRepository, username string) int {
+	user, err := users.FindByUsername(username)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to find user: %v\n", err)
+		return 1
+	}
+	if user == nil {
+		fmt.Fprintf(os.Stderr, "user %q not found\n", username)
+		return 1
+	}
+
+	if user.IsAdmin {
+		fmt.Printf("%s is already an administrator\n", user.Username)
+		return 0
+	}
+
+	if err := users.SetAdmin(user.ID, true); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to promote user: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("%s is now an administrator\n", user.Username)
+	return 0
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
@@ -30,44 +31,184 @@ func main() {
 	defer db.Close()
 
 	users := repositories.NewUsersRepository(db.DB)
+	userEmails := repositories.NewUserEmailsRepository(db.DB)
+	userIdentities := repositories.NewUserIdentitiesRepository(db.DB)
 	orgs := repositories.NewOrganizationsRepository(db.DB)
 	repos := repositories.NewRepositoriesRepository(db.DB)
 	contributors := repositories.NewContributorsRepository(db.DB)
 	stars := repositories.NewStarsRepository(db.DB)
 	tickets := repositories.NewTicketsRepository(db.DB)
+	milestones := repositories.NewMilestonesRepository(db.DB)
 	accessTokens := repositories.NewAccessTokensRepository(db.DB)
-	deviceAuthSessions := repositories.NewDeviceAuthSessionsRepository(db.DB)
+	oauthApplications := repositories.NewOAuthApplicationsRepository(db.DB)
+	oauthCodes := repositories.NewOAuthAuthorizationCodesRepository(db.DB)
+	oauthRefreshTokens := repositories.NewOAuthRefreshTokensRepository(db.DB)
+	oauthDeviceCodes := repositories.NewOAuthDeviceCodesRepository(db.DB)
+	notifications := repositories.NewNotificationsRepository(db.DB)
+	emailOutbox := repositories.NewEmailOutboxRepository(db.DB)
+	passwordResets := repositories.NewPasswordResetsRepository(db.DB)
+	sessions := repositories.NewSessionsRepository(db.DB)
+	twoFactorRepo := repositories.NewTwoFactorRepository(db.DB)
+	orgMembers := repositories.NewOrganizationMembersRepository(db.DB)
+	passkeys := repositories.NewPasskeysRepository(db.DB)
+	authLockouts := repositories.NewAuthLockoutsRepository(db.DB)
+	auditEvents := repositories.NewAuditEventsRepository(db.DB)
+	instanceSettings := repositories.NewInstanceSettingsRepository(db.DB)
+	webhooks := repositories.NewWebhooksRepository(db.DB)
+	webhookDeliveries := repositories.NewWebhookDeliveriesRepository(db.DB)
+	commitStatuses := repositories.NewCommitStatusesRepository(db.DB)
+	workflowRuns := repositories.NewWorkflowRunsRepository(db.DB)
+	secrets := repositories.NewSecretsRepository(db.DB)
+	goModuleVersions := repositories.NewGoModuleVersionsRepository(db.DB)
+	registryBlobs := repositories.NewRegistryBlobsRepository(db.DB)
+	registryManifests := repositories.NewRegistryManifestsRepository(db.DB)
+	registryTags := repositories.NewRegistryTagsRepository(db.DB)
+	packages := repositories.NewPackagesRepository(db.DB)
+	packageVersions := repositories.NewPackageVersionsRepository(db.DB)
+	packageFiles := repositories.NewPackageFilesRepository(db.DB)
+	packageDistTags := repositories.NewPackageDistTagsRepository(db.DB)
 
-	authService := services.NewAuthService(users, cfg.SigningSecret)
+	// Administrative commands run instead of the server
+	if len(os.Args) > 1 {
+		code := runCommand(users, os.Args[1:])
+		db.Close()
+		os.Exit(code)
+	}
+
+	authService := services.NewAuthService(users, sessions, cfg.SigningSecret)
+	accessTokenService := services.NewAccessTokenService(accessTokens)
+	oauthService := services.NewOAuthService(oauthApplications, oauthCodes, oauthRefreshTokens, oauthDeviceCodes, accessTokens, users, accessTokenService)
+	twoFactorService := services.NewTwoFactorService(twoFactorRepo)
 	flashService := services.NewFlashService()
+	auditService := services.NewAuditService(auditEvents)
+	authThrottleService := services.NewAuthThrottleService(services.NewMemoryRateLimiter(), authLockouts, services.AuthThrottleLimits{
+		IPRateLimit:         cfg.AuthIPRateLimit,
+		AccountRateLimit:    cfg.AuthAccountRateLimit,
+		DeviceCodeRateLimit: cfg.DeviceCodeRateLimit,
+		LockoutThreshold:    cfg.AuthLockoutThreshold,
+		LockoutDuration:     cfg.AuthLockoutDuration,
+		LockoutMaxDuration:  cfg.AuthLockoutMaxDuration,
+	})
 	gitService := services.NewGitService(cfg.ReposBasePath)
-	githubOAuthService := services.NewGitHubOAuthService(cfg.GitHubClientID, cfg.GitHubClientSecret, cfg.GitHubCallbackURL)
+	ticketTemplateService := services.NewTicketTemplateService(gitService)
+	emailService, err := services.NewEmailService(emailOutbox, cfg.PublicURL)
+	if err != nil {
+		slog.Error("failed to load email templates", "error", err)
+		os.Exit(1)
+	}
+	passkeyService, err := services.NewPasskeyService(passkeys, cfg.PublicURL)
+	if err != nil {
+		slog.Error("invalid public URL", "error", err)
+		os.Exit(1)
+	}
+	emailVerificationService := services.NewEmailVerificationService(userEmails, emailService, cfg.PublicURL)
+	notificationService := services.NewNotificationService(notifications, users, userEmails, repos, orgs, orgMembers, contributors, twoFactorService, emailService, cfg.PublicURL)
+	commitStatusService := services.NewCommitStatusService(commitStatuses)
+	webhookService := services.NewWebhookService(webhooks, webhookDeliveries, repos, users, orgs, instanceSettings, gitService, cfg.PublicURL)
+	workflowService := services.NewWorkflowService(workflowRuns, commitStatuses, repos, users, orgs, gitService, cfg.PublicURL)
+	goModuleService := services.NewGoModuleService(goModuleVersions, gitService, cfg.PublicURL, cfg.GoModuleCachePath)
+	registryService, err := services.NewRegistryService(registryBlobs, registryManifests, registryTags, cfg.SigningSecret, cfg.RegistryPath, cfg.RegistryMaxBlobSize)
+	if err != nil {
+		slog.Error("failed to set up the container registry", "error", err)
+		os.Exit(1)
+	}
+	packageService := services.NewPackageService(packages, packageVersions, packageFiles, packageDistTags, repos, cfg.PackagesPath)
+	secretService, err := services.NewSecretService(secrets, repos, users, orgs, orgMembers, contributors, twoFactorService, auditService, cfg.SecretsKey)
+	if err != nil {
+		slog.Error("failed to set up secrets encryption", "error", err)
+		os.Exit(1)
+	}
+	repositoryDeletionService := services.NewRepositoryDeletionService(repos, users, webhooks, secrets, packages, gitService, goModuleService, registryService, packageService, webhookService)
+
+	// Deliver queued emails in the background
+	outboxWorker := services.NewOutboxWorker(emailOutbox, newMailer(cfg))
+	go outboxWorker.Run(context.Background(), 10*time.Second)
+
+	// Deliver queued webhooks in the background
+	webhookWorker := services.NewWebhookWorker(webhooks, webhookDeliveries, instanceSettings)
+	go webhookWorker.Run(context.Background(), 5*time.Second)
+
+	// Remove abandoned container image uploads in the background
+	go func() {
+		for range time.Tick(time.Hour) {
+			if err := registryService.ExpireUploads(); err != nil {
+				slog.Error("failed to expire registry uploads", "error", err)
+			}
+		}
+	}()
+
+	var identityProviders []services.IdentityProvider
+	if cfg.GitHubClientID != "" {
+		identityProviders = append(identityProviders, services.NewGitHubIdentityProvider(cfg.GitHubClientID, cfg.GitHubClientSecret, cfg.GitHubCallbackURL))
+	}
+	for _, provider := range cfg.OIDCProviders {
+		identityProviders = append(identityProviders, services.NewOIDCProvider(services.OIDCProviderConfig{
+			ID:            provider.ID,
+			Name:          provider.Name,
+			Issuer:        provider.Issuer,
+			ClientID:      provider.ClientID,
+			ClientSecret:  provider.ClientSecret,
+			RedirectURL:   provider.RedirectURL,
+			Scopes:        provider.Scopes,
+			UsernameClaim: provider.UsernameClaim,
+			EmailClaim:    provider.EmailClaim,
+			GroupsClaim:   provider.GroupsClaim,
+			AllowedGroups: provider.AllowedGroups,
+			AdminGroups:   provider.AdminGroups,
+		}))
+	}
 
 	homeController := controllers.NewHomeController(repos, users, orgs, stars)
-	signUpController := controllers.NewSignUpController(users, authService, flashService)
-	signInController := controllers.NewSignInController(users, authService)
+	signUpController := controllers.NewSignUpController(users, userEmails, authService, flashService, emailVerificationService, instanceSettings)
+	signInController := controllers.NewSignInController(users, passkeys, authService, twoFactorService, passkeyService, authThrottleService, auditService)
 	signOutController := controllers.NewSignOutController(authService)
-	githubAuthController := controllers.NewGitHubAuthController(users, authService, githubOAuthService)
-	settingsController := controllers.NewSettingsController(users, accessTokens, authService)
-	accessTokensController := controllers.NewAccessTokensController(accessTokens)
-	deviceAuthController := controllers.NewDeviceAuthController(deviceAuthSessions, accessTokens, users)
-	forgotPasswordController := controllers.NewForgotPasswordController()
-	resetPasswordController := controllers.NewResetPasswordController()
-	orgsController := controllers.NewOrganizationsController(orgs, users, repos, stars, authService)
-	reposController := controllers.NewRepositoriesController(repos, users, contributors, stars, orgs, authService, gitService, cfg.ReposBasePath)
-	gitController := controllers.NewGitController(users, orgs, repos, contributors, accessTokens, authService, cfg.ReposBasePath)
+	externalAuthController := controllers.NewExternalAuthController(users, userEmails, userIdentities, authService, twoFactorService, identityProviders, auditService, instanceSettings)
+	settingsController := controllers.NewSettingsController(users, userEmails, accessTokens, repos, contributors, orgs, sessions, passkeys, auditEvents, authService, twoFactorService)
+	passkeysController := controllers.NewPasskeysController(passkeys, passkeyService)
+	twoFactorController := controllers.NewTwoFactorController(orgs, orgMembers, twoFactorService, authService)
+	sessionsController := controllers.NewSessionsController(sessions, authService)
+	userEmailsController := controllers.NewUserEmailsController(userEmails, emailVerificationService)
+	accessTokensController := controllers.NewAccessTokensController(accessTokens, accessTokenService, repos, contributors, users, orgs, auditService)
+	deviceAuthController := controllers.NewDeviceAuthController(oauthDeviceCodes, oauthApplications, oauthService, authThrottleService, auditService, cfg.PublicURL)
+	oauthController := controllers.NewOAuthController(oauthService, users, orgs, auditService)
+	oauthApplicationsController := controllers.NewOAuthApplicationsController(oauthApplications, orgs, orgMembers, oauthService, auditService)
+	forgotPasswordController := controllers.NewForgotPasswordController(users, userIdentities, passwordResets, emailService, cfg.PublicURL)
+	resetPasswordController := controllers.NewResetPasswordController(users, passwordResets, accessTokens, sessions, authService)
+	orgsController := controllers.NewOrganizationsController(orgs, orgMembers, users, repos, contributors, stars, auditEvents, authService, twoFactorService, auditService)
+	reposController := controllers.NewRepositoriesController(repos, users, userEmails, contributors, stars, orgs, repositoryDeletionService, authService, twoFactorService, gitService, auditService, webhookService, commitStatusService, cfg.ReposBasePath)
+	gitController := controllers.NewGitController(users, orgs, orgMembers, repos, contributors, accessTokenService, authService, twoFactorService, authThrottleService, gitService, webhookService, workflowService, cfg.ReposBasePath)
 	exploreController := controllers.NewExploreController(repos, users, orgs, stars, authService)
-	ticketsController := controllers.NewTicketsController(tickets, repos, users, stars, contributors, authService)
+	ticketsController := controllers.NewTicketsController(tickets, notifications, milestones, repos, users, stars, orgs, orgMembers, contributors, twoFactorService, authService, ticketTemplateService, notificationService, webhookService, workflowService, cfg.ReposBasePath)
+	notificationsController := controllers.NewNotificationsController(notifications, tickets, repos, users, orgs)
+	milestonesController := controllers.NewMilestonesController(milestones, repos, stars, orgs, orgMembers, contributors, twoFactorService, authService)
+	apiUsersController := controllers.NewAPIUsersController(users)
+	apiOrgsController := controllers.NewAPIOrganizationsController(orgs, orgMembers, users, accessTokenService, twoFactorService, auditService)
+	apiReposController := controllers.NewAPIRepositoriesController(repos, users, orgs, orgMembers, contributors, stars, repositoryDeletionService, accessTokenService, twoFactorService, gitService, auditService, webhookService, cfg.PublicURL)
+	apiTicketsController := controllers.NewAPITicketsController(tickets, repos, users, orgs, orgMembers, contributors, accessTokenService, twoFactorService, notificationService, webhookService, workflowService)
+	apiCommitStatusesController := controllers.NewAPICommitStatusesController(commitStatuses, repos, users, orgs, orgMembers, contributors, accessTokenService, twoFactorService, commitStatusService, gitService)
+	apiDocsController := controllers.NewAPIDocsController(cfg.PublicURL)
+	webhooksController := controllers.NewWebhooksController(webhooks, webhookDeliveries, repos, contributors, stars, orgs, orgMembers, webhookService, auditService)
+	goProxyController := controllers.NewGoProxyController(repos, users, orgs, orgMembers, contributors, accessTokenService, twoFactorService, goModuleService)
+	registryController := controllers.NewRegistryController(repos, users, orgs, orgMembers, contributors, accessTokenService, twoFactorService, registryService)
+	packageRegistryController := controllers.NewPackageRegistryController(repos, users, orgs, orgMembers, contributors, packages, accessTokenService, twoFactorService, packageService, cfg.PublicURL)
+	packagesController := controllers.NewPackagesController(repos, users, orgs, orgMembers, contributors, packages, accessTokenService, twoFactorService, packageService, cfg.PublicURL)
+	secretsController := controllers.NewSecretsController(secrets, repos, contributors, stars, orgs, orgMembers, secretService, auditService)
+	workflowRunsController := controllers.NewWorkflowRunsController(workflowRuns, repos, users, contributors, orgMembers, stars, workflowService)
+	runnerController := controllers.NewRunnerController(workflowRuns, repos, workflowService, secretService, gitService, cfg.RunnerToken)
+	adminController := controllers.NewAdminController(users, orgs, repos, sessions, passwordResets, instanceSettings, repositoryDeletionService, gitService, emailService, auditService, cfg.DatabasePath, cfg.ReposBasePath, cfg.PublicURL)
 
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(custommiddleware.RealIP(cfg.TrustedProxies))
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(middleware.Timeout(60 * time.Second))
+	r.Use(custommiddleware.CSRF(cfg.SigningSecret, cfg.PublicURL))
 	r.Use(custommiddleware.InjectUser(authService))
+	r.Use(custommiddleware.InjectUnreadNotifications(notifications))
 	r.Use(custommiddleware.InjectFlash(flashService))
+	r.Use(custommiddleware.InjectIdentityProviders(identityProviders))
 	r.Use(custommiddleware.StaticFileServer(public.FileServer()))
 
 	r.Get("/", wrapHandler(homeController.Show))
@@ -77,22 +218,61 @@ func main() {
 
 	r.Get("/auth/sign-in", wrapHandler(signInController.Show))
 	r.Post("/auth/sign-in", wrapHandler(signInController.Handle))
+	r.Get("/auth/sign-in/two-factor", wrapHandler(signInController.ShowTwoFactor))
+	r.Post("/auth/sign-in/two-factor", wrapHandler(signInController.HandleTwoFactor))
+	r.Post("/auth/sign-in/two-factor/passkey/options", wrapHandler(signInController.TwoFactorPasskeyOptions))
+	r.Post("/auth/sign-in/two-factor/passkey", wrapHandler(signInController.HandleTwoFactorPasskey))
+	r.Post("/auth/passkey/options", wrapHandler(signInController.PasskeyOptions))
+	r.Post("/auth/passkey", wrapHandler(signInController.HandlePasskey))
 
 	r.Get("/auth/sign-out", wrapHandler(signOutController.Handle))
 
-	r.Get("/auth/github", wrapHandler(githubAuthController.Login))
-	r.Get("/auth/github/callback", wrapHandler(githubAuthController.Callback))
+	r.Get("/auth/{provider}", wrapHandler(externalAuthController.Login))
+	r.Get("/auth/{provider}/callback", wrapHandler(externalAuthController.Callback))
 
-	r.Post("/api/auth/device/code", wrapHandler(deviceAuthController.InitiateDeviceAuth))
-	r.Get("/api/auth/device/poll", wrapHandler(deviceAuthController.PollDeviceAuth))
 	r.Get("/auth/device", wrapHandler(deviceAuthController.ShowDeviceAuthPage))
 	r.Post("/auth/device/confirm", wrapHandler(deviceAuthController.ConfirmDeviceAuth))
 
+	r.Get("/oauth/authorize", wrapHandler(oauthController.Authorize))
+	r.Post("/oauth/authorize", wrapHandler(oauthController.Decide))
+	r.Post("/oauth/token", wrapHandler(oauthController.Token))
+	r.Post("/oauth/revoke", wrapHandler(oauthController.Revoke))
+	r.Post("/oauth/introspect", wrapHandler(oauthController.Introspect))
+	r.Post("/oauth/device/code", wrapHandler(deviceAuthController.DeviceAuthorization))
+
 	r.Get("/settings", wrapHandler(settingsController.Show))
 	r.Post("/settings/general", wrapHandler(settingsController.UpdateGeneral))
 	r.Post("/settings/password", wrapHandler(settingsController.UpdatePassword))
 	r.Post("/settings/access-tokens", wrapHandler(accessTokensController.Create))
 	r.Post("/settings/access-tokens/{id}/delete", wrapHandler(accessTokensController.Delete))
+	r.Post("/settings/emails", wrapHandler(userEmailsController.Create))
+	r.Post("/settings/emails/{id}/delete", wrapHandler(userEmailsController.Delete))
+	r.Post("/settings/emails/{id}/primary", wrapHandler(userEmailsController.MakePrimary))
+	r.Post("/settings/emails/{id}/resend", wrapHandler(userEmailsController.ResendVerification))
+	r.Post("/settings/sessions/revoke-all", wrapHandler(sessionsController.RevokeAll))
+	r.Post("/settings/sessions/{id}/revoke", wrapHandler(sessionsController.Revoke))
+	r.Get("/settings/two-factor", wrapHandler(twoFactorController.Setup))
+	r.Post("/settings/two-factor", wrapHandler(twoFactorController.Enable))
+	r.Post("/settings/two-factor/disable", wrapHandler(twoFactorController.Disable))
+	r.Post("/settings/two-factor/recovery-codes", wrapHandler(twoFactorController.RegenerateRecoveryCodes))
+	r.Post("/settings/passkeys/options", wrapHandler(passkeysController.Options))
+	r.Post("/settings/passkeys", wrapHandler(passkeysController.Create))
+	r.Post("/settings/passkeys/{id}/delete", wrapHandler(passkeysController.Delete))
+	r.Get("/settings/applications", wrapHandler(oauthApplicationsController.Index))
+	r.Get("/settings/applications/new", wrapHandler(oauthApplicationsController.New))
+	r.Post("/settings/applications/new", wrapHandler(oauthApplicationsController.Create))
+	r.Get("/settings/applications/{id}", wrapHandler(oauthApplicationsController.Show))
+	r.Post("/settings/applications/{id}", wrapHandler(oauthApplicationsController.Update))
+	r.Post("/settings/applications/{id}/reset-secret", wrapHandler(oauthApplicationsController.ResetSecret))
+	r.Post("/settings/applications/{id}/delete", wrapHandler(oauthApplicationsController.Delete))
+	r.Post("/settings/applications/authorized/{id}/revoke", wrapHandler(oauthApplicationsController.RevokeAuthorization))
+
+	r.Get("/notifications", wrapHandler(notificationsController.Index))
+	r.Post("/notifications/read-all", wrapHandler(notificationsController.MarkAllRead))
+	r.Get("/notifications/{id}", wrapHandler(notificationsController.Open))
+	r.Post("/notifications/{id}/read", wrapHandler(notificationsController.MarkRead))
+	r.Post("/notifications/{id}/done", wrapHandler(notificationsController.MarkDone))
+	r.Post("/notifications/{id}/unsubscribe", wrapHandler(notificationsController.Unsubscribe))
 
 	r.Get("/forgot-password", wrapHandler(forgotPasswordController.Show))
 	r.Post("/forgot-password", wrapHandler(forgotPasswordController.Handle))
@@ -100,6 +280,8 @@ func main() {
 	r.Get("/reset-password", wrapHandler(resetPasswordController.Show))
 	r.Post("/reset-password", wrapHandler(resetPasswordController.Handle))
 
+	r.Get("/verify-email", wrapHandler(userEmailsController.Verify))
+
 	r.Get("/repositories/new", wrapHandler(reposController.Create))
 	r.Post("/repositories/new", wrapHandler(reposController.Store))
 
@@ -110,14 +292,100 @@ func main() {
 	r.Get("/explore/users", wrapHandler(exploreController.Users))
 	r.Get("/explore/organizations", wrapHandler(exploreController.Organizations))
 
+	r.Get("/api/docs", wrapHandler(apiDocsController.Show))
+
+	r.Route("/api/v1", func(r chi.Router) {
+		r.Use(custommiddleware.APIAuth(accessTokenService, users))
+		r.NotFound(wrapAPIHandler(func(w http.ResponseWriter, r *http.Request) error {
+			return httperror.NotFound("not found")
+		}))
+		r.MethodNotAllowed(wrapAPIHandler(func(w http.ResponseWriter, r *http.Request) error {
+			return httperror.New(http.StatusMethodNotAllowed, "method not allowed")
+		}))
+
+		controllers.RouteAPI(r, controllers.APIControllers{
+			Docs:           apiDocsController,
+			Users:          apiUsersController,
+			Organizations:  apiOrgsController,
+			Repositories:   apiReposController,
+			CommitStatuses: apiCommitStatusesController,
+			Tickets:        apiTicketsController,
+		}, wrapAPIHandler)
+	})
+
+	// Runners executing workflow jobs, authenticated with the runner token
+	r.Route("/api/runner", func(r chi.Router) {
+		r.Post("/jobs/claim", wrapAPIHandler(runnerController.Claim))
+		r.Get("/jobs/{id}/archive", wrapAPIHandler(runnerController.Archive))
+		r.Post("/jobs/{id}/log", wrapAPIHandler(runnerController.Log))
+		r.Post("/jobs/{id}/finish", wrapAPIHandler(runnerController.Finish))
+	})
+
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(custommiddleware.Auth(authService))
+		r.Use(custommiddleware.RequireAdmin)
+
+		r.Get("/", wrapHandler(adminController.Overview))
+		r.Post("/settings", wrapHandler(adminController.UpdateSettings))
+		r.Get("/users", wrapHandler(adminController.Users))
+		r.Post("/users/{id}/suspend", wrapHandler(adminController.Suspend))
+		r.Post("/users/{id}/unsuspend", wrapHandler(adminController.Unsuspend))
+		r.Post("/users/{id}/reset-password", wrapHandler(adminController.ResetPassword))
+		r.Post("/users/{id}/promote", wrapHandler(adminController.Promote))
+		r.Post("/users/{id}/demote", wrapHandler(adminController.Demote))
+		r.Post("/users/{id}/delete", wrapHandler(adminController.Delete))
+		r.Get("/repositories", wrapHandler(adminController.Repositories))
+	})
+
+	// Go modules of repositories, for GOPROXY=<public URL>/goproxy
+	r.Get("/goproxy/*", wrapHandler(goProxyController.Serve))
+
+	// Container images of repositories, for docker push <host>/<owner>/<repo>
+	r.Get("/v2/token", wrapHandler(registryController.Token))
+	r.HandleFunc("/v2/*", wrapHandler(registryController.Serve))
+
+	// npm and raw packages of users and organizations, for npm --registry <public URL>/api/packages/<owner>/npm/
+	r.Route("/api/packages/{owner}", func(r chi.Router) {
+		r.Use(custommiddleware.OwnerResolver(users, orgs))
+
+		r.HandleFunc("/npm/*", wrapHandler(packageRegistryController.NPM))
+		r.HandleFunc("/raw/*", wrapAPIHandler(packageRegistryController.Raw))
+	})
+
 	r.Route("/{owner}", func(r chi.Router) {
 		r.Use(custommiddleware.OwnerResolver(users, orgs))
+		r.Use(custommiddleware.GoImport(cfg.PublicURL))
 
 		r.Get("/", wrapHandler(orgsController.Show))
 		r.Get("/repositories", wrapHandler(orgsController.Repositories))
 		r.Get("/stars", wrapHandler(orgsController.Stars))
+		r.Get("/packages", wrapHandler(packagesController.Index))
+		r.Get("/packages/{type}/*", wrapHandler(packagesController.Show))
+		r.Post("/packages/{type}/*", wrapHandler(packagesController.Update))
 		r.Get("/settings", wrapHandler(orgsController.Settings))
-		r.Put("/settings", wrapHandler(orgsController.Update))
+		r.Post("/settings", wrapHandler(orgsController.Update))
+		r.Post("/settings/members", wrapHandler(orgsController.AddMember))
+		r.Post("/settings/members/{userID}/remove", wrapHandler(orgsController.RemoveMember))
+		r.Get("/settings/audit-log", wrapHandler(orgsController.AuditLog))
+		r.Get("/settings/applications", wrapHandler(oauthApplicationsController.Index))
+		r.Get("/settings/applications/new", wrapHandler(oauthApplicationsController.New))
+		r.Post("/settings/applications/new", wrapHandler(oauthApplicationsController.Create))
+		r.Get("/settings/applications/{id}", wrapHandler(oauthApplicationsController.Show))
+		r.Post("/settings/applications/{id}", wrapHandler(oauthApplicationsController.Update))
+		r.Post("/settings/applications/{id}/reset-secret", wrapHandler(oauthApplicationsController.ResetSecret))
+		r.Post("/settings/applications/{id}/delete", wrapHandler(oauthApplicationsController.Delete))
+		r.Get("/settings/hooks", wrapHandler(webhooksController.Index))
+		r.Get("/settings/hooks/new", wrapHandler(webhooksController.New))
+		r.Post("/settings/hooks/new", wrapHandler(webhooksController.Create))
+		r.Get("/settings/hooks/{id}", wrapHandler(webhooksController.Show))
+		r.Post("/settings/hooks/{id}", wrapHandler(webhooksController.Update))
+		r.Post("/settings/hooks/{id}/delete", wrapHandler(webhooksController.Delete))
+		r.Post("/settings/hooks/{id}/ping", wrapHandler(webhooksController.Ping))
+		r.Get("/settings/hooks/{id}/deliveries/{deliveryID}", wrapHandler(webhooksController.ShowDelivery))
+		r.Post("/settings/hooks/{id}/deliveries/{deliveryID}/redeliver", wrapHandler(webhooksController.Redeliver))
+		r.Get("/settings/secrets", wrapHandler(secretsController.Index))
+		r.Post("/settings/secrets", wrapHandler(secretsController.Save))
+		r.Post("/settings/secrets/{id}/delete", wrapHandler(secretsController.Delete))
 		r.Delete("/", wrapHandler(orgsController.Delete))
 
 		r.Route("/{repo}", func(r chi.Router) {
@@ -130,6 +398,18 @@ func main() {
 			r.Post("/settings/collaborators/remove", wrapHandler(reposController.RemoveCollaborator))
 			r.Post("/settings/collaborators/update", wrapHandler(reposController.UpdateCollaboratorRole))
 			r.Post("/settings/delete", wrapHandler(reposController.Delete))
+			r.Get("/settings/hooks", wrapHandler(webhooksController.Index))
+			r.Get("/settings/hooks/new", wrapHandler(webhooksController.New))
+			r.Post("/settings/hooks/new", wrapHandler(webhooksController.Create))
+			r.Get("/settings/hooks/{id}", wrapHandler(webhooksController.Show))
+			r.Post("/settings/hooks/{id}", wrapHandler(webhooksController.Update))
+			r.Post("/settings/hooks/{id}/delete", wrapHandler(webhooksController.Delete))
+			r.Post("/settings/hooks/{id}/ping", wrapHandler(webhooksController.Ping))
+			r.Get("/settings/hooks/{id}/deliveries/{deliveryID}", wrapHandler(webhooksController.ShowDelivery))
+			r.Post("/settings/hooks/{id}/deliveries/{deliveryID}/redeliver", wrapHandler(webhooksController.Redeliver))
+			r.Get("/settings/secrets", wrapHandler(secretsController.Index))
+			r.Post("/settings/secrets", wrapHandler(secretsController.Save))
+			r.Post("/settings/secrets/{id}/delete", wrapHandler(secretsController.Delete))
 
 			// Tree routes - handle both with and without ref
 			r.Get("/tree", wrapHandler(reposController.Tree))
@@ -144,6 +424,25 @@ func main() {
 			r.Post("/tickets/{number}/close", wrapHandler(ticketsController.Close))
 			r.Post("/tickets/{number}/reopen", wrapHandler(ticketsController.Reopen))
 			r.Post("/tickets/{number}/comments", wrapHandler(ticketsController.CreateComment))
+			r.Post("/tickets/{number}/milestone", wrapHandler(ticketsController.SetMilestone))
+			r.Post("/tickets/{number}/subscription", wrapHandler(ticketsController.Subscription))
+
+			// Workflow runs routes
+			r.Get("/runs", wrapHandler(workflowRunsController.Index))
+			r.Get("/runs/{number}", wrapHandler(workflowRunsController.Show))
+			r.Post("/runs/{number}/cancel", wrapHandler(workflowRunsController.Cancel))
+			r.Get("/runs/{number}/jobs/{jobID}/log", wrapHandler(workflowRunsController.Log))
+
+			// Milestones routes
+			r.Get("/milestones", wrapHandler(milestonesController.List))
+			r.Get("/milestones/new", wrapHandler(milestonesController.New))
+			r.Post("/milestones/new", wrapHandler(milestonesController.Create))
+			r.Get("/milestones/{id}/edit", wrapHandler(milestonesController.Edit))
+			r.Post("/milestones/{id}/edit", wrapHandler(milestonesController.Update))
+			r.Get("/milestones/{id}/close", wrapHandler(milestonesController.ConfirmClose))
+			r.Post("/milestones/{id}/close", wrapHandler(milestonesController.Close))
+			r.Post("/milestones/{id}/reopen", wrapHandler(milestonesController.Reopen))
+			r.Post("/milestones/{id}/delete", wrapHandler(milestonesController.Delete))
 
 			r.Get("/info/refs", wrapHandler(gitController.InfoRefs))
 			r.Post("/git-upload-pack", wrapHandler(gitController.UploadPack))
@@ -159,6 +458,23 @@ func main() {
 	}
 }
 
+// newMailer picks the mail transport configured through MAIL_TRANSPORT
+func newMailer(cfg config.Config) services.Mailer {
+	switch cfg.MailTransport {
+	case "smtp":
+		return services.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case "file":
+		return services.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	case "log":
+		slog.Warn("emails are only logged and never delivered, set MAIL_TRANSPORT to smtp to send them")
+		return services.NewLogMailer()
+	default:
+		slog.Error("unknown mail transport, expected log, file or smtp", "transport", cfg.MailTransport)
+		os.Exit(1)
+		return nil
+	}
+}
+
 func wrapHandler(fn func(http.ResponseWriter, *http.Request) error) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if err := fn(w, r); err != nil {
@@ -176,3 +492,16 @@ func wrapHandler(fn func(http.ResponseWriter, *http.Request) error) http.Handler
 		}
 	}
 }
+
+// wrapAPIHandler is wrapHandler for the API, it responds with errors as JSON
+func wrapAPIHandler(fn func(http.ResponseWriter, *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			var httpErr httperror.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode >= 500 {
+				slog.Error("handler error", "error", err, "path", r.URL.Path, "method", r.Method)
+			}
+			httperror.WriteJSON(w, err)
+		}
+	}
+}
@@ -30,5 +30,6 @@ func GetAllCommands() []*cli.Command {
 	return []*cli.Command{
 		LoginCommand(),
 		CommitCommand(),
+		RunnerCommand(),
 	}
 }
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -37,19 +38,25 @@ Once confirmed, your credentials will be stored locally for Git operations.`,
 	)
 }
 
+// cliClientID is the OAuth client ID the server knows the CLI by
+const cliClientID = "hypercommit-cli"
+
+// deviceCodeResponse is the device authorization response, RFC 8628 section 3.2
 type deviceCodeResponse struct {
-	SessionID       string `json:"session_id"`
-	UserCode        string `json:"user_code"`
-	VerificationURL string `json:"verification_url"`
-	ExpiresAt       int64  `json:"expires_at"`
-	Interval        int    `json:"interval"`
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int    `json:"interval"`
 }
 
-type pollResponse struct {
-	Status      string `json:"status"`
-	AccessToken string `json:"access_token,omitempty"`
-	Username    string `json:"username,omitempty"`
-	Error       string `json:"error,omitempty"`
+// tokenResponse is a response of the token endpoint, Error is set if the device isn't approved (yet)
+type tokenResponse struct {
+	AccessToken      string `json:"access_token,omitempty"`
+	Username         string `json:"username,omitempty"`
+	Error            string `json:"error,omitempty"`
+	ErrorDescription string `json:"error_description,omitempty"`
 }
 
 func runLogin(ctx context.Context, cmd *cli.Command) error {
@@ -86,7 +93,7 @@ func runLogin(ctx context.Context, cmd *cli.Command) error {
 		huh.NewGroup(
 			huh.NewConfirm().
 				Title("Open browser to authenticate?").
-				Description(fmt.Sprintf("URL: %s", deviceResp.VerificationURL)).
+				Description(fmt.Sprintf("URL: %s", deviceResp.VerificationURIComplete)).
 				Affirmative("Yes").
 				Negative("No").
 				Value(&openBrowser),
@@ -98,22 +105,22 @@ func runLogin(ctx context.Context, cmd *cli.Command) error {
 	}
 
 	if openBrowser {
-		if err := openURL(deviceResp.VerificationURL); err != nil {
+		if err := openURL(deviceResp.VerificationURIComplete); err != nil {
 			fmt.Printf("⚠️  Could not open browser automatically: %v\n", err)
-			fmt.Printf("Please visit: %s\n\n", deviceResp.VerificationURL)
+			fmt.Printf("Please visit: %s\n\n", deviceResp.VerificationURIComplete)
 		} else {
 			fmt.Println("✓ Opened browser")
 			fmt.Println()
 		}
 	} else {
-		fmt.Printf("Please visit: %s\n\n", deviceResp.VerificationURL)
+		fmt.Printf("Please visit: %s\n\n", deviceResp.VerificationURIComplete)
 	}
 
 	// Step 4: Poll for confirmation
 	fmt.Println("⏳ Waiting for confirmation...")
 	fmt.Println()
 
-	pollResult, err := pollForConfirmation(baseURL, deviceResp.SessionID, deviceResp.Interval, deviceResp.ExpiresAt)
+	pollResult, err := pollForConfirmation(baseURL, deviceResp.DeviceCode, deviceResp.Interval, deviceResp.ExpiresIn)
 	if err != nil {
 		return fmt.Errorf("authentication failed: %w", err)
 	}
@@ -135,7 +142,7 @@ func runLogin(ctx context.Context, cmd *cli.Command) error {
 func initiateDeviceAuth(baseURL string) (deviceCodeResponse, error) {
 	var resp deviceCodeResponse
 
-	httpResp, err := http.Post(baseURL+"/api/auth/device/code", "application/json", nil)
+	httpResp, err := http.PostForm(baseURL+"/oauth/device/code", url.Values{"client_id": {cliClientID}})
 	if err != nil {
 		return resp, err
 	}
@@ -153,23 +160,23 @@ func initiateDeviceAuth(baseURL string) (deviceCodeResponse, error) {
 	return resp, nil
 }
 
-func pollForConfirmation(baseURL, sessionID string, interval int, expiresAt int64) (*pollResponse, error) {
-	ticker := time.NewTicker(time.Duration(interval) * time.Second)
-	defer ticker.Stop()
-
-	timeout := time.Until(time.Unix(expiresAt, 0))
-	timeoutTimer := time.NewTimer(timeout)
+func pollForConfirmation(baseURL, deviceCode string, interval int, expiresIn int64) (*tokenResponse, error) {
+	timeoutTimer := time.NewTimer(time.Duration(expiresIn) * time.Second)
 	defer timeoutTimer.Stop()
 
-	pollURL := fmt.Sprintf("%s/api/auth/device/poll?session_id=%s", baseURL, sessionID)
+	form := url.Values{
+		"grant_type":  {"urn:ietf:params:oauth:grant-type:device_code"},
+		"device_code": {deviceCode},
+		"client_id":   {cliClientID},
+	}
 
 	for {
 		select {
 		case <-timeoutTimer.C:
 			return nil, fmt.Errorf("authentication timed out")
 
-		case <-ticker.C:
-			httpResp, err := http.Get(pollURL)
+		case <-time.After(time.Duration(interval) * time.Second):
+			httpResp, err := http.PostForm(baseURL+"/oauth/token", form)
 			if err != nil {
 				continue // Retry on network errors
 			}
@@ -180,26 +187,33 @@ func pollForConfirmation(baseURL, sessionID string, interval int, expiresAt int6
 				continue
 			}
 
-			var pollResp pollResponse
-			if err := json.Unmarshal(body, &pollResp); err != nil {
+			var tokenResp tokenResponse
+			if err := json.Unmarshal(body, &tokenResp); err != nil {
 				continue
 			}
 
-			switch pollResp.Status {
-			case "confirmed":
+			switch tokenResp.Error {
+			case "":
 				fmt.Println("✓ Authentication confirmed!")
-				return &pollResp, nil
+				return &tokenResp, nil
 
-			case "expired":
-				return nil, fmt.Errorf("authentication code expired: %s", pollResp.Error)
-
-			case "pending":
+			case "authorization_pending":
 				// Continue polling
 				continue
 
-			default:
-				// Unknown status, continue polling
+			case "slow_down":
+				// The server asks to poll less often, RFC 8628 section 3.5
+				interval += 5
 				continue
+
+			case "access_denied":
+				return nil, fmt.Errorf("authentication was denied")
+
+			case "expired_token":
+				return nil, fmt.Errorf("authentication code expired. Please run login again")
+
+			default:
+				return nil, fmt.Errorf("%s: %s", tokenResp.Error, tokenResp.ErrorDescription)
 			}
 		}
 	}
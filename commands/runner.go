@@ -0,0 +1,515 @@
+package commands
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	// runnerLogInterval is how often output of a job is sent to the server
+	runnerLogInterval = time.Second
+	// runnerHeartbeatInterval is how long the runner stays silent at most while a job produces no
+	// output. It bounds how long a cancelled job keeps running, the server gives up on jobs of
+	// runners silent for minutes.
+	runnerHeartbeatInterval = 5 * time.Second
+	// runnerLogChunkSize stays below the largest chunk the server accepts
+	runnerLogChunkSize = 512 << 10
+	// runnerWaitDelay is how long a step may keep its output open after it was killed
+	runnerWaitDelay = 10 * time.Second
+)
+
+// RunnerCommand creates the runner command that executes workflow jobs of a Hypercommit instance
+func RunnerCommand() *cli.Command {
+	hostname, _ := os.Hostname()
+
+	return NewCommandWithFlags(
+		"runner",
+		"Execute workflow jobs",
+		`Run workflow jobs of a Hypercommit instance on this machine.
+
+The runner asks the instance for queued jobs, checks the commit of each job out into a
+temporary workspace and runs its steps there with sh, one after another. Their output is
+streamed back to the instance as it is produced. The first step that fails fails the job.
+
+Steps run as local processes with the permissions of the runner, only run the runner for
+instances whose workflows you trust.`,
+		[]cli.Flag{
+			&cli.StringFlag{
+				Name:     "url",
+				Usage:    "Hypercommit instance URL, such as https://hypercommit.example.com",
+				Sources:  cli.EnvVars("HYPERCOMMIT_URL"),
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:     "token",
+				Usage:    "Runner token of the instance",
+				Sources:  cli.EnvVars("HYPERCOMMIT_RUNNER_TOKEN"),
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "name",
+				Usage: "Name the instance shows for the runner",
+				Value: hostname,
+			},
+			&cli.DurationFlag{
+				Name:  "poll",
+				Usage: "How long to wait before asking again when no job is queued",
+				Value: 5 * time.Second,
+			},
+			&cli.StringFlag{
+				Name:  "workdir",
+				Usage: "Directory to create the workspaces of jobs in",
+				Value: os.TempDir(),
+			},
+			&cli.BoolFlag{
+				Name:  "once",
+				Usage: "Exit after executing one job",
+			},
+		},
+		runRunner,
+	)
+}
+
+// runnerJob is a job handed to the runner, see the RunnerController of the server
+type runnerJob struct {
+	ID        int64  `json:"id"`
+	RunNumber int64  `json:"run_number"`
+	Workflow  string `json:"workflow"`
+	Ref       string `json:"ref"`
+	SHA       string `json:"sha"`
+	Job       struct {
+		Name           string            `json:"name"`
+		TimeoutSeconds int64             `json:"timeout_seconds"`
+		Env            map[string]string `json:"env"`
+		Steps          []runnerStep      `json:"steps"`
+	} `json:"job"`
+	Secrets map[string]string `json:"secrets"`
+}
+
+type runnerStep struct {
+	Name           string            `json:"name"`
+	Run            string            `json:"run"`
+	Env            map[string]string `json:"env"`
+	TimeoutSeconds int64             `json:"timeout_seconds"`
+}
+
+// runnerClient talks to the runner API of an instance
+type runnerClient struct {
+	baseURL string
+	token   string
+	http    *http.Client
+}
+
+func runRunner(ctx context.Context, cmd *cli.Command) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	client := &runnerClient{
+		baseURL: strings.TrimSuffix(cmd.String("url"), "/") + "/api/runner",
+		token:   cmd.String("token"),
+		http:    &http.Client{Timeout: 5 * time.Minute},
+	}
+	name := cmd.String("name")
+	if name == "" {
+		name = "runner"
+	}
+
+	fmt.Printf("Runner %s waiting for jobs\n", name)
+	for {
+		job, err := client.claim(ctx, name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			fmt.Fprintf(os.Stderr, "failed to claim a job: %v\n", err)
+		}
+
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(cmd.Duration("poll")):
+			}
+			continue
+		}
+
+		fmt.Printf("Running %s #%d: %s at %s\n", job.Workflow, job.RunNumber, job.Job.Name, job.SHA)
+		conclusion := executeJob(ctx, client, job, cmd.String("workdir"))
+		fmt.Printf("Finished %s #%d: %s: %s\n", job.Workflow, job.RunNumber, job.Job.Name, conclusion)
+
+		if err := client.finish(job.ID, conclusion); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to finish job %d: %v\n", job.ID, err)
+		}
+		if ctx.Err() != nil || cmd.Bool("once") {
+			return nil
+		}
+	}
+}
+
+// executeJob checks the commit of the job out and runs its steps, returning the conclusion
+func executeJob(ctx context.Context, client *runnerClient, job *runnerJob, workdir string) string {
+	jobCtx, cancelJob := context.WithTimeout(ctx, time.Duration(job.Job.TimeoutSeconds)*time.Second)
+	defer cancelJob()
+
+	log := newRunnerLog(job.Secrets)
+	cancelled := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if log.stream(jobCtx, client, job.ID) {
+			close(cancelled)
+			cancelJob()
+		}
+	}()
+
+	conclusion := runSteps(jobCtx, client, job, workdir, log)
+	select {
+	case <-cancelled:
+		conclusion = "cancelled"
+	default:
+		if ctx.Err() != nil {
+			log.Printf("\nThe runner was stopped.\n")
+			conclusion = "cancelled"
+		}
+	}
+
+	cancelJob()
+	<-done
+	if err := log.flush(client, job.ID); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to send log of job %d: %v\n", job.ID, err)
+	}
+	return conclusion
+}
+
+func runSteps(ctx context.Context, client *runnerClient, job *runnerJob, workdir string, log *runnerLog) string {
+	workspace, err := os.MkdirTemp(workdir, "hypercommit-job-*")
+	if err != nil {
+		log.Printf("Failed to create the workspace: %v\n", err)
+		return "failure"
+	}
+	defer os.RemoveAll(workspace)
+
+	log.Printf("Checking out %s at %s\n", job.Ref, job.SHA)
+	if err := client.checkout(ctx, job.ID, workspace); err != nil {
+		log.Printf("Failed to check out the commit: %v\n", err)
+		return "failure"
+	}
+
+	// The runner token stays with the runner, steps run whatever the workflow says
+	env := slices.DeleteFunc(os.Environ(), func(pair string) bool {
+		return strings.HasPrefix(pair, "HYPERCOMMIT_RUNNER_TOKEN=")
+	})
+	// Secrets come first, the environment of the workflow can override them
+	for key, value := range job.Secrets {
+		env = append(env, key+"="+value)
+	}
+	for key, value := range job.Job.Env {
+		env = append(env, key+"="+value)
+	}
+	env = append(env, "HYPERCOMMIT_WORKSPACE="+workspace)
+
+	for i, step := range job.Job.Steps {
+		log.Printf("\n==> %s\n", step.Name)
+
+		stepCtx, cancel := context.WithTimeout(ctx, time.Duration(step.TimeoutSeconds)*time.Second)
+		cmd := exec.CommandContext(stepCtx, "sh", "-c", step.Run)
+		cmd.Dir = workspace
+		cmd.Env = slices.Clip(env)
+		for key, value := range step.Env {
+			cmd.Env = append(cmd.Env, key+"="+value)
+		}
+		cmd.Stdout = log
+		cmd.Stderr = log
+		cmd.WaitDelay = runnerWaitDelay
+		isolateStep(cmd)
+
+		started := time.Now()
+		err := cmd.Run()
+		timedOut := errors.Is(stepCtx.Err(), context.DeadlineExceeded)
+		cancel()
+
+		if err != nil {
+			var exitErr *exec.ExitError
+			switch {
+			case ctx.Err() != nil && !errors.Is(ctx.Err(), context.DeadlineExceeded):
+				log.Printf("\nStep %d was stopped.\n", i+1)
+			case timedOut:
+				log.Printf("\nStep %d timed out after %s.\n", i+1, time.Since(started).Round(time.Second))
+			case errors.As(err, &exitErr):
+				log.Printf("\nStep %d exited with code %d.\n", i+1, exitErr.ExitCode())
+			default:
+				log.Printf("\nStep %d failed: %v\n", i+1, err)
+			}
+			return "failure"
+		}
+	}
+
+	return "success"
+}
+
+// runnerSecretMask replaces the values of secrets in the output of jobs
+const runnerSecretMask = "***"
+
+// runnerLog collects the output of a job until it is sent to the server, with the values of the
+// secrets of the job masked
+type runnerLog struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+	// secrets are the values to mask, longest first so that values containing others are masked whole
+	secrets [][]byte
+}
+
+func newRunnerLog(secrets map[string]string) *runnerLog {
+	var values []string
+	for _, value := range secrets {
+		// Multi-line values are printed line by line as often as whole
+		for _, part := range append(strings.Split(value, "\n"), value) {
+			part = strings.TrimSpace(part)
+			if part != "" && part != runnerSecretMask && !slices.Contains(values, part) {
+				values = append(values, part)
+			}
+		}
+	}
+	slices.SortFunc(values, func(a, b string) int { return len(b) - len(a) })
+
+	l := &runnerLog{}
+	for _, value := range values {
+		l.secrets = append(l.secrets, []byte(value))
+	}
+	return l
+}
+
+func (l *runnerLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.buffer.Write(p)
+}
+
+func (l *runnerLog) Printf(format string, args ...any) {
+	fmt.Fprintf(l, format, args...)
+}
+
+// take returns the collected output with the secrets masked, emptying the buffer. Unless final, it
+// keeps back the end of the output that could be the start of a secret written in part so far.
+func (l *runnerLog) take(final bool) []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	chunk := bytes.Clone(l.buffer.Bytes())
+	l.buffer.Reset()
+	if len(l.secrets) == 0 {
+		return chunk
+	}
+
+	for _, secret := range l.secrets {
+		chunk = bytes.ReplaceAll(chunk, secret, []byte(runnerSecretMask))
+	}
+	if !final {
+		keep := min(len(chunk), len(l.secrets[0])-1)
+		l.buffer.Write(chunk[len(chunk)-keep:])
+		chunk = chunk[:len(chunk)-keep]
+	}
+	return chunk
+}
+
+// stream sends the output to the server until ctx is done. It reports true if the server cancelled
+// the job.
+func (l *runnerLog) stream(ctx context.Context, client *runnerClient, jobID int64) bool {
+	ticker := time.NewTicker(runnerLogInterval)
+	defer ticker.Stop()
+
+	lastSent := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+
+		chunk := l.take(false)
+		if len(chunk) == 0 && time.Since(lastSent) < runnerHeartbeatInterval {
+			continue
+		}
+
+		cancelled, err := client.appendLog(jobID, chunk)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to send log of job %d: %v\n", jobID, err)
+			continue
+		}
+		lastSent = time.Now()
+		if cancelled {
+			return true
+		}
+	}
+}
+
+// flush sends the output that wasn't sent yet
+func (l *runnerLog) flush(client *runnerClient, jobID int64) error {
+	chunk := l.take(true)
+	if len(chunk) == 0 {
+		return nil
+	}
+	_, err := client.appendLog(jobID, chunk)
+	return err
+}
+
+// claim asks for a queued job, nil if there is none
+func (c *runnerClient) claim(ctx context.Context, name string) (*runnerJob, error) {
+	body, err := json.Marshal(map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(ctx, http.MethodPost, "/jobs/claim", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+
+	job := &runnerJob{}
+	if err := json.NewDecoder(resp.Body).Decode(job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// checkout extracts the files of the commit of the job into dir
+func (c *runnerClient) checkout(ctx context.Context, jobID int64, dir string) error {
+	resp, err := c.do(ctx, http.MethodGet, fmt.Sprintf("/jobs/%d/archive", jobID), "", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	archive := tar.NewReader(resp.Body)
+	for {
+		header, err := archive.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Entries can't escape the workspace
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("archive entry %q is outside the workspace", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := writeArchiveFile(target, archive, header.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(header.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeArchiveFile(path string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// appendLog sends output of the job, it reports true if the job was cancelled
+func (c *runnerClient) appendLog(jobID int64, chunk []byte) (bool, error) {
+	for {
+		part := chunk[:min(len(chunk), runnerLogChunkSize)]
+		chunk = chunk[len(part):]
+
+		resp, err := c.do(context.Background(), http.MethodPost, fmt.Sprintf("/jobs/%d/log", jobID), "application/octet-stream", bytes.NewReader(part))
+		if err != nil {
+			return false, err
+		}
+
+		var result struct {
+			Cancelled bool `json:"cancelled"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return false, err
+		}
+		if result.Cancelled || len(chunk) == 0 {
+			return result.Cancelled, nil
+		}
+	}
+}
+
+func (c *runnerClient) finish(jobID int64, conclusion string) error {
+	body, err := json.Marshal(map[string]string{"conclusion": conclusion})
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(context.Background(), http.MethodPost, fmt.Sprintf("/jobs/%d/finish", jobID), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// do sends a request to the runner API, responses other than 2xx are errors
+func (c *runnerClient) do(ctx context.Context, method, path, contentType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return nil, fmt.Errorf("server returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package commands
+
+import "os/exec"
+
+// isolateStep leaves the step alone, stopping it only stops the shell
+func isolateStep(cmd *exec.Cmd) {}
@@ -1,8 +1,14 @@
 package config
 
 import (
+	"log/slog"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/hypercommithq/hypercommit/env"
 )
@@ -15,18 +21,197 @@ type Config struct {
 	GitHubClientID     string
 	GitHubClientSecret string
 	GitHubCallbackURL  string
+
+	// PublicURL is the externally reachable base URL, used for links in emails
+	PublicURL string
+
+	// TrustedProxies are the reverse proxies whose X-Forwarded-For and X-Real-IP headers tell the
+	// address of clients. Those of other peers are ignored.
+	TrustedProxies []netip.Prefix
+
+	// Mail settings. MailTransport is one of "log", "file" or "smtp".
+	MailTransport string
+	MailFrom      string
+	MailDir       string
+	SMTPHost      string
+	SMTPPort      string
+	SMTPUsername  string
+	SMTPPassword  string
+
+	// Authentication throttling. The rate limits are failed attempts per minute,
+	// DeviceCodeRateLimit is requests per minute to /oauth/device/code.
+	AuthIPRateLimit        int
+	AuthAccountRateLimit   int
+	DeviceCodeRateLimit    int
+	AuthLockoutThreshold   int           // consecutive failures of an account before it is locked
+	AuthLockoutDuration    time.Duration // first lockout, doubled for every further one
+	AuthLockoutMaxDuration time.Duration
+
+	// OIDCProviders are OpenID Connect identity providers users can sign in with,
+	// next to GitHub if GitHubClientID is set
+	OIDCProviders []OIDCProvider
+
+	// RunnerToken authenticates the runners executing workflows on /api/runner, runners are
+	// refused while it is empty
+	RunnerToken string
+
+	// SecretsKey encrypts the secrets of workflows at rest. It defaults to the signing secret, changing
+	// it makes the stored secrets unreadable.
+	SecretsKey string
+
+	// GoModuleCachePath holds the zips of the Go module versions served on /goproxy
+	GoModuleCachePath string
+	// RegistryPath holds the blobs of the container images served on /v2/
+	RegistryPath string
+	// RegistryMaxBlobSize limits the size of the image layers and configs pushed to the registry
+	RegistryMaxBlobSize int64
+	// PackagesPath holds the files of the npm and raw packages served on /api/packages/
+	PackagesPath string
 }
 
+// OIDCProvider configures an OpenID Connect identity provider, see getOIDCProviders
+type OIDCProvider struct {
+	ID            string
+	Name          string
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	UsernameClaim string
+	EmailClaim    string
+	GroupsClaim   string
+	AllowedGroups []string
+	AdminGroups   []string
+}
+
+var oidcProviderIDRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// reservedProviderIDs are paths under /auth that aren't identity providers
+var reservedProviderIDs = []string{"github", "sign-in", "sign-up", "sign-out", "device", "passkey"}
+
 func New() Config {
-	return Config{
+	cfg := Config{
 		HTTPAddr:           env.GetVar("HTTP_ADDR", ":3000"),
 		DatabasePath:       env.GetVar("DATABASE_PATH", "hypercommit.db"),
 		SigningSecret:      getSigningSecret(),
 		ReposBasePath:      env.GetVar("REPOS_BASE_PATH", "repos"),
+		GoModuleCachePath:  env.GetVar("GO_MODULE_CACHE_PATH", "gomodcache"),
+		RegistryPath:       env.GetVar("REGISTRY_PATH", "registry"),
+		PackagesPath:       env.GetVar("PACKAGES_PATH", "packages"),
 		GitHubClientID:     env.GetVar("GITHUB_OAUTH_CLIENT_ID", ""),
 		GitHubClientSecret: env.GetVar("GITHUB_OAUTH_CLIENT_SECRET", ""),
 		GitHubCallbackURL:  env.GetVar("GITHUB_CALLBACK_URL", "http://localhost:3000/auth/github/callback"),
+		PublicURL:          strings.TrimSuffix(env.GetVar("PUBLIC_URL", "http://localhost:3000"), "/"),
+		TrustedProxies:     getTrustedProxies(),
+		MailTransport:      env.GetVar("MAIL_TRANSPORT", "log"),
+		MailFrom:           env.GetVar("MAIL_FROM", "Hypercommit <no-reply@localhost>"),
+		MailDir:            env.GetVar("MAIL_DIR", "mail"),
+		SMTPHost:           env.GetVar("SMTP_HOST", "localhost"),
+		SMTPPort:           env.GetVar("SMTP_PORT", "587"),
+		SMTPUsername:       env.GetVar("SMTP_USERNAME", ""),
+		SMTPPassword:       getSMTPPassword(),
+
+		AuthIPRateLimit:        env.GetInt("AUTH_IP_RATE_LIMIT", 20),
+		AuthAccountRateLimit:   env.GetInt("AUTH_ACCOUNT_RATE_LIMIT", 10),
+		DeviceCodeRateLimit:    env.GetInt("DEVICE_CODE_RATE_LIMIT", 10),
+		AuthLockoutThreshold:   env.GetInt("AUTH_LOCKOUT_THRESHOLD", 5),
+		AuthLockoutDuration:    env.GetDuration("AUTH_LOCKOUT_DURATION", time.Minute),
+		AuthLockoutMaxDuration: env.GetDuration("AUTH_LOCKOUT_MAX_DURATION", time.Hour),
+
+		RegistryMaxBlobSize: int64(env.GetInt("REGISTRY_MAX_BLOB_SIZE", 10<<30)),
+
+		RunnerToken: getCredential("runner_token", "RUNNER_TOKEN"),
+		SecretsKey:  getCredential("secrets_key", "SECRETS_KEY"),
+	}
+	if cfg.SecretsKey == "" {
+		cfg.SecretsKey = cfg.SigningSecret
+	}
+	cfg.OIDCProviders = getOIDCProviders(cfg.PublicURL)
+	return cfg
+}
+
+// getOIDCProviders reads the providers listed in OIDC_PROVIDERS, such as "keycloak,dex".
+// Each one is configured with variables named after its ID, OIDC_KEYCLOAK_ISSUER for keycloak:
+//
+//	OIDC_<ID>_ISSUER          issuer URL, the discovery document is fetched from it
+//	OIDC_<ID>_CLIENT_ID
+//	OIDC_<ID>_CLIENT_SECRET   or the credential oidc_<id>_client_secret
+//	OIDC_<ID>_NAME            shown on the sign-in button, defaults to the ID
+//	OIDC_<ID>_SCOPES          defaults to "openid profile email"
+//	OIDC_<ID>_USERNAME_CLAIM  defaults to "preferred_username"
+//	OIDC_<ID>_EMAIL_CLAIM     defaults to "email"
+//	OIDC_<ID>_GROUPS_CLAIM    defaults to "groups", nested claims like "realm_access.roles" work too
+//	OIDC_<ID>_ALLOWED_GROUPS  comma-separated, only their members can sign in
+//	OIDC_<ID>_ADMIN_GROUPS    comma-separated, their members are administrators of the instance
+//
+// The redirect URL to register at the provider is PUBLIC_URL/auth/<id>/callback.
+// Providers with an invalid ID or without issuer or client ID are skipped.
+func getOIDCProviders(publicURL string) []OIDCProvider {
+	var providers []OIDCProvider
+	for _, id := range splitList(os.Getenv("OIDC_PROVIDERS"), ",") {
+		id = strings.ToLower(id)
+		if !oidcProviderIDRegex.MatchString(id) || slices.Contains(reservedProviderIDs, id) {
+			slog.Error("invalid OIDC provider ID, skipping it", "id", id)
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(id, "-", "_")) + "_"
+		provider := OIDCProvider{
+			ID:            id,
+			Name:          env.GetVar(prefix+"NAME", id),
+			Issuer:        os.Getenv(prefix + "ISSUER"),
+			ClientID:      os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret:  getCredential("oidc_"+id+"_client_secret", prefix+"CLIENT_SECRET"),
+			RedirectURL:   publicURL + "/auth/" + id + "/callback",
+			Scopes:        splitList(env.GetVar(prefix+"SCOPES", "openid profile email"), " "),
+			UsernameClaim: env.GetVar(prefix+"USERNAME_CLAIM", "preferred_username"),
+			EmailClaim:    env.GetVar(prefix+"EMAIL_CLAIM", "email"),
+			GroupsClaim:   env.GetVar(prefix+"GROUPS_CLAIM", "groups"),
+			AllowedGroups: splitList(os.Getenv(prefix+"ALLOWED_GROUPS"), ","),
+			AdminGroups:   splitList(os.Getenv(prefix+"ADMIN_GROUPS"), ","),
+		}
+		if provider.Issuer == "" || provider.ClientID == "" {
+			slog.Error("OIDC provider is missing issuer or client ID, skipping it", "id", id)
+			continue
+		}
+		if !slices.Contains(provider.Scopes, "openid") {
+			provider.Scopes = append([]string{"openid"}, provider.Scopes...)
+		}
+
+		providers = append(providers, provider)
 	}
+	return providers
+}
+
+// getTrustedProxies reads TRUSTED_PROXIES, comma-separated addresses and CIDR ranges such as
+// "127.0.0.1,10.0.0.0/8". Invalid entries are skipped.
+func getTrustedProxies() []netip.Prefix {
+	var proxies []netip.Prefix
+	for _, item := range splitList(os.Getenv("TRUSTED_PROXIES"), ",") {
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(item)
+			if addrErr != nil {
+				slog.Error("invalid trusted proxy, skipping it", "proxy", item, "error", err)
+				continue
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies
+}
+
+// splitList splits s at sep and drops empty items
+func splitList(s, sep string) []string {
+	var items []string
+	for _, item := range strings.Split(s, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 func getSigningSecret() string {
@@ -39,3 +224,19 @@ func getSigningSecret() string {
 
 	return env.GetVar("SIGNING_SECRET", "insecure-dev-secret")
 }
+
+func getSMTPPassword() string {
+	return getCredential("smtp_password", "SMTP_PASSWORD")
+}
+
+// getCredential reads a secret from the systemd credential name, or from the env var key
+func getCredential(name, key string) string {
+	if credsDir := os.Getenv("CREDENTIALS_DIRECTORY"); credsDir != "" {
+		secretPath := filepath.Join(credsDir, name)
+		if data, err := os.ReadFile(secretPath); err == nil {
+			return strings.TrimSpace(string(data))
+		}
+	}
+
+	return env.GetVar(key, "")
+}
@@ -1,17 +1,19 @@
 package controllers
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
-	"fmt"
 	"net/http"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
 	"github.com/hypercommithq/hypercommit/database/repositories"
 	"github.com/hypercommithq/hypercommit/httperror"
 	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
 )
 
 type AccessTokensController interface {
@@ -20,35 +22,35 @@ type AccessTokensController interface {
 }
 
 type accessTokensController struct {
-	tokens repositories.AccessTokensRepository
+	tokens       repositories.AccessTokensRepository
+	tokenService services.AccessTokenService
+	repos        repositories.RepositoriesRepository
+	contributors repositories.ContributorsRepository
+	users        repositories.UsersRepository
+	orgs         repositories.OrganizationsRepository
+	audit        services.AuditService
 }
 
 func NewAccessTokensController(
 	tokens repositories.AccessTokensRepository,
+	tokenService services.AccessTokenService,
+	repos repositories.RepositoriesRepository,
+	contributors repositories.ContributorsRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	audit services.AuditService,
 ) AccessTokensController {
 	return &accessTokensController{
-		tokens: tokens,
+		tokens:       tokens,
+		tokenService: tokenService,
+		repos:        repos,
+		contributors: contributors,
+		users:        users,
+		orgs:         orgs,
+		audit:        audit,
 	}
 }
 
-// generateToken generates a cryptographically secure random token
-func (c *accessTokensController) generateToken() (string, string, error) {
-	// Generate 32 random bytes
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", "", err
-	}
-
-	// Encode as base64 for the raw token (shown to user once)
-	rawToken := base64.URLEncoding.EncodeToString(b)
-
-	// Hash the token for storage
-	hash := sha256.Sum256([]byte(rawToken))
-	tokenHash := fmt.Sprintf("%x", hash)
-
-	return rawToken, tokenHash, nil
-}
-
 func (c *accessTokensController) Create(w http.ResponseWriter, r *http.Request) error {
 	user := middleware.GetUserFromContext(r)
 	if user == nil {
@@ -60,32 +62,64 @@ func (c *accessTokensController) Create(w http.ResponseWriter, r *http.Request)
 		return httperror.New(http.StatusBadRequest, "Invalid form data")
 	}
 
-	name := r.FormValue("name")
+	name := strings.TrimSpace(r.FormValue("name"))
 	if name == "" {
-		// Store error in cookie
-		http.SetCookie(w, &http.Cookie{
-			Name:     "access_token_error",
-			Value:    "Token name is required",
-			Path:     "/",
-			HttpOnly: true,
-			MaxAge:   10,
-		})
-		http.Redirect(w, r, "/settings", http.StatusSeeOther)
-		return nil
+		return c.redirectWithError(w, r, "Token name is required")
 	}
 
-	// Generate token
-	rawToken, tokenHash, err := c.generateToken()
-	if err != nil {
-		return err
+	var scopes []string
+	for _, scope := range models.AccessTokenScopes {
+		if slices.Contains(r.Form["scopes"], scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		return c.redirectWithError(w, r, "Select at least one scope")
+	}
+
+	expiresAt, expirationError := parseTokenExpiration(r.FormValue("expiration"), r.FormValue("expires_on"))
+	if expirationError != "" {
+		return c.redirectWithError(w, r, expirationError)
 	}
 
-	// Save to database
-	_, err = c.tokens.Create(user.ID, name, tokenHash)
+	// Only repositories the user can access may be selected
+	var repositoryIDs []int64
+	if r.FormValue("repository_access") == "selected" {
+		options, err := listTokenRepositories(user, c.repos, c.contributors, c.users, c.orgs)
+		if err != nil {
+			return err
+		}
+
+		for _, value := range r.Form["repository_ids"] {
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return httperror.New(http.StatusBadRequest, "Invalid repository ID")
+			}
+			if !slices.ContainsFunc(options, func(option pages.TokenRepositoryOption) bool { return option.ID == id }) {
+				return httperror.New(http.StatusBadRequest, "Invalid repository ID")
+			}
+			repositoryIDs = append(repositoryIDs, id)
+		}
+
+		if len(repositoryIDs) == 0 {
+			return c.redirectWithError(w, r, "Select at least one repository")
+		}
+	}
+
+	rawToken, token, err := c.tokenService.Create(user.ID, name, scopes, expiresAt, repositoryIDs)
 	if err != nil {
 		return err
 	}
 
+	metadata := map[string]string{"scopes": strings.Join(scopes, " ")}
+	if expiresAt != nil {
+		metadata["expires_at"] = time.Unix(*expiresAt, 0).UTC().Format(time.RFC3339)
+	}
+	if len(repositoryIDs) > 0 {
+		metadata["repositories"] = strconv.Itoa(len(repositoryIDs))
+	}
+	c.audit.Record(r, user, models.AuditAccessTokenCreate, services.AccessTokenAuditTarget(token), metadata)
+
 	// Flash the token to show it once
 	http.SetCookie(w, &http.Cookie{
 		Name:     "new_access_token",
@@ -136,6 +170,7 @@ func (c *accessTokensController) Delete(w http.ResponseWriter, r *http.Request)
 	if err := c.tokens.Delete(tokenID); err != nil {
 		return err
 	}
+	c.audit.Record(r, user, models.AuditAccessTokenDelete, services.AccessTokenAuditTarget(token), nil)
 
 	http.SetCookie(w, &http.Cookie{
 		Name:     "access_token_success",
@@ -147,3 +182,105 @@ func (c *accessTokensController) Delete(w http.ResponseWriter, r *http.Request)
 	http.Redirect(w, r, "/settings#access-tokens", http.StatusSeeOther)
 	return nil
 }
+
+func (c *accessTokensController) redirectWithError(w http.ResponseWriter, r *http.Request, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "access_token_error",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/settings#access-tokens", http.StatusSeeOther)
+	return nil
+}
+
+// parseTokenExpiration turns the expiration select ("7", "30", "90", "365", "custom" or "never")
+// and the custom date into an expiry timestamp, nil meaning the token never expires.
+// The second result is a validation message for the user.
+func parseTokenExpiration(expiration, expiresOn string) (*int64, string) {
+	switch expiration {
+	case "never":
+		return nil, ""
+	case "custom":
+		date, err := time.ParseInLocation("2006-01-02", expiresOn, time.Local)
+		if err != nil {
+			return nil, "Please enter a valid expiration date"
+		}
+		// Valid until the end of the chosen day
+		expiresAt := date.AddDate(0, 0, 1).Unix()
+		if expiresAt <= time.Now().Unix() {
+			return nil, "The expiration date must be in the future"
+		}
+		return &expiresAt, ""
+	default:
+		days, err := strconv.Atoi(expiration)
+		if err != nil || days <= 0 || days > 365 {
+			return nil, "Please choose a valid expiration"
+		}
+		expiresAt := time.Now().AddDate(0, 0, days).Unix()
+		return &expiresAt, ""
+	}
+}
+
+// listTokenRepositories returns the repositories a token of the user can be restricted to:
+// the ones the user owns and the ones they contribute to
+func listTokenRepositories(
+	user *models.User,
+	repos repositories.RepositoriesRepository,
+	contributors repositories.ContributorsRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+) ([]pages.TokenRepositoryOption, error) {
+	owned, err := repos.FindAllByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	options := make([]pages.TokenRepositoryOption, 0, len(owned))
+	for _, repo := range owned {
+		options = append(options, pages.TokenRepositoryOption{ID: repo.ID, FullName: user.Username + "/" + repo.Name})
+	}
+
+	contributions, err := contributors.FindAllByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, contribution := range contributions {
+		repo, err := repos.FindByID(contribution.RepositoryID)
+		if err != nil {
+			return nil, err
+		}
+		if repo == nil || slices.ContainsFunc(options, func(option pages.TokenRepositoryOption) bool { return option.ID == repo.ID }) {
+			continue
+		}
+
+		owner := ""
+		if repo.OwnerUserID != nil {
+			ownerUser, err := users.FindByID(*repo.OwnerUserID)
+			if err != nil {
+				return nil, err
+			}
+			if ownerUser != nil {
+				owner = ownerUser.Username
+			}
+		} else if repo.OwnerOrgID != nil {
+			org, err := orgs.FindByID(*repo.OwnerOrgID)
+			if err != nil {
+				return nil, err
+			}
+			if org != nil {
+				owner = org.Username
+			}
+		}
+
+		options = append(options, pages.TokenRepositoryOption{ID: repo.ID, FullName: owner + "/" + repo.Name})
+	}
+
+	slices.SortFunc(options, func(a, b pages.TokenRepositoryOption) int {
+		return strings.Compare(a.FullName, b.FullName)
+	})
+
+	return options, nil
+}
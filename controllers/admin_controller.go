@@ -0,0 +1,386 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/httputil"
+	custommiddleware "github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// adminUsersPageSize is how many users the user list shows at once
+const adminUsersPageSize = 100
+
+// AdminController serves the site administration area. Its routes are guarded by middleware.RequireAdmin.
+type AdminController interface {
+	Overview(w http.ResponseWriter, r *http.Request) error
+	UpdateSettings(w http.ResponseWriter, r *http.Request) error
+	Users(w http.ResponseWriter, r *http.Request) error
+	Suspend(w http.ResponseWriter, r *http.Request) error
+	Unsuspend(w http.ResponseWriter, r *http.Request) error
+	ResetPassword(w http.ResponseWriter, r *http.Request) error
+	Promote(w http.ResponseWriter, r *http.Request) error
+	Demote(w http.ResponseWriter, r *http.Request) error
+	Delete(w http.ResponseWriter, r *http.Request) error
+	Repositories(w http.ResponseWriter, r *http.Request) error
+}
+
+type adminController struct {
+	users            repositories.UsersRepository
+	orgs             repositories.OrganizationsRepository
+	repos            repositories.RepositoriesRepository
+	sessions         repositories.SessionsRepository
+	passwordResets   repositories.PasswordResetsRepository
+	instanceSettings repositories.InstanceSettingsRepository
+	deletion         services.RepositoryDeletionService
+	gitService       services.GitService
+	emailService     services.EmailService
+	audit            services.AuditService
+	databasePath     string
+	reposBasePath    string
+	publicURL        string
+}
+
+func NewAdminController(
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	repos repositories.RepositoriesRepository,
+	sessions repositories.SessionsRepository,
+	passwordResets repositories.PasswordResetsRepository,
+	instanceSettings repositories.InstanceSettingsRepository,
+	deletion services.RepositoryDeletionService,
+	gitService services.GitService,
+	emailService services.EmailService,
+	audit services.AuditService,
+	databasePath string,
+	reposBasePath string,
+	publicURL string,
+) AdminController {
+	return &adminController{
+		users:            users,
+		orgs:             orgs,
+		repos:            repos,
+		sessions:         sessions,
+		passwordResets:   passwordResets,
+		instanceSettings: instanceSettings,
+		deletion:         deletion,
+		gitService:       gitService,
+		emailService:     emailService,
+		audit:            audit,
+		databasePath:     databasePath,
+		reposBasePath:    reposBasePath,
+		publicURL:        publicURL,
+	}
+}
+
+func (c *adminController) Overview(w http.ResponseWriter, r *http.Request) error {
+	stats, err := c.instanceSettings.Stats()
+	if err != nil {
+		return err
+	}
+
+	settings, err := c.instanceSettings.Find()
+	if err != nil {
+		return err
+	}
+
+	// The write-ahead log holds recent writes that aren't in the database file yet
+	var databaseSize int64
+	for _, path := range []string{c.databasePath, c.databasePath + "-wal"} {
+		if info, err := os.Stat(path); err == nil {
+			databaseSize += info.Size()
+		}
+	}
+
+	repositoryStorage, err := c.gitService.DiskUsage(c.reposBasePath)
+	if err != nil {
+		return err
+	}
+
+	success, _ := c.takeNotice(w, r)
+	return pages.AdminOverview(r, &pages.AdminOverviewData{
+		Stats:             stats,
+		Settings:          settings,
+		DatabaseSize:      databaseSize,
+		RepositoryStorage: repositoryStorage,
+		Success:           success,
+	}).Render(w, r)
+}
+
+func (c *adminController) UpdateSettings(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	settings := &models.InstanceSettings{
+		SignUpDisabled:               r.FormValue("sign_up_disabled") == "1",
+		WebhooksAllowPrivateNetworks: r.FormValue("webhooks_allow_private_networks") == "1",
+	}
+	if err := c.instanceSettings.Update(settings); err != nil {
+		return err
+	}
+
+	admin := custommiddleware.GetUserFromContext(r)
+	c.audit.Record(r, admin, models.AuditAdminSettingsUpdate, services.InstanceAuditTarget(), map[string]string{
+		"sign_up_disabled":                strconv.FormatBool(settings.SignUpDisabled),
+		"webhooks_allow_private_networks": strconv.FormatBool(settings.WebhooksAllowPrivateNetworks),
+	})
+
+	return c.redirectWithNotice(w, r, "/admin", "admin_success", "Settings saved")
+}
+
+func (c *adminController) Users(w http.ResponseWriter, r *http.Request) error {
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+
+	users, err := c.users.Search(query, adminUsersPageSize+1)
+	if err != nil {
+		return err
+	}
+
+	truncated := len(users) > adminUsersPageSize
+	if truncated {
+		users = users[:adminUsersPageSize]
+	}
+
+	success, failure := c.takeNotice(w, r)
+	return pages.AdminUsers(r, &pages.AdminUsersData{
+		CurrentUser: custommiddleware.GetUserFromContext(r),
+		Users:       users,
+		Query:       query,
+		Truncated:   truncated,
+		Success:     success,
+		Error:       failure,
+	}).Render(w, r)
+}
+
+// Suspend signs the user out everywhere and keeps them from signing in, until they are unsuspended
+func (c *adminController) Suspend(w http.ResponseWriter, r *http.Request) error {
+	admin, user, err := c.targetUser(w, r)
+	if err != nil || user == nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if err := c.users.SetSuspended(user.ID, &now); err != nil {
+		return err
+	}
+	if err := c.sessions.DeleteAllByUserID(user.ID); err != nil {
+		return err
+	}
+
+	slog.Info("user suspended", "username", user.Username, "admin", admin.Username)
+	c.audit.Record(r, admin, models.AuditAdminUserSuspend, services.UserAuditTarget(user), nil)
+
+	return c.redirectToUsers(w, r, "admin_success", "@"+user.Username+" suspended")
+}
+
+func (c *adminController) Unsuspend(w http.ResponseWriter, r *http.Request) error {
+	admin, user, err := c.targetUser(w, r)
+	if err != nil || user == nil {
+		return err
+	}
+
+	if err := c.users.SetSuspended(user.ID, nil); err != nil {
+		return err
+	}
+
+	slog.Info("user unsuspended", "username", user.Username, "admin", admin.Username)
+	c.audit.Record(r, admin, models.AuditAdminUserUnsuspend, services.UserAuditTarget(user), nil)
+
+	return c.redirectToUsers(w, r, "admin_success", "@"+user.Username+" unsuspended")
+}
+
+// ResetPassword removes the user's password and signs them out, then emails them a link to choose a new one
+func (c *adminController) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	admin, user, err := c.targetUser(w, r)
+	if err != nil || user == nil {
+		return err
+	}
+
+	user.Password = nil
+	if err := c.users.Update(user); err != nil {
+		return err
+	}
+	if err := c.sessions.DeleteAllByUserID(user.ID); err != nil {
+		return err
+	}
+	if err := sendPasswordResetLink(c.passwordResets, c.emailService, c.publicURL, user, httputil.ClientIP(r)); err != nil {
+		return err
+	}
+
+	slog.Info("password reset forced", "username", user.Username, "admin", admin.Username)
+	c.audit.Record(r, admin, models.AuditAdminUserPasswordReset, services.UserAuditTarget(user), nil)
+
+	return c.redirectToUsers(w, r, "admin_success", "Password reset link sent to @"+user.Username)
+}
+
+func (c *adminController) Promote(w http.ResponseWriter, r *http.Request) error {
+	return c.setAdmin(w, r, true)
+}
+
+func (c *adminController) Demote(w http.ResponseWriter, r *http.Request) error {
+	return c.setAdmin(w, r, false)
+}
+
+func (c *adminController) setAdmin(w http.ResponseWriter, r *http.Request, isAdmin bool) error {
+	admin, user, err := c.targetUser(w, r)
+	if err != nil || user == nil {
+		return err
+	}
+
+	if err := c.users.SetAdmin(user.ID, isAdmin); err != nil {
+		return err
+	}
+
+	action, message := models.AuditAdminUserPromote, "@"+user.Username+" is now an administrator"
+	if !isAdmin {
+		action, message = models.AuditAdminUserDemote, "@"+user.Username+" is no longer an administrator"
+	}
+
+	slog.Info("administrator changed", "username", user.Username, "admin", admin.Username, "is_admin", isAdmin)
+	c.audit.Record(r, admin, action, services.UserAuditTarget(user), nil)
+
+	return c.redirectToUsers(w, r, "admin_success", message)
+}
+
+// Delete deletes the user along with the repositories and packages they own. Organizations they are a member of are kept.
+func (c *adminController) Delete(w http.ResponseWriter, r *http.Request) error {
+	admin, user, err := c.targetUser(w, r)
+	if err != nil || user == nil {
+		return err
+	}
+
+	deleted, err := c.deletion.DeleteUser(user, admin)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("user deleted", "username", user.Username, "admin", admin.Username, "repositories", deleted)
+	c.audit.Record(r, admin, models.AuditAdminUserDelete, services.UserAuditTarget(user), map[string]string{
+		"repositories": strconv.Itoa(deleted),
+	})
+
+	return c.redirectToUsers(w, r, "admin_success", "@"+user.Username+" deleted")
+}
+
+// Repositories lists every repository with its size on disk, largest first
+func (c *adminController) Repositories(w http.ResponseWriter, r *http.Request) error {
+	repos, err := c.repos.FindAll()
+	if err != nil {
+		return err
+	}
+
+	data := &pages.AdminRepositoriesData{}
+	for _, repo := range repos {
+		size, err := c.gitService.DiskUsage(c.gitService.RepositoryPath(repo))
+		if err != nil {
+			return err
+		}
+
+		var ownerUsername string
+		if repo.OwnerUserID != nil {
+			user, err := c.users.FindByID(*repo.OwnerUserID)
+			if err == nil && user != nil {
+				ownerUsername = user.Username
+			}
+		} else if repo.OwnerOrgID != nil {
+			org, err := c.orgs.FindByID(*repo.OwnerOrgID)
+			if err == nil && org != nil {
+				ownerUsername = org.Username
+			}
+		}
+
+		data.Repositories = append(data.Repositories, pages.AdminRepository{
+			Repository:    repo,
+			OwnerUsername: ownerUsername,
+			DiskUsage:     size,
+		})
+		data.TotalSize += size
+	}
+
+	sort.SliceStable(data.Repositories, func(i, j int) bool {
+		return data.Repositories[i].DiskUsage > data.Repositories[j].DiskUsage
+	})
+
+	return pages.AdminRepositories(r, data).Render(w, r)
+}
+
+// targetUser returns the signed in administrator and the user of the {id} URL parameter.
+// Administrators can't act on their own account, they are sent back with an error and a nil user.
+func (c *adminController) targetUser(w http.ResponseWriter, r *http.Request) (*models.User, *models.User, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, nil, httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, nil, httperror.NotFound("user not found")
+	}
+
+	user, err := c.users.FindByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, httperror.NotFound("user not found")
+	}
+
+	admin := custommiddleware.GetUserFromContext(r)
+	if admin.ID == user.ID {
+		return nil, nil, c.redirectToUsers(w, r, "admin_error", "You can't change your own account from the administration area")
+	}
+
+	return admin, user, nil
+}
+
+// redirectToUsers goes back to the user list, keeping the search the action was taken from
+func (c *adminController) redirectToUsers(w http.ResponseWriter, r *http.Request, cookieName, message string) error {
+	target := "/admin/users"
+	if query := r.FormValue("q"); query != "" {
+		target += "?q=" + url.QueryEscape(query)
+	}
+	return c.redirectWithNotice(w, r, target, cookieName, message)
+}
+
+func (c *adminController) redirectWithNotice(w http.ResponseWriter, r *http.Request, target, cookieName, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieName,
+		Value:    message,
+		Path:     "/admin",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, target, http.StatusSeeOther)
+	return nil
+}
+
+// takeNotice returns and clears the success and error messages of the last action
+func (c *adminController) takeNotice(w http.ResponseWriter, r *http.Request) (string, string) {
+	messages := make([]string, 2)
+	for i, name := range []string{"admin_success", "admin_error"} {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			continue
+		}
+		messages[i] = cookie.Value
+		http.SetCookie(w, &http.Cookie{
+			Name:   name,
+			Value:  "",
+			Path:   "/admin",
+			MaxAge: -1,
+		})
+	}
+	return messages[0], messages[1]
+}
@@ -0,0 +1,462 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+const (
+	// apiPerPage is the page size of API lists unless the client asks for another one with per_page
+	apiPerPage    = 30
+	apiMaxPerPage = 100
+	// apiMaxBodySize limits the JSON documents clients can send
+	apiMaxBodySize = 1 << 20
+)
+
+// apiPermission is what a user may do with a repository, each permission includes the lower ones
+type apiPermission int
+
+const (
+	apiPermissionNone apiPermission = iota
+	apiPermissionRead
+	apiPermissionWrite
+	apiPermissionAdmin
+)
+
+func (p apiPermission) String() string {
+	switch p {
+	case apiPermissionRead:
+		return "read"
+	case apiPermissionWrite:
+		return "write"
+	case apiPermissionAdmin:
+		return "admin"
+	}
+	return "none"
+}
+
+// apiAccess decides what the user and access token of an API request may do. It is shared by the
+// API controllers.
+type apiAccess struct {
+	repos        repositories.RepositoriesRepository
+	users        repositories.UsersRepository
+	orgs         repositories.OrganizationsRepository
+	orgMembers   repositories.OrganizationMembersRepository
+	contributors repositories.ContributorsRepository
+	accessTokens services.AccessTokenService
+	twoFactor    services.TwoFactorService
+}
+
+// requireScope fails if the request's access token lacks scope. Anonymous requests have no token and
+// are limited by what they can see instead.
+func (a *apiAccess) requireScope(r *http.Request, scope string) error {
+	token := middleware.GetAccessTokenFromContext(r)
+	if token == nil || scope == "" || a.accessTokens.HasScope(token, scope) {
+		return nil
+	}
+	return httperror.Forbidden("access token is missing the " + scope + " scope")
+}
+
+// authenticateToken returns the user of the access token of a request to a package protocol, which
+// is anonymous without one. The token is the password of basic authentication or a bearer token.
+func (a *apiAccess) authenticateToken(r *http.Request) (*models.User, *models.AccessToken, error) {
+	username, rawToken, basic := r.BasicAuth()
+	if !basic {
+		var bearer bool
+		rawToken, bearer = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !bearer {
+			return nil, nil, nil
+		}
+	}
+
+	token, err := a.accessTokens.Authenticate(strings.TrimSpace(rawToken))
+	if err != nil {
+		return nil, nil, err
+	}
+	if token == nil {
+		return nil, nil, httperror.Unauthorized("invalid or expired access token")
+	}
+	user, err := a.users.FindByID(token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || (basic && !strings.EqualFold(username, user.Username)) {
+		return nil, nil, httperror.Unauthorized("invalid or expired access token")
+	}
+	if user.SuspendedAt != nil {
+		return nil, nil, httperror.Forbidden(accountSuspendedMessage)
+	}
+	if !a.accessTokens.HasScope(token, models.ScopeRepoRead) {
+		return nil, nil, httperror.Forbidden("access token is missing the " + models.ScopeRepoRead + " scope")
+	}
+	return user, token, nil
+}
+
+// requireAPIUser returns the user of the request, who has to be authenticated
+func requireAPIUser(r *http.Request) (*models.User, error) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		return nil, httperror.Unauthorized("authentication required")
+	}
+	return user, nil
+}
+
+// permission returns what the user may do with the repository. Owners of the repository and of its
+// organization administer it, organization members and collaborators get their role.
+func (a *apiAccess) permission(repo *models.Repository, user *models.User) (apiPermission, error) {
+	public := apiPermissionNone
+	if repo.Visibility == "public" {
+		public = apiPermissionRead
+	}
+	if user == nil {
+		return public, nil
+	}
+
+	if repo.OwnerUserID != nil && *repo.OwnerUserID == user.ID {
+		return apiPermissionAdmin, nil
+	}
+
+	permission := apiPermissionNone
+	if repo.OwnerOrgID != nil {
+		membership, err := a.orgMembers.FindByOrganizationAndUser(*repo.OwnerOrgID, user.ID)
+		if err != nil {
+			return apiPermissionNone, err
+		}
+		if membership != nil && membership.Role == models.OrganizationRoleOwner {
+			permission = apiPermissionAdmin
+		} else if membership != nil {
+			permission = apiPermissionWrite
+		}
+	}
+
+	contributor, err := a.contributors.FindByRepositoryAndUser(repo.ID, user.ID)
+	if err != nil {
+		return apiPermissionNone, err
+	}
+	if contributor != nil {
+		switch contributor.Role {
+		case "admin":
+			permission = max(permission, apiPermissionAdmin)
+		case "write":
+			permission = max(permission, apiPermissionWrite)
+		default:
+			permission = max(permission, apiPermissionRead)
+		}
+	}
+
+	// Members of organizations that require two-factor authentication need it to use their access
+	if permission > public && repo.OwnerOrgID != nil {
+		org, err := a.orgs.FindByID(*repo.OwnerOrgID)
+		if err != nil {
+			return apiPermissionNone, err
+		}
+		missing, err := missingRequiredTwoFactor(org, user.ID, a.twoFactor)
+		if err != nil {
+			return apiPermissionNone, err
+		}
+		if missing {
+			return public, nil
+		}
+	}
+
+	return max(permission, public), nil
+}
+
+// findRepository returns the repository of the URL if the request may use it with the permission.
+// Repositories the user can't see are reported as not found.
+func (a *apiAccess) findRepository(r *http.Request, need apiPermission) (*models.Repository, apiPermission, error) {
+	repo, err := a.repos.FindByOwnerAndName(chi.URLParam(r, "owner"), chi.URLParam(r, "repo"))
+	if err != nil {
+		return nil, apiPermissionNone, err
+	}
+	if repo == nil {
+		return nil, apiPermissionNone, httperror.NotFound("repository not found")
+	}
+
+	user := middleware.GetUserFromContext(r)
+	permission, err := a.permission(repo, user)
+	if err != nil {
+		return nil, apiPermissionNone, err
+	}
+	if permission == apiPermissionNone {
+		return nil, apiPermissionNone, httperror.NotFound("repository not found")
+	}
+
+	if token := middleware.GetAccessTokenFromContext(r); token != nil && !a.accessTokens.AllowsRepository(token, repo.ID) {
+		return nil, apiPermissionNone, httperror.Forbidden("access token is not allowed to access this repository")
+	}
+
+	if permission < need {
+		if user == nil {
+			return nil, apiPermissionNone, httperror.Unauthorized("authentication required")
+		}
+		return nil, apiPermissionNone, httperror.Forbidden(need.String() + " access to the repository is required")
+	}
+
+	return repo, permission, nil
+}
+
+// readableRepository returns the repository of the URL and the permission the user has on it.
+// Repositories the user can't read are reported as not found, as if they didn't exist.
+func (a *apiAccess) readableRepository(r *http.Request) (*models.Repository, apiPermission, error) {
+	repo, err := a.repos.FindByOwnerAndName(chi.URLParam(r, "owner"), chi.URLParam(r, "repo"))
+	if err != nil {
+		return nil, apiPermissionNone, httperror.New(500, "failed to find repository")
+	}
+	if repo == nil {
+		return nil, apiPermissionNone, httperror.NotFound("repository not found")
+	}
+
+	permission, err := a.permission(repo, middleware.GetUserFromContext(r))
+	if err != nil {
+		return nil, apiPermissionNone, err
+	}
+	if permission < apiPermissionRead {
+		return nil, apiPermissionNone, httperror.NotFound("repository not found")
+	}
+
+	return repo, permission, nil
+}
+
+// repositoryReader returns who the request lists repositories for: its user and, for access tokens
+// limited to some repositories, those
+func repositoryReader(r *http.Request) repositories.RepositoryReader {
+	var reader repositories.RepositoryReader
+	if user := middleware.GetUserFromContext(r); user != nil {
+		reader.UserID = user.ID
+	}
+	if token := middleware.GetAccessTokenFromContext(r); token != nil {
+		reader.RepositoryIDs = token.RepositoryIDs
+	}
+	return reader
+}
+
+// findOrganization returns the organization of the {org} URL parameter
+func (a *apiAccess) findOrganization(r *http.Request) (*models.Organization, error) {
+	org, err := a.orgs.FindByUsername(chi.URLParam(r, "org"))
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, httperror.NotFound("organization not found")
+	}
+	return org, nil
+}
+
+// organizationRole returns the role of the user in the organization, empty if they aren't a member
+func (a *apiAccess) organizationRole(org *models.Organization, user *models.User) (string, error) {
+	if user == nil {
+		return "", nil
+	}
+
+	membership, err := a.orgMembers.FindByOrganizationAndUser(org.ID, user.ID)
+	if err != nil || membership == nil {
+		return "", err
+	}
+	return membership.Role, nil
+}
+
+// apiUsernames looks up usernames by ID, remembering the ones it found for the rest of the request
+type apiUsernames struct {
+	users     repositories.UsersRepository
+	usernames map[int64]string
+}
+
+func newAPIUsernames(users repositories.UsersRepository) *apiUsernames {
+	return &apiUsernames{users: users, usernames: map[int64]string{}}
+}
+
+// get returns the username of the user, empty if they were deleted
+func (u *apiUsernames) get(id int64) (string, error) {
+	if username, ok := u.usernames[id]; ok {
+		return username, nil
+	}
+
+	user, err := u.users.FindByID(id)
+	if err != nil {
+		return "", err
+	}
+	if user != nil {
+		u.usernames[id] = user.Username
+	}
+	return u.usernames[id], nil
+}
+
+// repositoryOwner returns the username of the user or organization that owns the repository
+func (a *apiAccess) repositoryOwner(repo *models.Repository) (string, error) {
+	if repo.OwnerOrgID != nil {
+		org, err := a.orgs.FindByID(*repo.OwnerOrgID)
+		if err != nil || org == nil {
+			return "", err
+		}
+		return org.Username, nil
+	}
+
+	owner, err := a.users.FindByID(*repo.OwnerUserID)
+	if err != nil || owner == nil {
+		return "", err
+	}
+	return owner.Username, nil
+}
+
+// apiTime formats a timestamp of the database for API responses
+func apiTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
+
+func apiTimePtr(unix *int64) *string {
+	if unix == nil {
+		return nil
+	}
+	formatted := apiTime(*unix)
+	return &formatted
+}
+
+// decodeAPIBody reads the JSON document of the request into v
+func decodeAPIBody(w http.ResponseWriter, r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBodySize))
+	if err := decoder.Decode(v); err != nil {
+		if errors.Is(err, io.EOF) {
+			return httperror.BadRequest("request body is required")
+		}
+		return httperror.BadRequest("invalid JSON body: " + err.Error())
+	}
+	return nil
+}
+
+// writeAPIJSON responds with body as JSON. Successful GET responses carry an ETag of the document, a
+// request whose If-None-Match matches it gets 304 Not Modified instead.
+func writeAPIJSON(w http.ResponseWriter, r *http.Request, status int, body any) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	if r.Method == http.MethodGet && status == http.StatusOK {
+		sum := sha256.Sum256(data)
+		etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if etagMatches(r.Header.Get("If-None-Match"), etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return nil
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// etagMatches reports whether an If-None-Match header matches the etag, comparing weakly as
+// RFC 9110 section 13.1.2 asks
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// apiPage returns the page of a list the request asks for with per_page and cursor. Its limit is one
+// more than per_page, so that paginate can tell whether there is a next page. Cursors are opaque to
+// clients.
+func apiPage(r *http.Request) (repositories.Page, error) {
+	query := r.URL.Query()
+
+	perPage := apiPerPage
+	if value := query.Get("per_page"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			return repositories.Page{}, httperror.BadRequest("per_page must be a positive number")
+		}
+		perPage = min(n, apiMaxPerPage)
+	}
+
+	var after int64
+	if cursor := query.Get("cursor"); cursor != "" {
+		decoded, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err == nil {
+			after, err = strconv.ParseInt(string(decoded), 10, 64)
+		}
+		if err != nil {
+			return repositories.Page{}, httperror.BadRequest("invalid cursor")
+		}
+	}
+
+	return repositories.Page{After: after, Limit: perPage + 1}, nil
+}
+
+// paginate cuts items, found for the page, down to per_page. If there are more, a Link header points
+// to the next page.
+func paginate[T any](w http.ResponseWriter, r *http.Request, page repositories.Page, items []T, id func(T) int64) []T {
+	perPage := page.Limit - 1
+	if len(items) <= perPage {
+		return items
+	}
+	items = items[:perPage]
+
+	next := *r.URL
+	query := next.Query()
+	query.Set("cursor", base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id(items[len(items)-1]), 10))))
+	next.RawQuery = query.Encode()
+	w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.RequestURI()))
+
+	return items
+}
+
+type apiUserJSON struct {
+	ID          int64  `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	IsAdmin     bool   `json:"is_admin"`
+	// Email is only shown to the user themselves
+	Email     string `json:"email,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+func newAPIUserJSON(user *models.User) apiUserJSON {
+	return apiUserJSON{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		IsAdmin:     user.IsAdmin,
+		CreatedAt:   apiTime(user.CreatedAt),
+	}
+}
+
+type apiOrganizationJSON struct {
+	ID               int64  `json:"id"`
+	Username         string `json:"username"`
+	DisplayName      string `json:"display_name"`
+	RequireTwoFactor bool   `json:"require_two_factor"`
+	CreatedAt        string `json:"created_at"`
+}
+
+func newAPIOrganizationJSON(org *models.Organization) apiOrganizationJSON {
+	return apiOrganizationJSON{
+		ID:               org.ID,
+		Username:         org.Username,
+		DisplayName:      org.DisplayName,
+		RequireTwoFactor: org.RequireTwoFactor,
+		CreatedAt:        apiTime(org.CreatedAt),
+	}
+}
@@ -0,0 +1,297 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+const (
+	// defaultStatusContext is the context of statuses reported without one
+	defaultStatusContext       = "default"
+	maxStatusContextLength     = 255
+	maxStatusDescriptionLength = 1000
+)
+
+// commitIDRegex matches full SHA-1 and SHA-256 commit IDs
+var commitIDRegex = regexp.MustCompile(`^([0-9a-f]{40}|[0-9a-f]{64})$`)
+
+// APICommitStatusesController lets external systems such as CI report results for commits on
+// /api/v1. People with write access report statuses, everyone who can read the repository sees them.
+type APICommitStatusesController interface {
+	Create(w http.ResponseWriter, r *http.Request) error
+	// List returns the statuses of the commit a ref names
+	List(w http.ResponseWriter, r *http.Request) error
+	// Combined returns the combined status of the commit a ref names
+	Combined(w http.ResponseWriter, r *http.Request) error
+}
+
+type apiCommitStatusesController struct {
+	*apiAccess
+	statuses     repositories.CommitStatusesRepository
+	commitStatus services.CommitStatusService
+	git          services.GitService
+}
+
+func NewAPICommitStatusesController(
+	statuses repositories.CommitStatusesRepository,
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	accessTokens services.AccessTokenService,
+	twoFactor services.TwoFactorService,
+	commitStatus services.CommitStatusService,
+	git services.GitService,
+) APICommitStatusesController {
+	return &apiCommitStatusesController{
+		apiAccess: &apiAccess{
+			repos:        repos,
+			users:        users,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			contributors: contributors,
+			accessTokens: accessTokens,
+			twoFactor:    twoFactor,
+		},
+		statuses:     statuses,
+		commitStatus: commitStatus,
+		git:          git,
+	}
+}
+
+type apiCommitStatusJSON struct {
+	ID          int64   `json:"id"`
+	SHA         string  `json:"sha"`
+	Context     string  `json:"context"`
+	State       string  `json:"state" enum:"pending,success,failure,error"`
+	TargetURL   *string `json:"target_url"`
+	Description *string `json:"description"`
+	// Creator is empty if the user who reported the status was deleted
+	Creator   string `json:"creator"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type apiCombinedStatusJSON struct {
+	SHA string `json:"sha"`
+	// State is pending while nothing reported a status
+	State      string                `json:"state" enum:"pending,success,failure"`
+	TotalCount int                   `json:"total_count"`
+	Statuses   []apiCommitStatusJSON `json:"statuses"`
+}
+
+type apiCommitStatusRequest struct {
+	State       string  `json:"state" enum:"pending,success,failure,error"`
+	TargetURL   *string `json:"target_url"`
+	Description *string `json:"description"`
+	// Context defaults to "default"
+	Context *string `json:"context"`
+}
+
+func (c *apiCommitStatusesController) Create(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+	if err := c.requireScope(r, models.ScopeRepoWrite); err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	sha := strings.ToLower(chi.URLParam(r, "sha"))
+	if !commitIDRegex.MatchString(sha) {
+		return httperror.BadRequest("sha must be a full commit ID")
+	}
+	resolved, err := c.git.ResolveCommit(c.git.RepositoryPath(repo), sha)
+	if err != nil {
+		return err
+	}
+	if resolved != sha {
+		return httperror.NotFound("commit not found")
+	}
+
+	var request apiCommitStatusRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	switch request.State {
+	case models.CommitStatePending, models.CommitStateSuccess, models.CommitStateFailure, models.CommitStateError:
+	default:
+		return httperror.BadRequest("state must be pending, success, failure or error")
+	}
+
+	statusContext := defaultStatusContext
+	if request.Context != nil {
+		statusContext = strings.TrimSpace(*request.Context)
+	}
+	if statusContext == "" || utf8.RuneCountInString(statusContext) > maxStatusContextLength {
+		return httperror.BadRequest("context must be 1 to 255 characters")
+	}
+
+	var targetURL *string
+	if request.TargetURL != nil {
+		targetURL = optionalString(strings.TrimSpace(*request.TargetURL))
+	}
+	if targetURL != nil {
+		u, err := url.Parse(*targetURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return httperror.BadRequest("target_url must be an http or https URL")
+		}
+	}
+
+	var description *string
+	if request.Description != nil {
+		description = optionalString(strings.TrimSpace(*request.Description))
+	}
+	if description != nil && utf8.RuneCountInString(*description) > maxStatusDescriptionLength {
+		return httperror.BadRequest("description must be at most 1000 characters")
+	}
+
+	status, err := c.statuses.Upsert(&models.CommitStatus{
+		RepositoryID: repo.ID,
+		SHA:          sha,
+		Context:      statusContext,
+		State:        request.State,
+		TargetURL:    targetURL,
+		Description:  description,
+		CreatorID:    &user.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	body, err := commitStatusJSON(status, newAPIUsernames(c.users))
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusCreated, body)
+}
+
+func (c *apiCommitStatusesController) List(w http.ResponseWriter, r *http.Request) error {
+	repo, sha, err := c.findCommit(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	statuses, err := c.statuses.FindPageByRepositoryAndSHA(repo.ID, sha, page)
+	if err != nil {
+		return err
+	}
+	statuses = paginate(w, r, page, statuses, func(status *models.CommitStatus) int64 { return status.ID })
+
+	body, err := commitStatusesJSON(statuses, newAPIUsernames(c.users))
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiCommitStatusesController) Combined(w http.ResponseWriter, r *http.Request) error {
+	combined, err := c.findCombinedStatus(r)
+	if err != nil {
+		return err
+	}
+
+	statuses, err := commitStatusesJSON(combined.Statuses, newAPIUsernames(c.users))
+	if err != nil {
+		return err
+	}
+
+	state := combined.State
+	if state == "" {
+		state = models.CommitStatePending
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, apiCombinedStatusJSON{
+		SHA:        combined.SHA,
+		State:      state,
+		TotalCount: len(statuses),
+		Statuses:   statuses,
+	})
+}
+
+// findCombinedStatus returns the combined status of the commit the {ref} URL parameter names
+func (c *apiCommitStatusesController) findCombinedStatus(r *http.Request) (*services.CombinedStatus, error) {
+	repo, sha, err := c.findCommit(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.commitStatus.Combined(repo.ID, sha)
+}
+
+// findCommit returns the repository of the URL and the commit its {ref} URL parameter names
+func (c *apiCommitStatusesController) findCommit(r *http.Request) (*models.Repository, string, error) {
+	if err := c.requireScope(r, models.ScopeRepoRead); err != nil {
+		return nil, "", err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionRead)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sha, err := c.git.ResolveCommit(c.git.RepositoryPath(repo), chi.URLParam(r, "ref"))
+	if err != nil {
+		return nil, "", err
+	}
+	if sha == "" {
+		return nil, "", httperror.NotFound("commit not found")
+	}
+
+	return repo, sha, nil
+}
+
+func commitStatusJSON(status *models.CommitStatus, usernames *apiUsernames) (apiCommitStatusJSON, error) {
+	var creator string
+	if status.CreatorID != nil {
+		var err error
+		creator, err = usernames.get(*status.CreatorID)
+		if err != nil {
+			return apiCommitStatusJSON{}, err
+		}
+	}
+
+	return apiCommitStatusJSON{
+		ID:          status.ID,
+		SHA:         status.SHA,
+		Context:     status.Context,
+		State:       status.State,
+		TargetURL:   status.TargetURL,
+		Description: status.Description,
+		Creator:     creator,
+		CreatedAt:   apiTime(status.CreatedAt),
+		UpdatedAt:   apiTime(status.UpdatedAt),
+	}, nil
+}
+
+func commitStatusesJSON(statuses []*models.CommitStatus, usernames *apiUsernames) ([]apiCommitStatusJSON, error) {
+	body := make([]apiCommitStatusJSON, 0, len(statuses))
+	for _, status := range statuses {
+		statusJSON, err := commitStatusJSON(status, usernames)
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, statusJSON)
+	}
+	return body, nil
+}
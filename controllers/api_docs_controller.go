@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// APIDocsController documents the REST API for people and code generators
+type APIDocsController interface {
+	// OpenAPI serves the OpenAPI document of /api/v1
+	OpenAPI(w http.ResponseWriter, r *http.Request) error
+	// Show renders the interactive documentation of the API
+	Show(w http.ResponseWriter, r *http.Request) error
+}
+
+type apiDocsController struct {
+	document map[string]any
+	sections []pages.APIDocsSection
+}
+
+func NewAPIDocsController(publicURL string) APIDocsController {
+	return &apiDocsController{
+		document: openAPIDocument(publicURL),
+		sections: apiDocsSections(),
+	}
+}
+
+func (c *apiDocsController) OpenAPI(w http.ResponseWriter, r *http.Request) error {
+	return writeAPIJSON(w, r, http.StatusOK, c.document)
+}
+
+func (c *apiDocsController) Show(w http.ResponseWriter, r *http.Request) error {
+	return pages.APIDocs(r, &pages.APIDocsData{
+		SpecURL:  apiPrefix + "/openapi.json",
+		Sections: c.sections,
+	}).Render(w, r)
+}
+
+// apiDocsSections groups apiOperations by tag for the docs page
+func apiDocsSections() []pages.APIDocsSection {
+	sections := make([]pages.APIDocsSection, 0, len(apiTags))
+	for _, tag := range apiTags {
+		section := pages.APIDocsSection{Name: tag.Name, Description: tag.Description}
+
+		for _, op := range apiOperations {
+			if op.Tag != tag.Name {
+				continue
+			}
+
+			endpoint := pages.APIDocsEndpoint{
+				ID:             op.ID,
+				Method:         op.Method,
+				Path:           apiPrefix + op.Path,
+				Summary:        op.Summary,
+				Description:    op.description(),
+				PathParameters: op.pathParameters(),
+				Status:         op.Status,
+			}
+			for _, query := range op.Query {
+				endpoint.QueryParameters = append(endpoint.QueryParameters, query.Name)
+			}
+			if op.Paginated {
+				endpoint.QueryParameters = append(endpoint.QueryParameters, "per_page", "cursor")
+			}
+			if op.Request != nil {
+				endpoint.RequestExample = apiDocsExample(op.Request)
+			}
+			if op.Response != nil {
+				endpoint.ResponseExample = apiDocsExample(op.Response)
+			}
+
+			section.Endpoints = append(section.Endpoints, endpoint)
+		}
+
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+func apiDocsExample(v any) string {
+	data, err := json.MarshalIndent(apiExample(reflect.TypeOf(v), ""), "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
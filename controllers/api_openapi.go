@@ -0,0 +1,457 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+// apiPrefix is where the router mounts the API
+const apiPrefix = "/api/v1"
+
+// apiOperation documents an endpoint of the API. The OpenAPI document and the docs page are
+// generated from these and from the request and response types of the handlers.
+type apiOperation struct {
+	ID     string
+	Method string
+	// Path is relative to apiPrefix, with the same {param} placeholders as the routes
+	Path    string
+	Tag     string
+	Summary string
+	// Scope is the scope the access token needs on top of api
+	Scope string
+	// Authenticated endpoints reject anonymous requests, the others show anonymous clients what is public
+	Authenticated bool
+	// Paginated lists take per_page and cursor, and link to the next page
+	Paginated bool
+	Query     []apiQueryParameter
+	// Request is a value of the type of the JSON body, nil if the endpoint takes none
+	Request any
+	// Required lists the fields of Request that must be present
+	Required []string
+	Status   int
+	// OtherStatuses are further successful statuses with the same response
+	OtherStatuses []int
+	// Response is a value of the type of the JSON response, nil if it has no body
+	Response any
+}
+
+type apiQueryParameter struct {
+	Name        string
+	Description string
+	Enum        []string
+}
+
+var apiOperations = []apiOperation{
+	{ID: "getOpenAPIDocument", Method: http.MethodGet, Path: "/openapi.json", Tag: "meta", Summary: "Get this OpenAPI document", Status: http.StatusOK, Response: map[string]any{}},
+
+	{ID: "getCurrentUser", Method: http.MethodGet, Path: "/user", Tag: "users", Summary: "Get the authenticated user", Authenticated: true, Status: http.StatusOK, Response: apiUserJSON{}},
+	{ID: "updateCurrentUser", Method: http.MethodPatch, Path: "/user", Tag: "users", Summary: "Update the authenticated user", Authenticated: true, Request: apiUserRequest{}, Status: http.StatusOK, Response: apiUserJSON{}},
+	{ID: "listUsers", Method: http.MethodGet, Path: "/users", Tag: "users", Summary: "List users", Paginated: true, Status: http.StatusOK, Response: []apiUserJSON{}},
+	{ID: "getUser", Method: http.MethodGet, Path: "/users/{username}", Tag: "users", Summary: "Get a user", Status: http.StatusOK, Response: apiUserJSON{}},
+
+	{ID: "listOrganizations", Method: http.MethodGet, Path: "/orgs", Tag: "organizations", Summary: "List organizations", Paginated: true, Status: http.StatusOK, Response: []apiOrganizationJSON{}},
+	{ID: "createOrganization", Method: http.MethodPost, Path: "/orgs", Tag: "organizations", Summary: "Create an organization owned by the authenticated user", Scope: models.ScopeAdminOrg, Authenticated: true, Request: apiNewOrganizationRequest{}, Required: []string{"username", "display_name"}, Status: http.StatusCreated, Response: apiOrganizationJSON{}},
+	{ID: "getOrganization", Method: http.MethodGet, Path: "/orgs/{org}", Tag: "organizations", Summary: "Get an organization", Status: http.StatusOK, Response: apiOrganizationJSON{}},
+	{ID: "updateOrganization", Method: http.MethodPatch, Path: "/orgs/{org}", Tag: "organizations", Summary: "Update an organization, owners only", Scope: models.ScopeAdminOrg, Authenticated: true, Request: apiOrganizationRequest{}, Status: http.StatusOK, Response: apiOrganizationJSON{}},
+	{ID: "listOrganizationMembers", Method: http.MethodGet, Path: "/orgs/{org}/members", Tag: "organizations", Summary: "List the members of an organization, members only", Authenticated: true, Paginated: true, Status: http.StatusOK, Response: []apiMemberJSON{}},
+	{ID: "addOrganizationMember", Method: http.MethodPost, Path: "/orgs/{org}/members", Tag: "organizations", Summary: "Add a member to an organization, owners only", Scope: models.ScopeAdminOrg, Authenticated: true, Request: apiMemberRequest{}, Required: []string{"username"}, Status: http.StatusCreated, Response: apiMemberJSON{}},
+	{ID: "removeOrganizationMember", Method: http.MethodDelete, Path: "/orgs/{org}/members/{username}", Tag: "organizations", Summary: "Remove a member from an organization, owners only", Scope: models.ScopeAdminOrg, Authenticated: true, Status: http.StatusNoContent},
+
+	{ID: "listCurrentUserRepositories", Method: http.MethodGet, Path: "/user/repos", Tag: "repositories", Summary: "List the repositories of the authenticated user", Scope: models.ScopeRepoRead, Authenticated: true, Paginated: true, Status: http.StatusOK, Response: []apiRepositoryJSON{}},
+	{ID: "createCurrentUserRepository", Method: http.MethodPost, Path: "/user/repos", Tag: "repositories", Summary: "Create a repository owned by the authenticated user", Scope: models.ScopeRepoWrite, Authenticated: true, Request: apiRepositoryRequest{}, Required: []string{"name"}, Status: http.StatusCreated, Response: apiRepositoryJSON{}},
+	{ID: "listUserRepositories", Method: http.MethodGet, Path: "/users/{username}/repos", Tag: "repositories", Summary: "List the repositories of a user", Scope: models.ScopeRepoRead, Paginated: true, Status: http.StatusOK, Response: []apiRepositoryJSON{}},
+	{ID: "listOrganizationRepositories", Method: http.MethodGet, Path: "/orgs/{org}/repos", Tag: "repositories", Summary: "List the repositories of an organization", Scope: models.ScopeRepoRead, Paginated: true, Status: http.StatusOK, Response: []apiRepositoryJSON{}},
+	{ID: "createOrganizationRepository", Method: http.MethodPost, Path: "/orgs/{org}/repos", Tag: "repositories", Summary: "Create a repository owned by an organization, members only", Scope: models.ScopeRepoWrite, Authenticated: true, Request: apiRepositoryRequest{}, Required: []string{"name"}, Status: http.StatusCreated, Response: apiRepositoryJSON{}},
+	{ID: "getRepository", Method: http.MethodGet, Path: "/repos/{owner}/{repo}", Tag: "repositories", Summary: "Get a repository", Scope: models.ScopeRepoRead, Status: http.StatusOK, Response: apiRepositoryJSON{}},
+	{ID: "updateRepository", Method: http.MethodPatch, Path: "/repos/{owner}/{repo}", Tag: "repositories", Summary: "Update a repository, administrators only", Scope: models.ScopeRepoWrite, Authenticated: true, Request: apiRepositoryRequest{}, Status: http.StatusOK, Response: apiRepositoryJSON{}},
+	{ID: "deleteRepository", Method: http.MethodDelete, Path: "/repos/{owner}/{repo}", Tag: "repositories", Summary: "Delete a repository, administrators only", Scope: models.ScopeRepoWrite, Authenticated: true, Status: http.StatusNoContent},
+
+	{ID: "listCollaborators", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/collaborators", Tag: "collaborators", Summary: "List the collaborators of a repository, writers only", Scope: models.ScopeRepoRead, Authenticated: true, Paginated: true, Status: http.StatusOK, Response: []apiCollaboratorJSON{}},
+	{ID: "putCollaborator", Method: http.MethodPut, Path: "/repos/{owner}/{repo}/collaborators/{username}", Tag: "collaborators", Summary: "Add a collaborator or change their role, administrators only", Scope: models.ScopeRepoWrite, Authenticated: true, Request: apiCollaboratorRequest{}, Required: []string{"role"}, Status: http.StatusOK, OtherStatuses: []int{http.StatusCreated}, Response: apiCollaboratorJSON{}},
+	{ID: "deleteCollaborator", Method: http.MethodDelete, Path: "/repos/{owner}/{repo}/collaborators/{username}", Tag: "collaborators", Summary: "Remove a collaborator, administrators only", Scope: models.ScopeRepoWrite, Authenticated: true, Status: http.StatusNoContent},
+
+	{ID: "listStarredRepositories", Method: http.MethodGet, Path: "/user/starred", Tag: "stars", Summary: "List the repositories the authenticated user starred", Scope: models.ScopeRepoRead, Authenticated: true, Paginated: true, Status: http.StatusOK, Response: []apiRepositoryJSON{}},
+	{ID: "starRepository", Method: http.MethodPut, Path: "/user/starred/{owner}/{repo}", Tag: "stars", Summary: "Star a repository", Authenticated: true, Status: http.StatusNoContent},
+	{ID: "unstarRepository", Method: http.MethodDelete, Path: "/user/starred/{owner}/{repo}", Tag: "stars", Summary: "Unstar a repository", Authenticated: true, Status: http.StatusNoContent},
+
+	{ID: "createCommitStatus", Method: http.MethodPost, Path: "/repos/{owner}/{repo}/statuses/{sha}", Tag: "statuses", Summary: "Report the status of a commit for a context, writers only", Scope: models.ScopeRepoWrite, Authenticated: true, Request: apiCommitStatusRequest{}, Required: []string{"state"}, Status: http.StatusCreated, Response: apiCommitStatusJSON{}},
+	{ID: "listCommitStatuses", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/commits/{ref}/statuses", Tag: "statuses", Summary: "List the statuses of a commit", Scope: models.ScopeRepoRead, Paginated: true, Status: http.StatusOK, Response: []apiCommitStatusJSON{}},
+	{ID: "getCombinedCommitStatus", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/commits/{ref}/status", Tag: "statuses", Summary: "Get the combined status of a commit", Scope: models.ScopeRepoRead, Status: http.StatusOK, Response: apiCombinedStatusJSON{}},
+
+	{ID: "listTickets", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/tickets", Tag: "tickets", Summary: "List the tickets of a repository", Scope: models.ScopeTickets, Paginated: true, Query: []apiQueryParameter{{Name: "state", Description: "Only list tickets in this state, open by default", Enum: []string{"open", "closed", "all"}}}, Status: http.StatusOK, Response: []apiTicketJSON{}},
+	{ID: "createTicket", Method: http.MethodPost, Path: "/repos/{owner}/{repo}/tickets", Tag: "tickets", Summary: "Open a ticket", Scope: models.ScopeTickets, Authenticated: true, Request: apiNewTicketRequest{}, Required: []string{"title"}, Status: http.StatusCreated, Response: apiTicketJSON{}},
+	{ID: "getTicket", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/tickets/{number}", Tag: "tickets", Summary: "Get a ticket", Scope: models.ScopeTickets, Status: http.StatusOK, Response: apiTicketJSON{}},
+	{ID: "updateTicket", Method: http.MethodPatch, Path: "/repos/{owner}/{repo}/tickets/{number}", Tag: "tickets", Summary: "Edit, close or reopen a ticket, its author and writers only", Scope: models.ScopeTickets, Authenticated: true, Request: apiTicketRequest{}, Status: http.StatusOK, Response: apiTicketJSON{}},
+
+	{ID: "listComments", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/tickets/{number}/comments", Tag: "comments", Summary: "List the comments of a ticket", Scope: models.ScopeTickets, Paginated: true, Status: http.StatusOK, Response: []apiCommentJSON{}},
+	{ID: "createComment", Method: http.MethodPost, Path: "/repos/{owner}/{repo}/tickets/{number}/comments", Tag: "comments", Summary: "Comment on a ticket", Scope: models.ScopeTickets, Authenticated: true, Request: apiCommentRequest{}, Required: []string{"body"}, Status: http.StatusCreated, Response: apiCommentJSON{}},
+	{ID: "updateComment", Method: http.MethodPatch, Path: "/repos/{owner}/{repo}/tickets/{number}/comments/{id}", Tag: "comments", Summary: "Edit a comment, its author and writers only", Scope: models.ScopeTickets, Authenticated: true, Request: apiCommentRequest{}, Required: []string{"body"}, Status: http.StatusOK, Response: apiCommentJSON{}},
+	{ID: "deleteComment", Method: http.MethodDelete, Path: "/repos/{owner}/{repo}/tickets/{number}/comments/{id}", Tag: "comments", Summary: "Delete a comment, its author and writers only", Scope: models.ScopeTickets, Authenticated: true, Status: http.StatusNoContent},
+
+	{ID: "listLabels", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/labels", Tag: "labels", Summary: "List the labels of a repository", Scope: models.ScopeTickets, Paginated: true, Status: http.StatusOK, Response: []apiLabelJSON{}},
+	{ID: "createLabel", Method: http.MethodPost, Path: "/repos/{owner}/{repo}/labels", Tag: "labels", Summary: "Create a label, writers only", Scope: models.ScopeTickets, Authenticated: true, Request: apiNewLabelRequest{}, Required: []string{"name"}, Status: http.StatusCreated, Response: apiLabelJSON{}},
+	{ID: "getLabel", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/labels/{name}", Tag: "labels", Summary: "Get a label", Scope: models.ScopeTickets, Status: http.StatusOK, Response: apiLabelJSON{}},
+	{ID: "updateLabel", Method: http.MethodPatch, Path: "/repos/{owner}/{repo}/labels/{name}", Tag: "labels", Summary: "Edit a label, writers only", Scope: models.ScopeTickets, Authenticated: true, Request: apiLabelRequest{}, Status: http.StatusOK, Response: apiLabelJSON{}},
+	{ID: "deleteLabel", Method: http.MethodDelete, Path: "/repos/{owner}/{repo}/labels/{name}", Tag: "labels", Summary: "Delete a label and remove it from its tickets, writers only", Scope: models.ScopeTickets, Authenticated: true, Status: http.StatusNoContent},
+	{ID: "listTicketLabels", Method: http.MethodGet, Path: "/repos/{owner}/{repo}/tickets/{number}/labels", Tag: "labels", Summary: "List the labels of a ticket", Scope: models.ScopeTickets, Status: http.StatusOK, Response: []apiLabelJSON{}},
+	{ID: "addTicketLabels", Method: http.MethodPost, Path: "/repos/{owner}/{repo}/tickets/{number}/labels", Tag: "labels", Summary: "Add labels to a ticket, writers only", Scope: models.ScopeTickets, Authenticated: true, Request: apiTicketLabelsRequest{}, Required: []string{"labels"}, Status: http.StatusOK, Response: []apiLabelJSON{}},
+	{ID: "removeTicketLabel", Method: http.MethodDelete, Path: "/repos/{owner}/{repo}/tickets/{number}/labels/{name}", Tag: "labels", Summary: "Remove a label from a ticket, writers only", Scope: models.ScopeTickets, Authenticated: true, Status: http.StatusNoContent},
+}
+
+// apiTags describes the tags of apiOperations, in the order the documentation lists them
+var apiTags = []struct {
+	Name        string
+	Description string
+}{
+	{"users", "Accounts of people using Hypercommit"},
+	{"organizations", "Organizations and their members"},
+	{"repositories", "Repositories of users and organizations"},
+	{"collaborators", "People with access to a repository"},
+	{"stars", "Repositories starred by the authenticated user"},
+	{"statuses", "Results external systems such as CI report for commits"},
+	{"tickets", "Tickets of a repository"},
+	{"comments", "Comments on tickets"},
+	{"labels", "Labels of a repository and of its tickets"},
+	{"meta", "The API itself"},
+}
+
+// apiPathParameters describes the placeholders of the paths of apiOperations
+var apiPathParameters = map[string]string{
+	"username": "Username of a user",
+	"org":      "Username of an organization",
+	"owner":    "Username of the user or organization owning the repository",
+	"repo":     "Name of the repository",
+	"number":   "Number of the ticket in its repository",
+	"id":       "ID of the comment",
+	"name":     "Name of the label",
+	"sha":      "Full ID of the commit",
+	"ref":      "Branch, tag or commit ID",
+}
+
+var apiPathParameterRegex = regexp.MustCompile(`\{(\w+)\}`)
+
+// pathParameters returns the names of the placeholders of the operation's path
+func (o *apiOperation) pathParameters() []string {
+	var names []string
+	for _, match := range apiPathParameterRegex.FindAllStringSubmatch(o.Path, -1) {
+		names = append(names, match[1])
+	}
+	return names
+}
+
+// description explains who may call the operation
+func (o *apiOperation) description() string {
+	var sentences []string
+	if o.Authenticated {
+		sentences = append(sentences, "Requires authentication.")
+	} else {
+		sentences = append(sentences, "Anonymous clients only see what is public.")
+	}
+	if o.Scope != "" {
+		sentences = append(sentences, "Access tokens need the `"+o.Scope+"` scope.")
+	}
+	return strings.Join(sentences, " ")
+}
+
+// openAPIDocument builds the OpenAPI 3.1 document of the API served at publicURL
+func openAPIDocument(publicURL string) map[string]any {
+	schemas := openAPISchemas{}
+	schemas["Error"] = map[string]any{
+		"type":     "object",
+		"required": []string{"error"},
+		"properties": map[string]any{
+			"error": map[string]any{
+				"type":     "object",
+				"required": []string{"status", "message"},
+				"properties": map[string]any{
+					"status":  map[string]any{"type": "integer"},
+					"message": map[string]any{"type": "string"},
+				},
+			},
+		},
+	}
+
+	paths := map[string]map[string]any{}
+	for _, op := range apiOperations {
+		var parameters []any
+		for _, name := range op.pathParameters() {
+			schema := map[string]any{"type": "string"}
+			if name == "number" || name == "id" {
+				schema = map[string]any{"type": "integer", "format": "int64"}
+			}
+			parameters = append(parameters, map[string]any{
+				"name":        name,
+				"in":          "path",
+				"required":    true,
+				"description": apiPathParameters[name],
+				"schema":      schema,
+			})
+		}
+		for _, query := range op.Query {
+			schema := map[string]any{"type": "string"}
+			if len(query.Enum) > 0 {
+				schema["enum"] = query.Enum
+			}
+			parameters = append(parameters, map[string]any{
+				"name":        query.Name,
+				"in":          "query",
+				"description": query.Description,
+				"schema":      schema,
+			})
+		}
+		if op.Paginated {
+			parameters = append(parameters,
+				map[string]any{"$ref": "#/components/parameters/PerPage"},
+				map[string]any{"$ref": "#/components/parameters/Cursor"},
+			)
+		}
+
+		responses := map[string]any{
+			"default": map[string]any{"$ref": "#/components/responses/Error"},
+		}
+		for _, status := range append([]int{op.Status}, op.OtherStatuses...) {
+			responses[fmt.Sprint(status)] = openAPIResponse(&op, status, schemas)
+		}
+
+		operation := map[string]any{
+			"operationId": op.ID,
+			"tags":        []string{op.Tag},
+			"summary":     op.Summary,
+			"description": op.description(),
+			"responses":   responses,
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if op.Authenticated {
+			operation["security"] = []any{map[string]any{"accessToken": []string{}}}
+		} else {
+			operation["security"] = []any{map[string]any{}, map[string]any{"accessToken": []string{}}}
+		}
+		if op.Request != nil {
+			schema := schemas.schema(reflect.TypeOf(op.Request), false)
+			if len(op.Required) > 0 {
+				schema = map[string]any{"allOf": []any{schema}, "required": op.Required}
+			}
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schema}},
+			}
+		}
+
+		path := apiPrefix + op.Path
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(op.Method)] = operation
+	}
+
+	tags := make([]any, 0, len(apiTags))
+	for _, tag := range apiTags {
+		tags = append(tags, map[string]any{"name": tag.Name, "description": tag.Description})
+	}
+
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "Hypercommit API",
+			"version":     "1",
+			"description": "Authenticate with a personal access token or an OAuth access token with the `api` scope in the Authorization header: `Authorization: Bearer <token>`.",
+		},
+		"servers": []any{map[string]any{"url": publicURL}},
+		"tags":    tags,
+		"paths":   paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"accessToken": map[string]any{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "Personal access token or OAuth access token with the api scope",
+				},
+			},
+			"parameters": map[string]any{
+				"PerPage": map[string]any{
+					"name":        "per_page",
+					"in":          "query",
+					"description": fmt.Sprintf("Number of items per page, at most %d", apiMaxPerPage),
+					"schema":      map[string]any{"type": "integer", "minimum": 1, "maximum": apiMaxPerPage, "default": apiPerPage},
+				},
+				"Cursor": map[string]any{
+					"name":        "cursor",
+					"in":          "query",
+					"description": "Opaque cursor of the page, taken from the Link header of the previous one",
+					"schema":      map[string]any{"type": "string"},
+				},
+			},
+			"headers": map[string]any{
+				"Link": map[string]any{
+					"description": `Link to the next page with rel="next", left out on the last page`,
+					"schema":      map[string]any{"type": "string"},
+				},
+				"ETag": map[string]any{
+					"description": "Send it back in If-None-Match to get 304 Not Modified while the response is unchanged",
+					"schema":      map[string]any{"type": "string"},
+				},
+			},
+			"responses": map[string]any{
+				"Error": map[string]any{
+					"description": "The request failed",
+					"content":     map[string]any{"application/json": map[string]any{"schema": map[string]any{"$ref": "#/components/schemas/Error"}}},
+				},
+			},
+		},
+	}
+}
+
+// openAPIResponse describes the successful response of the operation
+func openAPIResponse(op *apiOperation, status int, schemas openAPISchemas) map[string]any {
+	response := map[string]any{"description": http.StatusText(status)}
+	if op.Response != nil {
+		response["content"] = map[string]any{
+			"application/json": map[string]any{"schema": schemas.schema(reflect.TypeOf(op.Response), true)},
+		}
+	}
+
+	headers := map[string]any{}
+	if op.Method == http.MethodGet && status == http.StatusOK {
+		headers["ETag"] = map[string]any{"$ref": "#/components/headers/ETag"}
+	}
+	if op.Paginated {
+		headers["Link"] = map[string]any{"$ref": "#/components/headers/Link"}
+	}
+	if len(headers) > 0 {
+		response["headers"] = headers
+	}
+
+	return response
+}
+
+// openAPISchemas collects the schemas of the named types of the API by their component name
+type openAPISchemas map[string]any
+
+// openAPIName names the component of an API type, apiRepositoryJSON becomes Repository
+func openAPIName(t reflect.Type) string {
+	name := strings.TrimSuffix(t.Name(), "JSON")
+	name = strings.TrimPrefix(name, "api")
+	return name
+}
+
+// schema returns the JSON schema of t, registering the structs it uses as components. All fields of
+// response structs are required unless they are omitempty, requests list theirs per operation.
+// Strings whose field ends in _at hold timestamps, an enum tag lists the values of a field.
+func (s openAPISchemas) schema(t reflect.Type, response bool) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := s.schema(t.Elem(), response)
+		if kind, ok := schema["type"].(string); ok {
+			schema["type"] = []string{kind, "null"}
+			if enum, ok := schema["enum"].([]any); ok {
+				schema["enum"] = append(enum, nil)
+			}
+			return schema
+		}
+		return map[string]any{"oneOf": []any{schema, map[string]any{"type": "null"}}}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": s.schema(t.Elem(), response)}
+	case reflect.Map:
+		return map[string]any{"type": "object"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Struct:
+		name := openAPIName(t)
+		if _, ok := s[name]; !ok {
+			// Registered before the fields so that recursive types terminate
+			s[name] = nil
+			s[name] = s.structSchema(t, response)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]any{}
+}
+
+func (s openAPISchemas) structSchema(t reflect.Type, response bool) map[string]any {
+	properties := map[string]any{}
+	required := []string{}
+	for i := range t.NumField() {
+		field := t.Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+
+		schema := s.schema(field.Type, response)
+		if strings.HasSuffix(name, "_at") {
+			schema["format"] = "date-time"
+		}
+		if enum := field.Tag.Get("enum"); enum != "" {
+			var values []any
+			for value := range strings.SplitSeq(enum, ",") {
+				values = append(values, value)
+			}
+			if field.Type.Kind() == reflect.Pointer {
+				values = append(values, nil)
+			}
+			schema["enum"] = values
+		}
+		properties[name] = schema
+
+		if response && !slices.Contains(strings.Split(options, ","), "omitempty") {
+			required = append(required, name)
+		}
+	}
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// apiExample returns an example value of t for the documentation. field is the JSON name of the
+// struct field holding it, if any.
+func apiExample(t reflect.Type, field string) any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return apiExample(t.Elem(), field)
+	case reflect.Slice:
+		return []any{apiExample(t.Elem(), "")}
+	case reflect.Map:
+		return map[string]any{}
+	case reflect.String:
+		if strings.HasSuffix(field, "_at") {
+			return "2025-01-01T12:00:00Z"
+		}
+		return field
+	case reflect.Bool:
+		return false
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return 1
+	case reflect.Struct:
+		example := map[string]any{}
+		for i := range t.NumField() {
+			structField := t.Field(i)
+			name, _, _ := strings.Cut(structField.Tag.Get("json"), ",")
+			if name == "-" || !structField.IsExported() {
+				continue
+			}
+			if enum, _, _ := strings.Cut(structField.Tag.Get("enum"), ","); enum != "" {
+				example[name] = enum
+				continue
+			}
+			example[name] = apiExample(structField.Type, name)
+		}
+		return example
+	}
+	return nil
+}
@@ -0,0 +1,460 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+const testPublicURL = "http://localhost:8080"
+
+// testAPI is the API wired the way the server wires it, on an in-memory database
+type testAPI struct {
+	router       chi.Router
+	users        repositories.UsersRepository
+	repos        repositories.RepositoriesRepository
+	accessTokens services.AccessTokenService
+	git          services.GitService
+	// route is the pattern of the route that served the last request
+	route string
+}
+
+func newTestAPI(t *testing.T, db *database.DB) *testAPI {
+	t.Helper()
+
+	dir := t.TempDir()
+	repos := repositories.NewRepositoriesRepository(db.DB)
+	users := repositories.NewUsersRepository(db.DB)
+	orgs := repositories.NewOrganizationsRepository(db.DB)
+	orgMembers := repositories.NewOrganizationMembersRepository(db.DB)
+	contributors := repositories.NewContributorsRepository(db.DB)
+	commitStatuses := repositories.NewCommitStatusesRepository(db.DB)
+	webhooks := repositories.NewWebhooksRepository(db.DB)
+	packages := repositories.NewPackagesRepository(db.DB)
+
+	accessTokens := services.NewAccessTokenService(repositories.NewAccessTokensRepository(db.DB))
+	twoFactor := services.NewTwoFactorService(repositories.NewTwoFactorRepository(db.DB))
+	audit := services.NewAuditService(repositories.NewAuditEventsRepository(db.DB))
+	git := services.NewGitService(filepath.Join(dir, "repos"))
+	emails, err := services.NewEmailService(repositories.NewEmailOutboxRepository(db.DB), testPublicURL)
+	if err != nil {
+		t.Fatalf("email service: %v", err)
+	}
+	notifications := services.NewNotificationService(repositories.NewNotificationsRepository(db.DB), users, repositories.NewUserEmailsRepository(db.DB), repos, orgs, orgMembers, contributors, twoFactor, emails, testPublicURL)
+	webhookService := services.NewWebhookService(webhooks, repositories.NewWebhookDeliveriesRepository(db.DB), repos, users, orgs, repositories.NewInstanceSettingsRepository(db.DB), git, testPublicURL)
+	workflows := services.NewWorkflowService(repositories.NewWorkflowRunsRepository(db.DB), commitStatuses, repos, users, orgs, git, testPublicURL)
+	registry, err := services.NewRegistryService(
+		repositories.NewRegistryBlobsRepository(db.DB),
+		repositories.NewRegistryManifestsRepository(db.DB),
+		repositories.NewRegistryTagsRepository(db.DB),
+		"secret",
+		filepath.Join(dir, "registry"),
+		1<<20,
+	)
+	if err != nil {
+		t.Fatalf("registry service: %v", err)
+	}
+	packageService := services.NewPackageService(
+		packages,
+		repositories.NewPackageVersionsRepository(db.DB),
+		repositories.NewPackageFilesRepository(db.DB),
+		repositories.NewPackageDistTagsRepository(db.DB),
+		repos,
+		filepath.Join(dir, "packages"),
+	)
+	deletion := services.NewRepositoryDeletionService(
+		repos,
+		users,
+		webhooks,
+		repositories.NewSecretsRepository(db.DB),
+		packages,
+		git,
+		services.NewGoModuleService(repositories.NewGoModuleVersionsRepository(db.DB), git, testPublicURL, filepath.Join(dir, "gomod")),
+		registry,
+		packageService,
+		webhookService,
+	)
+
+	api := &testAPI{users: users, repos: repos, accessTokens: accessTokens, git: git}
+	wrap := func(fn func(http.ResponseWriter, *http.Request) error) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			api.route = r.Method + " " + strings.TrimSuffix(chi.RouteContext(r.Context()).RoutePattern(), "/")
+			if err := fn(w, r); err != nil {
+				httperror.WriteJSON(w, err)
+			}
+		}
+	}
+
+	api.router = chi.NewRouter()
+	api.router.Route(apiPrefix, func(r chi.Router) {
+		r.Use(middleware.APIAuth(accessTokens, users))
+		RouteAPI(r, APIControllers{
+			Docs:           NewAPIDocsController(testPublicURL),
+			Users:          NewAPIUsersController(users),
+			Organizations:  NewAPIOrganizationsController(orgs, orgMembers, users, accessTokens, twoFactor, audit),
+			Repositories:   NewAPIRepositoriesController(repos, users, orgs, orgMembers, contributors, repositories.NewStarsRepository(db.DB), deletion, accessTokens, twoFactor, git, audit, webhookService, testPublicURL),
+			CommitStatuses: NewAPICommitStatusesController(commitStatuses, repos, users, orgs, orgMembers, contributors, accessTokens, twoFactor, services.NewCommitStatusService(commitStatuses), git),
+			Tickets:        NewAPITicketsController(repositories.NewTicketsRepository(db.DB), repos, users, orgs, orgMembers, contributors, accessTokens, twoFactor, notifications, webhookService, workflows),
+		}, wrap)
+	})
+
+	return api
+}
+
+func TestAPIRoutesMatchTheOpenAPIDocument(t *testing.T) {
+	api := newTestAPI(t, newTestDB(t))
+
+	documented := map[string]bool{}
+	for _, op := range apiOperations {
+		documented[op.Method+" "+apiPrefix+op.Path] = true
+	}
+
+	routed := map[string]bool{}
+	err := chi.Walk(api.router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		key := method + " " + strings.TrimSuffix(route, "/")
+		routed[key] = true
+		if !documented[key] {
+			t.Errorf("%s is routed but not documented", key)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk the API routes: %v", err)
+	}
+
+	for key := range documented {
+		if !routed[key] {
+			t.Errorf("%s is documented but not routed", key)
+		}
+	}
+}
+
+func TestAPIResponsesMatchTheOpenAPIDocument(t *testing.T) {
+	db := newTestDB(t)
+	api := newTestAPI(t, db)
+
+	alice, err := api.users.Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := api.users.Create("bob", "bob@example.com", "Bob", "hash"); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	token, _, err := api.accessTokens.Create(alice.ID, "test", models.AccessTokenScopes, nil, nil)
+	if err != nil {
+		t.Fatalf("create access token: %v", err)
+	}
+
+	var document map[string]any
+	w := httptest.NewRecorder()
+	api.router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, apiPrefix+"/openapi.json", nil))
+	if err := json.NewDecoder(w.Body).Decode(&document); err != nil {
+		t.Fatalf("decode the OpenAPI document: %v", err)
+	}
+
+	operations := map[string]*apiOperation{}
+	for i, op := range apiOperations {
+		operations[op.Method+" "+apiPrefix+op.Path] = &apiOperations[i]
+	}
+	called := map[string]bool{}
+
+	// call sends the request as Alice, or anonymously without a token, and checks that the response is
+	// one the document describes. It returns the decoded response.
+	call := func(token, method, path string, body any) any {
+		t.Helper()
+
+		var reader *bytes.Reader
+		if body != nil {
+			b, err := json.Marshal(body)
+			if err != nil {
+				t.Fatalf("encode %s %s: %v", method, path, err)
+			}
+			reader = bytes.NewReader(b)
+		} else {
+			reader = bytes.NewReader(nil)
+		}
+		r := httptest.NewRequest(method, apiPrefix+path, reader)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		if body != nil {
+			r.Header.Set("Content-Type", "application/json")
+		}
+		w := httptest.NewRecorder()
+		api.router.ServeHTTP(w, r)
+
+		op := operations[api.route]
+		if op == nil {
+			t.Fatalf("%s %s was served by %q, which is not documented", method, path, api.route)
+		}
+		called[api.route] = true
+
+		responses := jsonPath(document, "paths", apiPrefix+op.Path, strings.ToLower(method), "responses").(map[string]any)
+		response, ok := responses[fmt.Sprint(w.Code)].(map[string]any)
+		if !ok {
+			if w.Code < 400 {
+				t.Errorf("%s %s responded %d, which is not documented", method, path, w.Code)
+				return nil
+			}
+			response = resolveRef(document, responses["default"].(map[string]any))
+		}
+
+		schema, ok := jsonPath(response, "content", "application/json", "schema").(map[string]any)
+		if !ok {
+			if w.Body.Len() > 0 {
+				t.Errorf("%s %s responded %d with a body the document leaves out: %s", method, path, w.Code, w.Body)
+			}
+			return nil
+		}
+
+		var value any
+		if err := json.Unmarshal(w.Body.Bytes(), &value); err != nil {
+			t.Fatalf("%s %s responded %d with invalid JSON: %v", method, path, w.Code, err)
+		}
+		for _, problem := range validateSchema(document, schema, value, "$") {
+			t.Errorf("%s %s responded %d: %s", method, path, w.Code, problem)
+		}
+		return value
+	}
+
+	call(token, http.MethodGet, "/openapi.json", nil)
+
+	call(token, http.MethodGet, "/user", nil)
+	call(token, http.MethodPatch, "/user", map[string]any{"display_name": "Alice Liddell"})
+	call(token, http.MethodGet, "/users", nil)
+	call(token, http.MethodGet, "/users/alice", nil)
+
+	call(token, http.MethodPost, "/user/repos", map[string]any{"name": "app", "description": "An app"})
+	repo, err := api.repos.FindByUserAndName(alice.ID, "app")
+	if err != nil || repo == nil {
+		t.Fatalf("find repository: %v", err)
+	}
+	sha := commitFile(t, api.git.RepositoryPath(repo), "README.md", "# App\n")
+
+	call(token, http.MethodGet, "/user/repos", nil)
+	call(token, http.MethodGet, "/users/alice/repos", nil)
+	call(token, http.MethodGet, "/repos/alice/app", nil)
+	call(token, http.MethodPatch, "/repos/alice/app", map[string]any{"visibility": "private"})
+	call(token, http.MethodPut, "/user/starred/alice/app", nil)
+	call(token, http.MethodGet, "/user/starred", nil)
+	call(token, http.MethodDelete, "/user/starred/alice/app", nil)
+
+	call(token, http.MethodPost, "/orgs", map[string]any{"username": "acme", "display_name": "Acme"})
+	call(token, http.MethodGet, "/orgs", nil)
+	call(token, http.MethodGet, "/orgs/acme", nil)
+	call(token, http.MethodPatch, "/orgs/acme", map[string]any{"display_name": "Acme Inc"})
+	call(token, http.MethodPost, "/orgs/acme/members", map[string]any{"username": "bob"})
+	call(token, http.MethodGet, "/orgs/acme/members", nil)
+	call(token, http.MethodDelete, "/orgs/acme/members/bob", nil)
+	call(token, http.MethodPost, "/orgs/acme/repos", map[string]any{"name": "site"})
+	call(token, http.MethodGet, "/orgs/acme/repos", nil)
+	call(token, http.MethodDelete, "/repos/acme/site", nil)
+
+	call(token, http.MethodPut, "/repos/alice/app/collaborators/bob", map[string]any{"role": "write"})
+	call(token, http.MethodPut, "/repos/alice/app/collaborators/bob", map[string]any{"role": "read"})
+	call(token, http.MethodGet, "/repos/alice/app/collaborators", nil)
+	call(token, http.MethodDelete, "/repos/alice/app/collaborators/bob", nil)
+
+	call(token, http.MethodPost, "/repos/alice/app/statuses/"+sha, map[string]any{"state": "success", "context": "ci", "target_url": "https://ci.example.com/1"})
+	call(token, http.MethodGet, "/repos/alice/app/commits/main/statuses", nil)
+	call(token, http.MethodGet, "/repos/alice/app/commits/main/status", nil)
+
+	call(token, http.MethodPost, "/repos/alice/app/tickets", map[string]any{"title": "Crash", "body": "It crashes on start"})
+	call(token, http.MethodGet, "/repos/alice/app/tickets", nil)
+	call(token, http.MethodGet, "/repos/alice/app/tickets/1", nil)
+	call(token, http.MethodPatch, "/repos/alice/app/tickets/1", map[string]any{"state": "closed"})
+	comment, _ := call(token, http.MethodPost, "/repos/alice/app/tickets/1/comments", map[string]any{"body": "Same here"}).(map[string]any)
+	commentPath := fmt.Sprintf("/repos/alice/app/tickets/1/comments/%v", comment["id"])
+	call(token, http.MethodGet, "/repos/alice/app/tickets/1/comments", nil)
+	call(token, http.MethodPatch, commentPath, map[string]any{"body": "Same here, on Linux"})
+	call(token, http.MethodDelete, commentPath, nil)
+
+	call(token, http.MethodPost, "/repos/alice/app/labels", map[string]any{"name": "bug"})
+	call(token, http.MethodGet, "/repos/alice/app/labels", nil)
+	call(token, http.MethodGet, "/repos/alice/app/labels/bug", nil)
+	call(token, http.MethodPatch, "/repos/alice/app/labels/bug", map[string]any{"description": "Something is broken"})
+	call(token, http.MethodPost, "/repos/alice/app/tickets/1/labels", map[string]any{"labels": []string{"bug"}})
+	call(token, http.MethodGet, "/repos/alice/app/tickets/1/labels", nil)
+	call(token, http.MethodDelete, "/repos/alice/app/tickets/1/labels/bug", nil)
+	call(token, http.MethodDelete, "/repos/alice/app/labels/bug", nil)
+
+	call(token, http.MethodDelete, "/repos/alice/app", nil)
+
+	// Errors are described by the default response
+	call("", http.MethodGet, "/user", nil)
+	call(token, http.MethodGet, "/repos/alice/missing", nil)
+	call(token, http.MethodPost, "/user/repos", map[string]any{"name": ""})
+
+	for key := range operations {
+		if !called[key] {
+			t.Errorf("%s was not called", key)
+		}
+	}
+}
+
+// commitFile commits a file to the default branch of the bare repository at repoPath and returns the
+// ID of the commit
+func commitFile(t *testing.T, repoPath, name, content string) string {
+	t.Helper()
+
+	work := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Alice", "-c", "user.email=alice@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = work
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "--quiet", "--initial-branch=main")
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(work, name)), 0o755); err != nil {
+		t.Fatalf("create the directory of %s: %v", name, err)
+	}
+	if err := os.WriteFile(filepath.Join(work, name), []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	git("add", name)
+	git("commit", "--quiet", "-m", "Add "+name)
+	git("push", "--quiet", repoPath, "HEAD:refs/heads/main")
+	return git("rev-parse", "HEAD")
+}
+
+// jsonPath returns the value at the keys of nested JSON objects, nil if one is missing
+func jsonPath(value any, keys ...string) any {
+	for _, key := range keys {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+// resolveRef follows the $ref of an object of the document
+func resolveRef(document, object map[string]any) map[string]any {
+	for {
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return object
+		}
+		object, _ = jsonPath(document, strings.Split(strings.TrimPrefix(ref, "#/"), "/")...).(map[string]any)
+		if object == nil {
+			return map[string]any{}
+		}
+	}
+}
+
+// validateSchema checks value against the subset of JSON schema the document uses and describes
+// where it doesn't match. Objects may not hold properties the schema doesn't list.
+func validateSchema(document, schema map[string]any, value any, at string) []string {
+	schema = resolveRef(document, schema)
+
+	if oneOf, ok := schema["oneOf"].([]any); ok {
+		matches := 0
+		for _, option := range oneOf {
+			if len(validateSchema(document, option.(map[string]any), value, at)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			return []string{fmt.Sprintf("%s matches %d schemas of oneOf, want 1", at, matches)}
+		}
+		return nil
+	}
+
+	var problems []string
+	if enum, ok := schema["enum"].([]any); ok && !slices.ContainsFunc(enum, func(v any) bool { return reflect.DeepEqual(v, value) }) {
+		problems = append(problems, fmt.Sprintf("%s is %v, not one of %v", at, value, enum))
+	}
+
+	var types []string
+	switch kind := schema["type"].(type) {
+	case string:
+		types = []string{kind}
+	case []any:
+		for _, k := range kind {
+			types = append(types, k.(string))
+		}
+	}
+	if len(types) > 0 && !slices.Contains(types, jsonType(value)) && !(jsonType(value) == "integer" && slices.Contains(types, "number")) {
+		return append(problems, fmt.Sprintf("%s is %s, want %s", at, jsonType(value), strings.Join(types, " or ")))
+	}
+
+	switch value := value.(type) {
+	case string:
+		if schema["format"] == "date-time" {
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s is %q, not a date-time", at, value))
+			}
+		}
+	case []any:
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				problems = append(problems, validateSchema(document, items, item, fmt.Sprintf("%s[%d]", at, i))...)
+			}
+		}
+	case map[string]any:
+		if required, ok := schema["required"].([]any); ok {
+			for _, name := range required {
+				if _, ok := value[name.(string)]; !ok {
+					problems = append(problems, fmt.Sprintf("%s.%s is missing", at, name))
+				}
+			}
+		}
+		if properties, ok := schema["properties"].(map[string]any); ok {
+			for name, property := range value {
+				propertySchema, ok := properties[name].(map[string]any)
+				if !ok {
+					problems = append(problems, fmt.Sprintf("%s.%s is not documented", at, name))
+					continue
+				}
+				problems = append(problems, validateSchema(document, propertySchema, property, at+"."+name)...)
+			}
+		}
+	}
+	return problems
+}
+
+// jsonType names the JSON schema type of a decoded JSON value
+func jsonType(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return "unknown"
+}
@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// APIOrganizationsController serves organizations and their members on /api/v1. Changing them
+// needs the admin:org scope, and only owners of the organization can.
+type APIOrganizationsController interface {
+	List(w http.ResponseWriter, r *http.Request) error
+	Show(w http.ResponseWriter, r *http.Request) error
+	Create(w http.ResponseWriter, r *http.Request) error
+	Update(w http.ResponseWriter, r *http.Request) error
+	// ListMembers lists the members of an organization to its members
+	ListMembers(w http.ResponseWriter, r *http.Request) error
+	AddMember(w http.ResponseWriter, r *http.Request) error
+	RemoveMember(w http.ResponseWriter, r *http.Request) error
+}
+
+type apiOrganizationsController struct {
+	*apiAccess
+	audit services.AuditService
+}
+
+func NewAPIOrganizationsController(
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	users repositories.UsersRepository,
+	accessTokens services.AccessTokenService,
+	twoFactor services.TwoFactorService,
+	audit services.AuditService,
+) APIOrganizationsController {
+	return &apiOrganizationsController{
+		apiAccess: &apiAccess{
+			users:        users,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			accessTokens: accessTokens,
+			twoFactor:    twoFactor,
+		},
+		audit: audit,
+	}
+}
+
+type apiMemberJSON struct {
+	Username string `json:"username"`
+	Role     string `json:"role" enum:"owner,member"`
+	JoinedAt string `json:"joined_at"`
+}
+
+type apiNewOrganizationRequest struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+type apiOrganizationRequest struct {
+	DisplayName *string `json:"display_name"`
+}
+
+type apiMemberRequest struct {
+	Username string `json:"username"`
+	// Role defaults to member
+	Role string `json:"role" enum:"owner,member"`
+}
+
+func (c *apiOrganizationsController) List(w http.ResponseWriter, r *http.Request) error {
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	orgs, err := c.orgs.FindPage(page)
+	if err != nil {
+		return err
+	}
+	orgs = paginate(w, r, page, orgs, func(org *models.Organization) int64 { return org.ID })
+
+	body := make([]apiOrganizationJSON, 0, len(orgs))
+	for _, org := range orgs {
+		body = append(body, newAPIOrganizationJSON(org))
+	}
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiOrganizationsController) Show(w http.ResponseWriter, r *http.Request) error {
+	org, err := c.findOrganization(r)
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, newAPIOrganizationJSON(org))
+}
+
+func (c *apiOrganizationsController) Create(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeAdminOrg); err != nil {
+		return err
+	}
+
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	var request apiNewOrganizationRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	request.Username = strings.TrimSpace(request.Username)
+	request.DisplayName = strings.TrimSpace(request.DisplayName)
+	if request.Username == "" {
+		return httperror.BadRequest("username is required")
+	}
+	if request.DisplayName == "" {
+		return httperror.BadRequest("display_name is required")
+	}
+
+	// Users and organizations share the namespace of the URLs
+	existingOrg, err := c.orgs.FindByUsername(request.Username)
+	if err != nil {
+		return err
+	}
+	existingUser, err := c.users.FindByUsername(request.Username)
+	if err != nil {
+		return err
+	}
+	if existingOrg != nil || existingUser != nil {
+		return httperror.New(http.StatusConflict, "username already exists")
+	}
+
+	org, err := c.orgs.Create(request.Username, request.DisplayName)
+	if err != nil {
+		return err
+	}
+	if _, err := c.orgMembers.Create(org.ID, user.ID, models.OrganizationRoleOwner); err != nil {
+		return err
+	}
+
+	slog.Info("organization created", "username", org.Username, "displayName", org.DisplayName, "creator", user.Username)
+	c.audit.Record(r, user, models.AuditOrganizationCreate, services.OrganizationAuditTarget(org), nil)
+
+	w.Header().Set("Location", "/api/v1/orgs/"+org.Username)
+	return writeAPIJSON(w, r, http.StatusCreated, newAPIOrganizationJSON(org))
+}
+
+func (c *apiOrganizationsController) Update(w http.ResponseWriter, r *http.Request) error {
+	user, org, err := c.findOwnedOrganization(r)
+	if err != nil {
+		return err
+	}
+
+	var request apiOrganizationRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	if request.DisplayName != nil && strings.TrimSpace(*request.DisplayName) != org.DisplayName {
+		displayName := strings.TrimSpace(*request.DisplayName)
+		if displayName == "" {
+			return httperror.BadRequest("display_name can't be empty")
+		}
+
+		previous := org.DisplayName
+		org.DisplayName = displayName
+		if err := c.orgs.Update(org); err != nil {
+			return err
+		}
+		c.audit.Record(r, user, models.AuditOrganizationUpdate, services.OrganizationAuditTarget(org), map[string]string{
+			"previous_display_name": previous,
+			"display_name":          displayName,
+		})
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, newAPIOrganizationJSON(org))
+}
+
+func (c *apiOrganizationsController) ListMembers(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	org, err := c.findOrganization(r)
+	if err != nil {
+		return err
+	}
+
+	role, err := c.organizationRole(org, user)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return httperror.Forbidden("only members can see the members of the organization")
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	memberships, err := c.orgMembers.FindPageByOrganization(org.ID, page)
+	if err != nil {
+		return err
+	}
+	memberships = paginate(w, r, page, memberships, func(membership *models.OrganizationMember) int64 { return membership.ID })
+
+	usernames := newAPIUsernames(c.users)
+	body := make([]apiMemberJSON, 0, len(memberships))
+	for _, membership := range memberships {
+		username, err := usernames.get(membership.UserID)
+		if err != nil {
+			return err
+		}
+		body = append(body, apiMemberJSON{
+			Username: username,
+			Role:     membership.Role,
+			JoinedAt: apiTime(membership.CreatedAt),
+		})
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiOrganizationsController) AddMember(w http.ResponseWriter, r *http.Request) error {
+	user, org, err := c.findOwnedOrganization(r)
+	if err != nil {
+		return err
+	}
+
+	var request apiMemberRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	if request.Role == "" {
+		request.Role = models.OrganizationRoleMember
+	}
+	if request.Role != models.OrganizationRoleOwner && request.Role != models.OrganizationRoleMember {
+		return httperror.BadRequest("role must be owner or member")
+	}
+
+	member, err := c.users.FindByUsername(strings.TrimSpace(request.Username))
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return httperror.NotFound("user not found")
+	}
+
+	existing, err := c.orgMembers.FindByOrganizationAndUser(org.ID, member.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return httperror.New(http.StatusConflict, member.Username+" is already a member")
+	}
+
+	missing, err := missingRequiredTwoFactor(org, member.ID, c.twoFactor)
+	if err != nil {
+		return err
+	}
+	if missing {
+		return httperror.New(http.StatusUnprocessableEntity, member.Username+" has to enable two-factor authentication before joining")
+	}
+
+	membership, err := c.orgMembers.Create(org.ID, member.ID, request.Role)
+	if err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditMemberAdd, services.OrganizationAuditTarget(org), map[string]string{
+		"user": member.Username,
+		"role": request.Role,
+	})
+
+	return writeAPIJSON(w, r, http.StatusCreated, apiMemberJSON{
+		Username: member.Username,
+		Role:     membership.Role,
+		JoinedAt: apiTime(membership.CreatedAt),
+	})
+}
+
+func (c *apiOrganizationsController) RemoveMember(w http.ResponseWriter, r *http.Request) error {
+	user, org, err := c.findOwnedOrganization(r)
+	if err != nil {
+		return err
+	}
+
+	member, err := c.users.FindByUsername(chi.URLParam(r, "username"))
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return httperror.NotFound("user not found")
+	}
+
+	membership, err := c.orgMembers.FindByOrganizationAndUser(org.ID, member.ID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return httperror.NotFound("member not found")
+	}
+
+	if membership.Role == models.OrganizationRoleOwner {
+		owners, err := c.orgMembers.CountOwners(org.ID)
+		if err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return httperror.New(http.StatusUnprocessableEntity, "an organization needs at least one owner")
+		}
+	}
+
+	if err := c.orgMembers.Delete(org.ID, member.ID); err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditMemberRemove, services.OrganizationAuditTarget(org), map[string]string{
+		"user_id": strconv.FormatInt(member.ID, 10),
+		"user":    member.Username,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// findOwnedOrganization returns the organization of the URL if the user owns it and the token may
+// administer organizations
+func (c *apiOrganizationsController) findOwnedOrganization(r *http.Request) (*models.User, *models.Organization, error) {
+	if err := c.requireScope(r, models.ScopeAdminOrg); err != nil {
+		return nil, nil, err
+	}
+
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	org, err := c.findOrganization(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role, err := c.organizationRole(org, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if role != models.OrganizationRoleOwner {
+		return nil, nil, httperror.Forbidden("only owners can manage the organization")
+	}
+
+	return user, org, nil
+}
@@ -0,0 +1,725 @@
+package controllers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// APIRepositoriesController serves repositories, their collaborators and stars on /api/v1
+type APIRepositoriesController interface {
+	Show(w http.ResponseWriter, r *http.Request) error
+	Update(w http.ResponseWriter, r *http.Request) error
+	Delete(w http.ResponseWriter, r *http.Request) error
+	// ListForUser lists the repositories of the user in the URL, ListForCurrentUser those of the
+	// authenticated user and ListForOrganization those of an organization
+	ListForUser(w http.ResponseWriter, r *http.Request) error
+	ListForCurrentUser(w http.ResponseWriter, r *http.Request) error
+	ListForOrganization(w http.ResponseWriter, r *http.Request) error
+	CreateForCurrentUser(w http.ResponseWriter, r *http.Request) error
+	CreateForOrganization(w http.ResponseWriter, r *http.Request) error
+
+	ListCollaborators(w http.ResponseWriter, r *http.Request) error
+	// PutCollaborator adds a collaborator, or changes their role
+	PutCollaborator(w http.ResponseWriter, r *http.Request) error
+	DeleteCollaborator(w http.ResponseWriter, r *http.Request) error
+
+	ListStarred(w http.ResponseWriter, r *http.Request) error
+	Star(w http.ResponseWriter, r *http.Request) error
+	Unstar(w http.ResponseWriter, r *http.Request) error
+}
+
+type apiRepositoriesController struct {
+	*apiAccess
+	stars      repositories.StarsRepository
+	deletion   services.RepositoryDeletionService
+	git        services.GitService
+	audit      services.AuditService
+	webhookSvc services.WebhookService
+	publicURL  string
+}
+
+func NewAPIRepositoriesController(
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	stars repositories.StarsRepository,
+	deletion services.RepositoryDeletionService,
+	accessTokens services.AccessTokenService,
+	twoFactor services.TwoFactorService,
+	git services.GitService,
+	audit services.AuditService,
+	webhookSvc services.WebhookService,
+	publicURL string,
+) APIRepositoriesController {
+	return &apiRepositoriesController{
+		apiAccess: &apiAccess{
+			repos:        repos,
+			users:        users,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			contributors: contributors,
+			accessTokens: accessTokens,
+			twoFactor:    twoFactor,
+		},
+		stars:      stars,
+		deletion:   deletion,
+		git:        git,
+		audit:      audit,
+		webhookSvc: webhookSvc,
+		publicURL:  strings.TrimSuffix(publicURL, "/"),
+	}
+}
+
+type apiRepositoryJSON struct {
+	ID            int64   `json:"id"`
+	Owner         string  `json:"owner"`
+	Name          string  `json:"name"`
+	FullName      string  `json:"full_name"`
+	Description   *string `json:"description"`
+	DefaultBranch string  `json:"default_branch"`
+	Visibility    string  `json:"visibility" enum:"public,private"`
+	Stars         int64   `json:"stars"`
+	CloneURL      string  `json:"clone_url"`
+	CreatedAt     string  `json:"created_at"`
+	UpdatedAt     string  `json:"updated_at"`
+}
+
+// apiRepositoryRequest is the body of requests that create or update a repository. Fields left out
+// of an update keep their value.
+type apiRepositoryRequest struct {
+	Name          *string `json:"name"`
+	Description   *string `json:"description"`
+	DefaultBranch *string `json:"default_branch"`
+	Visibility    *string `json:"visibility" enum:"public,private"`
+}
+
+type apiCollaboratorRequest struct {
+	Role string `json:"role" enum:"read,write,admin"`
+}
+
+func (c *apiRepositoriesController) Show(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoRead); err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	body, err := c.repositoryJSON(repo)
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiRepositoriesController) Update(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoWrite); err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	var request apiRepositoryRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	owner := chi.URLParam(r, "owner")
+	previous := *repo
+
+	if request.Name != nil && *request.Name != repo.Name {
+		if err := validateRepositoryName(*request.Name); err != nil {
+			return err
+		}
+		existing, err := c.repos.FindByOwnerAndName(owner, *request.Name)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return httperror.New(http.StatusConflict, "repository name already exists")
+		}
+		repo.Name = *request.Name
+	}
+	if request.Description != nil {
+		repo.Description = optionalString(*request.Description)
+	}
+	if request.DefaultBranch != nil {
+		if *request.DefaultBranch == "" {
+			return httperror.BadRequest("default_branch can't be empty")
+		}
+		repo.DefaultBranch = *request.DefaultBranch
+	}
+	if request.Visibility != nil {
+		if err := validateRepositoryVisibility(*request.Visibility); err != nil {
+			return err
+		}
+		repo.Visibility = *request.Visibility
+	}
+
+	if err := c.repos.Update(repo); err != nil {
+		return err
+	}
+
+	user := middleware.GetUserFromContext(r)
+	auditRepositorySettingsChange(c.audit, r, user, owner, &previous, repo)
+	c.webhookSvc.RepositoryUpdated(&previous, repo, user)
+
+	body, err := c.repositoryJSON(repo)
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiRepositoriesController) Delete(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoWrite); err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	user := middleware.GetUserFromContext(r)
+	if err := c.deletion.Delete(repo, user); err != nil {
+		return err
+	}
+
+	owner := chi.URLParam(r, "owner")
+	slog.Info("repository deleted", "owner", owner, "name", repo.Name)
+	c.audit.Record(r, user, models.AuditRepositoryDelete, services.RepositoryAuditTarget(owner, repo), nil)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (c *apiRepositoriesController) ListForUser(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoRead); err != nil {
+		return err
+	}
+
+	owner, err := c.users.FindByUsername(chi.URLParam(r, "username"))
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return httperror.NotFound("user not found")
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	repos, err := c.repos.FindPageByUser(owner.ID, repositoryReader(r), page)
+	if err != nil {
+		return err
+	}
+	return c.writeRepositories(w, r, page, repos)
+}
+
+func (c *apiRepositoriesController) ListForCurrentUser(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoRead); err != nil {
+		return err
+	}
+
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	repos, err := c.repos.FindPageByUser(user.ID, repositoryReader(r), page)
+	if err != nil {
+		return err
+	}
+	return c.writeRepositories(w, r, page, repos)
+}
+
+func (c *apiRepositoriesController) ListForOrganization(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoRead); err != nil {
+		return err
+	}
+
+	org, err := c.findOrganization(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	repos, err := c.repos.FindPageByOrg(org.ID, repositoryReader(r), page)
+	if err != nil {
+		return err
+	}
+	return c.writeRepositories(w, r, page, repos)
+}
+
+func (c *apiRepositoriesController) CreateForCurrentUser(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoWrite); err != nil {
+		return err
+	}
+
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	request, err := c.decodeNewRepository(w, r)
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.repos.FindByUserAndName(user.ID, request.name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return httperror.New(http.StatusConflict, "repository name already exists")
+	}
+
+	repo, err := c.repos.CreateForUser(user.ID, request.name, request.visibility, request.defaultBranch, request.description)
+	if err != nil {
+		return err
+	}
+	return c.initRepository(w, r, user, user.Username, repo)
+}
+
+func (c *apiRepositoriesController) CreateForOrganization(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoWrite); err != nil {
+		return err
+	}
+
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	org, err := c.findOrganization(r)
+	if err != nil {
+		return err
+	}
+
+	role, err := c.organizationRole(org, user)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		return httperror.Forbidden("only members of the organization can create its repositories")
+	}
+
+	missing, err := missingRequiredTwoFactor(org, user.ID, c.twoFactor)
+	if err != nil {
+		return err
+	}
+	if missing {
+		return httperror.Forbidden(org.DisplayName + " requires two-factor authentication")
+	}
+
+	request, err := c.decodeNewRepository(w, r)
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.repos.FindByOrgAndName(org.ID, request.name)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return httperror.New(http.StatusConflict, "repository name already exists")
+	}
+
+	repo, err := c.repos.CreateForOrg(org.ID, request.name, request.visibility, request.defaultBranch, request.description)
+	if err != nil {
+		return err
+	}
+	return c.initRepository(w, r, user, org.Username, repo)
+}
+
+// apiNewRepository is a validated request to create a repository
+type apiNewRepository struct {
+	name          string
+	description   *string
+	visibility    string
+	defaultBranch string
+}
+
+// decodeNewRepository reads and validates the body of a request creating a repository, filling in
+// the defaults of the fields left out
+func (c *apiRepositoriesController) decodeNewRepository(w http.ResponseWriter, r *http.Request) (*apiNewRepository, error) {
+	var request apiRepositoryRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return nil, err
+	}
+
+	repo := &apiNewRepository{visibility: "public", defaultBranch: "main"}
+
+	if request.Name == nil {
+		return nil, httperror.BadRequest("name is required")
+	}
+	if err := validateRepositoryName(*request.Name); err != nil {
+		return nil, err
+	}
+	repo.name = *request.Name
+
+	if request.Visibility != nil {
+		if err := validateRepositoryVisibility(*request.Visibility); err != nil {
+			return nil, err
+		}
+		repo.visibility = *request.Visibility
+	}
+	if request.DefaultBranch != nil && *request.DefaultBranch != "" {
+		repo.defaultBranch = *request.DefaultBranch
+	}
+	if request.Description != nil {
+		repo.description = optionalString(*request.Description)
+	}
+
+	return repo, nil
+}
+
+// initRepository finishes creating a repository like the web interface does, the creator administers it
+func (c *apiRepositoriesController) initRepository(w http.ResponseWriter, r *http.Request, user *models.User, owner string, repo *models.Repository) error {
+	if _, err := c.contributors.Create(repo.ID, user.ID, "admin"); err != nil {
+		slog.Error("failed to create admin contributor", "error", err)
+	}
+
+	if err := c.git.InitRepository(repo); err != nil {
+		slog.Error("failed to initialize git repository", "error", err)
+		return httperror.New(http.StatusInternalServerError, "failed to initialize repository")
+	}
+
+	slog.Info("repository created", "owner", owner, "name", repo.Name, "visibility", repo.Visibility, "creator", user.Username)
+	c.audit.Record(r, user, models.AuditRepositoryCreate, services.RepositoryAuditTarget(owner, repo), map[string]string{
+		"visibility": repo.Visibility,
+	})
+	c.webhookSvc.Repository(repo, "created", user)
+
+	body, err := c.repositoryJSON(repo)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/repos/%s/%s", owner, repo.Name))
+	return writeAPIJSON(w, r, http.StatusCreated, body)
+}
+
+type apiCollaboratorJSON struct {
+	Username string `json:"username"`
+	Role     string `json:"role" enum:"read,write,admin"`
+	AddedAt  string `json:"added_at"`
+}
+
+func (c *apiRepositoriesController) ListCollaborators(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoRead); err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	contributors, err := c.contributors.FindPageByRepository(repo.ID, page)
+	if err != nil {
+		return err
+	}
+	contributors = paginate(w, r, page, contributors, func(contributor *models.Contributor) int64 { return contributor.ID })
+
+	usernames := newAPIUsernames(c.users)
+	body := make([]apiCollaboratorJSON, 0, len(contributors))
+	for _, contributor := range contributors {
+		username, err := usernames.get(contributor.UserID)
+		if err != nil {
+			return err
+		}
+		body = append(body, apiCollaboratorJSON{
+			Username: username,
+			Role:     contributor.Role,
+			AddedAt:  apiTime(contributor.CreatedAt),
+		})
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiRepositoriesController) PutCollaborator(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoWrite); err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	var request apiCollaboratorRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+	if request.Role != "read" && request.Role != "write" && request.Role != "admin" {
+		return httperror.BadRequest("role must be read, write or admin")
+	}
+
+	collaborator, err := c.users.FindByUsername(chi.URLParam(r, "username"))
+	if err != nil {
+		return err
+	}
+	if collaborator == nil {
+		return httperror.NotFound("user not found")
+	}
+
+	owner := chi.URLParam(r, "owner")
+	user := middleware.GetUserFromContext(r)
+	target := services.RepositoryAuditTarget(owner, repo)
+
+	existing, err := c.contributors.FindByRepositoryAndUser(repo.ID, collaborator.ID)
+	if err != nil {
+		return err
+	}
+
+	status := http.StatusOK
+	if existing != nil {
+		if existing.Role != request.Role {
+			if err := c.contributors.UpdateRole(existing.ID, request.Role); err != nil {
+				return err
+			}
+			c.audit.Record(r, user, models.AuditCollaboratorRoleChange, target, map[string]string{
+				"user":          collaborator.Username,
+				"previous_role": existing.Role,
+				"role":          request.Role,
+			})
+		}
+	} else {
+		// Organizations that require two-factor authentication only accept collaborators who have it
+		if repo.OwnerOrgID != nil {
+			org, err := c.orgs.FindByID(*repo.OwnerOrgID)
+			if err != nil {
+				return err
+			}
+			missing, err := missingRequiredTwoFactor(org, collaborator.ID, c.twoFactor)
+			if err != nil {
+				return err
+			}
+			if missing {
+				return httperror.New(http.StatusUnprocessableEntity, "the organization requires collaborators to use two-factor authentication")
+			}
+		}
+
+		if existing, err = c.contributors.Create(repo.ID, collaborator.ID, request.Role); err != nil {
+			return err
+		}
+		c.audit.Record(r, user, models.AuditCollaboratorAdd, target, map[string]string{
+			"user": collaborator.Username,
+			"role": request.Role,
+		})
+		status = http.StatusCreated
+	}
+
+	return writeAPIJSON(w, r, status, apiCollaboratorJSON{
+		Username: collaborator.Username,
+		Role:     request.Role,
+		AddedAt:  apiTime(existing.CreatedAt),
+	})
+}
+
+func (c *apiRepositoriesController) DeleteCollaborator(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoWrite); err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionAdmin)
+	if err != nil {
+		return err
+	}
+
+	collaborator, err := c.users.FindByUsername(chi.URLParam(r, "username"))
+	if err != nil {
+		return err
+	}
+	if collaborator == nil {
+		return httperror.NotFound("user not found")
+	}
+
+	existing, err := c.contributors.FindByRepositoryAndUser(repo.ID, collaborator.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return httperror.NotFound("collaborator not found")
+	}
+
+	if err := c.contributors.Delete(existing.ID); err != nil {
+		return err
+	}
+	c.audit.Record(r, middleware.GetUserFromContext(r), models.AuditCollaboratorRemove, services.RepositoryAuditTarget(chi.URLParam(r, "owner"), repo), map[string]string{
+		"user": collaborator.Username,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (c *apiRepositoriesController) ListStarred(w http.ResponseWriter, r *http.Request) error {
+	if err := c.requireScope(r, models.ScopeRepoRead); err != nil {
+		return err
+	}
+
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	repos, err := c.repos.FindStarredPageByUser(user.ID, repositoryReader(r), page)
+	if err != nil {
+		return err
+	}
+	return c.writeRepositories(w, r, page, repos)
+}
+
+func (c *apiRepositoriesController) Star(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.stars.FindByUserAndRepository(repo.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		if _, err := c.stars.Create(repo.ID, user.ID); err != nil {
+			return err
+		}
+		c.webhookSvc.Star(repo, "created", user)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (c *apiRepositoriesController) Unstar(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	repo, _, err := c.findRepository(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.stars.FindByUserAndRepository(repo.ID, user.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		if err := c.stars.Delete(repo.ID, user.ID); err != nil {
+			return err
+		}
+		c.webhookSvc.Star(repo, "deleted", user)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// writeRepositories responds with repos, found for the page
+func (c *apiRepositoriesController) writeRepositories(w http.ResponseWriter, r *http.Request, page repositories.Page, repos []*models.Repository) error {
+	repos = paginate(w, r, page, repos, func(repo *models.Repository) int64 { return repo.ID })
+
+	body := make([]apiRepositoryJSON, 0, len(repos))
+	for _, repo := range repos {
+		repoJSON, err := c.repositoryJSON(repo)
+		if err != nil {
+			return err
+		}
+		body = append(body, repoJSON)
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiRepositoriesController) repositoryJSON(repo *models.Repository) (apiRepositoryJSON, error) {
+	owner, err := c.repositoryOwner(repo)
+	if err != nil {
+		return apiRepositoryJSON{}, err
+	}
+
+	stars, err := c.stars.CountByRepository(repo.ID)
+	if err != nil {
+		return apiRepositoryJSON{}, err
+	}
+
+	return apiRepositoryJSON{
+		ID:            repo.ID,
+		Owner:         owner,
+		Name:          repo.Name,
+		FullName:      owner + "/" + repo.Name,
+		Description:   repo.Description,
+		DefaultBranch: repo.DefaultBranch,
+		Visibility:    repo.Visibility,
+		Stars:         stars,
+		CloneURL:      c.publicURL + "/" + owner + "/" + repo.Name,
+		CreatedAt:     apiTime(repo.CreatedAt),
+		UpdatedAt:     apiTime(repo.UpdatedAt),
+	}, nil
+}
+
+func validateRepositoryName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return httperror.BadRequest("name is required")
+	}
+	if strings.ContainsAny(name, "/\\ ") || name == "." || name == ".." {
+		return httperror.BadRequest("name can't contain slashes or spaces")
+	}
+	return nil
+}
+
+func validateRepositoryVisibility(visibility string) error {
+	if visibility != "public" && visibility != "private" {
+		return httperror.BadRequest("visibility must be public or private")
+	}
+	return nil
+}
+
+// optionalString returns nil for an empty string, which clears optional columns
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,77 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// APIControllers serve the endpoints of the API
+type APIControllers struct {
+	Docs           APIDocsController
+	Users          APIUsersController
+	Organizations  APIOrganizationsController
+	Repositories   APIRepositoriesController
+	CommitStatuses APICommitStatusesController
+	Tickets        APITicketsController
+}
+
+// RouteAPI registers the endpoints of apiOperations on r, which the router mounts at /api/v1. wrap
+// turns the handlers into http.HandlerFuncs that respond with their errors.
+func RouteAPI(r chi.Router, c APIControllers, wrap func(func(http.ResponseWriter, *http.Request) error) http.HandlerFunc) {
+	r.Get("/openapi.json", wrap(c.Docs.OpenAPI))
+
+	r.Get("/user", wrap(c.Users.CurrentUser))
+	r.Patch("/user", wrap(c.Users.UpdateCurrentUser))
+	r.Get("/user/repos", wrap(c.Repositories.ListForCurrentUser))
+	r.Post("/user/repos", wrap(c.Repositories.CreateForCurrentUser))
+	r.Get("/user/starred", wrap(c.Repositories.ListStarred))
+	r.Put("/user/starred/{owner}/{repo}", wrap(c.Repositories.Star))
+	r.Delete("/user/starred/{owner}/{repo}", wrap(c.Repositories.Unstar))
+
+	r.Get("/users", wrap(c.Users.List))
+	r.Get("/users/{username}", wrap(c.Users.Show))
+	r.Get("/users/{username}/repos", wrap(c.Repositories.ListForUser))
+
+	r.Get("/orgs", wrap(c.Organizations.List))
+	r.Post("/orgs", wrap(c.Organizations.Create))
+	r.Get("/orgs/{org}", wrap(c.Organizations.Show))
+	r.Patch("/orgs/{org}", wrap(c.Organizations.Update))
+	r.Get("/orgs/{org}/members", wrap(c.Organizations.ListMembers))
+	r.Post("/orgs/{org}/members", wrap(c.Organizations.AddMember))
+	r.Delete("/orgs/{org}/members/{username}", wrap(c.Organizations.RemoveMember))
+	r.Get("/orgs/{org}/repos", wrap(c.Repositories.ListForOrganization))
+	r.Post("/orgs/{org}/repos", wrap(c.Repositories.CreateForOrganization))
+
+	r.Route("/repos/{owner}/{repo}", func(r chi.Router) {
+		r.Get("/", wrap(c.Repositories.Show))
+		r.Patch("/", wrap(c.Repositories.Update))
+		r.Delete("/", wrap(c.Repositories.Delete))
+
+		r.Get("/collaborators", wrap(c.Repositories.ListCollaborators))
+		r.Put("/collaborators/{username}", wrap(c.Repositories.PutCollaborator))
+		r.Delete("/collaborators/{username}", wrap(c.Repositories.DeleteCollaborator))
+
+		r.Post("/statuses/{sha}", wrap(c.CommitStatuses.Create))
+		r.Get("/commits/{ref}/statuses", wrap(c.CommitStatuses.List))
+		r.Get("/commits/{ref}/status", wrap(c.CommitStatuses.Combined))
+
+		r.Get("/tickets", wrap(c.Tickets.List))
+		r.Post("/tickets", wrap(c.Tickets.Create))
+		r.Get("/tickets/{number}", wrap(c.Tickets.Show))
+		r.Patch("/tickets/{number}", wrap(c.Tickets.Update))
+		r.Get("/tickets/{number}/comments", wrap(c.Tickets.ListComments))
+		r.Post("/tickets/{number}/comments", wrap(c.Tickets.CreateComment))
+		r.Patch("/tickets/{number}/comments/{id}", wrap(c.Tickets.UpdateComment))
+		r.Delete("/tickets/{number}/comments/{id}", wrap(c.Tickets.DeleteComment))
+		r.Get("/tickets/{number}/labels", wrap(c.Tickets.ListTicketLabels))
+		r.Post("/tickets/{number}/labels", wrap(c.Tickets.AddTicketLabels))
+		r.Delete("/tickets/{number}/labels/{name}", wrap(c.Tickets.RemoveTicketLabel))
+
+		r.Get("/labels", wrap(c.Tickets.ListLabels))
+		r.Post("/labels", wrap(c.Tickets.CreateLabel))
+		r.Get("/labels/{name}", wrap(c.Tickets.ShowLabel))
+		r.Patch("/labels/{name}", wrap(c.Tickets.UpdateLabel))
+		r.Delete("/labels/{name}", wrap(c.Tickets.DeleteLabel))
+	})
+}
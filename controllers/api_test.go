@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+func mustExec(t *testing.T, db *database.DB, query string, args ...any) int64 {
+	t.Helper()
+
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+	return id
+}
+
+func newTestAPIAccess(db *database.DB) *apiAccess {
+	return &apiAccess{
+		repos:        repositories.NewRepositoriesRepository(db.DB),
+		users:        repositories.NewUsersRepository(db.DB),
+		orgs:         repositories.NewOrganizationsRepository(db.DB),
+		orgMembers:   repositories.NewOrganizationMembersRepository(db.DB),
+		contributors: repositories.NewContributorsRepository(db.DB),
+		accessTokens: services.NewAccessTokenService(repositories.NewAccessTokensRepository(db.DB)),
+		twoFactor:    services.NewTwoFactorService(repositories.NewTwoFactorRepository(db.DB)),
+	}
+}
+
+func TestRepositoryPagesOnlyHoldReadableRepositories(t *testing.T) {
+	db := newTestDB(t)
+	access := newTestAPIAccess(db)
+
+	users := map[string]*models.User{}
+	for _, username := range []string{"alice", "bob", "carol"} {
+		user, err := access.users.Create(username, username+"@example.com", username, "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users[username] = user
+	}
+	alice, bob, carol := users["alice"], users["bob"], users["carol"]
+	mustExec(t, db, `INSERT INTO two_factor_credentials (user_id, secret, confirmed_at) VALUES (?, 'secret', unixepoch())`, carol.ID)
+
+	acme := mustExec(t, db, `INSERT INTO organizations (username, display_name) VALUES ('acme', 'Acme')`)
+	secure := mustExec(t, db, `INSERT INTO organizations (username, display_name, require_two_factor) VALUES ('secure', 'Secure', 1)`)
+	mustExec(t, db, `INSERT INTO organization_members (organization_id, user_id, role) VALUES (?, ?, 'member')`, acme, bob.ID)
+	mustExec(t, db, `INSERT INTO organization_members (organization_id, user_id, role) VALUES (?, ?, 'member')`, secure, bob.ID)
+	mustExec(t, db, `INSERT INTO organization_members (organization_id, user_id, role) VALUES (?, ?, 'owner')`, secure, carol.ID)
+
+	newRepo := func(name, visibility string, ownerUserID, ownerOrgID any) int64 {
+		return mustExec(t, db, `INSERT INTO repositories (name, visibility, owner_user_id, owner_org_id) VALUES (?, ?, ?, ?)`, name, visibility, ownerUserID, ownerOrgID)
+	}
+	newRepo("public", "public", alice.ID, nil)
+	newRepo("private", "private", alice.ID, nil)
+	shared := newRepo("shared", "private", alice.ID, nil)
+	newRepo("internal", "private", nil, acme)
+	newRepo("public", "public", nil, secure)
+	newRepo("private", "private", nil, secure)
+	secureShared := newRepo("shared", "private", nil, secure)
+	mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'read')`, shared, bob.ID)
+	mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'write')`, secureShared, alice.ID)
+
+	all, err := access.repos.FindAll()
+	if err != nil {
+		t.Fatalf("find repositories: %v", err)
+	}
+
+	readers := map[string]*models.User{"anonymous": nil, "alice": alice, "bob": bob, "carol": carol}
+	for name, user := range readers {
+		var reader repositories.RepositoryReader
+		if user != nil {
+			reader.UserID = user.ID
+		}
+
+		var want, got []int64
+		for _, repo := range all {
+			permission, err := access.permission(repo, user)
+			if err != nil {
+				t.Fatalf("permission: %v", err)
+			}
+			if permission >= apiPermissionRead {
+				want = append(want, repo.ID)
+			}
+		}
+
+		page := repositories.Page{Limit: 100}
+		for _, find := range []func() ([]*models.Repository, error){
+			func() ([]*models.Repository, error) { return access.repos.FindPageByUser(alice.ID, reader, page) },
+			func() ([]*models.Repository, error) { return access.repos.FindPageByOrg(acme, reader, page) },
+			func() ([]*models.Repository, error) { return access.repos.FindPageByOrg(secure, reader, page) },
+		} {
+			repos, err := find()
+			if err != nil {
+				t.Fatalf("find page: %v", err)
+			}
+			for _, repo := range repos {
+				got = append(got, repo.ID)
+			}
+		}
+		slices.Sort(got)
+		slices.Sort(want)
+
+		if !slices.Equal(got, want) {
+			t.Errorf("%s: pages hold repositories %v, the API lets them read %v", name, got, want)
+		}
+	}
+
+	// Access tokens limited to some repositories only list those
+	repos, err := access.repos.FindPageByUser(alice.ID, repositories.RepositoryReader{UserID: bob.ID, RepositoryIDs: []int64{shared}}, repositories.Page{Limit: 100})
+	if err != nil {
+		t.Fatalf("find page: %v", err)
+	}
+	if len(repos) != 1 || repos[0].ID != shared {
+		t.Errorf("token limited to %d lists %v", shared, repos)
+	}
+}
+
+var nextLinkRegex = regexp.MustCompile(`^<([^>]+)>; rel="next"$`)
+
+func TestAPIListsArePaginatedByCursor(t *testing.T) {
+	db := newTestDB(t)
+	users := repositories.NewUsersRepository(db.DB)
+
+	var want []string
+	for i := range 7 {
+		username := fmt.Sprintf("user%d", i)
+		if _, err := users.Create(username, username+"@example.com", username, "hash"); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		want = append(want, username)
+	}
+
+	controller := NewAPIUsersController(users)
+	var got []string
+	pages := 0
+	for url := "/api/v1/users?per_page=3"; url != ""; pages++ {
+		if pages > len(want) {
+			t.Fatal("pagination doesn't end")
+		}
+
+		w := httptest.NewRecorder()
+		if err := controller.List(w, httptest.NewRequest(http.MethodGet, url, nil)); err != nil {
+			t.Fatalf("list %s: %v", url, err)
+		}
+
+		var page []apiUserJSON
+		if err := json.NewDecoder(w.Body).Decode(&page); err != nil {
+			t.Fatalf("decode %s: %v", url, err)
+		}
+		if len(page) > 3 {
+			t.Errorf("%s returned %d users", url, len(page))
+		}
+		for _, user := range page {
+			got = append(got, user.Username)
+		}
+
+		url = ""
+		if link := w.Header().Get("Link"); link != "" {
+			match := nextLinkRegex.FindStringSubmatch(link)
+			if match == nil {
+				t.Fatalf("malformed Link header %q", link)
+			}
+			url = match[1]
+		}
+	}
+
+	if pages != 3 {
+		t.Errorf("listed %d pages, want 3", pages)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("listed %v, want %v", got, want)
+	}
+
+	w := httptest.NewRecorder()
+	err := controller.List(w, httptest.NewRequest(http.MethodGet, "/api/v1/users?cursor=bm90IGFuIGlk", nil))
+	if err == nil {
+		t.Error("an invalid cursor was accepted")
+	}
+}
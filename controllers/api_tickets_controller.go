@@ -0,0 +1,791 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// labelColorRegex matches the hex colors labels are shown in
+var labelColorRegex = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// APITicketsController serves the tickets of a repository, their comments and labels on /api/v1.
+// Everyone who can read a repository can open tickets and comment. Authors edit their own tickets
+// and comments, people with write access edit any of them and manage labels.
+type APITicketsController interface {
+	List(w http.ResponseWriter, r *http.Request) error
+	Create(w http.ResponseWriter, r *http.Request) error
+	Show(w http.ResponseWriter, r *http.Request) error
+	// Update edits a ticket, and closes or reopens it with its state
+	Update(w http.ResponseWriter, r *http.Request) error
+
+	ListComments(w http.ResponseWriter, r *http.Request) error
+	CreateComment(w http.ResponseWriter, r *http.Request) error
+	UpdateComment(w http.ResponseWriter, r *http.Request) error
+	DeleteComment(w http.ResponseWriter, r *http.Request) error
+
+	ListLabels(w http.ResponseWriter, r *http.Request) error
+	CreateLabel(w http.ResponseWriter, r *http.Request) error
+	ShowLabel(w http.ResponseWriter, r *http.Request) error
+	UpdateLabel(w http.ResponseWriter, r *http.Request) error
+	DeleteLabel(w http.ResponseWriter, r *http.Request) error
+	ListTicketLabels(w http.ResponseWriter, r *http.Request) error
+	AddTicketLabels(w http.ResponseWriter, r *http.Request) error
+	RemoveTicketLabel(w http.ResponseWriter, r *http.Request) error
+}
+
+type apiTicketsController struct {
+	*apiAccess
+	tickets   repositories.TicketsRepository
+	notifier  services.NotificationService
+	webhooks  services.WebhookService
+	workflows services.WorkflowService
+}
+
+func NewAPITicketsController(
+	tickets repositories.TicketsRepository,
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	accessTokens services.AccessTokenService,
+	twoFactor services.TwoFactorService,
+	notifier services.NotificationService,
+	webhooks services.WebhookService,
+	workflows services.WorkflowService,
+) APITicketsController {
+	return &apiTicketsController{
+		apiAccess: &apiAccess{
+			repos:        repos,
+			users:        users,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			contributors: contributors,
+			accessTokens: accessTokens,
+			twoFactor:    twoFactor,
+		},
+		tickets:   tickets,
+		notifier:  notifier,
+		webhooks:  webhooks,
+		workflows: workflows,
+	}
+}
+
+type apiTicketJSON struct {
+	Number      int64    `json:"number"`
+	Title       string   `json:"title"`
+	Body        *string  `json:"body"`
+	State       string   `json:"state" enum:"open,closed"`
+	Author      string   `json:"author"`
+	Labels      []string `json:"labels"`
+	MilestoneID *int64   `json:"milestone_id"`
+	ClosedBy    *string  `json:"closed_by"`
+	ClosedAt    *string  `json:"closed_at"`
+	CreatedAt   string   `json:"created_at"`
+	UpdatedAt   string   `json:"updated_at"`
+}
+
+type apiCommentJSON struct {
+	ID        int64  `json:"id"`
+	Author    string `json:"author"`
+	Body      string `json:"body"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type apiLabelJSON struct {
+	ID          int64   `json:"id"`
+	Name        string  `json:"name"`
+	Color       string  `json:"color"`
+	Description *string `json:"description"`
+}
+
+type apiNewTicketRequest struct {
+	Title string  `json:"title"`
+	Body  *string `json:"body"`
+}
+
+// apiTicketRequest edits a ticket, fields left out keep their value
+type apiTicketRequest struct {
+	Title *string `json:"title"`
+	Body  *string `json:"body"`
+	State *string `json:"state" enum:"open,closed"`
+}
+
+type apiCommentRequest struct {
+	Body string `json:"body"`
+}
+
+type apiNewLabelRequest struct {
+	Name string `json:"name"`
+	// Color defaults to defaultLabelColor
+	Color       string  `json:"color"`
+	Description *string `json:"description"`
+}
+
+// apiLabelRequest edits a label, fields left out keep their value
+type apiLabelRequest struct {
+	Name        *string `json:"name"`
+	Color       *string `json:"color"`
+	Description *string `json:"description"`
+}
+
+// apiTicketLabelsRequest names the labels to add to a ticket
+type apiTicketLabelsRequest struct {
+	Labels []string `json:"labels"`
+}
+
+func (c *apiTicketsController) List(w http.ResponseWriter, r *http.Request) error {
+	repo, _, err := c.findTicketsRepository(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	state := r.URL.Query().Get("state")
+	switch state {
+	case "":
+		state = "open"
+	case "all":
+		state = ""
+	case "open", "closed":
+	default:
+		return httperror.BadRequest("state must be open, closed or all")
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	tickets, err := c.tickets.FindPageByRepository(repo.ID, state, page)
+	if err != nil {
+		return err
+	}
+	tickets = paginate(w, r, page, tickets, func(ticket *models.Ticket) int64 { return ticket.ID })
+
+	usernames := newAPIUsernames(c.users)
+	body := make([]apiTicketJSON, 0, len(tickets))
+	for _, ticket := range tickets {
+		ticketJSON, err := c.ticketJSON(ticket, usernames)
+		if err != nil {
+			return err
+		}
+		body = append(body, ticketJSON)
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiTicketsController) Create(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	repo, _, err := c.findTicketsRepository(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	var request apiNewTicketRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+	if strings.TrimSpace(request.Title) == "" {
+		return httperror.BadRequest("title is required")
+	}
+
+	var body *string
+	if request.Body != nil {
+		body = optionalString(*request.Body)
+	}
+
+	ticket, err := c.tickets.Create(repo.ID, user.ID, request.Title, body)
+	if err != nil {
+		return err
+	}
+	c.notifier.TicketOpened(ticket, user)
+	c.webhooks.Ticket(repo, ticket, "opened", user)
+	c.workflows.Ticket(repo, ticket, "opened", user)
+
+	ticketJSON, err := c.ticketJSON(ticket, newAPIUsernames(c.users))
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Location", fmt.Sprintf("%s/%d", r.URL.Path, ticket.Number))
+	return writeAPIJSON(w, r, http.StatusCreated, ticketJSON)
+}
+
+func (c *apiTicketsController) Show(w http.ResponseWriter, r *http.Request) error {
+	_, _, ticket, err := c.findTicket(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	ticketJSON, err := c.ticketJSON(ticket, newAPIUsernames(c.users))
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, ticketJSON)
+}
+
+func (c *apiTicketsController) Update(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	repo, permission, ticket, err := c.findTicket(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+	if ticket.AuthorID != user.ID && permission < apiPermissionWrite {
+		return httperror.Forbidden("only the author and people with write access can edit the ticket")
+	}
+
+	// Webhooks hear about the changes once they are all saved
+	var actions []string
+
+	var request apiTicketRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	if request.Title != nil || request.Body != nil {
+		if request.Title != nil {
+			if strings.TrimSpace(*request.Title) == "" {
+				return httperror.BadRequest("title can't be empty")
+			}
+			ticket.Title = *request.Title
+		}
+		if request.Body != nil {
+			ticket.Body = optionalString(*request.Body)
+		}
+		if err := c.tickets.Update(ticket); err != nil {
+			return err
+		}
+		actions = append(actions, "edited")
+	}
+
+	if request.State != nil && *request.State != ticket.Status {
+		switch *request.State {
+		case "closed":
+			err = c.tickets.Close(ticket.ID, user.ID)
+		case "open":
+			err = c.tickets.Reopen(ticket.ID)
+		default:
+			return httperror.BadRequest("state must be open or closed")
+		}
+		if err != nil {
+			return err
+		}
+		ticket.Status = *request.State
+		c.notifier.TicketStatusChanged(ticket, user)
+		if ticket.Status == "closed" {
+			actions = append(actions, "closed")
+		} else {
+			actions = append(actions, "reopened")
+		}
+	}
+
+	// Read it back for the timestamps the database set
+	ticket, err = c.tickets.FindByID(ticket.ID)
+	if err != nil {
+		return err
+	}
+	for _, action := range actions {
+		c.webhooks.Ticket(repo, ticket, action, user)
+		c.workflows.Ticket(repo, ticket, action, user)
+	}
+
+	ticketJSON, err := c.ticketJSON(ticket, newAPIUsernames(c.users))
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, ticketJSON)
+}
+
+func (c *apiTicketsController) ListComments(w http.ResponseWriter, r *http.Request) error {
+	_, _, ticket, err := c.findTicket(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	comments, err := c.tickets.FindCommentPageByTicket(ticket.ID, page)
+	if err != nil {
+		return err
+	}
+	comments = paginate(w, r, page, comments, func(comment *models.TicketComment) int64 { return comment.ID })
+
+	usernames := newAPIUsernames(c.users)
+	body := make([]apiCommentJSON, 0, len(comments))
+	for _, comment := range comments {
+		commentJSON, err := commentJSON(comment, usernames)
+		if err != nil {
+			return err
+		}
+		body = append(body, commentJSON)
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiTicketsController) CreateComment(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	repo, _, ticket, err := c.findTicket(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	var request apiCommentRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+	if strings.TrimSpace(request.Body) == "" {
+		return httperror.BadRequest("body is required")
+	}
+
+	comment, err := c.tickets.CreateComment(ticket.ID, user.ID, request.Body)
+	if err != nil {
+		return err
+	}
+	c.notifier.CommentCreated(ticket, comment, user)
+	c.webhooks.TicketComment(repo, ticket, comment, "created", user)
+
+	body, err := commentJSON(comment, newAPIUsernames(c.users))
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusCreated, body)
+}
+
+func (c *apiTicketsController) UpdateComment(w http.ResponseWriter, r *http.Request) error {
+	user, repo, ticket, comment, err := c.findOwnComment(r)
+	if err != nil {
+		return err
+	}
+
+	var request apiCommentRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+	if strings.TrimSpace(request.Body) == "" {
+		return httperror.BadRequest("body is required")
+	}
+
+	comment.Body = request.Body
+	if err := c.tickets.UpdateComment(comment); err != nil {
+		return err
+	}
+
+	// Read it back for the timestamps the database set
+	comments, err := c.tickets.FindCommentsByTicket(comment.TicketID)
+	if err != nil {
+		return err
+	}
+	for _, updated := range comments {
+		if updated.ID == comment.ID {
+			comment = updated
+		}
+	}
+	c.webhooks.TicketComment(repo, ticket, comment, "edited", user)
+
+	body, err := commentJSON(comment, newAPIUsernames(c.users))
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiTicketsController) DeleteComment(w http.ResponseWriter, r *http.Request) error {
+	user, repo, ticket, comment, err := c.findOwnComment(r)
+	if err != nil {
+		return err
+	}
+
+	if err := c.tickets.DeleteComment(comment.ID); err != nil {
+		return err
+	}
+	c.webhooks.TicketComment(repo, ticket, comment, "deleted", user)
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (c *apiTicketsController) ListLabels(w http.ResponseWriter, r *http.Request) error {
+	repo, _, err := c.findTicketsRepository(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	labels, err := c.tickets.FindLabelPageByRepository(repo.ID, page)
+	if err != nil {
+		return err
+	}
+	labels = paginate(w, r, page, labels, func(label *models.TicketLabel) int64 { return label.ID })
+
+	return writeAPIJSON(w, r, http.StatusOK, labelsJSON(labels))
+}
+
+func (c *apiTicketsController) CreateLabel(w http.ResponseWriter, r *http.Request) error {
+	repo, _, err := c.findTicketsRepository(r, apiPermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	var request apiNewLabelRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	if err := c.validateLabelName(repo, request.Name, 0); err != nil {
+		return err
+	}
+	if request.Color == "" {
+		request.Color = defaultLabelColor
+	}
+	if !labelColorRegex.MatchString(request.Color) {
+		return httperror.BadRequest("color must be a hex color like #6b7280")
+	}
+	var description *string
+	if request.Description != nil {
+		description = optionalString(*request.Description)
+	}
+
+	label, err := c.tickets.CreateLabel(repo.ID, request.Name, request.Color, description)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Location", r.URL.Path+"/"+label.Name)
+	return writeAPIJSON(w, r, http.StatusCreated, labelJSON(label))
+}
+
+func (c *apiTicketsController) ShowLabel(w http.ResponseWriter, r *http.Request) error {
+	_, label, err := c.findLabel(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, labelJSON(label))
+}
+
+func (c *apiTicketsController) UpdateLabel(w http.ResponseWriter, r *http.Request) error {
+	repo, label, err := c.findLabel(r, apiPermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	var request apiLabelRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	if request.Name != nil && *request.Name != label.Name {
+		if err := c.validateLabelName(repo, *request.Name, label.ID); err != nil {
+			return err
+		}
+		label.Name = *request.Name
+	}
+	if request.Color != nil {
+		if !labelColorRegex.MatchString(*request.Color) {
+			return httperror.BadRequest("color must be a hex color like #6b7280")
+		}
+		label.Color = *request.Color
+	}
+	if request.Description != nil {
+		label.Description = optionalString(*request.Description)
+	}
+
+	if err := c.tickets.UpdateLabel(label); err != nil {
+		return err
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, labelJSON(label))
+}
+
+func (c *apiTicketsController) DeleteLabel(w http.ResponseWriter, r *http.Request) error {
+	_, label, err := c.findLabel(r, apiPermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	if err := c.tickets.DeleteLabel(label.ID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (c *apiTicketsController) ListTicketLabels(w http.ResponseWriter, r *http.Request) error {
+	_, _, ticket, err := c.findTicket(r, apiPermissionRead)
+	if err != nil {
+		return err
+	}
+
+	labels, err := c.tickets.FindLabelsByTicket(ticket.ID)
+	if err != nil {
+		return err
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, labelsJSON(labels))
+}
+
+func (c *apiTicketsController) AddTicketLabels(w http.ResponseWriter, r *http.Request) error {
+	repo, _, ticket, err := c.findTicket(r, apiPermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	var request apiTicketLabelsRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+	if len(request.Labels) == 0 {
+		return httperror.BadRequest("labels is required")
+	}
+
+	// Check every label exists before adding any
+	labels := make([]*models.TicketLabel, 0, len(request.Labels))
+	for _, name := range request.Labels {
+		label, err := c.tickets.FindLabelByName(repo.ID, name)
+		if err != nil {
+			return err
+		}
+		if label == nil {
+			return httperror.New(http.StatusUnprocessableEntity, "label "+name+" doesn't exist")
+		}
+		labels = append(labels, label)
+	}
+
+	for _, label := range labels {
+		if err := c.tickets.AddLabel(ticket.ID, label.ID); err != nil {
+			return err
+		}
+	}
+
+	current, err := c.tickets.FindLabelsByTicket(ticket.ID)
+	if err != nil {
+		return err
+	}
+	return writeAPIJSON(w, r, http.StatusOK, labelsJSON(current))
+}
+
+func (c *apiTicketsController) RemoveTicketLabel(w http.ResponseWriter, r *http.Request) error {
+	repo, _, ticket, err := c.findTicket(r, apiPermissionWrite)
+	if err != nil {
+		return err
+	}
+
+	label, err := c.tickets.FindLabelByName(repo.ID, chi.URLParam(r, "name"))
+	if err != nil {
+		return err
+	}
+	if label == nil {
+		return httperror.NotFound("label not found")
+	}
+
+	if err := c.tickets.RemoveLabel(ticket.ID, label.ID); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// findTicketsRepository returns the repository of the URL for requests with the tickets scope
+func (c *apiTicketsController) findTicketsRepository(r *http.Request, need apiPermission) (*models.Repository, apiPermission, error) {
+	if err := c.requireScope(r, models.ScopeTickets); err != nil {
+		return nil, apiPermissionNone, err
+	}
+	return c.findRepository(r, need)
+}
+
+// findTicket returns the ticket of the URL and its repository
+func (c *apiTicketsController) findTicket(r *http.Request, need apiPermission) (*models.Repository, apiPermission, *models.Ticket, error) {
+	repo, permission, err := c.findTicketsRepository(r, need)
+	if err != nil {
+		return nil, apiPermissionNone, nil, err
+	}
+
+	number, err := strconv.ParseInt(chi.URLParam(r, "number"), 10, 64)
+	if err != nil {
+		return nil, apiPermissionNone, nil, httperror.BadRequest("invalid ticket number")
+	}
+
+	ticket, err := c.tickets.FindByRepositoryAndNumber(repo.ID, number)
+	if err != nil {
+		return nil, apiPermissionNone, nil, err
+	}
+	if ticket == nil {
+		return nil, apiPermissionNone, nil, httperror.NotFound("ticket not found")
+	}
+
+	return repo, permission, ticket, nil
+}
+
+// findOwnComment returns the comment of the URL if the user may edit it: they wrote it, or they
+// have write access to the repository
+// findOwnComment returns the comment of the URL if the user may change it, along with the user and
+// the ticket and repository of the comment
+func (c *apiTicketsController) findOwnComment(r *http.Request) (*models.User, *models.Repository, *models.Ticket, *models.TicketComment, error) {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	repo, permission, ticket, err := c.findTicket(r, apiPermissionRead)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, nil, nil, nil, httperror.BadRequest("invalid comment ID")
+	}
+
+	comments, err := c.tickets.FindCommentsByTicket(ticket.ID)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	for _, comment := range comments {
+		if comment.ID != id {
+			continue
+		}
+		if comment.AuthorID != user.ID && permission < apiPermissionWrite {
+			return nil, nil, nil, nil, httperror.Forbidden("only the author and people with write access can change the comment")
+		}
+		return user, repo, ticket, comment, nil
+	}
+
+	return nil, nil, nil, nil, httperror.NotFound("comment not found")
+}
+
+// findLabel returns the label of the URL and its repository
+func (c *apiTicketsController) findLabel(r *http.Request, need apiPermission) (*models.Repository, *models.TicketLabel, error) {
+	repo, _, err := c.findTicketsRepository(r, need)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	label, err := c.tickets.FindLabelByName(repo.ID, chi.URLParam(r, "name"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if label == nil {
+		return nil, nil, httperror.NotFound("label not found")
+	}
+
+	return repo, label, nil
+}
+
+// validateLabelName checks a new name of a label isn't empty or taken by another label of the repository
+func (c *apiTicketsController) validateLabelName(repo *models.Repository, name string, labelID int64) error {
+	if strings.TrimSpace(name) == "" {
+		return httperror.BadRequest("name is required")
+	}
+	if strings.Contains(name, "/") {
+		return httperror.BadRequest("name can't contain slashes")
+	}
+
+	existing, err := c.tickets.FindLabelByName(repo.ID, name)
+	if err != nil {
+		return err
+	}
+	if existing != nil && existing.ID != labelID {
+		return httperror.New(http.StatusConflict, "label "+name+" already exists")
+	}
+	return nil
+}
+
+func (c *apiTicketsController) ticketJSON(ticket *models.Ticket, usernames *apiUsernames) (apiTicketJSON, error) {
+	author, err := usernames.get(ticket.AuthorID)
+	if err != nil {
+		return apiTicketJSON{}, err
+	}
+
+	var closedBy *string
+	if ticket.ClosedByID != nil {
+		username, err := usernames.get(*ticket.ClosedByID)
+		if err != nil {
+			return apiTicketJSON{}, err
+		}
+		closedBy = &username
+	}
+
+	labels, err := c.tickets.FindLabelsByTicket(ticket.ID)
+	if err != nil {
+		return apiTicketJSON{}, err
+	}
+	labelNames := make([]string, 0, len(labels))
+	for _, label := range labels {
+		labelNames = append(labelNames, label.Name)
+	}
+
+	return apiTicketJSON{
+		Number:      ticket.Number,
+		Title:       ticket.Title,
+		Body:        ticket.Body,
+		State:       ticket.Status,
+		Author:      author,
+		Labels:      labelNames,
+		MilestoneID: ticket.MilestoneID,
+		ClosedBy:    closedBy,
+		ClosedAt:    apiTimePtr(ticket.ClosedAt),
+		CreatedAt:   apiTime(ticket.CreatedAt),
+		UpdatedAt:   apiTime(ticket.UpdatedAt),
+	}, nil
+}
+
+func commentJSON(comment *models.TicketComment, usernames *apiUsernames) (apiCommentJSON, error) {
+	author, err := usernames.get(comment.AuthorID)
+	if err != nil {
+		return apiCommentJSON{}, err
+	}
+
+	return apiCommentJSON{
+		ID:        comment.ID,
+		Author:    author,
+		Body:      comment.Body,
+		CreatedAt: apiTime(comment.CreatedAt),
+		UpdatedAt: apiTime(comment.UpdatedAt),
+	}, nil
+}
+
+func labelJSON(label *models.TicketLabel) apiLabelJSON {
+	return apiLabelJSON{
+		ID:          label.ID,
+		Name:        label.Name,
+		Color:       label.Color,
+		Description: label.Description,
+	}
+}
+
+func labelsJSON(labels []*models.TicketLabel) []apiLabelJSON {
+	body := make([]apiLabelJSON, 0, len(labels))
+	for _, label := range labels {
+		body = append(body, labelJSON(label))
+	}
+	return body
+}
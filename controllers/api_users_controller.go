@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+)
+
+// APIUsersController serves users on /api/v1
+type APIUsersController interface {
+	// CurrentUser shows the authenticated user, including their email address
+	CurrentUser(w http.ResponseWriter, r *http.Request) error
+	UpdateCurrentUser(w http.ResponseWriter, r *http.Request) error
+	List(w http.ResponseWriter, r *http.Request) error
+	Show(w http.ResponseWriter, r *http.Request) error
+}
+
+type apiUsersController struct {
+	users repositories.UsersRepository
+}
+
+type apiUserRequest struct {
+	DisplayName *string `json:"display_name"`
+}
+
+func NewAPIUsersController(users repositories.UsersRepository) APIUsersController {
+	return &apiUsersController{users: users}
+}
+
+func (c *apiUsersController) CurrentUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	body := newAPIUserJSON(user)
+	body.Email = user.Email
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiUsersController) UpdateCurrentUser(w http.ResponseWriter, r *http.Request) error {
+	user, err := requireAPIUser(r)
+	if err != nil {
+		return err
+	}
+
+	var request apiUserRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+
+	if request.DisplayName != nil {
+		displayName := strings.TrimSpace(*request.DisplayName)
+		if displayName == "" {
+			return httperror.BadRequest("display_name can't be empty")
+		}
+		user.DisplayName = displayName
+		if err := c.users.Update(user); err != nil {
+			return err
+		}
+	}
+
+	body := newAPIUserJSON(user)
+	body.Email = user.Email
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiUsersController) List(w http.ResponseWriter, r *http.Request) error {
+	page, err := apiPage(r)
+	if err != nil {
+		return err
+	}
+	users, err := c.users.FindPage(page)
+	if err != nil {
+		return err
+	}
+	users = paginate(w, r, page, users, func(user *models.User) int64 { return user.ID })
+
+	body := make([]apiUserJSON, 0, len(users))
+	for _, user := range users {
+		body = append(body, newAPIUserJSON(user))
+	}
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *apiUsersController) Show(w http.ResponseWriter, r *http.Request) error {
+	user, err := c.users.FindByUsername(chi.URLParam(r, "username"))
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return httperror.NotFound("user not found")
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, newAPIUserJSON(user))
+}
@@ -1,191 +1,133 @@
 package controllers
 
 import (
-	"crypto/rand"
-	"crypto/sha256"
-	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"math/big"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/hypercommithq/hypercommit/database/models"
 	"github.com/hypercommithq/hypercommit/database/repositories"
 	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/httputil"
 	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
 	"github.com/hypercommithq/hypercommit/views/pages"
 )
 
 type DeviceAuthController interface {
-	InitiateDeviceAuth(w http.ResponseWriter, r *http.Request) error
-	PollDeviceAuth(w http.ResponseWriter, r *http.Request) error
+	// DeviceAuthorization starts a device authorization grant, RFC 8628 section 3.1
+	DeviceAuthorization(w http.ResponseWriter, r *http.Request) error
 	ShowDeviceAuthPage(w http.ResponseWriter, r *http.Request) error
 	ConfirmDeviceAuth(w http.ResponseWriter, r *http.Request) error
 }
 
 type deviceAuthController struct {
-	sessions     repositories.DeviceAuthSessionsRepository
-	accessTokens repositories.AccessTokensRepository
-	users        repositories.UsersRepository
+	deviceCodes  repositories.OAuthDeviceCodesRepository
+	applications repositories.OAuthApplicationsRepository
+	oauth        services.OAuthService
+	throttle     services.AuthThrottleService
+	audit        services.AuditService
+	publicURL    string
 }
 
 func NewDeviceAuthController(
-	sessions repositories.DeviceAuthSessionsRepository,
-	accessTokens repositories.AccessTokensRepository,
-	users repositories.UsersRepository,
+	deviceCodes repositories.OAuthDeviceCodesRepository,
+	applications repositories.OAuthApplicationsRepository,
+	oauth services.OAuthService,
+	throttle services.AuthThrottleService,
+	audit services.AuditService,
+	publicURL string,
 ) DeviceAuthController {
 	return &deviceAuthController{
-		sessions:     sessions,
-		accessTokens: accessTokens,
-		users:        users,
+		deviceCodes:  deviceCodes,
+		applications: applications,
+		oauth:        oauth,
+		throttle:     throttle,
+		audit:        audit,
+		publicURL:    publicURL,
 	}
 }
 
-// generateDeviceCode generates a user-friendly device code
-func (c *deviceAuthController) generateDeviceCode() (string, error) {
-	const charset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // Exclude ambiguous characters
-	const codeLength = 8
-
-	code := make([]byte, codeLength)
-	for i := range code {
-		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
-		if err != nil {
-			return "", err
-		}
-		code[i] = charset[num.Int64()]
-	}
-
-	// Format as XXXX-XXXX
-	return fmt.Sprintf("%s-%s", string(code[:4]), string(code[4:])), nil
-}
-
-// generateAccessToken generates a cryptographically secure access token
-func (c *deviceAuthController) generateAccessToken() (string, string, error) {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
-		return "", "", err
+// DeviceAuthorization issues a device code and the code the user enters to approve it
+func (c *deviceAuthController) DeviceAuthorization(w http.ResponseWriter, r *http.Request) error {
+	if wait := c.throttle.CheckDeviceCode(r); wait > 0 {
+		httputil.SetRetryAfter(w, wait)
+		return writeOAuthJSON(w, http.StatusTooManyRequests, &services.OAuthError{
+			Code:        services.OAuthErrorSlowDown,
+			Description: "Too many device authorization requests. Try again later.",
+		})
 	}
 
-	rawToken := base64.URLEncoding.EncodeToString(b)
-	hash := sha256.Sum256([]byte(rawToken))
-	tokenHash := fmt.Sprintf("%x", hash)
-
-	return rawToken, tokenHash, nil
-}
-
-// InitiateDeviceAuth creates a new device auth session
-func (c *deviceAuthController) InitiateDeviceAuth(w http.ResponseWriter, r *http.Request) error {
-	sessionID := uuid.New().String()
-	code, err := c.generateDeviceCode()
-	if err != nil {
-		return err
+	if err := r.ParseForm(); err != nil {
+		return writeOAuthError(w, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "invalid form data"})
 	}
 
-	// Session expires in 10 minutes
-	expiresAt := time.Now().Add(10 * time.Minute).Unix()
-
-	session, err := c.sessions.Create(sessionID, code, expiresAt)
+	application, err := c.oauth.AuthenticateClient(r)
 	if err != nil {
-		return err
+		return writeOAuthError(w, err)
 	}
 
-	// Return JSON response with session details
-	verificationURL := fmt.Sprintf("%s://%s/auth/device?code=%s",
-		func() string {
-			if r.TLS != nil {
-				return "https"
-			}
-			return "http"
-		}(),
-		r.Host,
-		code,
-	)
-
-	response := map[string]interface{}{
-		"session_id":       session.ID,
-		"user_code":        session.Code,
-		"verification_url": verificationURL,
-		"expires_at":       session.ExpiresAt,
-		"interval":         1, // Poll every 1 second
+	// The CLI acts on behalf of the user, so it gets every scope unless it asks for fewer
+	scope := r.PostFormValue("scope")
+	if scope == "" && c.oauth.IsCLI(application) {
+		scope = strings.Join(models.AccessTokenScopes, " ")
 	}
-
-	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(response)
-}
-
-// PollDeviceAuth checks the status of a device auth session
-func (c *deviceAuthController) PollDeviceAuth(w http.ResponseWriter, r *http.Request) error {
-	sessionID := r.URL.Query().Get("session_id")
-	if sessionID == "" {
-		return httperror.New(http.StatusBadRequest, "session_id is required")
+	scopes, err := c.oauth.ParseScopes(scope)
+	if err != nil {
+		return writeOAuthError(w, err)
 	}
 
-	session, err := c.sessions.FindByID(sessionID)
+	deviceCode, grant, err := c.oauth.CreateDeviceCode(application, scopes)
 	if err != nil {
 		return err
 	}
 
-	if session == nil {
-		return httperror.New(http.StatusNotFound, "Session not found")
-	}
-
-	// Check if expired
-	if time.Now().Unix() > session.ExpiresAt {
-		_ = c.sessions.UpdateStatus(sessionID, "expired")
-		response := map[string]interface{}{
-			"status": "expired",
-			"error":  "The device code has expired. Please start a new authentication flow.",
-		}
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusGone)
-		return json.NewEncoder(w).Encode(response)
-	}
-
-	// Return current status
-	response := map[string]interface{}{
-		"status": session.Status,
-	}
-
-	if session.Status == "confirmed" && session.AccessToken != nil && session.UserID != nil {
-		// Fetch username
-		user, err := c.users.FindByID(*session.UserID)
-		if err != nil {
-			return err
-		}
-
-		response["access_token"] = *session.AccessToken
-		response["username"] = user.Username
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	return json.NewEncoder(w).Encode(response)
+	verificationURI := c.publicURL + "/auth/device"
+	return writeOAuthJSON(w, http.StatusOK, map[string]any{
+		"device_code":               deviceCode,
+		"user_code":                 grant.UserCode,
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?code=" + url.QueryEscape(grant.UserCode),
+		"expires_in":                int64(services.OAuthDeviceCodeTTL.Seconds()),
+		"interval":                  services.OAuthDeviceCodeInterval,
+	})
 }
 
-// ShowDeviceAuthPage shows the device confirmation page
+// ShowDeviceAuthPage asks for the code shown on the device, and once entered which access to grant
 func (c *deviceAuthController) ShowDeviceAuthPage(w http.ResponseWriter, r *http.Request) error {
-	code := r.URL.Query().Get("code")
-	if code != "" {
-		// Normalize code (remove hyphens and convert to uppercase)
-		code = strings.ToUpper(strings.ReplaceAll(code, "-", ""))
-		// Add hyphen back in the middle for display
-		if len(code) == 8 {
-			code = fmt.Sprintf("%s-%s", code[:4], code[4:])
-		}
-	}
-
 	user := middleware.GetUserFromContext(r)
+	code := normalizeUserCode(r.URL.Query().Get("code"))
 
 	data := &pages.DeviceAuthData{
 		User: user,
 		Code: code,
 	}
+	if user == nil || code == "" {
+		return pages.DeviceAuth(r, data).Render(w, r)
+	}
+
+	grant, err := c.pendingDeviceCode(code)
+	if err != nil {
+		return err
+	}
+	if grant == nil {
+		data.Error = "Invalid or expired code. Please try again."
+		return pages.DeviceAuth(r, data).Render(w, r)
+	}
+
+	application, err := c.deviceApplication(grant)
+	if err != nil {
+		return err
+	}
+	data.Application = application
+	data.Scopes = grant.Scopes
 
 	return pages.DeviceAuth(r, data).Render(w, r)
 }
 
-// ConfirmDeviceAuth confirms a device auth session
+// ConfirmDeviceAuth approves or denies the device
 func (c *deviceAuthController) ConfirmDeviceAuth(w http.ResponseWriter, r *http.Request) error {
 	user := middleware.GetUserFromContext(r)
 	if user == nil {
@@ -197,24 +139,16 @@ func (c *deviceAuthController) ConfirmDeviceAuth(w http.ResponseWriter, r *http.
 		return httperror.New(http.StatusBadRequest, "Invalid form data")
 	}
 
-	code := r.FormValue("code")
+	code := normalizeUserCode(r.FormValue("code"))
 	if code == "" {
 		return httperror.New(http.StatusBadRequest, "Code is required")
 	}
 
-	// Normalize code
-	code = strings.ToUpper(strings.ReplaceAll(code, "-", ""))
-	if len(code) == 8 {
-		code = fmt.Sprintf("%s-%s", code[:4], code[4:])
-	}
-
-	// Find session by code
-	session, err := c.sessions.FindByCode(code)
+	grant, err := c.pendingDeviceCode(code)
 	if err != nil {
 		return err
 	}
-
-	if session == nil {
+	if grant == nil {
 		data := &pages.DeviceAuthData{
 			User:  user,
 			Code:  code,
@@ -223,51 +157,67 @@ func (c *deviceAuthController) ConfirmDeviceAuth(w http.ResponseWriter, r *http.
 		return pages.DeviceAuth(r, data).Render(w, r)
 	}
 
-	// Check if expired
-	if time.Now().Unix() > session.ExpiresAt {
-		_ = c.sessions.UpdateStatus(session.ID, "expired")
-		data := &pages.DeviceAuthData{
-			User:  user,
-			Code:  code,
-			Error: "This code has expired. Please start a new authentication flow.",
-		}
-		return pages.DeviceAuth(r, data).Render(w, r)
+	application, err := c.deviceApplication(grant)
+	if err != nil {
+		return err
 	}
 
-	// Check if already confirmed
-	if session.Status == "confirmed" {
-		data := &pages.DeviceAuthData{
-			User:    user,
-			Code:    code,
-			Success: true,
-		}
-		return pages.DeviceAuth(r, data).Render(w, r)
+	status := models.OAuthDeviceCodeDenied
+	if r.FormValue("decision") == "approve" {
+		status = models.OAuthDeviceCodeApproved
 	}
 
-	// Generate access token
-	rawToken, tokenHash, err := c.generateAccessToken()
-	if err != nil {
+	// The device collects its token when it polls next
+	if err := c.deviceCodes.Decide(grant.ID, user.ID, status); err != nil {
 		return err
 	}
+	if status == models.OAuthDeviceCodeApproved && !c.oauth.IsCLI(application) {
+		c.audit.Record(r, user, models.AuditOAuthAuthorize, services.OAuthApplicationAuditTarget(application), map[string]string{"scopes": strings.Join(grant.Scopes, " ")})
+	}
+
+	data := &pages.DeviceAuthData{
+		User:        user,
+		Code:        code,
+		Application: application,
+		Success:     status == models.OAuthDeviceCodeApproved,
+		Denied:      status == models.OAuthDeviceCodeDenied,
+	}
+	return pages.DeviceAuth(r, data).Render(w, r)
+}
 
-	// Create access token in database
-	tokenName := fmt.Sprintf("CLI Device Auth - %s", time.Now().Format("2006-01-02 15:04:05"))
-	_, err = c.accessTokens.Create(user.ID, tokenName, tokenHash)
+// pendingDeviceCode returns the device code the user code belongs to, or nil if it's unknown, decided or expired
+func (c *deviceAuthController) pendingDeviceCode(userCode string) (*models.OAuthDeviceCode, error) {
+	grant, err := c.deviceCodes.FindByUserCode(userCode)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if grant == nil || grant.Status != models.OAuthDeviceCodePending || time.Now().Unix() >= grant.ExpiresAt {
+		return nil, nil
+	}
+	return grant, nil
+}
 
-	// Confirm the session
-	err = c.sessions.Confirm(session.ID, user.ID, rawToken)
+// deviceApplication returns the application a device code was issued to
+func (c *deviceAuthController) deviceApplication(grant *models.OAuthDeviceCode) (*models.OAuthApplication, error) {
+	if grant.ApplicationID == nil {
+		return c.oauth.FindClient(services.OAuthCLIClientID)
+	}
+
+	application, err := c.applications.FindByID(*grant.ApplicationID)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	if application == nil {
+		return nil, httperror.NotFound("The application doesn't exist, it may have been deleted.")
+	}
+	return application, nil
+}
 
-	// Show success page
-	data := &pages.DeviceAuthData{
-		User:    user,
-		Code:    code,
-		Success: true,
+// normalizeUserCode turns user input like "abcd1234" into the "ABCD-1234" form of user codes
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+	if len(code) == 8 {
+		code = fmt.Sprintf("%s-%s", code[:4], code[4:])
 	}
-	return pages.DeviceAuth(r, data).Render(w, r)
+	return code
 }
@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/httputil"
+	"github.com/hypercommithq/hypercommit/services"
+	"golang.org/x/oauth2"
+)
+
+// externalAuthCookie holds the provider, state, PKCE verifier and nonce of a sign-in in progress
+const externalAuthCookie = "external_auth"
+
+var usernameDisallowedChars = regexp.MustCompile(`[^A-Za-z0-9_.-]+`)
+
+type ExternalAuthController interface {
+	Login(w http.ResponseWriter, r *http.Request) error
+	Callback(w http.ResponseWriter, r *http.Request) error
+}
+
+type externalAuthController struct {
+	users       repositories.UsersRepository
+	userEmails  repositories.UserEmailsRepository
+	identities  repositories.UserIdentitiesRepository
+	authService services.AuthService
+	twoFactor   services.TwoFactorService
+	providers   []services.IdentityProvider
+	audit       services.AuditService
+	settings    repositories.InstanceSettingsRepository
+}
+
+func NewExternalAuthController(
+	users repositories.UsersRepository,
+	userEmails repositories.UserEmailsRepository,
+	identities repositories.UserIdentitiesRepository,
+	authService services.AuthService,
+	twoFactor services.TwoFactorService,
+	providers []services.IdentityProvider,
+	audit services.AuditService,
+	settings repositories.InstanceSettingsRepository,
+) ExternalAuthController {
+	return &externalAuthController{
+		users:       users,
+		userEmails:  userEmails,
+		identities:  identities,
+		authService: authService,
+		twoFactor:   twoFactor,
+		providers:   providers,
+		audit:       audit,
+		settings:    settings,
+	}
+}
+
+func (c *externalAuthController) provider(r *http.Request) (services.IdentityProvider, error) {
+	id := chi.URLParam(r, "provider")
+	for _, provider := range c.providers {
+		if provider.ID() == id {
+			return provider, nil
+		}
+	}
+	return nil, httperror.NotFound("Identity provider not found")
+}
+
+func (c *externalAuthController) Login(w http.ResponseWriter, r *http.Request) error {
+	provider, err := c.provider(r)
+	if err != nil {
+		return err
+	}
+
+	// The state protects against CSRF, the verifier (PKCE) and nonce against stolen codes and tokens
+	state := generateRandomState()
+	verifier := oauth2.GenerateVerifier()
+	nonce := generateRandomState()
+
+	authURL, err := provider.AuthURL(r.Context(), state, verifier, nonce)
+	if err != nil {
+		return fmt.Errorf("failed to start sign-in with %s: %w", provider.ID(), err)
+	}
+
+	// Store them in a cookie for verification in callback
+	http.SetCookie(w, &http.Cookie{
+		Name:     externalAuthCookie,
+		Value:    strings.Join([]string{provider.ID(), state, verifier, nonce}, "."),
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   httputil.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   600, // 10 minutes
+	})
+
+	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+	return nil
+}
+
+func (c *externalAuthController) Callback(w http.ResponseWriter, r *http.Request) error {
+	provider, err := c.provider(r)
+	if err != nil {
+		return err
+	}
+
+	// Verify state parameter
+	cookie, err := r.Cookie(externalAuthCookie)
+	if err != nil {
+		return httperror.New(http.StatusBadRequest, "Missing state cookie")
+	}
+
+	parts := strings.Split(cookie.Value, ".")
+	if len(parts) != 4 || parts[0] != provider.ID() {
+		return httperror.New(http.StatusBadRequest, "Invalid state cookie")
+	}
+	state, verifier, nonce := parts[1], parts[2], parts[3]
+
+	if r.URL.Query().Get("state") != state {
+		return httperror.New(http.StatusBadRequest, "Invalid state parameter")
+	}
+
+	// Clear state cookie
+	http.SetCookie(w, &http.Cookie{
+		Name:   externalAuthCookie,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	// The provider redirects back with an error if the user declined or isn't allowed
+	if errorCode := r.URL.Query().Get("error"); errorCode != "" {
+		slog.Info("identity provider returned an error", "provider", provider.ID(), "error", errorCode, "description", r.URL.Query().Get("error_description"))
+		return httperror.Forbidden(fmt.Sprintf("Signing in with %s failed.", provider.Name()))
+	}
+
+	// Get code from query parameter
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		return httperror.New(http.StatusBadRequest, "Missing authorization code")
+	}
+
+	identity, err := provider.Identify(r.Context(), code, verifier, nonce)
+	if errors.Is(err, services.ErrIdentityNotAllowed) {
+		return httperror.Forbidden(fmt.Sprintf("Your %s account isn't allowed to sign in to this instance.", provider.Name()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to identify %s user: %w", provider.ID(), err)
+	}
+
+	user, err := c.findOrCreateUser(provider, identity)
+	if err != nil {
+		return err
+	}
+
+	if user.SuspendedAt != nil {
+		return httperror.Forbidden(accountSuspendedMessage)
+	}
+
+	// Providers that manage administrators grant and revoke the role on every sign-in
+	if identity.IsAdmin != nil && *identity.IsAdmin != user.IsAdmin {
+		if err := c.users.SetAdmin(user.ID, *identity.IsAdmin); err != nil {
+			return fmt.Errorf("failed to update administrator role: %w", err)
+		}
+		action := models.AuditAdminUserDemote
+		if *identity.IsAdmin {
+			action = models.AuditAdminUserPromote
+		}
+		c.audit.Record(r, nil, action, services.UserAuditTarget(user), map[string]string{"provider": provider.ID()})
+		user.IsAdmin = *identity.IsAdmin
+	}
+
+	// Signing in with an identity provider doesn't skip the second factor
+	enabled, err := c.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return fmt.Errorf("failed to check two-factor authentication: %w", err)
+	}
+	if enabled {
+		if err := c.twoFactor.BeginChallenge(w, r, user.ID); err != nil {
+			return fmt.Errorf("failed to start two-factor challenge: %w", err)
+		}
+		http.Redirect(w, r, "/auth/sign-in/two-factor", http.StatusSeeOther)
+		return nil
+	}
+
+	// Start a session
+	if err := c.authService.StartSession(w, r, user.ID); err != nil {
+		return fmt.Errorf("failed to start session: %w", err)
+	}
+	c.audit.Record(r, user, models.AuditSignIn, services.UserAuditTarget(user), map[string]string{"method": provider.ID()})
+
+	// Redirect to home page
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+// findOrCreateUser returns the user linked to identity. An identity seen for the first time is linked
+// to the account with its verified email, or to a new account if there is none.
+func (c *externalAuthController) findOrCreateUser(provider services.IdentityProvider, identity *services.ExternalIdentity) (*models.User, error) {
+	linked, err := c.identities.FindByProviderAndSubject(provider.ID(), identity.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != nil {
+		user, err := c.users.FindByID(linked.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user %d of %s identity not found", linked.UserID, provider.ID())
+		}
+		return user, nil
+	}
+
+	// Accounts are only linked by an email they verified, and only if the provider verified it too,
+	// otherwise anyone could claim an account by adding its address there or here.
+	var user *models.User
+	if identity.Email != "" {
+		userEmail, err := c.userEmails.FindVerifiedByEmail(identity.Email)
+		if err != nil {
+			return nil, err
+		}
+
+		if userEmail != nil {
+			if !identity.EmailVerified {
+				return nil, httperror.New(http.StatusConflict, fmt.Sprintf("An account with this email already exists. Verify the email on %s or sign in with your password.", provider.Name()))
+			}
+
+			user, err = c.users.FindByID(userEmail.UserID)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			// The address can still be the unverified primary one of an account, which users.email
+			// keeps unique
+			owner, err := c.users.FindByEmail(identity.Email)
+			if err != nil {
+				return nil, err
+			}
+			if owner != nil {
+				return nil, httperror.New(http.StatusConflict, "An account with this email already exists. Sign in with your password and verify the email to use it here.")
+			}
+		}
+	}
+
+	// Create new user if doesn't exist
+	if user == nil {
+		settings, err := c.settings.Find()
+		if err != nil {
+			return nil, err
+		}
+		if settings.SignUpDisabled {
+			return nil, httperror.Forbidden("Sign-up is disabled on this instance. Ask an administrator to create an account for you.")
+		}
+
+		username, err := c.availableUsername(identity.Username)
+		if err != nil {
+			return nil, err
+		}
+
+		displayName := identity.Name
+		if displayName == "" {
+			displayName = username
+		}
+
+		user, err = c.users.CreateExternal(username, identity.Email, displayName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create user: %w", err)
+		}
+
+		if identity.Email != "" {
+			userEmail, err := c.userEmails.Create(user.ID, identity.Email, true, false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create user email: %w", err)
+			}
+
+			// The provider has proven ownership of the address, which replaces unverified claims of it
+			if identity.EmailVerified {
+				if err := c.userEmails.MarkVerified(userEmail.ID); err != nil {
+					return nil, fmt.Errorf("failed to verify user email: %w", err)
+				}
+			}
+		}
+	}
+
+	if _, err := c.identities.Create(user.ID, provider.ID(), identity.Subject); err != nil {
+		return nil, fmt.Errorf("failed to link %s identity: %w", provider.ID(), err)
+	}
+
+	return user, nil
+}
+
+// availableUsername turns the username at the provider into one that is valid and not taken here,
+// by dropping characters that can't be in URLs and numbering it if needed
+func (c *externalAuthController) availableUsername(preferred string) (string, error) {
+	base := strings.Trim(usernameDisallowedChars.ReplaceAllString(preferred, "-"), "-.")
+	if base == "" {
+		base = "user"
+	}
+
+	username := base
+	for n := 2; ; n++ {
+		existing, err := c.users.FindByUsername(username)
+		if err != nil {
+			return "", err
+		}
+		if existing == nil {
+			return username, nil
+		}
+		username = base + "-" + strconv.Itoa(n)
+	}
+}
+
+func generateRandomState() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// stubIdentityProvider is an identity provider findOrCreateUser can be called with
+type stubIdentityProvider struct{}
+
+func (stubIdentityProvider) ID() string   { return "stub" }
+func (stubIdentityProvider) Name() string { return "Stub" }
+
+func (stubIdentityProvider) AuthURL(ctx context.Context, state, verifier, nonce string) (string, error) {
+	return "", nil
+}
+
+func (stubIdentityProvider) Identify(ctx context.Context, code, verifier, nonce string) (*services.ExternalIdentity, error) {
+	return nil, nil
+}
+
+func TestExternalSignInIgnoresUnverifiedClaimsOfTheEmail(t *testing.T) {
+	db := newTestDB(t)
+	users := repositories.NewUsersRepository(db.DB)
+	userEmails := repositories.NewUserEmailsRepository(db.DB)
+	c := &externalAuthController{
+		users:      users,
+		userEmails: userEmails,
+		identities: repositories.NewUserIdentitiesRepository(db.DB),
+		settings:   repositories.NewInstanceSettingsRepository(db.DB),
+	}
+
+	// Mallory adds Victor's address without being able to verify it
+	mallory, err := users.Create("mallory", "mallory@example.com", "Mallory", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	squatted, err := userEmails.Create(mallory.ID, "victor@example.com", false, false)
+	if err != nil {
+		t.Fatalf("create user email: %v", err)
+	}
+
+	user, err := c.findOrCreateUser(stubIdentityProvider{}, &services.ExternalIdentity{
+		Subject:       "victor",
+		Username:      "victor",
+		Email:         "victor@example.com",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if user.ID == mallory.ID {
+		t.Fatal("the identity was linked to the account with the unverified address")
+	}
+
+	// The verified claim replaces the unverified one
+	if userEmail, err := userEmails.FindByID(squatted.ID); err != nil || userEmail != nil {
+		t.Errorf("unverified claim = %+v, %v, want it removed", userEmail, err)
+	}
+	verified, err := userEmails.FindVerifiedByEmail("victor@example.com")
+	if err != nil {
+		t.Fatalf("find verified email: %v", err)
+	}
+	if verified == nil || verified.UserID != user.ID {
+		t.Errorf("verified address = %+v, want it verified for the new account", verified)
+	}
+
+	// Signing in again finds the same account
+	again, err := c.findOrCreateUser(stubIdentityProvider{}, &services.ExternalIdentity{
+		Subject:       "victor",
+		Email:         "victor@example.com",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("sign in again: %v", err)
+	}
+	if again.ID != user.ID {
+		t.Errorf("second sign-in got user %d, want %d", again.ID, user.ID)
+	}
+}
+
+func TestExternalSignInLinksAccountsByVerifiedEmail(t *testing.T) {
+	db := newTestDB(t)
+	users := repositories.NewUsersRepository(db.DB)
+	userEmails := repositories.NewUserEmailsRepository(db.DB)
+	c := &externalAuthController{
+		users:      users,
+		userEmails: userEmails,
+		identities: repositories.NewUserIdentitiesRepository(db.DB),
+		settings:   repositories.NewInstanceSettingsRepository(db.DB),
+	}
+
+	alice, err := users.Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if _, err := userEmails.Create(alice.ID, "alice@example.com", true, true); err != nil {
+		t.Fatalf("create user email: %v", err)
+	}
+
+	// Providers that didn't verify the address can't claim the account
+	if _, err := c.findOrCreateUser(stubIdentityProvider{}, &services.ExternalIdentity{
+		Subject: "someone",
+		Email:   "alice@example.com",
+	}); err == nil {
+		t.Error("an unverified email at the provider was linked to the account")
+	}
+
+	user, err := c.findOrCreateUser(stubIdentityProvider{}, &services.ExternalIdentity{
+		Subject:       "alice",
+		Email:         "Alice@Example.com",
+		EmailVerified: true,
+	})
+	if err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if user.ID != alice.ID {
+		t.Errorf("signed in as user %d, want %d", user.ID, alice.ID)
+	}
+}
@@ -1,22 +1,152 @@
 package controllers
 
-import "net/http"
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/httputil"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+const (
+	// passwordResetTokenTTL is how long a reset link stays valid
+	passwordResetTokenTTL = time.Hour
+	// passwordResetWindow is the window the limits below apply to
+	passwordResetWindow = time.Hour
+	// passwordResetMaxPerEmail bounds how many reset emails a single address can receive per window
+	passwordResetMaxPerEmail = 3
+	// passwordResetMaxPerIP bounds how many resets a single client can request per window
+	passwordResetMaxPerIP = 10
+)
 
 type ForgotPasswordController interface {
 	Show(w http.ResponseWriter, r *http.Request) error
 	Handle(w http.ResponseWriter, r *http.Request) error
 }
 
-type forgotPasswordController struct{}
+type forgotPasswordController struct {
+	users          repositories.UsersRepository
+	identities     repositories.UserIdentitiesRepository
+	passwordResets repositories.PasswordResetsRepository
+	emailService   services.EmailService
+	publicURL      string
+}
 
-func NewForgotPasswordController() ForgotPasswordController {
-	return &forgotPasswordController{}
+func NewForgotPasswordController(users repositories.UsersRepository, identities repositories.UserIdentitiesRepository, passwordResets repositories.PasswordResetsRepository, emailService services.EmailService, publicURL string) ForgotPasswordController {
+	return &forgotPasswordController{
+		users:          users,
+		identities:     identities,
+		passwordResets: passwordResets,
+		emailService:   emailService,
+		publicURL:      publicURL,
+	}
 }
 
 func (c *forgotPasswordController) Show(w http.ResponseWriter, r *http.Request) error {
-	return nil
+	return pages.ForgotPassword(r, nil).Render(w, r)
 }
 
 func (c *forgotPasswordController) Handle(w http.ResponseWriter, r *http.Request) error {
-	return nil
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if email == "" {
+		return pages.ForgotPassword(r, &pages.ForgotPasswordData{
+			Error: "Email is required",
+		}).Render(w, r)
+	}
+
+	ip := httputil.ClientIP(r)
+	normalizedEmail := strings.ToLower(email)
+	since := time.Now().Add(-passwordResetWindow).Unix()
+
+	ipCount, err := c.passwordResets.CountRequestsByIPSince(ip, since)
+	if err != nil {
+		return err
+	}
+	if ipCount >= passwordResetMaxPerIP {
+		w.WriteHeader(http.StatusTooManyRequests)
+		return pages.ForgotPassword(r, &pages.ForgotPasswordData{
+			Email: email,
+			Error: "Too many password reset requests. Please try again later.",
+		}).Render(w, r)
+	}
+
+	emailCount, err := c.passwordResets.CountRequestsByEmailSince(normalizedEmail, since)
+	if err != nil {
+		return err
+	}
+
+	if err := c.passwordResets.RecordRequest(normalizedEmail, ip); err != nil {
+		return err
+	}
+
+	// Past the per-address limit we silently stop sending, so the limit can't be used
+	// to tell registered addresses apart or to flood someone's inbox
+	if emailCount < passwordResetMaxPerEmail {
+		if err := c.sendResetLink(email, ip); err != nil {
+			return err
+		}
+	}
+
+	return pages.ForgotPassword(r, &pages.ForgotPasswordData{
+		Sent: true,
+	}).Render(w, r)
+}
+
+// sendResetLink creates a reset token and emails it, if the address belongs to an account that signs in
+// with a password. Accounts without one only get a link if they can't sign in with an identity provider
+// either, which is the case after an administrator forced a password reset.
+func (c *forgotPasswordController) sendResetLink(email, ip string) error {
+	user, err := c.users.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		slog.Info("password reset requested for unknown or passwordless account", "ip", ip)
+		return nil
+	}
+	if user.Password == nil {
+		identities, err := c.identities.CountByUser(user.ID)
+		if err != nil {
+			return err
+		}
+		if identities > 0 {
+			slog.Info("password reset requested for unknown or passwordless account", "ip", ip)
+			return nil
+		}
+	}
+
+	return sendPasswordResetLink(c.passwordResets, c.emailService, c.publicURL, user, ip)
+}
+
+// sendPasswordResetLink creates a reset token for user and emails the link to their primary address
+func sendPasswordResetLink(passwordResets repositories.PasswordResetsRepository, emailService services.EmailService, publicURL string, user *models.User, ip string) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	rawToken := base64.RawURLEncoding.EncodeToString(b)
+	tokenHash := fmt.Sprintf("%x", sha256.Sum256([]byte(rawToken)))
+
+	expiresAt := time.Now().Add(passwordResetTokenTTL).Unix()
+	if _, err := passwordResets.CreateToken(user.ID, tokenHash, expiresAt, ip); err != nil {
+		return err
+	}
+
+	resetURL := publicURL + "/reset-password?token=" + url.QueryEscape(rawToken)
+	return emailService.SendPasswordReset(user.Email, user.Username, resetURL, passwordResetTokenTTL)
 }
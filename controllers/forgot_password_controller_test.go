@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/services/smtptest"
+)
+
+var resetTokenPattern = regexp.MustCompile(`/reset-password\?token=([A-Za-z0-9_-]+)`)
+
+func TestForgotPasswordEmailsAResetLink(t *testing.T) {
+	db := newTestDB(t)
+	users := repositories.NewUsersRepository(db.DB)
+	passwordResets := repositories.NewPasswordResetsRepository(db.DB)
+	outbox := repositories.NewEmailOutboxRepository(db.DB)
+
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatalf("start smtp server: %v", err)
+	}
+	defer server.Close()
+
+	emailService, err := services.NewEmailService(outbox, "https://hypercommit.test")
+	if err != nil {
+		t.Fatalf("create email service: %v", err)
+	}
+	worker := services.NewOutboxWorker(outbox, services.NewSMTPMailer(server.Host(), server.Port(), "", "", "noreply@example.com"))
+	controller := NewForgotPasswordController(users, repositories.NewUserIdentitiesRepository(db.DB), passwordResets, emailService, "https://hypercommit.test")
+
+	user, err := users.Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	for _, email := range []string{"nobody@example.com", "alice@example.com"} {
+		r := httptest.NewRequest(http.MethodPost, "/forgot-password", strings.NewReader(url.Values{"email": {email}}.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		if err := controller.Handle(w, r); err != nil {
+			t.Fatalf("request reset for %s: %v", email, err)
+		}
+		if w.Code != http.StatusOK {
+			t.Fatalf("request reset for %s: status %d", email, w.Code)
+		}
+	}
+
+	if err := worker.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("deliver outbox: %v", err)
+	}
+
+	// Only the registered address gets an email
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	if len(messages[0].To) != 1 || messages[0].To[0] != "alice@example.com" {
+		t.Errorf("envelope recipients = %v, want [alice@example.com]", messages[0].To)
+	}
+
+	body, err := io.ReadAll(quotedprintable.NewReader(strings.NewReader(messages[0].Data)))
+	if err != nil {
+		t.Fatalf("decode email: %v", err)
+	}
+	match := resetTokenPattern.FindStringSubmatch(string(body))
+	if match == nil {
+		t.Fatalf("no reset link in email:\n%s", body)
+	}
+
+	tokenHash := fmt.Sprintf("%x", sha256.Sum256([]byte(match[1])))
+	token, err := passwordResets.FindValidToken(tokenHash, time.Now().Unix())
+	if err != nil {
+		t.Fatalf("find token: %v", err)
+	}
+	if token == nil || token.UserID != user.ID {
+		t.Fatalf("reset link token = %+v, want a valid token of the user", token)
+	}
+}
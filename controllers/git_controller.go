@@ -1,7 +1,6 @@
 package controllers
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -13,6 +12,7 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/hypercommithq/hypercommit/database/models"
 	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httputil"
 	"github.com/hypercommithq/hypercommit/middleware"
 	"github.com/hypercommithq/hypercommit/services"
 )
@@ -24,31 +24,45 @@ type GitController interface {
 }
 
 type gitController struct {
-	users         repositories.UsersRepository
-	orgs          repositories.OrganizationsRepository
-	repos         repositories.RepositoriesRepository
-	contributors  repositories.ContributorsRepository
-	accessTokens  repositories.AccessTokensRepository
+	*apiAccess
 	authService   services.AuthService
+	throttle      services.AuthThrottleService
+	gitService    services.GitService
+	webhooks      services.WebhookService
+	workflows     services.WorkflowService
 	reposBasePath string
 }
 
 func NewGitController(
 	users repositories.UsersRepository,
 	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
 	repos repositories.RepositoriesRepository,
 	contributors repositories.ContributorsRepository,
-	accessTokens repositories.AccessTokensRepository,
+	accessTokens services.AccessTokenService,
 	authService services.AuthService,
+	twoFactor services.TwoFactorService,
+	throttle services.AuthThrottleService,
+	gitService services.GitService,
+	webhooks services.WebhookService,
+	workflows services.WorkflowService,
 	reposBasePath string,
 ) GitController {
 	return &gitController{
-		users:         users,
-		orgs:          orgs,
-		repos:         repos,
-		contributors:  contributors,
-		accessTokens:  accessTokens,
+		apiAccess: &apiAccess{
+			repos:        repos,
+			users:        users,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			contributors: contributors,
+			accessTokens: accessTokens,
+			twoFactor:    twoFactor,
+		},
 		authService:   authService,
+		throttle:      throttle,
+		gitService:    gitService,
+		webhooks:      webhooks,
+		workflows:     workflows,
 		reposBasePath: reposBasePath,
 	}
 }
@@ -109,6 +123,9 @@ func (c *gitController) handleGitOperation(w http.ResponseWriter, r *http.Reques
 		"visibility", repo.Visibility,
 		"isWriteOp", isWriteOp)
 
+	// pusher is who authenticated for a write, push webhooks are sent on their behalf
+	var pusher *models.User
+
 	if repo.Visibility != "public" || isWriteOp {
 		user, _ := c.authService.GetUserFromCookie(r)
 
@@ -125,6 +142,20 @@ func (c *gitController) handleGitOperation(w http.ResponseWriter, r *http.Reques
 
 			authenticatedUser, err := c.users.FindByUsername(username)
 			if err != nil {
+				return err
+			}
+
+			if wait := c.throttle.Check(r, authenticatedUser, username); wait > 0 {
+				httputil.SetRetryAfter(w, wait)
+				http.Error(w, "Too many failed authentication attempts. Try again later.", http.StatusTooManyRequests)
+				slog.Warn("git authentication throttled", "username", username, "wait", wait)
+				return nil
+			}
+
+			if authenticatedUser == nil {
+				if err := c.throttle.Failure(r, nil, username); err != nil {
+					return err
+				}
 				w.Header().Set("WWW-Authenticate", `Basic realm="Git Repository"`)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				slog.Warn("user not found", "username", username)
@@ -134,55 +165,106 @@ func (c *gitController) handleGitOperation(w http.ResponseWriter, r *http.Reques
 			// Try password authentication first
 			valid := authenticatedUser.Password != nil && c.authService.CheckPassword(password, *authenticatedUser.Password)
 
+			// A password alone would bypass the second factor, those accounts have to use an access token
+			if valid {
+				twoFactorEnabled, err := c.twoFactor.IsEnabled(authenticatedUser.ID)
+				if err != nil {
+					return err
+				}
+				if twoFactorEnabled {
+					w.Header().Set("WWW-Authenticate", `Basic realm="Git Repository"`)
+					http.Error(w, "Password authentication is not available for accounts with two-factor authentication. Use a personal access token instead.", http.StatusUnauthorized)
+					slog.Warn("password authentication refused for two-factor account", "username", username)
+					return nil
+				}
+			}
+
 			// If password auth fails, try access token authentication
 			if !valid {
-				valid, err = c.authenticateWithAccessToken(authenticatedUser.ID, password)
+				token, err := c.authenticateWithAccessToken(authenticatedUser.ID, password)
 				if err != nil {
 					slog.Warn("token authentication error", "username", username, "error", err)
 				}
+
+				if token != nil {
+					requiredScope := models.ScopeRepoRead
+					if isWriteOp {
+						requiredScope = models.ScopeRepoWrite
+					}
+
+					if !c.accessTokens.HasScope(token, requiredScope) {
+						http.Error(w, "Forbidden: access token is missing the "+requiredScope+" scope", http.StatusForbidden)
+						slog.Warn("access token missing scope", "username", username, "scope", requiredScope)
+						return nil
+					}
+
+					if !c.accessTokens.AllowsRepository(token, repo.ID) {
+						http.Error(w, "Forbidden: access token is not allowed to access this repository", http.StatusForbidden)
+						slog.Warn("access token not allowed for repository", "username", username, "repo", repoName)
+						return nil
+					}
+
+					valid = true
+				}
 			}
 
 			slog.Info("authentication check", "username", username, "valid", valid)
 			if !valid {
+				if err := c.throttle.Failure(r, authenticatedUser, username); err != nil {
+					return err
+				}
 				w.Header().Set("WWW-Authenticate", `Basic realm="Git Repository"`)
 				http.Error(w, "Unauthorized", http.StatusUnauthorized)
 				slog.Warn("invalid password or token", "username", username)
 				return nil
 			}
 
+			c.throttle.Success(authenticatedUser, username)
+
+			if authenticatedUser.SuspendedAt != nil {
+				http.Error(w, "Forbidden: "+accountSuspendedMessage, http.StatusForbidden)
+				slog.Warn("suspended user refused", "username", username)
+				return nil
+			}
+
 			user = authenticatedUser
 			slog.Info("basic auth successful", "username", username)
 		}
 
-		hasAccess := false
-
-		if repo.OwnerUserID != nil && *repo.OwnerUserID == user.ID {
-			hasAccess = true
-		} else if repo.OwnerOrgID != nil {
-			hasAccess = true
+		// The same rules as the API: owners and organization members may push, contributors as their
+		// role allows, and members of organizations requiring two-factor authentication need it
+		permission, err := c.permission(repo, user)
+		if err != nil {
+			return err
 		}
-
-		if !hasAccess && isWriteOp {
-			contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, user.ID)
-			if err == nil && contributor != nil {
-				if contributor.Role == "write" || contributor.Role == "admin" {
-					hasAccess = true
-				}
-			}
+		required := apiPermissionRead
+		if isWriteOp {
+			required = apiPermissionWrite
 		}
 
-		if !hasAccess && !isWriteOp {
-			contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, user.ID)
-			if err == nil && contributor != nil {
-				hasAccess = true
+		if permission < required && repo.OwnerOrgID != nil {
+			org, err := c.orgs.FindByID(*repo.OwnerOrgID)
+			if err != nil {
+				return err
+			}
+			missing, err := missingRequiredTwoFactor(org, user.ID, c.twoFactor)
+			if err != nil {
+				return err
+			}
+			if missing {
+				http.Error(w, "Forbidden: "+org.DisplayName+" requires two-factor authentication", http.StatusForbidden)
+				slog.Warn("user without required two-factor authentication", "user", user.Username, "owner", owner)
+				return nil
 			}
 		}
 
-		if !hasAccess {
+		if permission < required {
 			http.Error(w, "Forbidden", http.StatusForbidden)
 			slog.Warn("user does not have access", "user", user.Username, "owner", owner, "isWriteOp", isWriteOp)
 			return nil
 		}
+
+		pusher = user
 	}
 
 	repoPath := filepath.Join(c.reposBasePath, ownerIDForPath, fmt.Sprintf("%d", repo.ID))
@@ -225,12 +307,38 @@ func (c *gitController) handleGitOperation(w http.ResponseWriter, r *http.Reques
 	cmd.Stdin = r.Body
 	cmd.Stderr = os.Stderr
 
+	// Pushes are told apart by comparing the refs before and after receive-pack
+	var refsBefore map[string]string
+	if operation == "receive-pack" {
+		refsBefore, err = c.gitService.ListRefs(absRepoPath)
+		if err != nil {
+			slog.Error("failed to list refs before push", "error", err, "repo", repoName)
+		}
+	}
+
 	output, err := cmd.Output()
 	if err != nil {
 		http.Error(w, "Failed to execute git command", http.StatusInternalServerError)
 		return err
 	}
 
+	if refsBefore != nil && pusher != nil {
+		refsAfter, err := c.gitService.ListRefs(absRepoPath)
+		if err != nil {
+			slog.Error("failed to list refs after push", "error", err, "repo", repoName)
+		} else {
+			c.webhooks.Push(repo, pusher, refsBefore, refsAfter)
+
+			// Workflows run with the repository's secrets, only pushes of people who may write to it queue them
+			permission, err := c.permission(repo, pusher)
+			if err != nil {
+				slog.Error("failed to check the pusher's permission", "error", err, "repo", repoName)
+			} else if permission >= apiPermissionWrite {
+				c.workflows.Push(repo, pusher, refsBefore, refsAfter)
+			}
+		}
+	}
+
 	parts := strings.SplitN(string(output), "\r\n\r\n", 2)
 	if len(parts) < 2 {
 		parts = strings.SplitN(string(output), "\n\n", 2)
@@ -268,29 +376,17 @@ func (c *gitController) handleGitOperation(w http.ResponseWriter, r *http.Reques
 	return nil
 }
 
-// authenticateWithAccessToken checks if the provided token is valid for the user
-func (c *gitController) authenticateWithAccessToken(userID int64, rawToken string) (bool, error) {
-	// Hash the provided token the same way we did when storing it
-	hash := sha256.Sum256([]byte(rawToken))
-	tokenHash := fmt.Sprintf("%x", hash)
-
-	// Find the token
-	token, err := c.accessTokens.FindByTokenHash(tokenHash)
-	if err != nil {
-		return false, err
-	}
-
-	if token == nil {
-		return false, nil
+// authenticateWithAccessToken returns the access token if it is valid, unexpired and belongs to the user
+func (c *gitController) authenticateWithAccessToken(userID int64, rawToken string) (*models.AccessToken, error) {
+	token, err := c.accessTokens.Authenticate(rawToken)
+	if err != nil || token == nil {
+		return nil, err
 	}
 
 	// Verify the token belongs to the user
 	if token.UserID != userID {
-		return false, nil
+		return nil, nil
 	}
 
-	// Update last used timestamp (ignore errors as this is not critical)
-	_ = c.accessTokens.UpdateLastUsed(token.ID)
-
-	return true, nil
+	return token, nil
 }
@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+func TestGitAccessFollowsRepositoryPermissions(t *testing.T) {
+	db := newTestDB(t)
+	dir := t.TempDir()
+	users := repositories.NewUsersRepository(db.DB)
+	orgs := repositories.NewOrganizationsRepository(db.DB)
+	repos := repositories.NewRepositoriesRepository(db.DB)
+	authService := services.NewAuthService(users, repositories.NewSessionsRepository(db.DB), "signing secret")
+	git := services.NewGitService(dir)
+
+	controller := NewGitController(
+		users,
+		orgs,
+		repositories.NewOrganizationMembersRepository(db.DB),
+		repos,
+		repositories.NewContributorsRepository(db.DB),
+		services.NewAccessTokenService(repositories.NewAccessTokensRepository(db.DB)),
+		authService,
+		services.NewTwoFactorService(repositories.NewTwoFactorRepository(db.DB)),
+		services.NewAuthThrottleService(services.NewMemoryRateLimiter(), repositories.NewAuthLockoutsRepository(db.DB), services.AuthThrottleLimits{
+			IPRateLimit:        100,
+			AccountRateLimit:   100,
+			LockoutThreshold:   100,
+			LockoutDuration:    time.Minute,
+			LockoutMaxDuration: time.Hour,
+		}),
+		git,
+		services.NewWebhookService(repositories.NewWebhooksRepository(db.DB), repositories.NewWebhookDeliveriesRepository(db.DB), repos, users, orgs, repositories.NewInstanceSettingsRepository(db.DB), git, testPublicURL),
+		services.NewWorkflowService(repositories.NewWorkflowRunsRepository(db.DB), repositories.NewCommitStatusesRepository(db.DB), repos, users, orgs, git, testPublicURL),
+		dir,
+	)
+
+	hash, err := authService.HashPassword("correct horse")
+	if err != nil {
+		t.Fatalf("hash password: %v", err)
+	}
+	accounts := map[string]int64{}
+	for _, username := range []string{"owner", "member", "reader", "outsider"} {
+		user, err := users.Create(username, username+"@example.com", username, hash)
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		accounts[username] = user.ID
+	}
+
+	acme := mustExec(t, db, `INSERT INTO organizations (username, display_name) VALUES ('acme', 'Acme')`)
+	mustExec(t, db, `INSERT INTO organization_members (organization_id, user_id, role) VALUES (?, ?, 'owner')`, acme, accounts["owner"])
+	mustExec(t, db, `INSERT INTO organization_members (organization_id, user_id, role) VALUES (?, ?, 'member')`, acme, accounts["member"])
+	for _, visibility := range []string{"public", "private"} {
+		repo, err := repos.CreateForOrg(acme, visibility, visibility, "main", nil)
+		if err != nil {
+			t.Fatalf("create repository: %v", err)
+		}
+		if err := git.InitRepository(repo); err != nil {
+			t.Fatalf("init repository: %v", err)
+		}
+		mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'read')`, repo.ID, accounts["reader"])
+	}
+
+	router := chi.NewRouter()
+	router.Route("/{owner}", func(r chi.Router) {
+		r.Use(middleware.OwnerResolver(users, orgs))
+		r.Route("/{repo}", func(r chi.Router) {
+			r.Get("/info/refs", func(w http.ResponseWriter, r *http.Request) {
+				if err := controller.InfoRefs(w, r); err != nil {
+					httperror.WriteJSON(w, err)
+				}
+			})
+		})
+	})
+
+	for _, tt := range []struct {
+		user    string
+		repo    string
+		service string
+		want    int
+	}{
+		{"", "public", "git-upload-pack", http.StatusOK},
+		{"", "public", "git-receive-pack", http.StatusUnauthorized},
+		{"outsider", "public", "git-upload-pack", http.StatusOK},
+		{"outsider", "public", "git-receive-pack", http.StatusForbidden},
+		{"outsider", "private", "git-upload-pack", http.StatusForbidden},
+		{"outsider", "private", "git-receive-pack", http.StatusForbidden},
+		{"reader", "private", "git-upload-pack", http.StatusOK},
+		{"reader", "private", "git-receive-pack", http.StatusForbidden},
+		{"member", "private", "git-receive-pack", http.StatusOK},
+		{"owner", "private", "git-receive-pack", http.StatusOK},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/acme/"+tt.repo+".git/info/refs?service="+tt.service, nil)
+		if tt.user != "" {
+			r.SetBasicAuth(tt.user, "correct horse")
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tt.want {
+			t.Errorf("%q %s of the %s repository: status %d, want %d", tt.user, tt.service, tt.repo, w.Code, tt.want)
+		}
+	}
+}
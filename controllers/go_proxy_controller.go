@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/services"
+	"golang.org/x/mod/module"
+)
+
+// GoProxyController serves the Go modules of repositories with the GOPROXY protocol under /goproxy,
+// for GOPROXY=https://<host>/goproxy. Private modules need an access token with the repo:read scope,
+// as the password of basic authentication, such as from .netrc, or as a bearer token.
+type GoProxyController interface {
+	// Serve answers <module>/@v/list, <module>/@v/<version>.info, .mod and .zip, and <module>/@latest
+	Serve(w http.ResponseWriter, r *http.Request) error
+}
+
+type goProxyController struct {
+	*apiAccess
+	goModules services.GoModuleService
+}
+
+func NewGoProxyController(
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	accessTokens services.AccessTokenService,
+	twoFactor services.TwoFactorService,
+	goModules services.GoModuleService,
+) GoProxyController {
+	return &goProxyController{
+		apiAccess: &apiAccess{
+			repos:        repos,
+			users:        users,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			contributors: contributors,
+			accessTokens: accessTokens,
+			twoFactor:    twoFactor,
+		},
+		goModules: goModules,
+	}
+}
+
+// goProxyInfoJSON is the .info of a version
+type goProxyInfoJSON struct {
+	Version string    `json:"Version"`
+	Time    time.Time `json:"Time"`
+}
+
+func (c *goProxyController) Serve(w http.ResponseWriter, r *http.Request) error {
+	path := chi.URLParam(r, "*")
+
+	escapedPath, file, found := strings.Cut(path, "/@v/")
+	latest := false
+	if !found {
+		escapedPath, latest = strings.CutSuffix(path, "/@latest")
+		if !latest {
+			return httperror.NotFound("not found")
+		}
+	}
+
+	modulePath, err := module.UnescapePath(escapedPath)
+	if err != nil {
+		return httperror.NotFound("not found: invalid module path")
+	}
+	repo, err := c.findModuleRepository(w, r, modulePath)
+	if err != nil {
+		return err
+	}
+
+	if latest {
+		version, err := c.goModules.Latest(repo, modulePath)
+		if err != nil {
+			return c.moduleError(err)
+		}
+		if version == nil {
+			return httperror.NotFound("not found: the module has no versions")
+		}
+		return writeGoProxyInfo(w, r, version)
+	}
+
+	if file == "list" {
+		versions, err := c.goModules.Versions(repo, modulePath)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, version := range versions {
+			w.Write([]byte(version + "\n"))
+		}
+		return nil
+	}
+
+	escapedVersion, ext, _ := cutLast(file, ".")
+	versionName, err := module.UnescapeVersion(escapedVersion)
+	if err != nil {
+		return httperror.NotFound("not found: invalid version")
+	}
+	version, err := c.goModules.Version(repo, modulePath, versionName)
+	if err != nil {
+		return c.moduleError(err)
+	}
+	if version == nil {
+		return httperror.NotFound("not found: unknown version " + versionName)
+	}
+
+	switch ext {
+	case "info":
+		return writeGoProxyInfo(w, r, version)
+	case "mod":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, err := w.Write([]byte(version.GoMod))
+		return err
+	case "zip":
+		zipPath, err := c.goModules.Zip(repo, modulePath, version)
+		if err != nil {
+			return c.moduleError(err)
+		}
+		zipFile, err := os.Open(zipPath)
+		if err != nil {
+			return err
+		}
+		defer zipFile.Close()
+
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("ETag", `"`+*version.ZipHash+`"`)
+		w.Header().Set("Cache-Control", "private, max-age=86400, immutable")
+		http.ServeContent(w, r, "", time.Unix(version.CreatedAt, 0), zipFile)
+		return nil
+	}
+	return httperror.NotFound("not found")
+}
+
+// findModuleRepository returns the repository of the module if the request may read it. Requests
+// without credentials are asked for them for any module that isn't public, so that they don't tell
+// private modules from missing ones.
+func (c *goProxyController) findModuleRepository(w http.ResponseWriter, r *http.Request, modulePath string) (*models.Repository, error) {
+	user, token, err := c.authenticateToken(r)
+	var httpErr httperror.HTTPError
+	if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="Hypercommit"`)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	owner, name, ok := c.goModules.ParsePath(modulePath)
+	if !ok {
+		return nil, httperror.NotFound("not found: " + modulePath + " is not hosted here")
+	}
+	repo, err := c.repos.FindByOwnerAndName(owner, name)
+	if err != nil {
+		return nil, err
+	}
+
+	permission := apiPermissionNone
+	if repo != nil {
+		permission, err = c.permission(repo, user)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if permission == apiPermissionNone || (token != nil && !c.accessTokens.AllowsRepository(token, repo.ID)) {
+		if user == nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Hypercommit"`)
+			return nil, httperror.Unauthorized("authentication required")
+		}
+		return nil, httperror.NotFound("not found: repository " + owner + "/" + name + " not found")
+	}
+	return repo, nil
+}
+
+// moduleError reports versions that aren't versions of the module as not found, as the go command
+// expects
+func (c *goProxyController) moduleError(err error) error {
+	if errors.Is(err, services.ErrGoModulePathMismatch) || errors.Is(err, services.ErrInvalidGoModuleZip) {
+		return httperror.NotFound("not found: " + err.Error())
+	}
+	return err
+}
+
+func writeGoProxyInfo(w http.ResponseWriter, r *http.Request, version *models.GoModuleVersion) error {
+	return writeAPIJSON(w, r, http.StatusOK, goProxyInfoJSON{
+		Version: version.Version,
+		Time:    time.Unix(version.CommitTime, 0).UTC(),
+	})
+}
+
+// cutLast slices s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
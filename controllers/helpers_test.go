@@ -0,0 +1,20 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database"
+)
+
+// newTestDB opens an empty in-memory database with the full schema
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
@@ -0,0 +1,555 @@
+package controllers
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	custommiddleware "github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+type MilestonesController interface {
+	List(w http.ResponseWriter, r *http.Request) error
+	New(w http.ResponseWriter, r *http.Request) error
+	Create(w http.ResponseWriter, r *http.Request) error
+	Edit(w http.ResponseWriter, r *http.Request) error
+	Update(w http.ResponseWriter, r *http.Request) error
+	ConfirmClose(w http.ResponseWriter, r *http.Request) error
+	Close(w http.ResponseWriter, r *http.Request) error
+	Reopen(w http.ResponseWriter, r *http.Request) error
+	Delete(w http.ResponseWriter, r *http.Request) error
+}
+
+type milestonesController struct {
+	*apiAccess
+	milestones  repositories.MilestonesRepository
+	stars       repositories.StarsRepository
+	authService services.AuthService
+}
+
+func NewMilestonesController(
+	milestones repositories.MilestonesRepository,
+	repos repositories.RepositoriesRepository,
+	stars repositories.StarsRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	twoFactor services.TwoFactorService,
+	authService services.AuthService,
+) MilestonesController {
+	return &milestonesController{
+		apiAccess: &apiAccess{
+			repos:        repos,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			contributors: contributors,
+			twoFactor:    twoFactor,
+		},
+		milestones:  milestones,
+		stars:       stars,
+		authService: authService,
+	}
+}
+
+func (c *milestonesController) List(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+
+	statusFilter := r.URL.Query().Get("status")
+	if statusFilter != "closed" {
+		statusFilter = "open"
+	}
+
+	milestones, err := c.milestones.FindAllByRepository(repo.ID, statusFilter)
+	if err != nil {
+		slog.Error("failed to fetch milestones", "error", err)
+		milestones = []*models.Milestone{}
+	}
+
+	// Compute progress from open/closed ticket counts
+	progress := make([]pages.MilestoneProgress, 0, len(milestones))
+	for _, milestone := range milestones {
+		openTickets, _ := c.milestones.CountTickets(milestone.ID, "open")
+		closedTickets, _ := c.milestones.CountTickets(milestone.ID, "closed")
+		progress = append(progress, pages.MilestoneProgress{
+			Milestone:     milestone,
+			OpenTickets:   openTickets,
+			ClosedTickets: closedTickets,
+		})
+	}
+
+	openCount, _ := c.milestones.CountByRepository(repo.ID, "open")
+	closedCount, _ := c.milestones.CountByRepository(repo.ID, "closed")
+
+	canManage := permission >= apiPermissionAdmin
+
+	// Get star info
+	starCount, _ := c.stars.CountByRepository(repo.ID)
+	hasStarred := false
+	if currentUser != nil {
+		star, _ := c.stars.FindByUserAndRepository(repo.ID, currentUser.ID)
+		hasStarred = star != nil
+	}
+
+	cloneURL := "https://" + r.Host + "/" + owner + "/" + repoName
+	repositoryURL := cloneURL
+
+	return pages.MilestonesList(r, &pages.MilestonesListData{
+		User:          currentUser,
+		Repository:    repo,
+		OwnerUsername: owner,
+		Milestones:    progress,
+		StatusFilter:  statusFilter,
+		OpenCount:     openCount,
+		ClosedCount:   closedCount,
+		CanManage:     canManage,
+		StarCount:     starCount,
+		HasStarred:    hasStarred,
+		CloneURL:      cloneURL,
+		RepositoryURL: repositoryURL,
+	}).Render(w, r)
+}
+
+func (c *milestonesController) New(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("access denied")
+	}
+
+	return pages.MilestoneForm(r, c.formData(r, repo, currentUser, owner)).Render(w, r)
+}
+
+func (c *milestonesController) Create(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("access denied")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form data")
+	}
+
+	data := c.formData(r, repo, currentUser, owner)
+	data.Title = strings.TrimSpace(r.FormValue("title"))
+	data.Description = r.FormValue("description")
+	data.DueDate = r.FormValue("due_date")
+
+	hasErrors := false
+
+	if data.Title == "" {
+		data.TitleError = "Title is required"
+		hasErrors = true
+	} else if existing, _ := c.milestones.FindByRepositoryAndTitle(repo.ID, data.Title); existing != nil {
+		data.TitleError = "A milestone with this title already exists"
+		hasErrors = true
+	}
+
+	dueDate, err := parseDueDate(data.DueDate)
+	if err != nil {
+		data.DueDateError = "Due date must be a valid date"
+		hasErrors = true
+	}
+
+	if hasErrors {
+		return pages.MilestoneForm(r, data).Render(w, r)
+	}
+
+	var description *string
+	if data.Description != "" {
+		description = &data.Description
+	}
+
+	_, err = c.milestones.Create(repo.ID, data.Title, description, dueDate)
+	if err != nil {
+		slog.Error("failed to create milestone", "error", err)
+		return httperror.New(500, "failed to create milestone")
+	}
+
+	http.Redirect(w, r, "/"+owner+"/"+repoName+"/milestones", http.StatusSeeOther)
+	return nil
+}
+
+func (c *milestonesController) Edit(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("access denied")
+	}
+
+	milestone, err := c.findMilestone(r, repo)
+	if err != nil {
+		return err
+	}
+
+	data := c.formData(r, repo, currentUser, owner)
+	data.Milestone = milestone
+	data.Title = milestone.Title
+	if milestone.Description != nil {
+		data.Description = *milestone.Description
+	}
+	if milestone.DueDate != nil {
+		data.DueDate = time.Unix(*milestone.DueDate, 0).UTC().Format("2006-01-02")
+	}
+
+	return pages.MilestoneForm(r, data).Render(w, r)
+}
+
+func (c *milestonesController) Update(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("access denied")
+	}
+
+	milestone, err := c.findMilestone(r, repo)
+	if err != nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form data")
+	}
+
+	data := c.formData(r, repo, currentUser, owner)
+	data.Milestone = milestone
+	data.Title = strings.TrimSpace(r.FormValue("title"))
+	data.Description = r.FormValue("description")
+	data.DueDate = r.FormValue("due_date")
+
+	hasErrors := false
+
+	if data.Title == "" {
+		data.TitleError = "Title is required"
+		hasErrors = true
+	} else if data.Title != milestone.Title {
+		if existing, _ := c.milestones.FindByRepositoryAndTitle(repo.ID, data.Title); existing != nil {
+			data.TitleError = "A milestone with this title already exists"
+			hasErrors = true
+		}
+	}
+
+	dueDate, err := parseDueDate(data.DueDate)
+	if err != nil {
+		data.DueDateError = "Due date must be a valid date"
+		hasErrors = true
+	}
+
+	if hasErrors {
+		return pages.MilestoneForm(r, data).Render(w, r)
+	}
+
+	milestone.Title = data.Title
+	milestone.Description = nil
+	if data.Description != "" {
+		milestone.Description = &data.Description
+	}
+	milestone.DueDate = dueDate
+
+	if err := c.milestones.Update(milestone); err != nil {
+		slog.Error("failed to update milestone", "error", err)
+		return httperror.New(500, "failed to update milestone")
+	}
+
+	http.Redirect(w, r, "/"+owner+"/"+repoName+"/milestones", http.StatusSeeOther)
+	return nil
+}
+
+// ConfirmClose shows the close dialog, offering to move open tickets to another milestone
+func (c *milestonesController) ConfirmClose(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("access denied")
+	}
+
+	milestone, err := c.findMilestone(r, repo)
+	if err != nil {
+		return err
+	}
+
+	openTickets, _ := c.milestones.CountTickets(milestone.ID, "open")
+
+	// Candidate targets are the other open milestones
+	others, err := c.milestones.FindAllByRepository(repo.ID, "open")
+	if err != nil {
+		slog.Error("failed to fetch milestones", "error", err)
+	}
+	targets := make([]*models.Milestone, 0, len(others))
+	for _, other := range others {
+		if other.ID != milestone.ID {
+			targets = append(targets, other)
+		}
+	}
+
+	starCount, _ := c.stars.CountByRepository(repo.ID)
+	star, _ := c.stars.FindByUserAndRepository(repo.ID, currentUser.ID)
+
+	cloneURL := "https://" + r.Host + "/" + owner + "/" + repoName
+	repositoryURL := cloneURL
+
+	return pages.CloseMilestone(r, &pages.CloseMilestoneData{
+		User:          currentUser,
+		Repository:    repo,
+		OwnerUsername: owner,
+		Milestone:     milestone,
+		OpenTickets:   openTickets,
+		Targets:       targets,
+		StarCount:     starCount,
+		HasStarred:    star != nil,
+		CloneURL:      cloneURL,
+		RepositoryURL: repositoryURL,
+	}).Render(w, r)
+}
+
+func (c *milestonesController) Close(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("access denied")
+	}
+
+	milestone, err := c.findMilestone(r, repo)
+	if err != nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form data")
+	}
+
+	// Optionally move the remaining open tickets before closing
+	switch moveTo := r.FormValue("move_to"); moveTo {
+	case "", "keep":
+	case "none":
+		if err := c.milestones.MoveOpenTickets(milestone.ID, nil); err != nil {
+			slog.Error("failed to move open tickets", "error", err)
+			return httperror.New(500, "failed to move open tickets")
+		}
+	default:
+		targetID, err := strconv.ParseInt(moveTo, 10, 64)
+		if err != nil {
+			return httperror.BadRequest("invalid target milestone")
+		}
+
+		target, err := c.milestones.FindByID(targetID)
+		if err != nil || target == nil || target.RepositoryID != repo.ID || target.ID == milestone.ID {
+			return httperror.BadRequest("invalid target milestone")
+		}
+
+		if err := c.milestones.MoveOpenTickets(milestone.ID, &target.ID); err != nil {
+			slog.Error("failed to move open tickets", "error", err)
+			return httperror.New(500, "failed to move open tickets")
+		}
+	}
+
+	if err := c.milestones.Close(milestone.ID); err != nil {
+		slog.Error("failed to close milestone", "error", err)
+		return httperror.New(500, "failed to close milestone")
+	}
+
+	http.Redirect(w, r, "/"+owner+"/"+repoName+"/milestones", http.StatusSeeOther)
+	return nil
+}
+
+func (c *milestonesController) Reopen(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("access denied")
+	}
+
+	milestone, err := c.findMilestone(r, repo)
+	if err != nil {
+		return err
+	}
+
+	if err := c.milestones.Reopen(milestone.ID); err != nil {
+		slog.Error("failed to reopen milestone", "error", err)
+		return httperror.New(500, "failed to reopen milestone")
+	}
+
+	http.Redirect(w, r, "/"+owner+"/"+repoName+"/milestones?status=closed", http.StatusSeeOther)
+	return nil
+}
+
+func (c *milestonesController) Delete(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("access denied")
+	}
+
+	milestone, err := c.findMilestone(r, repo)
+	if err != nil {
+		return err
+	}
+
+	if err := c.milestones.Delete(milestone.ID); err != nil {
+		slog.Error("failed to delete milestone", "error", err)
+		return httperror.New(500, "failed to delete milestone")
+	}
+
+	http.Redirect(w, r, "/"+owner+"/"+repoName+"/milestones", http.StatusSeeOther)
+	return nil
+}
+
+// findMilestone loads the milestone from the {id} URL parameter and checks it belongs to the repository
+func (c *milestonesController) findMilestone(r *http.Request, repo *models.Repository) (*models.Milestone, error) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, httperror.BadRequest("invalid milestone id")
+	}
+
+	milestone, err := c.milestones.FindByID(id)
+	if err != nil {
+		return nil, httperror.New(500, "failed to find milestone")
+	}
+	if milestone == nil || milestone.RepositoryID != repo.ID {
+		return nil, httperror.NotFound("milestone not found")
+	}
+
+	return milestone, nil
+}
+
+func (c *milestonesController) formData(r *http.Request, repo *models.Repository, user *models.User, owner string) *pages.MilestoneFormData {
+	starCount, _ := c.stars.CountByRepository(repo.ID)
+	star, _ := c.stars.FindByUserAndRepository(repo.ID, user.ID)
+
+	cloneURL := "https://" + r.Host + "/" + owner + "/" + repo.Name
+
+	return &pages.MilestoneFormData{
+		User:          user,
+		Repository:    repo,
+		OwnerUsername: owner,
+		StarCount:     starCount,
+		HasStarred:    star != nil,
+		CloneURL:      cloneURL,
+		RepositoryURL: cloneURL,
+	}
+}
+
+// parseDueDate parses a YYYY-MM-DD date input, an empty value means no due date
+func parseDueDate(value string) (*int64, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil, err
+	}
+
+	unix := t.Unix()
+	return &unix, nil
+}
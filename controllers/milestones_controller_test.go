@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// repositoryRequest is a request for a page of alice's repository by the user, anonymous if nil
+func repositoryRequest(method, target, repo string, user *models.User) *http.Request {
+	r := httptest.NewRequest(method, target, nil)
+	routeContext := chi.NewRouteContext()
+	routeContext.URLParams.Add("owner", "alice")
+	routeContext.URLParams.Add("repo", repo)
+	ctx := context.WithValue(r.Context(), chi.RouteCtxKey, routeContext)
+	return r.WithContext(context.WithValue(ctx, middleware.ContextKeyUser, user))
+}
+
+func TestMilestonesOfPrivateRepositoriesAreHidden(t *testing.T) {
+	db := newTestDB(t)
+	access := newTestAPIAccess(db)
+	milestones := repositories.NewMilestonesRepository(db.DB)
+	controller := NewMilestonesController(
+		milestones,
+		access.repos,
+		repositories.NewStarsRepository(db.DB),
+		access.orgs,
+		access.orgMembers,
+		access.contributors,
+		access.twoFactor,
+		services.NewAuthService(access.users, repositories.NewSessionsRepository(db.DB), "signing secret"),
+	)
+
+	users := map[string]*models.User{}
+	for _, username := range []string{"alice", "reader", "outsider"} {
+		user, err := access.users.Create(username, username+"@example.com", username, "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users[username] = user
+	}
+	repo, err := access.repos.CreateForUser(users["alice"].ID, "secret", "private", "main", nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+	mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'read')`, repo.ID, users["reader"].ID)
+	milestone, err := milestones.Create(repo.ID, "Launch plan", nil, nil)
+	if err != nil {
+		t.Fatalf("create milestone: %v", err)
+	}
+	closePath := "/alice/secret/milestones/" + strconv.FormatInt(milestone.ID, 10) + "/close"
+
+	for _, tt := range []struct {
+		name    string
+		handler func(http.ResponseWriter, *http.Request) error
+		method  string
+		target  string
+	}{
+		{"list", controller.List, http.MethodGet, "/alice/secret/milestones"},
+		{"closed list", controller.List, http.MethodGet, "/alice/secret/milestones?status=closed"},
+		{"new", controller.New, http.MethodGet, "/alice/secret/milestones/new"},
+		{"close", controller.ConfirmClose, http.MethodGet, closePath},
+	} {
+		for _, user := range []*models.User{nil, users["outsider"]} {
+			r := repositoryRequest(tt.method, tt.target, "secret", user)
+			chi.RouteContext(r.Context()).URLParams.Add("id", strconv.FormatInt(milestone.ID, 10))
+			w := httptest.NewRecorder()
+			err := tt.handler(w, r)
+
+			var httpErr httperror.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+				t.Errorf("%s as %v: error %v, want not found", tt.name, user, err)
+			}
+			if strings.Contains(w.Body.String(), "Launch plan") {
+				t.Errorf("%s as %v shows the milestone", tt.name, user)
+			}
+		}
+	}
+
+	w := httptest.NewRecorder()
+	if err := controller.List(w, repositoryRequest(http.MethodGet, "/alice/secret/milestones", "secret", users["reader"])); err != nil {
+		t.Fatalf("list as a contributor: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), "Launch plan") {
+		t.Error("the milestone isn't listed for a contributor")
+	}
+}
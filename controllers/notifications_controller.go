@@ -0,0 +1,270 @@
+package controllers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	custommiddleware "github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+type NotificationsController interface {
+	Index(w http.ResponseWriter, r *http.Request) error
+	Open(w http.ResponseWriter, r *http.Request) error
+	MarkRead(w http.ResponseWriter, r *http.Request) error
+	MarkAllRead(w http.ResponseWriter, r *http.Request) error
+	MarkDone(w http.ResponseWriter, r *http.Request) error
+	Unsubscribe(w http.ResponseWriter, r *http.Request) error
+}
+
+type notificationsController struct {
+	notifications repositories.NotificationsRepository
+	tickets       repositories.TicketsRepository
+	repos         repositories.RepositoriesRepository
+	users         repositories.UsersRepository
+	orgs          repositories.OrganizationsRepository
+}
+
+func NewNotificationsController(
+	notifications repositories.NotificationsRepository,
+	tickets repositories.TicketsRepository,
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+) NotificationsController {
+	return &notificationsController{
+		notifications: notifications,
+		tickets:       tickets,
+		repos:         repos,
+		users:         users,
+		orgs:          orgs,
+	}
+}
+
+func (c *notificationsController) Index(w http.ResponseWriter, r *http.Request) error {
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	filter := r.URL.Query().Get("filter")
+	if filter != "all" && filter != "done" {
+		filter = "unread"
+	}
+
+	notifications, err := c.notifications.FindAllByUser(currentUser.ID, filter)
+	if err != nil {
+		slog.Error("failed to fetch notifications", "error", err)
+		return httperror.New(500, "failed to fetch notifications")
+	}
+
+	unreadCount, err := c.notifications.CountUnread(currentUser.ID)
+	if err != nil {
+		slog.Error("failed to count unread notifications", "error", err)
+	}
+
+	// Resolve tickets, repositories and actors, caching lookups shared by several notifications
+	tickets := make(map[int64]*models.Ticket)
+	repoNames := make(map[int64]string)
+	actors := make(map[int64]*models.User)
+
+	items := make([]pages.NotificationItem, 0, len(notifications))
+	for _, notification := range notifications {
+		ticket, ok := tickets[notification.TicketID]
+		if !ok {
+			ticket, err = c.tickets.FindByID(notification.TicketID)
+			if err != nil {
+				slog.Error("failed to fetch notification ticket", "error", err)
+			}
+			tickets[notification.TicketID] = ticket
+		}
+		if ticket == nil {
+			continue
+		}
+
+		repoName, ok := repoNames[ticket.RepositoryID]
+		if !ok {
+			repoName = c.repositoryFullName(ticket.RepositoryID)
+			repoNames[ticket.RepositoryID] = repoName
+		}
+		if repoName == "" {
+			continue
+		}
+
+		actor, ok := actors[notification.ActorID]
+		if !ok {
+			actor, err = c.users.FindByID(notification.ActorID)
+			if err != nil {
+				slog.Error("failed to fetch notification actor", "error", err)
+			}
+			actors[notification.ActorID] = actor
+		}
+
+		items = append(items, pages.NotificationItem{
+			Notification:   notification,
+			Ticket:         ticket,
+			Actor:          actor,
+			RepositoryName: repoName,
+		})
+	}
+
+	return pages.Notifications(r, &pages.NotificationsData{
+		User:        currentUser,
+		Items:       items,
+		Filter:      filter,
+		UnreadCount: unreadCount,
+	}).Render(w, r)
+}
+
+// Open marks a notification as read and redirects to its ticket
+func (c *notificationsController) Open(w http.ResponseWriter, r *http.Request) error {
+	currentUser, notification, err := c.findNotification(w, r)
+	if err != nil || notification == nil {
+		return err
+	}
+
+	if err := c.notifications.MarkRead(notification.ID, currentUser.ID); err != nil {
+		slog.Error("failed to mark notification as read", "error", err)
+	}
+
+	ticket, err := c.tickets.FindByID(notification.TicketID)
+	if err != nil || ticket == nil {
+		return httperror.NotFound("ticket not found")
+	}
+
+	repoName := c.repositoryFullName(ticket.RepositoryID)
+	if repoName == "" {
+		return httperror.NotFound("repository not found")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/%s/tickets/%d", repoName, ticket.Number), http.StatusSeeOther)
+	return nil
+}
+
+func (c *notificationsController) MarkRead(w http.ResponseWriter, r *http.Request) error {
+	currentUser, notification, err := c.findNotification(w, r)
+	if err != nil || notification == nil {
+		return err
+	}
+
+	if err := c.notifications.MarkRead(notification.ID, currentUser.ID); err != nil {
+		slog.Error("failed to mark notification as read", "error", err)
+		return httperror.New(500, "failed to update notification")
+	}
+
+	c.redirectBack(w, r)
+	return nil
+}
+
+func (c *notificationsController) MarkAllRead(w http.ResponseWriter, r *http.Request) error {
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := c.notifications.MarkAllRead(currentUser.ID); err != nil {
+		slog.Error("failed to mark notifications as read", "error", err)
+		return httperror.New(500, "failed to update notifications")
+	}
+
+	c.redirectBack(w, r)
+	return nil
+}
+
+func (c *notificationsController) MarkDone(w http.ResponseWriter, r *http.Request) error {
+	currentUser, notification, err := c.findNotification(w, r)
+	if err != nil || notification == nil {
+		return err
+	}
+
+	if err := c.notifications.MarkDone(notification.ID, currentUser.ID); err != nil {
+		slog.Error("failed to mark notification as done", "error", err)
+		return httperror.New(500, "failed to update notification")
+	}
+
+	c.redirectBack(w, r)
+	return nil
+}
+
+// Unsubscribe stops notifications for the notification's ticket and marks it as done
+func (c *notificationsController) Unsubscribe(w http.ResponseWriter, r *http.Request) error {
+	currentUser, notification, err := c.findNotification(w, r)
+	if err != nil || notification == nil {
+		return err
+	}
+
+	if err := c.notifications.SetSubscribed(notification.TicketID, currentUser.ID, false); err != nil {
+		slog.Error("failed to unsubscribe from ticket", "error", err)
+		return httperror.New(500, "failed to unsubscribe")
+	}
+	if err := c.notifications.MarkDone(notification.ID, currentUser.ID); err != nil {
+		slog.Error("failed to mark notification as done", "error", err)
+	}
+
+	c.redirectBack(w, r)
+	return nil
+}
+
+// findNotification loads the notification from the URL and makes sure it belongs to the current user.
+// When it returns a nil notification without an error, a response has already been written.
+func (c *notificationsController) findNotification(w http.ResponseWriter, r *http.Request) (*models.User, *models.Notification, error) {
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil, nil, nil
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, nil, httperror.BadRequest("invalid notification id")
+	}
+
+	notification, err := c.notifications.FindByID(id)
+	if err != nil {
+		return nil, nil, httperror.New(500, "failed to find notification")
+	}
+	if notification == nil || notification.UserID != currentUser.ID {
+		return nil, nil, httperror.NotFound("notification not found")
+	}
+
+	return currentUser, notification, nil
+}
+
+// repositoryFullName returns "owner/name" for a repository, or an empty string if it can't be resolved
+func (c *notificationsController) repositoryFullName(repositoryID int64) string {
+	repo, err := c.repos.FindByID(repositoryID)
+	if err != nil || repo == nil {
+		return ""
+	}
+
+	if repo.OwnerUserID != nil {
+		owner, err := c.users.FindByID(*repo.OwnerUserID)
+		if err == nil && owner != nil {
+			return owner.Username + "/" + repo.Name
+		}
+	} else if repo.OwnerOrgID != nil {
+		owner, err := c.orgs.FindByID(*repo.OwnerOrgID)
+		if err == nil && owner != nil {
+			return owner.Username + "/" + repo.Name
+		}
+	}
+
+	return ""
+}
+
+// redirectBack returns to the inbox, keeping the filter the user was looking at
+func (c *notificationsController) redirectBack(w http.ResponseWriter, r *http.Request) {
+	target := "/notifications"
+	if filter := r.FormValue("filter"); filter == "all" || filter == "done" {
+		target += "?filter=" + filter
+	}
+	http.Redirect(w, r, target, http.StatusSeeOther)
+}
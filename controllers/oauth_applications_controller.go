@@ -0,0 +1,444 @@
+package controllers
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// OAuthApplicationsController manages the OAuth applications registered by users, under /settings/applications,
+// and by organizations, under /{owner}/settings/applications, as well as the applications users authorized
+type OAuthApplicationsController interface {
+	Index(w http.ResponseWriter, r *http.Request) error
+	New(w http.ResponseWriter, r *http.Request) error
+	Create(w http.ResponseWriter, r *http.Request) error
+	Show(w http.ResponseWriter, r *http.Request) error
+	Update(w http.ResponseWriter, r *http.Request) error
+	ResetSecret(w http.ResponseWriter, r *http.Request) error
+	Delete(w http.ResponseWriter, r *http.Request) error
+	RevokeAuthorization(w http.ResponseWriter, r *http.Request) error
+}
+
+type oauthApplicationsController struct {
+	applications repositories.OAuthApplicationsRepository
+	orgs         repositories.OrganizationsRepository
+	orgMembers   repositories.OrganizationMembersRepository
+	oauth        services.OAuthService
+	audit        services.AuditService
+}
+
+func NewOAuthApplicationsController(
+	applications repositories.OAuthApplicationsRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	oauth services.OAuthService,
+	audit services.AuditService,
+) OAuthApplicationsController {
+	return &oauthApplicationsController{
+		applications: applications,
+		orgs:         orgs,
+		orgMembers:   orgMembers,
+		oauth:        oauth,
+		audit:        audit,
+	}
+}
+
+// applicationsOwner is who the applications being managed belong to. Organization is nil for the user's own.
+type applicationsOwner struct {
+	user         *models.User
+	organization *models.Organization
+	base         string
+}
+
+func (c *oauthApplicationsController) Index(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	data := &pages.OAuthApplicationsData{
+		Organization: owner.organization,
+		Success:      c.takeNotice(w, r, "oauth_application_success"),
+	}
+
+	if owner.organization != nil {
+		data.Applications, err = c.applications.FindAllByOwnerOrg(owner.organization.ID)
+	} else {
+		data.Applications, err = c.applications.FindAllByOwnerUser(owner.user.ID)
+		if err == nil {
+			data.Authorized, err = c.applications.FindAuthorizedByUser(owner.user.ID)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	return pages.OAuthApplications(r, data).Render(w, r)
+}
+
+func (c *oauthApplicationsController) New(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	return pages.OAuthApplicationForm(r, &pages.OAuthApplicationFormData{
+		Organization: owner.organization,
+		Confidential: true,
+	}).Render(w, r)
+}
+
+func (c *oauthApplicationsController) Create(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	data := &pages.OAuthApplicationFormData{
+		Organization: owner.organization,
+		Name:         strings.TrimSpace(r.FormValue("name")),
+		HomepageURL:  strings.TrimSpace(r.FormValue("homepage_url")),
+		RedirectURIs: r.FormValue("redirect_uris"),
+		Confidential: r.FormValue("confidential") == "on",
+	}
+
+	redirectURIs, validationError := validateOAuthApplication(data.Name, data.HomepageURL, data.RedirectURIs)
+	if validationError != "" {
+		data.Error = validationError
+		return pages.OAuthApplicationForm(r, data).Render(w, r)
+	}
+
+	clientID, clientSecret, clientSecretHash, err := c.oauth.NewClientCredentials()
+	if err != nil {
+		return err
+	}
+	if !data.Confidential {
+		clientSecret, clientSecretHash = "", ""
+	}
+
+	var ownerUserID, ownerOrgID *int64
+	if owner.organization != nil {
+		ownerOrgID = &owner.organization.ID
+	} else {
+		ownerUserID = &owner.user.ID
+	}
+
+	application, err := c.applications.Create(ownerUserID, ownerOrgID, data.Name, data.HomepageURL, redirectURIs, clientID, clientSecretHash, data.Confidential)
+	if err != nil {
+		return err
+	}
+	c.audit.Record(r, owner.user, models.AuditOAuthApplicationCreate, services.OAuthApplicationAuditTarget(application), map[string]string{"client_id": clientID})
+
+	if clientSecret != "" {
+		c.setNotice(w, "new_oauth_client_secret", clientSecret)
+	}
+	c.setNotice(w, "oauth_application_success", "Application registered. Use the client ID and secret below in your application.")
+	http.Redirect(w, r, owner.base+"/"+strconv.FormatInt(application.ID, 10), http.StatusSeeOther)
+	return nil
+}
+
+func (c *oauthApplicationsController) Show(w http.ResponseWriter, r *http.Request) error {
+	owner, application, err := c.findApplication(w, r)
+	if err != nil || application == nil {
+		return err
+	}
+
+	return pages.OAuthApplicationForm(r, &pages.OAuthApplicationFormData{
+		Organization:    owner.organization,
+		Application:     application,
+		Name:            application.Name,
+		HomepageURL:     application.HomepageURL,
+		RedirectURIs:    strings.Join(application.RedirectURIs, "\n"),
+		Confidential:    application.Confidential,
+		NewClientSecret: c.takeNotice(w, r, "new_oauth_client_secret"),
+		Success:         c.takeNotice(w, r, "oauth_application_success"),
+	}).Render(w, r)
+}
+
+func (c *oauthApplicationsController) Update(w http.ResponseWriter, r *http.Request) error {
+	owner, application, err := c.findApplication(w, r)
+	if err != nil || application == nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	data := &pages.OAuthApplicationFormData{
+		Organization: owner.organization,
+		Application:  application,
+		Name:         strings.TrimSpace(r.FormValue("name")),
+		HomepageURL:  strings.TrimSpace(r.FormValue("homepage_url")),
+		RedirectURIs: r.FormValue("redirect_uris"),
+		Confidential: application.Confidential,
+	}
+
+	redirectURIs, validationError := validateOAuthApplication(data.Name, data.HomepageURL, data.RedirectURIs)
+	if validationError != "" {
+		data.Error = validationError
+		return pages.OAuthApplicationForm(r, data).Render(w, r)
+	}
+
+	application.Name = data.Name
+	application.HomepageURL = data.HomepageURL
+	application.RedirectURIs = redirectURIs
+	if err := c.applications.Update(application); err != nil {
+		return err
+	}
+	c.audit.Record(r, owner.user, models.AuditOAuthApplicationUpdate, services.OAuthApplicationAuditTarget(application), nil)
+
+	c.setNotice(w, "oauth_application_success", "Application updated successfully")
+	http.Redirect(w, r, owner.base+"/"+strconv.FormatInt(application.ID, 10), http.StatusSeeOther)
+	return nil
+}
+
+// ResetSecret replaces the client secret. The old one stops working right away.
+func (c *oauthApplicationsController) ResetSecret(w http.ResponseWriter, r *http.Request) error {
+	owner, application, err := c.findApplication(w, r)
+	if err != nil || application == nil {
+		return err
+	}
+
+	if !application.Confidential {
+		return httperror.BadRequest("Public applications don't have a client secret")
+	}
+
+	clientSecret, clientSecretHash, err := c.oauth.NewClientSecret()
+	if err != nil {
+		return err
+	}
+	if err := c.applications.UpdateClientSecret(application.ID, clientSecretHash); err != nil {
+		return err
+	}
+	c.audit.Record(r, owner.user, models.AuditOAuthApplicationSecretReset, services.OAuthApplicationAuditTarget(application), nil)
+
+	c.setNotice(w, "new_oauth_client_secret", clientSecret)
+	c.setNotice(w, "oauth_application_success", "Client secret reset. Update your application to use the new one.")
+	http.Redirect(w, r, owner.base+"/"+strconv.FormatInt(application.ID, 10), http.StatusSeeOther)
+	return nil
+}
+
+// Delete deletes the application, which revokes every token issued to it
+func (c *oauthApplicationsController) Delete(w http.ResponseWriter, r *http.Request) error {
+	owner, application, err := c.findApplication(w, r)
+	if err != nil || application == nil {
+		return err
+	}
+
+	if err := c.applications.Delete(application.ID); err != nil {
+		return err
+	}
+	c.audit.Record(r, owner.user, models.AuditOAuthApplicationDelete, services.OAuthApplicationAuditTarget(application), nil)
+
+	c.setNotice(w, "oauth_application_success", "Application deleted successfully")
+	http.Redirect(w, r, owner.base, http.StatusSeeOther)
+	return nil
+}
+
+// RevokeAuthorization revokes the tokens an application holds for the signed in user
+func (c *oauthApplicationsController) RevokeAuthorization(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	applicationID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid application ID")
+	}
+
+	application, err := c.applications.FindByID(applicationID)
+	if err != nil {
+		return err
+	}
+	if application == nil {
+		return httperror.NotFound("Application not found")
+	}
+
+	if err := c.oauth.RevokeAuthorization(application.ID, user.ID); err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditOAuthRevoke, services.OAuthApplicationAuditTarget(application), nil)
+
+	c.setNotice(w, "oauth_application_success", "Access of "+application.Name+" revoked")
+	http.Redirect(w, r, "/settings/applications#authorized", http.StatusSeeOther)
+	return nil
+}
+
+// setNotice stores a message to show once on the next page
+func (c *oauthApplicationsController) setNotice(w http.ResponseWriter, name, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+}
+
+// takeNotice returns and clears a message stored with setNotice
+func (c *oauthApplicationsController) takeNotice(w http.ResponseWriter, r *http.Request, name string) string {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   name,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+	return cookie.Value
+}
+
+// findOwner returns who manages applications on this page: the signed in user on their settings,
+// or an organization its owners manage. It redirects to sign in and returns nil otherwise.
+func (c *oauthApplicationsController) findOwner(w http.ResponseWriter, r *http.Request) (*applicationsOwner, error) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil, nil
+	}
+
+	ownerType, ok := middleware.GetOwnerType(r.Context())
+	if !ok {
+		return &applicationsOwner{user: user, base: "/settings/applications"}, nil
+	}
+	if ownerType != middleware.OwnerTypeOrg {
+		return nil, httperror.NotFound("organization not found")
+	}
+
+	ownerID, _ := middleware.GetOwnerID(r.Context())
+	org, err := c.orgs.FindByID(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, httperror.NotFound("organization not found")
+	}
+
+	membership, err := c.orgMembers.FindByOrganizationAndUser(org.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil || membership.Role != models.OrganizationRoleOwner {
+		return nil, httperror.Forbidden("access denied")
+	}
+
+	return &applicationsOwner{user: user, organization: org, base: "/" + org.Username + "/settings/applications"}, nil
+}
+
+// findApplication returns the application in the URL, if it belongs to the owner of the page
+func (c *oauthApplicationsController) findApplication(w http.ResponseWriter, r *http.Request) (*applicationsOwner, *models.OAuthApplication, error) {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return nil, nil, err
+	}
+
+	applicationID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, nil, httperror.New(http.StatusBadRequest, "Invalid application ID")
+	}
+
+	application, err := c.applications.FindByID(applicationID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var owned bool
+	if application != nil && owner.organization != nil {
+		owned = application.OwnerOrgID != nil && *application.OwnerOrgID == owner.organization.ID
+	} else if application != nil {
+		owned = application.OwnerUserID != nil && *application.OwnerUserID == owner.user.ID
+	}
+	if !owned {
+		return nil, nil, httperror.NotFound("Application not found")
+	}
+
+	return owner, application, nil
+}
+
+// validateOAuthApplication checks the form of an application and returns its redirect URIs, one per line.
+// The second result is a validation message for the user.
+func validateOAuthApplication(name, homepageURL, redirectURIs string) ([]string, string) {
+	if name == "" {
+		return nil, "Application name is required"
+	}
+	if len(name) > 100 {
+		return nil, "Application name must be at most 100 characters"
+	}
+
+	if homepageURL != "" {
+		u, err := url.Parse(homepageURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, "Homepage URL must be an http or https URL"
+		}
+	}
+
+	var uris []string
+	for _, line := range strings.Split(redirectURIs, "\n") {
+		uri := strings.TrimSpace(line)
+		if uri == "" {
+			continue
+		}
+		if message := validateRedirectURI(uri); message != "" {
+			return nil, message
+		}
+		if !slices.Contains(uris, uri) {
+			uris = append(uris, uri)
+		}
+	}
+	if len(uris) == 0 {
+		return nil, "Add at least one redirect URI"
+	}
+
+	return uris, ""
+}
+
+// validateRedirectURI accepts https URLs, http URLs of the loopback interface for native apps
+// and private-use schemes like com.example.app:/callback, RFC 8252
+func validateRedirectURI(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme == "" {
+		return "Redirect URI " + uri + " isn't a valid URL"
+	}
+	if u.Fragment != "" {
+		return "Redirect URI " + uri + " can't contain a fragment"
+	}
+
+	switch u.Scheme {
+	case "https":
+		if u.Host == "" {
+			return "Redirect URI " + uri + " has no host"
+		}
+	case "http":
+		host := u.Hostname()
+		if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+			return "Redirect URI " + uri + " must use https, http is only allowed for localhost"
+		}
+	default:
+		if !strings.Contains(u.Scheme, ".") {
+			return "Redirect URI " + uri + " must use https or a reverse domain name scheme like com.example.app"
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// oauthDeviceCodeGrantType is the grant type of RFC 8628
+const oauthDeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+type OAuthController interface {
+	// Authorize shows the consent screen of the authorization code grant
+	Authorize(w http.ResponseWriter, r *http.Request) error
+	// Decide handles the user's answer on the consent screen
+	Decide(w http.ResponseWriter, r *http.Request) error
+	Token(w http.ResponseWriter, r *http.Request) error
+	Revoke(w http.ResponseWriter, r *http.Request) error
+	Introspect(w http.ResponseWriter, r *http.Request) error
+}
+
+type oauthController struct {
+	oauth services.OAuthService
+	users repositories.UsersRepository
+	orgs  repositories.OrganizationsRepository
+	audit services.AuditService
+}
+
+func NewOAuthController(
+	oauth services.OAuthService,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	audit services.AuditService,
+) OAuthController {
+	return &oauthController{
+		oauth: oauth,
+		users: users,
+		orgs:  orgs,
+		audit: audit,
+	}
+}
+
+// oauthAuthorizeRequest is a validated request of the authorization endpoint
+type oauthAuthorizeRequest struct {
+	application   *models.OAuthApplication
+	redirectURI   string
+	scopes        []string
+	state         string
+	codeChallenge string
+}
+
+// parseAuthorizeRequest validates the parameters of the authorization endpoint. Until the client and
+// redirect URI are known to be valid, errors are shown to the user. Later ones are sent to the client,
+// in which case the request is returned along with the error.
+func (c *oauthController) parseAuthorizeRequest(params url.Values) (*oauthAuthorizeRequest, error) {
+	clientID := params.Get("client_id")
+	if clientID == "" || clientID == services.OAuthCLIClientID {
+		return nil, httperror.BadRequest("The application didn't identify itself correctly.")
+	}
+
+	application, err := c.oauth.FindClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if application == nil {
+		return nil, httperror.NotFound("The application doesn't exist, it may have been deleted.")
+	}
+
+	// The redirect URI can only be left out if just one is registered
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(application.RedirectURIs) == 1 {
+		redirectURI = application.RedirectURIs[0]
+	}
+	if !slices.Contains(application.RedirectURIs, redirectURI) {
+		return nil, httperror.BadRequest("The application asked to send you to an address it didn't register.")
+	}
+
+	request := &oauthAuthorizeRequest{
+		application: application,
+		redirectURI: redirectURI,
+		state:       params.Get("state"),
+	}
+
+	if params.Get("response_type") != "code" {
+		return request, &services.OAuthError{Code: "unsupported_response_type", Description: "only the code response type is supported"}
+	}
+
+	request.scopes, err = c.oauth.ParseScopes(params.Get("scope"))
+	if err != nil {
+		return request, err
+	}
+
+	// PKCE is required of every client, only with S256
+	request.codeChallenge = params.Get("code_challenge")
+	if request.codeChallenge == "" {
+		return request, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "code_challenge is required"}
+	}
+	if params.Get("code_challenge_method") != "S256" {
+		return request, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "code_challenge_method must be S256"}
+	}
+
+	return request, nil
+}
+
+func (c *oauthController) Authorize(w http.ResponseWriter, r *http.Request) error {
+	request, err := c.parseAuthorizeRequest(r.URL.Query())
+	if err != nil {
+		return c.authorizeError(w, r, request, err)
+	}
+
+	owner, err := c.applicationOwner(request.application)
+	if err != nil {
+		return err
+	}
+
+	return pages.OAuthAuthorize(r, &pages.OAuthAuthorizeData{
+		User:        middleware.GetUserFromContext(r),
+		Application: request.application,
+		Owner:       owner,
+		Scopes:      request.scopes,
+		RedirectURI: request.redirectURI,
+		Params:      r.URL.Query(),
+	}).Render(w, r)
+}
+
+func (c *oauthController) Decide(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	request, err := c.parseAuthorizeRequest(r.PostForm)
+	if err != nil {
+		return c.authorizeError(w, r, request, err)
+	}
+
+	if r.PostFormValue("decision") != "approve" {
+		return c.authorizeError(w, r, request, &services.OAuthError{Code: services.OAuthErrorAccessDenied, Description: "the user denied the request"})
+	}
+
+	code, err := c.oauth.CreateAuthorizationCode(request.application, user, request.redirectURI, request.scopes, request.codeChallenge)
+	if err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditOAuthAuthorize, services.OAuthApplicationAuditTarget(request.application), map[string]string{"scopes": strings.Join(request.scopes, " ")})
+
+	http.Redirect(w, r, oauthRedirectURL(request.redirectURI, url.Values{"code": {code}}, request.state), http.StatusSeeOther)
+	return nil
+}
+
+// authorizeError sends OAuth errors back to the client, other errors are shown to the user
+func (c *oauthController) authorizeError(w http.ResponseWriter, r *http.Request, request *oauthAuthorizeRequest, err error) error {
+	var oauthErr *services.OAuthError
+	if request == nil || !errors.As(err, &oauthErr) {
+		return err
+	}
+
+	params := url.Values{"error": {oauthErr.Code}}
+	if oauthErr.Description != "" {
+		params.Set("error_description", oauthErr.Description)
+	}
+	http.Redirect(w, r, oauthRedirectURL(request.redirectURI, params, request.state), http.StatusSeeOther)
+	return nil
+}
+
+func (c *oauthController) Token(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return writeOAuthError(w, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "invalid form data"})
+	}
+
+	application, err := c.oauth.AuthenticateClient(r)
+	if err != nil {
+		return writeOAuthError(w, err)
+	}
+
+	var response *services.OAuthTokenResponse
+	switch grantType := r.PostFormValue("grant_type"); grantType {
+	case "authorization_code", "refresh_token":
+		if c.oauth.IsCLI(application) {
+			return writeOAuthError(w, &services.OAuthError{Code: services.OAuthErrorUnauthorizedClient, Description: "the CLI can only use the device code grant"})
+		}
+		if grantType == "authorization_code" {
+			response, err = c.oauth.ExchangeAuthorizationCode(application, r.PostFormValue("code"), r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier"))
+		} else {
+			response, err = c.oauth.Refresh(application, r.PostFormValue("refresh_token"), r.PostFormValue("scope"))
+		}
+	case oauthDeviceCodeGrantType:
+		response, err = c.oauth.ExchangeDeviceCode(application, r.PostFormValue("device_code"))
+	case "":
+		err = &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "grant_type is required"}
+	default:
+		err = &services.OAuthError{Code: services.OAuthErrorUnsupportedGrantType}
+	}
+	if err != nil {
+		return writeOAuthError(w, err)
+	}
+
+	return writeOAuthJSON(w, http.StatusOK, response)
+}
+
+func (c *oauthController) Revoke(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return writeOAuthError(w, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "invalid form data"})
+	}
+
+	application, err := c.oauth.AuthenticateClient(r)
+	if err != nil {
+		return writeOAuthError(w, err)
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		return writeOAuthError(w, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "token is required"})
+	}
+
+	// Unknown tokens are no error, RFC 7009 section 2.2
+	if err := c.oauth.Revoke(application, token); err != nil {
+		return err
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (c *oauthController) Introspect(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return writeOAuthError(w, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "invalid form data"})
+	}
+
+	application, err := c.oauth.AuthenticateClient(r)
+	if err != nil {
+		return writeOAuthError(w, err)
+	}
+
+	token := r.PostFormValue("token")
+	if token == "" {
+		return writeOAuthError(w, &services.OAuthError{Code: services.OAuthErrorInvalidRequest, Description: "token is required"})
+	}
+
+	introspection, err := c.oauth.Introspect(application, token)
+	if err != nil {
+		return err
+	}
+
+	return writeOAuthJSON(w, http.StatusOK, introspection)
+}
+
+// applicationOwner returns the username of the user or organization that registered the application
+func (c *oauthController) applicationOwner(application *models.OAuthApplication) (string, error) {
+	if application.OwnerOrgID != nil {
+		org, err := c.orgs.FindByID(*application.OwnerOrgID)
+		if err != nil || org == nil {
+			return "", err
+		}
+		return org.Username, nil
+	}
+
+	owner, err := c.users.FindByID(*application.OwnerUserID)
+	if err != nil || owner == nil {
+		return "", err
+	}
+	return owner.Username, nil
+}
+
+// oauthRedirectURL adds params and the state of the client to its redirect URI
+func oauthRedirectURL(redirectURI string, params url.Values, state string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if state != "" {
+		query.Set("state", state)
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+func writeOAuthJSON(w http.ResponseWriter, status int, body any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(body)
+}
+
+// writeOAuthError responds with an OAuth error, RFC 6749 section 5.2. Other errors are returned.
+func writeOAuthError(w http.ResponseWriter, err error) error {
+	var oauthErr *services.OAuthError
+	if !errors.As(err, &oauthErr) {
+		return err
+	}
+
+	status := http.StatusBadRequest
+	if oauthErr.Code == services.OAuthErrorInvalidClient {
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="Hypercommit"`)
+	}
+
+	return writeOAuthJSON(w, status, oauthErr)
+}
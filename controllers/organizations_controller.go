@@ -1,10 +1,16 @@
 package controllers
 
 import (
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/hypercommithq/hypercommit/database/models"
 	"github.com/hypercommithq/hypercommit/database/repositories"
 	"github.com/hypercommithq/hypercommit/httperror"
@@ -13,6 +19,9 @@ import (
 	"github.com/hypercommithq/hypercommit/views/pages"
 )
 
+// auditLogPageSize is how many events the audit log page shows, exports include all of them
+const auditLogPageSize = 200
+
 type OrganizationsController interface {
 	New(w http.ResponseWriter, r *http.Request) error
 	Create(w http.ResponseWriter, r *http.Request) error
@@ -22,24 +31,48 @@ type OrganizationsController interface {
 	Stars(w http.ResponseWriter, r *http.Request) error
 	Settings(w http.ResponseWriter, r *http.Request) error
 	Update(w http.ResponseWriter, r *http.Request) error
+	AddMember(w http.ResponseWriter, r *http.Request) error
+	RemoveMember(w http.ResponseWriter, r *http.Request) error
+	AuditLog(w http.ResponseWriter, r *http.Request) error
 	Delete(w http.ResponseWriter, r *http.Request) error
 }
 
 type organizationsController struct {
-	orgs        repositories.OrganizationsRepository
-	users       repositories.UsersRepository
-	repos       repositories.RepositoriesRepository
-	stars       repositories.StarsRepository
-	authService services.AuthService
+	orgs         repositories.OrganizationsRepository
+	orgMembers   repositories.OrganizationMembersRepository
+	users        repositories.UsersRepository
+	repos        repositories.RepositoriesRepository
+	contributors repositories.ContributorsRepository
+	stars        repositories.StarsRepository
+	auditEvents  repositories.AuditEventsRepository
+	authService  services.AuthService
+	twoFactor    services.TwoFactorService
+	audit        services.AuditService
 }
 
-func NewOrganizationsController(orgs repositories.OrganizationsRepository, users repositories.UsersRepository, repos repositories.RepositoriesRepository, stars repositories.StarsRepository, authService services.AuthService) OrganizationsController {
+func NewOrganizationsController(
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	users repositories.UsersRepository,
+	repos repositories.RepositoriesRepository,
+	contributors repositories.ContributorsRepository,
+	stars repositories.StarsRepository,
+	auditEvents repositories.AuditEventsRepository,
+	authService services.AuthService,
+	twoFactor services.TwoFactorService,
+	audit services.AuditService,
+) OrganizationsController {
 	return &organizationsController{
-		orgs:        orgs,
-		users:       users,
-		repos:       repos,
-		stars:       stars,
-		authService: authService,
+		orgs:         orgs,
+		orgMembers:   orgMembers,
+		users:        users,
+		repos:        repos,
+		contributors: contributors,
+		stars:        stars,
+		auditEvents:  auditEvents,
+		authService:  authService,
+		twoFactor:    twoFactor,
+		audit:        audit,
 	}
 }
 
@@ -118,7 +151,12 @@ func (c *organizationsController) Store(w http.ResponseWriter, r *http.Request)
 		return pages.NewOrganization(r, orgData).Render(w, r)
 	}
 
+	if _, err := c.orgMembers.Create(org.ID, user.ID, models.OrganizationRoleOwner); err != nil {
+		return err
+	}
+
 	slog.Info("organization created", "username", username, "displayName", displayName, "creator", user.Username)
+	c.audit.Record(r, user, models.AuditOrganizationCreate, services.OrganizationAuditTarget(org), nil)
 
 	http.Redirect(w, r, fmt.Sprintf("/%s", org.Username), http.StatusSeeOther)
 	return nil
@@ -191,13 +229,10 @@ func (c *organizationsController) Show(w http.ResponseWriter, r *http.Request) e
 			starCounts[repo.ID] = starCount
 		}
 
-		// Check if current user can manage this organization
-		// TODO: Implement proper organization membership/ownership check
-		canManage := false
-		if currentUser != nil {
-			// For now, we'll need to implement organization membership
-			// This is a placeholder - proper implementation needed
-			canManage = false
+		// Owners can manage the organization
+		canManage, err := c.isOwner(org.ID, currentUser)
+		if err != nil {
+			return err
 		}
 
 		return pages.OrganizationProfile(r, &pages.OrganizationProfileData{
@@ -280,11 +315,10 @@ func (c *organizationsController) Repositories(w http.ResponseWriter, r *http.Re
 			starCounts[repo.ID] = starCount
 		}
 
-		// Check if current user can manage this organization
-		canManage := false
-		if currentUser != nil {
-			// TODO: Implement proper organization membership/ownership check
-			canManage = false
+		// Owners can manage the organization
+		canManage, err := c.isOwner(org.ID, currentUser)
+		if err != nil {
+			return err
 		}
 
 		return pages.OrganizationProfile(r, &pages.OrganizationProfileData{
@@ -372,13 +406,485 @@ func (c *organizationsController) Stars(w http.ResponseWriter, r *http.Request)
 }
 
 func (c *organizationsController) Settings(w http.ResponseWriter, r *http.Request) error {
-	return nil
+	user, org, err := c.findManagedOrganization(w, r)
+	if err != nil || org == nil {
+		return err
+	}
+
+	memberships, err := c.orgMembers.FindAllByOrganization(org.ID)
+	if err != nil {
+		return err
+	}
+
+	members := make([]pages.OrganizationMemberData, 0, len(memberships))
+	for _, membership := range memberships {
+		memberUser, err := c.users.FindByID(membership.UserID)
+		if err != nil {
+			return err
+		}
+		if memberUser == nil {
+			continue
+		}
+
+		twoFactorEnabled, err := c.twoFactor.IsEnabled(memberUser.ID)
+		if err != nil {
+			return err
+		}
+
+		members = append(members, pages.OrganizationMemberData{
+			User:             memberUser,
+			Role:             membership.Role,
+			TwoFactorEnabled: twoFactorEnabled,
+		})
+	}
+
+	userTwoFactorEnabled, err := c.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return err
+	}
+
+	data := &pages.OrganizationSettingsData{
+		Organization:         org,
+		Members:              members,
+		UserTwoFactorEnabled: userTwoFactorEnabled,
+	}
+
+	if cookie, err := r.Cookie("org_settings_success"); err == nil {
+		data.Success = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "org_settings_success",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	if cookie, err := r.Cookie("org_settings_error"); err == nil {
+		data.Error = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "org_settings_error",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	return pages.OrganizationSettings(r, data).Render(w, r)
 }
 
 func (c *organizationsController) Update(w http.ResponseWriter, r *http.Request) error {
-	return nil
+	user, org, err := c.findManagedOrganization(w, r)
+	if err != nil || org == nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form data")
+	}
+
+	requireTwoFactor := r.FormValue("require_two_factor") == "1"
+	if requireTwoFactor == org.RequireTwoFactor {
+		return c.redirectWithSuccess(w, r, org, "Settings saved")
+	}
+
+	if !requireTwoFactor {
+		org.RequireTwoFactor = false
+		if err := c.orgs.Update(org); err != nil {
+			return err
+		}
+		c.audit.Record(r, user, models.AuditOrganizationUpdate, services.OrganizationAuditTarget(org), map[string]string{
+			"require_two_factor": "false",
+		})
+		return c.redirectWithSuccess(w, r, org, "Two-factor authentication is no longer required")
+	}
+
+	userTwoFactorEnabled, err := c.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return err
+	}
+	if !userTwoFactorEnabled {
+		return c.redirectWithError(w, r, org, "Enable two-factor authentication for your account before requiring it from others")
+	}
+
+	org.RequireTwoFactor = true
+	if err := c.orgs.Update(org); err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditOrganizationUpdate, services.OrganizationAuditTarget(org), map[string]string{
+		"require_two_factor": "true",
+	})
+
+	removed, err := c.removeUsersWithoutTwoFactor(r, user, org)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("organization requires two-factor authentication", "org", org.Username, "removed", removed, "by", user.Username)
+
+	message := "Two-factor authentication is now required"
+	if removed > 0 {
+		message += fmt.Sprintf(". Removed %d users without it from the organization and its repositories.", removed)
+	}
+	return c.redirectWithSuccess(w, r, org, message)
+}
+
+func (c *organizationsController) AddMember(w http.ResponseWriter, r *http.Request) error {
+	user, org, err := c.findManagedOrganization(w, r)
+	if err != nil || org == nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form data")
+	}
+
+	role := r.FormValue("role")
+	if role != models.OrganizationRoleOwner && role != models.OrganizationRoleMember {
+		return c.redirectWithError(w, r, org, "Invalid role")
+	}
+
+	member, err := c.users.FindByUsername(strings.TrimSpace(r.FormValue("username")))
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return c.redirectWithError(w, r, org, "User not found")
+	}
+
+	existing, err := c.orgMembers.FindByOrganizationAndUser(org.ID, member.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return c.redirectWithError(w, r, org, member.Username+" is already a member")
+	}
+
+	missing, err := missingRequiredTwoFactor(org, member.ID, c.twoFactor)
+	if err != nil {
+		return err
+	}
+	if missing {
+		return c.redirectWithError(w, r, org, member.Username+" has to enable two-factor authentication before joining")
+	}
+
+	if _, err := c.orgMembers.Create(org.ID, member.ID, role); err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditMemberAdd, services.OrganizationAuditTarget(org), map[string]string{
+		"user": member.Username,
+		"role": role,
+	})
+
+	return c.redirectWithSuccess(w, r, org, member.Username+" added")
+}
+
+func (c *organizationsController) RemoveMember(w http.ResponseWriter, r *http.Request) error {
+	user, org, err := c.findManagedOrganization(w, r)
+	if err != nil || org == nil {
+		return err
+	}
+
+	userID, err := strconv.ParseInt(chi.URLParam(r, "userID"), 10, 64)
+	if err != nil {
+		return httperror.BadRequest("invalid user ID")
+	}
+
+	membership, err := c.orgMembers.FindByOrganizationAndUser(org.ID, userID)
+	if err != nil {
+		return err
+	}
+	if membership == nil {
+		return httperror.NotFound("member not found")
+	}
+
+	if membership.Role == models.OrganizationRoleOwner {
+		owners, err := c.orgMembers.CountOwners(org.ID)
+		if err != nil {
+			return err
+		}
+		if owners <= 1 {
+			return c.redirectWithError(w, r, org, "An organization needs at least one owner")
+		}
+	}
+
+	if err := c.orgMembers.Delete(org.ID, userID); err != nil {
+		return err
+	}
+	c.auditMemberRemoval(r, user, services.OrganizationAuditTarget(org), models.AuditMemberRemove, userID, "")
+
+	return c.redirectWithSuccess(w, r, org, "Member removed")
+}
+
+// AuditLog shows the organization's audit log, filtered by action and actor. With format=csv or
+// format=json it downloads every matching event instead.
+func (c *organizationsController) AuditLog(w http.ResponseWriter, r *http.Request) error {
+	_, org, err := c.findManagedOrganization(w, r)
+	if err != nil || org == nil {
+		return err
+	}
+
+	query := r.URL.Query()
+	filter := repositories.AuditEventFilter{
+		Action: query.Get("action"),
+		Actor:  strings.TrimPrefix(strings.TrimSpace(query.Get("actor")), "@"),
+	}
+
+	format := query.Get("format")
+	if format != "csv" && format != "json" {
+		filter.Limit = auditLogPageSize
+	}
+
+	events, err := c.auditEvents.FindAllByOrganization(org.ID, filter)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "csv":
+		setAttachment(w, org.Username+"-audit-log.csv", "text/csv")
+		return writeAuditEventsCSV(w, events)
+	case "json":
+		setAttachment(w, org.Username+"-audit-log.json", "application/json")
+		return writeAuditEventsJSON(w, events)
+	}
+
+	return pages.OrganizationAuditLog(r, &pages.OrganizationAuditLogData{
+		Organization: org,
+		Events:       events,
+		Action:       filter.Action,
+		Actor:        filter.Actor,
+		Truncated:    len(events) == auditLogPageSize,
+	}).Render(w, r)
 }
 
 func (c *organizationsController) Delete(w http.ResponseWriter, r *http.Request) error {
 	return nil
 }
+
+// findManagedOrganization returns the organization of the URL if the signed in user owns it.
+// It returns a nil organization if it already responded, e.g. with a redirect to sign in.
+func (c *organizationsController) findManagedOrganization(w http.ResponseWriter, r *http.Request) (*models.User, *models.Organization, error) {
+	ownerType, _ := custommiddleware.GetOwnerType(r.Context())
+	if ownerType != custommiddleware.OwnerTypeOrg {
+		return nil, nil, httperror.NotFound("organization not found")
+	}
+
+	ownerID, _ := custommiddleware.GetOwnerID(r.Context())
+
+	user := custommiddleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil, nil, nil
+	}
+
+	org, err := c.orgs.FindByID(ownerID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if org == nil {
+		return nil, nil, httperror.NotFound("organization not found")
+	}
+
+	owner, err := c.isOwner(org.ID, user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !owner {
+		return nil, nil, httperror.Forbidden("access denied")
+	}
+
+	return user, org, nil
+}
+
+func (c *organizationsController) isOwner(orgID int64, user *models.User) (bool, error) {
+	if user == nil {
+		return false, nil
+	}
+
+	membership, err := c.orgMembers.FindByOrganizationAndUser(orgID, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return membership != nil && membership.Role == models.OrganizationRoleOwner, nil
+}
+
+// removeUsersWithoutTwoFactor removes members, and collaborators of the organization's repositories,
+// that don't use two-factor authentication. It returns how many users were removed.
+func (c *organizationsController) removeUsersWithoutTwoFactor(r *http.Request, actor *models.User, org *models.Organization) (int, error) {
+	removed := map[int64]bool{}
+
+	memberships, err := c.orgMembers.FindAllByOrganization(org.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, membership := range memberships {
+		enabled, err := c.twoFactor.IsEnabled(membership.UserID)
+		if err != nil {
+			return 0, err
+		}
+		if enabled {
+			continue
+		}
+		if err := c.orgMembers.Delete(org.ID, membership.UserID); err != nil {
+			return 0, err
+		}
+		c.auditMemberRemoval(r, actor, services.OrganizationAuditTarget(org), models.AuditMemberRemove, membership.UserID, "two_factor_required")
+		removed[membership.UserID] = true
+	}
+
+	orgRepos, err := c.repos.FindAllByOrg(org.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, repo := range orgRepos {
+		contributors, err := c.contributors.FindAllByRepository(repo.ID)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, contributor := range contributors {
+			enabled, err := c.twoFactor.IsEnabled(contributor.UserID)
+			if err != nil {
+				return 0, err
+			}
+			if enabled {
+				continue
+			}
+			if err := c.contributors.Delete(contributor.ID); err != nil {
+				return 0, err
+			}
+			c.auditMemberRemoval(r, actor, services.RepositoryAuditTarget(org.Username, repo), models.AuditCollaboratorRemove, contributor.UserID, "two_factor_required")
+			removed[contributor.UserID] = true
+		}
+	}
+
+	return len(removed), nil
+}
+
+// auditMemberRemoval records that a user was removed from the target, with the reason if it wasn't done by hand
+func (c *organizationsController) auditMemberRemoval(r *http.Request, actor *models.User, target services.AuditTarget, action string, userID int64, reason string) {
+	metadata := map[string]string{"user_id": strconv.FormatInt(userID, 10)}
+	if removedUser, err := c.users.FindByID(userID); err == nil && removedUser != nil {
+		metadata["user"] = removedUser.Username
+	}
+	if reason != "" {
+		metadata["reason"] = reason
+	}
+
+	c.audit.Record(r, actor, action, target, metadata)
+}
+
+func (c *organizationsController) redirectWithSuccess(w http.ResponseWriter, r *http.Request, org *models.Organization, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "org_settings_success",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/"+org.Username+"/settings", http.StatusSeeOther)
+	return nil
+}
+
+func (c *organizationsController) redirectWithError(w http.ResponseWriter, r *http.Request, org *models.Organization, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "org_settings_error",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/"+org.Username+"/settings", http.StatusSeeOther)
+	return nil
+}
+
+// missingRequiredTwoFactor reports whether the organization requires two-factor authentication
+// and the user doesn't use it
+func missingRequiredTwoFactor(org *models.Organization, userID int64, twoFactor services.TwoFactorService) (bool, error) {
+	if org == nil || !org.RequireTwoFactor {
+		return false, nil
+	}
+
+	enabled, err := twoFactor.IsEnabled(userID)
+	if err != nil {
+		return false, err
+	}
+
+	return !enabled, nil
+}
+
+func setAttachment(w http.ResponseWriter, filename, contentType string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
+func writeAuditEventsCSV(w http.ResponseWriter, events []*models.AuditEvent) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"time", "actor", "action", "target_type", "target", "ip_address", "metadata"}); err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		metadata, err := json.Marshal(event.Metadata)
+		if err != nil {
+			return err
+		}
+
+		err = writer.Write([]string{
+			time.Unix(event.CreatedAt, 0).UTC().Format(time.RFC3339),
+			csvCell(event.ActorName),
+			csvCell(event.Action),
+			csvCell(event.TargetType),
+			csvCell(event.TargetName),
+			csvCell(event.IPAddress),
+			csvCell(string(metadata)),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// csvCell keeps spreadsheets from running a value as a formula, by prefixing values starting with
+// a character that starts one with a quote
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func writeAuditEventsJSON(w http.ResponseWriter, events []*models.AuditEvent) error {
+	type auditEventJSON struct {
+		Time       string            `json:"time"`
+		Actor      string            `json:"actor"`
+		Action     string            `json:"action"`
+		TargetType string            `json:"target_type"`
+		Target     string            `json:"target"`
+		IPAddress  string            `json:"ip_address"`
+		Metadata   map[string]string `json:"metadata"`
+	}
+
+	result := make([]auditEventJSON, 0, len(events))
+	for _, event := range events {
+		result = append(result, auditEventJSON{
+			Time:       time.Unix(event.CreatedAt, 0).UTC().Format(time.RFC3339),
+			Actor:      event.ActorName,
+			Action:     event.Action,
+			TargetType: event.TargetType,
+			Target:     event.TargetName,
+			IPAddress:  event.IPAddress,
+			Metadata:   event.Metadata,
+		})
+	}
+
+	return json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+func TestAuditEventsCSVEscapesFormulas(t *testing.T) {
+	w := httptest.NewRecorder()
+	err := writeAuditEventsCSV(w, []*models.AuditEvent{{
+		ActorName:  "=HYPERLINK(\"https://evil.test\")",
+		Action:     "repository.create",
+		TargetType: "repository",
+		TargetName: "@SUM(1+1)",
+		IPAddress:  "192.0.2.1",
+		Metadata:   map[string]string{"name": "-2+3"},
+		CreatedAt:  1_700_000_000,
+	}, {
+		ActorName:  "+alice",
+		Action:     "member.add",
+		TargetType: "user",
+		TargetName: "bob",
+		CreatedAt:  1_700_000_000,
+	}})
+	if err != nil {
+		t.Fatalf("write CSV: %v", err)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("read CSV: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want a header and 2 events", len(records))
+	}
+
+	want := [][]string{
+		{"2023-11-14T22:13:20Z", "'=HYPERLINK(\"https://evil.test\")", "repository.create", "repository", "'@SUM(1+1)", "192.0.2.1", `{"name":"-2+3"}`},
+		{"2023-11-14T22:13:20Z", "'+alice", "member.add", "user", "bob", "", "null"},
+	}
+	for i, record := range records[1:] {
+		for j := range record {
+			if record[j] != want[i][j] {
+				t.Errorf("event %d, column %s = %q, want %q", i+1, records[0][j], record[j], want[i][j])
+			}
+		}
+	}
+}
@@ -0,0 +1,665 @@
+package controllers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// PackageRegistryController serves the packages of users and organizations under
+// /api/packages/{owner}, with the npm registry API for npm packages and a plain upload and download
+// API for raw packages. Clients authenticate with an access token, as a bearer token such as the
+// _authToken of .npmrc, or as the password of basic authentication. Publishing needs the repo:write
+// scope, and creates the package on first publish.
+type PackageRegistryController interface {
+	// NPM answers the npm registry API: package documents, tarballs, publish, unpublish, dist-tags
+	// and whoami
+	NPM(w http.ResponseWriter, r *http.Request) error
+	// Raw answers GET, PUT and DELETE of raw/{name}/{version}/{file}, and lists the versions of a raw
+	// package on raw/{name}
+	Raw(w http.ResponseWriter, r *http.Request) error
+}
+
+type packageRegistryController struct {
+	*packageAccess
+	packageService services.PackageService
+	publicURL      string
+}
+
+func NewPackageRegistryController(
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	packages repositories.PackagesRepository,
+	accessTokens services.AccessTokenService,
+	twoFactor services.TwoFactorService,
+	packageService services.PackageService,
+	publicURL string,
+) PackageRegistryController {
+	return &packageRegistryController{
+		packageAccess: &packageAccess{
+			apiAccess: &apiAccess{
+				repos:        repos,
+				users:        users,
+				orgs:         orgs,
+				orgMembers:   orgMembers,
+				contributors: contributors,
+				accessTokens: accessTokens,
+				twoFactor:    twoFactor,
+			},
+			packages: packages,
+		},
+		packageService: packageService,
+		publicURL:      publicURL,
+	}
+}
+
+// packageAccess decides what users may do with packages. Packages linked to a repository have the
+// permissions of the repository. Unlinked packages are administered by their user, or by the owners
+// of their organization, whose members may publish them, and nobody else sees them.
+type packageAccess struct {
+	*apiAccess
+	packages repositories.PackagesRepository
+}
+
+// packageOwner returns the user or the organization of the {owner} URL parameter
+func packageOwner(r *http.Request) (ownerUserID, ownerOrgID *int64) {
+	ownerType, _ := middleware.GetOwnerType(r.Context())
+	ownerID, _ := middleware.GetOwnerID(r.Context())
+	if ownerType == middleware.OwnerTypeOrg {
+		return nil, &ownerID
+	}
+	return &ownerID, nil
+}
+
+// findPackage returns the package of the {owner} URL parameter, nil if there is none
+func (a *packageAccess) findPackage(r *http.Request, packageType, name string) (*models.Package, error) {
+	ownerUserID, ownerOrgID := packageOwner(r)
+	if ownerOrgID != nil {
+		return a.packages.FindByOrgAndName(*ownerOrgID, packageType, name)
+	}
+	return a.packages.FindByUserAndName(*ownerUserID, packageType, name)
+}
+
+// ownerPermission returns what the user may do with the unlinked packages of an owner
+func (a *packageAccess) ownerPermission(ownerUserID, ownerOrgID *int64, user *models.User) (apiPermission, error) {
+	if user == nil {
+		return apiPermissionNone, nil
+	}
+	if ownerUserID != nil {
+		if *ownerUserID == user.ID {
+			return apiPermissionAdmin, nil
+		}
+		return apiPermissionNone, nil
+	}
+
+	membership, err := a.orgMembers.FindByOrganizationAndUser(*ownerOrgID, user.ID)
+	if err != nil || membership == nil {
+		return apiPermissionNone, err
+	}
+	org, err := a.orgs.FindByID(*ownerOrgID)
+	if err != nil {
+		return apiPermissionNone, err
+	}
+	missing, err := missingRequiredTwoFactor(org, user.ID, a.twoFactor)
+	if err != nil || missing {
+		return apiPermissionNone, err
+	}
+	if membership.Role == models.OrganizationRoleOwner {
+		return apiPermissionAdmin, nil
+	}
+	return apiPermissionWrite, nil
+}
+
+// packagePermission returns what the user may do with the package with the access token of the
+// request, nil for sessions. Tokens limited to repositories only get the public access to packages
+// of other repositories, and none to unlinked packages.
+func (a *packageAccess) packagePermission(pkg *models.Package, user *models.User, token *models.AccessToken) (apiPermission, error) {
+	if pkg.RepositoryID != nil {
+		repo, err := a.repos.FindByID(*pkg.RepositoryID)
+		if err != nil {
+			return apiPermissionNone, err
+		}
+		if repo != nil {
+			if token != nil && !a.accessTokens.AllowsRepository(token, repo.ID) {
+				user = nil
+			}
+			return a.permission(repo, user)
+		}
+	}
+
+	if token != nil && len(token.RepositoryIDs) > 0 {
+		return apiPermissionNone, nil
+	}
+	return a.ownerPermission(pkg.OwnerUserID, pkg.OwnerOrgID, user)
+}
+
+// packageCredentials are the user and access token of a request to the package registry
+type packageCredentials struct {
+	user  *models.User
+	token *models.AccessToken
+}
+
+type npmWhoamiJSON struct {
+	Username string `json:"username"`
+}
+
+type npmOKJSON struct {
+	OK bool `json:"ok"`
+}
+
+// npmUnpublishJSON is the package document npm unpublish sends back without the unpublished version
+type npmUnpublishJSON struct {
+	Versions map[string]json.RawMessage `json:"versions"`
+}
+
+type rawVersionJSON struct {
+	Version   string        `json:"version"`
+	CreatedAt string        `json:"created_at"`
+	Files     []rawFileJSON `json:"files"`
+}
+
+type rawFileJSON struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+	DownloadURL string `json:"download_url"`
+}
+
+func (c *packageRegistryController) NPM(w http.ResponseWriter, r *http.Request) error {
+	if err := c.serveNPM(w, r); err != nil {
+		return c.writeNPMError(w, err)
+	}
+	return nil
+}
+
+func (c *packageRegistryController) serveNPM(w http.ResponseWriter, r *http.Request) error {
+	// Scoped names come escaped as @scope%2fname
+	path, err := url.PathUnescape(chi.URLParam(r, "*"))
+	if err != nil {
+		return httperror.NotFound("not found")
+	}
+
+	switch {
+	case path == "-/ping":
+		return writeAPIJSON(w, r, http.StatusOK, struct{}{})
+	case path == "-/whoami":
+		creds, err := c.authenticate(w, r)
+		if err != nil {
+			return err
+		}
+		if creds.user == nil {
+			return c.unauthorized(w)
+		}
+		return writeAPIJSON(w, r, http.StatusOK, npmWhoamiJSON{Username: creds.user.Username})
+	case strings.HasPrefix(path, "-/package/"):
+		name, tag, found := strings.Cut(strings.TrimPrefix(path, "-/package/"), "/dist-tags")
+		if !found {
+			return httperror.NotFound("not found")
+		}
+		return c.serveDistTags(w, r, name, strings.TrimPrefix(tag, "/"))
+	}
+
+	// Unpublishing adds the revision of the package document, which isn't needed here
+	path, _, _ = strings.Cut(path, "/-rev/")
+	name, file, isTarball := strings.Cut(path, "/-/")
+	if isTarball {
+		return c.serveTarball(w, r, name, file)
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		pkg, _, err := c.authorize(w, r, models.PackageTypeNPM, name, apiPermissionRead)
+		if err != nil {
+			return err
+		}
+		packument, err := c.packageService.NPMPackument(pkg, c.npmBase(r))
+		if err != nil {
+			return err
+		}
+		return writeAPIJSON(w, r, http.StatusOK, packument)
+	case http.MethodPut:
+		if strings.Contains(chi.URLParam(r, "*"), "/-rev/") {
+			return c.unpublishVersions(w, r, name)
+		}
+		return c.publish(w, r, name)
+	case http.MethodDelete:
+		pkg, _, err := c.authorize(w, r, models.PackageTypeNPM, name, apiPermissionAdmin)
+		if err != nil {
+			return err
+		}
+		if err := c.packageService.Delete(pkg); err != nil {
+			return err
+		}
+		return writeAPIJSON(w, r, http.StatusOK, npmOKJSON{OK: true})
+	}
+	return httperror.New(http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func (c *packageRegistryController) publish(w http.ResponseWriter, r *http.Request, name string) error {
+	// Authorize before buffering the document, anyone may send one
+	pkg, creds, err := c.authorizePublish(w, r, models.PackageTypeNPM, name)
+	if err != nil {
+		return err
+	}
+
+	// The tarball is base64 encoded in the document
+	r.Body = http.MaxBytesReader(w, r.Body, services.PackageMaxFileSize/3*4+apiMaxBodySize)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return httperror.New(http.StatusRequestEntityTooLarge, services.ErrPackageFileTooLarge.Error())
+		}
+		return err
+	}
+	publish, err := c.packageService.ParseNPMPublish(name, body)
+	if err != nil {
+		return packageError(err)
+	}
+
+	if pkg == nil {
+		if pkg, err = c.createPackage(r, models.PackageTypeNPM, name); err != nil {
+			return err
+		}
+	}
+	if _, err := c.packageService.PublishNPM(pkg, creds.user, publish); err != nil {
+		return packageError(err)
+	}
+	return writeAPIJSON(w, r, http.StatusCreated, npmOKJSON{OK: true})
+}
+
+// unpublishVersions deletes the versions missing from the package document, as npm unpublish
+// <name>@<version> sends it
+func (c *packageRegistryController) unpublishVersions(w http.ResponseWriter, r *http.Request, name string) error {
+	pkg, _, err := c.authorize(w, r, models.PackageTypeNPM, name, apiPermissionAdmin)
+	if err != nil {
+		return err
+	}
+	var document npmUnpublishJSON
+	if err := decodeAPIBody(w, r, &document); err != nil {
+		return err
+	}
+
+	versions, err := c.packageService.Versions(pkg)
+	if err != nil {
+		return err
+	}
+	for _, version := range versions {
+		if _, kept := document.Versions[version.Version.Version]; kept {
+			continue
+		}
+		if err := c.packageService.DeleteVersion(pkg, version.Version.Version); err != nil {
+			return packageError(err)
+		}
+	}
+	return writeAPIJSON(w, r, http.StatusOK, npmOKJSON{OK: true})
+}
+
+func (c *packageRegistryController) serveTarball(w http.ResponseWriter, r *http.Request, name, file string) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		pkg, _, err := c.authorize(w, r, models.PackageTypeNPM, name, apiPermissionRead)
+		if err != nil {
+			return err
+		}
+		version, ok := c.tarballVersion(pkg, file)
+		if !ok {
+			return httperror.NotFound("not found")
+		}
+		return c.serveFile(w, r, pkg, version, file)
+	case http.MethodDelete:
+		// npm unpublish deletes the tarball after the version, which is usually gone by then
+		pkg, _, err := c.authorize(w, r, models.PackageTypeNPM, name, apiPermissionAdmin)
+		if err != nil {
+			return err
+		}
+		if version, ok := c.tarballVersion(pkg, file); ok {
+			if err := c.packageService.DeleteVersion(pkg, version); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+		}
+		return writeAPIJSON(w, r, http.StatusOK, npmOKJSON{OK: true})
+	}
+	return httperror.New(http.StatusMethodNotAllowed, "method not allowed")
+}
+
+// tarballVersion returns the version of the tarball file name of a package
+func (c *packageRegistryController) tarballVersion(pkg *models.Package, file string) (string, bool) {
+	prefix := strings.TrimSuffix(c.packageService.NPMTarballName(pkg.Name, ""), ".tgz")
+	version, found := strings.CutPrefix(file, prefix)
+	if !found || !strings.HasSuffix(version, ".tgz") {
+		return "", false
+	}
+	return strings.TrimSuffix(version, ".tgz"), true
+}
+
+func (c *packageRegistryController) serveDistTags(w http.ResponseWriter, r *http.Request, name, tag string) error {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		pkg, _, err := c.authorize(w, r, models.PackageTypeNPM, name, apiPermissionRead)
+		if err != nil {
+			return err
+		}
+		distTags, err := c.packageService.DistTags(pkg)
+		if err != nil {
+			return err
+		}
+		return writeAPIJSON(w, r, http.StatusOK, distTags)
+	}
+
+	if tag == "" {
+		return httperror.New(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	pkg, _, err := c.authorize(w, r, models.PackageTypeNPM, name, apiPermissionWrite)
+	if err != nil {
+		return err
+	}
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		var version string
+		if err := decodeAPIBody(w, r, &version); err != nil {
+			return err
+		}
+		if err := c.packageService.SetDistTag(pkg, tag, version); err != nil {
+			return packageError(err)
+		}
+	case http.MethodDelete:
+		if tag == "latest" {
+			return httperror.BadRequest("the latest dist-tag can't be deleted")
+		}
+		if err := c.packageService.DeleteDistTag(pkg, tag); err != nil {
+			return packageError(err)
+		}
+	default:
+		return httperror.New(http.StatusMethodNotAllowed, "method not allowed")
+	}
+	return writeAPIJSON(w, r, http.StatusOK, npmOKJSON{OK: true})
+}
+
+func (c *packageRegistryController) Raw(w http.ResponseWriter, r *http.Request) error {
+	segments := strings.Split(chi.URLParam(r, "*"), "/")
+	name := segments[0]
+
+	if len(segments) == 1 {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return httperror.New(http.StatusMethodNotAllowed, "method not allowed")
+		}
+		pkg, _, err := c.authorize(w, r, models.PackageTypeRaw, name, apiPermissionRead)
+		if err != nil {
+			return err
+		}
+		return c.listRawVersions(w, r, pkg)
+	}
+	if len(segments) != 3 {
+		return httperror.NotFound("not found")
+	}
+	version, file := segments[1], segments[2]
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		pkg, _, err := c.authorize(w, r, models.PackageTypeRaw, name, apiPermissionRead)
+		if err != nil {
+			return err
+		}
+		return c.serveFile(w, r, pkg, version, file)
+	case http.MethodPut:
+		pkg, creds, err := c.authorizePublish(w, r, models.PackageTypeRaw, name)
+		if err != nil {
+			return err
+		}
+		if pkg == nil {
+			if pkg, err = c.createPackage(r, models.PackageTypeRaw, name); err != nil {
+				return err
+			}
+		}
+		uploaded, err := c.packageService.UploadFile(pkg, creds.user, version, file, r.Body)
+		if err != nil {
+			return packageError(err)
+		}
+		return writeAPIJSON(w, r, http.StatusCreated, c.rawFileJSON(r, pkg, version, uploaded))
+	case http.MethodDelete:
+		pkg, _, err := c.authorize(w, r, models.PackageTypeRaw, name, apiPermissionWrite)
+		if err != nil {
+			return err
+		}
+		if err := c.packageService.DeleteFile(pkg, version, file); err != nil {
+			return packageError(err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return httperror.New(http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func (c *packageRegistryController) listRawVersions(w http.ResponseWriter, r *http.Request, pkg *models.Package) error {
+	versions, err := c.packageService.Versions(pkg)
+	if err != nil {
+		return err
+	}
+
+	body := make([]rawVersionJSON, 0, len(versions))
+	for _, version := range versions {
+		files := make([]rawFileJSON, 0, len(version.Files))
+		for _, file := range version.Files {
+			files = append(files, c.rawFileJSON(r, pkg, version.Version.Version, file))
+		}
+		body = append(body, rawVersionJSON{
+			Version:   version.Version.Version,
+			CreatedAt: apiTime(version.Version.CreatedAt),
+			Files:     files,
+		})
+	}
+	return writeAPIJSON(w, r, http.StatusOK, body)
+}
+
+func (c *packageRegistryController) rawFileJSON(r *http.Request, pkg *models.Package, version string, file *models.PackageFile) rawFileJSON {
+	return rawFileJSON{
+		Name:        file.Name,
+		Size:        file.Size,
+		SHA256:      file.SHA256,
+		DownloadURL: c.publicURL + "/api/packages/" + chi.URLParam(r, "owner") + "/raw/" + pkg.Name + "/" + version + "/" + file.Name,
+	}
+}
+
+// serveFile downloads a file of a version of the package. Files are never shown inline, they are
+// content of users served on the site's origin.
+func (c *packageRegistryController) serveFile(w http.ResponseWriter, r *http.Request, pkg *models.Package, version, name string) error {
+	file, err := c.packageService.FindFile(pkg, version, name)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return httperror.NotFound("not found")
+	}
+	content, err := c.packageService.OpenFile(pkg, file)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", "attachment; filename=\""+file.Name+"\"")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("ETag", `"`+file.SHA256+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=86400, immutable")
+	http.ServeContent(w, r, "", time.Unix(file.CreatedAt, 0), content)
+	return nil
+}
+
+// authenticate returns the credentials of the request. Downloads in a browser, such as from the
+// packages pages, may use the session instead of an access token.
+func (c *packageRegistryController) authenticate(w http.ResponseWriter, r *http.Request) (*packageCredentials, error) {
+	user, token, err := c.authenticateToken(r)
+	if err != nil {
+		var httpErr httperror.HTTPError
+		if errors.As(err, &httpErr) && httpErr.StatusCode == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", `Basic realm="Hypercommit"`)
+		}
+		return nil, err
+	}
+	if user == nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) {
+		user = middleware.GetUserFromContext(r)
+	}
+	return &packageCredentials{user: user, token: token}, nil
+}
+
+// authorize returns the package of the {owner} URL parameter if the request may use it with the
+// permission
+func (c *packageRegistryController) authorize(w http.ResponseWriter, r *http.Request, packageType, name string, need apiPermission) (*models.Package, *packageCredentials, error) {
+	creds, err := c.authenticate(w, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if need >= apiPermissionWrite {
+		if err := c.requireWriteScope(creds); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	pkg, err := c.findPackage(r, packageType, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := c.checkPermission(w, creds, pkg, name, need); err != nil {
+		return nil, nil, err
+	}
+	return pkg, creds, nil
+}
+
+// checkPermission fails unless the request may use the package, which may be nil, with the
+// permission. Anonymous requests are asked to sign in for packages they can't see, so that they
+// don't tell private packages from missing ones.
+func (c *packageRegistryController) checkPermission(w http.ResponseWriter, creds *packageCredentials, pkg *models.Package, name string, need apiPermission) error {
+	permission := apiPermissionNone
+	if pkg != nil {
+		var err error
+		permission, err = c.packagePermission(pkg, creds.user, creds.token)
+		if err != nil {
+			return err
+		}
+	}
+
+	if permission == apiPermissionNone {
+		if creds.user == nil {
+			return c.unauthorized(w)
+		}
+		return httperror.NotFound("package " + name + " not found")
+	}
+	if permission < need {
+		if creds.user == nil {
+			return c.unauthorized(w)
+		}
+		return httperror.Forbidden(need.String() + " access to the package is required")
+	}
+	return nil
+}
+
+// authorizePublish checks the request may publish a version of the package and returns it, nil if
+// the owner has none of the name yet and it may be created
+func (c *packageRegistryController) authorizePublish(w http.ResponseWriter, r *http.Request, packageType, name string) (*models.Package, *packageCredentials, error) {
+	creds, err := c.authenticate(w, r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if creds.user == nil {
+		return nil, nil, c.unauthorized(w)
+	}
+	if err := c.requireWriteScope(creds); err != nil {
+		return nil, nil, err
+	}
+
+	pkg, err := c.findPackage(r, packageType, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if pkg != nil {
+		if err := c.checkPermission(w, creds, pkg, name, apiPermissionWrite); err != nil {
+			return nil, nil, err
+		}
+		return pkg, creds, nil
+	}
+
+	ownerUserID, ownerOrgID := packageOwner(r)
+	permission, err := c.ownerPermission(ownerUserID, ownerOrgID, creds.user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if permission < apiPermissionWrite {
+		return nil, nil, httperror.Forbidden("you can't publish packages of " + chi.URLParam(r, "owner"))
+	}
+	if creds.token != nil && len(creds.token.RepositoryIDs) > 0 {
+		return nil, nil, httperror.Forbidden("access tokens limited to repositories can't create packages")
+	}
+	return nil, creds, nil
+}
+
+// createPackage creates the package of the owner on its first publish
+func (c *packageRegistryController) createPackage(r *http.Request, packageType, name string) (*models.Package, error) {
+	ownerUserID, ownerOrgID := packageOwner(r)
+	pkg, err := c.packageService.Create(ownerUserID, ownerOrgID, packageType, name)
+	if err != nil {
+		return nil, packageError(err)
+	}
+	return pkg, nil
+}
+
+func (c *packageRegistryController) requireWriteScope(creds *packageCredentials) error {
+	if creds.token != nil && !c.accessTokens.HasScope(creds.token, models.ScopeRepoWrite) {
+		return httperror.Forbidden("access token is missing the " + models.ScopeRepoWrite + " scope")
+	}
+	return nil
+}
+
+func (c *packageRegistryController) unauthorized(w http.ResponseWriter) error {
+	w.Header().Set("WWW-Authenticate", `Basic realm="Hypercommit"`)
+	return httperror.Unauthorized("authentication required")
+}
+
+// npmBase is the registry URL of the owner's npm packages
+func (c *packageRegistryController) npmBase(r *http.Request) string {
+	return c.publicURL + "/api/packages/" + chi.URLParam(r, "owner") + "/npm"
+}
+
+// writeNPMError responds with err as {"error": message}, which the npm client shows
+func (c *packageRegistryController) writeNPMError(w http.ResponseWriter, err error) error {
+	var httpErr httperror.HTTPError
+	if !errors.As(err, &httpErr) {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpErr.StatusCode)
+	return json.NewEncoder(w).Encode(map[string]string{"error": httpErr.Message})
+}
+
+// packageError reports the errors of the package service with their status
+func packageError(err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidPackageName),
+		errors.Is(err, services.ErrInvalidPackageVersion),
+		errors.Is(err, services.ErrInvalidPackageFileName),
+		errors.Is(err, services.ErrInvalidNPMPublish):
+		return httperror.BadRequest(err.Error())
+	case errors.Is(err, services.ErrPackageVersionExists), errors.Is(err, services.ErrPackageFileExists):
+		return httperror.New(http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrPackageFileTooLarge):
+		return httperror.New(http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, os.ErrNotExist), errors.Is(err, sql.ErrNoRows):
+		return httperror.NotFound("not found")
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// countingReader is a request body that records how much of it was read
+type countingReader struct {
+	*strings.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestNPMPublishIsAuthorizedBeforeTheBodyIsRead(t *testing.T) {
+	db := newTestDB(t)
+	access := newTestAPIAccess(db)
+	packages := repositories.NewPackagesRepository(db.DB)
+	controller := NewPackageRegistryController(
+		access.repos,
+		access.users,
+		access.orgs,
+		access.orgMembers,
+		access.contributors,
+		packages,
+		access.accessTokens,
+		access.twoFactor,
+		services.NewPackageService(
+			packages,
+			repositories.NewPackageVersionsRepository(db.DB),
+			repositories.NewPackageFilesRepository(db.DB),
+			repositories.NewPackageDistTagsRepository(db.DB),
+			access.repos,
+			filepath.Join(t.TempDir(), "packages"),
+		),
+		testPublicURL,
+	)
+
+	router := chi.NewRouter()
+	router.Route("/api/packages/{owner}", func(r chi.Router) {
+		r.Use(middleware.OwnerResolver(access.users, access.orgs))
+		r.HandleFunc("/npm/*", func(w http.ResponseWriter, r *http.Request) {
+			if err := controller.NPM(w, r); err != nil {
+				t.Errorf("npm: %v", err)
+			}
+		})
+	})
+
+	tokens := map[string]string{}
+	for _, username := range []string{"alice", "outsider"} {
+		user, err := access.users.Create(username, username+"@example.com", username, "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		token, _, err := access.accessTokens.Create(user.ID, "npm", models.AccessTokenScopes, nil, nil)
+		if err != nil {
+			t.Fatalf("create access token: %v", err)
+		}
+		tokens[username] = token
+	}
+
+	for _, tt := range []struct {
+		user     string
+		want     int
+		wantRead bool
+	}{
+		{"", http.StatusUnauthorized, false},
+		{"outsider", http.StatusForbidden, false},
+		{"alice", http.StatusBadRequest, true},
+	} {
+		body := &countingReader{Reader: strings.NewReader(`{"name": "left-pad", "versions": {}}`)}
+		r := httptest.NewRequest(http.MethodPut, "/api/packages/alice/npm/left-pad", body)
+		if tt.user != "" {
+			r.Header.Set("Authorization", "Bearer "+tokens[tt.user])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != tt.want {
+			t.Errorf("publish as %q: status %d, want %d", tt.user, w.Code, tt.want)
+		}
+		if read := body.read > 0; read != tt.wantRead {
+			t.Errorf("publish as %q read %d bytes of the body", tt.user, body.read)
+		}
+	}
+
+	// Invalid documents don't leave an empty package behind
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM packages`).Scan(&count); err != nil {
+		t.Fatalf("count packages: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("%d packages were created", count)
+	}
+}
@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// PackagesController shows the packages of users and organizations on the packages tab of their
+// profile, under /{owner}/packages, and lets administrators of a package link it to a repository or
+// delete it. Visitors only see the packages they may read.
+type PackagesController interface {
+	Index(w http.ResponseWriter, r *http.Request) error
+	// Show shows a package with its versions, files, dist-tags and how to install it
+	Show(w http.ResponseWriter, r *http.Request) error
+	// Update handles the forms of a package, posted to <package URL>/repository to link it to a
+	// repository and to <package URL>/delete to delete it
+	Update(w http.ResponseWriter, r *http.Request) error
+}
+
+type packagesController struct {
+	*packageAccess
+	packageService services.PackageService
+	publicURL      string
+}
+
+func NewPackagesController(
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	packages repositories.PackagesRepository,
+	accessTokens services.AccessTokenService,
+	twoFactor services.TwoFactorService,
+	packageService services.PackageService,
+	publicURL string,
+) PackagesController {
+	return &packagesController{
+		packageAccess: &packageAccess{
+			apiAccess: &apiAccess{
+				repos:        repos,
+				users:        users,
+				orgs:         orgs,
+				orgMembers:   orgMembers,
+				contributors: contributors,
+				accessTokens: accessTokens,
+				twoFactor:    twoFactor,
+			},
+			packages: packages,
+		},
+		packageService: packageService,
+		publicURL:      publicURL,
+	}
+}
+
+func (c *packagesController) Index(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	profile, err := c.profile(r, user)
+	if err != nil {
+		return err
+	}
+
+	ownerUserID, ownerOrgID := packageOwner(r)
+	var packages []*models.Package
+	if ownerOrgID != nil {
+		packages, err = c.packages.FindAllByOrg(*ownerOrgID)
+	} else {
+		packages, err = c.packages.FindAllByUser(*ownerUserID)
+	}
+	if err != nil {
+		return err
+	}
+
+	data := &pages.OwnerPackagesData{
+		User:    user,
+		Profile: profile,
+		Success: c.takeNotice(w, r),
+	}
+	for _, pkg := range packages {
+		permission, err := c.packagePermission(pkg, user, nil)
+		if err != nil {
+			return err
+		}
+		if permission < apiPermissionRead {
+			continue
+		}
+		repo, err := c.linkedRepository(pkg)
+		if err != nil {
+			return err
+		}
+		data.Packages = append(data.Packages, pages.PackageListItem{Package: pkg, Repository: repo})
+	}
+
+	return pages.OwnerPackages(r, data).Render(w, r)
+}
+
+func (c *packagesController) Show(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	pkg, permission, err := c.findWebPackage(r, user, chi.URLParam(r, "*"))
+	if err != nil {
+		return err
+	}
+	profile, err := c.profile(r, user)
+	if err != nil {
+		return err
+	}
+
+	repo, err := c.linkedRepository(pkg)
+	if err != nil {
+		return err
+	}
+	versions, err := c.packageService.Versions(pkg)
+	if err != nil {
+		return err
+	}
+	distTags := map[string]string{}
+	if pkg.Type == models.PackageTypeNPM {
+		distTags, err = c.packageService.DistTags(pkg)
+		if err != nil {
+			return err
+		}
+	}
+
+	data := &pages.ShowPackageData{
+		User:        user,
+		Profile:     profile,
+		Package:     pkg,
+		Repository:  repo,
+		Versions:    versions,
+		DistTags:    distTags,
+		RegistryURL: c.publicURL + "/api/packages/" + profile.Username + "/" + pkg.Type,
+		CanAdmin:    permission >= apiPermissionAdmin,
+		Success:     c.takeNotice(w, r),
+	}
+	if data.CanAdmin {
+		// Packages can be linked to the repositories of their owner the user administers
+		var repos []*models.Repository
+		if pkg.OwnerOrgID != nil {
+			repos, err = c.repos.FindAllByOrg(*pkg.OwnerOrgID)
+		} else {
+			repos, err = c.repos.FindAllByUser(*pkg.OwnerUserID)
+		}
+		if err != nil {
+			return err
+		}
+		for _, candidate := range repos {
+			repoPermission, err := c.permission(candidate, user)
+			if err != nil {
+				return err
+			}
+			if repoPermission >= apiPermissionAdmin {
+				data.Repositories = append(data.Repositories, candidate)
+			}
+		}
+	}
+
+	return pages.ShowPackage(r, data).Render(w, r)
+}
+
+func (c *packagesController) Update(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	path, action, _ := cutLast(chi.URLParam(r, "*"), "/")
+	pkg, permission, err := c.findWebPackage(r, user, path)
+	if err != nil {
+		return err
+	}
+	if permission < apiPermissionAdmin {
+		return httperror.Forbidden("only administrators of the package can change it")
+	}
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form")
+	}
+	packageURL := "/" + chi.URLParam(r, "owner") + "/packages/" + pkg.Type + "/" + pkg.Name
+
+	switch action {
+	case "repository":
+		var repo *models.Repository
+		if name := strings.TrimSpace(r.FormValue("repository")); name != "" {
+			if pkg.OwnerOrgID != nil {
+				repo, err = c.repos.FindByOrgAndName(*pkg.OwnerOrgID, name)
+			} else {
+				repo, err = c.repos.FindByUserAndName(*pkg.OwnerUserID, name)
+			}
+			if err != nil {
+				return err
+			}
+			if repo == nil {
+				return httperror.BadRequest("repository not found")
+			}
+			repoPermission, err := c.permission(repo, user)
+			if err != nil {
+				return err
+			}
+			if repoPermission < apiPermissionAdmin {
+				return httperror.Forbidden("only administrators of the repository can link packages to it")
+			}
+		}
+		if err := c.packageService.Link(pkg, repo); err != nil {
+			return err
+		}
+
+		if repo != nil {
+			c.setNotice(w, "Package linked to "+repo.Name)
+		} else {
+			c.setNotice(w, "Package unlinked, only its owner can see it now")
+		}
+		http.Redirect(w, r, packageURL, http.StatusSeeOther)
+		return nil
+	case "delete":
+		if err := c.packageService.Delete(pkg); err != nil {
+			return err
+		}
+
+		c.setNotice(w, "Package "+pkg.Name+" deleted")
+		http.Redirect(w, r, "/"+chi.URLParam(r, "owner")+"/packages", http.StatusSeeOther)
+		return nil
+	}
+	return httperror.NotFound("not found")
+}
+
+// findWebPackage returns the package of the {type} URL parameter and name if the user may read it,
+// along with the user's permission
+func (c *packagesController) findWebPackage(r *http.Request, user *models.User, name string) (*models.Package, apiPermission, error) {
+	pkg, err := c.findPackage(r, chi.URLParam(r, "type"), name)
+	if err != nil {
+		return nil, apiPermissionNone, err
+	}
+	if pkg == nil {
+		return nil, apiPermissionNone, httperror.NotFound("package not found")
+	}
+
+	permission, err := c.packagePermission(pkg, user, nil)
+	if err != nil {
+		return nil, apiPermissionNone, err
+	}
+	if permission == apiPermissionNone {
+		return nil, apiPermissionNone, httperror.NotFound("package not found")
+	}
+	return pkg, permission, nil
+}
+
+// linkedRepository returns the repository the package is linked to, nil if there is none
+func (c *packagesController) linkedRepository(pkg *models.Package) (*models.Repository, error) {
+	if pkg.RepositoryID == nil {
+		return nil, nil
+	}
+	return c.repos.FindByID(*pkg.RepositoryID)
+}
+
+// profile returns what the profile layout shows of the owner of the URL
+func (c *packagesController) profile(r *http.Request, user *models.User) (pages.PackagesProfile, error) {
+	ownerUserID, ownerOrgID := packageOwner(r)
+	if ownerOrgID != nil {
+		org, err := c.orgs.FindByID(*ownerOrgID)
+		if err != nil {
+			return pages.PackagesProfile{}, err
+		}
+		if org == nil {
+			return pages.PackagesProfile{}, httperror.NotFound("organization not found")
+		}
+		role, err := c.organizationRole(org, user)
+		if err != nil {
+			return pages.PackagesProfile{}, err
+		}
+		return pages.PackagesProfile{
+			Username:     org.Username,
+			DisplayName:  org.DisplayName,
+			IsOrg:        true,
+			ShowSettings: role == models.OrganizationRoleOwner,
+		}, nil
+	}
+
+	owner, err := c.users.FindByID(*ownerUserID)
+	if err != nil {
+		return pages.PackagesProfile{}, err
+	}
+	if owner == nil {
+		return pages.PackagesProfile{}, httperror.NotFound("user not found")
+	}
+	return pages.PackagesProfile{
+		Username:    owner.Username,
+		DisplayName: owner.DisplayName,
+	}, nil
+}
+
+func (c *packagesController) setNotice(w http.ResponseWriter, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "package_success",
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+}
+
+// takeNotice returns and clears a message stored with setNotice
+func (c *packagesController) takeNotice(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie("package_success")
+	if err != nil {
+		return ""
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   "package_success",
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+	return cookie.Value
+}
@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+type PasskeysController interface {
+	Options(w http.ResponseWriter, r *http.Request) error
+	Create(w http.ResponseWriter, r *http.Request) error
+	Delete(w http.ResponseWriter, r *http.Request) error
+}
+
+type passkeysController struct {
+	passkeys       repositories.PasskeysRepository
+	passkeyService services.PasskeyService
+}
+
+func NewPasskeysController(passkeys repositories.PasskeysRepository, passkeyService services.PasskeyService) PasskeysController {
+	return &passkeysController{
+		passkeys:       passkeys,
+		passkeyService: passkeyService,
+	}
+}
+
+// Options returns the options for registering a new passkey in the browser
+func (c *passkeysController) Options(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		return httperror.Unauthorized("authentication required")
+	}
+
+	options, err := c.passkeyService.RegistrationOptions(user)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(options)
+}
+
+func (c *passkeysController) Create(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	name := strings.TrimSpace(r.FormValue("name"))
+	if name == "" {
+		return c.redirectWithError(w, r, "Passkey name is required")
+	}
+	if len(name) > 100 {
+		return c.redirectWithError(w, r, "Passkey name must be 100 characters or less")
+	}
+
+	passkey, err := c.passkeyService.FinishRegistration(user, name, r.FormValue("credential"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPasskeyExists):
+			return c.redirectWithError(w, r, "This passkey is already registered")
+		case errors.Is(err, services.ErrInvalidPasskey):
+			return c.redirectWithError(w, r, "The passkey could not be verified. Please try again.")
+		}
+		return err
+	}
+
+	slog.Info("passkey registered", "user", user.Username, "passkey", passkey.ID)
+
+	return c.redirectWithSuccess(w, r, "Passkey added")
+}
+
+func (c *passkeysController) Delete(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return httperror.BadRequest("invalid passkey ID")
+	}
+
+	deleted, err := c.passkeys.DeleteByIDAndUser(id, user.ID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return httperror.NotFound("passkey not found")
+	}
+
+	return c.redirectWithSuccess(w, r, "Passkey deleted")
+}
+
+func (c *passkeysController) redirectWithSuccess(w http.ResponseWriter, r *http.Request, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "passkey_success",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/settings#passkeys", http.StatusSeeOther)
+	return nil
+}
+
+func (c *passkeysController) redirectWithError(w http.ResponseWriter, r *http.Request, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "passkey_error",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/settings#passkeys", http.StatusSeeOther)
+	return nil
+}
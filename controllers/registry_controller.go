@@ -0,0 +1,680 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/httputil"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// RegistryController serves the container images of repositories with the OCI distribution protocol
+// under /v2/, for docker push <host>/<owner>/<repo>:<tag>. Images have the permissions of their
+// repository. Clients sign in with an access token as password, which the token endpoint exchanges
+// for a registry token as in the Docker token authentication.
+type RegistryController interface {
+	// Token issues a registry token for the scopes of the request
+	Token(w http.ResponseWriter, r *http.Request) error
+	// Serve answers the endpoints of the distribution specification
+	Serve(w http.ResponseWriter, r *http.Request) error
+}
+
+type registryController struct {
+	*apiAccess
+	registry services.RegistryService
+}
+
+func NewRegistryController(
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	accessTokens services.AccessTokenService,
+	twoFactor services.TwoFactorService,
+	registry services.RegistryService,
+) RegistryController {
+	return &registryController{
+		apiAccess: &apiAccess{
+			repos:        repos,
+			users:        users,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			contributors: contributors,
+			accessTokens: accessTokens,
+			twoFactor:    twoFactor,
+		},
+		registry: registry,
+	}
+}
+
+// registryErrorStatuses are the statuses of the error codes
+var registryErrorStatuses = map[string]int{
+	services.RegistryErrorBlobUnknown:         http.StatusNotFound,
+	services.RegistryErrorBlobUploadInvalid:   http.StatusRequestedRangeNotSatisfiable,
+	services.RegistryErrorBlobUploadUnknown:   http.StatusNotFound,
+	services.RegistryErrorDigestInvalid:       http.StatusBadRequest,
+	services.RegistryErrorManifestBlobUnknown: http.StatusBadRequest,
+	services.RegistryErrorManifestInvalid:     http.StatusBadRequest,
+	services.RegistryErrorManifestUnknown:     http.StatusNotFound,
+	services.RegistryErrorNameInvalid:         http.StatusBadRequest,
+	services.RegistryErrorNameUnknown:         http.StatusNotFound,
+	services.RegistryErrorSizeInvalid:         http.StatusRequestEntityTooLarge,
+	services.RegistryErrorTagInvalid:          http.StatusBadRequest,
+	services.RegistryErrorUnauthorized:        http.StatusUnauthorized,
+	services.RegistryErrorDenied:              http.StatusForbidden,
+	services.RegistryErrorUnsupported:         http.StatusMethodNotAllowed,
+}
+
+// registryUnauthorized asks the client to get a registry token for scope from the token endpoint
+type registryUnauthorized struct {
+	scope   string
+	message string
+}
+
+func (e *registryUnauthorized) Error() string {
+	return services.RegistryErrorUnauthorized + ": " + e.message
+}
+
+// registryCredentials are what a request authenticated with, a registry token or an access token.
+// Requests without either are anonymous.
+type registryCredentials struct {
+	user          *models.User
+	token         *models.AccessToken
+	registryToken *services.RegistryToken
+}
+
+type registryTokenJSON struct {
+	Token       string    `json:"token"`
+	AccessToken string    `json:"access_token"`
+	ExpiresIn   int64     `json:"expires_in"`
+	IssuedAt    time.Time `json:"issued_at"`
+}
+
+type registryTagsJSON struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type registryIndexJSON struct {
+	SchemaVersion int                           `json:"schemaVersion"`
+	MediaType     string                        `json:"mediaType"`
+	Manifests     []services.RegistryDescriptor `json:"manifests"`
+}
+
+var errRegistryMethodNotAllowed = &services.RegistryError{Code: services.RegistryErrorUnsupported, Message: "method not allowed"}
+
+func (c *registryController) Token(w http.ResponseWriter, r *http.Request) error {
+	user, token, err := c.authenticateToken(r)
+	if err != nil {
+		return c.writeError(w, r, err)
+	}
+
+	// Scopes are repository:<name>:<actions>, each action granted as far as the user may do it
+	access := []services.RegistryAccess{}
+	for _, scope := range strings.Fields(strings.Join(r.URL.Query()["scope"], " ")) {
+		resourceType, rest, _ := strings.Cut(scope, ":")
+		name, requested, found := cutLast(rest, ":")
+		if resourceType != "repository" || !found {
+			continue
+		}
+		owner, repoName, _, ok := c.registry.ParseName(name)
+		if !ok {
+			continue
+		}
+		repo, err := c.repos.FindByOwnerAndNameFold(owner, repoName)
+		if err != nil {
+			return err
+		}
+		if repo == nil {
+			continue
+		}
+		allowed, err := c.actions(user, token, repo)
+		if err != nil {
+			return err
+		}
+
+		granted := []string{}
+		for _, action := range strings.Split(requested, ",") {
+			if action == "*" {
+				granted = append(granted[:0], allowed...)
+				break
+			}
+			if slices.Contains(allowed, action) && !slices.Contains(granted, action) {
+				granted = append(granted, action)
+			}
+		}
+		access = append(access, services.RegistryAccess{Type: "repository", Name: name, Actions: granted})
+	}
+
+	var userID int64
+	if user != nil {
+		userID = user.ID
+	}
+	rawToken, err := c.registry.IssueToken(userID, access)
+	if err != nil {
+		return err
+	}
+
+	return writeOAuthJSON(w, http.StatusOK, registryTokenJSON{
+		Token:       rawToken,
+		AccessToken: rawToken,
+		ExpiresIn:   int64(services.RegistryTokenLifetime.Seconds()),
+		IssuedAt:    time.Now().UTC(),
+	})
+}
+
+func (c *registryController) Serve(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	if err := c.serve(w, r); err != nil {
+		return c.writeError(w, r, err)
+	}
+	return nil
+}
+
+func (c *registryController) serve(w http.ResponseWriter, r *http.Request) error {
+	creds, err := c.authenticate(r)
+	if err != nil {
+		return err
+	}
+
+	path := chi.URLParam(r, "*")
+	if path == "" {
+		// Clients check their credentials here, anonymous ones are asked to sign in
+		if creds.user == nil && creds.registryToken == nil {
+			return &registryUnauthorized{message: "authentication required"}
+		}
+		return writeRegistryJSON(w, http.StatusOK, "application/json", struct{}{})
+	}
+
+	// Names have slashes of their own, endpoints are told apart by their last segments
+	segments := strings.Split(path, "/")
+	n := len(segments)
+	name := func(end int) string {
+		return strings.Join(segments[:n-end], "/")
+	}
+	switch {
+	case n > 2 && segments[n-2] == "tags" && segments[n-1] == "list":
+		return c.tags(w, r, creds, name(2))
+	case n > 2 && segments[n-2] == "blobs" && segments[n-1] == "uploads":
+		return c.upload(w, r, creds, name(2), "")
+	case n > 3 && segments[n-3] == "blobs" && segments[n-2] == "uploads":
+		return c.upload(w, r, creds, name(3), segments[n-1])
+	case n > 2 && segments[n-2] == "blobs":
+		return c.blob(w, r, creds, name(2), segments[n-1])
+	case n > 2 && segments[n-2] == "manifests":
+		return c.manifest(w, r, creds, name(2), segments[n-1])
+	case n > 2 && segments[n-2] == "referrers":
+		return c.referrers(w, r, creds, name(2), segments[n-1])
+	}
+	return httperror.NotFound("unknown endpoint")
+}
+
+func (c *registryController) blob(w http.ResponseWriter, r *http.Request, creds *registryCredentials, name, digest string) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		repo, _, err := c.authorize(creds, name, services.RegistryActionPull)
+		if err != nil {
+			return err
+		}
+		blob, err := c.registry.FindBlob(repo, digest)
+		if err != nil {
+			return err
+		}
+		if blob == nil {
+			return &services.RegistryError{Code: services.RegistryErrorBlobUnknown, Message: "blob unknown to registry"}
+		}
+		file, err := c.registry.OpenBlob(blob)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Docker-Content-Digest", blob.Digest)
+		w.Header().Set("ETag", `"`+blob.Digest+`"`)
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+		http.ServeContent(w, r, "", time.Unix(blob.CreatedAt, 0), file)
+		return nil
+
+	case http.MethodDelete:
+		repo, _, err := c.authorize(creds, name, services.RegistryActionDelete)
+		if err != nil {
+			return err
+		}
+		if err := c.registry.DeleteBlob(repo, digest); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+	return errRegistryMethodNotAllowed
+}
+
+// upload answers the endpoints of blob uploads, id is empty to start one
+func (c *registryController) upload(w http.ResponseWriter, r *http.Request, creds *registryCredentials, name, id string) error {
+	repo, _, err := c.authorize(creds, name, services.RegistryActionPush)
+	if err != nil {
+		return err
+	}
+
+	if id == "" {
+		if r.Method != http.MethodPost {
+			return errRegistryMethodNotAllowed
+		}
+		return c.startUpload(w, r, creds, repo, name)
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		size, err := c.registry.UploadSize(repo, id)
+		if err != nil {
+			return err
+		}
+		return writeRegistryUpload(w, name, id, size, http.StatusNoContent)
+
+	case http.MethodPatch:
+		offset, err := contentRangeStart(r)
+		if err != nil {
+			return err
+		}
+		size, err := c.registry.AppendUpload(repo, id, offset, r.Body)
+		if err != nil {
+			return err
+		}
+		return writeRegistryUpload(w, name, id, size, http.StatusAccepted)
+
+	case http.MethodPut:
+		// The last chunk may come along with the digest
+		if r.ContentLength != 0 {
+			offset, err := contentRangeStart(r)
+			if err != nil {
+				return err
+			}
+			if _, err := c.registry.AppendUpload(repo, id, offset, r.Body); err != nil {
+				return err
+			}
+		}
+		blob, err := c.registry.FinishUpload(repo, id, r.URL.Query().Get("digest"))
+		if err != nil {
+			return err
+		}
+		return writeRegistryBlobCreated(w, name, blob)
+
+	case http.MethodDelete:
+		if err := c.registry.CancelUpload(repo, id); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return errRegistryMethodNotAllowed
+}
+
+// startUpload mounts a blob of another image the user can pull, uploads a blob in one request when
+// given its digest, or starts an upload in chunks
+func (c *registryController) startUpload(w http.ResponseWriter, r *http.Request, creds *registryCredentials, repo *models.Repository, name string) error {
+	query := r.URL.Query()
+
+	if digest, fromName := query.Get("mount"), query.Get("from"); digest != "" && fromName != "" {
+		from, err := c.mountSource(creds, fromName)
+		if err != nil {
+			return err
+		}
+		if from != nil {
+			blob, err := c.registry.MountBlob(repo, from, digest)
+			if err != nil {
+				return err
+			}
+			if blob != nil {
+				return writeRegistryBlobCreated(w, name, blob)
+			}
+		}
+	}
+
+	id, err := c.registry.StartUpload(repo)
+	if err != nil {
+		return err
+	}
+
+	if digest := query.Get("digest"); digest != "" {
+		if _, err := c.registry.AppendUpload(repo, id, 0, r.Body); err != nil {
+			return err
+		}
+		blob, err := c.registry.FinishUpload(repo, id, digest)
+		if err != nil {
+			return err
+		}
+		return writeRegistryBlobCreated(w, name, blob)
+	}
+
+	return writeRegistryUpload(w, name, id, 0, http.StatusAccepted)
+}
+
+// mountSource returns the repository of the image blobs are mounted from, nil if the user can't
+// pull it, in which case the blob is uploaded instead
+func (c *registryController) mountSource(creds *registryCredentials, name string) (*models.Repository, error) {
+	owner, repoName, _, ok := c.registry.ParseName(name)
+	if !ok || (creds.registryToken != nil && !creds.registryToken.Allows(name, services.RegistryActionPull)) {
+		return nil, nil
+	}
+	repo, err := c.repos.FindByOwnerAndNameFold(owner, repoName)
+	if err != nil || repo == nil {
+		return nil, err
+	}
+	actions, err := c.actions(creds.user, creds.token, repo)
+	if err != nil || !slices.Contains(actions, services.RegistryActionPull) {
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (c *registryController) manifest(w http.ResponseWriter, r *http.Request, creds *registryCredentials, name, reference string) error {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		repo, image, err := c.authorize(creds, name, services.RegistryActionPull)
+		if err != nil {
+			return err
+		}
+		manifest, err := c.registry.FindManifest(repo, image, reference)
+		if err != nil {
+			return err
+		}
+		if manifest == nil {
+			return &services.RegistryError{Code: services.RegistryErrorManifestUnknown, Message: "manifest unknown to registry"}
+		}
+
+		w.Header().Set("Content-Type", manifest.MediaType)
+		w.Header().Set("Docker-Content-Digest", manifest.Digest)
+		w.Header().Set("ETag", `"`+manifest.Digest+`"`)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		http.ServeContent(w, r, "", time.Unix(manifest.CreatedAt, 0), bytes.NewReader(manifest.Content))
+		return nil
+
+	case http.MethodPut:
+		repo, image, err := c.authorize(creds, name, services.RegistryActionPush)
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(http.MaxBytesReader(w, r.Body, services.RegistryMaxManifestSize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				return &services.RegistryError{Code: services.RegistryErrorSizeInvalid, Message: "manifests are at most 4 MiB"}
+			}
+			return err
+		}
+		mediaType, _, _ := strings.Cut(r.Header.Get("Content-Type"), ";")
+
+		manifest, err := c.registry.PutManifest(repo, image, reference, strings.TrimSpace(mediaType), content)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Location", "/v2/"+name+"/manifests/"+manifest.Digest)
+		w.Header().Set("Docker-Content-Digest", manifest.Digest)
+		if manifest.SubjectDigest != nil {
+			w.Header().Set("OCI-Subject", *manifest.SubjectDigest)
+		}
+		w.WriteHeader(http.StatusCreated)
+		return nil
+
+	case http.MethodDelete:
+		repo, image, err := c.authorize(creds, name, services.RegistryActionDelete)
+		if err != nil {
+			return err
+		}
+		if err := c.registry.DeleteManifest(repo, image, reference); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusAccepted)
+		return nil
+	}
+	return errRegistryMethodNotAllowed
+}
+
+func (c *registryController) tags(w http.ResponseWriter, r *http.Request, creds *registryCredentials, name string) error {
+	if r.Method != http.MethodGet {
+		return errRegistryMethodNotAllowed
+	}
+	repo, image, err := c.authorize(creds, name, services.RegistryActionPull)
+	if err != nil {
+		return err
+	}
+
+	// Without n every tag is listed
+	limit := -1
+	if n := r.URL.Query().Get("n"); n != "" {
+		limit, err = strconv.Atoi(n)
+		if err != nil || limit < 0 {
+			return httperror.BadRequest("n must be a number of tags")
+		}
+	}
+	tags, err := c.registry.Tags(repo, image, r.URL.Query().Get("last"), limit)
+	if err != nil {
+		return err
+	}
+	if tags == nil {
+		tags = []string{}
+	}
+
+	if limit > 0 && len(tags) == limit {
+		next := "/v2/" + name + "/tags/list?n=" + strconv.Itoa(limit) + "&last=" + url.QueryEscape(tags[len(tags)-1])
+		w.Header().Set("Link", "<"+next+`>; rel="next"`)
+	}
+	return writeRegistryJSON(w, http.StatusOK, "application/json", registryTagsJSON{Name: name, Tags: tags})
+}
+
+func (c *registryController) referrers(w http.ResponseWriter, r *http.Request, creds *registryCredentials, name, digest string) error {
+	if r.Method != http.MethodGet {
+		return errRegistryMethodNotAllowed
+	}
+	repo, image, err := c.authorize(creds, name, services.RegistryActionPull)
+	if err != nil {
+		return err
+	}
+
+	artifactType := r.URL.Query().Get("artifactType")
+	descriptors, err := c.registry.Referrers(repo, image, digest, artifactType)
+	if err != nil {
+		return err
+	}
+	if artifactType != "" {
+		w.Header().Set("OCI-Filters-Applied", "artifactType")
+	}
+	return writeRegistryJSON(w, http.StatusOK, services.RegistryMediaTypeOCIIndex, registryIndexJSON{
+		SchemaVersion: 2,
+		MediaType:     services.RegistryMediaTypeOCIIndex,
+		Manifests:     descriptors,
+	})
+}
+
+// authenticate returns the credentials of the request, a registry token or an access token
+func (c *registryController) authenticate(r *http.Request) (*registryCredentials, error) {
+	rawToken, bearer := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if registryToken := c.registry.ParseToken(strings.TrimSpace(rawToken)); bearer && registryToken != nil {
+		creds := &registryCredentials{registryToken: registryToken}
+		if registryToken.UserID == 0 {
+			return creds, nil
+		}
+
+		user, err := c.users.FindByID(registryToken.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, httperror.Unauthorized("invalid or expired token")
+		}
+		if user.SuspendedAt != nil {
+			return nil, httperror.Forbidden(accountSuspendedMessage)
+		}
+		creds.user = user
+		return creds, nil
+	}
+
+	user, token, err := c.authenticateToken(r)
+	if err != nil {
+		return nil, err
+	}
+	return &registryCredentials{user: user, token: token}, nil
+}
+
+// actions returns the actions the user, limited by the access token if any, may do with the images
+// of the repository: readers pull, writers push and administrators delete
+func (c *registryController) actions(user *models.User, token *models.AccessToken, repo *models.Repository) ([]string, error) {
+	permission, err := c.permission(repo, user)
+	if err != nil {
+		return nil, err
+	}
+	if token != nil && !c.accessTokens.AllowsRepository(token, repo.ID) {
+		return nil, nil
+	}
+	write := token == nil || c.accessTokens.HasScope(token, models.ScopeRepoWrite)
+
+	var actions []string
+	if permission >= apiPermissionRead {
+		actions = append(actions, services.RegistryActionPull)
+	}
+	if permission >= apiPermissionWrite && write {
+		actions = append(actions, services.RegistryActionPush)
+	}
+	if permission >= apiPermissionAdmin && write {
+		actions = append(actions, services.RegistryActionDelete)
+	}
+	return actions, nil
+}
+
+// authorize returns the repository of the image and the path of the image below it if the request may
+// do the action. Images the user can't pull are reported as unknown, anonymous users and registry
+// tokens that don't grant the action are asked for a token that does.
+func (c *registryController) authorize(creds *registryCredentials, name, action string) (*models.Repository, string, error) {
+	owner, repoName, image, ok := c.registry.ParseName(name)
+	if !ok {
+		return nil, "", &services.RegistryError{Code: services.RegistryErrorNameInvalid, Message: "invalid repository name"}
+	}
+	repo, err := c.repos.FindByOwnerAndNameFold(owner, repoName)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var actions []string
+	if repo != nil {
+		actions, err = c.actions(creds.user, creds.token, repo)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	scope := "repository:" + name + ":" + action
+	if action == services.RegistryActionPush {
+		scope = "repository:" + name + ":pull,push"
+	}
+	if !slices.Contains(actions, action) {
+		if creds.user == nil {
+			return nil, "", &registryUnauthorized{scope: scope, message: "authentication required"}
+		}
+		if !slices.Contains(actions, services.RegistryActionPull) {
+			return nil, "", &services.RegistryError{Code: services.RegistryErrorNameUnknown, Message: "repository name not known to registry"}
+		}
+		return nil, "", &services.RegistryError{Code: services.RegistryErrorDenied, Message: "you may not " + action + " this image"}
+	}
+	if creds.registryToken != nil && !creds.registryToken.Allows(name, action) {
+		return nil, "", &registryUnauthorized{scope: scope, message: "the token doesn't grant " + action}
+	}
+	return repo, image, nil
+}
+
+// writeError responds with a registry error, asking for a token on 401 Unauthorized. Other errors are
+// returned.
+func (c *registryController) writeError(w http.ResponseWriter, r *http.Request, err error) error {
+	var registryErr *services.RegistryError
+	var unauthorized *registryUnauthorized
+	var httpErr httperror.HTTPError
+	status := 0
+	scope := ""
+	switch {
+	case errors.As(err, &unauthorized):
+		registryErr = &services.RegistryError{Code: services.RegistryErrorUnauthorized, Message: unauthorized.message}
+		status = http.StatusUnauthorized
+		scope = unauthorized.scope
+	case errors.As(err, &registryErr):
+		status = registryErrorStatuses[registryErr.Code]
+	case errors.As(err, &httpErr):
+		code := services.RegistryErrorUnsupported
+		switch httpErr.StatusCode {
+		case http.StatusUnauthorized:
+			code = services.RegistryErrorUnauthorized
+		case http.StatusForbidden:
+			code = services.RegistryErrorDenied
+		}
+		registryErr = &services.RegistryError{Code: code, Message: httpErr.Message}
+		status = httpErr.StatusCode
+	default:
+		return err
+	}
+
+	if status == http.StatusUnauthorized {
+		scheme := "http"
+		if httputil.IsHTTPS(r) {
+			scheme = "https"
+		}
+		challenge := fmt.Sprintf(`Bearer realm="%s://%s/v2/token",service=%q`, scheme, r.Host, r.Host)
+		if scope != "" {
+			challenge += fmt.Sprintf(",scope=%q", scope)
+		}
+		w.Header().Set("WWW-Authenticate", challenge)
+	}
+	return writeRegistryJSON(w, status, "application/json", map[string]any{
+		"errors": []*services.RegistryError{registryErr},
+	})
+}
+
+func writeRegistryJSON(w http.ResponseWriter, status int, contentType string, body any) error {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(body)
+}
+
+// writeRegistryUpload responds with the state of a blob upload
+func writeRegistryUpload(w http.ResponseWriter, name, id string, size int64, status int) error {
+	w.Header().Set("Location", "/v2/"+name+"/blobs/uploads/"+id)
+	w.Header().Set("Range", fmt.Sprintf("0-%d", max(size-1, 0)))
+	w.Header().Set("Docker-Upload-UUID", id)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(status)
+	return nil
+}
+
+func writeRegistryBlobCreated(w http.ResponseWriter, name string, blob *models.RegistryBlob) error {
+	w.Header().Set("Location", "/v2/"+name+"/blobs/"+blob.Digest)
+	w.Header().Set("Docker-Content-Digest", blob.Digest)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusCreated)
+	return nil
+}
+
+// contentRangeStart returns where the chunk of a request starts, -1 without a Content-Range
+func contentRangeStart(r *http.Request) (int64, error) {
+	contentRange := strings.TrimPrefix(r.Header.Get("Content-Range"), "bytes ")
+	if contentRange == "" {
+		return -1, nil
+	}
+	start, _, _ := strings.Cut(contentRange, "-")
+	offset, err := strconv.ParseInt(start, 10, 64)
+	if err != nil || offset < 0 {
+		return 0, &services.RegistryError{Code: services.RegistryErrorBlobUploadInvalid, Message: "invalid Content-Range"}
+	}
+	return offset, nil
+}
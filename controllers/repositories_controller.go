@@ -4,9 +4,8 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
-	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/hypercommithq/hypercommit/database/models"
@@ -35,32 +34,50 @@ type RepositoriesController interface {
 type repositoriesController struct {
 	repos         repositories.RepositoriesRepository
 	users         repositories.UsersRepository
+	userEmails    repositories.UserEmailsRepository
 	contributors  repositories.ContributorsRepository
 	stars         repositories.StarsRepository
 	orgs          repositories.OrganizationsRepository
+	deletion      services.RepositoryDeletionService
 	authService   services.AuthService
+	twoFactor     services.TwoFactorService
 	gitService    services.GitService
+	audit         services.AuditService
+	webhookSvc    services.WebhookService
+	commitStatus  services.CommitStatusService
 	reposBasePath string
 }
 
 func NewRepositoriesController(
 	repos repositories.RepositoriesRepository,
 	users repositories.UsersRepository,
+	userEmails repositories.UserEmailsRepository,
 	contributors repositories.ContributorsRepository,
 	stars repositories.StarsRepository,
 	orgs repositories.OrganizationsRepository,
+	deletion services.RepositoryDeletionService,
 	authService services.AuthService,
+	twoFactor services.TwoFactorService,
 	gitService services.GitService,
+	audit services.AuditService,
+	webhookSvc services.WebhookService,
+	commitStatus services.CommitStatusService,
 	reposBasePath string,
 ) RepositoriesController {
 	return &repositoriesController{
 		repos:         repos,
 		users:         users,
+		userEmails:    userEmails,
 		contributors:  contributors,
 		stars:         stars,
 		orgs:          orgs,
+		deletion:      deletion,
 		authService:   authService,
+		twoFactor:     twoFactor,
 		gitService:    gitService,
+		audit:         audit,
+		webhookSvc:    webhookSvc,
+		commitStatus:  commitStatus,
 		reposBasePath: reposBasePath,
 	}
 }
@@ -145,7 +162,6 @@ func (c *repositoriesController) Store(w http.ResponseWriter, r *http.Request) e
 
 	// Determine if owner is user or organization
 	var repo *models.Repository
-	var ownerIDForPath string
 
 	if ownerUsername == user.Username {
 		// Check for existing repo under user
@@ -165,7 +181,6 @@ func (c *repositoriesController) Store(w http.ResponseWriter, r *http.Request) e
 			repoData.NameError = "Failed to create repository"
 			return pages.NewRepository(r, repoData).Render(w, r)
 		}
-		ownerIDForPath = fmt.Sprintf("%d", user.ID)
 	} else {
 		// Find organization
 		org, err := c.orgs.FindByUsername(ownerUsername)
@@ -179,6 +194,15 @@ func (c *repositoriesController) Store(w http.ResponseWriter, r *http.Request) e
 			return pages.NewRepository(r, repoData).Render(w, r)
 		}
 
+		missing, err := missingRequiredTwoFactor(org, user.ID, c.twoFactor)
+		if err != nil {
+			return err
+		}
+		if missing {
+			repoData.NameError = org.DisplayName + " requires two-factor authentication. Enable it in your settings first."
+			return pages.NewRepository(r, repoData).Render(w, r)
+		}
+
 		// Check for existing repo under org
 		existingRepo, err := c.repos.FindByOrgAndName(org.ID, name)
 		if err != nil {
@@ -196,7 +220,6 @@ func (c *repositoriesController) Store(w http.ResponseWriter, r *http.Request) e
 			repoData.NameError = "Failed to create repository"
 			return pages.NewRepository(r, repoData).Render(w, r)
 		}
-		ownerIDForPath = fmt.Sprintf("org_%d", org.ID)
 	}
 
 	// Create admin contributor
@@ -205,27 +228,16 @@ func (c *repositoriesController) Store(w http.ResponseWriter, r *http.Request) e
 		slog.Error("failed to create admin contributor", "error", err)
 	}
 
-	repoPath := filepath.Join(c.reposBasePath, ownerIDForPath, fmt.Sprintf("%d", repo.ID))
-	if err := os.MkdirAll(repoPath, 0755); err != nil {
-		slog.Error("failed to create repository directory", "error", err)
-		return httperror.New(http.StatusInternalServerError, "failed to create repository")
-	}
-
-	cmd := exec.Command("git", "init", "--bare")
-	cmd.Dir = repoPath
-	if err := cmd.Run(); err != nil {
+	if err := c.gitService.InitRepository(repo); err != nil {
 		slog.Error("failed to initialize git repository", "error", err)
 		return httperror.New(http.StatusInternalServerError, "failed to initialize repository")
 	}
 
-	configCmd := exec.Command("git", "config", "http.receivepack", "true")
-	configCmd.Dir = repoPath
-	if err := configCmd.Run(); err != nil {
-		slog.Error("failed to configure git repository", "error", err)
-		return httperror.New(http.StatusInternalServerError, "failed to configure repository")
-	}
-
 	slog.Info("repository created", "owner", ownerUsername, "name", name, "visibility", visibility, "creator", user.Username)
+	c.audit.Record(r, user, models.AuditRepositoryCreate, services.RepositoryAuditTarget(ownerUsername, repo), map[string]string{
+		"visibility": visibility,
+	})
+	c.webhookSvc.Repository(repo, "created", user)
 
 	http.Redirect(w, r, fmt.Sprintf("/%s/%s", ownerUsername, name), http.StatusSeeOther)
 	return nil
@@ -500,23 +512,59 @@ func (c *repositoriesController) Tree(w http.ResponseWriter, r *http.Request) er
 		entries = []services.TreeEntry{}
 	}
 
+	// Latest commit of the branch, who wrote it and what CI reported for it
+	var headCommit *services.Commit
+	var headCommitAuthor *models.User
+	var headStatus *services.CombinedStatus
+	commits, err := c.gitService.ListCommits(repoPath, "refs/heads/"+ref, nil, 1)
+	if err != nil {
+		slog.Error("failed to read latest commit", "error", err, "ref", ref)
+	} else if len(commits) > 0 {
+		headCommit = &commits[0]
+		headStatus, err = c.commitStatus.Combined(repo.ID, headCommit.ID)
+		if err != nil {
+			slog.Error("failed to find commit statuses", "error", err, "sha", headCommit.ID)
+		}
+		headCommitAuthor, err = c.commitAuthor(headCommit)
+		if err != nil {
+			slog.Error("failed to find commit author", "error", err, "sha", headCommit.ID)
+		}
+	}
+
 	data := &pages.RepositoryTreeData{
-		User:          user,
-		Repository:    repo,
-		OwnerUsername: owner,
-		CanManage:     canManage,
-		StarCount:     starCount,
-		HasStarred:    hasStarred,
-		Branches:      branches,
-		CurrentBranch: ref,
-		CurrentPath:   treePath,
-		Entries:       entries,
-		IsEmpty:       len(branches) == 0,
+		User:             user,
+		Repository:       repo,
+		OwnerUsername:    owner,
+		CanManage:        canManage,
+		StarCount:        starCount,
+		HasStarred:       hasStarred,
+		Branches:         branches,
+		CurrentBranch:    ref,
+		CurrentPath:      treePath,
+		Entries:          entries,
+		IsEmpty:          len(branches) == 0,
+		HeadCommit:       headCommit,
+		HeadCommitAuthor: headCommitAuthor,
+		HeadStatus:       headStatus,
 	}
 
 	return pages.RepositoryTree(r, data).Render(w, r)
 }
 
+// commitAuthor returns the user who verified the author email of the commit, nil if nobody did.
+// Unverified addresses don't count, anyone can put any address in their commits.
+func (c *repositoriesController) commitAuthor(commit *services.Commit) (*models.User, error) {
+	userIDs, err := c.userEmails.FindUserIDsByVerifiedEmails([]string{commit.AuthorEmail})
+	if err != nil {
+		return nil, err
+	}
+	userID, ok := userIDs[strings.ToLower(commit.AuthorEmail)]
+	if !ok {
+		return nil, nil
+	}
+	return c.users.FindByID(userID)
+}
+
 func (c *repositoriesController) Settings(w http.ResponseWriter, r *http.Request) error {
 	owner := chi.URLParam(r, "owner")
 	repoName := chi.URLParam(r, "repo")
@@ -674,6 +722,7 @@ func (c *repositoriesController) UpdateSettings(w http.ResponseWriter, r *http.R
 	}
 
 	// Update repository
+	previous := *repo
 	repo.Name = name
 	repo.DefaultBranch = defaultBranch
 	repo.Visibility = visibility
@@ -684,6 +733,9 @@ func (c *repositoriesController) UpdateSettings(w http.ResponseWriter, r *http.R
 		return pages.RepositorySettings(r, settingsData).Render(w, r)
 	}
 
+	auditRepositorySettingsChange(c.audit, r, user, owner, &previous, repo)
+	c.webhookSvc.RepositoryUpdated(&previous, repo, user)
+
 	settingsData.GeneralSuccess = "Settings updated successfully!"
 
 	// If name changed, redirect to new URL
@@ -728,26 +780,13 @@ func (c *repositoriesController) Delete(w http.ResponseWriter, r *http.Request)
 		return httperror.Forbidden("access denied")
 	}
 
-	// Delete from database
-	if err := c.repos.Delete(repo.ID); err != nil {
+	if err := c.deletion.Delete(repo, user); err != nil {
 		slog.Error("failed to delete repository", "error", err)
 		return httperror.New(http.StatusInternalServerError, "failed to delete repository")
 	}
 
-	// Delete repository directory
-	var ownerIDForPath string
-	if repo.OwnerUserID != nil {
-		ownerIDForPath = fmt.Sprintf("%d", *repo.OwnerUserID)
-	} else if repo.OwnerOrgID != nil {
-		ownerIDForPath = fmt.Sprintf("org_%d", *repo.OwnerOrgID)
-	}
-
-	repoPath := filepath.Join(c.reposBasePath, ownerIDForPath, fmt.Sprintf("%d", repo.ID))
-	if err := os.RemoveAll(repoPath); err != nil {
-		slog.Error("failed to delete repository directory", "error", err)
-	}
-
 	slog.Info("repository deleted", "owner", owner, "name", repoName)
+	c.audit.Record(r, user, models.AuditRepositoryDelete, services.RepositoryAuditTarget(owner, repo), nil)
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 	return nil
@@ -795,6 +834,7 @@ func (c *repositoriesController) Star(w http.ResponseWriter, r *http.Request) er
 	}
 
 	slog.Info("repository starred", "user", user.Username, "repo", repoName)
+	c.webhookSvc.Star(repo, "created", user)
 
 	referer := r.Header.Get("Referer")
 	if referer == "" {
@@ -822,6 +862,11 @@ func (c *repositoriesController) Unstar(w http.ResponseWriter, r *http.Request)
 		return httperror.Unauthorized("authentication required")
 	}
 
+	existingStar, err := c.stars.FindByUserAndRepository(repo.ID, user.ID)
+	if err != nil {
+		slog.Error("failed to check existing star", "error", err)
+	}
+
 	// Delete star
 	err = c.stars.Delete(repo.ID, user.ID)
 	if err != nil {
@@ -830,6 +875,9 @@ func (c *repositoriesController) Unstar(w http.ResponseWriter, r *http.Request)
 	}
 
 	slog.Info("repository unstarred", "user", user.Username, "repo", repoName)
+	if existingStar != nil {
+		c.webhookSvc.Star(repo, "deleted", user)
+	}
 
 	referer := r.Header.Get("Referer")
 	if referer == "" {
@@ -887,6 +935,22 @@ func (c *repositoriesController) AddCollaborator(w http.ResponseWriter, r *http.
 		return nil
 	}
 
+	// Organizations that require two-factor authentication only accept collaborators who have it
+	if repo.OwnerOrgID != nil {
+		org, err := c.orgs.FindByID(*repo.OwnerOrgID)
+		if err != nil {
+			return err
+		}
+		missing, err := missingRequiredTwoFactor(org, collabUser.ID, c.twoFactor)
+		if err != nil {
+			return err
+		}
+		if missing {
+			http.Redirect(w, r, fmt.Sprintf("/%s/%s/settings?collaborator_error=The+organization+requires+collaborators+to+use+two-factor+authentication", owner, repoName), http.StatusSeeOther)
+			return nil
+		}
+	}
+
 	// Check if user is already a collaborator
 	existing, _ := c.contributors.FindByRepositoryAndUser(repo.ID, collabUser.ID)
 	if existing != nil {
@@ -903,6 +967,10 @@ func (c *repositoriesController) AddCollaborator(w http.ResponseWriter, r *http.
 	}
 
 	slog.Info("collaborator added", "repo", repoName, "username", username, "role", role)
+	c.audit.Record(r, user, models.AuditCollaboratorAdd, services.RepositoryAuditTarget(owner, repo), map[string]string{
+		"user": collabUser.Username,
+		"role": role,
+	})
 	http.Redirect(w, r, fmt.Sprintf("/%s/%s/settings?collaborator_success=Collaborator+added+successfully", owner, repoName), http.StatusSeeOther)
 	return nil
 }
@@ -944,6 +1012,11 @@ func (c *repositoriesController) RemoveCollaborator(w http.ResponseWriter, r *ht
 	var userID int64
 	fmt.Sscanf(userIDStr, "%d", &userID)
 
+	collabUser, err := c.users.FindByID(userID)
+	if err != nil {
+		return err
+	}
+
 	// Remove collaborator
 	err = c.contributors.DeleteByRepositoryAndUser(repo.ID, userID)
 	if err != nil {
@@ -953,6 +1026,11 @@ func (c *repositoriesController) RemoveCollaborator(w http.ResponseWriter, r *ht
 	}
 
 	slog.Info("collaborator removed", "repo", repoName, "user_id", userID)
+	if collabUser != nil {
+		c.audit.Record(r, user, models.AuditCollaboratorRemove, services.RepositoryAuditTarget(owner, repo), map[string]string{
+			"user": collabUser.Username,
+		})
+	}
 	http.Redirect(w, r, fmt.Sprintf("/%s/%s/settings?collaborator_success=Collaborator+removed+successfully", owner, repoName), http.StatusSeeOther)
 	return nil
 }
@@ -1012,6 +1090,40 @@ func (c *repositoriesController) UpdateCollaboratorRole(w http.ResponseWriter, r
 	}
 
 	slog.Info("collaborator role updated", "repo", repoName, "user_id", userID, "new_role", role)
+	metadata := map[string]string{
+		"previous_role": contributor.Role,
+		"role":          role,
+	}
+	if collabUser, err := c.users.FindByID(userID); err == nil && collabUser != nil {
+		metadata["user"] = collabUser.Username
+	}
+	c.audit.Record(r, user, models.AuditCollaboratorRoleChange, services.RepositoryAuditTarget(owner, repo), metadata)
 	http.Redirect(w, r, fmt.Sprintf("/%s/%s/settings?collaborator_success=Role+updated+successfully", owner, repoName), http.StatusSeeOther)
 	return nil
 }
+
+// auditRepositorySettingsChange records a change of visibility on its own, as it exposes or hides the
+// repository, and any other changed setting as an update
+func auditRepositorySettingsChange(audit services.AuditService, r *http.Request, user *models.User, owner string, previous, repo *models.Repository) {
+	target := services.RepositoryAuditTarget(owner, repo)
+
+	if previous.Visibility != repo.Visibility {
+		audit.Record(r, user, models.AuditRepositoryVisibilityChange, target, map[string]string{
+			"previous_visibility": previous.Visibility,
+			"visibility":          repo.Visibility,
+		})
+	}
+
+	changes := map[string]string{}
+	if previous.Name != repo.Name {
+		changes["previous_name"] = previous.Name
+		changes["name"] = repo.Name
+	}
+	if previous.DefaultBranch != repo.DefaultBranch {
+		changes["previous_default_branch"] = previous.DefaultBranch
+		changes["default_branch"] = repo.DefaultBranch
+	}
+	if len(changes) > 0 {
+		audit.Record(r, user, models.AuditRepositoryUpdate, target, changes)
+	}
+}
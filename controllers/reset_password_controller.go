@@ -1,22 +1,162 @@
 package controllers
 
-import "net/http"
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
 
 type ResetPasswordController interface {
 	Show(w http.ResponseWriter, r *http.Request) error
 	Handle(w http.ResponseWriter, r *http.Request) error
 }
 
-type resetPasswordController struct{}
+type resetPasswordController struct {
+	users          repositories.UsersRepository
+	passwordResets repositories.PasswordResetsRepository
+	accessTokens   repositories.AccessTokensRepository
+	sessions       repositories.SessionsRepository
+	authService    services.AuthService
+}
 
-func NewResetPasswordController() ResetPasswordController {
-	return &resetPasswordController{}
+func NewResetPasswordController(users repositories.UsersRepository, passwordResets repositories.PasswordResetsRepository, accessTokens repositories.AccessTokensRepository, sessions repositories.SessionsRepository, authService services.AuthService) ResetPasswordController {
+	return &resetPasswordController{
+		users:          users,
+		passwordResets: passwordResets,
+		accessTokens:   accessTokens,
+		sessions:       sessions,
+		authService:    authService,
+	}
 }
 
 func (c *resetPasswordController) Show(w http.ResponseWriter, r *http.Request) error {
-	return nil
+	rawToken := r.URL.Query().Get("token")
+
+	token, err := c.findToken(rawToken)
+	if err != nil {
+		return err
+	}
+
+	return pages.ResetPassword(r, &pages.ResetPasswordData{
+		Token:              rawToken,
+		InvalidToken:       token == nil,
+		RevokeAccessTokens: true,
+	}).Render(w, r)
 }
 
 func (c *resetPasswordController) Handle(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	rawToken := r.FormValue("token")
+	password := r.FormValue("password")
+	confirmPassword := r.FormValue("confirm_password")
+	revokeAccessTokens := r.FormValue("revoke_access_tokens") == "1"
+
+	token, err := c.findToken(rawToken)
+	if err != nil {
+		return err
+	}
+	if token == nil {
+		return pages.ResetPassword(r, &pages.ResetPasswordData{
+			InvalidToken: true,
+		}).Render(w, r)
+	}
+
+	data := &pages.ResetPasswordData{
+		Token:              rawToken,
+		RevokeAccessTokens: revokeAccessTokens,
+	}
+	hasErrors := false
+
+	if password == "" {
+		data.PasswordError = "New password is required"
+		hasErrors = true
+	} else if len(password) < 8 {
+		data.PasswordError = "Password must be at least 8 characters"
+		hasErrors = true
+	}
+
+	if confirmPassword == "" {
+		data.ConfirmPasswordError = "Please confirm your new password"
+		hasErrors = true
+	} else if password != confirmPassword {
+		data.ConfirmPasswordError = "Passwords do not match"
+		hasErrors = true
+	}
+
+	if hasErrors {
+		return pages.ResetPassword(r, data).Render(w, r)
+	}
+
+	user, err := c.users.FindByID(token.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return pages.ResetPassword(r, &pages.ResetPasswordData{
+			InvalidToken: true,
+		}).Render(w, r)
+	}
+
+	// Consume the token before changing anything, so it can only ever be redeemed once
+	consumed, err := c.passwordResets.ConsumeToken(token.ID)
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return pages.ResetPassword(r, &pages.ResetPasswordData{
+			InvalidToken: true,
+		}).Render(w, r)
+	}
+
+	hashedPassword, err := c.authService.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	user.Password = &hashedPassword
+	if err := c.users.Update(user); err != nil {
+		return err
+	}
+
+	// Any other links that are still out there shouldn't work after the password changed
+	if err := c.passwordResets.InvalidateTokensForUser(user.ID); err != nil {
+		return err
+	}
+
+	// Whoever knew the old password shouldn't stay signed in
+	if err := c.sessions.DeleteAllByUserID(user.ID); err != nil {
+		return err
+	}
+	if err := c.authService.EndSession(w, r); err != nil {
+		return err
+	}
+
+	if revokeAccessTokens {
+		if err := c.accessTokens.DeleteAllByUserID(user.ID); err != nil {
+			return err
+		}
+	}
+
+	http.Redirect(w, r, "/auth/sign-in?reset=1", http.StatusSeeOther)
 	return nil
 }
+
+// findToken looks up an unused, unexpired reset token by its raw value
+func (c *resetPasswordController) findToken(rawToken string) (*models.PasswordResetToken, error) {
+	if rawToken == "" {
+		return nil, nil
+	}
+
+	tokenHash := fmt.Sprintf("%x", sha256.Sum256([]byte(rawToken)))
+	return c.passwordResets.FindValidToken(tokenHash, time.Now().Unix())
+}
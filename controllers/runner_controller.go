@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+const (
+	// maxRunnerNameLength bounds the names runners give themselves
+	maxRunnerNameLength = 100
+	// maxRunnerLogChunk bounds the output a runner sends at once
+	maxRunnerLogChunk = 1 << 20
+)
+
+// RunnerController is the API of /api/runner that runners use to execute workflow jobs. Runners
+// authenticate with the runner token of the instance, there is no API without one.
+type RunnerController interface {
+	// Claim hands the oldest queued job to the runner, or responds with 204 No Content if none is queued
+	Claim(w http.ResponseWriter, r *http.Request) error
+	// Archive responds with the files of the commit the job runs on, as a tar archive
+	Archive(w http.ResponseWriter, r *http.Request) error
+	// Log appends the request body to the log of the job and tells the runner if the job was cancelled
+	Log(w http.ResponseWriter, r *http.Request) error
+	Finish(w http.ResponseWriter, r *http.Request) error
+}
+
+type runnerController struct {
+	runs     repositories.WorkflowRunsRepository
+	repos    repositories.RepositoriesRepository
+	workflow services.WorkflowService
+	secrets  services.SecretService
+	git      services.GitService
+	token    string
+}
+
+func NewRunnerController(
+	runs repositories.WorkflowRunsRepository,
+	repos repositories.RepositoriesRepository,
+	workflow services.WorkflowService,
+	secrets services.SecretService,
+	git services.GitService,
+	token string,
+) RunnerController {
+	return &runnerController{
+		runs:     runs,
+		repos:    repos,
+		workflow: workflow,
+		secrets:  secrets,
+		git:      git,
+		token:    token,
+	}
+}
+
+type runnerJobJSON struct {
+	ID        int64                    `json:"id"`
+	RunID     int64                    `json:"run_id"`
+	RunNumber int64                    `json:"run_number"`
+	Workflow  string                   `json:"workflow"`
+	Ref       string                   `json:"ref"`
+	SHA       string                   `json:"sha"`
+	Job       services.WorkflowJobSpec `json:"job"`
+	// Secrets are set as environment variables of the steps and masked in their output
+	Secrets map[string]string `json:"secrets,omitempty"`
+}
+
+type runnerClaimRequest struct {
+	Name string `json:"name"`
+}
+
+type runnerLogResponse struct {
+	Cancelled bool `json:"cancelled"`
+}
+
+type runnerFinishRequest struct {
+	Conclusion string `json:"conclusion"`
+}
+
+func (c *runnerController) Claim(w http.ResponseWriter, r *http.Request) error {
+	if err := c.authenticate(r); err != nil {
+		return err
+	}
+
+	var request runnerClaimRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+	name := strings.TrimSpace(request.Name)
+	if name == "" || len(name) > maxRunnerNameLength {
+		return httperror.BadRequest("name must be 1 to 100 characters")
+	}
+
+	job, run, err := c.workflow.Claim(name)
+	if err != nil {
+		return err
+	}
+	if job == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	var spec services.WorkflowJobSpec
+	if err := json.Unmarshal([]byte(job.Definition), &spec); err != nil {
+		return err
+	}
+
+	secrets, err := c.secrets.ForJob(r, run, job, name)
+	if err != nil {
+		// The job was claimed already, fail it rather than leaving it to the stale job reaper
+		slog.Error("failed to read secrets of workflow job", "job", job.ID, "error", err)
+		if _, err := c.workflow.AppendLog(job, []byte("Failed to read the secrets of the repository.\n")); err != nil {
+			slog.Error("failed to append workflow log", "job", job.ID, "error", err)
+		}
+		if err := c.workflow.Finish(job, models.WorkflowConclusionFailure); err != nil {
+			return err
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, runnerJobJSON{
+		ID:        job.ID,
+		RunID:     run.ID,
+		RunNumber: run.Number,
+		Workflow:  run.Name,
+		Ref:       run.Ref,
+		SHA:       run.SHA,
+		Job:       spec,
+		Secrets:   secrets,
+	})
+}
+
+func (c *runnerController) Archive(w http.ResponseWriter, r *http.Request) error {
+	job, err := c.findRunningJob(r)
+	if err != nil {
+		return err
+	}
+
+	run, err := c.runs.FindRunByID(job.RunID)
+	if err != nil {
+		return err
+	}
+	repo, err := c.repos.FindByID(job.RepositoryID)
+	if err != nil {
+		return err
+	}
+	if run == nil || repo == nil {
+		return httperror.NotFound("repository not found")
+	}
+
+	w.Header().Set("Content-Type", "application/x-tar")
+	return c.git.Archive(c.git.RepositoryPath(repo), run.SHA, w)
+}
+
+func (c *runnerController) Log(w http.ResponseWriter, r *http.Request) error {
+	job, err := c.findJob(r)
+	if err != nil {
+		return err
+	}
+
+	chunk, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRunnerLogChunk))
+	if err != nil {
+		return httperror.New(http.StatusRequestEntityTooLarge, "log chunks must be at most 1 MiB")
+	}
+
+	running := false
+	if job.Status == models.WorkflowStatusRunning {
+		running, err = c.workflow.AppendLog(job, chunk)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeAPIJSON(w, r, http.StatusOK, runnerLogResponse{Cancelled: !running})
+}
+
+func (c *runnerController) Finish(w http.ResponseWriter, r *http.Request) error {
+	job, err := c.findJob(r)
+	if err != nil {
+		return err
+	}
+
+	var request runnerFinishRequest
+	if err := decodeAPIBody(w, r, &request); err != nil {
+		return err
+	}
+	switch request.Conclusion {
+	case models.WorkflowConclusionSuccess, models.WorkflowConclusionFailure, models.WorkflowConclusionCancelled:
+	default:
+		return httperror.BadRequest("conclusion must be success, failure or cancelled")
+	}
+
+	// A job cancelled in the meantime stays cancelled, Finish leaves completed jobs alone
+	if err := c.workflow.Finish(job, request.Conclusion); err != nil {
+		return err
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// authenticate checks the runner token the request carries as a bearer token
+func (c *runnerController) authenticate(r *http.Request) error {
+	if c.token == "" {
+		return httperror.NotFound("runners are disabled on this instance")
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(c.token)) != 1 {
+		return httperror.Unauthorized("invalid runner token")
+	}
+	return nil
+}
+
+// findJob returns the job of the {id} URL parameter, which a runner must have claimed
+func (c *runnerController) findJob(r *http.Request) (*models.WorkflowJob, error) {
+	if err := c.authenticate(r); err != nil {
+		return nil, err
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, httperror.NotFound("job not found")
+	}
+
+	job, err := c.runs.FindJobByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if job == nil || job.Status == models.WorkflowStatusQueued {
+		return nil, httperror.NotFound("job not found")
+	}
+	return job, nil
+}
+
+// findRunningJob returns the job of the {id} URL parameter, failing once the job completed
+func (c *runnerController) findRunningJob(r *http.Request) (*models.WorkflowJob, error) {
+	job, err := c.findJob(r)
+	if err != nil {
+		return nil, err
+	}
+	if job.Status != models.WorkflowStatusRunning {
+		return nil, httperror.New(http.StatusConflict, "job is not running")
+	}
+	return job, nil
+}
@@ -0,0 +1,324 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// SecretsController manages the workflow secrets of repositories, under /{owner}/{repo}/settings/secrets,
+// and of organizations, under /{owner}/settings/secrets. Values are write-only, pages only list names.
+type SecretsController interface {
+	Index(w http.ResponseWriter, r *http.Request) error
+	// Save creates the secret of the submitted name, or replaces its value if it exists
+	Save(w http.ResponseWriter, r *http.Request) error
+	Delete(w http.ResponseWriter, r *http.Request) error
+}
+
+type secretsController struct {
+	secrets      repositories.SecretsRepository
+	repos        repositories.RepositoriesRepository
+	contributors repositories.ContributorsRepository
+	stars        repositories.StarsRepository
+	orgs         repositories.OrganizationsRepository
+	orgMembers   repositories.OrganizationMembersRepository
+	secretSvc    services.SecretService
+	audit        services.AuditService
+}
+
+func NewSecretsController(
+	secrets repositories.SecretsRepository,
+	repos repositories.RepositoriesRepository,
+	contributors repositories.ContributorsRepository,
+	stars repositories.StarsRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	secretSvc services.SecretService,
+	audit services.AuditService,
+) SecretsController {
+	return &secretsController{
+		secrets:      secrets,
+		repos:        repos,
+		contributors: contributors,
+		stars:        stars,
+		orgs:         orgs,
+		orgMembers:   orgMembers,
+		secretSvc:    secretSvc,
+		audit:        audit,
+	}
+}
+
+// secretsOwner is the repository or organization whose secrets are being managed
+type secretsOwner struct {
+	user       *models.User
+	repository *models.Repository
+	// name is the full name of the repository or the name of the organization, for the audit log
+	name string
+	// orgID is the organization owning the secrets or their repository, for the audit log
+	orgID *int64
+	scope pages.SecretScope
+}
+
+func (c *secretsController) Index(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	data, err := c.pageData(owner)
+	if err != nil {
+		return err
+	}
+	data.Success = c.takeNotice(w, r)
+	return pages.Secrets(r, data).Render(w, r)
+}
+
+func (c *secretsController) Save(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	data, err := c.pageData(owner)
+	if err != nil {
+		return err
+	}
+	data.Name = r.FormValue("name")
+
+	name, err := c.secretSvc.NormalizeName(data.Name)
+	if err != nil {
+		data.Error = "Invalid name: " + err.Error()
+		return pages.Secrets(r, data).Render(w, r)
+	}
+
+	var secret *models.Secret
+	for _, existing := range data.Secrets {
+		if existing.Name == name {
+			secret = existing
+		}
+	}
+	created := secret == nil
+	if created {
+		secret = &models.Secret{Name: name, CreatorID: &owner.user.ID}
+		if owner.repository != nil {
+			secret.RepositoryID = &owner.repository.ID
+		} else {
+			secret.OrganizationID = &owner.scope.Organization.ID
+		}
+	}
+
+	if err := c.secretSvc.Seal(secret, r.FormValue("value")); err != nil {
+		if errors.Is(err, services.ErrInvalidSecretValue) {
+			data.Error = "Invalid value: " + err.Error()
+			return pages.Secrets(r, data).Render(w, r)
+		}
+		return err
+	}
+
+	if created {
+		secret, err = c.secrets.Create(secret)
+		if err != nil {
+			return err
+		}
+		c.audit.Record(r, owner.user, models.AuditSecretCreate, services.SecretAuditTarget(secret, owner.name, owner.orgID), nil)
+		c.setNotice(w, "Secret "+secret.Name+" created")
+	} else {
+		if err := c.secrets.UpdateValue(secret); err != nil {
+			return err
+		}
+		c.audit.Record(r, owner.user, models.AuditSecretUpdate, services.SecretAuditTarget(secret, owner.name, owner.orgID), nil)
+		c.setNotice(w, "Secret "+secret.Name+" updated")
+	}
+
+	http.Redirect(w, r, owner.scope.Base(), http.StatusSeeOther)
+	return nil
+}
+
+func (c *secretsController) Delete(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	secretID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid secret ID")
+	}
+
+	secret, err := c.secrets.FindByID(secretID)
+	if err != nil {
+		return err
+	}
+
+	var owned bool
+	if secret != nil && owner.repository != nil {
+		owned = secret.RepositoryID != nil && *secret.RepositoryID == owner.repository.ID
+	} else if secret != nil {
+		owned = secret.OrganizationID != nil && *secret.OrganizationID == owner.scope.Organization.ID
+	}
+	if !owned {
+		return httperror.NotFound("Secret not found")
+	}
+
+	if err := c.secrets.Delete(secret.ID); err != nil {
+		return err
+	}
+	c.audit.Record(r, owner.user, models.AuditSecretDelete, services.SecretAuditTarget(secret, owner.name, owner.orgID), nil)
+
+	c.setNotice(w, "Secret "+secret.Name+" deleted")
+	http.Redirect(w, r, owner.scope.Base(), http.StatusSeeOther)
+	return nil
+}
+
+// pageData lists the secrets of the owner, and for repositories of organizations the secrets they
+// inherit
+func (c *secretsController) pageData(owner *secretsOwner) (*pages.SecretsData, error) {
+	data := &pages.SecretsData{Scope: owner.scope}
+
+	var err error
+	if owner.repository == nil {
+		data.Secrets, err = c.secrets.FindAllByOrganization(owner.scope.Organization.ID)
+		return data, err
+	}
+
+	data.Secrets, err = c.secrets.FindAllByRepository(owner.repository.ID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.repository.OwnerOrgID != nil {
+		data.Inherited, err = c.secrets.FindAllByOrganization(*owner.repository.OwnerOrgID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// setNotice stores a message to show once on the next page
+func (c *secretsController) setNotice(w http.ResponseWriter, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "secret_success",
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+}
+
+// takeNotice returns and clears a message stored with setNotice
+func (c *secretsController) takeNotice(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie("secret_success")
+	if err != nil {
+		return ""
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   "secret_success",
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+	return cookie.Value
+}
+
+// findOwner returns the repository or organization of the URL if the signed in user administers it.
+// It redirects to sign in and returns nil for anonymous users.
+func (c *secretsController) findOwner(w http.ResponseWriter, r *http.Request) (*secretsOwner, error) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil, nil
+	}
+
+	ownerName := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+	if repoName == "" {
+		return c.findOrganizationOwner(r, user)
+	}
+
+	repo, err := c.repos.FindByOwnerAndName(ownerName, repoName)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return nil, httperror.NotFound("repository not found")
+	}
+
+	canManage := repo.OwnerUserID != nil && *repo.OwnerUserID == user.ID
+	if !canManage && repo.OwnerOrgID != nil {
+		membership, err := c.orgMembers.FindByOrganizationAndUser(*repo.OwnerOrgID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		canManage = membership != nil && membership.Role == models.OrganizationRoleOwner
+	}
+	if !canManage {
+		contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		canManage = contributor != nil && contributor.Role == "admin"
+	}
+	if !canManage {
+		return nil, httperror.Forbidden("access denied")
+	}
+
+	starCount, _ := c.stars.CountByRepository(repo.ID)
+	star, _ := c.stars.FindByUserAndRepository(repo.ID, user.ID)
+
+	return &secretsOwner{
+		user:       user,
+		repository: repo,
+		name:       ownerName + "/" + repo.Name,
+		orgID:      repo.OwnerOrgID,
+		scope: pages.SecretScope{
+			Repository:    repo,
+			OwnerUsername: ownerName,
+			StarCount:     starCount,
+			HasStarred:    star != nil,
+		},
+	}, nil
+}
+
+func (c *secretsController) findOrganizationOwner(r *http.Request, user *models.User) (*secretsOwner, error) {
+	ownerType, _ := middleware.GetOwnerType(r.Context())
+	if ownerType != middleware.OwnerTypeOrg {
+		return nil, httperror.NotFound("organization not found")
+	}
+
+	ownerID, _ := middleware.GetOwnerID(r.Context())
+	org, err := c.orgs.FindByID(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, httperror.NotFound("organization not found")
+	}
+
+	membership, err := c.orgMembers.FindByOrganizationAndUser(org.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil || membership.Role != models.OrganizationRoleOwner {
+		return nil, httperror.Forbidden("access denied")
+	}
+
+	return &secretsOwner{
+		user:  user,
+		name:  org.Username,
+		orgID: &org.ID,
+		scope: pages.SecretScope{Organization: org},
+	}, nil
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+type SessionsController interface {
+	Revoke(w http.ResponseWriter, r *http.Request) error
+	RevokeAll(w http.ResponseWriter, r *http.Request) error
+}
+
+type sessionsController struct {
+	sessions    repositories.SessionsRepository
+	authService services.AuthService
+}
+
+func NewSessionsController(sessions repositories.SessionsRepository, authService services.AuthService) SessionsController {
+	return &sessionsController{
+		sessions:    sessions,
+		authService: authService,
+	}
+}
+
+func (c *sessionsController) Revoke(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid session ID")
+	}
+
+	session, err := c.sessions.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if session == nil || session.UserID != user.ID {
+		return httperror.New(http.StatusNotFound, "Session not found")
+	}
+
+	currentSession, err := c.authService.CurrentSession(r)
+	if err != nil {
+		return err
+	}
+
+	// Revoking the session in use is the same as signing out
+	if currentSession != nil && currentSession.ID == session.ID {
+		if err := c.authService.EndSession(w, r); err != nil {
+			return err
+		}
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := c.sessions.Delete(session.ID); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "session_success",
+		Value:    "Session revoked",
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/settings#sessions", http.StatusSeeOther)
+	return nil
+}
+
+// RevokeAll signs the user out of every session, including the current one
+func (c *sessionsController) RevokeAll(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := c.sessions.DeleteAllByUserID(user.ID); err != nil {
+		return err
+	}
+	if err := c.authService.EndSession(w, r); err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+	return nil
+}
@@ -3,6 +3,7 @@ package controllers
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/hypercommithq/hypercommit/database/models"
 	"github.com/hypercommithq/hypercommit/database/repositories"
@@ -12,6 +13,9 @@ import (
 	"github.com/hypercommithq/hypercommit/views/pages"
 )
 
+// securityLogSize is how many recent events the security log in settings shows
+const securityLogSize = 50
+
 type SettingsController interface {
 	Show(w http.ResponseWriter, r *http.Request) error
 	UpdateGeneral(w http.ResponseWriter, r *http.Request) error
@@ -20,15 +24,43 @@ type SettingsController interface {
 
 type settingsController struct {
 	users        repositories.UsersRepository
+	userEmails   repositories.UserEmailsRepository
 	accessTokens repositories.AccessTokensRepository
+	repos        repositories.RepositoriesRepository
+	contributors repositories.ContributorsRepository
+	orgs         repositories.OrganizationsRepository
+	sessions     repositories.SessionsRepository
+	passkeys     repositories.PasskeysRepository
+	auditEvents  repositories.AuditEventsRepository
 	authService  services.AuthService
+	twoFactor    services.TwoFactorService
 }
 
-func NewSettingsController(users repositories.UsersRepository, accessTokens repositories.AccessTokensRepository, authService services.AuthService) SettingsController {
+func NewSettingsController(
+	users repositories.UsersRepository,
+	userEmails repositories.UserEmailsRepository,
+	accessTokens repositories.AccessTokensRepository,
+	repos repositories.RepositoriesRepository,
+	contributors repositories.ContributorsRepository,
+	orgs repositories.OrganizationsRepository,
+	sessions repositories.SessionsRepository,
+	passkeys repositories.PasskeysRepository,
+	auditEvents repositories.AuditEventsRepository,
+	authService services.AuthService,
+	twoFactor services.TwoFactorService,
+) SettingsController {
 	return &settingsController{
 		users:        users,
+		userEmails:   userEmails,
 		accessTokens: accessTokens,
+		repos:        repos,
+		contributors: contributors,
+		orgs:         orgs,
+		sessions:     sessions,
+		passkeys:     passkeys,
+		auditEvents:  auditEvents,
 		authService:  authService,
+		twoFactor:    twoFactor,
 	}
 }
 
@@ -48,6 +80,11 @@ func (c *settingsController) Show(w http.ResponseWriter, r *http.Request) error
 		tokens = []*models.AccessToken{}
 	}
 
+	tokenRepositories, err := listTokenRepositories(user, c.repos, c.contributors, c.users, c.orgs)
+	if err != nil {
+		return err
+	}
+
 	// Get flash messages from cookies
 	newToken := ""
 	tokenSuccess := ""
@@ -75,6 +112,36 @@ func (c *settingsController) Show(w http.ResponseWriter, r *http.Request) error
 		})
 	}
 
+	emails, err := c.userEmails.FindByUserID(user.ID)
+	if err != nil {
+		return err
+	}
+
+	emailSuccess := ""
+	emailError := ""
+
+	if cookie, err := r.Cookie("email_success"); err == nil {
+		emailSuccess = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "email_success",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	if cookie, err := r.Cookie("email_error"); err == nil {
+		emailError = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "email_error",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
 	if cookie, err := r.Cookie("access_token_error"); err == nil {
 		tokenError = cookie.Value
 		// Clear cookie
@@ -86,12 +153,127 @@ func (c *settingsController) Show(w http.ResponseWriter, r *http.Request) error
 		})
 	}
 
+	sessions, err := c.sessions.FindActiveByUserID(user.ID, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	currentSession, err := c.authService.CurrentSession(r)
+	if err != nil {
+		return err
+	}
+	var currentSessionID int64
+	if currentSession != nil {
+		currentSessionID = currentSession.ID
+	}
+
+	sessionSuccess := ""
+
+	if cookie, err := r.Cookie("session_success"); err == nil {
+		sessionSuccess = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "session_success",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	twoFactorEnabled, err := c.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return err
+	}
+
+	recoveryCodesLeft := 0
+	if twoFactorEnabled {
+		recoveryCodesLeft, err = c.twoFactor.RemainingRecoveryCodes(user.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	twoFactorSuccess := ""
+	twoFactorError := ""
+
+	if cookie, err := r.Cookie("two_factor_success"); err == nil {
+		twoFactorSuccess = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "two_factor_success",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	if cookie, err := r.Cookie("two_factor_error"); err == nil {
+		twoFactorError = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "two_factor_error",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	passkeys, err := c.passkeys.FindAllByUser(user.ID)
+	if err != nil {
+		return err
+	}
+
+	passkeySuccess := ""
+	passkeyError := ""
+
+	if cookie, err := r.Cookie("passkey_success"); err == nil {
+		passkeySuccess = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "passkey_success",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	if cookie, err := r.Cookie("passkey_error"); err == nil {
+		passkeyError = cookie.Value
+		// Clear cookie
+		http.SetCookie(w, &http.Cookie{
+			Name:   "passkey_error",
+			Value:  "",
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	securityLog, err := c.auditEvents.FindAllByUser(user.ID, securityLogSize)
+	if err != nil {
+		return err
+	}
+
 	return pages.Settings(r, &pages.SettingsData{
 		User:               user,
 		AccessTokens:       tokens,
 		NewAccessToken:     newToken,
 		AccessTokenSuccess: tokenSuccess,
 		AccessTokenError:   tokenError,
+		TokenRepositories:  tokenRepositories,
+		Emails:             emails,
+		EmailSuccess:       emailSuccess,
+		EmailError:         emailError,
+		Sessions:           sessions,
+		CurrentSessionID:   currentSessionID,
+		SessionSuccess:     sessionSuccess,
+		TwoFactorEnabled:   twoFactorEnabled,
+		RecoveryCodesLeft:  recoveryCodesLeft,
+		TwoFactorSuccess:   twoFactorSuccess,
+		TwoFactorError:     twoFactorError,
+		Passkeys:           passkeys,
+		PasskeySuccess:     passkeySuccess,
+		PasskeyError:       passkeyError,
+		SecurityLog:        securityLog,
 	}).Render(w, r)
 }
 
@@ -220,6 +402,20 @@ func (c *settingsController) UpdatePassword(w http.ResponseWriter, r *http.Reque
 		return err
 	}
 
-	settingsData.PasswordSuccess = "Password updated successfully"
+	// Sign out every other device, only the one that changed the password stays signed in
+	currentSession, err := c.authService.CurrentSession(r)
+	if err != nil {
+		return err
+	}
+	if currentSession != nil {
+		err = c.sessions.DeleteOthersByUserID(user.ID, currentSession.ID)
+	} else {
+		err = c.sessions.DeleteAllByUserID(user.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	settingsData.PasswordSuccess = "Password updated successfully. All other sessions were signed out."
 	return pages.Settings(r, settingsData).Render(w, r)
 }
@@ -1,34 +1,72 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/hypercommithq/hypercommit/database/models"
 	"github.com/hypercommithq/hypercommit/database/repositories"
 	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/httputil"
 	"github.com/hypercommithq/hypercommit/services"
 	"github.com/hypercommithq/hypercommit/views/pages"
 )
 
+// accountSuspendedMessage is shown instead of signing in users that an administrator suspended
+const accountSuspendedMessage = "This account has been suspended. Contact the administrator of this instance."
+
 type SignInController interface {
 	Show(w http.ResponseWriter, r *http.Request) error
 	Handle(w http.ResponseWriter, r *http.Request) error
+	ShowTwoFactor(w http.ResponseWriter, r *http.Request) error
+	HandleTwoFactor(w http.ResponseWriter, r *http.Request) error
+	PasskeyOptions(w http.ResponseWriter, r *http.Request) error
+	HandlePasskey(w http.ResponseWriter, r *http.Request) error
+	TwoFactorPasskeyOptions(w http.ResponseWriter, r *http.Request) error
+	HandleTwoFactorPasskey(w http.ResponseWriter, r *http.Request) error
 }
 
 type signInController struct {
-	users       repositories.UsersRepository
-	authService services.AuthService
+	users          repositories.UsersRepository
+	passkeys       repositories.PasskeysRepository
+	authService    services.AuthService
+	twoFactor      services.TwoFactorService
+	passkeyService services.PasskeyService
+	throttle       services.AuthThrottleService
+	audit          services.AuditService
 }
 
-func NewSignInController(users repositories.UsersRepository, authService services.AuthService) SignInController {
+func NewSignInController(
+	users repositories.UsersRepository,
+	passkeys repositories.PasskeysRepository,
+	authService services.AuthService,
+	twoFactor services.TwoFactorService,
+	passkeyService services.PasskeyService,
+	throttle services.AuthThrottleService,
+	audit services.AuditService,
+) SignInController {
 	return &signInController{
-		users:       users,
-		authService: authService,
+		users:          users,
+		passkeys:       passkeys,
+		authService:    authService,
+		twoFactor:      twoFactor,
+		passkeyService: passkeyService,
+		throttle:       throttle,
+		audit:          audit,
 	}
 }
 
 func (c *signInController) Show(w http.ResponseWriter, r *http.Request) error {
-	return pages.SignIn(r, nil).Render(w, r)
+	data := &pages.SignInData{}
+	if r.URL.Query().Get("reset") == "1" {
+		data.Notice = "Your password has been reset. You can now sign in with your new password."
+	}
+
+	return pages.SignIn(r, data).Render(w, r)
 }
 
 func (c *signInController) Handle(w http.ResponseWriter, r *http.Request) error {
@@ -50,14 +88,277 @@ func (c *signInController) Handle(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
+	if wait := c.throttle.Check(r, user, email); wait > 0 {
+		httputil.SetRetryAfter(w, wait)
+		w.WriteHeader(http.StatusTooManyRequests)
+		return pages.SignIn(r, &pages.SignInData{
+			Error: tooManyAttempts(wait),
+		}).Render(w, r)
+	}
+
 	if user == nil || user.Password == nil || !c.authService.CheckPassword(password, *user.Password) {
+		if err := c.throttle.Failure(r, user, email); err != nil {
+			return err
+		}
+		target := services.LoginAuditTarget(email)
+		if user != nil {
+			target = services.UserAuditTarget(user)
+		}
+		c.audit.Record(r, nil, models.AuditSignInFailed, target, map[string]string{"method": "password"})
 		return pages.SignIn(r, &pages.SignInData{
 			Error: "Invalid email or password",
 		}).Render(w, r)
 	}
 
-	c.authService.SetUserCookie(w, r, user.ID)
+	if user.SuspendedAt != nil {
+		return pages.SignIn(r, &pages.SignInData{
+			Error: accountSuspendedMessage,
+		}).Render(w, r)
+	}
+
+	// Users with two-factor authentication get a session only after the second step
+	enabled, err := c.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return err
+	}
+	if enabled {
+		if err := c.twoFactor.BeginChallenge(w, r, user.ID); err != nil {
+			return err
+		}
+		http.Redirect(w, r, "/auth/sign-in/two-factor", http.StatusSeeOther)
+		return nil
+	}
+
+	c.throttle.Success(user, email)
+	if err := c.authService.StartSession(w, r, user.ID); err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditSignIn, services.UserAuditTarget(user), map[string]string{"method": "password"})
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 	return nil
 }
+
+func (c *signInController) ShowTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	challenge, err := c.twoFactor.PendingChallenge(r)
+	if err != nil {
+		return err
+	}
+	if challenge == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	return c.renderTwoFactor(w, r, challenge, "")
+}
+
+func (c *signInController) HandleTwoFactor(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	challenge, err := c.twoFactor.PendingChallenge(r)
+	if err != nil {
+		return err
+	}
+	if challenge == nil {
+		return pages.SignIn(r, &pages.SignInData{
+			Error: "Your sign-in has expired. Please sign in again.",
+		}).Render(w, r)
+	}
+
+	user, err := c.users.FindByID(challenge.UserID)
+	if err != nil {
+		return err
+	}
+	if wait := c.throttle.Check(r, user, ""); wait > 0 {
+		return c.renderTwoFactorThrottled(w, r, challenge, wait)
+	}
+
+	valid, err := c.twoFactor.Verify(challenge.UserID, r.FormValue("code"))
+	if err != nil {
+		return err
+	}
+
+	if !valid {
+		return c.failTwoFactor(w, r, challenge, user, "two_factor", "Invalid authentication code")
+	}
+
+	if err := c.twoFactor.EndChallenge(w, r, challenge); err != nil {
+		return err
+	}
+	c.throttle.Success(user, "")
+	if err := c.authService.StartSession(w, r, challenge.UserID); err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditSignIn, services.UserAuditTarget(user), map[string]string{"method": "two_factor"})
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+// PasskeyOptions returns the options for signing in with any passkey the browser knows about
+func (c *signInController) PasskeyOptions(w http.ResponseWriter, r *http.Request) error {
+	options, err := c.passkeyService.AuthenticationOptions(nil)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(options)
+}
+
+// HandlePasskey signs in with a passkey instead of a password. The authenticator verified
+// the user, so the passkey counts as both factors and no code is asked for.
+func (c *signInController) HandlePasskey(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	passkey, err := c.passkeyService.FinishAuthentication(nil, r.FormValue("credential"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidPasskey) {
+			return pages.SignIn(r, &pages.SignInData{
+				Error: "Your passkey could not be verified. Please try again.",
+			}).Render(w, r)
+		}
+		return err
+	}
+
+	user, err := c.users.FindByID(passkey.UserID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return httperror.Unauthorized("user not found")
+	}
+	if user.SuspendedAt != nil {
+		return pages.SignIn(r, &pages.SignInData{
+			Error: accountSuspendedMessage,
+		}).Render(w, r)
+	}
+
+	if err := c.authService.StartSession(w, r, passkey.UserID); err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditSignIn, services.UserAuditTarget(user), map[string]string{"method": "passkey"})
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+// TwoFactorPasskeyOptions returns the options for using a passkey of the user signing in as the second factor
+func (c *signInController) TwoFactorPasskeyOptions(w http.ResponseWriter, r *http.Request) error {
+	challenge, err := c.twoFactor.PendingChallenge(r)
+	if err != nil {
+		return err
+	}
+	if challenge == nil {
+		return httperror.Unauthorized("sign-in expired")
+	}
+
+	options, err := c.passkeyService.AuthenticationOptions(&challenge.UserID)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(options)
+}
+
+func (c *signInController) HandleTwoFactorPasskey(w http.ResponseWriter, r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	challenge, err := c.twoFactor.PendingChallenge(r)
+	if err != nil {
+		return err
+	}
+	if challenge == nil {
+		return pages.SignIn(r, &pages.SignInData{
+			Error: "Your sign-in has expired. Please sign in again.",
+		}).Render(w, r)
+	}
+
+	user, err := c.users.FindByID(challenge.UserID)
+	if err != nil {
+		return err
+	}
+	if wait := c.throttle.Check(r, user, ""); wait > 0 {
+		return c.renderTwoFactorThrottled(w, r, challenge, wait)
+	}
+
+	if _, err := c.passkeyService.FinishAuthentication(&challenge.UserID, r.FormValue("credential")); err != nil {
+		if errors.Is(err, services.ErrInvalidPasskey) {
+			return c.failTwoFactor(w, r, challenge, user, "two_factor_passkey", "Your passkey could not be verified")
+		}
+		return err
+	}
+
+	if err := c.twoFactor.EndChallenge(w, r, challenge); err != nil {
+		return err
+	}
+	c.throttle.Success(user, "")
+	if err := c.authService.StartSession(w, r, challenge.UserID); err != nil {
+		return err
+	}
+	c.audit.Record(r, user, models.AuditSignIn, services.UserAuditTarget(user), map[string]string{"method": "two_factor_passkey"})
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	return nil
+}
+
+// failTwoFactor counts a failed second factor, sending the user back to sign in once they ran out of attempts.
+// The failure also counts towards locking the account, so restarting the sign-in doesn't allow more guesses.
+func (c *signInController) failTwoFactor(w http.ResponseWriter, r *http.Request, challenge *models.TwoFactorChallenge, user *models.User, method, message string) error {
+	if err := c.throttle.Failure(r, user, ""); err != nil {
+		return err
+	}
+	if user != nil {
+		c.audit.Record(r, nil, models.AuditSignInFailed, services.UserAuditTarget(user), map[string]string{"method": method})
+	}
+
+	remaining, err := c.twoFactor.FailChallenge(challenge)
+	if err != nil {
+		return err
+	}
+	if !remaining {
+		if err := c.twoFactor.EndChallenge(w, r, nil); err != nil {
+			return err
+		}
+		return pages.SignIn(r, &pages.SignInData{
+			Error: "Too many failed attempts. Please sign in again.",
+		}).Render(w, r)
+	}
+
+	return c.renderTwoFactor(w, r, challenge, message)
+}
+
+func (c *signInController) renderTwoFactorThrottled(w http.ResponseWriter, r *http.Request, challenge *models.TwoFactorChallenge, wait time.Duration) error {
+	httputil.SetRetryAfter(w, wait)
+	w.WriteHeader(http.StatusTooManyRequests)
+	return c.renderTwoFactor(w, r, challenge, tooManyAttempts(wait))
+}
+
+func (c *signInController) renderTwoFactor(w http.ResponseWriter, r *http.Request, challenge *models.TwoFactorChallenge, message string) error {
+	passkeys, err := c.passkeys.CountByUser(challenge.UserID)
+	if err != nil {
+		return err
+	}
+
+	return pages.SignInTwoFactor(r, &pages.SignInTwoFactorData{
+		Error:       message,
+		HasPasskeys: passkeys > 0,
+	}).Render(w, r)
+}
+
+// tooManyAttempts tells the user how long to wait, in whole minutes past the first minute
+func tooManyAttempts(wait time.Duration) string {
+	if wait <= time.Minute {
+		return "Too many failed sign-in attempts. Try again in a minute."
+	}
+
+	minutes := int((wait + time.Minute - 1) / time.Minute)
+	return fmt.Sprintf("Too many failed sign-in attempts. Try again in %d minutes.", minutes)
+}
@@ -21,7 +21,10 @@ func NewSignOutController(authService services.AuthService) SignOutController {
 }
 
 func (c *signOutController) Handle(w http.ResponseWriter, r *http.Request) error {
-	c.authService.ClearUserCookie(w)
+	if err := c.authService.EndSession(w, r); err != nil {
+		return err
+	}
+
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 	return nil
 }
@@ -16,21 +16,32 @@ type SignUpController interface {
 }
 
 type signUpController struct {
-	users        repositories.UsersRepository
-	authService  services.AuthService
-	flashService services.FlashService
+	users             repositories.UsersRepository
+	userEmails        repositories.UserEmailsRepository
+	authService       services.AuthService
+	flashService      services.FlashService
+	emailVerification services.EmailVerificationService
+	instanceSettings  repositories.InstanceSettingsRepository
 }
 
-func NewSignUpController(users repositories.UsersRepository, authService services.AuthService, flashService services.FlashService) SignUpController {
+func NewSignUpController(users repositories.UsersRepository, userEmails repositories.UserEmailsRepository, authService services.AuthService, flashService services.FlashService, emailVerification services.EmailVerificationService, instanceSettings repositories.InstanceSettingsRepository) SignUpController {
 	return &signUpController{
-		users:        users,
-		authService:  authService,
-		flashService: flashService,
+		users:             users,
+		userEmails:        userEmails,
+		authService:       authService,
+		flashService:      flashService,
+		emailVerification: emailVerification,
+		instanceSettings:  instanceSettings,
 	}
 }
 
 func (c *signUpController) Show(w http.ResponseWriter, r *http.Request) error {
-	return pages.SignUp(r, nil).Render(w, r)
+	settings, err := c.instanceSettings.Find()
+	if err != nil {
+		return err
+	}
+
+	return pages.SignUp(r, &pages.SignUpData{Disabled: settings.SignUpDisabled}).Render(w, r)
 }
 
 func (c *signUpController) Handle(w http.ResponseWriter, r *http.Request) error {
@@ -38,6 +49,15 @@ func (c *signUpController) Handle(w http.ResponseWriter, r *http.Request) error
 		return httperror.New(http.StatusBadRequest, "Invalid form data")
 	}
 
+	settings, err := c.instanceSettings.Find()
+	if err != nil {
+		return err
+	}
+	if settings.SignUpDisabled {
+		w.WriteHeader(http.StatusForbidden)
+		return pages.SignUp(r, &pages.SignUpData{Disabled: true}).Render(w, r)
+	}
+
 	username := strings.TrimSpace(r.FormValue("username"))
 	email := strings.TrimSpace(r.FormValue("email"))
 	displayName := strings.TrimSpace(r.FormValue("display_name"))
@@ -78,16 +98,21 @@ func (c *signUpController) Handle(w http.ResponseWriter, r *http.Request) error
 		return pages.SignUp(r, signUpData).Render(w, r)
 	}
 
-	existingUser, err := c.users.FindByEmail(email)
+	existingEmail, err := c.userEmails.FindVerifiedByEmail(email)
 	if err != nil {
 		return err
 	}
-	if existingUser != nil {
+	// Primary addresses are unique among users even before they are verified
+	existingOwner, err := c.users.FindByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existingEmail != nil || existingOwner != nil {
 		signUpData.EmailError = "Email already in use"
 		return pages.SignUp(r, signUpData).Render(w, r)
 	}
 
-	existingUser, err = c.users.FindByUsername(username)
+	existingUser, err := c.users.FindByUsername(username)
 	if err != nil {
 		return err
 	}
@@ -106,7 +131,18 @@ func (c *signUpController) Handle(w http.ResponseWriter, r *http.Request) error
 		return err
 	}
 
-	c.authService.SetUserCookie(w, r, user.ID)
+	userEmail, err := c.userEmails.Create(user.ID, email, true, false)
+	if err != nil {
+		return err
+	}
+
+	if err := c.emailVerification.SendVerification(user, userEmail); err != nil {
+		return err
+	}
+
+	if err := c.authService.StartSession(w, r, user.ID); err != nil {
+		return err
+	}
 	c.flashService.Set(w, r, services.FlashCelebration)
 
 	http.Redirect(w, r, "/", http.StatusSeeOther)
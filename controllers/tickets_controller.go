@@ -1,9 +1,12 @@
 package controllers
 
 import (
+	"fmt"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/hypercommithq/hypercommit/database/models"
@@ -14,6 +17,9 @@ import (
 	"github.com/hypercommithq/hypercommit/views/pages"
 )
 
+// defaultLabelColor is used for labels created implicitly, e.g. from a ticket template
+const defaultLabelColor = "#6b7280"
+
 type TicketsController interface {
 	List(w http.ResponseWriter, r *http.Request) error
 	Show(w http.ResponseWriter, r *http.Request) error
@@ -22,32 +28,61 @@ type TicketsController interface {
 	Close(w http.ResponseWriter, r *http.Request) error
 	Reopen(w http.ResponseWriter, r *http.Request) error
 	CreateComment(w http.ResponseWriter, r *http.Request) error
+	SetMilestone(w http.ResponseWriter, r *http.Request) error
+	Subscription(w http.ResponseWriter, r *http.Request) error
 }
 
 type ticketsController struct {
-	tickets      repositories.TicketsRepository
-	repos        repositories.RepositoriesRepository
-	users        repositories.UsersRepository
-	stars        repositories.StarsRepository
-	contributors repositories.ContributorsRepository
-	authService  services.AuthService
+	*apiAccess
+	tickets       repositories.TicketsRepository
+	notifications repositories.NotificationsRepository
+	milestones    repositories.MilestonesRepository
+	stars         repositories.StarsRepository
+	authService   services.AuthService
+	templates     services.TicketTemplateService
+	notifier      services.NotificationService
+	webhooks      services.WebhookService
+	workflows     services.WorkflowService
+	reposBasePath string
 }
 
 func NewTicketsController(
 	tickets repositories.TicketsRepository,
+	notifications repositories.NotificationsRepository,
+	milestones repositories.MilestonesRepository,
 	repos repositories.RepositoriesRepository,
 	users repositories.UsersRepository,
 	stars repositories.StarsRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
 	contributors repositories.ContributorsRepository,
+	twoFactor services.TwoFactorService,
 	authService services.AuthService,
+	templates services.TicketTemplateService,
+	notifier services.NotificationService,
+	webhooks services.WebhookService,
+	workflows services.WorkflowService,
+	reposBasePath string,
 ) TicketsController {
 	return &ticketsController{
-		tickets:      tickets,
-		repos:        repos,
-		users:        users,
-		stars:        stars,
-		contributors: contributors,
-		authService:  authService,
+		apiAccess: &apiAccess{
+			repos:        repos,
+			users:        users,
+			orgs:         orgs,
+			orgMembers:   orgMembers,
+			contributors: contributors,
+			twoFactor:    twoFactor,
+		},
+		tickets:       tickets,
+		notifications: notifications,
+		milestones:    milestones,
+		stars:         stars,
+		authService:   authService,
+		templates:     templates,
+		notifier:      notifier,
+		webhooks:      webhooks,
+		workflows:     workflows,
+		reposBasePath: reposBasePath,
 	}
 }
 
@@ -55,45 +90,69 @@ func (c *ticketsController) List(w http.ResponseWriter, r *http.Request) error {
 	owner := chi.URLParam(r, "owner")
 	repoName := chi.URLParam(r, "repo")
 
-	repo, err := c.repos.FindByOwnerAndName(owner, repoName)
+	repo, permission, err := c.readableRepository(r)
 	if err != nil {
-		return httperror.New(500, "failed to find repository")
-	}
-	if repo == nil {
-		return httperror.NotFound("repository not found")
+		return err
 	}
 
 	currentUser := custommiddleware.GetUserFromContext(r)
 
-	// Get filter from query params (default to "open")
+	// Get filters from query params (default to "open")
+	query := r.URL.Query().Get("q")
+	filter := parseTicketQuery(query)
+
 	statusFilter := r.URL.Query().Get("status")
+	if statusFilter == "" {
+		statusFilter = filter.Status
+	}
 	if statusFilter == "" {
 		statusFilter = "open"
 	}
 
-	tickets, err := c.tickets.FindAllByRepository(repo.ID, statusFilter)
-	if err != nil {
-		slog.Error("failed to fetch tickets", "error", err)
+	var tickets []*models.Ticket
+	var openCount, closedCount int64
+
+	if filter.Milestone != "" {
+		milestone, err := c.milestones.FindByRepositoryAndTitle(repo.ID, filter.Milestone)
+		if err != nil {
+			slog.Error("failed to find milestone", "error", err)
+		}
+		if milestone != nil {
+			tickets, err = c.tickets.FindAllByMilestone(milestone.ID, statusFilter)
+			if err != nil {
+				slog.Error("failed to fetch tickets", "error", err)
+			}
+			openCount, _ = c.milestones.CountTickets(milestone.ID, "open")
+			closedCount, _ = c.milestones.CountTickets(milestone.ID, "closed")
+		}
+	} else {
+		tickets, err = c.tickets.FindAllByRepository(repo.ID, statusFilter)
+		if err != nil {
+			slog.Error("failed to fetch tickets", "error", err)
+		}
+		openCount, _ = c.tickets.CountByRepository(repo.ID, "open")
+		closedCount, _ = c.tickets.CountByRepository(repo.ID, "closed")
+	}
+	if tickets == nil {
 		tickets = []*models.Ticket{}
 	}
 
-	openCount, _ := c.tickets.CountByRepository(repo.ID, "open")
-	closedCount, _ := c.tickets.CountByRepository(repo.ID, "closed")
-
-	// Check permissions
-	canManage := false
-	if currentUser != nil {
-		if repo.OwnerUserID != nil && *repo.OwnerUserID == currentUser.ID {
-			canManage = true
-		} else {
-			// Check if user is an admin contributor
-			contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, currentUser.ID)
-			if err == nil && contributor != nil && contributor.Role == "admin" {
-				canManage = true
+	// Get milestones referenced by the listed tickets
+	milestones := make(map[int64]*models.Milestone)
+	for _, ticket := range tickets {
+		if ticket.MilestoneID == nil {
+			continue
+		}
+		if _, exists := milestones[*ticket.MilestoneID]; !exists {
+			milestone, err := c.milestones.FindByID(*ticket.MilestoneID)
+			if err == nil && milestone != nil {
+				milestones[*ticket.MilestoneID] = milestone
 			}
 		}
 	}
 
+	canManage := permission >= apiPermissionAdmin
+
 	// Get star info
 	starCount, _ := c.stars.CountByRepository(repo.ID)
 	hasStarred := false
@@ -110,6 +169,8 @@ func (c *ticketsController) List(w http.ResponseWriter, r *http.Request) error {
 		Repository:    repo,
 		OwnerUsername: owner,
 		Tickets:       tickets,
+		Milestones:    milestones,
+		Query:         query,
 		StatusFilter:  statusFilter,
 		OpenCount:     openCount,
 		ClosedCount:   closedCount,
@@ -131,12 +192,9 @@ func (c *ticketsController) Show(w http.ResponseWriter, r *http.Request) error {
 		return httperror.BadRequest("invalid ticket number")
 	}
 
-	repo, err := c.repos.FindByOwnerAndName(owner, repoName)
+	repo, permission, err := c.readableRepository(r)
 	if err != nil {
-		return httperror.New(500, "failed to find repository")
-	}
-	if repo == nil {
-		return httperror.NotFound("repository not found")
+		return err
 	}
 
 	ticket, err := c.tickets.FindByRepositoryAndNumber(repo.ID, number)
@@ -173,19 +231,7 @@ func (c *ticketsController) Show(w http.ResponseWriter, r *http.Request) error {
 
 	currentUser := custommiddleware.GetUserFromContext(r)
 
-	// Check permissions
-	canManage := false
-	if currentUser != nil {
-		if repo.OwnerUserID != nil && *repo.OwnerUserID == currentUser.ID {
-			canManage = true
-		} else {
-			// Check if user is an admin contributor
-			contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, currentUser.ID)
-			if err == nil && contributor != nil && contributor.Role == "admin" {
-				canManage = true
-			}
-		}
-	}
+	canManage := permission >= apiPermissionAdmin
 
 	// Get star info
 	starCount, _ := c.stars.CountByRepository(repo.ID)
@@ -195,6 +241,50 @@ func (c *ticketsController) Show(w http.ResponseWriter, r *http.Request) error {
 		hasStarred = star != nil
 	}
 
+	// Get milestone info
+	var milestone *models.Milestone
+	if ticket.MilestoneID != nil {
+		milestone, err = c.milestones.FindByID(*ticket.MilestoneID)
+		if err != nil {
+			slog.Error("failed to fetch ticket milestone", "error", err)
+		}
+	}
+
+	var openMilestones []*models.Milestone
+	if canManage {
+		openMilestones, err = c.milestones.FindAllByRepository(repo.ID, "open")
+		if err != nil {
+			slog.Error("failed to fetch milestones", "error", err)
+		}
+	}
+
+	// Get labels and assignees
+	labels, err := c.tickets.FindLabelsByTicket(ticket.ID)
+	if err != nil {
+		slog.Error("failed to fetch ticket labels", "error", err)
+	}
+
+	var assignees []*models.User
+	ticketAssignees, err := c.tickets.FindAssigneesByTicket(ticket.ID)
+	if err != nil {
+		slog.Error("failed to fetch ticket assignees", "error", err)
+	}
+	for _, assignee := range ticketAssignees {
+		user, err := c.users.FindByID(assignee.UserID)
+		if err == nil && user != nil {
+			assignees = append(assignees, user)
+		}
+	}
+
+	subscribed := false
+	if currentUser != nil {
+		subscription, err := c.notifications.FindSubscription(ticket.ID, currentUser.ID)
+		if err != nil {
+			slog.Error("failed to fetch ticket subscription", "error", err)
+		}
+		subscribed = subscription != nil && subscription.Subscribed
+	}
+
 	cloneURL := "https://" + r.Host + "/" + owner + "/" + repoName
 	repositoryURL := cloneURL
 
@@ -206,6 +296,11 @@ func (c *ticketsController) Show(w http.ResponseWriter, r *http.Request) error {
 		Author:         author,
 		Comments:       comments,
 		CommentAuthors: commentAuthors,
+		Milestone:      milestone,
+		Milestones:     openMilestones,
+		Labels:         labels,
+		Assignees:      assignees,
+		Subscribed:     subscribed,
 		CanManage:      canManage,
 		StarCount:      starCount,
 		HasStarred:     hasStarred,
@@ -218,12 +313,9 @@ func (c *ticketsController) New(w http.ResponseWriter, r *http.Request) error {
 	owner := chi.URLParam(r, "owner")
 	repoName := chi.URLParam(r, "repo")
 
-	repo, err := c.repos.FindByOwnerAndName(owner, repoName)
+	repo, permission, err := c.readableRepository(r)
 	if err != nil {
-		return httperror.New(500, "failed to find repository")
-	}
-	if repo == nil {
-		return httperror.NotFound("repository not found")
+		return err
 	}
 
 	currentUser := custommiddleware.GetUserFromContext(r)
@@ -232,30 +324,55 @@ func (c *ticketsController) New(w http.ResponseWriter, r *http.Request) error {
 		return nil
 	}
 
-	// Check permissions
-	canManage := false
-	if repo.OwnerUserID != nil && *repo.OwnerUserID == currentUser.ID {
-		canManage = true
-	} else {
-		// Check if user is an admin contributor
-		contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, currentUser.ID)
-		if err == nil && contributor != nil && contributor.Role == "admin" {
-			canManage = true
-		}
-	}
+	canManage := permission >= apiPermissionAdmin
 
 	// Get star info
 	starCount, _ := c.stars.CountByRepository(repo.ID)
 	star, _ := c.stars.FindByUserAndRepository(repo.ID, currentUser.ID)
 	hasStarred := star != nil
 
+	var openMilestones []*models.Milestone
+	if canManage {
+		openMilestones, err = c.milestones.FindAllByRepository(repo.ID, "open")
+		if err != nil {
+			slog.Error("failed to fetch milestones", "error", err)
+		}
+	}
+
 	cloneURL := "https://" + r.Host + "/" + owner + "/" + repoName
 	repositoryURL := cloneURL
 
+	// Offer the repository's ticket templates unless the user already picked one
+	// or explicitly asked for a blank ticket
+	var templates []services.TicketTemplate
+	var template *services.TicketTemplate
+	if file := r.URL.Query().Get("template"); file != "" {
+		template, err = c.templates.FindTemplate(c.repoPath(repo), repo.DefaultBranch, file)
+		if err != nil {
+			slog.Error("failed to load ticket template", "error", err)
+		}
+	} else if r.URL.Query().Get("blank") == "" {
+		templates, err = c.templates.ListTemplates(c.repoPath(repo), repo.DefaultBranch)
+		if err != nil {
+			slog.Error("failed to list ticket templates", "error", err)
+		}
+	}
+
+	var title, body string
+	if template != nil {
+		title = template.Title
+		body = template.Body
+	}
+
 	return pages.NewTicket(r, &pages.NewTicketData{
 		User:          currentUser,
 		Repository:    repo,
 		OwnerUsername: owner,
+		Title:         title,
+		Body:          body,
+		Templates:     templates,
+		Template:      template,
+		Milestones:    openMilestones,
 		CanManage:     canManage,
 		StarCount:     starCount,
 		HasStarred:    hasStarred,
@@ -268,12 +385,9 @@ func (c *ticketsController) Create(w http.ResponseWriter, r *http.Request) error
 	owner := chi.URLParam(r, "owner")
 	repoName := chi.URLParam(r, "repo")
 
-	repo, err := c.repos.FindByOwnerAndName(owner, repoName)
+	repo, permission, err := c.readableRepository(r)
 	if err != nil {
-		return httperror.New(500, "failed to find repository")
-	}
-	if repo == nil {
-		return httperror.NotFound("repository not found")
+		return err
 	}
 
 	currentUser := custommiddleware.GetUserFromContext(r)
@@ -288,24 +402,41 @@ func (c *ticketsController) Create(w http.ResponseWriter, r *http.Request) error
 
 	title := r.FormValue("title")
 	body := r.FormValue("body")
+	milestoneIDStr := r.FormValue("milestone_id")
+
+	var template *services.TicketTemplate
+	if file := r.FormValue("template"); file != "" {
+		template, err = c.templates.FindTemplate(c.repoPath(repo), repo.DefaultBranch, file)
+		if err != nil {
+			slog.Error("failed to load ticket template", "error", err)
+		}
+	}
 
 	var bodyPtr *string
 	if body != "" {
 		bodyPtr = &body
 	}
 
+	canManage := permission >= apiPermissionAdmin
+
+	// Only managers can put a ticket on a milestone
+	var milestoneID *int64
+	if canManage && milestoneIDStr != "" {
+		id, err := strconv.ParseInt(milestoneIDStr, 10, 64)
+		if err == nil {
+			milestone, err := c.milestones.FindByID(id)
+			if err == nil && milestone != nil && milestone.RepositoryID == repo.ID {
+				milestoneID = &milestone.ID
+			}
+		}
+	}
+
 	// Validate
 	if title == "" {
 		// Return to form with error
-		canManage := false
-		if repo.OwnerUserID != nil && *repo.OwnerUserID == currentUser.ID {
-			canManage = true
-		} else {
-			// Check if user is an admin contributor
-			contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, currentUser.ID)
-			if err == nil && contributor != nil && contributor.Role == "admin" {
-				canManage = true
-			}
+		var openMilestones []*models.Milestone
+		if canManage {
+			openMilestones, _ = c.milestones.FindAllByRepository(repo.ID, "open")
 		}
 		starCount, _ := c.stars.CountByRepository(repo.ID)
 		star, _ := c.stars.FindByUserAndRepository(repo.ID, currentUser.ID)
@@ -319,6 +450,9 @@ func (c *ticketsController) Create(w http.ResponseWriter, r *http.Request) error
 			OwnerUsername: owner,
 			Title:         title,
 			Body:          body,
+			Template:      template,
+			MilestoneID:   milestoneID,
+			Milestones:    openMilestones,
 			TitleError:    "Title is required",
 			CanManage:     canManage,
 			StarCount:     starCount,
@@ -334,6 +468,21 @@ func (c *ticketsController) Create(w http.ResponseWriter, r *http.Request) error
 		return httperror.New(500, "failed to create ticket")
 	}
 
+	if milestoneID != nil {
+		if err := c.tickets.SetMilestone(ticket.ID, milestoneID); err != nil {
+			slog.Error("failed to set ticket milestone", "error", err)
+		}
+	}
+
+	c.notifier.TicketOpened(ticket, currentUser)
+
+	if template != nil {
+		c.applyTemplate(ticket, repo, template, currentUser)
+	}
+
+	c.webhooks.Ticket(repo, ticket, "opened", currentUser)
+	c.workflows.Ticket(repo, ticket, "opened", currentUser)
+
 	http.Redirect(w, r, "/"+owner+"/"+repoName+"/tickets/"+strconv.FormatInt(ticket.Number, 10), http.StatusSeeOther)
 	return nil
 }
@@ -348,12 +497,9 @@ func (c *ticketsController) Close(w http.ResponseWriter, r *http.Request) error
 		return httperror.BadRequest("invalid ticket number")
 	}
 
-	repo, err := c.repos.FindByOwnerAndName(owner, repoName)
+	repo, _, err := c.readableRepository(r)
 	if err != nil {
-		return httperror.New(500, "failed to find repository")
-	}
-	if repo == nil {
-		return httperror.NotFound("repository not found")
+		return err
 	}
 
 	currentUser := custommiddleware.GetUserFromContext(r)
@@ -373,6 +519,16 @@ func (c *ticketsController) Close(w http.ResponseWriter, r *http.Request) error
 		return httperror.New(500, "failed to close ticket")
 	}
 
+	ticket.Status = "closed"
+	c.notifier.TicketStatusChanged(ticket, currentUser)
+
+	// Read it back for the closing time the database set
+	if closed, err := c.tickets.FindByID(ticket.ID); err == nil && closed != nil {
+		ticket = closed
+	}
+	c.webhooks.Ticket(repo, ticket, "closed", currentUser)
+	c.workflows.Ticket(repo, ticket, "closed", currentUser)
+
 	http.Redirect(w, r, "/"+owner+"/"+repoName+"/tickets/"+numberStr, http.StatusSeeOther)
 	return nil
 }
@@ -387,12 +543,9 @@ func (c *ticketsController) Reopen(w http.ResponseWriter, r *http.Request) error
 		return httperror.BadRequest("invalid ticket number")
 	}
 
-	repo, err := c.repos.FindByOwnerAndName(owner, repoName)
+	repo, _, err := c.readableRepository(r)
 	if err != nil {
-		return httperror.New(500, "failed to find repository")
-	}
-	if repo == nil {
-		return httperror.NotFound("repository not found")
+		return err
 	}
 
 	currentUser := custommiddleware.GetUserFromContext(r)
@@ -412,6 +565,13 @@ func (c *ticketsController) Reopen(w http.ResponseWriter, r *http.Request) error
 		return httperror.New(500, "failed to reopen ticket")
 	}
 
+	ticket.Status = "open"
+	ticket.ClosedAt = nil
+	ticket.ClosedByID = nil
+	c.notifier.TicketStatusChanged(ticket, currentUser)
+	c.webhooks.Ticket(repo, ticket, "reopened", currentUser)
+	c.workflows.Ticket(repo, ticket, "reopened", currentUser)
+
 	http.Redirect(w, r, "/"+owner+"/"+repoName+"/tickets/"+numberStr, http.StatusSeeOther)
 	return nil
 }
@@ -426,12 +586,9 @@ func (c *ticketsController) CreateComment(w http.ResponseWriter, r *http.Request
 		return httperror.BadRequest("invalid ticket number")
 	}
 
-	repo, err := c.repos.FindByOwnerAndName(owner, repoName)
+	repo, _, err := c.readableRepository(r)
 	if err != nil {
-		return httperror.New(500, "failed to find repository")
-	}
-	if repo == nil {
-		return httperror.NotFound("repository not found")
+		return err
 	}
 
 	currentUser := custommiddleware.GetUserFromContext(r)
@@ -456,12 +613,222 @@ func (c *ticketsController) CreateComment(w http.ResponseWriter, r *http.Request
 		return nil
 	}
 
-	_, err = c.tickets.CreateComment(ticket.ID, currentUser.ID, body)
+	comment, err := c.tickets.CreateComment(ticket.ID, currentUser.ID, body)
 	if err != nil {
 		slog.Error("failed to create comment", "error", err)
 		return httperror.New(500, "failed to create comment")
 	}
 
+	c.notifier.CommentCreated(ticket, comment, currentUser)
+	c.webhooks.TicketComment(repo, ticket, comment, "created", currentUser)
+
 	http.Redirect(w, r, "/"+owner+"/"+repoName+"/tickets/"+numberStr, http.StatusSeeOther)
 	return nil
 }
+
+func (c *ticketsController) SetMilestone(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+	numberStr := chi.URLParam(r, "number")
+
+	number, err := strconv.ParseInt(numberStr, 10, 64)
+	if err != nil {
+		return httperror.BadRequest("invalid ticket number")
+	}
+
+	repo, permission, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	canManage := permission >= apiPermissionAdmin
+
+	if !canManage {
+		return httperror.Forbidden("access denied")
+	}
+
+	ticket, err := c.tickets.FindByRepositoryAndNumber(repo.ID, number)
+	if err != nil || ticket == nil {
+		return httperror.NotFound("ticket not found")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form data")
+	}
+
+	// An empty milestone_id clears the milestone
+	var milestoneID *int64
+	if milestoneIDStr := r.FormValue("milestone_id"); milestoneIDStr != "" {
+		id, err := strconv.ParseInt(milestoneIDStr, 10, 64)
+		if err != nil {
+			return httperror.BadRequest("invalid milestone")
+		}
+
+		milestone, err := c.milestones.FindByID(id)
+		if err != nil || milestone == nil || milestone.RepositoryID != repo.ID {
+			return httperror.NotFound("milestone not found")
+		}
+		milestoneID = &milestone.ID
+	}
+
+	if err := c.tickets.SetMilestone(ticket.ID, milestoneID); err != nil {
+		slog.Error("failed to set ticket milestone", "error", err)
+		return httperror.New(500, "failed to set milestone")
+	}
+
+	http.Redirect(w, r, "/"+owner+"/"+repoName+"/tickets/"+numberStr, http.StatusSeeOther)
+	return nil
+}
+
+// ticketQuery holds the filters parsed from the ticket search box,
+// e.g. `is:closed milestone:"v1.2"`
+type ticketQuery struct {
+	Status    string
+	Milestone string
+}
+
+func parseTicketQuery(query string) ticketQuery {
+	var filter ticketQuery
+
+	for _, token := range tokenizeTicketQuery(query) {
+		key, value, ok := strings.Cut(token, ":")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "is":
+			if value == "open" || value == "closed" {
+				filter.Status = value
+			}
+		case "milestone":
+			filter.Milestone = value
+		}
+	}
+
+	return filter
+}
+
+// tokenizeTicketQuery splits a query on whitespace, keeping double-quoted
+// values together and stripping the quotes
+func tokenizeTicketQuery(query string) []string {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+
+	for _, ch := range query {
+		switch {
+		case ch == '"':
+			inQuotes = !inQuotes
+		case (ch == ' ' || ch == '\t') && !inQuotes:
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(ch)
+		}
+	}
+
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+
+	return tokens
+}
+
+// Subscription subscribes or unsubscribes the current user from a ticket
+func (c *ticketsController) Subscription(w http.ResponseWriter, r *http.Request) error {
+	owner := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+	numberStr := chi.URLParam(r, "number")
+
+	number, err := strconv.ParseInt(numberStr, 10, 64)
+	if err != nil {
+		return httperror.BadRequest("invalid ticket number")
+	}
+
+	repo, _, err := c.readableRepository(r)
+	if err != nil {
+		return err
+	}
+
+	currentUser := custommiddleware.GetUserFromContext(r)
+	if currentUser == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	ticket, err := c.tickets.FindByRepositoryAndNumber(repo.ID, number)
+	if err != nil || ticket == nil {
+		return httperror.NotFound("ticket not found")
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.BadRequest("invalid form data")
+	}
+
+	subscribed := r.FormValue("action") == "subscribe"
+	if err := c.notifications.SetSubscribed(ticket.ID, currentUser.ID, subscribed); err != nil {
+		slog.Error("failed to update ticket subscription", "error", err)
+		return httperror.New(500, "failed to update subscription")
+	}
+
+	http.Redirect(w, r, "/"+owner+"/"+repoName+"/tickets/"+numberStr, http.StatusSeeOther)
+	return nil
+}
+
+// applyTemplate adds the template's default labels and assignees to a freshly
+// created ticket. Missing labels are created, unknown users and users who can't
+// read the repository are skipped.
+func (c *ticketsController) applyTemplate(ticket *models.Ticket, repo *models.Repository, template *services.TicketTemplate, currentUser *models.User) {
+	for _, name := range template.Labels {
+		label, err := c.tickets.FindLabelByName(repo.ID, name)
+		if err != nil {
+			slog.Error("failed to find label", "error", err, "label", name)
+			continue
+		}
+		if label == nil {
+			label, err = c.tickets.CreateLabel(repo.ID, name, defaultLabelColor, nil)
+			if err != nil {
+				slog.Error("failed to create label", "error", err, "label", name)
+				continue
+			}
+		}
+		if err := c.tickets.AddLabel(ticket.ID, label.ID); err != nil {
+			slog.Error("failed to add label to ticket", "error", err, "label", name)
+		}
+	}
+
+	for _, username := range template.Assignees {
+		user, err := c.users.FindByUsername(username)
+		if err != nil || user == nil {
+			continue
+		}
+		if permission, err := c.permission(repo, user); err != nil || permission < apiPermissionRead {
+			continue
+		}
+		if err := c.tickets.AddAssignee(ticket.ID, user.ID, currentUser.ID); err != nil {
+			slog.Error("failed to assign ticket", "error", err, "assignee", username)
+			continue
+		}
+		c.notifier.TicketAssigned(ticket, user.ID, currentUser)
+	}
+}
+
+func (c *ticketsController) repoPath(repo *models.Repository) string {
+	var ownerIDForPath string
+	if repo.OwnerUserID != nil {
+		ownerIDForPath = fmt.Sprintf("%d", *repo.OwnerUserID)
+	} else if repo.OwnerOrgID != nil {
+		ownerIDForPath = fmt.Sprintf("org_%d", *repo.OwnerOrgID)
+	}
+
+	return filepath.Join(c.reposBasePath, ownerIDForPath, fmt.Sprintf("%d", repo.ID))
+}
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+func TestTicketTemplatesOfPrivateRepositoriesAreHidden(t *testing.T) {
+	db := newTestDB(t)
+	dir := t.TempDir()
+	access := newTestAPIAccess(db)
+	tickets := repositories.NewTicketsRepository(db.DB)
+	git := services.NewGitService(dir)
+	emails, err := services.NewEmailService(repositories.NewEmailOutboxRepository(db.DB), testPublicURL)
+	if err != nil {
+		t.Fatalf("email service: %v", err)
+	}
+	controller := NewTicketsController(
+		tickets,
+		repositories.NewNotificationsRepository(db.DB),
+		repositories.NewMilestonesRepository(db.DB),
+		access.repos,
+		access.users,
+		repositories.NewStarsRepository(db.DB),
+		access.orgs,
+		access.orgMembers,
+		access.contributors,
+		access.twoFactor,
+		services.NewAuthService(access.users, repositories.NewSessionsRepository(db.DB), "signing secret"),
+		services.NewTicketTemplateService(git),
+		services.NewNotificationService(repositories.NewNotificationsRepository(db.DB), access.users, repositories.NewUserEmailsRepository(db.DB), access.repos, access.orgs, access.orgMembers, access.contributors, access.twoFactor, emails, testPublicURL),
+		services.NewWebhookService(repositories.NewWebhooksRepository(db.DB), repositories.NewWebhookDeliveriesRepository(db.DB), access.repos, access.users, access.orgs, repositories.NewInstanceSettingsRepository(db.DB), git, testPublicURL),
+		services.NewWorkflowService(repositories.NewWorkflowRunsRepository(db.DB), repositories.NewCommitStatusesRepository(db.DB), access.repos, access.users, access.orgs, git, testPublicURL),
+		dir,
+	)
+
+	users := map[string]*models.User{}
+	for _, username := range []string{"alice", "reader", "outsider"} {
+		user, err := access.users.Create(username, username+"@example.com", username, "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		users[username] = user
+	}
+	repo, err := access.repos.CreateForUser(users["alice"].ID, "secret", "private", "main", nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+	if err := git.InitRepository(repo); err != nil {
+		t.Fatalf("init repository: %v", err)
+	}
+	commitFile(t, git.RepositoryPath(repo), services.TicketTemplatesPath+"/bug.md", "---\nname: Bug\ntitle: Crash report\nassignees: [alice, reader, outsider, nobody]\n---\nSecret reproduction steps\n")
+	mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'read')`, repo.ID, users["reader"].ID)
+
+	newTicket := func(user *models.User) *http.Request {
+		form := url.Values{"title": {"It crashes"}, "template": {"bug.md"}}
+		r := repositoryRequest(http.MethodPost, "/alice/secret/tickets", "secret", user)
+		r.Body = io.NopCloser(strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return r
+	}
+
+	for _, user := range []*models.User{nil, users["outsider"]} {
+		for _, target := range []string{"/alice/secret/tickets/new?template=bug.md", "/alice/secret/tickets/new"} {
+			w := httptest.NewRecorder()
+			err := controller.New(w, repositoryRequest(http.MethodGet, target, "secret", user))
+
+			var httpErr httperror.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+				t.Errorf("%s as %v: error %v, want not found", target, user, err)
+			}
+			if strings.Contains(w.Body.String(), "Secret reproduction steps") {
+				t.Errorf("%s as %v shows the template", target, user)
+			}
+		}
+
+		err := controller.Create(httptest.NewRecorder(), newTicket(user))
+		var httpErr httperror.HTTPError
+		if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusNotFound {
+			t.Errorf("creating a ticket as %v: error %v, want not found", user, err)
+		}
+	}
+	if count, err := tickets.CountByRepository(repo.ID, "open"); err != nil || count != 0 {
+		t.Fatalf("tickets created without access: %d, %v", count, err)
+	}
+
+	w := httptest.NewRecorder()
+	if err := controller.New(w, repositoryRequest(http.MethodGet, "/alice/secret/tickets/new?template=bug.md", "secret", users["reader"])); err != nil {
+		t.Fatalf("new ticket as a contributor: %v", err)
+	}
+	if !strings.Contains(w.Body.String(), "Secret reproduction steps") {
+		t.Error("the template isn't offered to a contributor")
+	}
+
+	// Template assignees who can't read the repository are skipped like unknown users
+	if err := controller.Create(httptest.NewRecorder(), newTicket(users["reader"])); err != nil {
+		t.Fatalf("create ticket as a contributor: %v", err)
+	}
+	ticket, err := tickets.FindByRepositoryAndNumber(repo.ID, 1)
+	if err != nil || ticket == nil {
+		t.Fatalf("find ticket: %v, %v", ticket, err)
+	}
+	assignees, err := tickets.FindAssigneesByTicket(ticket.ID)
+	if err != nil {
+		t.Fatalf("find assignees: %v", err)
+	}
+	var assigned []int64
+	for _, assignee := range assignees {
+		assigned = append(assigned, assignee.UserID)
+	}
+	slices.Sort(assigned)
+	if want := []int64{users["alice"].ID, users["reader"].ID}; !slices.Equal(assigned, want) {
+		t.Errorf("assignees %v, want %v", assigned, want)
+	}
+}
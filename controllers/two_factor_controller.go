@@ -0,0 +1,235 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+type TwoFactorController interface {
+	Setup(w http.ResponseWriter, r *http.Request) error
+	Enable(w http.ResponseWriter, r *http.Request) error
+	Disable(w http.ResponseWriter, r *http.Request) error
+	RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) error
+}
+
+type twoFactorController struct {
+	orgs        repositories.OrganizationsRepository
+	orgMembers  repositories.OrganizationMembersRepository
+	twoFactor   services.TwoFactorService
+	authService services.AuthService
+}
+
+func NewTwoFactorController(
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	twoFactor services.TwoFactorService,
+	authService services.AuthService,
+) TwoFactorController {
+	return &twoFactorController{
+		orgs:        orgs,
+		orgMembers:  orgMembers,
+		twoFactor:   twoFactor,
+		authService: authService,
+	}
+}
+
+func (c *twoFactorController) Setup(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	return c.renderSetup(w, r, user, "")
+}
+
+func (c *twoFactorController) Enable(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	codes, err := c.twoFactor.ConfirmEnrollment(user.ID, r.FormValue("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidTwoFactorCode):
+			return c.renderSetup(w, r, user, "Invalid authentication code. Make sure the time on your device is correct.")
+		case errors.Is(err, services.ErrTwoFactorNotEnrolling):
+			return c.renderSetup(w, r, user, "")
+		case errors.Is(err, services.ErrTwoFactorAlreadyEnabled):
+			http.Redirect(w, r, "/settings#two-factor", http.StatusSeeOther)
+			return nil
+		}
+		return err
+	}
+
+	return pages.TwoFactorRecoveryCodes(r, &pages.TwoFactorRecoveryCodesData{
+		RecoveryCodes: codes,
+		JustEnabled:   true,
+	}).Render(w, r)
+}
+
+func (c *twoFactorController) Disable(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	if message, err := c.confirmIdentity(user, r); err != nil || message != "" {
+		if err != nil {
+			return err
+		}
+		return c.redirectWithError(w, r, message)
+	}
+
+	// Members can't drop below the requirements of their organizations
+	org, err := c.findRequiringOrganization(user.ID)
+	if err != nil {
+		return err
+	}
+	if org != nil {
+		return c.redirectWithError(w, r, org.DisplayName+" requires two-factor authentication. Leave the organization before disabling it.")
+	}
+
+	if err := c.twoFactor.Disable(user.ID); err != nil {
+		return err
+	}
+
+	return c.redirectWithSuccess(w, r, "Two-factor authentication disabled")
+}
+
+func (c *twoFactorController) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	enabled, err := c.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return c.redirectWithError(w, r, "Two-factor authentication is not enabled")
+	}
+
+	if message, err := c.confirmIdentity(user, r); err != nil || message != "" {
+		if err != nil {
+			return err
+		}
+		return c.redirectWithError(w, r, message)
+	}
+
+	codes, err := c.twoFactor.RegenerateRecoveryCodes(user.ID)
+	if err != nil {
+		return err
+	}
+
+	return pages.TwoFactorRecoveryCodes(r, &pages.TwoFactorRecoveryCodesData{
+		RecoveryCodes: codes,
+	}).Render(w, r)
+}
+
+func (c *twoFactorController) renderSetup(w http.ResponseWriter, r *http.Request, user *models.User, message string) error {
+	credential, err := c.twoFactor.BeginEnrollment(user.ID)
+	if err != nil {
+		if errors.Is(err, services.ErrTwoFactorAlreadyEnabled) {
+			http.Redirect(w, r, "/settings#two-factor", http.StatusSeeOther)
+			return nil
+		}
+		return err
+	}
+
+	return pages.TwoFactorSetup(r, &pages.TwoFactorSetupData{
+		Secret:          credential.Secret,
+		ProvisioningURI: c.twoFactor.ProvisioningURI(user, credential.Secret),
+		Error:           message,
+	}).Render(w, r)
+}
+
+// confirmIdentity asks for the password again before changing two-factor settings. Accounts
+// signed up through GitHub have no password, they confirm with a code instead.
+// It returns a message for the user if the confirmation failed.
+func (c *twoFactorController) confirmIdentity(user *models.User, r *http.Request) (string, error) {
+	if user.Password != nil {
+		if !c.authService.CheckPassword(r.FormValue("password"), *user.Password) {
+			return "Incorrect password", nil
+		}
+		return "", nil
+	}
+
+	valid, err := c.twoFactor.Verify(user.ID, r.FormValue("code"))
+	if err != nil {
+		return "", err
+	}
+	if !valid {
+		return "Invalid authentication code", nil
+	}
+
+	return "", nil
+}
+
+// findRequiringOrganization returns an organization of the user that requires two-factor authentication, or nil
+func (c *twoFactorController) findRequiringOrganization(userID int64) (*models.Organization, error) {
+	memberships, err := c.orgMembers.FindAllByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, membership := range memberships {
+		org, err := c.orgs.FindByID(membership.OrganizationID)
+		if err != nil {
+			return nil, err
+		}
+		if org != nil && org.RequireTwoFactor {
+			return org, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (c *twoFactorController) redirectWithSuccess(w http.ResponseWriter, r *http.Request, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "two_factor_success",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/settings#two-factor", http.StatusSeeOther)
+	return nil
+}
+
+func (c *twoFactorController) redirectWithError(w http.ResponseWriter, r *http.Request, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "two_factor_error",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/settings#two-factor", http.StatusSeeOther)
+	return nil
+}
@@ -0,0 +1,215 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// maxEmailsPerUser bounds how many addresses a single account can have
+const maxEmailsPerUser = 10
+
+type UserEmailsController interface {
+	Create(w http.ResponseWriter, r *http.Request) error
+	Delete(w http.ResponseWriter, r *http.Request) error
+	MakePrimary(w http.ResponseWriter, r *http.Request) error
+	ResendVerification(w http.ResponseWriter, r *http.Request) error
+	Verify(w http.ResponseWriter, r *http.Request) error
+}
+
+type userEmailsController struct {
+	userEmails        repositories.UserEmailsRepository
+	emailVerification services.EmailVerificationService
+}
+
+func NewUserEmailsController(
+	userEmails repositories.UserEmailsRepository,
+	emailVerification services.EmailVerificationService,
+) UserEmailsController {
+	return &userEmailsController{
+		userEmails:        userEmails,
+		emailVerification: emailVerification,
+	}
+}
+
+func (c *userEmailsController) Create(w http.ResponseWriter, r *http.Request) error {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	email := strings.TrimSpace(r.FormValue("email"))
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return c.redirectWithError(w, r, "Please enter a valid email address")
+	}
+
+	// Addresses others added without verifying them don't stop anyone, the first to verify keeps it
+	existing, err := c.userEmails.FindVerifiedByEmail(email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return c.redirectWithError(w, r, "Email already in use")
+	}
+
+	own, err := c.userEmails.FindByUserAndEmail(user.ID, email)
+	if err != nil {
+		return err
+	}
+	if own != nil {
+		return c.redirectWithError(w, r, "You already added this email address")
+	}
+
+	userEmails, err := c.userEmails.FindByUserID(user.ID)
+	if err != nil {
+		return err
+	}
+	if len(userEmails) >= maxEmailsPerUser {
+		return c.redirectWithError(w, r, "You can add at most 10 email addresses")
+	}
+
+	userEmail, err := c.userEmails.Create(user.ID, email, false, false)
+	if err != nil {
+		return err
+	}
+
+	if err := c.emailVerification.SendVerification(user, userEmail); err != nil {
+		return err
+	}
+
+	return c.redirectWithSuccess(w, r, "Email added. We sent a verification link to "+email)
+}
+
+func (c *userEmailsController) Delete(w http.ResponseWriter, r *http.Request) error {
+	_, userEmail, err := c.findOwnEmail(w, r)
+	if err != nil || userEmail == nil {
+		return err
+	}
+
+	if userEmail.IsPrimary {
+		return c.redirectWithError(w, r, "You cannot remove your primary email address")
+	}
+
+	if err := c.userEmails.Delete(userEmail.ID); err != nil {
+		return err
+	}
+
+	return c.redirectWithSuccess(w, r, "Email removed")
+}
+
+func (c *userEmailsController) MakePrimary(w http.ResponseWriter, r *http.Request) error {
+	user, userEmail, err := c.findOwnEmail(w, r)
+	if err != nil || userEmail == nil {
+		return err
+	}
+
+	if userEmail.VerifiedAt == nil {
+		return c.redirectWithError(w, r, "Only verified email addresses can be made primary")
+	}
+
+	if !userEmail.IsPrimary {
+		if err := c.userEmails.SetPrimary(user.ID, userEmail.ID); err != nil {
+			return err
+		}
+	}
+
+	return c.redirectWithSuccess(w, r, userEmail.Email+" is now your primary email address")
+}
+
+func (c *userEmailsController) ResendVerification(w http.ResponseWriter, r *http.Request) error {
+	user, userEmail, err := c.findOwnEmail(w, r)
+	if err != nil || userEmail == nil {
+		return err
+	}
+
+	if userEmail.VerifiedAt != nil {
+		return c.redirectWithSuccess(w, r, userEmail.Email+" is already verified")
+	}
+
+	if err := c.emailVerification.SendVerification(user, userEmail); err != nil {
+		if errors.Is(err, services.ErrTooManyVerificationEmails) {
+			return c.redirectWithError(w, r, "Too many verification emails. Please try again later.")
+		}
+		return err
+	}
+
+	return c.redirectWithSuccess(w, r, "We sent a new verification link to "+userEmail.Email)
+}
+
+func (c *userEmailsController) Verify(w http.ResponseWriter, r *http.Request) error {
+	userEmail, err := c.emailVerification.Verify(r.URL.Query().Get("token"))
+	if err != nil {
+		return err
+	}
+
+	data := &pages.VerifyEmailData{}
+	if userEmail != nil {
+		data.Email = userEmail.Email
+	}
+
+	return pages.VerifyEmail(r, data).Render(w, r)
+}
+
+// findOwnEmail loads the email from the URL and makes sure it belongs to the signed in user.
+// It returns a nil email if it already responded, e.g. with a redirect to sign in.
+func (c *userEmailsController) findOwnEmail(w http.ResponseWriter, r *http.Request) (*models.User, *models.UserEmail, error) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil, nil, nil
+	}
+
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, nil, httperror.New(http.StatusBadRequest, "Invalid email ID")
+	}
+
+	userEmail, err := c.userEmails.FindByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if userEmail == nil || userEmail.UserID != user.ID {
+		return nil, nil, httperror.New(http.StatusNotFound, "Email not found")
+	}
+
+	return user, userEmail, nil
+}
+
+func (c *userEmailsController) redirectWithSuccess(w http.ResponseWriter, r *http.Request, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "email_success",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/settings#emails", http.StatusSeeOther)
+	return nil
+}
+
+func (c *userEmailsController) redirectWithError(w http.ResponseWriter, r *http.Request, message string) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "email_error",
+		Value:    message,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+	http.Redirect(w, r, "/settings#emails", http.StatusSeeOther)
+	return nil
+}
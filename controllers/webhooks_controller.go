@@ -0,0 +1,516 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// webhookRecentDeliveries is how many deliveries the page of a webhook lists
+const webhookRecentDeliveries = 30
+
+// WebhooksController manages the webhooks of repositories, under /{owner}/{repo}/settings/hooks, and
+// of organizations, under /{owner}/settings/hooks, along with their delivery log
+type WebhooksController interface {
+	Index(w http.ResponseWriter, r *http.Request) error
+	New(w http.ResponseWriter, r *http.Request) error
+	Create(w http.ResponseWriter, r *http.Request) error
+	Show(w http.ResponseWriter, r *http.Request) error
+	Update(w http.ResponseWriter, r *http.Request) error
+	Delete(w http.ResponseWriter, r *http.Request) error
+	Ping(w http.ResponseWriter, r *http.Request) error
+	ShowDelivery(w http.ResponseWriter, r *http.Request) error
+	Redeliver(w http.ResponseWriter, r *http.Request) error
+}
+
+type webhooksController struct {
+	webhooks     repositories.WebhooksRepository
+	deliveries   repositories.WebhookDeliveriesRepository
+	repos        repositories.RepositoriesRepository
+	contributors repositories.ContributorsRepository
+	stars        repositories.StarsRepository
+	orgs         repositories.OrganizationsRepository
+	orgMembers   repositories.OrganizationMembersRepository
+	webhookSvc   services.WebhookService
+	audit        services.AuditService
+}
+
+func NewWebhooksController(
+	webhooks repositories.WebhooksRepository,
+	deliveries repositories.WebhookDeliveriesRepository,
+	repos repositories.RepositoriesRepository,
+	contributors repositories.ContributorsRepository,
+	stars repositories.StarsRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	webhookSvc services.WebhookService,
+	audit services.AuditService,
+) WebhooksController {
+	return &webhooksController{
+		webhooks:     webhooks,
+		deliveries:   deliveries,
+		repos:        repos,
+		contributors: contributors,
+		stars:        stars,
+		orgs:         orgs,
+		orgMembers:   orgMembers,
+		webhookSvc:   webhookSvc,
+		audit:        audit,
+	}
+}
+
+// webhooksOwner is the repository or organization whose webhooks are being managed
+type webhooksOwner struct {
+	user       *models.User
+	repository *models.Repository
+	// orgID is the organization owning the webhooks or their repository, for the audit log
+	orgID *int64
+	scope pages.WebhookScope
+}
+
+func (c *webhooksController) Index(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	var webhooks []*models.Webhook
+	if owner.repository != nil {
+		webhooks, err = c.webhooks.FindAllByRepository(owner.repository.ID)
+	} else {
+		webhooks, err = c.webhooks.FindAllByOrganization(owner.scope.Organization.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return pages.Webhooks(r, &pages.WebhooksData{
+		Scope:    owner.scope,
+		Webhooks: webhooks,
+		Success:  c.takeNotice(w, r),
+	}).Render(w, r)
+}
+
+func (c *webhooksController) New(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	return pages.WebhookForm(r, &pages.WebhookFormData{
+		Scope:       owner.scope,
+		ContentType: models.WebhookContentTypeJSON,
+		Events:      []string{models.WebhookEventPush},
+		Active:      true,
+	}).Render(w, r)
+}
+
+func (c *webhooksController) Create(w http.ResponseWriter, r *http.Request) error {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	data := webhookFormFromRequest(r)
+	data.Scope = owner.scope
+	secret := r.FormValue("secret")
+
+	if data.Error = c.validateWebhook(r, data); data.Error != "" {
+		return pages.WebhookForm(r, data).Render(w, r)
+	}
+
+	webhook := &models.Webhook{
+		URL:         data.URL,
+		Secret:      secret,
+		ContentType: data.ContentType,
+		Events:      data.Events,
+		Active:      data.Active,
+	}
+	if owner.repository != nil {
+		webhook.RepositoryID = &owner.repository.ID
+	} else {
+		webhook.OrganizationID = &owner.scope.Organization.ID
+	}
+
+	webhook, err = c.webhooks.Create(webhook)
+	if err != nil {
+		return err
+	}
+	c.audit.Record(r, owner.user, models.AuditWebhookCreate, services.WebhookAuditTarget(webhook, owner.orgID), map[string]string{
+		"events": strings.Join(webhook.Events, " "),
+	})
+
+	notice := "Webhook created"
+	if webhook.Active {
+		c.webhookSvc.Ping(webhook, owner.user)
+		notice = "Webhook created. We sent it a ping event, its delivery is listed below."
+	}
+
+	c.setNotice(w, notice)
+	http.Redirect(w, r, owner.scope.Base()+"/"+strconv.FormatInt(webhook.ID, 10), http.StatusSeeOther)
+	return nil
+}
+
+func (c *webhooksController) Show(w http.ResponseWriter, r *http.Request) error {
+	owner, webhook, err := c.findWebhook(w, r)
+	if err != nil || webhook == nil {
+		return err
+	}
+
+	deliveries, err := c.deliveries.FindRecentByWebhook(webhook.ID, webhookRecentDeliveries)
+	if err != nil {
+		return err
+	}
+
+	return pages.WebhookForm(r, &pages.WebhookFormData{
+		Scope:       owner.scope,
+		Webhook:     webhook,
+		URL:         webhook.URL,
+		ContentType: webhook.ContentType,
+		Events:      webhook.Events,
+		Active:      webhook.Active,
+		Deliveries:  deliveries,
+		Success:     c.takeNotice(w, r),
+	}).Render(w, r)
+}
+
+func (c *webhooksController) Update(w http.ResponseWriter, r *http.Request) error {
+	owner, webhook, err := c.findWebhook(w, r)
+	if err != nil || webhook == nil {
+		return err
+	}
+
+	if err := r.ParseForm(); err != nil {
+		return httperror.New(http.StatusBadRequest, "Invalid form data")
+	}
+
+	data := webhookFormFromRequest(r)
+	data.Scope = owner.scope
+	data.Webhook = webhook
+
+	if data.Error = c.validateWebhook(r, data); data.Error != "" {
+		data.Deliveries, err = c.deliveries.FindRecentByWebhook(webhook.ID, webhookRecentDeliveries)
+		if err != nil {
+			return err
+		}
+		return pages.WebhookForm(r, data).Render(w, r)
+	}
+
+	// The secret is never shown again, leaving the field empty keeps it
+	if secret := r.FormValue("secret"); secret != "" {
+		webhook.Secret = secret
+	} else if r.FormValue("remove_secret") == "on" {
+		webhook.Secret = ""
+	}
+	webhook.URL = data.URL
+	webhook.ContentType = data.ContentType
+	webhook.Events = data.Events
+	webhook.Active = data.Active
+
+	if err := c.webhooks.Update(webhook); err != nil {
+		return err
+	}
+	c.audit.Record(r, owner.user, models.AuditWebhookUpdate, services.WebhookAuditTarget(webhook, owner.orgID), map[string]string{
+		"events": strings.Join(webhook.Events, " "),
+		"active": strconv.FormatBool(webhook.Active),
+	})
+
+	c.setNotice(w, "Webhook updated successfully")
+	http.Redirect(w, r, owner.scope.Base()+"/"+strconv.FormatInt(webhook.ID, 10), http.StatusSeeOther)
+	return nil
+}
+
+// Delete deletes the webhook along with its delivery log
+func (c *webhooksController) Delete(w http.ResponseWriter, r *http.Request) error {
+	owner, webhook, err := c.findWebhook(w, r)
+	if err != nil || webhook == nil {
+		return err
+	}
+
+	if err := c.webhooks.Delete(webhook.ID); err != nil {
+		return err
+	}
+	c.audit.Record(r, owner.user, models.AuditWebhookDelete, services.WebhookAuditTarget(webhook, owner.orgID), nil)
+
+	c.setNotice(w, "Webhook deleted successfully")
+	http.Redirect(w, r, owner.scope.Base(), http.StatusSeeOther)
+	return nil
+}
+
+// Ping queues a ping event to check the webhook works
+func (c *webhooksController) Ping(w http.ResponseWriter, r *http.Request) error {
+	owner, webhook, err := c.findWebhook(w, r)
+	if err != nil || webhook == nil {
+		return err
+	}
+
+	if !webhook.Active {
+		return httperror.BadRequest("Inactive webhooks aren't delivered, activate it first")
+	}
+
+	c.webhookSvc.Ping(webhook, owner.user)
+
+	c.setNotice(w, "Ping queued, it will show up below once delivered")
+	http.Redirect(w, r, owner.scope.Base()+"/"+strconv.FormatInt(webhook.ID, 10), http.StatusSeeOther)
+	return nil
+}
+
+func (c *webhooksController) ShowDelivery(w http.ResponseWriter, r *http.Request) error {
+	owner, webhook, delivery, err := c.findDelivery(w, r)
+	if err != nil || delivery == nil {
+		return err
+	}
+
+	return pages.WebhookDelivery(r, &pages.WebhookDeliveryData{
+		Scope:    owner.scope,
+		Webhook:  webhook,
+		Delivery: delivery,
+		Success:  c.takeNotice(w, r),
+	}).Render(w, r)
+}
+
+// Redeliver queues the payload of a delivery again and shows the new delivery
+func (c *webhooksController) Redeliver(w http.ResponseWriter, r *http.Request) error {
+	owner, webhook, delivery, err := c.findDelivery(w, r)
+	if err != nil || delivery == nil {
+		return err
+	}
+
+	redelivery, err := c.webhookSvc.Redeliver(delivery)
+	if err != nil {
+		return err
+	}
+
+	c.setNotice(w, "Redelivery queued")
+	http.Redirect(w, r, owner.scope.DeliveryURL(webhook, redelivery), http.StatusSeeOther)
+	return nil
+}
+
+// validateWebhook returns a validation message for the user, empty if the form is valid
+func (c *webhooksController) validateWebhook(r *http.Request, data *pages.WebhookFormData) string {
+	if data.URL == "" {
+		return "Payload URL is required"
+	}
+	if len(data.URL) > 2048 {
+		return "Payload URL must be at most 2048 characters"
+	}
+	if len(data.Events) == 0 {
+		return "Select at least one event"
+	}
+
+	err := c.webhookSvc.ValidateURL(r.Context(), data.URL)
+	switch {
+	case errors.Is(err, services.ErrWebhookURLInvalid):
+		return "Payload URL must be an http or https URL"
+	case errors.Is(err, services.ErrWebhookURLUnresolvable):
+		return "The host of the payload URL could not be resolved"
+	case errors.Is(err, services.ErrWebhookURLPrivate):
+		return "The payload URL points to a private network. An administrator must allow webhooks to private networks first."
+	case err != nil:
+		return "The payload URL could not be checked, try again later"
+	}
+
+	return ""
+}
+
+// webhookFormFromRequest reads the fields of the webhook form, other than the secret
+func webhookFormFromRequest(r *http.Request) *pages.WebhookFormData {
+	data := &pages.WebhookFormData{
+		URL:         strings.TrimSpace(r.FormValue("url")),
+		ContentType: models.WebhookContentTypeJSON,
+		Active:      r.FormValue("active") == "on",
+	}
+	if r.FormValue("content_type") == models.WebhookContentTypeForm {
+		data.ContentType = models.WebhookContentTypeForm
+	}
+
+	for _, event := range models.WebhookEvents {
+		if slices.Contains(r.Form["events"], event) {
+			data.Events = append(data.Events, event)
+		}
+	}
+
+	return data
+}
+
+// setNotice stores a message to show once on the next page
+func (c *webhooksController) setNotice(w http.ResponseWriter, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     "webhook_success",
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		MaxAge:   10,
+	})
+}
+
+// takeNotice returns and clears a message stored with setNotice
+func (c *webhooksController) takeNotice(w http.ResponseWriter, r *http.Request) string {
+	cookie, err := r.Cookie("webhook_success")
+	if err != nil {
+		return ""
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   "webhook_success",
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+	return cookie.Value
+}
+
+// findOwner returns the repository or organization of the URL if the signed in user administers it.
+// It redirects to sign in and returns nil for anonymous users.
+func (c *webhooksController) findOwner(w http.ResponseWriter, r *http.Request) (*webhooksOwner, error) {
+	user := middleware.GetUserFromContext(r)
+	if user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil, nil
+	}
+
+	ownerName := chi.URLParam(r, "owner")
+	repoName := chi.URLParam(r, "repo")
+	if repoName == "" {
+		return c.findOrganizationOwner(r, user)
+	}
+
+	repo, err := c.repos.FindByOwnerAndName(ownerName, repoName)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return nil, httperror.NotFound("repository not found")
+	}
+
+	canManage := repo.OwnerUserID != nil && *repo.OwnerUserID == user.ID
+	if !canManage && repo.OwnerOrgID != nil {
+		membership, err := c.orgMembers.FindByOrganizationAndUser(*repo.OwnerOrgID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		canManage = membership != nil && membership.Role == models.OrganizationRoleOwner
+	}
+	if !canManage {
+		contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+		canManage = contributor != nil && contributor.Role == "admin"
+	}
+	if !canManage {
+		return nil, httperror.Forbidden("access denied")
+	}
+
+	starCount, _ := c.stars.CountByRepository(repo.ID)
+	star, _ := c.stars.FindByUserAndRepository(repo.ID, user.ID)
+
+	return &webhooksOwner{
+		user:       user,
+		repository: repo,
+		orgID:      repo.OwnerOrgID,
+		scope: pages.WebhookScope{
+			Repository:    repo,
+			OwnerUsername: ownerName,
+			StarCount:     starCount,
+			HasStarred:    star != nil,
+		},
+	}, nil
+}
+
+func (c *webhooksController) findOrganizationOwner(r *http.Request, user *models.User) (*webhooksOwner, error) {
+	ownerType, _ := middleware.GetOwnerType(r.Context())
+	if ownerType != middleware.OwnerTypeOrg {
+		return nil, httperror.NotFound("organization not found")
+	}
+
+	ownerID, _ := middleware.GetOwnerID(r.Context())
+	org, err := c.orgs.FindByID(ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if org == nil {
+		return nil, httperror.NotFound("organization not found")
+	}
+
+	membership, err := c.orgMembers.FindByOrganizationAndUser(org.ID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil || membership.Role != models.OrganizationRoleOwner {
+		return nil, httperror.Forbidden("access denied")
+	}
+
+	return &webhooksOwner{
+		user:  user,
+		orgID: &org.ID,
+		scope: pages.WebhookScope{Organization: org},
+	}, nil
+}
+
+// findWebhook returns the webhook in the URL, if it belongs to the owner of the page
+func (c *webhooksController) findWebhook(w http.ResponseWriter, r *http.Request) (*webhooksOwner, *models.Webhook, error) {
+	owner, err := c.findOwner(w, r)
+	if err != nil || owner == nil {
+		return nil, nil, err
+	}
+
+	webhookID, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		return nil, nil, httperror.New(http.StatusBadRequest, "Invalid webhook ID")
+	}
+
+	webhook, err := c.webhooks.FindByID(webhookID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var owned bool
+	if webhook != nil && owner.repository != nil {
+		owned = webhook.RepositoryID != nil && *webhook.RepositoryID == owner.repository.ID
+	} else if webhook != nil {
+		owned = webhook.OrganizationID != nil && *webhook.OrganizationID == owner.scope.Organization.ID
+	}
+	if !owned {
+		return nil, nil, httperror.NotFound("Webhook not found")
+	}
+
+	return owner, webhook, nil
+}
+
+// findDelivery returns the delivery in the URL, if it belongs to the webhook in the URL
+func (c *webhooksController) findDelivery(w http.ResponseWriter, r *http.Request) (*webhooksOwner, *models.Webhook, *models.WebhookDelivery, error) {
+	owner, webhook, err := c.findWebhook(w, r)
+	if err != nil || webhook == nil {
+		return nil, nil, nil, err
+	}
+
+	deliveryID, err := strconv.ParseInt(chi.URLParam(r, "deliveryID"), 10, 64)
+	if err != nil {
+		return nil, nil, nil, httperror.New(http.StatusBadRequest, "Invalid delivery ID")
+	}
+
+	delivery, err := c.deliveries.FindByID(deliveryID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if delivery == nil || delivery.WebhookID != webhook.ID {
+		return nil, nil, nil, httperror.NotFound("Delivery not found")
+	}
+
+	return owner, webhook, delivery, nil
+}
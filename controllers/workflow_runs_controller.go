@@ -0,0 +1,263 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/pages"
+)
+
+// workflowRunsPerPage is how many of the latest runs the list shows
+const workflowRunsPerPage = 50
+
+// WorkflowRunsController shows the workflow runs of a repository to everyone who can read it.
+// People with write access can cancel runs.
+type WorkflowRunsController interface {
+	Index(w http.ResponseWriter, r *http.Request) error
+	Show(w http.ResponseWriter, r *http.Request) error
+	// Log responds with the log of a job as plain text from the offset query parameter on. The
+	// X-Log-Offset header tells where to continue from and X-Job-Status whether more output may come.
+	Log(w http.ResponseWriter, r *http.Request) error
+	Cancel(w http.ResponseWriter, r *http.Request) error
+}
+
+type workflowRunsController struct {
+	runs         repositories.WorkflowRunsRepository
+	repos        repositories.RepositoriesRepository
+	users        repositories.UsersRepository
+	contributors repositories.ContributorsRepository
+	orgMembers   repositories.OrganizationMembersRepository
+	stars        repositories.StarsRepository
+	workflow     services.WorkflowService
+}
+
+func NewWorkflowRunsController(
+	runs repositories.WorkflowRunsRepository,
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	contributors repositories.ContributorsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	stars repositories.StarsRepository,
+	workflow services.WorkflowService,
+) WorkflowRunsController {
+	return &workflowRunsController{
+		runs:         runs,
+		repos:        repos,
+		users:        users,
+		contributors: contributors,
+		orgMembers:   orgMembers,
+		stars:        stars,
+		workflow:     workflow,
+	}
+}
+
+// workflowRunsAccess is a repository along with what the current user may do with it
+type workflowRunsAccess struct {
+	repository *models.Repository
+	user       *models.User
+	canWrite   bool
+	canManage  bool
+}
+
+func (c *workflowRunsController) Index(w http.ResponseWriter, r *http.Request) error {
+	access, err := c.findRepository(r)
+	if err != nil {
+		return err
+	}
+
+	runs, err := c.runs.FindRunsByRepository(access.repository.ID, workflowRunsPerPage)
+	if err != nil {
+		return err
+	}
+
+	starCount, hasStarred := c.starInfo(access)
+	cloneURL := "https://" + r.Host + "/" + chi.URLParam(r, "owner") + "/" + access.repository.Name
+
+	return pages.WorkflowRuns(r, &pages.WorkflowRunsData{
+		Repository:    access.repository,
+		OwnerUsername: chi.URLParam(r, "owner"),
+		Runs:          runs,
+		CanManage:     access.canManage,
+		StarCount:     starCount,
+		HasStarred:    hasStarred,
+		CloneURL:      cloneURL,
+		RepositoryURL: cloneURL,
+	}).Render(w, r)
+}
+
+func (c *workflowRunsController) Show(w http.ResponseWriter, r *http.Request) error {
+	access, run, err := c.findRun(r)
+	if err != nil {
+		return err
+	}
+
+	jobs, err := c.runs.FindJobsByRun(run.ID)
+	if err != nil {
+		return err
+	}
+
+	var triggeredBy string
+	if run.TriggeredByID != nil {
+		if user, err := c.users.FindByID(*run.TriggeredByID); err == nil && user != nil {
+			triggeredBy = user.Username
+		}
+	}
+
+	starCount, hasStarred := c.starInfo(access)
+	cloneURL := "https://" + r.Host + "/" + chi.URLParam(r, "owner") + "/" + access.repository.Name
+
+	return pages.WorkflowRun(r, &pages.WorkflowRunData{
+		Repository:    access.repository,
+		OwnerUsername: chi.URLParam(r, "owner"),
+		Run:           run,
+		Jobs:          jobs,
+		TriggeredBy:   triggeredBy,
+		CanCancel:     access.canWrite,
+		CanManage:     access.canManage,
+		StarCount:     starCount,
+		HasStarred:    hasStarred,
+		CloneURL:      cloneURL,
+		RepositoryURL: cloneURL,
+	}).Render(w, r)
+}
+
+func (c *workflowRunsController) Log(w http.ResponseWriter, r *http.Request) error {
+	_, run, err := c.findRun(r)
+	if err != nil {
+		return err
+	}
+
+	jobID, err := strconv.ParseInt(chi.URLParam(r, "jobID"), 10, 64)
+	if err != nil {
+		return httperror.NotFound("job not found")
+	}
+	job, err := c.runs.FindJobByID(jobID)
+	if err != nil {
+		return err
+	}
+	if job == nil || job.RunID != run.ID {
+		return httperror.NotFound("job not found")
+	}
+
+	offset, _ := strconv.ParseInt(r.URL.Query().Get("offset"), 10, 64)
+	offset = max(0, min(offset, job.LogSize))
+
+	// The log is read after the job, output appended in between is sent along and counted
+	log, err := c.runs.ReadLog(job.ID, offset)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Log-Offset", strconv.FormatInt(offset+int64(len(log)), 10))
+	w.Header().Set("X-Job-Status", job.Status)
+	_, err = w.Write(log)
+	return err
+}
+
+func (c *workflowRunsController) Cancel(w http.ResponseWriter, r *http.Request) error {
+	access, run, err := c.findRun(r)
+	if err != nil {
+		return err
+	}
+	if access.user == nil {
+		http.Redirect(w, r, "/auth/sign-in", http.StatusSeeOther)
+		return nil
+	}
+	if !access.canWrite {
+		return httperror.Forbidden("access denied")
+	}
+
+	if err := c.workflow.Cancel(run); err != nil {
+		return err
+	}
+
+	http.Redirect(w, r, "/"+chi.URLParam(r, "owner")+"/"+access.repository.Name+"/runs/"+strconv.FormatInt(run.Number, 10), http.StatusSeeOther)
+	return nil
+}
+
+// findRepository returns the repository of the URL if the current user can read it
+func (c *workflowRunsController) findRepository(r *http.Request) (*workflowRunsAccess, error) {
+	repo, err := c.repos.FindByOwnerAndName(chi.URLParam(r, "owner"), chi.URLParam(r, "repo"))
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return nil, httperror.NotFound("repository not found")
+	}
+
+	access := &workflowRunsAccess{repository: repo, user: middleware.GetUserFromContext(r)}
+	canRead := repo.Visibility == "public"
+
+	if user := access.user; user != nil {
+		if repo.OwnerUserID != nil && *repo.OwnerUserID == user.ID {
+			access.canWrite, access.canManage = true, true
+		} else if repo.OwnerOrgID != nil {
+			membership, err := c.orgMembers.FindByOrganizationAndUser(*repo.OwnerOrgID, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if membership != nil {
+				access.canWrite = true
+				access.canManage = membership.Role == models.OrganizationRoleOwner
+			}
+		}
+
+		if !access.canWrite {
+			contributor, err := c.contributors.FindByRepositoryAndUser(repo.ID, user.ID)
+			if err != nil {
+				return nil, err
+			}
+			if contributor != nil {
+				canRead = true
+				access.canWrite = contributor.Role == "write" || contributor.Role == "admin"
+				access.canManage = contributor.Role == "admin"
+			}
+		}
+	}
+
+	if !canRead && !access.canWrite {
+		return nil, httperror.NotFound("repository not found")
+	}
+	return access, nil
+}
+
+// findRun returns the run of the {number} URL parameter
+func (c *workflowRunsController) findRun(r *http.Request) (*workflowRunsAccess, *models.WorkflowRun, error) {
+	access, err := c.findRepository(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	number, err := strconv.ParseInt(chi.URLParam(r, "number"), 10, 64)
+	if err != nil {
+		return nil, nil, httperror.NotFound("run not found")
+	}
+
+	run, err := c.runs.FindRunByNumber(access.repository.ID, number)
+	if err != nil {
+		return nil, nil, err
+	}
+	if run == nil {
+		return nil, nil, httperror.NotFound("run not found")
+	}
+	return access, run, nil
+}
+
+// starInfo returns the star count of the repository and whether the current user starred it
+func (c *workflowRunsController) starInfo(access *workflowRunsAccess) (int64, bool) {
+	starCount, _ := c.stars.CountByRepository(access.repository.ID)
+	if access.user == nil {
+		return starCount, false
+	}
+	star, _ := c.stars.FindByUserAndRepository(access.repository.ID, access.user.ID)
+	return starCount, star != nil
+}
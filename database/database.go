@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	_ "embed"
+	"fmt"
 
 	_ "modernc.org/sqlite"
 )
@@ -34,6 +35,9 @@ func New(dsn string) (*DB, error) {
 	if err := runMigrations(db); err != nil {
 		return nil, err
 	}
+	if err := runVersionedMigrations(db); err != nil {
+		return nil, err
+	}
 
 	return &DB{DB: db}, nil
 }
@@ -54,27 +58,211 @@ func runMigrations(db *sql.DB) error {
 		}
 	}
 
-	// Check if github_user_id column exists in users table
+	// Check if milestone_id column exists in tickets table
+	var milestoneIDExists bool
+	row = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('tickets') WHERE name='milestone_id'")
+	if err := row.Scan(&milestoneIDExists); err != nil {
+		return err
+	}
+
+	// Add milestone_id column if it doesn't exist
+	if !milestoneIDExists {
+		_, err := db.Exec("ALTER TABLE tickets ADD COLUMN milestone_id INTEGER REFERENCES milestones(id) ON DELETE SET NULL")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := db.Exec("CREATE INDEX IF NOT EXISTS idx_tickets_milestone ON tickets(milestone_id)")
+	if err != nil {
+		return err
+	}
+
+	// Check if scopes column exists in access_tokens table
+	var scopesExists bool
+	row = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('access_tokens') WHERE name='scopes'")
+	if err := row.Scan(&scopesExists); err != nil {
+		return err
+	}
+
+	// Add scopes and expires_at columns if they don't exist. Tokens created before
+	// scopes existed had full rights, so they keep every scope.
+	if !scopesExists {
+		_, err := db.Exec("ALTER TABLE access_tokens ADD COLUMN scopes TEXT NOT NULL DEFAULT ''")
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec("ALTER TABLE access_tokens ADD COLUMN expires_at INTEGER")
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec("UPDATE access_tokens SET scopes = 'repo:read repo:write tickets admin:org api'")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check if application_id column exists in access_tokens table
+	var applicationIDExists bool
+	row = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('access_tokens') WHERE name='application_id'")
+	if err := row.Scan(&applicationIDExists); err != nil {
+		return err
+	}
+
+	// Add application_id column if it doesn't exist
+	if !applicationIDExists {
+		_, err := db.Exec("ALTER TABLE access_tokens ADD COLUMN application_id INTEGER REFERENCES oauth_applications(id) ON DELETE CASCADE")
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = db.Exec("CREATE INDEX IF NOT EXISTS idx_access_tokens_application ON access_tokens(application_id)")
+	if err != nil {
+		return err
+	}
+
+	// Device authorization moved to oauth_device_codes. Its sessions only live for minutes,
+	// so those in progress are dropped rather than moved.
+	_, err = db.Exec("DROP TABLE IF EXISTS device_auth_sessions")
+	if err != nil {
+		return err
+	}
+
+	// Check if require_two_factor column exists in organizations table
+	var requireTwoFactorExists bool
+	row = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('organizations') WHERE name='require_two_factor'")
+	if err := row.Scan(&requireTwoFactorExists); err != nil {
+		return err
+	}
+
+	// Add require_two_factor column if it doesn't exist
+	if !requireTwoFactorExists {
+		_, err := db.Exec("ALTER TABLE organizations ADD COLUMN require_two_factor INTEGER NOT NULL DEFAULT 0")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check if is_admin column exists in users table
+	var isAdminExists bool
+	row = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('users') WHERE name='is_admin'")
+	if err := row.Scan(&isAdminExists); err != nil {
+		return err
+	}
+
+	// Add is_admin and suspended_at columns if they don't exist
+	if !isAdminExists {
+		_, err := db.Exec("ALTER TABLE users ADD COLUMN is_admin INTEGER NOT NULL DEFAULT 0")
+		if err != nil {
+			return err
+		}
+
+		_, err = db.Exec("ALTER TABLE users ADD COLUMN suspended_at INTEGER")
+		if err != nil {
+			return err
+		}
+	}
+
+	// Check if the github_user_id column of users still holds GitHub accounts
 	var githubUserIDExists bool
 	row = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info('users') WHERE name='github_user_id'")
 	if err := row.Scan(&githubUserIDExists); err != nil {
 		return err
 	}
 
-	// Add github_user_id column if it doesn't exist
-	if !githubUserIDExists {
-		_, err := db.Exec("ALTER TABLE users ADD COLUMN github_user_id TEXT")
+	// GitHub accounts are linked through user_identities like those of other providers.
+	// The column can't be dropped because it is UNIQUE, so it is emptied once moved.
+	if githubUserIDExists {
+		_, err := db.Exec(`
+			INSERT OR IGNORE INTO user_identities (user_id, provider, subject)
+			SELECT id, 'github', github_user_id FROM users WHERE github_user_id IS NOT NULL
+		`)
 		if err != nil {
 			return err
 		}
 
-		// Create unique index on github_user_id
-		_, err = db.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_users_github_user_id ON users(github_user_id) WHERE github_user_id IS NOT NULL")
+		_, err = db.Exec("UPDATE users SET github_user_id = NULL WHERE github_user_id IS NOT NULL")
 		if err != nil {
 			return err
 		}
 	}
 
+	// Backfill user_emails with the address of every user that has none yet
+	_, err = db.Exec(`
+		INSERT INTO user_emails (user_id, email, is_primary)
+		SELECT id, email, 1 FROM users
+		WHERE email != '' AND NOT EXISTS (SELECT 1 FROM user_emails WHERE user_emails.user_id = users.id)
+	`)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// versionedMigrations change data or tables in ways that must only happen once. PRAGMA user_version
+// counts how many of them ran, so new ones are appended and existing ones never change.
+var versionedMigrations = []string{
+	// Organizations from before members existed get everyone who contributes to their repositories.
+	// Administering a repository doesn't make anyone an owner of the organization, so they are all
+	// members.
+	`
+	INSERT INTO organization_members (organization_id, user_id, role)
+	SELECT DISTINCT repositories.owner_org_id, contributors.user_id, 'member'
+	FROM contributors
+	JOIN repositories ON repositories.id = contributors.repository_id
+	WHERE repositories.owner_org_id IS NOT NULL
+		AND NOT EXISTS (SELECT 1 FROM organization_members WHERE organization_members.organization_id = repositories.owner_org_id)
+	`,
+	// Addresses were unique across users, so unverified ones could block their owners from adding
+	// them. SQLite can't drop the constraint, the table is rebuilt without it.
+	`
+	CREATE TABLE user_emails_new (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		email TEXT NOT NULL COLLATE NOCASE,
+		is_primary INTEGER NOT NULL DEFAULT 0,
+		verified_at INTEGER,
+		created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+		FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	);
+	INSERT INTO user_emails_new (id, user_id, email, is_primary, verified_at, created_at)
+	SELECT id, user_id, email, is_primary, verified_at, created_at FROM user_emails;
+	DROP TABLE user_emails;
+	ALTER TABLE user_emails_new RENAME TO user_emails;
+	CREATE INDEX idx_user_emails_user ON user_emails(user_id);
+	CREATE UNIQUE INDEX idx_user_emails_user_email ON user_emails(user_id, email);
+	CREATE UNIQUE INDEX idx_user_emails_verified ON user_emails(email) WHERE verified_at IS NOT NULL;
+	`,
+}
+
+func runVersionedMigrations(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+
+	for ; version < len(versionedMigrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(versionedMigrations[version]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", version+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", version+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
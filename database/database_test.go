@@ -0,0 +1,70 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openTestDB(t *testing.T, path string) *DB {
+	t.Helper()
+
+	db, err := New(path)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	return db
+}
+
+func exec(t *testing.T, db *DB, query string, args ...any) {
+	t.Helper()
+
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+func TestOrganizationMembersBackfillRunsOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hypercommit.db")
+
+	// An organization from before members existed, with a repository admin and a writer
+	db := openTestDB(t, path)
+	exec(t, db, "INSERT INTO users (id, username, email, display_name) VALUES (1, 'alice', 'alice@example.com', 'Alice'), (2, 'bob', 'bob@example.com', 'Bob')")
+	exec(t, db, "INSERT INTO organizations (id, username, display_name) VALUES (1, 'acme', 'Acme')")
+	exec(t, db, "INSERT INTO repositories (id, name, visibility, owner_org_id) VALUES (1, 'widgets', 'public', 1)")
+	exec(t, db, "INSERT INTO contributors (repository_id, user_id, role) VALUES (1, 1, 'admin'), (1, 2, 'write')")
+	exec(t, db, "PRAGMA user_version = 0")
+	db.Close()
+
+	db = openTestDB(t, path)
+	rows, err := db.Query("SELECT user_id, role FROM organization_members WHERE organization_id = 1 ORDER BY user_id")
+	if err != nil {
+		t.Fatalf("query members: %v", err)
+	}
+	var roles []string
+	for rows.Next() {
+		var userID int64
+		var role string
+		if err := rows.Scan(&userID, &role); err != nil {
+			t.Fatalf("scan member: %v", err)
+		}
+		roles = append(roles, role)
+	}
+	rows.Close()
+	if len(roles) != 2 || roles[0] != "member" || roles[1] != "member" {
+		t.Fatalf("backfilled roles = %v, want [member member]", roles)
+	}
+
+	// An organization left without members stays without them
+	exec(t, db, "DELETE FROM organization_members")
+	db.Close()
+
+	db = openTestDB(t, path)
+	defer db.Close()
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM organization_members").Scan(&count); err != nil {
+		t.Fatalf("count members: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("got %d members after restarting, want 0", count)
+	}
+}
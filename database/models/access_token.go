@@ -1,10 +1,26 @@
 package models
 
+// Access token scopes
+const (
+	ScopeRepoRead  = "repo:read"
+	ScopeRepoWrite = "repo:write"
+	ScopeTickets   = "tickets"
+	ScopeAdminOrg  = "admin:org"
+	ScopeAPI       = "api"
+)
+
+// AccessTokenScopes lists every scope in the order they are shown
+var AccessTokenScopes = []string{ScopeRepoRead, ScopeRepoWrite, ScopeTickets, ScopeAdminOrg, ScopeAPI}
+
 type AccessToken struct {
-	ID         int64
-	UserID     int64
-	Name       string
-	TokenHash  string
-	LastUsedAt *int64
-	CreatedAt  int64
+	ID            int64
+	UserID        int64
+	Name          string
+	TokenHash     string
+	Scopes        []string
+	ExpiresAt     *int64
+	RepositoryIDs []int64 // empty means all repositories the user can access
+	ApplicationID *int64  // the OAuth application it was issued to, nil for personal access tokens
+	LastUsedAt    *int64
+	CreatedAt     int64
 }
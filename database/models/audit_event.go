@@ -0,0 +1,116 @@
+package models
+
+// Audit event actions
+const (
+	AuditSignIn       = "user.sign_in"
+	AuditSignInFailed = "user.sign_in_failed"
+
+	AuditRepositoryCreate           = "repo.create"
+	AuditRepositoryUpdate           = "repo.update"
+	AuditRepositoryVisibilityChange = "repo.visibility_change"
+	AuditRepositoryDelete           = "repo.delete"
+	AuditCollaboratorAdd            = "repo.collaborator_add"
+	AuditCollaboratorRoleChange     = "repo.collaborator_role_change"
+	AuditCollaboratorRemove         = "repo.collaborator_remove"
+
+	AuditAccessTokenCreate = "access_token.create"
+	AuditAccessTokenDelete = "access_token.delete"
+
+	AuditOAuthApplicationCreate      = "oauth_application.create"
+	AuditOAuthApplicationUpdate      = "oauth_application.update"
+	AuditOAuthApplicationSecretReset = "oauth_application.secret_reset"
+	AuditOAuthApplicationDelete      = "oauth_application.delete"
+	AuditOAuthAuthorize              = "oauth.authorize"
+	AuditOAuthRevoke                 = "oauth.revoke"
+
+	AuditWebhookCreate = "webhook.create"
+	AuditWebhookUpdate = "webhook.update"
+	AuditWebhookDelete = "webhook.delete"
+
+	AuditSecretCreate = "secret.create"
+	AuditSecretUpdate = "secret.update"
+	AuditSecretDelete = "secret.delete"
+	// AuditSecretAccess is recorded when a runner is handed the secrets of a repository for a job
+	AuditSecretAccess = "secret.access"
+
+	AuditOrganizationCreate = "org.create"
+	AuditOrganizationUpdate = "org.update"
+	AuditMemberAdd          = "org.member_add"
+	AuditMemberRemove       = "org.member_remove"
+
+	AuditAdminUserSuspend       = "admin.user_suspend"
+	AuditAdminUserUnsuspend     = "admin.user_unsuspend"
+	AuditAdminUserPasswordReset = "admin.user_password_reset"
+	AuditAdminUserDelete        = "admin.user_delete"
+	AuditAdminUserPromote       = "admin.user_promote"
+	AuditAdminUserDemote        = "admin.user_demote"
+	AuditAdminSettingsUpdate    = "admin.settings_update"
+)
+
+// AuditActions lists every action in the order they are offered as filters
+var AuditActions = []string{
+	AuditSignIn,
+	AuditSignInFailed,
+	AuditRepositoryCreate,
+	AuditRepositoryUpdate,
+	AuditRepositoryVisibilityChange,
+	AuditRepositoryDelete,
+	AuditCollaboratorAdd,
+	AuditCollaboratorRoleChange,
+	AuditCollaboratorRemove,
+	AuditAccessTokenCreate,
+	AuditAccessTokenDelete,
+	AuditOAuthApplicationCreate,
+	AuditOAuthApplicationUpdate,
+	AuditOAuthApplicationSecretReset,
+	AuditOAuthApplicationDelete,
+	AuditOAuthAuthorize,
+	AuditOAuthRevoke,
+	AuditWebhookCreate,
+	AuditWebhookUpdate,
+	AuditWebhookDelete,
+	AuditSecretCreate,
+	AuditSecretUpdate,
+	AuditSecretDelete,
+	AuditSecretAccess,
+	AuditOrganizationCreate,
+	AuditOrganizationUpdate,
+	AuditMemberAdd,
+	AuditMemberRemove,
+	AuditAdminUserSuspend,
+	AuditAdminUserUnsuspend,
+	AuditAdminUserPasswordReset,
+	AuditAdminUserDelete,
+	AuditAdminUserPromote,
+	AuditAdminUserDemote,
+	AuditAdminSettingsUpdate,
+}
+
+// Audit event target types
+const (
+	AuditTargetUser             = "user"
+	AuditTargetRepository       = "repository"
+	AuditTargetAccessToken      = "access_token"
+	AuditTargetOAuthApplication = "oauth_application"
+	AuditTargetOrganization     = "organization"
+	AuditTargetWebhook          = "webhook"
+	AuditTargetSecret           = "secret"
+	AuditTargetInstance         = "instance"
+)
+
+// AuditEvent records who did what to which target. ActorID is nil for anonymous actors,
+// such as someone failing to sign in, or once the actor is deleted. OrgID is set for
+// events in an organization, including those of its repositories.
+type AuditEvent struct {
+	ID         int64
+	ActorID    *int64
+	ActorName  string
+	Action     string
+	TargetType string
+	TargetID   *int64
+	TargetName string
+	OrgID      *int64
+	IPAddress  string
+	Metadata   map[string]string
+	CreatedAt  int64
+}
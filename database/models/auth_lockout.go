@@ -0,0 +1,13 @@
+package models
+
+// AuthLockout records an account being locked out after repeated failed sign-in attempts.
+// UserID is nil when the attempts used an identifier that matches no user.
+type AuthLockout struct {
+	ID          int64
+	UserID      *int64
+	Account     string
+	IPAddress   string
+	Failures    int
+	LockedUntil int64
+	CreatedAt   int64
+}
@@ -0,0 +1,25 @@
+package models
+
+// Commit status states
+const (
+	CommitStatePending = "pending"
+	CommitStateSuccess = "success"
+	CommitStateFailure = "failure"
+	CommitStateError   = "error"
+)
+
+// CommitStatus is the latest result an external system such as CI reported for a commit. There is one
+// per context, reporting again with the same context replaces it.
+type CommitStatus struct {
+	ID           int64
+	RepositoryID int64
+	SHA          string
+	// Context names the check, like "ci/build"
+	Context     string
+	State       string // 'pending', 'success', 'failure' or 'error'
+	TargetURL   *string
+	Description *string
+	CreatorID   *int64
+	CreatedAt   int64
+	UpdatedAt   int64
+}
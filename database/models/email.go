@@ -0,0 +1,15 @@
+package models
+
+type OutboxEmail struct {
+	ID            int64
+	ToAddress     string
+	Subject       string
+	TextBody      string
+	HTMLBody      *string
+	Status        string // 'pending', 'sent' or 'failed'
+	Attempts      int64
+	LastError     *string
+	NextAttemptAt int64
+	SentAt        *int64
+	CreatedAt     int64
+}
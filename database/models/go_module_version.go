@@ -0,0 +1,17 @@
+package models
+
+// GoModuleVersion is a tag of a repository served as a version of its Go module. It records what
+// was served first, so the version stays the same even if the tag is moved.
+type GoModuleVersion struct {
+	ID           int64
+	RepositoryID int64
+	Version      string
+	CommitSHA    string
+	// GoMod is the go.mod file of the version
+	GoMod      string
+	CommitTime int64
+	// ZipHash is the go.sum checksum of the module zip, nil until the zip was built
+	ZipHash   *string
+	ZipSize   *int64
+	CreatedAt int64
+}
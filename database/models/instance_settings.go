@@ -0,0 +1,18 @@
+package models
+
+// InstanceSettings are settings of the whole instance that administrators change at /admin
+type InstanceSettings struct {
+	SignUpDisabled bool
+	// WebhooksAllowPrivateNetworks lets webhooks target loopback and private network addresses
+	WebhooksAllowPrivateNetworks bool
+}
+
+// SystemStats counts what is stored on the instance
+type SystemStats struct {
+	Users          int
+	Admins         int
+	SuspendedUsers int
+	Organizations  int
+	Repositories   int
+	Tickets        int
+}
@@ -0,0 +1,13 @@
+package models
+
+type Milestone struct {
+	ID           int64
+	RepositoryID int64
+	Title        string
+	Description  *string
+	DueDate      *int64
+	Status       string // 'open' or 'closed'
+	ClosedAt     *int64
+	CreatedAt    int64
+	UpdatedAt    int64
+}
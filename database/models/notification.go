@@ -0,0 +1,23 @@
+package models
+
+type TicketSubscription struct {
+	ID         int64
+	TicketID   int64
+	UserID     int64
+	Reason     string // 'author', 'comment', 'assigned', 'mention' or 'manual'
+	Subscribed bool
+	CreatedAt  int64
+	UpdatedAt  int64
+}
+
+type Notification struct {
+	ID        int64
+	UserID    int64
+	TicketID  int64
+	ActorID   int64
+	CommentID *int64
+	Reason    string // 'comment', 'mention', 'assigned', 'closed' or 'reopened'
+	ReadAt    *int64
+	DoneAt    *int64
+	CreatedAt int64
+}
@@ -0,0 +1,17 @@
+package models
+
+// OAuthApplication is a third-party application that acts on behalf of users with OAuth 2.0.
+// Public applications, such as native or browser apps, can't keep a secret and only use PKCE.
+type OAuthApplication struct {
+	ID               int64
+	OwnerUserID      *int64
+	OwnerOrgID       *int64
+	Name             string
+	HomepageURL      string
+	RedirectURIs     []string
+	ClientID         string
+	ClientSecretHash string
+	Confidential     bool
+	CreatedAt        int64
+	UpdatedAt        int64
+}
@@ -0,0 +1,13 @@
+package models
+
+type OAuthAuthorizationCode struct {
+	ID            int64
+	CodeHash      string
+	ApplicationID int64
+	UserID        int64
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     int64
+	CreatedAt     int64
+}
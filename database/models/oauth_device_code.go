@@ -0,0 +1,23 @@
+package models
+
+// Device code statuses
+const (
+	OAuthDeviceCodePending  = "pending"
+	OAuthDeviceCodeApproved = "approved"
+	OAuthDeviceCodeDenied   = "denied"
+)
+
+// OAuthDeviceCode is a device authorization grant (RFC 8628) waiting for a user
+// to enter its user code, and for the device to pick up its tokens
+type OAuthDeviceCode struct {
+	ID             int64
+	DeviceCodeHash string
+	UserCode       string // User-friendly code like "ABCD-1234"
+	ApplicationID  *int64 // nil for the Hypercommit CLI
+	Scopes         []string
+	UserID         *int64 // set once a user approved or denied it
+	Status         string
+	LastPolledAt   *int64
+	ExpiresAt      int64
+	CreatedAt      int64
+}
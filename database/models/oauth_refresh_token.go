@@ -0,0 +1,11 @@
+package models
+
+type OAuthRefreshToken struct {
+	ID            int64
+	TokenHash     string
+	ApplicationID int64
+	UserID        int64
+	Scopes        []string
+	ExpiresAt     int64
+	CreatedAt     int64
+}
@@ -1,9 +1,10 @@
 package models
 
 type Organization struct {
-	ID          int64
-	Username    string
-	DisplayName string
-	CreatedAt   int64
-	UpdatedAt   int64
+	ID               int64
+	Username         string
+	DisplayName      string
+	RequireTwoFactor bool
+	CreatedAt        int64
+	UpdatedAt        int64
 }
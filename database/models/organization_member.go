@@ -0,0 +1,14 @@
+package models
+
+const (
+	OrganizationRoleOwner  = "owner"
+	OrganizationRoleMember = "member"
+)
+
+type OrganizationMember struct {
+	ID             int64
+	OrganizationID int64
+	UserID         int64
+	Role           string
+	CreatedAt      int64
+}
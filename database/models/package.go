@@ -0,0 +1,22 @@
+package models
+
+// Types of packages
+const (
+	PackageTypeNPM = "npm"
+	PackageTypeRaw = "raw"
+)
+
+// Package is a package of a user or an organization, published with the protocol of its type
+type Package struct {
+	ID          int64
+	Type        string
+	Name        string
+	OwnerUserID *int64
+	OwnerOrgID  *int64
+	// RepositoryID is the repository whose visibility and permissions the package has, nil for a
+	// package only its owner can use
+	RepositoryID *int64
+	CreatedAt    int64
+	// UpdatedAt is when a version was last published
+	UpdatedAt int64
+}
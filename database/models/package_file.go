@@ -0,0 +1,14 @@
+package models
+
+// PackageFile is a file of a package version, such as the tarball of an npm version
+type PackageFile struct {
+	ID        int64
+	VersionID int64
+	Name      string
+	Size      int64
+	// SHA1, SHA256 and SHA512 are the hex digests of the content
+	SHA1      string
+	SHA256    string
+	SHA512    string
+	CreatedAt int64
+}
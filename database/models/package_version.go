@@ -0,0 +1,23 @@
+package models
+
+// PackageVersion is a published version of a package
+type PackageVersion struct {
+	ID        int64
+	PackageID int64
+	Version   string
+	// Metadata is the package.json of npm versions as published, empty for raw versions
+	Metadata    string
+	PublisherID *int64
+	CreatedAt   int64
+}
+
+// PackageDistTag is an npm dist-tag, a name such as latest pointing to a version of a package
+type PackageDistTag struct {
+	ID        int64
+	PackageID int64
+	Name      string
+	VersionID int64
+	// Version is the version the tag points to
+	Version   string
+	UpdatedAt int64
+}
@@ -0,0 +1,26 @@
+package models
+
+const (
+	PasskeyCeremonyRegistration   = "registration"
+	PasskeyCeremonyAuthentication = "authentication"
+)
+
+type Passkey struct {
+	ID           int64
+	UserID       int64
+	Name         string
+	CredentialID string // base64url encoded, as sent by the browser
+	PublicKey    []byte // COSE encoded public key
+	SignCount    uint32
+	LastUsedAt   *int64
+	CreatedAt    int64
+}
+
+type PasskeyChallenge struct {
+	ID            int64
+	UserID        *int64 // nil for passwordless sign-in, where the user is not known yet
+	Ceremony      string
+	ChallengeHash string
+	ExpiresAt     int64
+	CreatedAt     int64
+}
@@ -0,0 +1,11 @@
+package models
+
+type PasswordResetToken struct {
+	ID          int64
+	UserID      int64
+	TokenHash   string
+	ExpiresAt   int64
+	UsedAt      *int64
+	RequestedIP *string
+	CreatedAt   int64
+}
@@ -0,0 +1,12 @@
+package models
+
+// RegistryBlob links a blob of the container registry to a repository it was pushed to. The blob
+// file itself is stored once on disk by digest.
+type RegistryBlob struct {
+	ID           int64
+	RepositoryID int64
+	// Digest is the content address of the blob, such as sha256:<hex>
+	Digest    string
+	Size      int64
+	CreatedAt int64
+}
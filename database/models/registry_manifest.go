@@ -0,0 +1,17 @@
+package models
+
+// RegistryManifest is a manifest of a container image of a repository, an image manifest or an
+// index of manifests
+type RegistryManifest struct {
+	ID           int64
+	RepositoryID int64
+	// Image is the path of the image below the repository, empty for the image named after it
+	Image     string
+	Digest    string
+	MediaType string
+	// Content is the manifest exactly as pushed, its digest depends on every byte
+	Content []byte
+	// SubjectDigest is the manifest this one refers to, such as the image of a signature
+	SubjectDigest *string
+	CreatedAt     int64
+}
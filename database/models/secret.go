@@ -0,0 +1,15 @@
+package models
+
+// Secret is a value workflow jobs of a repository, or of every repository of an organization, see
+// as an environment variable. It is only ever decrypted for the jobs.
+type Secret struct {
+	ID             int64
+	RepositoryID   *int64
+	OrganizationID *int64
+	Name           string
+	// EncryptedValue is the nonce and ciphertext of the value, see services.SecretService
+	EncryptedValue []byte
+	CreatorID      *int64
+	CreatedAt      int64
+	UpdatedAt      int64
+}
@@ -0,0 +1,12 @@
+package models
+
+type Session struct {
+	ID         int64
+	UserID     int64
+	TokenHash  string
+	UserAgent  string
+	IPAddress  string
+	LastSeenAt int64
+	ExpiresAt  int64
+	CreatedAt  int64
+}
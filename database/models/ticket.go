@@ -10,6 +10,7 @@ type Ticket struct {
 	AuthorID     int64
 	ClosedAt     *int64
 	ClosedByID   *int64
+	MilestoneID  *int64
 	CreatedAt    int64
 	UpdatedAt    int64
 }
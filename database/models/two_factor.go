@@ -0,0 +1,26 @@
+package models
+
+type TwoFactorCredential struct {
+	UserID       int64
+	Secret       string // base32 encoded TOTP secret
+	ConfirmedAt  *int64
+	LastUsedStep int64 // last accepted TOTP time step, so a code can't be replayed
+	CreatedAt    int64
+}
+
+type TwoFactorRecoveryCode struct {
+	ID        int64
+	UserID    int64
+	CodeHash  string
+	UsedAt    *int64
+	CreatedAt int64
+}
+
+type TwoFactorChallenge struct {
+	ID        int64
+	UserID    int64
+	TokenHash string
+	Attempts  int
+	ExpiresAt int64
+	CreatedAt int64
+}
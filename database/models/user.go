@@ -6,7 +6,8 @@ type User struct {
 	Email         string
 	DisplayName   string
 	Password      *string
-	GitHubUserID  *string
+	IsAdmin       bool
+	SuspendedAt   *int64 // nil unless an administrator suspended the account
 	CreatedAt     int64
 	UpdatedAt     int64
 }
@@ -0,0 +1,19 @@
+package models
+
+type UserEmail struct {
+	ID         int64
+	UserID     int64
+	Email      string
+	IsPrimary  bool
+	VerifiedAt *int64
+	CreatedAt  int64
+}
+
+type EmailVerificationToken struct {
+	ID          int64
+	UserEmailID int64
+	TokenHash   string
+	ExpiresAt   int64
+	UsedAt      *int64
+	CreatedAt   int64
+}
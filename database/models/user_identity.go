@@ -0,0 +1,10 @@
+package models
+
+// UserIdentity links a user to their account at an identity provider
+type UserIdentity struct {
+	ID        int64
+	UserID    int64
+	Provider  string // ID of the provider, such as "github"
+	Subject   string // the provider's stable ID of the account
+	CreatedAt int64
+}
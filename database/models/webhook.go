@@ -0,0 +1,84 @@
+package models
+
+import "slices"
+
+// Webhook events. Ping is sent when a webhook is created and can't be selected.
+const (
+	WebhookEventPing          = "ping"
+	WebhookEventPush          = "push"
+	WebhookEventTicket        = "ticket"
+	WebhookEventTicketComment = "ticket_comment"
+	WebhookEventStar          = "star"
+	WebhookEventRepository    = "repository"
+	WebhookEventRelease       = "release"
+)
+
+// WebhookEvents lists the events a webhook can subscribe to, in the order they are offered
+var WebhookEvents = []string{
+	WebhookEventPush,
+	WebhookEventTicket,
+	WebhookEventTicketComment,
+	WebhookEventStar,
+	WebhookEventRepository,
+	WebhookEventRelease,
+}
+
+// Webhook content types
+const (
+	WebhookContentTypeJSON = "json"
+	WebhookContentTypeForm = "form"
+)
+
+// Webhook posts events of a repository, or of every repository of an organization, to a URL
+type Webhook struct {
+	ID             int64
+	RepositoryID   *int64
+	OrganizationID *int64
+	URL            string
+	// Secret signs the payloads, empty if they aren't signed
+	Secret      string
+	ContentType string // 'json' or 'form'
+	Events      []string
+	Active      bool
+	CreatedAt   int64
+	UpdatedAt   int64
+}
+
+// Subscribes reports whether the webhook is delivered the event
+func (h *Webhook) Subscribes(event string) bool {
+	return event == WebhookEventPing || slices.Contains(h.Events, event)
+}
+
+// WebhookDelivery is an event queued for a webhook, along with the request and response of its
+// latest attempt. Redeliveries are new deliveries with the same GUID and payload.
+type WebhookDelivery struct {
+	ID              int64
+	WebhookID       int64
+	GUID            string
+	Event           string
+	Action          *string
+	Payload         string
+	Redelivery      bool
+	Status          string // 'pending', 'delivered' or 'failed'
+	Attempts        int64
+	NextAttemptAt   int64
+	RequestHeaders  *string
+	ResponseStatus  *int64
+	ResponseHeaders *string
+	ResponseBody    *string
+	LastError       *string
+	DurationMS      *int64
+	DeliveredAt     *int64
+	CreatedAt       int64
+}
+
+// WebhookAttempt is the outcome of sending a delivery once
+type WebhookAttempt struct {
+	RequestHeaders  string
+	ResponseStatus  *int64
+	ResponseHeaders *string
+	ResponseBody    *string
+	// Error is why the attempt failed, empty if the receiver answered with 2xx
+	Error      string
+	DurationMS int64
+}
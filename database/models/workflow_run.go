@@ -0,0 +1,56 @@
+package models
+
+// Workflow run and job statuses
+const (
+	WorkflowStatusQueued    = "queued"
+	WorkflowStatusRunning   = "running"
+	WorkflowStatusCompleted = "completed"
+)
+
+// Conclusions of completed workflow runs and jobs
+const (
+	WorkflowConclusionSuccess   = "success"
+	WorkflowConclusionFailure   = "failure"
+	WorkflowConclusionCancelled = "cancelled"
+)
+
+// WorkflowRun is one execution of a workflow of .hypercommit/workflows for an event
+type WorkflowRun struct {
+	ID           int64
+	RepositoryID int64
+	// Number counts the runs of the repository, it is what the UI shows
+	Number int64
+	// Workflow is the path of the workflow file, Name the name it declares
+	Workflow string
+	Name     string
+	Event    string // 'push', 'tag' or 'ticket'
+	Ref      string
+	SHA      string
+	Status   string // 'queued', 'running' or 'completed'
+	// Conclusion is set once the run completed
+	Conclusion    *string
+	TriggeredByID *int64
+	StartedAt     *int64
+	FinishedAt    *int64
+	CreatedAt     int64
+}
+
+// WorkflowJob is a job of a run, a matrix job has one per combination. Runners claim queued jobs
+// and execute their steps.
+type WorkflowJob struct {
+	ID           int64
+	RunID        int64
+	RepositoryID int64
+	Name         string
+	// Definition is the JSON of the services.WorkflowJobSpec the runner executes
+	Definition string
+	Status     string
+	Conclusion *string
+	RunnerName *string
+	// LogSize is the size of the log in bytes, the log itself is read separately
+	LogSize     int64
+	HeartbeatAt *int64
+	StartedAt   *int64
+	FinishedAt  *int64
+	CreatedAt   int64
+}
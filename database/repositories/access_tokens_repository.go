@@ -3,17 +3,23 @@ package repositories
 import (
 	"database/sql"
 	"errors"
+	"strings"
 
 	"github.com/hypercommithq/hypercommit/database/models"
 )
 
 type AccessTokensRepository interface {
-	Create(userID int64, name, tokenHash string) (*models.AccessToken, error)
+	Create(userID int64, name, tokenHash string, scopes []string, expiresAt *int64, repositoryIDs []int64) (*models.AccessToken, error)
+	// CreateForApplication creates a token issued to an OAuth application
+	CreateForApplication(userID, applicationID int64, name, tokenHash string, scopes []string, expiresAt int64) (*models.AccessToken, error)
 	FindByID(id int64) (*models.AccessToken, error)
 	FindByTokenHash(tokenHash string) (*models.AccessToken, error)
+	// FindByUserID returns the personal access tokens of the user, not those of OAuth applications
 	FindByUserID(userID int64) ([]*models.AccessToken, error)
 	UpdateLastUsed(id int64) error
 	Delete(id int64) error
+	DeleteAllByUserID(userID int64) error
+	DeleteByApplicationAndUser(applicationID, userID int64) error
 }
 
 type accessTokensRepository struct {
@@ -24,104 +30,64 @@ func NewAccessTokensRepository(db *sql.DB) AccessTokensRepository {
 	return &accessTokensRepository{db: db}
 }
 
-func (r *accessTokensRepository) Create(userID int64, name, tokenHash string) (*models.AccessToken, error) {
+func (r *accessTokensRepository) Create(userID int64, name, tokenHash string, scopes []string, expiresAt *int64, repositoryIDs []int64) (*models.AccessToken, error) {
 	query := `
-		INSERT INTO access_tokens (user_id, name, token_hash)
-		VALUES (?, ?, ?)
-		RETURNING id, user_id, name, token_hash, last_used_at, created_at
+		INSERT INTO access_tokens (user_id, name, token_hash, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, user_id, name, token_hash, scopes, expires_at, application_id, last_used_at, created_at
 	`
 
-	token := &models.AccessToken{}
-	var lastUsedAt sql.NullInt64
-	err := r.db.QueryRow(query, userID, name, tokenHash).Scan(
-		&token.ID,
-		&token.UserID,
-		&token.Name,
-		&token.TokenHash,
-		&lastUsedAt,
-		&token.CreatedAt,
-	)
+	token, err := scanAccessToken(r.db.QueryRow(query, userID, name, tokenHash, strings.Join(scopes, " "), expiresAt))
 	if err != nil {
 		return nil, err
 	}
 
-	// Convert sql.NullInt64 to *int64
-	if lastUsedAt.Valid {
-		token.LastUsedAt = &lastUsedAt.Int64
+	for _, repositoryID := range repositoryIDs {
+		_, err := r.db.Exec(`INSERT INTO access_token_repositories (access_token_id, repository_id) VALUES (?, ?)`, token.ID, repositoryID)
+		if err != nil {
+			return nil, err
+		}
 	}
+	token.RepositoryIDs = repositoryIDs
 
 	return token, nil
 }
 
+func (r *accessTokensRepository) CreateForApplication(userID, applicationID int64, name, tokenHash string, scopes []string, expiresAt int64) (*models.AccessToken, error) {
+	query := `
+		INSERT INTO access_tokens (user_id, name, token_hash, scopes, expires_at, application_id)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, user_id, name, token_hash, scopes, expires_at, application_id, last_used_at, created_at
+	`
+
+	return scanAccessToken(r.db.QueryRow(query, userID, name, tokenHash, strings.Join(scopes, " "), expiresAt, applicationID))
+}
+
 func (r *accessTokensRepository) FindByID(id int64) (*models.AccessToken, error) {
 	query := `
-		SELECT id, user_id, name, token_hash, last_used_at, created_at
+		SELECT id, user_id, name, token_hash, scopes, expires_at, application_id, last_used_at, created_at
 		FROM access_tokens
 		WHERE id = ?
 	`
 
-	token := &models.AccessToken{}
-	var lastUsedAt sql.NullInt64
-	err := r.db.QueryRow(query, id).Scan(
-		&token.ID,
-		&token.UserID,
-		&token.Name,
-		&token.TokenHash,
-		&lastUsedAt,
-		&token.CreatedAt,
-	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	// Convert sql.NullInt64 to *int64
-	if lastUsedAt.Valid {
-		token.LastUsedAt = &lastUsedAt.Int64
-	}
-
-	return token, nil
+	return r.findOne(query, id)
 }
 
 func (r *accessTokensRepository) FindByTokenHash(tokenHash string) (*models.AccessToken, error) {
 	query := `
-		SELECT id, user_id, name, token_hash, last_used_at, created_at
+		SELECT id, user_id, name, token_hash, scopes, expires_at, application_id, last_used_at, created_at
 		FROM access_tokens
 		WHERE token_hash = ?
 	`
 
-	token := &models.AccessToken{}
-	var lastUsedAt sql.NullInt64
-	err := r.db.QueryRow(query, tokenHash).Scan(
-		&token.ID,
-		&token.UserID,
-		&token.Name,
-		&token.TokenHash,
-		&lastUsedAt,
-		&token.CreatedAt,
-	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	// Convert sql.NullInt64 to *int64
-	if lastUsedAt.Valid {
-		token.LastUsedAt = &lastUsedAt.Int64
-	}
-
-	return token, nil
+	return r.findOne(query, tokenHash)
 }
 
 func (r *accessTokensRepository) FindByUserID(userID int64) ([]*models.AccessToken, error) {
 	query := `
-		SELECT id, user_id, name, token_hash, last_used_at, created_at
+		SELECT id, user_id, name, token_hash, scopes, expires_at, application_id, last_used_at, created_at
 		FROM access_tokens
-		WHERE user_id = ?
+		WHERE user_id = ? AND application_id IS NULL
 		ORDER BY created_at DESC
 	`
 
@@ -129,30 +95,27 @@ func (r *accessTokensRepository) FindByUserID(userID int64) ([]*models.AccessTok
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
 	var tokens []*models.AccessToken
 	for rows.Next() {
-		token := &models.AccessToken{}
-		var lastUsedAt sql.NullInt64
-		err := rows.Scan(
-			&token.ID,
-			&token.UserID,
-			&token.Name,
-			&token.TokenHash,
-			&lastUsedAt,
-			&token.CreatedAt,
-		)
+		token, err := scanAccessToken(rows)
 		if err != nil {
+			rows.Close()
 			return nil, err
 		}
+		tokens = append(tokens, token)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-		// Convert sql.NullInt64 to *int64
-		if lastUsedAt.Valid {
-			token.LastUsedAt = &lastUsedAt.Int64
+	// Loaded after the rows are closed, the database only has a single connection
+	for _, token := range tokens {
+		token.RepositoryIDs, err = r.findRepositoryIDs(token.ID)
+		if err != nil {
+			return nil, err
 		}
-
-		tokens = append(tokens, token)
 	}
 
 	return tokens, nil
@@ -199,5 +162,94 @@ func (r *accessTokensRepository) Delete(id int64) error {
 		return sql.ErrNoRows
 	}
 
-	return nil
+	_, err = r.db.Exec(`DELETE FROM access_token_repositories WHERE access_token_id = ?`, id)
+	return err
+}
+
+func (r *accessTokensRepository) DeleteAllByUserID(userID int64) error {
+	query := `
+		DELETE FROM access_token_repositories
+		WHERE access_token_id IN (SELECT id FROM access_tokens WHERE user_id = ?)
+	`
+
+	if _, err := r.db.Exec(query, userID); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`DELETE FROM access_tokens WHERE user_id = ?`, userID)
+	return err
+}
+
+func (r *accessTokensRepository) DeleteByApplicationAndUser(applicationID, userID int64) error {
+	_, err := r.db.Exec(`DELETE FROM access_tokens WHERE application_id = ? AND user_id = ?`, applicationID, userID)
+	return err
+}
+
+func (r *accessTokensRepository) findOne(query string, args ...any) (*models.AccessToken, error) {
+	token, err := scanAccessToken(r.db.QueryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	token.RepositoryIDs, err = r.findRepositoryIDs(token.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (r *accessTokensRepository) findRepositoryIDs(tokenID int64) ([]int64, error) {
+	query := `
+		SELECT repository_id
+		FROM access_token_repositories
+		WHERE access_token_id = ?
+		ORDER BY repository_id
+	`
+
+	rows, err := r.db.Query(query, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repositoryIDs []int64
+	for rows.Next() {
+		var repositoryID int64
+		if err := rows.Scan(&repositoryID); err != nil {
+			return nil, err
+		}
+		repositoryIDs = append(repositoryIDs, repositoryID)
+	}
+
+	return repositoryIDs, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanAccessToken(row rowScanner) (*models.AccessToken, error) {
+	token := &models.AccessToken{}
+	var scopes string
+	err := row.Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Name,
+		&token.TokenHash,
+		&scopes,
+		&token.ExpiresAt,
+		&token.ApplicationID,
+		&token.LastUsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	return token, nil
 }
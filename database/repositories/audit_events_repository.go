@@ -0,0 +1,136 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+// AuditEventFilter narrows down the audit log of an organization. Empty fields match everything.
+type AuditEventFilter struct {
+	Action string
+	Actor  string // username of the actor
+	Limit  int    // 0 means no limit
+}
+
+type AuditEventsRepository interface {
+	Create(event *models.AuditEvent) (*models.AuditEvent, error)
+	FindAllByUser(userID int64, limit int) ([]*models.AuditEvent, error)
+	FindAllByOrganization(orgID int64, filter AuditEventFilter) ([]*models.AuditEvent, error)
+}
+
+type auditEventsRepository struct {
+	db *sql.DB
+}
+
+func NewAuditEventsRepository(db *sql.DB) AuditEventsRepository {
+	return &auditEventsRepository{db: db}
+}
+
+func (r *auditEventsRepository) Create(event *models.AuditEvent) (*models.AuditEvent, error) {
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if event.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	query := `
+		INSERT INTO audit_events (actor_id, actor_name, action, target_type, target_id, target_name, org_id, ip_address, metadata)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, actor_id, actor_name, action, target_type, target_id, target_name, org_id, ip_address, metadata, created_at
+	`
+
+	return scanAuditEvent(r.db.QueryRow(query,
+		event.ActorID,
+		event.ActorName,
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		event.TargetName,
+		event.OrgID,
+		event.IPAddress,
+		string(metadata),
+	))
+}
+
+// FindAllByUser returns the security log of a user, the events they caused or that targeted
+// their account, newest first
+func (r *auditEventsRepository) FindAllByUser(userID int64, limit int) ([]*models.AuditEvent, error) {
+	query := `
+		SELECT id, actor_id, actor_name, action, target_type, target_id, target_name, org_id, ip_address, metadata, created_at
+		FROM audit_events
+		WHERE actor_id = ? OR (target_type = ? AND target_id = ?)
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`
+
+	return r.findAll(query, userID, models.AuditTargetUser, userID, limit)
+}
+
+// FindAllByOrganization returns the audit log of an organization, newest first
+func (r *auditEventsRepository) FindAllByOrganization(orgID int64, filter AuditEventFilter) ([]*models.AuditEvent, error) {
+	query := `
+		SELECT id, actor_id, actor_name, action, target_type, target_id, target_name, org_id, ip_address, metadata, created_at
+		FROM audit_events
+		WHERE org_id = ?
+			AND (? = '' OR action = ?)
+			AND (? = '' OR actor_name = ? COLLATE NOCASE)
+		ORDER BY created_at DESC, id DESC
+		LIMIT ?
+	`
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = -1
+	}
+
+	return r.findAll(query, orgID, filter.Action, filter.Action, filter.Actor, filter.Actor, limit)
+}
+
+func (r *auditEventsRepository) findAll(query string, args ...any) ([]*models.AuditEvent, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		event, err := scanAuditEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func scanAuditEvent(row rowScanner) (*models.AuditEvent, error) {
+	event := &models.AuditEvent{}
+	var metadata string
+	err := row.Scan(
+		&event.ID,
+		&event.ActorID,
+		&event.ActorName,
+		&event.Action,
+		&event.TargetType,
+		&event.TargetID,
+		&event.TargetName,
+		&event.OrgID,
+		&event.IPAddress,
+		&metadata,
+		&event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(metadata), &event.Metadata); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type AuthLockoutsRepository interface {
+	Create(userID *int64, account, ipAddress string, failures int, lockedUntil int64) (*models.AuthLockout, error)
+}
+
+type authLockoutsRepository struct {
+	db *sql.DB
+}
+
+func NewAuthLockoutsRepository(db *sql.DB) AuthLockoutsRepository {
+	return &authLockoutsRepository{db: db}
+}
+
+func (r *authLockoutsRepository) Create(userID *int64, account, ipAddress string, failures int, lockedUntil int64) (*models.AuthLockout, error) {
+	query := `
+		INSERT INTO auth_lockouts (user_id, account, ip_address, failures, locked_until)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, user_id, account, ip_address, failures, locked_until, created_at
+	`
+
+	lockout := &models.AuthLockout{}
+	err := r.db.QueryRow(query, userID, account, ipAddress, failures, lockedUntil).Scan(
+		&lockout.ID,
+		&lockout.UserID,
+		&lockout.Account,
+		&lockout.IPAddress,
+		&lockout.Failures,
+		&lockout.LockedUntil,
+		&lockout.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return lockout, nil
+}
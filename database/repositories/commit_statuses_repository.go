@@ -0,0 +1,111 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type CommitStatusesRepository interface {
+	// Upsert stores the status of the commit for its context, replacing the one reported before
+	Upsert(status *models.CommitStatus) (*models.CommitStatus, error)
+	// FindByRepositoryAndSHA returns the statuses of the commit ordered by context
+	FindByRepositoryAndSHA(repositoryID int64, sha string) ([]*models.CommitStatus, error)
+	FindPageByRepositoryAndSHA(repositoryID int64, sha string, page Page) ([]*models.CommitStatus, error)
+}
+
+type commitStatusesRepository struct {
+	db *sql.DB
+}
+
+func NewCommitStatusesRepository(db *sql.DB) CommitStatusesRepository {
+	return &commitStatusesRepository{db: db}
+}
+
+const commitStatusColumns = `id, repository_id, sha, context, state, target_url, description, creator_id, created_at, updated_at`
+
+func (r *commitStatusesRepository) Upsert(status *models.CommitStatus) (*models.CommitStatus, error) {
+	query := `
+		INSERT INTO commit_statuses (repository_id, sha, context, state, target_url, description, creator_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(repository_id, sha, context) DO UPDATE SET
+			state = excluded.state,
+			target_url = excluded.target_url,
+			description = excluded.description,
+			creator_id = excluded.creator_id,
+			updated_at = unixepoch()
+		RETURNING ` + commitStatusColumns
+
+	return scanCommitStatus(r.db.QueryRow(query,
+		status.RepositoryID,
+		status.SHA,
+		status.Context,
+		status.State,
+		status.TargetURL,
+		status.Description,
+		status.CreatorID,
+	))
+}
+
+func (r *commitStatusesRepository) FindByRepositoryAndSHA(repositoryID int64, sha string) ([]*models.CommitStatus, error) {
+	query := `
+		SELECT ` + commitStatusColumns + `
+		FROM commit_statuses
+		WHERE repository_id = ? AND sha = ?
+		ORDER BY context ASC
+	`
+
+	return r.findAll(query, repositoryID, sha)
+}
+
+func (r *commitStatusesRepository) FindPageByRepositoryAndSHA(repositoryID int64, sha string, page Page) ([]*models.CommitStatus, error) {
+	query := `
+		SELECT ` + commitStatusColumns + `
+		FROM commit_statuses
+		WHERE repository_id = ? AND sha = ? AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return r.findAll(query, repositoryID, sha, page.After, page.Limit)
+}
+
+func (r *commitStatusesRepository) findAll(query string, args ...any) ([]*models.CommitStatus, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var statuses []*models.CommitStatus
+	for rows.Next() {
+		status, err := scanCommitStatus(rows)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, rows.Err()
+}
+
+func scanCommitStatus(row rowScanner) (*models.CommitStatus, error) {
+	status := &models.CommitStatus{}
+	err := row.Scan(
+		&status.ID,
+		&status.RepositoryID,
+		&status.SHA,
+		&status.Context,
+		&status.State,
+		&status.TargetURL,
+		&status.Description,
+		&status.CreatorID,
+		&status.CreatedAt,
+		&status.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
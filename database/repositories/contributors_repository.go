@@ -12,6 +12,7 @@ type ContributorsRepository interface {
 	FindByID(id int64) (*models.Contributor, error)
 	FindByRepositoryAndUser(repositoryID, userID int64) (*models.Contributor, error)
 	FindAllByRepository(repositoryID int64) ([]*models.Contributor, error)
+	FindPageByRepository(repositoryID int64, page Page) ([]*models.Contributor, error)
 	FindAllByUser(userID int64) ([]*models.Contributor, error)
 	UpdateRole(id int64, role string) error
 	Delete(id int64) error
@@ -106,7 +107,23 @@ func (r *contributorsRepository) FindAllByRepository(repositoryID int64) ([]*mod
 		ORDER BY created_at ASC
 	`
 
-	rows, err := r.db.Query(query, repositoryID)
+	return r.findAll(query, repositoryID)
+}
+
+func (r *contributorsRepository) FindPageByRepository(repositoryID int64, page Page) ([]*models.Contributor, error) {
+	query := `
+		SELECT id, repository_id, user_id, role, created_at
+		FROM contributors
+		WHERE repository_id = ? AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return r.findAll(query, repositoryID, page.After, page.Limit)
+}
+
+func (r *contributorsRepository) findAll(query string, args ...any) ([]*models.Contributor, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type EmailOutboxRepository interface {
+	Enqueue(toAddress, subject, textBody string, htmlBody *string) (*models.OutboxEmail, error)
+	FindDue(now int64, limit int) ([]*models.OutboxEmail, error)
+	MarkSent(id int64) error
+	MarkAttemptFailed(id int64, lastError string, nextAttemptAt int64) error
+	MarkFailed(id int64, lastError string) error
+}
+
+type emailOutboxRepository struct {
+	db *sql.DB
+}
+
+func NewEmailOutboxRepository(db *sql.DB) EmailOutboxRepository {
+	return &emailOutboxRepository{db: db}
+}
+
+func (r *emailOutboxRepository) Enqueue(toAddress, subject, textBody string, htmlBody *string) (*models.OutboxEmail, error) {
+	query := `
+		INSERT INTO email_outbox (to_address, subject, text_body, html_body)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, to_address, subject, text_body, html_body, status, attempts, last_error, next_attempt_at, sent_at, created_at
+	`
+
+	email := &models.OutboxEmail{}
+	err := r.db.QueryRow(query, toAddress, subject, textBody, htmlBody).Scan(
+		&email.ID,
+		&email.ToAddress,
+		&email.Subject,
+		&email.TextBody,
+		&email.HTMLBody,
+		&email.Status,
+		&email.Attempts,
+		&email.LastError,
+		&email.NextAttemptAt,
+		&email.SentAt,
+		&email.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return email, nil
+}
+
+// FindDue returns pending emails whose next attempt is due, oldest first
+func (r *emailOutboxRepository) FindDue(now int64, limit int) ([]*models.OutboxEmail, error) {
+	query := `
+		SELECT id, to_address, subject, text_body, html_body, status, attempts, last_error, next_attempt_at, sent_at, created_at
+		FROM email_outbox
+		WHERE status = 'pending' AND next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, now, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []*models.OutboxEmail
+	for rows.Next() {
+		email := &models.OutboxEmail{}
+		err := rows.Scan(
+			&email.ID,
+			&email.ToAddress,
+			&email.Subject,
+			&email.TextBody,
+			&email.HTMLBody,
+			&email.Status,
+			&email.Attempts,
+			&email.LastError,
+			&email.NextAttemptAt,
+			&email.SentAt,
+			&email.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, rows.Err()
+}
+
+func (r *emailOutboxRepository) MarkSent(id int64) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'sent', attempts = attempts + 1, last_error = NULL, sent_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// MarkAttemptFailed records a failed attempt and schedules the next one
+func (r *emailOutboxRepository) MarkAttemptFailed(id int64, lastError string, nextAttemptAt int64) error {
+	query := `
+		UPDATE email_outbox
+		SET attempts = attempts + 1, last_error = ?, next_attempt_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, lastError, nextAttemptAt, id)
+	return err
+}
+
+// MarkFailed gives up on an email after its last attempt
+func (r *emailOutboxRepository) MarkFailed(id int64, lastError string) error {
+	query := `
+		UPDATE email_outbox
+		SET status = 'failed', attempts = attempts + 1, last_error = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, lastError, id)
+	return err
+}
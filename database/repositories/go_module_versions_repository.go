@@ -0,0 +1,86 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type GoModuleVersionsRepository interface {
+	// Create records the version, or returns the one recorded in the meantime
+	Create(version *models.GoModuleVersion) (*models.GoModuleVersion, error)
+	FindByVersion(repositoryID int64, version string) (*models.GoModuleVersion, error)
+	// UpdateZip records the checksum and size of the zip built for the version
+	UpdateZip(id int64, hash string, size int64) error
+	// DeleteAllByRepository deletes the versions of a repository being deleted
+	DeleteAllByRepository(repositoryID int64) error
+}
+
+type goModuleVersionsRepository struct {
+	db *sql.DB
+}
+
+func NewGoModuleVersionsRepository(db *sql.DB) GoModuleVersionsRepository {
+	return &goModuleVersionsRepository{db: db}
+}
+
+func (r *goModuleVersionsRepository) Create(version *models.GoModuleVersion) (*models.GoModuleVersion, error) {
+	query := `
+		INSERT INTO go_module_versions (repository_id, version, commit_sha, go_mod, commit_time)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (repository_id, version) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query,
+		version.RepositoryID,
+		version.Version,
+		version.CommitSHA,
+		version.GoMod,
+		version.CommitTime,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindByVersion(version.RepositoryID, version.Version)
+}
+
+func (r *goModuleVersionsRepository) FindByVersion(repositoryID int64, version string) (*models.GoModuleVersion, error) {
+	query := `
+		SELECT id, repository_id, version, commit_sha, go_mod, commit_time, zip_hash, zip_size, created_at
+		FROM go_module_versions
+		WHERE repository_id = ? AND version = ?
+	`
+
+	moduleVersion := &models.GoModuleVersion{}
+	err := r.db.QueryRow(query, repositoryID, version).Scan(
+		&moduleVersion.ID,
+		&moduleVersion.RepositoryID,
+		&moduleVersion.Version,
+		&moduleVersion.CommitSHA,
+		&moduleVersion.GoMod,
+		&moduleVersion.CommitTime,
+		&moduleVersion.ZipHash,
+		&moduleVersion.ZipSize,
+		&moduleVersion.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return moduleVersion, nil
+}
+
+func (r *goModuleVersionsRepository) UpdateZip(id int64, hash string, size int64) error {
+	_, err := r.db.Exec(`UPDATE go_module_versions SET zip_hash = ?, zip_size = ? WHERE id = ?`, hash, size, id)
+	return err
+}
+
+func (r *goModuleVersionsRepository) DeleteAllByRepository(repositoryID int64) error {
+	_, err := r.db.Exec(`DELETE FROM go_module_versions WHERE repository_id = ?`, repositoryID)
+	return err
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"database/sql"
+	"strconv"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+// Keys of instance_settings
+const (
+	settingSignUpDisabled               = "sign_up_disabled"
+	settingWebhooksAllowPrivateNetworks = "webhooks_allow_private_networks"
+)
+
+type InstanceSettingsRepository interface {
+	Find() (*models.InstanceSettings, error)
+	Update(settings *models.InstanceSettings) error
+	Stats() (*models.SystemStats, error)
+}
+
+type instanceSettingsRepository struct {
+	db *sql.DB
+}
+
+func NewInstanceSettingsRepository(db *sql.DB) InstanceSettingsRepository {
+	return &instanceSettingsRepository{db: db}
+}
+
+// Find returns the instance settings, with defaults for those that were never changed
+func (r *instanceSettingsRepository) Find() (*models.InstanceSettings, error) {
+	rows, err := r.db.Query(`SELECT key, value FROM instance_settings`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := &models.InstanceSettings{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+
+		switch key {
+		case settingSignUpDisabled:
+			settings.SignUpDisabled, _ = strconv.ParseBool(value)
+		case settingWebhooksAllowPrivateNetworks:
+			settings.WebhooksAllowPrivateNetworks, _ = strconv.ParseBool(value)
+		}
+	}
+
+	return settings, rows.Err()
+}
+
+func (r *instanceSettingsRepository) Update(settings *models.InstanceSettings) error {
+	values := map[string]string{
+		settingSignUpDisabled:               strconv.FormatBool(settings.SignUpDisabled),
+		settingWebhooksAllowPrivateNetworks: strconv.FormatBool(settings.WebhooksAllowPrivateNetworks),
+	}
+
+	query := `
+		INSERT INTO instance_settings (key, value)
+		VALUES (?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = unixepoch()
+	`
+	for key, value := range values {
+		if _, err := r.db.Exec(query, key, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *instanceSettingsRepository) Stats() (*models.SystemStats, error) {
+	query := `
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(*) FROM users WHERE is_admin = 1),
+			(SELECT COUNT(*) FROM users WHERE suspended_at IS NOT NULL),
+			(SELECT COUNT(*) FROM organizations),
+			(SELECT COUNT(*) FROM repositories),
+			(SELECT COUNT(*) FROM tickets)
+	`
+
+	stats := &models.SystemStats{}
+	err := r.db.QueryRow(query).Scan(
+		&stats.Users,
+		&stats.Admins,
+		&stats.SuspendedUsers,
+		&stats.Organizations,
+		&stats.Repositories,
+		&stats.Tickets,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,245 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type MilestonesRepository interface {
+	Create(repositoryID int64, title string, description *string, dueDate *int64) (*models.Milestone, error)
+	FindByID(id int64) (*models.Milestone, error)
+	FindByRepositoryAndTitle(repositoryID int64, title string) (*models.Milestone, error)
+	FindAllByRepository(repositoryID int64, status string) ([]*models.Milestone, error)
+	CountByRepository(repositoryID int64, status string) (int64, error)
+	CountTickets(milestoneID int64, status string) (int64, error)
+	Update(milestone *models.Milestone) error
+	Close(id int64) error
+	Reopen(id int64) error
+	MoveOpenTickets(fromID int64, toID *int64) error
+	Delete(id int64) error
+}
+
+type milestonesRepository struct {
+	db *sql.DB
+}
+
+func NewMilestonesRepository(db *sql.DB) MilestonesRepository {
+	return &milestonesRepository{db: db}
+}
+
+func (r *milestonesRepository) Create(repositoryID int64, title string, description *string, dueDate *int64) (*models.Milestone, error) {
+	query := `
+		INSERT INTO milestones (repository_id, title, description, due_date)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, repository_id, title, description, due_date, status, closed_at, created_at, updated_at
+	`
+
+	milestone := &models.Milestone{}
+	err := r.db.QueryRow(query, repositoryID, title, description, dueDate).Scan(
+		&milestone.ID,
+		&milestone.RepositoryID,
+		&milestone.Title,
+		&milestone.Description,
+		&milestone.DueDate,
+		&milestone.Status,
+		&milestone.ClosedAt,
+		&milestone.CreatedAt,
+		&milestone.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return milestone, nil
+}
+
+func (r *milestonesRepository) FindByID(id int64) (*models.Milestone, error) {
+	query := `
+		SELECT id, repository_id, title, description, due_date, status, closed_at, created_at, updated_at
+		FROM milestones
+		WHERE id = ?
+	`
+
+	milestone := &models.Milestone{}
+	err := r.db.QueryRow(query, id).Scan(
+		&milestone.ID,
+		&milestone.RepositoryID,
+		&milestone.Title,
+		&milestone.Description,
+		&milestone.DueDate,
+		&milestone.Status,
+		&milestone.ClosedAt,
+		&milestone.CreatedAt,
+		&milestone.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return milestone, nil
+}
+
+func (r *milestonesRepository) FindByRepositoryAndTitle(repositoryID int64, title string) (*models.Milestone, error) {
+	query := `
+		SELECT id, repository_id, title, description, due_date, status, closed_at, created_at, updated_at
+		FROM milestones
+		WHERE repository_id = ? AND title = ?
+	`
+
+	milestone := &models.Milestone{}
+	err := r.db.QueryRow(query, repositoryID, title).Scan(
+		&milestone.ID,
+		&milestone.RepositoryID,
+		&milestone.Title,
+		&milestone.Description,
+		&milestone.DueDate,
+		&milestone.Status,
+		&milestone.ClosedAt,
+		&milestone.CreatedAt,
+		&milestone.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return milestone, nil
+}
+
+func (r *milestonesRepository) FindAllByRepository(repositoryID int64, status string) ([]*models.Milestone, error) {
+	query := `
+		SELECT id, repository_id, title, description, due_date, status, closed_at, created_at, updated_at
+		FROM milestones
+		WHERE repository_id = ?
+	`
+
+	args := []interface{}{repositoryID}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	// Milestones without a due date are listed after the dated ones
+	query += " ORDER BY due_date IS NULL, due_date ASC, title ASC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var milestones []*models.Milestone
+	for rows.Next() {
+		milestone := &models.Milestone{}
+		err := rows.Scan(
+			&milestone.ID,
+			&milestone.RepositoryID,
+			&milestone.Title,
+			&milestone.Description,
+			&milestone.DueDate,
+			&milestone.Status,
+			&milestone.ClosedAt,
+			&milestone.CreatedAt,
+			&milestone.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		milestones = append(milestones, milestone)
+	}
+
+	return milestones, nil
+}
+
+func (r *milestonesRepository) CountByRepository(repositoryID int64, status string) (int64, error) {
+	query := `SELECT COUNT(*) FROM milestones WHERE repository_id = ?`
+	args := []interface{}{repositoryID}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	var count int64
+	err := r.db.QueryRow(query, args...).Scan(&count)
+	return count, err
+}
+
+func (r *milestonesRepository) CountTickets(milestoneID int64, status string) (int64, error) {
+	query := `SELECT COUNT(*) FROM tickets WHERE milestone_id = ?`
+	args := []interface{}{milestoneID}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	var count int64
+	err := r.db.QueryRow(query, args...).Scan(&count)
+	return count, err
+}
+
+func (r *milestonesRepository) Update(milestone *models.Milestone) error {
+	query := `
+		UPDATE milestones
+		SET title = ?, description = ?, due_date = ?, updated_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, milestone.Title, milestone.Description, milestone.DueDate, milestone.ID)
+	return err
+}
+
+func (r *milestonesRepository) Close(id int64) error {
+	query := `
+		UPDATE milestones
+		SET status = 'closed', closed_at = unixepoch(), updated_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *milestonesRepository) Reopen(id int64) error {
+	query := `
+		UPDATE milestones
+		SET status = 'open', closed_at = NULL, updated_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+// MoveOpenTickets reassigns every open ticket of a milestone to another
+// milestone, or removes them from any milestone when toID is nil
+func (r *milestonesRepository) MoveOpenTickets(fromID int64, toID *int64) error {
+	query := `
+		UPDATE tickets
+		SET milestone_id = ?, updated_at = unixepoch()
+		WHERE milestone_id = ? AND status = 'open'
+	`
+
+	_, err := r.db.Exec(query, toID, fromID)
+	return err
+}
+
+func (r *milestonesRepository) Delete(id int64) error {
+	// Detach tickets first, foreign keys are not enforced by SQLite by default
+	_, err := r.db.Exec(`UPDATE tickets SET milestone_id = NULL WHERE milestone_id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	query := `DELETE FROM milestones WHERE id = ?`
+	_, err = r.db.Exec(query, id)
+	return err
+}
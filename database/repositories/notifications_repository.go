@@ -0,0 +1,258 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type NotificationsRepository interface {
+	// Subscriptions
+	Subscribe(ticketID, userID int64, reason string) error
+	SetSubscribed(ticketID, userID int64, subscribed bool) error
+	FindSubscription(ticketID, userID int64) (*models.TicketSubscription, error)
+	FindSubscriberIDs(ticketID int64) ([]int64, error)
+
+	// Notifications
+	Create(userID, ticketID, actorID int64, commentID *int64, reason string) (*models.Notification, error)
+	FindByID(id int64) (*models.Notification, error)
+	FindAllByUser(userID int64, filter string) ([]*models.Notification, error)
+	CountUnread(userID int64) (int64, error)
+	MarkRead(id, userID int64) error
+	MarkAllRead(userID int64) error
+	MarkDone(id, userID int64) error
+}
+
+type notificationsRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationsRepository(db *sql.DB) NotificationsRepository {
+	return &notificationsRepository{db: db}
+}
+
+// Subscribe subscribes a user to a ticket unless a subscription row already exists.
+// An existing row is left untouched, so users who explicitly unsubscribed stay unsubscribed.
+func (r *notificationsRepository) Subscribe(ticketID, userID int64, reason string) error {
+	query := `
+		INSERT INTO ticket_subscriptions (ticket_id, user_id, reason)
+		VALUES (?, ?, ?)
+		ON CONFLICT(ticket_id, user_id) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query, ticketID, userID, reason)
+	return err
+}
+
+// SetSubscribed records a manual subscribe or unsubscribe, overriding any previous state
+func (r *notificationsRepository) SetSubscribed(ticketID, userID int64, subscribed bool) error {
+	query := `
+		INSERT INTO ticket_subscriptions (ticket_id, user_id, reason, subscribed)
+		VALUES (?, ?, 'manual', ?)
+		ON CONFLICT(ticket_id, user_id) DO UPDATE SET reason = 'manual', subscribed = excluded.subscribed
+	`
+
+	_, err := r.db.Exec(query, ticketID, userID, subscribed)
+	return err
+}
+
+func (r *notificationsRepository) FindSubscription(ticketID, userID int64) (*models.TicketSubscription, error) {
+	query := `
+		SELECT id, ticket_id, user_id, reason, subscribed, created_at, updated_at
+		FROM ticket_subscriptions
+		WHERE ticket_id = ? AND user_id = ?
+	`
+
+	subscription := &models.TicketSubscription{}
+	err := r.db.QueryRow(query, ticketID, userID).Scan(
+		&subscription.ID,
+		&subscription.TicketID,
+		&subscription.UserID,
+		&subscription.Reason,
+		&subscription.Subscribed,
+		&subscription.CreatedAt,
+		&subscription.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+func (r *notificationsRepository) FindSubscriberIDs(ticketID int64) ([]int64, error) {
+	query := `
+		SELECT user_id
+		FROM ticket_subscriptions
+		WHERE ticket_id = ? AND subscribed = 1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.Query(query, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userIDs []int64
+	for rows.Next() {
+		var userID int64
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
+
+func (r *notificationsRepository) Create(userID, ticketID, actorID int64, commentID *int64, reason string) (*models.Notification, error) {
+	query := `
+		INSERT INTO notifications (user_id, ticket_id, actor_id, comment_id, reason)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, user_id, ticket_id, actor_id, comment_id, reason, read_at, done_at, created_at
+	`
+
+	notification := &models.Notification{}
+	err := r.db.QueryRow(query, userID, ticketID, actorID, commentID, reason).Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.TicketID,
+		&notification.ActorID,
+		&notification.CommentID,
+		&notification.Reason,
+		&notification.ReadAt,
+		&notification.DoneAt,
+		&notification.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+func (r *notificationsRepository) FindByID(id int64) (*models.Notification, error) {
+	query := `
+		SELECT id, user_id, ticket_id, actor_id, comment_id, reason, read_at, done_at, created_at
+		FROM notifications
+		WHERE id = ?
+	`
+
+	notification := &models.Notification{}
+	err := r.db.QueryRow(query, id).Scan(
+		&notification.ID,
+		&notification.UserID,
+		&notification.TicketID,
+		&notification.ActorID,
+		&notification.CommentID,
+		&notification.Reason,
+		&notification.ReadAt,
+		&notification.DoneAt,
+		&notification.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return notification, nil
+}
+
+// FindAllByUser returns a user's notifications, newest first.
+// filter is "unread" for unread items, "done" for items marked as done, anything else for all items not done.
+func (r *notificationsRepository) FindAllByUser(userID int64, filter string) ([]*models.Notification, error) {
+	query := `
+		SELECT id, user_id, ticket_id, actor_id, comment_id, reason, read_at, done_at, created_at
+		FROM notifications
+		WHERE user_id = ?
+	`
+	switch filter {
+	case "unread":
+		query += " AND read_at IS NULL AND done_at IS NULL"
+	case "done":
+		query += " AND done_at IS NOT NULL"
+	default:
+		query += " AND done_at IS NULL"
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT 100"
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*models.Notification
+	for rows.Next() {
+		notification := &models.Notification{}
+		err := rows.Scan(
+			&notification.ID,
+			&notification.UserID,
+			&notification.TicketID,
+			&notification.ActorID,
+			&notification.CommentID,
+			&notification.Reason,
+			&notification.ReadAt,
+			&notification.DoneAt,
+			&notification.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+func (r *notificationsRepository) CountUnread(userID int64) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM notifications
+		WHERE user_id = ? AND read_at IS NULL AND done_at IS NULL
+	`
+
+	var count int64
+	err := r.db.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+func (r *notificationsRepository) MarkRead(id, userID int64) error {
+	query := `
+		UPDATE notifications
+		SET read_at = unixepoch()
+		WHERE id = ? AND user_id = ? AND read_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, id, userID)
+	return err
+}
+
+func (r *notificationsRepository) MarkAllRead(userID int64) error {
+	query := `
+		UPDATE notifications
+		SET read_at = unixepoch()
+		WHERE user_id = ? AND read_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, userID)
+	return err
+}
+
+func (r *notificationsRepository) MarkDone(id, userID int64) error {
+	query := `
+		UPDATE notifications
+		SET done_at = unixepoch(), read_at = COALESCE(read_at, unixepoch())
+		WHERE id = ? AND user_id = ?
+	`
+
+	_, err := r.db.Exec(query, id, userID)
+	return err
+}
@@ -0,0 +1,209 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type OAuthApplicationsRepository interface {
+	Create(ownerUserID, ownerOrgID *int64, name, homepageURL string, redirectURIs []string, clientID, clientSecretHash string, confidential bool) (*models.OAuthApplication, error)
+	FindByID(id int64) (*models.OAuthApplication, error)
+	FindByClientID(clientID string) (*models.OAuthApplication, error)
+	FindAllByOwnerUser(userID int64) ([]*models.OAuthApplication, error)
+	FindAllByOwnerOrg(orgID int64) ([]*models.OAuthApplication, error)
+	// FindAuthorizedByUser returns the applications that hold tokens of the user
+	FindAuthorizedByUser(userID int64) ([]*models.OAuthApplication, error)
+	Update(application *models.OAuthApplication) error
+	UpdateClientSecret(id int64, clientSecretHash string) error
+	// Delete deletes the application and every token and grant issued to it
+	Delete(id int64) error
+}
+
+type oauthApplicationsRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthApplicationsRepository(db *sql.DB) OAuthApplicationsRepository {
+	return &oauthApplicationsRepository{db: db}
+}
+
+func (r *oauthApplicationsRepository) Create(ownerUserID, ownerOrgID *int64, name, homepageURL string, redirectURIs []string, clientID, clientSecretHash string, confidential bool) (*models.OAuthApplication, error) {
+	query := `
+		INSERT INTO oauth_applications (owner_user_id, owner_org_id, name, homepage_url, redirect_uris, client_id, client_secret_hash, confidential)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, owner_user_id, owner_org_id, name, homepage_url, redirect_uris, client_id, client_secret_hash, confidential, created_at, updated_at
+	`
+
+	return scanOAuthApplication(r.db.QueryRow(query, ownerUserID, ownerOrgID, name, homepageURL, strings.Join(redirectURIs, "\n"), clientID, clientSecretHash, confidential))
+}
+
+func (r *oauthApplicationsRepository) FindByID(id int64) (*models.OAuthApplication, error) {
+	query := `
+		SELECT id, owner_user_id, owner_org_id, name, homepage_url, redirect_uris, client_id, client_secret_hash, confidential, created_at, updated_at
+		FROM oauth_applications
+		WHERE id = ?
+	`
+
+	return r.findOne(query, id)
+}
+
+func (r *oauthApplicationsRepository) FindByClientID(clientID string) (*models.OAuthApplication, error) {
+	query := `
+		SELECT id, owner_user_id, owner_org_id, name, homepage_url, redirect_uris, client_id, client_secret_hash, confidential, created_at, updated_at
+		FROM oauth_applications
+		WHERE client_id = ?
+	`
+
+	return r.findOne(query, clientID)
+}
+
+func (r *oauthApplicationsRepository) FindAllByOwnerUser(userID int64) ([]*models.OAuthApplication, error) {
+	query := `
+		SELECT id, owner_user_id, owner_org_id, name, homepage_url, redirect_uris, client_id, client_secret_hash, confidential, created_at, updated_at
+		FROM oauth_applications
+		WHERE owner_user_id = ?
+		ORDER BY name
+	`
+
+	return r.findAll(query, userID)
+}
+
+func (r *oauthApplicationsRepository) FindAllByOwnerOrg(orgID int64) ([]*models.OAuthApplication, error) {
+	query := `
+		SELECT id, owner_user_id, owner_org_id, name, homepage_url, redirect_uris, client_id, client_secret_hash, confidential, created_at, updated_at
+		FROM oauth_applications
+		WHERE owner_org_id = ?
+		ORDER BY name
+	`
+
+	return r.findAll(query, orgID)
+}
+
+func (r *oauthApplicationsRepository) FindAuthorizedByUser(userID int64) ([]*models.OAuthApplication, error) {
+	query := `
+		SELECT id, owner_user_id, owner_org_id, name, homepage_url, redirect_uris, client_id, client_secret_hash, confidential, created_at, updated_at
+		FROM oauth_applications
+		WHERE id IN (SELECT application_id FROM access_tokens WHERE user_id = ? AND application_id IS NOT NULL)
+			OR id IN (SELECT application_id FROM oauth_refresh_tokens WHERE user_id = ?)
+		ORDER BY name
+	`
+
+	return r.findAll(query, userID, userID)
+}
+
+func (r *oauthApplicationsRepository) Update(application *models.OAuthApplication) error {
+	query := `
+		UPDATE oauth_applications
+		SET name = ?, homepage_url = ?, redirect_uris = ?, confidential = ?, updated_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query,
+		application.Name,
+		application.HomepageURL,
+		strings.Join(application.RedirectURIs, "\n"),
+		application.Confidential,
+		application.ID,
+	)
+	return err
+}
+
+func (r *oauthApplicationsRepository) UpdateClientSecret(id int64, clientSecretHash string) error {
+	query := `
+		UPDATE oauth_applications
+		SET client_secret_hash = ?, updated_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, clientSecretHash, id)
+	return err
+}
+
+func (r *oauthApplicationsRepository) Delete(id int64) error {
+	dependents := []string{
+		`DELETE FROM access_token_repositories WHERE access_token_id IN (SELECT id FROM access_tokens WHERE application_id = ?)`,
+		`DELETE FROM access_tokens WHERE application_id = ?`,
+		`DELETE FROM oauth_refresh_tokens WHERE application_id = ?`,
+		`DELETE FROM oauth_authorization_codes WHERE application_id = ?`,
+		`DELETE FROM oauth_device_codes WHERE application_id = ?`,
+	}
+	for _, query := range dependents {
+		if _, err := r.db.Exec(query, id); err != nil {
+			return err
+		}
+	}
+
+	result, err := r.db.Exec(`DELETE FROM oauth_applications WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *oauthApplicationsRepository) findOne(query string, args ...any) (*models.OAuthApplication, error) {
+	application, err := scanOAuthApplication(r.db.QueryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return application, nil
+}
+
+func (r *oauthApplicationsRepository) findAll(query string, args ...any) ([]*models.OAuthApplication, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applications []*models.OAuthApplication
+	for rows.Next() {
+		application, err := scanOAuthApplication(rows)
+		if err != nil {
+			return nil, err
+		}
+		applications = append(applications, application)
+	}
+
+	return applications, rows.Err()
+}
+
+func scanOAuthApplication(row rowScanner) (*models.OAuthApplication, error) {
+	application := &models.OAuthApplication{}
+	var redirectURIs string
+	err := row.Scan(
+		&application.ID,
+		&application.OwnerUserID,
+		&application.OwnerOrgID,
+		&application.Name,
+		&application.HomepageURL,
+		&redirectURIs,
+		&application.ClientID,
+		&application.ClientSecretHash,
+		&application.Confidential,
+		&application.CreatedAt,
+		&application.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	application.RedirectURIs = strings.Fields(redirectURIs)
+	return application, nil
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type OAuthAuthorizationCodesRepository interface {
+	Create(codeHash string, applicationID, userID int64, redirectURI string, scopes []string, codeChallenge string, expiresAt int64) (*models.OAuthAuthorizationCode, error)
+	// Consume deletes the code and returns it, so it can only be exchanged once. It returns nil if it is unknown.
+	Consume(codeHash string) (*models.OAuthAuthorizationCode, error)
+	DeleteExpired(now int64) error
+}
+
+type oauthAuthorizationCodesRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthAuthorizationCodesRepository(db *sql.DB) OAuthAuthorizationCodesRepository {
+	return &oauthAuthorizationCodesRepository{db: db}
+}
+
+func (r *oauthAuthorizationCodesRepository) Create(codeHash string, applicationID, userID int64, redirectURI string, scopes []string, codeChallenge string, expiresAt int64) (*models.OAuthAuthorizationCode, error) {
+	query := `
+		INSERT INTO oauth_authorization_codes (code_hash, application_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, code_hash, application_id, user_id, redirect_uri, scopes, code_challenge, expires_at, created_at
+	`
+
+	return scanOAuthAuthorizationCode(r.db.QueryRow(query, codeHash, applicationID, userID, redirectURI, strings.Join(scopes, " "), codeChallenge, expiresAt))
+}
+
+func (r *oauthAuthorizationCodesRepository) Consume(codeHash string) (*models.OAuthAuthorizationCode, error) {
+	query := `
+		DELETE FROM oauth_authorization_codes
+		WHERE code_hash = ?
+		RETURNING id, code_hash, application_id, user_id, redirect_uri, scopes, code_challenge, expires_at, created_at
+	`
+
+	code, err := scanOAuthAuthorizationCode(r.db.QueryRow(query, codeHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return code, nil
+}
+
+func (r *oauthAuthorizationCodesRepository) DeleteExpired(now int64) error {
+	_, err := r.db.Exec(`DELETE FROM oauth_authorization_codes WHERE expires_at < ?`, now)
+	return err
+}
+
+func scanOAuthAuthorizationCode(row rowScanner) (*models.OAuthAuthorizationCode, error) {
+	code := &models.OAuthAuthorizationCode{}
+	var scopes string
+	err := row.Scan(
+		&code.ID,
+		&code.CodeHash,
+		&code.ApplicationID,
+		&code.UserID,
+		&code.RedirectURI,
+		&scopes,
+		&code.CodeChallenge,
+		&code.ExpiresAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	code.Scopes = strings.Fields(scopes)
+	return code, nil
+}
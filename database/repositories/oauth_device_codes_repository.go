@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type OAuthDeviceCodesRepository interface {
+	Create(deviceCodeHash, userCode string, applicationID *int64, scopes []string, expiresAt int64) (*models.OAuthDeviceCode, error)
+	FindByDeviceCodeHash(deviceCodeHash string) (*models.OAuthDeviceCode, error)
+	FindByUserCode(userCode string) (*models.OAuthDeviceCode, error)
+	// Decide records that the user approved or denied a pending device code
+	Decide(id, userID int64, status string) error
+	UpdateLastPolled(id int64) error
+	Delete(id int64) error
+	DeleteExpired(now int64) error
+}
+
+type oauthDeviceCodesRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthDeviceCodesRepository(db *sql.DB) OAuthDeviceCodesRepository {
+	return &oauthDeviceCodesRepository{db: db}
+}
+
+func (r *oauthDeviceCodesRepository) Create(deviceCodeHash, userCode string, applicationID *int64, scopes []string, expiresAt int64) (*models.OAuthDeviceCode, error) {
+	query := `
+		INSERT INTO oauth_device_codes (device_code_hash, user_code, application_id, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, device_code_hash, user_code, application_id, scopes, user_id, status, last_polled_at, expires_at, created_at
+	`
+
+	return scanOAuthDeviceCode(r.db.QueryRow(query, deviceCodeHash, userCode, applicationID, strings.Join(scopes, " "), expiresAt))
+}
+
+func (r *oauthDeviceCodesRepository) FindByDeviceCodeHash(deviceCodeHash string) (*models.OAuthDeviceCode, error) {
+	query := `
+		SELECT id, device_code_hash, user_code, application_id, scopes, user_id, status, last_polled_at, expires_at, created_at
+		FROM oauth_device_codes
+		WHERE device_code_hash = ?
+	`
+
+	return r.findOne(query, deviceCodeHash)
+}
+
+func (r *oauthDeviceCodesRepository) FindByUserCode(userCode string) (*models.OAuthDeviceCode, error) {
+	query := `
+		SELECT id, device_code_hash, user_code, application_id, scopes, user_id, status, last_polled_at, expires_at, created_at
+		FROM oauth_device_codes
+		WHERE user_code = ?
+	`
+
+	return r.findOne(query, userCode)
+}
+
+func (r *oauthDeviceCodesRepository) Decide(id, userID int64, status string) error {
+	query := `
+		UPDATE oauth_device_codes
+		SET user_id = ?, status = ?
+		WHERE id = ? AND status = 'pending'
+	`
+
+	result, err := r.db.Exec(query, userID, status, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *oauthDeviceCodesRepository) UpdateLastPolled(id int64) error {
+	_, err := r.db.Exec(`UPDATE oauth_device_codes SET last_polled_at = unixepoch() WHERE id = ?`, id)
+	return err
+}
+
+func (r *oauthDeviceCodesRepository) Delete(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM oauth_device_codes WHERE id = ?`, id)
+	return err
+}
+
+func (r *oauthDeviceCodesRepository) DeleteExpired(now int64) error {
+	_, err := r.db.Exec(`DELETE FROM oauth_device_codes WHERE expires_at < ?`, now)
+	return err
+}
+
+func (r *oauthDeviceCodesRepository) findOne(query string, args ...any) (*models.OAuthDeviceCode, error) {
+	deviceCode, err := scanOAuthDeviceCode(r.db.QueryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return deviceCode, nil
+}
+
+func scanOAuthDeviceCode(row rowScanner) (*models.OAuthDeviceCode, error) {
+	deviceCode := &models.OAuthDeviceCode{}
+	var scopes string
+	err := row.Scan(
+		&deviceCode.ID,
+		&deviceCode.DeviceCodeHash,
+		&deviceCode.UserCode,
+		&deviceCode.ApplicationID,
+		&scopes,
+		&deviceCode.UserID,
+		&deviceCode.Status,
+		&deviceCode.LastPolledAt,
+		&deviceCode.ExpiresAt,
+		&deviceCode.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceCode.Scopes = strings.Fields(scopes)
+	return deviceCode, nil
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type OAuthRefreshTokensRepository interface {
+	Create(tokenHash string, applicationID, userID int64, scopes []string, expiresAt int64) (*models.OAuthRefreshToken, error)
+	FindByTokenHash(tokenHash string) (*models.OAuthRefreshToken, error)
+	// Consume deletes the token and returns it, so it can only be used once. It returns nil if it is unknown.
+	Consume(tokenHash string) (*models.OAuthRefreshToken, error)
+	Delete(id int64) error
+	DeleteByApplicationAndUser(applicationID, userID int64) error
+	DeleteExpired(now int64) error
+}
+
+type oauthRefreshTokensRepository struct {
+	db *sql.DB
+}
+
+func NewOAuthRefreshTokensRepository(db *sql.DB) OAuthRefreshTokensRepository {
+	return &oauthRefreshTokensRepository{db: db}
+}
+
+func (r *oauthRefreshTokensRepository) Create(tokenHash string, applicationID, userID int64, scopes []string, expiresAt int64) (*models.OAuthRefreshToken, error) {
+	query := `
+		INSERT INTO oauth_refresh_tokens (token_hash, application_id, user_id, scopes, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, token_hash, application_id, user_id, scopes, expires_at, created_at
+	`
+
+	return scanOAuthRefreshToken(r.db.QueryRow(query, tokenHash, applicationID, userID, strings.Join(scopes, " "), expiresAt))
+}
+
+func (r *oauthRefreshTokensRepository) FindByTokenHash(tokenHash string) (*models.OAuthRefreshToken, error) {
+	query := `
+		SELECT id, token_hash, application_id, user_id, scopes, expires_at, created_at
+		FROM oauth_refresh_tokens
+		WHERE token_hash = ?
+	`
+
+	return r.findOne(query, tokenHash)
+}
+
+func (r *oauthRefreshTokensRepository) Consume(tokenHash string) (*models.OAuthRefreshToken, error) {
+	query := `
+		DELETE FROM oauth_refresh_tokens
+		WHERE token_hash = ?
+		RETURNING id, token_hash, application_id, user_id, scopes, expires_at, created_at
+	`
+
+	return r.findOne(query, tokenHash)
+}
+
+func (r *oauthRefreshTokensRepository) Delete(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM oauth_refresh_tokens WHERE id = ?`, id)
+	return err
+}
+
+func (r *oauthRefreshTokensRepository) DeleteByApplicationAndUser(applicationID, userID int64) error {
+	_, err := r.db.Exec(`DELETE FROM oauth_refresh_tokens WHERE application_id = ? AND user_id = ?`, applicationID, userID)
+	return err
+}
+
+func (r *oauthRefreshTokensRepository) DeleteExpired(now int64) error {
+	_, err := r.db.Exec(`DELETE FROM oauth_refresh_tokens WHERE expires_at < ?`, now)
+	return err
+}
+
+func (r *oauthRefreshTokensRepository) findOne(query string, args ...any) (*models.OAuthRefreshToken, error) {
+	token, err := scanOAuthRefreshToken(r.db.QueryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func scanOAuthRefreshToken(row rowScanner) (*models.OAuthRefreshToken, error) {
+	token := &models.OAuthRefreshToken{}
+	var scopes string
+	err := row.Scan(
+		&token.ID,
+		&token.TokenHash,
+		&token.ApplicationID,
+		&token.UserID,
+		&scopes,
+		&token.ExpiresAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	return token, nil
+}
@@ -0,0 +1,148 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type OrganizationMembersRepository interface {
+	Create(organizationID, userID int64, role string) (*models.OrganizationMember, error)
+	FindByOrganizationAndUser(organizationID, userID int64) (*models.OrganizationMember, error)
+	FindAllByOrganization(organizationID int64) ([]*models.OrganizationMember, error)
+	FindPageByOrganization(organizationID int64, page Page) ([]*models.OrganizationMember, error)
+	FindAllByUser(userID int64) ([]*models.OrganizationMember, error)
+	CountOwners(organizationID int64) (int, error)
+	Delete(organizationID, userID int64) error
+}
+
+type organizationMembersRepository struct {
+	db *sql.DB
+}
+
+func NewOrganizationMembersRepository(db *sql.DB) OrganizationMembersRepository {
+	return &organizationMembersRepository{db: db}
+}
+
+func (r *organizationMembersRepository) Create(organizationID, userID int64, role string) (*models.OrganizationMember, error) {
+	query := `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES (?, ?, ?)
+		RETURNING id, organization_id, user_id, role, created_at
+	`
+
+	member := &models.OrganizationMember{}
+	err := r.db.QueryRow(query, organizationID, userID, role).Scan(
+		&member.ID,
+		&member.OrganizationID,
+		&member.UserID,
+		&member.Role,
+		&member.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (r *organizationMembersRepository) FindByOrganizationAndUser(organizationID, userID int64) (*models.OrganizationMember, error) {
+	query := `
+		SELECT id, organization_id, user_id, role, created_at
+		FROM organization_members
+		WHERE organization_id = ? AND user_id = ?
+	`
+
+	member := &models.OrganizationMember{}
+	err := r.db.QueryRow(query, organizationID, userID).Scan(
+		&member.ID,
+		&member.OrganizationID,
+		&member.UserID,
+		&member.Role,
+		&member.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (r *organizationMembersRepository) FindAllByOrganization(organizationID int64) ([]*models.OrganizationMember, error) {
+	query := `
+		SELECT id, organization_id, user_id, role, created_at
+		FROM organization_members
+		WHERE organization_id = ?
+		ORDER BY role = 'owner' DESC, created_at ASC
+	`
+
+	return r.findAll(query, organizationID)
+}
+
+func (r *organizationMembersRepository) FindPageByOrganization(organizationID int64, page Page) ([]*models.OrganizationMember, error) {
+	query := `
+		SELECT id, organization_id, user_id, role, created_at
+		FROM organization_members
+		WHERE organization_id = ? AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return r.findAll(query, organizationID, page.After, page.Limit)
+}
+
+func (r *organizationMembersRepository) FindAllByUser(userID int64) ([]*models.OrganizationMember, error) {
+	query := `
+		SELECT id, organization_id, user_id, role, created_at
+		FROM organization_members
+		WHERE user_id = ?
+		ORDER BY created_at ASC
+	`
+
+	return r.findAll(query, userID)
+}
+
+func (r *organizationMembersRepository) CountOwners(organizationID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM organization_members WHERE organization_id = ? AND role = 'owner'`
+
+	var count int
+	err := r.db.QueryRow(query, organizationID).Scan(&count)
+	return count, err
+}
+
+func (r *organizationMembersRepository) Delete(organizationID, userID int64) error {
+	query := `DELETE FROM organization_members WHERE organization_id = ? AND user_id = ?`
+
+	_, err := r.db.Exec(query, organizationID, userID)
+	return err
+}
+
+func (r *organizationMembersRepository) findAll(query string, args ...any) ([]*models.OrganizationMember, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*models.OrganizationMember
+	for rows.Next() {
+		member := &models.OrganizationMember{}
+		err := rows.Scan(
+			&member.ID,
+			&member.OrganizationID,
+			&member.UserID,
+			&member.Role,
+			&member.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
@@ -12,6 +12,7 @@ type OrganizationsRepository interface {
 	FindByID(id int64) (*models.Organization, error)
 	FindByUsername(username string) (*models.Organization, error)
 	FindAll() ([]*models.Organization, error)
+	FindPage(page Page) ([]*models.Organization, error)
 	Update(org *models.Organization) error
 	Delete(id int64) error
 }
@@ -28,7 +29,7 @@ func (r *organizationsRepository) Create(username, displayName string) (*models.
 	query := `
 		INSERT INTO organizations (username, display_name)
 		VALUES (?, ?)
-		RETURNING id, username, display_name, created_at, updated_at
+		RETURNING id, username, display_name, require_two_factor, created_at, updated_at
 	`
 
 	org := &models.Organization{}
@@ -36,6 +37,7 @@ func (r *organizationsRepository) Create(username, displayName string) (*models.
 		&org.ID,
 		&org.Username,
 		&org.DisplayName,
+		&org.RequireTwoFactor,
 		&org.CreatedAt,
 		&org.UpdatedAt,
 	)
@@ -48,7 +50,7 @@ func (r *organizationsRepository) Create(username, displayName string) (*models.
 
 func (r *organizationsRepository) FindByID(id int64) (*models.Organization, error) {
 	query := `
-		SELECT id, username, display_name, created_at, updated_at
+		SELECT id, username, display_name, require_two_factor, created_at, updated_at
 		FROM organizations
 		WHERE id = ?
 	`
@@ -58,6 +60,7 @@ func (r *organizationsRepository) FindByID(id int64) (*models.Organization, erro
 		&org.ID,
 		&org.Username,
 		&org.DisplayName,
+		&org.RequireTwoFactor,
 		&org.CreatedAt,
 		&org.UpdatedAt,
 	)
@@ -73,7 +76,7 @@ func (r *organizationsRepository) FindByID(id int64) (*models.Organization, erro
 
 func (r *organizationsRepository) FindByUsername(username string) (*models.Organization, error) {
 	query := `
-		SELECT id, username, display_name, created_at, updated_at
+		SELECT id, username, display_name, require_two_factor, created_at, updated_at
 		FROM organizations
 		WHERE username = ?
 	`
@@ -83,6 +86,7 @@ func (r *organizationsRepository) FindByUsername(username string) (*models.Organ
 		&org.ID,
 		&org.Username,
 		&org.DisplayName,
+		&org.RequireTwoFactor,
 		&org.CreatedAt,
 		&org.UpdatedAt,
 	)
@@ -98,12 +102,28 @@ func (r *organizationsRepository) FindByUsername(username string) (*models.Organ
 
 func (r *organizationsRepository) FindAll() ([]*models.Organization, error) {
 	query := `
-		SELECT id, username, display_name, created_at, updated_at
+		SELECT id, username, display_name, require_two_factor, created_at, updated_at
 		FROM organizations
 		ORDER BY username ASC
 	`
 
-	rows, err := r.db.Query(query)
+	return r.findAll(query)
+}
+
+func (r *organizationsRepository) FindPage(page Page) ([]*models.Organization, error) {
+	query := `
+		SELECT id, username, display_name, require_two_factor, created_at, updated_at
+		FROM organizations
+		WHERE id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return r.findAll(query, page.After, page.Limit)
+}
+
+func (r *organizationsRepository) findAll(query string, args ...any) ([]*models.Organization, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -116,6 +136,7 @@ func (r *organizationsRepository) FindAll() ([]*models.Organization, error) {
 			&org.ID,
 			&org.Username,
 			&org.DisplayName,
+			&org.RequireTwoFactor,
 			&org.CreatedAt,
 			&org.UpdatedAt,
 		)
@@ -131,11 +152,11 @@ func (r *organizationsRepository) FindAll() ([]*models.Organization, error) {
 func (r *organizationsRepository) Update(org *models.Organization) error {
 	query := `
 		UPDATE organizations
-		SET username = ?, display_name = ?
+		SET username = ?, display_name = ?, require_two_factor = ?
 		WHERE id = ?
 	`
 
-	result, err := r.db.Exec(query, org.Username, org.DisplayName, org.ID)
+	result, err := r.db.Exec(query, org.Username, org.DisplayName, org.RequireTwoFactor, org.ID)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,132 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type PackageDistTagsRepository interface {
+	// Set points the dist-tag of the package to the version, creating the tag if needed
+	Set(packageID int64, name string, versionID int64) error
+	// FindByPackageAndName returns a dist-tag of the package, nil if there is none
+	FindByPackageAndName(packageID int64, name string) (*models.PackageDistTag, error)
+	// FindAllByPackage returns the dist-tags of the package ordered by name
+	FindAllByPackage(packageID int64) ([]*models.PackageDistTag, error)
+	// Delete deletes the dist-tag of the package, it returns sql.ErrNoRows if there is no such tag
+	Delete(packageID int64, name string) error
+	// DeleteAllByVersion deletes the dist-tags pointing to a version being deleted
+	DeleteAllByVersion(versionID int64) error
+	// DeleteAllByPackage deletes the dist-tags of a package being deleted
+	DeleteAllByPackage(packageID int64) error
+}
+
+type packageDistTagsRepository struct {
+	db *sql.DB
+}
+
+func NewPackageDistTagsRepository(db *sql.DB) PackageDistTagsRepository {
+	return &packageDistTagsRepository{db: db}
+}
+
+func (r *packageDistTagsRepository) Set(packageID int64, name string, versionID int64) error {
+	query := `
+		INSERT INTO package_dist_tags (package_id, name, version_id)
+		VALUES (?, ?, ?)
+		ON CONFLICT (package_id, name) DO UPDATE SET version_id = excluded.version_id, updated_at = unixepoch()
+	`
+
+	_, err := r.db.Exec(query, packageID, name, versionID)
+	return err
+}
+
+func (r *packageDistTagsRepository) FindByPackageAndName(packageID int64, name string) (*models.PackageDistTag, error) {
+	query := `
+		SELECT t.id, t.package_id, t.name, t.version_id, v.version, t.updated_at
+		FROM package_dist_tags t
+		INNER JOIN package_versions v ON v.id = t.version_id
+		WHERE t.package_id = ? AND t.name = ?
+	`
+
+	tag, err := scanPackageDistTag(r.db.QueryRow(query, packageID, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return tag, nil
+}
+
+func (r *packageDistTagsRepository) FindAllByPackage(packageID int64) ([]*models.PackageDistTag, error) {
+	query := `
+		SELECT t.id, t.package_id, t.name, t.version_id, v.version, t.updated_at
+		FROM package_dist_tags t
+		INNER JOIN package_versions v ON v.id = t.version_id
+		WHERE t.package_id = ?
+		ORDER BY t.name
+	`
+
+	rows, err := r.db.Query(query, packageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []*models.PackageDistTag
+	for rows.Next() {
+		tag, err := scanPackageDistTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
+
+func (r *packageDistTagsRepository) Delete(packageID int64, name string) error {
+	result, err := r.db.Exec(`DELETE FROM package_dist_tags WHERE package_id = ? AND name = ?`, packageID, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *packageDistTagsRepository) DeleteAllByVersion(versionID int64) error {
+	_, err := r.db.Exec(`DELETE FROM package_dist_tags WHERE version_id = ?`, versionID)
+	return err
+}
+
+func (r *packageDistTagsRepository) DeleteAllByPackage(packageID int64) error {
+	_, err := r.db.Exec(`DELETE FROM package_dist_tags WHERE package_id = ?`, packageID)
+	return err
+}
+
+func scanPackageDistTag(row rowScanner) (*models.PackageDistTag, error) {
+	tag := &models.PackageDistTag{}
+	err := row.Scan(
+		&tag.ID,
+		&tag.PackageID,
+		&tag.Name,
+		&tag.VersionID,
+		&tag.Version,
+		&tag.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
@@ -0,0 +1,150 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type PackageFilesRepository interface {
+	Create(file *models.PackageFile) (*models.PackageFile, error)
+	// FindByVersionAndName returns a file of the version, nil if there is none
+	FindByVersionAndName(versionID int64, name string) (*models.PackageFile, error)
+	// FindAllByPackage returns the files of every version of the package ordered by name
+	FindAllByPackage(packageID int64) ([]*models.PackageFile, error)
+	// CountByVersion returns how many files the version has
+	CountByVersion(versionID int64) (int64, error)
+	Delete(id int64) error
+	// DeleteAllByVersion deletes the files of a version being deleted
+	DeleteAllByVersion(versionID int64) error
+	// DeleteAllByPackage deletes the files of a package being deleted
+	DeleteAllByPackage(packageID int64) error
+}
+
+type packageFilesRepository struct {
+	db *sql.DB
+}
+
+func NewPackageFilesRepository(db *sql.DB) PackageFilesRepository {
+	return &packageFilesRepository{db: db}
+}
+
+func (r *packageFilesRepository) Create(file *models.PackageFile) (*models.PackageFile, error) {
+	query := `
+		INSERT INTO package_files (version_id, name, size, sha1, sha256, sha512)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id, version_id, name, size, sha1, sha256, sha512, created_at
+	`
+
+	return scanPackageFile(r.db.QueryRow(query,
+		file.VersionID,
+		file.Name,
+		file.Size,
+		file.SHA1,
+		file.SHA256,
+		file.SHA512,
+	))
+}
+
+func (r *packageFilesRepository) FindByVersionAndName(versionID int64, name string) (*models.PackageFile, error) {
+	query := `
+		SELECT id, version_id, name, size, sha1, sha256, sha512, created_at
+		FROM package_files
+		WHERE version_id = ? AND name = ?
+	`
+
+	file, err := scanPackageFile(r.db.QueryRow(query, versionID, name))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (r *packageFilesRepository) FindAllByPackage(packageID int64) ([]*models.PackageFile, error) {
+	query := `
+		SELECT f.id, f.version_id, f.name, f.size, f.sha1, f.sha256, f.sha512, f.created_at
+		FROM package_files f
+		INNER JOIN package_versions v ON v.id = f.version_id
+		WHERE v.package_id = ?
+		ORDER BY f.name
+	`
+
+	rows, err := r.db.Query(query, packageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []*models.PackageFile
+	for rows.Next() {
+		file, err := scanPackageFile(rows)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+
+	return files, rows.Err()
+}
+
+func (r *packageFilesRepository) CountByVersion(versionID int64) (int64, error) {
+	var count int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM package_files WHERE version_id = ?`, versionID).Scan(&count)
+	return count, err
+}
+
+func (r *packageFilesRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM package_files WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *packageFilesRepository) DeleteAllByVersion(versionID int64) error {
+	_, err := r.db.Exec(`DELETE FROM package_files WHERE version_id = ?`, versionID)
+	return err
+}
+
+func (r *packageFilesRepository) DeleteAllByPackage(packageID int64) error {
+	query := `
+		DELETE FROM package_files
+		WHERE version_id IN (SELECT id FROM package_versions WHERE package_id = ?)
+	`
+
+	_, err := r.db.Exec(query, packageID)
+	return err
+}
+
+func scanPackageFile(row rowScanner) (*models.PackageFile, error) {
+	file := &models.PackageFile{}
+	err := row.Scan(
+		&file.ID,
+		&file.VersionID,
+		&file.Name,
+		&file.Size,
+		&file.SHA1,
+		&file.SHA256,
+		&file.SHA512,
+		&file.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return file, nil
+}
@@ -0,0 +1,133 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type PackageVersionsRepository interface {
+	Create(version *models.PackageVersion) (*models.PackageVersion, error)
+	// FindByPackageAndVersion returns a version of the package, nil if there is none
+	FindByPackageAndVersion(packageID int64, version string) (*models.PackageVersion, error)
+	// FindAllByPackage returns the versions of the package, most recent first
+	FindAllByPackage(packageID int64) ([]*models.PackageVersion, error)
+	// CountByPackage returns how many versions the package has
+	CountByPackage(packageID int64) (int64, error)
+	Delete(id int64) error
+	// DeleteAllByPackage deletes the versions of a package being deleted
+	DeleteAllByPackage(packageID int64) error
+}
+
+type packageVersionsRepository struct {
+	db *sql.DB
+}
+
+func NewPackageVersionsRepository(db *sql.DB) PackageVersionsRepository {
+	return &packageVersionsRepository{db: db}
+}
+
+func (r *packageVersionsRepository) Create(version *models.PackageVersion) (*models.PackageVersion, error) {
+	query := `
+		INSERT INTO package_versions (package_id, version, metadata, publisher_id)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, package_id, version, metadata, publisher_id, created_at
+	`
+
+	return scanPackageVersion(r.db.QueryRow(query,
+		version.PackageID,
+		version.Version,
+		version.Metadata,
+		version.PublisherID,
+	))
+}
+
+func (r *packageVersionsRepository) FindByPackageAndVersion(packageID int64, version string) (*models.PackageVersion, error) {
+	query := `
+		SELECT id, package_id, version, metadata, publisher_id, created_at
+		FROM package_versions
+		WHERE package_id = ? AND version = ?
+	`
+
+	packageVersion, err := scanPackageVersion(r.db.QueryRow(query, packageID, version))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return packageVersion, nil
+}
+
+func (r *packageVersionsRepository) FindAllByPackage(packageID int64) ([]*models.PackageVersion, error) {
+	query := `
+		SELECT id, package_id, version, metadata, publisher_id, created_at
+		FROM package_versions
+		WHERE package_id = ?
+		ORDER BY created_at DESC, id DESC
+	`
+
+	rows, err := r.db.Query(query, packageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []*models.PackageVersion
+	for rows.Next() {
+		version, err := scanPackageVersion(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+
+	return versions, rows.Err()
+}
+
+func (r *packageVersionsRepository) CountByPackage(packageID int64) (int64, error) {
+	var count int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM package_versions WHERE package_id = ?`, packageID).Scan(&count)
+	return count, err
+}
+
+func (r *packageVersionsRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM package_versions WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *packageVersionsRepository) DeleteAllByPackage(packageID int64) error {
+	_, err := r.db.Exec(`DELETE FROM package_versions WHERE package_id = ?`, packageID)
+	return err
+}
+
+func scanPackageVersion(row rowScanner) (*models.PackageVersion, error) {
+	version := &models.PackageVersion{}
+	err := row.Scan(
+		&version.ID,
+		&version.PackageID,
+		&version.Version,
+		&version.Metadata,
+		&version.PublisherID,
+		&version.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return version, nil
+}
@@ -0,0 +1,186 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type PackagesRepository interface {
+	Create(pkg *models.Package) (*models.Package, error)
+	FindByID(id int64) (*models.Package, error)
+	// FindByUserAndName returns the package of a user by type and name, nil if there is none
+	FindByUserAndName(userID int64, packageType, name string) (*models.Package, error)
+	// FindByOrgAndName returns the package of an organization by type and name, nil if there is none
+	FindByOrgAndName(orgID int64, packageType, name string) (*models.Package, error)
+	// FindAllByUser returns the packages of a user, most recently updated first
+	FindAllByUser(userID int64) ([]*models.Package, error)
+	// FindAllByOrg returns the packages of an organization, most recently updated first
+	FindAllByOrg(orgID int64) ([]*models.Package, error)
+	// SetRepository links the package to a repository, or unlinks it if repositoryID is nil
+	SetRepository(id int64, repositoryID *int64) error
+	// Touch marks the package as updated, after a version was published
+	Touch(id int64) error
+	// UnlinkRepository unlinks the packages of a repository being deleted
+	UnlinkRepository(repositoryID int64) error
+	Delete(id int64) error
+}
+
+type packagesRepository struct {
+	db *sql.DB
+}
+
+func NewPackagesRepository(db *sql.DB) PackagesRepository {
+	return &packagesRepository{db: db}
+}
+
+func (r *packagesRepository) Create(pkg *models.Package) (*models.Package, error) {
+	query := `
+		INSERT INTO packages (type, name, owner_user_id, owner_org_id, repository_id)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, type, name, owner_user_id, owner_org_id, repository_id, created_at, updated_at
+	`
+
+	return scanPackage(r.db.QueryRow(query,
+		pkg.Type,
+		pkg.Name,
+		pkg.OwnerUserID,
+		pkg.OwnerOrgID,
+		pkg.RepositoryID,
+	))
+}
+
+func (r *packagesRepository) FindByID(id int64) (*models.Package, error) {
+	query := `
+		SELECT id, type, name, owner_user_id, owner_org_id, repository_id, created_at, updated_at
+		FROM packages
+		WHERE id = ?
+	`
+
+	return r.find(query, id)
+}
+
+func (r *packagesRepository) FindByUserAndName(userID int64, packageType, name string) (*models.Package, error) {
+	query := `
+		SELECT id, type, name, owner_user_id, owner_org_id, repository_id, created_at, updated_at
+		FROM packages
+		WHERE owner_user_id = ? AND type = ? AND name = ?
+	`
+
+	return r.find(query, userID, packageType, name)
+}
+
+func (r *packagesRepository) FindByOrgAndName(orgID int64, packageType, name string) (*models.Package, error) {
+	query := `
+		SELECT id, type, name, owner_user_id, owner_org_id, repository_id, created_at, updated_at
+		FROM packages
+		WHERE owner_org_id = ? AND type = ? AND name = ?
+	`
+
+	return r.find(query, orgID, packageType, name)
+}
+
+func (r *packagesRepository) FindAllByUser(userID int64) ([]*models.Package, error) {
+	query := `
+		SELECT id, type, name, owner_user_id, owner_org_id, repository_id, created_at, updated_at
+		FROM packages
+		WHERE owner_user_id = ?
+		ORDER BY updated_at DESC, id DESC
+	`
+
+	return r.findAll(query, userID)
+}
+
+func (r *packagesRepository) FindAllByOrg(orgID int64) ([]*models.Package, error) {
+	query := `
+		SELECT id, type, name, owner_user_id, owner_org_id, repository_id, created_at, updated_at
+		FROM packages
+		WHERE owner_org_id = ?
+		ORDER BY updated_at DESC, id DESC
+	`
+
+	return r.findAll(query, orgID)
+}
+
+func (r *packagesRepository) SetRepository(id int64, repositoryID *int64) error {
+	_, err := r.db.Exec(`UPDATE packages SET repository_id = ? WHERE id = ?`, repositoryID, id)
+	return err
+}
+
+func (r *packagesRepository) Touch(id int64) error {
+	_, err := r.db.Exec(`UPDATE packages SET updated_at = unixepoch() WHERE id = ?`, id)
+	return err
+}
+
+func (r *packagesRepository) UnlinkRepository(repositoryID int64) error {
+	_, err := r.db.Exec(`UPDATE packages SET repository_id = NULL WHERE repository_id = ?`, repositoryID)
+	return err
+}
+
+func (r *packagesRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM packages WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *packagesRepository) find(query string, args ...any) (*models.Package, error) {
+	pkg, err := scanPackage(r.db.QueryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return pkg, nil
+}
+
+func (r *packagesRepository) findAll(query string, args ...any) ([]*models.Package, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var packages []*models.Package
+	for rows.Next() {
+		pkg, err := scanPackage(rows)
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+
+	return packages, rows.Err()
+}
+
+func scanPackage(row rowScanner) (*models.Package, error) {
+	pkg := &models.Package{}
+	err := row.Scan(
+		&pkg.ID,
+		&pkg.Type,
+		&pkg.Name,
+		&pkg.OwnerUserID,
+		&pkg.OwnerOrgID,
+		&pkg.RepositoryID,
+		&pkg.CreatedAt,
+		&pkg.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
@@ -0,0 +1,8 @@
+package repositories
+
+// Page selects a page of a list ordered by ID: at most Limit rows with an ID greater than After.
+// API handlers ask for one row more than they show to know whether there is a next page.
+type Page struct {
+	After int64
+	Limit int
+}
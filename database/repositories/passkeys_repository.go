@@ -0,0 +1,198 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type PasskeysRepository interface {
+	Create(userID int64, name, credentialID string, publicKey []byte, signCount uint32) (*models.Passkey, error)
+	FindByCredentialID(credentialID string) (*models.Passkey, error)
+	FindAllByUser(userID int64) ([]*models.Passkey, error)
+	CountByUser(userID int64) (int, error)
+	UpdateSignCount(id int64, signCount uint32) error
+	DeleteByIDAndUser(id, userID int64) (bool, error)
+
+	// Challenges of ongoing ceremonies
+	CreateChallenge(userID *int64, ceremony, challengeHash string, expiresAt int64) error
+	TakeChallenge(ceremony, challengeHash string, now int64) (*models.PasskeyChallenge, error)
+	DeleteExpiredChallenges(now int64) error
+}
+
+type passkeysRepository struct {
+	db *sql.DB
+}
+
+func NewPasskeysRepository(db *sql.DB) PasskeysRepository {
+	return &passkeysRepository{db: db}
+}
+
+func (r *passkeysRepository) Create(userID int64, name, credentialID string, publicKey []byte, signCount uint32) (*models.Passkey, error) {
+	query := `
+		INSERT INTO passkeys (user_id, name, credential_id, public_key, sign_count)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, user_id, name, credential_id, public_key, sign_count, last_used_at, created_at
+	`
+
+	passkey := &models.Passkey{}
+	err := r.db.QueryRow(query, userID, name, credentialID, publicKey, signCount).Scan(
+		&passkey.ID,
+		&passkey.UserID,
+		&passkey.Name,
+		&passkey.CredentialID,
+		&passkey.PublicKey,
+		&passkey.SignCount,
+		&passkey.LastUsedAt,
+		&passkey.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return passkey, nil
+}
+
+func (r *passkeysRepository) FindByCredentialID(credentialID string) (*models.Passkey, error) {
+	query := `
+		SELECT id, user_id, name, credential_id, public_key, sign_count, last_used_at, created_at
+		FROM passkeys
+		WHERE credential_id = ?
+	`
+
+	passkey := &models.Passkey{}
+	err := r.db.QueryRow(query, credentialID).Scan(
+		&passkey.ID,
+		&passkey.UserID,
+		&passkey.Name,
+		&passkey.CredentialID,
+		&passkey.PublicKey,
+		&passkey.SignCount,
+		&passkey.LastUsedAt,
+		&passkey.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return passkey, nil
+}
+
+func (r *passkeysRepository) FindAllByUser(userID int64) ([]*models.Passkey, error) {
+	query := `
+		SELECT id, user_id, name, credential_id, public_key, sign_count, last_used_at, created_at
+		FROM passkeys
+		WHERE user_id = ?
+		ORDER BY created_at ASC, id ASC
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var passkeys []*models.Passkey
+	for rows.Next() {
+		passkey := &models.Passkey{}
+		err := rows.Scan(
+			&passkey.ID,
+			&passkey.UserID,
+			&passkey.Name,
+			&passkey.CredentialID,
+			&passkey.PublicKey,
+			&passkey.SignCount,
+			&passkey.LastUsedAt,
+			&passkey.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		passkeys = append(passkeys, passkey)
+	}
+
+	return passkeys, rows.Err()
+}
+
+func (r *passkeysRepository) CountByUser(userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM passkeys WHERE user_id = ?`, userID).Scan(&count)
+	return count, err
+}
+
+// UpdateSignCount stores the signature counter of the last assertion and marks the passkey as used
+func (r *passkeysRepository) UpdateSignCount(id int64, signCount uint32) error {
+	query := `
+		UPDATE passkeys
+		SET sign_count = ?, last_used_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, signCount, id)
+	return err
+}
+
+// DeleteByIDAndUser deletes a passkey of the user. It reports false if the user has no such passkey.
+func (r *passkeysRepository) DeleteByIDAndUser(id, userID int64) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM passkeys WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (r *passkeysRepository) CreateChallenge(userID *int64, ceremony, challengeHash string, expiresAt int64) error {
+	query := `
+		INSERT INTO passkey_challenges (user_id, ceremony, challenge_hash, expires_at)
+		VALUES (?, ?, ?, ?)
+	`
+
+	_, err := r.db.Exec(query, userID, ceremony, challengeHash, expiresAt)
+	return err
+}
+
+// TakeChallenge deletes and returns an unexpired challenge, so it can't be answered twice
+func (r *passkeysRepository) TakeChallenge(ceremony, challengeHash string, now int64) (*models.PasskeyChallenge, error) {
+	query := `
+		DELETE FROM passkey_challenges
+		WHERE ceremony = ? AND challenge_hash = ?
+		RETURNING id, user_id, ceremony, challenge_hash, expires_at, created_at
+	`
+
+	challenge := &models.PasskeyChallenge{}
+	err := r.db.QueryRow(query, ceremony, challengeHash).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.Ceremony,
+		&challenge.ChallengeHash,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if challenge.ExpiresAt <= now {
+		return nil, nil
+	}
+
+	return challenge, nil
+}
+
+func (r *passkeysRepository) DeleteExpiredChallenges(now int64) error {
+	_, err := r.db.Exec(`DELETE FROM passkey_challenges WHERE expires_at <= ?`, now)
+	return err
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type PasswordResetsRepository interface {
+	// Tokens
+	CreateToken(userID int64, tokenHash string, expiresAt int64, requestedIP string) (*models.PasswordResetToken, error)
+	FindValidToken(tokenHash string, now int64) (*models.PasswordResetToken, error)
+	ConsumeToken(id int64) (bool, error)
+	InvalidateTokensForUser(userID int64) error
+
+	// Requests, used for rate limiting
+	RecordRequest(email, ipAddress string) error
+	CountRequestsByEmailSince(email string, since int64) (int64, error)
+	CountRequestsByIPSince(ipAddress string, since int64) (int64, error)
+}
+
+type passwordResetsRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetsRepository(db *sql.DB) PasswordResetsRepository {
+	return &passwordResetsRepository{db: db}
+}
+
+func (r *passwordResetsRepository) CreateToken(userID int64, tokenHash string, expiresAt int64, requestedIP string) (*models.PasswordResetToken, error) {
+	query := `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at, requested_ip)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, user_id, token_hash, expires_at, used_at, requested_ip, created_at
+	`
+
+	token := &models.PasswordResetToken{}
+	err := r.db.QueryRow(query, userID, tokenHash, expiresAt, requestedIP).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RequestedIP,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// FindValidToken returns the token with the given hash if it is unused and not expired
+func (r *passwordResetsRepository) FindValidToken(tokenHash string, now int64) (*models.PasswordResetToken, error) {
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, requested_ip, created_at
+		FROM password_reset_tokens
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+	`
+
+	token := &models.PasswordResetToken{}
+	err := r.db.QueryRow(query, tokenHash, now).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.RequestedIP,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ConsumeToken marks a token as used. It reports false if the token was already used,
+// so two concurrent requests can't both redeem the same token.
+func (r *passwordResetsRepository) ConsumeToken(id int64) (bool, error) {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = unixepoch()
+		WHERE id = ? AND used_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// InvalidateTokensForUser marks all outstanding tokens of a user as used
+func (r *passwordResetsRepository) InvalidateTokensForUser(userID int64) error {
+	query := `
+		UPDATE password_reset_tokens
+		SET used_at = unixepoch()
+		WHERE user_id = ? AND used_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, userID)
+	return err
+}
+
+func (r *passwordResetsRepository) RecordRequest(email, ipAddress string) error {
+	query := `
+		INSERT INTO password_reset_requests (email, ip_address)
+		VALUES (?, ?)
+	`
+
+	_, err := r.db.Exec(query, email, ipAddress)
+	return err
+}
+
+func (r *passwordResetsRepository) CountRequestsByEmailSince(email string, since int64) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM password_reset_requests
+		WHERE email = ? AND created_at >= ?
+	`
+
+	var count int64
+	err := r.db.QueryRow(query, email, since).Scan(&count)
+	return count, err
+}
+
+func (r *passwordResetsRepository) CountRequestsByIPSince(ipAddress string, since int64) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM password_reset_requests
+		WHERE ip_address = ? AND created_at >= ?
+	`
+
+	var count int64
+	err := r.db.QueryRow(query, ipAddress, since).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,117 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type RegistryBlobsRepository interface {
+	// Link records that the repository has the blob, or returns the link recorded before
+	Link(repositoryID int64, digest string, size int64) (*models.RegistryBlob, error)
+	FindByDigest(repositoryID int64, digest string) (*models.RegistryBlob, error)
+	// FindAllDigestsByRepository returns the digests of the blobs of the repository
+	FindAllDigestsByRepository(repositoryID int64) ([]string, error)
+	// CountByDigest counts the repositories that have the blob
+	CountByDigest(digest string) (int64, error)
+	// Delete unlinks the blob from the repository, it returns sql.ErrNoRows if it wasn't linked
+	Delete(repositoryID int64, digest string) error
+	// DeleteAllByRepository unlinks the blobs of a repository being deleted
+	DeleteAllByRepository(repositoryID int64) error
+}
+
+type registryBlobsRepository struct {
+	db *sql.DB
+}
+
+func NewRegistryBlobsRepository(db *sql.DB) RegistryBlobsRepository {
+	return &registryBlobsRepository{db: db}
+}
+
+func (r *registryBlobsRepository) Link(repositoryID int64, digest string, size int64) (*models.RegistryBlob, error) {
+	query := `
+		INSERT INTO registry_blobs (repository_id, digest, size)
+		VALUES (?, ?, ?)
+		ON CONFLICT (repository_id, digest) DO NOTHING
+	`
+
+	if _, err := r.db.Exec(query, repositoryID, digest, size); err != nil {
+		return nil, err
+	}
+
+	return r.FindByDigest(repositoryID, digest)
+}
+
+func (r *registryBlobsRepository) FindByDigest(repositoryID int64, digest string) (*models.RegistryBlob, error) {
+	query := `
+		SELECT id, repository_id, digest, size, created_at
+		FROM registry_blobs
+		WHERE repository_id = ? AND digest = ?
+	`
+
+	blob := &models.RegistryBlob{}
+	err := r.db.QueryRow(query, repositoryID, digest).Scan(
+		&blob.ID,
+		&blob.RepositoryID,
+		&blob.Digest,
+		&blob.Size,
+		&blob.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return blob, nil
+}
+
+func (r *registryBlobsRepository) FindAllDigestsByRepository(repositoryID int64) ([]string, error) {
+	rows, err := r.db.Query(`SELECT digest FROM registry_blobs WHERE repository_id = ?`, repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var digests []string
+	for rows.Next() {
+		var digest string
+		if err := rows.Scan(&digest); err != nil {
+			return nil, err
+		}
+		digests = append(digests, digest)
+	}
+
+	return digests, rows.Err()
+}
+
+func (r *registryBlobsRepository) CountByDigest(digest string) (int64, error) {
+	var count int64
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM registry_blobs WHERE digest = ?`, digest).Scan(&count)
+	return count, err
+}
+
+func (r *registryBlobsRepository) Delete(repositoryID int64, digest string) error {
+	result, err := r.db.Exec(`DELETE FROM registry_blobs WHERE repository_id = ? AND digest = ?`, repositoryID, digest)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *registryBlobsRepository) DeleteAllByRepository(repositoryID int64) error {
+	_, err := r.db.Exec(`DELETE FROM registry_blobs WHERE repository_id = ?`, repositoryID)
+	return err
+}
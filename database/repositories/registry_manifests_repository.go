@@ -0,0 +1,138 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type RegistryManifestsRepository interface {
+	// Create records the manifest, or returns the one with the same digest recorded before
+	Create(manifest *models.RegistryManifest) (*models.RegistryManifest, error)
+	FindByDigest(repositoryID int64, image, digest string) (*models.RegistryManifest, error)
+	// FindByTag returns the manifest the tag of the image points to
+	FindByTag(repositoryID int64, image, tag string) (*models.RegistryManifest, error)
+	// FindAllBySubject returns the manifests of the image that refer to the subject, oldest first
+	FindAllBySubject(repositoryID int64, image, subjectDigest string) ([]*models.RegistryManifest, error)
+	Delete(id int64) error
+	// DeleteAllByRepository deletes the manifests of a repository being deleted
+	DeleteAllByRepository(repositoryID int64) error
+}
+
+type registryManifestsRepository struct {
+	db *sql.DB
+}
+
+func NewRegistryManifestsRepository(db *sql.DB) RegistryManifestsRepository {
+	return &registryManifestsRepository{db: db}
+}
+
+func (r *registryManifestsRepository) Create(manifest *models.RegistryManifest) (*models.RegistryManifest, error) {
+	query := `
+		INSERT INTO registry_manifests (repository_id, image, digest, media_type, content, subject_digest)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (repository_id, image, digest) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query,
+		manifest.RepositoryID,
+		manifest.Image,
+		manifest.Digest,
+		manifest.MediaType,
+		manifest.Content,
+		manifest.SubjectDigest,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.FindByDigest(manifest.RepositoryID, manifest.Image, manifest.Digest)
+}
+
+func (r *registryManifestsRepository) FindByDigest(repositoryID int64, image, digest string) (*models.RegistryManifest, error) {
+	query := `
+		SELECT id, repository_id, image, digest, media_type, content, subject_digest, created_at
+		FROM registry_manifests
+		WHERE repository_id = ? AND image = ? AND digest = ?
+	`
+
+	return r.find(query, repositoryID, image, digest)
+}
+
+func (r *registryManifestsRepository) FindByTag(repositoryID int64, image, tag string) (*models.RegistryManifest, error) {
+	query := `
+		SELECT m.id, m.repository_id, m.image, m.digest, m.media_type, m.content, m.subject_digest, m.created_at
+		FROM registry_tags t
+		JOIN registry_manifests m ON m.id = t.manifest_id
+		WHERE t.repository_id = ? AND t.image = ? AND t.name = ?
+	`
+
+	return r.find(query, repositoryID, image, tag)
+}
+
+func (r *registryManifestsRepository) FindAllBySubject(repositoryID int64, image, subjectDigest string) ([]*models.RegistryManifest, error) {
+	query := `
+		SELECT id, repository_id, image, digest, media_type, content, subject_digest, created_at
+		FROM registry_manifests
+		WHERE repository_id = ? AND image = ? AND subject_digest = ?
+		ORDER BY id
+	`
+
+	rows, err := r.db.Query(query, repositoryID, image, subjectDigest)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var manifests []*models.RegistryManifest
+	for rows.Next() {
+		manifest, err := scanRegistryManifest(rows)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	return manifests, rows.Err()
+}
+
+func (r *registryManifestsRepository) Delete(id int64) error {
+	_, err := r.db.Exec(`DELETE FROM registry_manifests WHERE id = ?`, id)
+	return err
+}
+
+func (r *registryManifestsRepository) DeleteAllByRepository(repositoryID int64) error {
+	_, err := r.db.Exec(`DELETE FROM registry_manifests WHERE repository_id = ?`, repositoryID)
+	return err
+}
+
+func (r *registryManifestsRepository) find(query string, args ...any) (*models.RegistryManifest, error) {
+	manifest, err := scanRegistryManifest(r.db.QueryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+func scanRegistryManifest(row rowScanner) (*models.RegistryManifest, error) {
+	manifest := &models.RegistryManifest{}
+	err := row.Scan(
+		&manifest.ID,
+		&manifest.RepositoryID,
+		&manifest.Image,
+		&manifest.Digest,
+		&manifest.MediaType,
+		&manifest.Content,
+		&manifest.SubjectDigest,
+		&manifest.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
@@ -0,0 +1,93 @@
+package repositories
+
+import (
+	"database/sql"
+)
+
+type RegistryTagsRepository interface {
+	// Set points the tag of the image to the manifest, creating the tag if needed
+	Set(repositoryID int64, image, name string, manifestID int64) error
+	// FindNames returns up to limit tags of the image in lexical order, those after last if it isn't
+	// empty
+	FindNames(repositoryID int64, image, last string, limit int) ([]string, error)
+	// Delete deletes the tag of the image, it returns sql.ErrNoRows if there is no such tag
+	Delete(repositoryID int64, image, name string) error
+	// DeleteAllByManifest deletes the tags pointing to a manifest being deleted
+	DeleteAllByManifest(manifestID int64) error
+	// DeleteAllByRepository deletes the tags of a repository being deleted
+	DeleteAllByRepository(repositoryID int64) error
+}
+
+type registryTagsRepository struct {
+	db *sql.DB
+}
+
+func NewRegistryTagsRepository(db *sql.DB) RegistryTagsRepository {
+	return &registryTagsRepository{db: db}
+}
+
+func (r *registryTagsRepository) Set(repositoryID int64, image, name string, manifestID int64) error {
+	query := `
+		INSERT INTO registry_tags (repository_id, image, name, manifest_id)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT (repository_id, image, name) DO UPDATE SET manifest_id = excluded.manifest_id, updated_at = unixepoch()
+	`
+
+	_, err := r.db.Exec(query, repositoryID, image, name, manifestID)
+	return err
+}
+
+func (r *registryTagsRepository) FindNames(repositoryID int64, image, last string, limit int) ([]string, error) {
+	query := `
+		SELECT name
+		FROM registry_tags
+		WHERE repository_id = ? AND image = ? AND name > ?
+		ORDER BY name
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, repositoryID, image, last, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+func (r *registryTagsRepository) Delete(repositoryID int64, image, name string) error {
+	result, err := r.db.Exec(`DELETE FROM registry_tags WHERE repository_id = ? AND image = ? AND name = ?`, repositoryID, image, name)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *registryTagsRepository) DeleteAllByManifest(manifestID int64) error {
+	_, err := r.db.Exec(`DELETE FROM registry_tags WHERE manifest_id = ?`, manifestID)
+	return err
+}
+
+func (r *registryTagsRepository) DeleteAllByRepository(repositoryID int64) error {
+	_, err := r.db.Exec(`DELETE FROM registry_tags WHERE repository_id = ?`, repositoryID)
+	return err
+}
@@ -3,10 +3,18 @@ package repositories
 import (
 	"database/sql"
 	"errors"
+	"strings"
 
 	"github.com/hypercommithq/hypercommit/database/models"
 )
 
+// RepositoryReader is who lists repositories, pages of repositories only hold those they may read.
+// UserID is 0 for anonymous readers, RepositoryIDs limits an access token to some repositories.
+type RepositoryReader struct {
+	UserID        int64
+	RepositoryIDs []int64
+}
+
 type RepositoriesRepository interface {
 	CreateForUser(userID int64, name, visibility, defaultBranch string, description *string) (*models.Repository, error)
 	CreateForOrg(orgID int64, name, visibility, defaultBranch string, description *string) (*models.Repository, error)
@@ -14,8 +22,16 @@ type RepositoriesRepository interface {
 	FindByUserAndName(userID int64, name string) (*models.Repository, error)
 	FindByOrgAndName(orgID int64, name string) (*models.Repository, error)
 	FindByOwnerAndName(ownerUsername, repoName string) (*models.Repository, error)
+	// FindByOwnerAndNameFold is FindByOwnerAndName ignoring case, for protocols whose names are lower
+	// case such as container images
+	FindByOwnerAndNameFold(ownerUsername, repoName string) (*models.Repository, error)
 	FindAllByUser(userID int64) ([]*models.Repository, error)
 	FindAllByOrg(orgID int64) ([]*models.Repository, error)
+	// FindPageByUser, FindPageByOrg and FindStarredPageByUser return a page of the repositories of a
+	// user, of an organization and starred by a user, filtered down to those reader may read
+	FindPageByUser(userID int64, reader RepositoryReader, page Page) ([]*models.Repository, error)
+	FindPageByOrg(orgID int64, reader RepositoryReader, page Page) ([]*models.Repository, error)
+	FindStarredPageByUser(userID int64, reader RepositoryReader, page Page) ([]*models.Repository, error)
 	FindPublic() ([]*models.Repository, error)
 	FindAll() ([]*models.Repository, error)
 	Update(repo *models.Repository) error
@@ -200,6 +216,39 @@ func (r *repositoriesRepository) FindByOwnerAndName(ownerUsername, repoName stri
 	return repo, nil
 }
 
+func (r *repositoriesRepository) FindByOwnerAndNameFold(ownerUsername, repoName string) (*models.Repository, error) {
+	query := `
+		SELECT r.id, r.name, r.description, r.default_branch, r.visibility, r.owner_user_id, r.owner_org_id, r.created_at, r.updated_at
+		FROM repositories r
+		LEFT JOIN users u ON r.owner_user_id = u.id
+		LEFT JOIN organizations o ON r.owner_org_id = o.id
+		WHERE (lower(u.username) = lower(?) OR lower(o.username) = lower(?)) AND lower(r.name) = lower(?)
+		ORDER BY r.id
+		LIMIT 1
+	`
+
+	repo := &models.Repository{}
+	err := r.db.QueryRow(query, ownerUsername, ownerUsername, repoName).Scan(
+		&repo.ID,
+		&repo.Name,
+		&repo.Description,
+		&repo.DefaultBranch,
+		&repo.Visibility,
+		&repo.OwnerUserID,
+		&repo.OwnerOrgID,
+		&repo.CreatedAt,
+		&repo.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return repo, nil
+}
+
 func (r *repositoriesRepository) FindAllByUser(userID int64) ([]*models.Repository, error) {
 	query := `
 		SELECT id, name, description, default_branch, visibility, owner_user_id, owner_org_id, created_at, updated_at
@@ -371,7 +420,113 @@ func (r *repositoriesRepository) Update(repo *models.Repository) error {
 	return nil
 }
 
+func (r *repositoriesRepository) FindPageByUser(userID int64, reader RepositoryReader, page Page) ([]*models.Repository, error) {
+	return r.findReadablePage(`repositories r`, `r.owner_user_id = ?`, []any{userID}, reader, page)
+}
+
+func (r *repositoriesRepository) FindPageByOrg(orgID int64, reader RepositoryReader, page Page) ([]*models.Repository, error) {
+	return r.findReadablePage(`repositories r`, `r.owner_org_id = ?`, []any{orgID}, reader, page)
+}
+
+func (r *repositoriesRepository) FindStarredPageByUser(userID int64, reader RepositoryReader, page Page) ([]*models.Repository, error) {
+	return r.findReadablePage(`repositories r INNER JOIN stars s ON s.repository_id = r.id`, `s.user_id = ?`, []any{userID}, reader, page)
+}
+
+// readableRepository is the condition for the repository r to be readable by the user of its four
+// arguments. It mirrors the permissions of the API: public repositories, those the user owns, and
+// those of organizations they are a member of or they collaborate on. Organizations requiring
+// two-factor authentication only let in who has it.
+const readableRepository = `(
+	r.visibility = 'public'
+	OR r.owner_user_id = ?
+	OR (
+		(
+			EXISTS (SELECT 1 FROM organization_members m WHERE m.organization_id = r.owner_org_id AND m.user_id = ?)
+			OR EXISTS (SELECT 1 FROM contributors c WHERE c.repository_id = r.id AND c.user_id = ?)
+		)
+		AND NOT (
+			EXISTS (SELECT 1 FROM organizations o WHERE o.id = r.owner_org_id AND o.require_two_factor = 1)
+			AND NOT EXISTS (SELECT 1 FROM two_factor_credentials t WHERE t.user_id = ? AND t.confirmed_at IS NOT NULL)
+		)
+	)
+)`
+
+// findReadablePage returns a page of the repositories r of the from clause matching where, with its
+// args, that reader may read
+func (r *repositoriesRepository) findReadablePage(from, where string, args []any, reader RepositoryReader, page Page) ([]*models.Repository, error) {
+	query := `
+		SELECT r.id, r.name, r.description, r.default_branch, r.visibility, r.owner_user_id, r.owner_org_id, r.created_at, r.updated_at
+		FROM ` + from + `
+		WHERE ` + where + ` AND r.id > ? AND ` + readableRepository
+	args = append(args, page.After, reader.UserID, reader.UserID, reader.UserID, reader.UserID)
+
+	if len(reader.RepositoryIDs) > 0 {
+		query += ` AND r.id IN (?` + strings.Repeat(`, ?`, len(reader.RepositoryIDs)-1) + `)`
+		for _, id := range reader.RepositoryIDs {
+			args = append(args, id)
+		}
+	}
+
+	query += ` ORDER BY r.id ASC LIMIT ?`
+	args = append(args, page.Limit)
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var repos []*models.Repository
+	for rows.Next() {
+		repo := &models.Repository{}
+		err := rows.Scan(
+			&repo.ID,
+			&repo.Name,
+			&repo.Description,
+			&repo.DefaultBranch,
+			&repo.Visibility,
+			&repo.OwnerUserID,
+			&repo.OwnerOrgID,
+			&repo.CreatedAt,
+			&repo.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		repos = append(repos, repo)
+	}
+
+	return repos, rows.Err()
+}
+
+// Delete deletes the repository with its rows in other tables: contributors, stars, tickets with their
+// comments, labels and notifications, milestones, commit statuses and workflow runs. Webhooks, secrets,
+// Go module versions, container images and packages are left to their services.
 func (r *repositoriesRepository) Delete(id int64) error {
+	dependents := []string{
+		`DELETE FROM contributors WHERE repository_id = ?`,
+		`DELETE FROM stars WHERE repository_id = ?`,
+		`DELETE FROM access_token_repositories WHERE repository_id = ?`,
+		`DELETE FROM notifications WHERE ticket_id IN (SELECT id FROM tickets WHERE repository_id = ?)`,
+		`DELETE FROM ticket_subscriptions WHERE ticket_id IN (SELECT id FROM tickets WHERE repository_id = ?)`,
+		`DELETE FROM ticket_reactions WHERE comment_id IN (SELECT c.id FROM ticket_comments c JOIN tickets t ON t.id = c.ticket_id WHERE t.repository_id = ?)`,
+		`DELETE FROM ticket_reactions WHERE ticket_id IN (SELECT id FROM tickets WHERE repository_id = ?)`,
+		`DELETE FROM ticket_assignees WHERE ticket_id IN (SELECT id FROM tickets WHERE repository_id = ?)`,
+		`DELETE FROM ticket_label_assignments WHERE ticket_id IN (SELECT id FROM tickets WHERE repository_id = ?)`,
+		`DELETE FROM ticket_comments WHERE ticket_id IN (SELECT id FROM tickets WHERE repository_id = ?)`,
+		`DELETE FROM tickets WHERE repository_id = ?`,
+		`DELETE FROM ticket_labels WHERE repository_id = ?`,
+		`DELETE FROM milestones WHERE repository_id = ?`,
+		`DELETE FROM commit_statuses WHERE repository_id = ?`,
+		`DELETE FROM workflow_jobs WHERE repository_id = ?`,
+		`DELETE FROM workflow_runs WHERE repository_id = ?`,
+	}
+	for _, query := range dependents {
+		if _, err := r.db.Exec(query, id); err != nil {
+			return err
+		}
+	}
+
 	query := `DELETE FROM repositories WHERE id = ?`
 
 	result, err := r.db.Exec(query, id)
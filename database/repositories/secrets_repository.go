@@ -0,0 +1,151 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type SecretsRepository interface {
+	Create(secret *models.Secret) (*models.Secret, error)
+	FindByID(id int64) (*models.Secret, error)
+	// FindAllByRepository returns the secrets of the repository ordered by name
+	FindAllByRepository(repositoryID int64) ([]*models.Secret, error)
+	// FindAllByOrganization returns the secrets of the organization ordered by name
+	FindAllByOrganization(orgID int64) ([]*models.Secret, error)
+	// UpdateValue replaces the encrypted value of the secret
+	UpdateValue(secret *models.Secret) error
+	Delete(id int64) error
+	// DeleteAllByRepository deletes the secrets of a repository being deleted
+	DeleteAllByRepository(repositoryID int64) error
+}
+
+type secretsRepository struct {
+	db *sql.DB
+}
+
+func NewSecretsRepository(db *sql.DB) SecretsRepository {
+	return &secretsRepository{db: db}
+}
+
+func (r *secretsRepository) Create(secret *models.Secret) (*models.Secret, error) {
+	query := `
+		INSERT INTO secrets (repository_id, organization_id, name, value, creator_id)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, repository_id, organization_id, name, value, creator_id, created_at, updated_at
+	`
+
+	return scanSecret(r.db.QueryRow(query,
+		secret.RepositoryID,
+		secret.OrganizationID,
+		secret.Name,
+		secret.EncryptedValue,
+		secret.CreatorID,
+	))
+}
+
+func (r *secretsRepository) FindByID(id int64) (*models.Secret, error) {
+	query := `
+		SELECT id, repository_id, organization_id, name, value, creator_id, created_at, updated_at
+		FROM secrets
+		WHERE id = ?
+	`
+
+	secret, err := scanSecret(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+func (r *secretsRepository) FindAllByRepository(repositoryID int64) ([]*models.Secret, error) {
+	query := `
+		SELECT id, repository_id, organization_id, name, value, creator_id, created_at, updated_at
+		FROM secrets
+		WHERE repository_id = ?
+		ORDER BY name
+	`
+
+	return r.findAll(query, repositoryID)
+}
+
+func (r *secretsRepository) FindAllByOrganization(orgID int64) ([]*models.Secret, error) {
+	query := `
+		SELECT id, repository_id, organization_id, name, value, creator_id, created_at, updated_at
+		FROM secrets
+		WHERE organization_id = ?
+		ORDER BY name
+	`
+
+	return r.findAll(query, orgID)
+}
+
+func (r *secretsRepository) UpdateValue(secret *models.Secret) error {
+	_, err := r.db.Exec(`UPDATE secrets SET value = ?, updated_at = unixepoch() WHERE id = ?`, secret.EncryptedValue, secret.ID)
+	return err
+}
+
+func (r *secretsRepository) Delete(id int64) error {
+	result, err := r.db.Exec(`DELETE FROM secrets WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *secretsRepository) DeleteAllByRepository(repositoryID int64) error {
+	_, err := r.db.Exec(`DELETE FROM secrets WHERE repository_id = ?`, repositoryID)
+	return err
+}
+
+func (r *secretsRepository) findAll(query string, args ...any) ([]*models.Secret, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var secrets []*models.Secret
+	for rows.Next() {
+		secret, err := scanSecret(rows)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, rows.Err()
+}
+
+func scanSecret(row rowScanner) (*models.Secret, error) {
+	secret := &models.Secret{}
+	err := row.Scan(
+		&secret.ID,
+		&secret.RepositoryID,
+		&secret.OrganizationID,
+		&secret.Name,
+		&secret.EncryptedValue,
+		&secret.CreatorID,
+		&secret.CreatedAt,
+		&secret.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
@@ -0,0 +1,173 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type SessionsRepository interface {
+	Create(userID int64, tokenHash, userAgent, ipAddress string, expiresAt int64) (*models.Session, error)
+	FindByID(id int64) (*models.Session, error)
+	FindByTokenHash(tokenHash string) (*models.Session, error)
+	FindActiveByUserID(userID int64, now int64) ([]*models.Session, error)
+	Touch(id int64, ipAddress string, expiresAt int64) error
+	Delete(id int64) error
+	DeleteAllByUserID(userID int64) error
+	DeleteOthersByUserID(userID, keepID int64) error
+	DeleteExpired(now int64) error
+}
+
+type sessionsRepository struct {
+	db *sql.DB
+}
+
+func NewSessionsRepository(db *sql.DB) SessionsRepository {
+	return &sessionsRepository{db: db}
+}
+
+func (r *sessionsRepository) Create(userID int64, tokenHash, userAgent, ipAddress string, expiresAt int64) (*models.Session, error) {
+	query := `
+		INSERT INTO sessions (user_id, token_hash, user_agent, ip_address, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id, user_id, token_hash, user_agent, ip_address, last_seen_at, expires_at, created_at
+	`
+
+	session := &models.Session{}
+	err := r.db.QueryRow(query, userID, tokenHash, userAgent, ipAddress, expiresAt).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.TokenHash,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (r *sessionsRepository) FindByID(id int64) (*models.Session, error) {
+	query := `
+		SELECT id, user_id, token_hash, user_agent, ip_address, last_seen_at, expires_at, created_at
+		FROM sessions
+		WHERE id = ?
+	`
+
+	return r.findOne(query, id)
+}
+
+func (r *sessionsRepository) FindByTokenHash(tokenHash string) (*models.Session, error) {
+	query := `
+		SELECT id, user_id, token_hash, user_agent, ip_address, last_seen_at, expires_at, created_at
+		FROM sessions
+		WHERE token_hash = ?
+	`
+
+	return r.findOne(query, tokenHash)
+}
+
+// FindActiveByUserID returns the unexpired sessions of a user, most recently used first
+func (r *sessionsRepository) FindActiveByUserID(userID int64, now int64) ([]*models.Session, error) {
+	query := `
+		SELECT id, user_id, token_hash, user_agent, ip_address, last_seen_at, expires_at, created_at
+		FROM sessions
+		WHERE user_id = ? AND expires_at > ?
+		ORDER BY last_seen_at DESC, id DESC
+	`
+
+	rows, err := r.db.Query(query, userID, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session := &models.Session{}
+		err := rows.Scan(
+			&session.ID,
+			&session.UserID,
+			&session.TokenHash,
+			&session.UserAgent,
+			&session.IPAddress,
+			&session.LastSeenAt,
+			&session.ExpiresAt,
+			&session.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch records activity on a session and pushes its expiry out
+func (r *sessionsRepository) Touch(id int64, ipAddress string, expiresAt int64) error {
+	query := `
+		UPDATE sessions
+		SET last_seen_at = unixepoch(), ip_address = ?, expires_at = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, ipAddress, expiresAt, id)
+	return err
+}
+
+func (r *sessionsRepository) Delete(id int64) error {
+	query := `DELETE FROM sessions WHERE id = ?`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *sessionsRepository) DeleteAllByUserID(userID int64) error {
+	query := `DELETE FROM sessions WHERE user_id = ?`
+
+	_, err := r.db.Exec(query, userID)
+	return err
+}
+
+// DeleteOthersByUserID signs a user out of every session except keepID
+func (r *sessionsRepository) DeleteOthersByUserID(userID, keepID int64) error {
+	query := `DELETE FROM sessions WHERE user_id = ? AND id != ?`
+
+	_, err := r.db.Exec(query, userID, keepID)
+	return err
+}
+
+func (r *sessionsRepository) DeleteExpired(now int64) error {
+	query := `DELETE FROM sessions WHERE expires_at <= ?`
+
+	_, err := r.db.Exec(query, now)
+	return err
+}
+
+func (r *sessionsRepository) findOne(query string, args ...any) (*models.Session, error) {
+	session := &models.Session{}
+	err := r.db.QueryRow(query, args...).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.TokenHash,
+		&session.UserAgent,
+		&session.IPAddress,
+		&session.LastSeenAt,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return session, nil
+}
@@ -12,17 +12,38 @@ type TicketsRepository interface {
 	FindByID(id int64) (*models.Ticket, error)
 	FindByRepositoryAndNumber(repositoryID, number int64) (*models.Ticket, error)
 	FindAllByRepository(repositoryID int64, status string) ([]*models.Ticket, error)
+	// FindPageByRepository returns a page of the tickets of the repository, all of them if status is empty
+	FindPageByRepository(repositoryID int64, status string, page Page) ([]*models.Ticket, error)
+	FindAllByMilestone(milestoneID int64, status string) ([]*models.Ticket, error)
 	CountByRepository(repositoryID int64, status string) (int64, error)
 	Update(ticket *models.Ticket) error
 	Close(ticketID, closedByID int64) error
 	Reopen(ticketID int64) error
+	SetMilestone(ticketID int64, milestoneID *int64) error
 	Delete(id int64) error
 
 	// Comments
 	CreateComment(ticketID, authorID int64, body string) (*models.TicketComment, error)
 	FindCommentsByTicket(ticketID int64) ([]*models.TicketComment, error)
+	FindCommentPageByTicket(ticketID int64, page Page) ([]*models.TicketComment, error)
 	UpdateComment(comment *models.TicketComment) error
 	DeleteComment(id int64) error
+
+	// Labels
+	CreateLabel(repositoryID int64, name, color string, description *string) (*models.TicketLabel, error)
+	FindLabelByName(repositoryID int64, name string) (*models.TicketLabel, error)
+	FindLabelsByRepository(repositoryID int64) ([]*models.TicketLabel, error)
+	FindLabelPageByRepository(repositoryID int64, page Page) ([]*models.TicketLabel, error)
+	FindLabelsByTicket(ticketID int64) ([]*models.TicketLabel, error)
+	UpdateLabel(label *models.TicketLabel) error
+	// DeleteLabel deletes the label and takes it off every ticket
+	DeleteLabel(id int64) error
+	AddLabel(ticketID, labelID int64) error
+	RemoveLabel(ticketID, labelID int64) error
+
+	// Assignees
+	AddAssignee(ticketID, userID, assignedByID int64) error
+	FindAssigneesByTicket(ticketID int64) ([]*models.TicketAssignee, error)
 }
 
 type ticketsRepository struct {
@@ -48,7 +69,7 @@ func (r *ticketsRepository) Create(repositoryID, authorID int64, title string, b
 	query := `
 		INSERT INTO tickets (repository_id, number, title, body, author_id)
 		VALUES (?, ?, ?, ?, ?)
-		RETURNING id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, created_at, updated_at
+		RETURNING id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, milestone_id, created_at, updated_at
 	`
 
 	ticket := &models.Ticket{}
@@ -62,6 +83,7 @@ func (r *ticketsRepository) Create(repositoryID, authorID int64, title string, b
 		&ticket.AuthorID,
 		&ticket.ClosedAt,
 		&ticket.ClosedByID,
+		&ticket.MilestoneID,
 		&ticket.CreatedAt,
 		&ticket.UpdatedAt,
 	)
@@ -74,7 +96,7 @@ func (r *ticketsRepository) Create(repositoryID, authorID int64, title string, b
 
 func (r *ticketsRepository) FindByID(id int64) (*models.Ticket, error) {
 	query := `
-		SELECT id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, created_at, updated_at
+		SELECT id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, milestone_id, created_at, updated_at
 		FROM tickets
 		WHERE id = ?
 	`
@@ -90,6 +112,7 @@ func (r *ticketsRepository) FindByID(id int64) (*models.Ticket, error) {
 		&ticket.AuthorID,
 		&ticket.ClosedAt,
 		&ticket.ClosedByID,
+		&ticket.MilestoneID,
 		&ticket.CreatedAt,
 		&ticket.UpdatedAt,
 	)
@@ -105,7 +128,7 @@ func (r *ticketsRepository) FindByID(id int64) (*models.Ticket, error) {
 
 func (r *ticketsRepository) FindByRepositoryAndNumber(repositoryID, number int64) (*models.Ticket, error) {
 	query := `
-		SELECT id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, created_at, updated_at
+		SELECT id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, milestone_id, created_at, updated_at
 		FROM tickets
 		WHERE repository_id = ? AND number = ?
 	`
@@ -121,6 +144,7 @@ func (r *ticketsRepository) FindByRepositoryAndNumber(repositoryID, number int64
 		&ticket.AuthorID,
 		&ticket.ClosedAt,
 		&ticket.ClosedByID,
+		&ticket.MilestoneID,
 		&ticket.CreatedAt,
 		&ticket.UpdatedAt,
 	)
@@ -136,7 +160,7 @@ func (r *ticketsRepository) FindByRepositoryAndNumber(repositoryID, number int64
 
 func (r *ticketsRepository) FindAllByRepository(repositoryID int64, status string) ([]*models.Ticket, error) {
 	query := `
-		SELECT id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, created_at, updated_at
+		SELECT id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, milestone_id, created_at, updated_at
 		FROM tickets
 		WHERE repository_id = ?
 	`
@@ -149,6 +173,69 @@ func (r *ticketsRepository) FindAllByRepository(repositoryID int64, status strin
 
 	query += " ORDER BY number DESC"
 
+	return r.findTickets(query, args...)
+}
+
+func (r *ticketsRepository) FindPageByRepository(repositoryID int64, status string, page Page) ([]*models.Ticket, error) {
+	query := `
+		SELECT id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, milestone_id, created_at, updated_at
+		FROM tickets
+		WHERE repository_id = ? AND (? = '' OR status = ?) AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return r.findTickets(query, repositoryID, status, status, page.After, page.Limit)
+}
+
+func (r *ticketsRepository) findTickets(query string, args ...any) ([]*models.Ticket, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tickets []*models.Ticket
+	for rows.Next() {
+		ticket := &models.Ticket{}
+		err := rows.Scan(
+			&ticket.ID,
+			&ticket.RepositoryID,
+			&ticket.Number,
+			&ticket.Title,
+			&ticket.Body,
+			&ticket.Status,
+			&ticket.AuthorID,
+			&ticket.ClosedAt,
+			&ticket.ClosedByID,
+			&ticket.MilestoneID,
+			&ticket.CreatedAt,
+			&ticket.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, ticket)
+	}
+
+	return tickets, nil
+}
+
+func (r *ticketsRepository) FindAllByMilestone(milestoneID int64, status string) ([]*models.Ticket, error) {
+	query := `
+		SELECT id, repository_id, number, title, body, status, author_id, closed_at, closed_by_id, milestone_id, created_at, updated_at
+		FROM tickets
+		WHERE milestone_id = ?
+	`
+
+	args := []interface{}{milestoneID}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+
+	query += " ORDER BY number DESC"
+
 	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
@@ -168,6 +255,7 @@ func (r *ticketsRepository) FindAllByRepository(repositoryID int64, status strin
 			&ticket.AuthorID,
 			&ticket.ClosedAt,
 			&ticket.ClosedByID,
+			&ticket.MilestoneID,
 			&ticket.CreatedAt,
 			&ticket.UpdatedAt,
 		)
@@ -227,6 +315,17 @@ func (r *ticketsRepository) Reopen(ticketID int64) error {
 	return err
 }
 
+func (r *ticketsRepository) SetMilestone(ticketID int64, milestoneID *int64) error {
+	query := `
+		UPDATE tickets
+		SET milestone_id = ?, updated_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, milestoneID, ticketID)
+	return err
+}
+
 func (r *ticketsRepository) Delete(id int64) error {
 	query := `DELETE FROM tickets WHERE id = ?`
 	_, err := r.db.Exec(query, id)
@@ -269,7 +368,23 @@ func (r *ticketsRepository) FindCommentsByTicket(ticketID int64) ([]*models.Tick
 		ORDER BY created_at ASC
 	`
 
-	rows, err := r.db.Query(query, ticketID)
+	return r.findComments(query, ticketID)
+}
+
+func (r *ticketsRepository) FindCommentPageByTicket(ticketID int64, page Page) ([]*models.TicketComment, error) {
+	query := `
+		SELECT id, ticket_id, author_id, body, created_at, updated_at
+		FROM ticket_comments
+		WHERE ticket_id = ? AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return r.findComments(query, ticketID, page.After, page.Limit)
+}
+
+func (r *ticketsRepository) findComments(query string, args ...any) ([]*models.TicketComment, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -332,3 +447,222 @@ func (r *ticketsRepository) DeleteComment(id int64) error {
 
 	return nil
 }
+
+// Labels
+
+func (r *ticketsRepository) CreateLabel(repositoryID int64, name, color string, description *string) (*models.TicketLabel, error) {
+	query := `
+		INSERT INTO ticket_labels (repository_id, name, color, description)
+		VALUES (?, ?, ?, ?)
+		RETURNING id, repository_id, name, color, description, created_at
+	`
+
+	label := &models.TicketLabel{}
+	err := r.db.QueryRow(query, repositoryID, name, color, description).Scan(
+		&label.ID,
+		&label.RepositoryID,
+		&label.Name,
+		&label.Color,
+		&label.Description,
+		&label.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return label, nil
+}
+
+func (r *ticketsRepository) FindLabelByName(repositoryID int64, name string) (*models.TicketLabel, error) {
+	query := `
+		SELECT id, repository_id, name, color, description, created_at
+		FROM ticket_labels
+		WHERE repository_id = ? AND name = ?
+	`
+
+	label := &models.TicketLabel{}
+	err := r.db.QueryRow(query, repositoryID, name).Scan(
+		&label.ID,
+		&label.RepositoryID,
+		&label.Name,
+		&label.Color,
+		&label.Description,
+		&label.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return label, nil
+}
+
+func (r *ticketsRepository) FindLabelsByRepository(repositoryID int64) ([]*models.TicketLabel, error) {
+	query := `
+		SELECT id, repository_id, name, color, description, created_at
+		FROM ticket_labels
+		WHERE repository_id = ?
+		ORDER BY name ASC
+	`
+
+	return r.findLabels(query, repositoryID)
+}
+
+func (r *ticketsRepository) FindLabelPageByRepository(repositoryID int64, page Page) ([]*models.TicketLabel, error) {
+	query := `
+		SELECT id, repository_id, name, color, description, created_at
+		FROM ticket_labels
+		WHERE repository_id = ? AND id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return r.findLabels(query, repositoryID, page.After, page.Limit)
+}
+
+func (r *ticketsRepository) findLabels(query string, args ...any) ([]*models.TicketLabel, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []*models.TicketLabel
+	for rows.Next() {
+		label := &models.TicketLabel{}
+		err := rows.Scan(
+			&label.ID,
+			&label.RepositoryID,
+			&label.Name,
+			&label.Color,
+			&label.Description,
+			&label.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+
+	return labels, nil
+}
+
+func (r *ticketsRepository) FindLabelsByTicket(ticketID int64) ([]*models.TicketLabel, error) {
+	query := `
+		SELECT l.id, l.repository_id, l.name, l.color, l.description, l.created_at
+		FROM ticket_labels l
+		INNER JOIN ticket_label_assignments a ON a.label_id = l.id
+		WHERE a.ticket_id = ?
+		ORDER BY l.name ASC
+	`
+
+	rows, err := r.db.Query(query, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var labels []*models.TicketLabel
+	for rows.Next() {
+		label := &models.TicketLabel{}
+		err := rows.Scan(
+			&label.ID,
+			&label.RepositoryID,
+			&label.Name,
+			&label.Color,
+			&label.Description,
+			&label.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		labels = append(labels, label)
+	}
+
+	return labels, nil
+}
+
+func (r *ticketsRepository) AddLabel(ticketID, labelID int64) error {
+	query := `
+		INSERT INTO ticket_label_assignments (ticket_id, label_id)
+		VALUES (?, ?)
+		ON CONFLICT(ticket_id, label_id) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query, ticketID, labelID)
+	return err
+}
+
+func (r *ticketsRepository) UpdateLabel(label *models.TicketLabel) error {
+	query := `
+		UPDATE ticket_labels
+		SET name = ?, color = ?, description = ?
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, label.Name, label.Color, label.Description, label.ID)
+	return err
+}
+
+func (r *ticketsRepository) DeleteLabel(id int64) error {
+	if _, err := r.db.Exec(`DELETE FROM ticket_label_assignments WHERE label_id = ?`, id); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`DELETE FROM ticket_labels WHERE id = ?`, id)
+	return err
+}
+
+func (r *ticketsRepository) RemoveLabel(ticketID, labelID int64) error {
+	query := `DELETE FROM ticket_label_assignments WHERE ticket_id = ? AND label_id = ?`
+	_, err := r.db.Exec(query, ticketID, labelID)
+	return err
+}
+
+// Assignees
+
+func (r *ticketsRepository) AddAssignee(ticketID, userID, assignedByID int64) error {
+	query := `
+		INSERT INTO ticket_assignees (ticket_id, user_id, assigned_by_id)
+		VALUES (?, ?, ?)
+		ON CONFLICT(ticket_id, user_id) DO NOTHING
+	`
+
+	_, err := r.db.Exec(query, ticketID, userID, assignedByID)
+	return err
+}
+
+func (r *ticketsRepository) FindAssigneesByTicket(ticketID int64) ([]*models.TicketAssignee, error) {
+	query := `
+		SELECT id, ticket_id, user_id, assigned_by_id, created_at
+		FROM ticket_assignees
+		WHERE ticket_id = ?
+		ORDER BY created_at ASC
+	`
+
+	rows, err := r.db.Query(query, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var assignees []*models.TicketAssignee
+	for rows.Next() {
+		assignee := &models.TicketAssignee{}
+		err := rows.Scan(
+			&assignee.ID,
+			&assignee.TicketID,
+			&assignee.UserID,
+			&assignee.AssignedByID,
+			&assignee.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		assignees = append(assignees, assignee)
+	}
+
+	return assignees, nil
+}
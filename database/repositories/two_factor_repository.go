@@ -0,0 +1,249 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type TwoFactorRepository interface {
+	// Credentials
+	SaveCredential(userID int64, secret string) (*models.TwoFactorCredential, error)
+	FindCredential(userID int64) (*models.TwoFactorCredential, error)
+	ConfirmCredential(userID, step int64) error
+	UseStep(userID, step int64) (bool, error)
+	DeleteCredential(userID int64) error
+
+	// Recovery codes
+	ReplaceRecoveryCodes(userID int64, codeHashes []string) error
+	UseRecoveryCode(userID int64, codeHash string) (bool, error)
+	CountUnusedRecoveryCodes(userID int64) (int, error)
+
+	// Challenges of sign-ins waiting for a second factor
+	CreateChallenge(userID int64, tokenHash string, expiresAt int64) (*models.TwoFactorChallenge, error)
+	FindValidChallenge(tokenHash string, now int64) (*models.TwoFactorChallenge, error)
+	IncrementChallengeAttempts(id int64) error
+	DeleteChallenge(id int64) error
+	DeleteExpiredChallenges(now int64) error
+}
+
+type twoFactorRepository struct {
+	db *sql.DB
+}
+
+func NewTwoFactorRepository(db *sql.DB) TwoFactorRepository {
+	return &twoFactorRepository{db: db}
+}
+
+// SaveCredential stores a new, unconfirmed secret for the user, replacing any previous one
+func (r *twoFactorRepository) SaveCredential(userID int64, secret string) (*models.TwoFactorCredential, error) {
+	query := `
+		INSERT INTO two_factor_credentials (user_id, secret)
+		VALUES (?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			confirmed_at = NULL,
+			last_used_step = 0,
+			created_at = unixepoch()
+		RETURNING user_id, secret, confirmed_at, last_used_step, created_at
+	`
+
+	credential := &models.TwoFactorCredential{}
+	err := r.db.QueryRow(query, userID, secret).Scan(
+		&credential.UserID,
+		&credential.Secret,
+		&credential.ConfirmedAt,
+		&credential.LastUsedStep,
+		&credential.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+func (r *twoFactorRepository) FindCredential(userID int64) (*models.TwoFactorCredential, error) {
+	query := `
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM two_factor_credentials
+		WHERE user_id = ?
+	`
+
+	credential := &models.TwoFactorCredential{}
+	err := r.db.QueryRow(query, userID).Scan(
+		&credential.UserID,
+		&credential.Secret,
+		&credential.ConfirmedAt,
+		&credential.LastUsedStep,
+		&credential.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return credential, nil
+}
+
+// ConfirmCredential enables two-factor authentication, step is the time step of the code that confirmed it
+func (r *twoFactorRepository) ConfirmCredential(userID, step int64) error {
+	query := `
+		UPDATE two_factor_credentials
+		SET confirmed_at = unixepoch(), last_used_step = ?
+		WHERE user_id = ?
+	`
+
+	_, err := r.db.Exec(query, step, userID)
+	return err
+}
+
+// UseStep records that a code of the given time step was accepted. It reports false if
+// a code of this or a later step was already used, so a code can't be replayed.
+func (r *twoFactorRepository) UseStep(userID, step int64) (bool, error) {
+	query := `
+		UPDATE two_factor_credentials
+		SET last_used_step = ?
+		WHERE user_id = ? AND last_used_step < ?
+	`
+
+	result, err := r.db.Exec(query, step, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+// DeleteCredential disables two-factor authentication and removes the recovery codes
+func (r *twoFactorRepository) DeleteCredential(userID int64) error {
+	if _, err := r.db.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`DELETE FROM two_factor_credentials WHERE user_id = ?`, userID)
+	return err
+}
+
+func (r *twoFactorRepository) ReplaceRecoveryCodes(userID int64, codeHashes []string) error {
+	if _, err := r.db.Exec(`DELETE FROM two_factor_recovery_codes WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		_, err := r.db.Exec(`INSERT INTO two_factor_recovery_codes (user_id, code_hash) VALUES (?, ?)`, userID, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used. It reports false if there is no such code.
+func (r *twoFactorRepository) UseRecoveryCode(userID int64, codeHash string) (bool, error) {
+	query := `
+		UPDATE two_factor_recovery_codes
+		SET used_at = unixepoch()
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows > 0, nil
+}
+
+func (r *twoFactorRepository) CountUnusedRecoveryCodes(userID int64) (int, error) {
+	query := `SELECT COUNT(*) FROM two_factor_recovery_codes WHERE user_id = ? AND used_at IS NULL`
+
+	var count int
+	err := r.db.QueryRow(query, userID).Scan(&count)
+	return count, err
+}
+
+func (r *twoFactorRepository) CreateChallenge(userID int64, tokenHash string, expiresAt int64) (*models.TwoFactorChallenge, error) {
+	query := `
+		INSERT INTO two_factor_challenges (user_id, token_hash, expires_at)
+		VALUES (?, ?, ?)
+		RETURNING id, user_id, token_hash, attempts, expires_at, created_at
+	`
+
+	challenge := &models.TwoFactorChallenge{}
+	err := r.db.QueryRow(query, userID, tokenHash, expiresAt).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.TokenHash,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+// FindValidChallenge returns the challenge with the given hash if it hasn't expired
+func (r *twoFactorRepository) FindValidChallenge(tokenHash string, now int64) (*models.TwoFactorChallenge, error) {
+	query := `
+		SELECT id, user_id, token_hash, attempts, expires_at, created_at
+		FROM two_factor_challenges
+		WHERE token_hash = ? AND expires_at > ?
+	`
+
+	challenge := &models.TwoFactorChallenge{}
+	err := r.db.QueryRow(query, tokenHash, now).Scan(
+		&challenge.ID,
+		&challenge.UserID,
+		&challenge.TokenHash,
+		&challenge.Attempts,
+		&challenge.ExpiresAt,
+		&challenge.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (r *twoFactorRepository) IncrementChallengeAttempts(id int64) error {
+	query := `UPDATE two_factor_challenges SET attempts = attempts + 1 WHERE id = ?`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *twoFactorRepository) DeleteChallenge(id int64) error {
+	query := `DELETE FROM two_factor_challenges WHERE id = ?`
+
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *twoFactorRepository) DeleteExpiredChallenges(now int64) error {
+	query := `DELETE FROM two_factor_challenges WHERE expires_at <= ?`
+
+	_, err := r.db.Exec(query, now)
+	return err
+}
@@ -0,0 +1,352 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type UserEmailsRepository interface {
+	Create(userID int64, email string, isPrimary, verified bool) (*models.UserEmail, error)
+	FindByID(id int64) (*models.UserEmail, error)
+	FindVerifiedByEmail(email string) (*models.UserEmail, error)
+	FindByUserAndEmail(userID int64, email string) (*models.UserEmail, error)
+	FindByUserID(userID int64) ([]*models.UserEmail, error)
+	FindVerifiedPrimaryByUser(userID int64) (*models.UserEmail, error)
+	FindUserIDsByVerifiedEmails(emails []string) (map[string]int64, error)
+	MarkVerified(id int64) error
+	SetPrimary(userID, id int64) error
+	Delete(id int64) error
+
+	// Verification tokens
+	CreateVerificationToken(userEmailID int64, tokenHash string, expiresAt int64) (*models.EmailVerificationToken, error)
+	FindValidVerificationToken(tokenHash string, now int64) (*models.EmailVerificationToken, error)
+	ConsumeVerificationToken(id int64) (bool, error)
+	CountVerificationTokensSince(userEmailID int64, since int64) (int64, error)
+}
+
+type userEmailsRepository struct {
+	db *sql.DB
+}
+
+func NewUserEmailsRepository(db *sql.DB) UserEmailsRepository {
+	return &userEmailsRepository{db: db}
+}
+
+func (r *userEmailsRepository) Create(userID int64, email string, isPrimary, verified bool) (*models.UserEmail, error) {
+	query := `
+		INSERT INTO user_emails (user_id, email, is_primary, verified_at)
+		VALUES (?, ?, ?, CASE WHEN ? THEN unixepoch() END)
+		RETURNING id, user_id, email, is_primary, verified_at, created_at
+	`
+
+	userEmail := &models.UserEmail{}
+	err := r.db.QueryRow(query, userID, email, isPrimary, verified).Scan(
+		&userEmail.ID,
+		&userEmail.UserID,
+		&userEmail.Email,
+		&userEmail.IsPrimary,
+		&userEmail.VerifiedAt,
+		&userEmail.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return userEmail, nil
+}
+
+func (r *userEmailsRepository) FindByID(id int64) (*models.UserEmail, error) {
+	query := `
+		SELECT id, user_id, email, is_primary, verified_at, created_at
+		FROM user_emails
+		WHERE id = ?
+	`
+
+	return r.findOne(query, id)
+}
+
+// FindVerifiedByEmail looks up the verified address case-insensitively, regardless of which user it
+// belongs to. Unverified addresses don't belong to anyone and are never returned.
+func (r *userEmailsRepository) FindVerifiedByEmail(email string) (*models.UserEmail, error) {
+	query := `
+		SELECT id, user_id, email, is_primary, verified_at, created_at
+		FROM user_emails
+		WHERE email = ? AND verified_at IS NOT NULL
+	`
+
+	return r.findOne(query, email)
+}
+
+// FindByUserAndEmail looks up an address of the user case-insensitively, verified or not
+func (r *userEmailsRepository) FindByUserAndEmail(userID int64, email string) (*models.UserEmail, error) {
+	query := `
+		SELECT id, user_id, email, is_primary, verified_at, created_at
+		FROM user_emails
+		WHERE user_id = ? AND email = ?
+	`
+
+	return r.findOne(query, userID, email)
+}
+
+// FindByUserID returns the addresses of a user, primary first
+func (r *userEmailsRepository) FindByUserID(userID int64) ([]*models.UserEmail, error) {
+	query := `
+		SELECT id, user_id, email, is_primary, verified_at, created_at
+		FROM user_emails
+		WHERE user_id = ?
+		ORDER BY is_primary DESC, created_at ASC, id ASC
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var userEmails []*models.UserEmail
+	for rows.Next() {
+		userEmail := &models.UserEmail{}
+		err := rows.Scan(
+			&userEmail.ID,
+			&userEmail.UserID,
+			&userEmail.Email,
+			&userEmail.IsPrimary,
+			&userEmail.VerifiedAt,
+			&userEmail.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		userEmails = append(userEmails, userEmail)
+	}
+
+	return userEmails, rows.Err()
+}
+
+// FindVerifiedPrimaryByUser returns the user's primary address, or nil if it isn't verified
+func (r *userEmailsRepository) FindVerifiedPrimaryByUser(userID int64) (*models.UserEmail, error) {
+	query := `
+		SELECT id, user_id, email, is_primary, verified_at, created_at
+		FROM user_emails
+		WHERE user_id = ? AND is_primary = 1 AND verified_at IS NOT NULL
+	`
+
+	return r.findOne(query, userID)
+}
+
+// FindUserIDsByVerifiedEmails maps verified addresses to the users owning them, e.g. to
+// attribute commits. Keys are lowercased; unknown and unverified addresses are left out.
+func (r *userEmailsRepository) FindUserIDsByVerifiedEmails(emails []string) (map[string]int64, error) {
+	userIDs := make(map[string]int64)
+	if len(emails) == 0 {
+		return userIDs, nil
+	}
+
+	placeholders := make([]string, len(emails))
+	args := make([]any, len(emails))
+	for i, email := range emails {
+		placeholders[i] = "?"
+		args[i] = email
+	}
+
+	query := `
+		SELECT email, user_id
+		FROM user_emails
+		WHERE verified_at IS NOT NULL AND email IN (` + strings.Join(placeholders, ", ") + `)
+	`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var email string
+		var userID int64
+		if err := rows.Scan(&email, &userID); err != nil {
+			return nil, err
+		}
+		userIDs[strings.ToLower(email)] = userID
+	}
+
+	return userIDs, rows.Err()
+}
+
+// MarkVerified verifies the address and removes it from other users that added it without verifying
+// it, along with their verification links. Primary addresses of other users are kept, they are
+// their only one.
+func (r *userEmailsRepository) MarkVerified(id int64) error {
+	query := `
+		UPDATE user_emails
+		SET verified_at = unixepoch()
+		WHERE id = ? AND verified_at IS NULL
+	`
+	if _, err := r.db.Exec(query, id); err != nil {
+		return err
+	}
+
+	// Unverified claims of the address by other users
+	claims := `
+		SELECT claims.id
+		FROM user_emails AS claims
+		JOIN user_emails AS verified ON verified.email = claims.email
+		WHERE verified.id = ? AND claims.user_id != verified.user_id
+			AND claims.verified_at IS NULL AND claims.is_primary = 0
+	`
+	if _, err := r.db.Exec(`DELETE FROM email_verification_tokens WHERE user_email_id IN (`+claims+`)`, id); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`DELETE FROM user_emails WHERE id IN (`+claims+`)`, id)
+	return err
+}
+
+// SetPrimary makes the address the user's primary one and mirrors it in users.email
+func (r *userEmailsRepository) SetPrimary(userID, id int64) error {
+	query := `
+		UPDATE user_emails
+		SET is_primary = (id = ?)
+		WHERE user_id = ?
+	`
+
+	if _, err := r.db.Exec(query, id, userID); err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE users
+		SET email = (SELECT email FROM user_emails WHERE id = ?)
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, id, userID)
+	return err
+}
+
+func (r *userEmailsRepository) Delete(id int64) error {
+	query := `DELETE FROM user_emails WHERE id = ?`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	_, err = r.db.Exec(`DELETE FROM email_verification_tokens WHERE user_email_id = ?`, id)
+	return err
+}
+
+func (r *userEmailsRepository) CreateVerificationToken(userEmailID int64, tokenHash string, expiresAt int64) (*models.EmailVerificationToken, error) {
+	query := `
+		INSERT INTO email_verification_tokens (user_email_id, token_hash, expires_at)
+		VALUES (?, ?, ?)
+		RETURNING id, user_email_id, token_hash, expires_at, used_at, created_at
+	`
+
+	token := &models.EmailVerificationToken{}
+	err := r.db.QueryRow(query, userEmailID, tokenHash, expiresAt).Scan(
+		&token.ID,
+		&token.UserEmailID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// FindValidVerificationToken returns the token with the given hash if it is unused and not expired
+func (r *userEmailsRepository) FindValidVerificationToken(tokenHash string, now int64) (*models.EmailVerificationToken, error) {
+	query := `
+		SELECT id, user_email_id, token_hash, expires_at, used_at, created_at
+		FROM email_verification_tokens
+		WHERE token_hash = ? AND used_at IS NULL AND expires_at > ?
+	`
+
+	token := &models.EmailVerificationToken{}
+	err := r.db.QueryRow(query, tokenHash, now).Scan(
+		&token.ID,
+		&token.UserEmailID,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.UsedAt,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// ConsumeVerificationToken marks a token as used. It reports false if the token was already used.
+func (r *userEmailsRepository) ConsumeVerificationToken(id int64) (bool, error) {
+	query := `
+		UPDATE email_verification_tokens
+		SET used_at = unixepoch()
+		WHERE id = ? AND used_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return rows == 1, nil
+}
+
+func (r *userEmailsRepository) CountVerificationTokensSince(userEmailID int64, since int64) (int64, error) {
+	query := `
+		SELECT COUNT(*)
+		FROM email_verification_tokens
+		WHERE user_email_id = ? AND created_at >= ?
+	`
+
+	var count int64
+	err := r.db.QueryRow(query, userEmailID, since).Scan(&count)
+	return count, err
+}
+
+func (r *userEmailsRepository) findOne(query string, args ...any) (*models.UserEmail, error) {
+	userEmail := &models.UserEmail{}
+	err := r.db.QueryRow(query, args...).Scan(
+		&userEmail.ID,
+		&userEmail.UserID,
+		&userEmail.Email,
+		&userEmail.IsPrimary,
+		&userEmail.VerifiedAt,
+		&userEmail.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return userEmail, nil
+}
@@ -0,0 +1,75 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type UserIdentitiesRepository interface {
+	Create(userID int64, provider, subject string) (*models.UserIdentity, error)
+	FindByProviderAndSubject(provider, subject string) (*models.UserIdentity, error)
+	CountByUser(userID int64) (int, error)
+}
+
+type userIdentitiesRepository struct {
+	db *sql.DB
+}
+
+func NewUserIdentitiesRepository(db *sql.DB) UserIdentitiesRepository {
+	return &userIdentitiesRepository{db: db}
+}
+
+func (r *userIdentitiesRepository) Create(userID int64, provider, subject string) (*models.UserIdentity, error) {
+	query := `
+		INSERT INTO user_identities (user_id, provider, subject)
+		VALUES (?, ?, ?)
+		RETURNING id, user_id, provider, subject, created_at
+	`
+
+	identity := &models.UserIdentity{}
+	err := r.db.QueryRow(query, userID, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+func (r *userIdentitiesRepository) FindByProviderAndSubject(provider, subject string) (*models.UserIdentity, error) {
+	query := `
+		SELECT id, user_id, provider, subject, created_at
+		FROM user_identities
+		WHERE provider = ? AND subject = ?
+	`
+
+	identity := &models.UserIdentity{}
+	err := r.db.QueryRow(query, provider, subject).Scan(
+		&identity.ID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return identity, nil
+}
+
+func (r *userIdentitiesRepository) CountByUser(userID int64) (int, error) {
+	var count int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM user_identities WHERE user_id = ?`, userID).Scan(&count)
+	return count, err
+}
@@ -9,13 +9,17 @@ import (
 
 type UsersRepository interface {
 	Create(username, email, displayName, password string) (*models.User, error)
-	CreateFromGitHub(username, email, displayName, githubUserID string) (*models.User, error)
+	// CreateExternal creates a user without a password, who signs in with an identity provider
+	CreateExternal(username, email, displayName string) (*models.User, error)
 	FindByID(id int64) (*models.User, error)
 	FindByUsername(username string) (*models.User, error)
 	FindByEmail(email string) (*models.User, error)
-	FindByGitHubUserID(githubUserID string) (*models.User, error)
 	FindAll() ([]*models.User, error)
+	FindPage(page Page) ([]*models.User, error)
+	Search(query string, limit int) ([]*models.User, error)
 	Update(user *models.User) error
+	SetAdmin(id int64, isAdmin bool) error
+	SetSuspended(id int64, suspendedAt *int64) error
 	Delete(id int64) error
 }
 
@@ -31,7 +35,7 @@ func (r *usersRepository) Create(username, email, displayName, password string)
 	query := `
 		INSERT INTO users (username, email, display_name, password)
 		VALUES (?, ?, ?, ?)
-		RETURNING id, username, email, display_name, password, github_user_id, created_at, updated_at
+		RETURNING id, username, email, display_name, password, is_admin, suspended_at, created_at, updated_at
 	`
 
 	user := &models.User{}
@@ -41,7 +45,8 @@ func (r *usersRepository) Create(username, email, displayName, password string)
 		&user.Email,
 		&user.DisplayName,
 		&user.Password,
-		&user.GitHubUserID,
+		&user.IsAdmin,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -52,21 +57,22 @@ func (r *usersRepository) Create(username, email, displayName, password string)
 	return user, nil
 }
 
-func (r *usersRepository) CreateFromGitHub(username, email, displayName, githubUserID string) (*models.User, error) {
+func (r *usersRepository) CreateExternal(username, email, displayName string) (*models.User, error) {
 	query := `
-		INSERT INTO users (username, email, display_name, github_user_id)
-		VALUES (?, ?, ?, ?)
-		RETURNING id, username, email, display_name, password, github_user_id, created_at, updated_at
+		INSERT INTO users (username, email, display_name)
+		VALUES (?, ?, ?)
+		RETURNING id, username, email, display_name, password, is_admin, suspended_at, created_at, updated_at
 	`
 
 	user := &models.User{}
-	err := r.db.QueryRow(query, username, email, displayName, githubUserID).Scan(
+	err := r.db.QueryRow(query, username, email, displayName).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.DisplayName,
 		&user.Password,
-		&user.GitHubUserID,
+		&user.IsAdmin,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -79,7 +85,7 @@ func (r *usersRepository) CreateFromGitHub(username, email, displayName, githubU
 
 func (r *usersRepository) FindByID(id int64) (*models.User, error) {
 	query := `
-		SELECT id, username, email, display_name, password, github_user_id, created_at, updated_at
+		SELECT id, username, email, display_name, password, is_admin, suspended_at, created_at, updated_at
 		FROM users
 		WHERE id = ?
 	`
@@ -91,7 +97,8 @@ func (r *usersRepository) FindByID(id int64) (*models.User, error) {
 		&user.Email,
 		&user.DisplayName,
 		&user.Password,
-		&user.GitHubUserID,
+		&user.IsAdmin,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -107,7 +114,7 @@ func (r *usersRepository) FindByID(id int64) (*models.User, error) {
 
 func (r *usersRepository) FindByUsername(username string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, display_name, password, github_user_id, created_at, updated_at
+		SELECT id, username, email, display_name, password, is_admin, suspended_at, created_at, updated_at
 		FROM users
 		WHERE username = ?
 	`
@@ -119,7 +126,8 @@ func (r *usersRepository) FindByUsername(username string) (*models.User, error)
 		&user.Email,
 		&user.DisplayName,
 		&user.Password,
-		&user.GitHubUserID,
+		&user.IsAdmin,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -135,7 +143,7 @@ func (r *usersRepository) FindByUsername(username string) (*models.User, error)
 
 func (r *usersRepository) FindByEmail(email string) (*models.User, error) {
 	query := `
-		SELECT id, username, email, display_name, password, github_user_id, created_at, updated_at
+		SELECT id, username, email, display_name, password, is_admin, suspended_at, created_at, updated_at
 		FROM users
 		WHERE email = ?
 	`
@@ -147,35 +155,8 @@ func (r *usersRepository) FindByEmail(email string) (*models.User, error) {
 		&user.Email,
 		&user.DisplayName,
 		&user.Password,
-		&user.GitHubUserID,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	return user, nil
-}
-
-func (r *usersRepository) FindByGitHubUserID(githubUserID string) (*models.User, error) {
-	query := `
-		SELECT id, username, email, display_name, password, github_user_id, created_at, updated_at
-		FROM users
-		WHERE github_user_id = ?
-	`
-
-	user := &models.User{}
-	err := r.db.QueryRow(query, githubUserID).Scan(
-		&user.ID,
-		&user.Username,
-		&user.Email,
-		&user.DisplayName,
-		&user.Password,
-		&user.GitHubUserID,
+		&user.IsAdmin,
+		&user.SuspendedAt,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -192,11 +173,11 @@ func (r *usersRepository) FindByGitHubUserID(githubUserID string) (*models.User,
 func (r *usersRepository) Update(user *models.User) error {
 	query := `
 		UPDATE users
-		SET username = ?, email = ?, display_name = ?, password = ?, github_user_id = ?
+		SET username = ?, email = ?, display_name = ?, password = ?
 		WHERE id = ?
 	`
 
-	result, err := r.db.Exec(query, user.Username, user.Email, user.DisplayName, user.Password, user.GitHubUserID, user.ID)
+	result, err := r.db.Exec(query, user.Username, user.Email, user.DisplayName, user.Password, user.ID)
 	if err != nil {
 		return err
 	}
@@ -215,12 +196,28 @@ func (r *usersRepository) Update(user *models.User) error {
 
 func (r *usersRepository) FindAll() ([]*models.User, error) {
 	query := `
-		SELECT id, username, email, display_name, password, github_user_id, created_at, updated_at
+		SELECT id, username, email, display_name, password, is_admin, suspended_at, created_at, updated_at
 		FROM users
 		ORDER BY id ASC
 	`
 
-	rows, err := r.db.Query(query)
+	return r.findAll(query)
+}
+
+func (r *usersRepository) FindPage(page Page) ([]*models.User, error) {
+	query := `
+		SELECT id, username, email, display_name, password, is_admin, suspended_at, created_at, updated_at
+		FROM users
+		WHERE id > ?
+		ORDER BY id ASC
+		LIMIT ?
+	`
+
+	return r.findAll(query, page.After, page.Limit)
+}
+
+func (r *usersRepository) findAll(query string, args ...any) ([]*models.User, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -235,7 +232,8 @@ func (r *usersRepository) FindAll() ([]*models.User, error) {
 			&user.Email,
 			&user.DisplayName,
 			&user.Password,
-			&user.GitHubUserID,
+			&user.IsAdmin,
+			&user.SuspendedAt,
 			&user.CreatedAt,
 			&user.UpdatedAt,
 		)
@@ -248,7 +246,102 @@ func (r *usersRepository) FindAll() ([]*models.User, error) {
 	return users, nil
 }
 
+// Search returns users whose username, display name or email contains query, newest first
+func (r *usersRepository) Search(query string, limit int) ([]*models.User, error) {
+	sqlQuery := `
+		SELECT id, username, email, display_name, password, is_admin, suspended_at, created_at, updated_at
+		FROM users
+		WHERE username LIKE ?1 OR display_name LIKE ?1 OR email LIKE ?1
+		ORDER BY id DESC
+		LIMIT ?2
+	`
+
+	rows, err := r.db.Query(sqlQuery, "%"+query+"%", limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user := &models.User{}
+		err := rows.Scan(
+			&user.ID,
+			&user.Username,
+			&user.Email,
+			&user.DisplayName,
+			&user.Password,
+			&user.IsAdmin,
+			&user.SuspendedAt,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *usersRepository) SetAdmin(id int64, isAdmin bool) error {
+	_, err := r.db.Exec(`UPDATE users SET is_admin = ? WHERE id = ?`, isAdmin, id)
+	return err
+}
+
+// SetSuspended suspends the user at suspendedAt, or lifts the suspension if it is nil
+func (r *usersRepository) SetSuspended(id int64, suspendedAt *int64) error {
+	_, err := r.db.Exec(`UPDATE users SET suspended_at = ? WHERE id = ?`, suspendedAt, id)
+	return err
+}
+
+// Delete deletes the user with their account data: sessions, credentials, emails, tokens,
+// memberships, stars and notifications. Tickets and comments they wrote, and the workflow runs,
+// commit statuses and secrets they created, are kept. The caller deletes the repositories and
+// packages they own.
 func (r *usersRepository) Delete(id int64) error {
+	dependents := []string{
+		`DELETE FROM sessions WHERE user_id = ?`,
+		`DELETE FROM two_factor_credentials WHERE user_id = ?`,
+		`DELETE FROM two_factor_recovery_codes WHERE user_id = ?`,
+		`DELETE FROM two_factor_challenges WHERE user_id = ?`,
+		`DELETE FROM passkeys WHERE user_id = ?`,
+		`DELETE FROM passkey_challenges WHERE user_id = ?`,
+		`DELETE FROM user_identities WHERE user_id = ?`,
+		`DELETE FROM email_verification_tokens WHERE user_email_id IN (SELECT id FROM user_emails WHERE user_id = ?)`,
+		`DELETE FROM user_emails WHERE user_id = ?`,
+		`DELETE FROM access_token_repositories WHERE access_token_id IN (SELECT id FROM access_tokens WHERE user_id = ?)`,
+		`DELETE FROM access_tokens WHERE user_id = ?`,
+		`DELETE FROM oauth_authorization_codes WHERE user_id = ?`,
+		`DELETE FROM oauth_refresh_tokens WHERE user_id = ?`,
+		`DELETE FROM oauth_device_codes WHERE user_id = ?`,
+		`DELETE FROM access_tokens WHERE application_id IN (SELECT id FROM oauth_applications WHERE owner_user_id = ?)`,
+		`DELETE FROM oauth_refresh_tokens WHERE application_id IN (SELECT id FROM oauth_applications WHERE owner_user_id = ?)`,
+		`DELETE FROM oauth_authorization_codes WHERE application_id IN (SELECT id FROM oauth_applications WHERE owner_user_id = ?)`,
+		`DELETE FROM oauth_device_codes WHERE application_id IN (SELECT id FROM oauth_applications WHERE owner_user_id = ?)`,
+		`DELETE FROM oauth_applications WHERE owner_user_id = ?`,
+		`DELETE FROM organization_members WHERE user_id = ?`,
+		`DELETE FROM contributors WHERE user_id = ?`,
+		`DELETE FROM stars WHERE user_id = ?`,
+		`DELETE FROM ticket_assignees WHERE user_id = ?`,
+		`DELETE FROM ticket_reactions WHERE user_id = ?`,
+		`DELETE FROM ticket_subscriptions WHERE user_id = ?`,
+		`DELETE FROM notifications WHERE user_id = ?`,
+		`DELETE FROM password_reset_tokens WHERE user_id = ?`,
+		`UPDATE tickets SET closed_by_id = NULL WHERE closed_by_id = ?`,
+		`UPDATE auth_lockouts SET user_id = NULL WHERE user_id = ?`,
+		`UPDATE audit_events SET actor_id = NULL WHERE actor_id = ?`,
+		`UPDATE workflow_runs SET triggered_by_id = NULL WHERE triggered_by_id = ?`,
+		`UPDATE commit_statuses SET creator_id = NULL WHERE creator_id = ?`,
+		`UPDATE secrets SET creator_id = NULL WHERE creator_id = ?`,
+	}
+	for _, query := range dependents {
+		if _, err := r.db.Exec(query, id); err != nil {
+			return err
+		}
+	}
+
 	query := `DELETE FROM users WHERE id = ?`
 
 	result, err := r.db.Exec(query, id)
@@ -0,0 +1,157 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type WebhookDeliveriesRepository interface {
+	Enqueue(webhookID int64, guid, event string, action *string, payload string, redelivery bool) (*models.WebhookDelivery, error)
+	FindByID(id int64) (*models.WebhookDelivery, error)
+	// FindRecentByWebhook returns the latest deliveries of the webhook, newest first
+	FindRecentByWebhook(webhookID int64, limit int) ([]*models.WebhookDelivery, error)
+	FindDue(now int64, limit int) ([]*models.WebhookDelivery, error)
+	// RecordAttempt stores the outcome of an attempt and moves the delivery to status. Pending
+	// deliveries are tried again at nextAttemptAt.
+	RecordAttempt(id int64, attempt *models.WebhookAttempt, status string, nextAttemptAt int64) error
+}
+
+type webhookDeliveriesRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookDeliveriesRepository(db *sql.DB) WebhookDeliveriesRepository {
+	return &webhookDeliveriesRepository{db: db}
+}
+
+const webhookDeliveryColumns = `id, webhook_id, guid, event, action, payload, redelivery, status, attempts, next_attempt_at,
+		request_headers, response_status, response_headers, response_body, last_error, duration_ms, delivered_at, created_at`
+
+func (r *webhookDeliveriesRepository) Enqueue(webhookID int64, guid, event string, action *string, payload string, redelivery bool) (*models.WebhookDelivery, error) {
+	query := `
+		INSERT INTO webhook_deliveries (webhook_id, guid, event, action, payload, redelivery)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING ` + webhookDeliveryColumns
+
+	return scanWebhookDelivery(r.db.QueryRow(query, webhookID, guid, event, action, payload, redelivery))
+}
+
+func (r *webhookDeliveriesRepository) FindByID(id int64) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = ?`
+
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return delivery, nil
+}
+
+func (r *webhookDeliveriesRepository) FindRecentByWebhook(webhookID int64, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE webhook_id = ?
+		ORDER BY id DESC
+		LIMIT ?
+	`
+
+	return r.findAll(query, webhookID, limit)
+}
+
+// FindDue returns pending deliveries whose next attempt is due, oldest first
+func (r *webhookDeliveriesRepository) FindDue(now int64, limit int) ([]*models.WebhookDelivery, error) {
+	query := `
+		SELECT ` + webhookDeliveryColumns + `
+		FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= ?
+		ORDER BY next_attempt_at ASC, id ASC
+		LIMIT ?
+	`
+
+	return r.findAll(query, now, limit)
+}
+
+func (r *webhookDeliveriesRepository) RecordAttempt(id int64, attempt *models.WebhookAttempt, status string, nextAttemptAt int64) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = ?,
+			attempts = attempts + 1,
+			next_attempt_at = ?,
+			request_headers = ?,
+			response_status = ?,
+			response_headers = ?,
+			response_body = ?,
+			last_error = NULLIF(?, ''),
+			duration_ms = ?,
+			delivered_at = CASE WHEN ? = 'delivered' THEN unixepoch() ELSE delivered_at END
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query,
+		status,
+		nextAttemptAt,
+		attempt.RequestHeaders,
+		attempt.ResponseStatus,
+		attempt.ResponseHeaders,
+		attempt.ResponseBody,
+		attempt.Error,
+		attempt.DurationMS,
+		status,
+		id,
+	)
+	return err
+}
+
+func (r *webhookDeliveriesRepository) findAll(query string, args ...any) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	err := row.Scan(
+		&delivery.ID,
+		&delivery.WebhookID,
+		&delivery.GUID,
+		&delivery.Event,
+		&delivery.Action,
+		&delivery.Payload,
+		&delivery.Redelivery,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.RequestHeaders,
+		&delivery.ResponseStatus,
+		&delivery.ResponseHeaders,
+		&delivery.ResponseBody,
+		&delivery.LastError,
+		&delivery.DurationMS,
+		&delivery.DeliveredAt,
+		&delivery.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
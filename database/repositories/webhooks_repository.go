@@ -0,0 +1,196 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+type WebhooksRepository interface {
+	Create(webhook *models.Webhook) (*models.Webhook, error)
+	FindByID(id int64) (*models.Webhook, error)
+	FindAllByRepository(repositoryID int64) ([]*models.Webhook, error)
+	FindAllByOrganization(orgID int64) ([]*models.Webhook, error)
+	// FindActiveForRepository returns the active webhooks of the repository and of the organization
+	// owning it, orgID is nil for repositories of users
+	FindActiveForRepository(repositoryID int64, orgID *int64) ([]*models.Webhook, error)
+	Update(webhook *models.Webhook) error
+	// Delete deletes the webhook and its deliveries
+	Delete(id int64) error
+	// DeleteAllByRepository deletes the webhooks of a repository being deleted, and their deliveries
+	DeleteAllByRepository(repositoryID int64) error
+}
+
+type webhooksRepository struct {
+	db *sql.DB
+}
+
+func NewWebhooksRepository(db *sql.DB) WebhooksRepository {
+	return &webhooksRepository{db: db}
+}
+
+func (r *webhooksRepository) Create(webhook *models.Webhook) (*models.Webhook, error) {
+	query := `
+		INSERT INTO webhooks (repository_id, organization_id, url, secret, content_type, events, active)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id, repository_id, organization_id, url, secret, content_type, events, active, created_at, updated_at
+	`
+
+	return scanWebhook(r.db.QueryRow(query,
+		webhook.RepositoryID,
+		webhook.OrganizationID,
+		webhook.URL,
+		webhook.Secret,
+		webhook.ContentType,
+		strings.Join(webhook.Events, " "),
+		webhook.Active,
+	))
+}
+
+func (r *webhooksRepository) FindByID(id int64) (*models.Webhook, error) {
+	query := `
+		SELECT id, repository_id, organization_id, url, secret, content_type, events, active, created_at, updated_at
+		FROM webhooks
+		WHERE id = ?
+	`
+
+	webhook, err := scanWebhook(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (r *webhooksRepository) FindAllByRepository(repositoryID int64) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, repository_id, organization_id, url, secret, content_type, events, active, created_at, updated_at
+		FROM webhooks
+		WHERE repository_id = ?
+		ORDER BY id
+	`
+
+	return r.findAll(query, repositoryID)
+}
+
+func (r *webhooksRepository) FindAllByOrganization(orgID int64) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, repository_id, organization_id, url, secret, content_type, events, active, created_at, updated_at
+		FROM webhooks
+		WHERE organization_id = ?
+		ORDER BY id
+	`
+
+	return r.findAll(query, orgID)
+}
+
+func (r *webhooksRepository) FindActiveForRepository(repositoryID int64, orgID *int64) ([]*models.Webhook, error) {
+	query := `
+		SELECT id, repository_id, organization_id, url, secret, content_type, events, active, created_at, updated_at
+		FROM webhooks
+		WHERE active = 1 AND (repository_id = ? OR (? IS NOT NULL AND organization_id = ?))
+		ORDER BY id
+	`
+
+	return r.findAll(query, repositoryID, orgID, orgID)
+}
+
+func (r *webhooksRepository) Update(webhook *models.Webhook) error {
+	query := `
+		UPDATE webhooks
+		SET url = ?, secret = ?, content_type = ?, events = ?, active = ?, updated_at = unixepoch()
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query,
+		webhook.URL,
+		webhook.Secret,
+		webhook.ContentType,
+		strings.Join(webhook.Events, " "),
+		webhook.Active,
+		webhook.ID,
+	)
+	return err
+}
+
+func (r *webhooksRepository) Delete(id int64) error {
+	if _, err := r.db.Exec(`DELETE FROM webhook_deliveries WHERE webhook_id = ?`, id); err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(`DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
+
+func (r *webhooksRepository) DeleteAllByRepository(repositoryID int64) error {
+	query := `
+		DELETE FROM webhook_deliveries
+		WHERE webhook_id IN (SELECT id FROM webhooks WHERE repository_id = ?)
+	`
+	if _, err := r.db.Exec(query, repositoryID); err != nil {
+		return err
+	}
+
+	_, err := r.db.Exec(`DELETE FROM webhooks WHERE repository_id = ?`, repositoryID)
+	return err
+}
+
+func (r *webhooksRepository) findAll(query string, args ...any) ([]*models.Webhook, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*models.Webhook
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func scanWebhook(row rowScanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	var events string
+	err := row.Scan(
+		&webhook.ID,
+		&webhook.RepositoryID,
+		&webhook.OrganizationID,
+		&webhook.URL,
+		&webhook.Secret,
+		&webhook.ContentType,
+		&events,
+		&webhook.Active,
+		&webhook.CreatedAt,
+		&webhook.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.Events = strings.Fields(events)
+	return webhook, nil
+}
@@ -0,0 +1,304 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+)
+
+// WorkflowRunsRepository stores workflow runs and their jobs
+type WorkflowRunsRepository interface {
+	// CreateRun stores a queued run with the next number of its repository
+	CreateRun(run *models.WorkflowRun) (*models.WorkflowRun, error)
+	FindRunByID(id int64) (*models.WorkflowRun, error)
+	FindRunByNumber(repositoryID, number int64) (*models.WorkflowRun, error)
+	// FindRunsByRepository returns the latest runs of the repository, newest first
+	FindRunsByRepository(repositoryID int64, limit int) ([]*models.WorkflowRun, error)
+	// UpdateRunStatus moves a run to status, setting started_at when it starts and finished_at
+	// when it completes
+	UpdateRunStatus(id int64, status string, conclusion *string) error
+
+	CreateJob(job *models.WorkflowJob) (*models.WorkflowJob, error)
+	FindJobByID(id int64) (*models.WorkflowJob, error)
+	FindJobsByRun(runID int64) ([]*models.WorkflowJob, error)
+	// ClaimJob hands the oldest queued job to the runner, nil if there is none
+	ClaimJob(runnerName string) (*models.WorkflowJob, error)
+	// AppendLog adds output to the log of a running job and records that its runner is alive.
+	// It reports false if the job isn't running anymore.
+	AppendLog(id int64, chunk []byte) (bool, error)
+	// ReadLog returns the log of the job from the byte offset on
+	ReadLog(id, offset int64) ([]byte, error)
+	// FinishJob completes a job that hasn't completed yet with the conclusion. It reports false if
+	// the job had already completed.
+	FinishJob(id int64, conclusion string) (bool, error)
+	// CancelJobs completes the jobs of the run that haven't completed as cancelled
+	CancelJobs(runID int64) error
+	// FindStaleJobs returns running jobs whose runner last checked in before the timestamp
+	FindStaleJobs(before int64) ([]*models.WorkflowJob, error)
+}
+
+type workflowRunsRepository struct {
+	db *sql.DB
+}
+
+func NewWorkflowRunsRepository(db *sql.DB) WorkflowRunsRepository {
+	return &workflowRunsRepository{db: db}
+}
+
+const workflowRunColumns = `id, repository_id, number, workflow, name, event, ref, sha, status, conclusion, triggered_by_id,
+		started_at, finished_at, created_at`
+
+// workflowJobColumns leave out the log, which can be large, and give its size instead
+const workflowJobColumns = `id, run_id, repository_id, name, definition, status, conclusion, runner_name, length(log),
+		heartbeat_at, started_at, finished_at, created_at`
+
+func (r *workflowRunsRepository) CreateRun(run *models.WorkflowRun) (*models.WorkflowRun, error) {
+	query := `
+		INSERT INTO workflow_runs (repository_id, number, workflow, name, event, ref, sha, triggered_by_id)
+		VALUES (?, (SELECT COALESCE(MAX(number), 0) + 1 FROM workflow_runs WHERE repository_id = ?), ?, ?, ?, ?, ?, ?)
+		RETURNING ` + workflowRunColumns
+
+	return scanWorkflowRun(r.db.QueryRow(query,
+		run.RepositoryID,
+		run.RepositoryID,
+		run.Workflow,
+		run.Name,
+		run.Event,
+		run.Ref,
+		run.SHA,
+		run.TriggeredByID,
+	))
+}
+
+func (r *workflowRunsRepository) FindRunByID(id int64) (*models.WorkflowRun, error) {
+	query := `SELECT ` + workflowRunColumns + ` FROM workflow_runs WHERE id = ?`
+	return r.findRun(query, id)
+}
+
+func (r *workflowRunsRepository) FindRunByNumber(repositoryID, number int64) (*models.WorkflowRun, error) {
+	query := `SELECT ` + workflowRunColumns + ` FROM workflow_runs WHERE repository_id = ? AND number = ?`
+	return r.findRun(query, repositoryID, number)
+}
+
+func (r *workflowRunsRepository) findRun(query string, args ...any) (*models.WorkflowRun, error) {
+	run, err := scanWorkflowRun(r.db.QueryRow(query, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return run, nil
+}
+
+func (r *workflowRunsRepository) FindRunsByRepository(repositoryID int64, limit int) ([]*models.WorkflowRun, error) {
+	query := `
+		SELECT ` + workflowRunColumns + `
+		FROM workflow_runs
+		WHERE repository_id = ?
+		ORDER BY number DESC
+		LIMIT ?
+	`
+
+	rows, err := r.db.Query(query, repositoryID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []*models.WorkflowRun
+	for rows.Next() {
+		run, err := scanWorkflowRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+func (r *workflowRunsRepository) UpdateRunStatus(id int64, status string, conclusion *string) error {
+	query := `
+		UPDATE workflow_runs
+		SET status = ?,
+			conclusion = ?,
+			started_at = CASE WHEN ? != 'queued' THEN COALESCE(started_at, unixepoch()) ELSE started_at END,
+			finished_at = CASE WHEN ? = 'completed' THEN COALESCE(finished_at, unixepoch()) ELSE NULL END
+		WHERE id = ?
+	`
+
+	_, err := r.db.Exec(query, status, conclusion, status, status, id)
+	return err
+}
+
+func (r *workflowRunsRepository) CreateJob(job *models.WorkflowJob) (*models.WorkflowJob, error) {
+	query := `
+		INSERT INTO workflow_jobs (run_id, repository_id, name, definition)
+		VALUES (?, ?, ?, ?)
+		RETURNING ` + workflowJobColumns
+
+	return scanWorkflowJob(r.db.QueryRow(query, job.RunID, job.RepositoryID, job.Name, job.Definition))
+}
+
+func (r *workflowRunsRepository) FindJobByID(id int64) (*models.WorkflowJob, error) {
+	query := `SELECT ` + workflowJobColumns + ` FROM workflow_jobs WHERE id = ?`
+
+	job, err := scanWorkflowJob(r.db.QueryRow(query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *workflowRunsRepository) FindJobsByRun(runID int64) ([]*models.WorkflowJob, error) {
+	query := `SELECT ` + workflowJobColumns + ` FROM workflow_jobs WHERE run_id = ? ORDER BY id ASC`
+	return r.findJobs(query, runID)
+}
+
+func (r *workflowRunsRepository) ClaimJob(runnerName string) (*models.WorkflowJob, error) {
+	query := `
+		UPDATE workflow_jobs
+		SET status = 'running',
+			runner_name = ?,
+			started_at = unixepoch(),
+			heartbeat_at = unixepoch()
+		WHERE id = (SELECT id FROM workflow_jobs WHERE status = 'queued' ORDER BY id ASC LIMIT 1)
+		RETURNING ` + workflowJobColumns
+
+	job, err := scanWorkflowJob(r.db.QueryRow(query, runnerName))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+func (r *workflowRunsRepository) AppendLog(id int64, chunk []byte) (bool, error) {
+	query := `
+		UPDATE workflow_jobs
+		SET log = CAST(log || ? AS BLOB), heartbeat_at = unixepoch()
+		WHERE id = ? AND status = 'running'
+	`
+
+	result, err := r.db.Exec(query, chunk, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (r *workflowRunsRepository) ReadLog(id, offset int64) ([]byte, error) {
+	var log []byte
+	err := r.db.QueryRow(`SELECT substr(log, ? + 1) FROM workflow_jobs WHERE id = ?`, offset, id).Scan(&log)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return log, err
+}
+
+func (r *workflowRunsRepository) FinishJob(id int64, conclusion string) (bool, error) {
+	query := `
+		UPDATE workflow_jobs
+		SET status = 'completed', conclusion = ?, finished_at = unixepoch()
+		WHERE id = ? AND status != 'completed'
+	`
+
+	result, err := r.db.Exec(query, conclusion, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (r *workflowRunsRepository) CancelJobs(runID int64) error {
+	query := `
+		UPDATE workflow_jobs
+		SET status = 'completed', conclusion = 'cancelled', finished_at = unixepoch()
+		WHERE run_id = ? AND status != 'completed'
+	`
+
+	_, err := r.db.Exec(query, runID)
+	return err
+}
+
+func (r *workflowRunsRepository) FindStaleJobs(before int64) ([]*models.WorkflowJob, error) {
+	query := `SELECT ` + workflowJobColumns + ` FROM workflow_jobs WHERE status = 'running' AND heartbeat_at < ?`
+	return r.findJobs(query, before)
+}
+
+func (r *workflowRunsRepository) findJobs(query string, args ...any) ([]*models.WorkflowJob, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*models.WorkflowJob
+	for rows.Next() {
+		job, err := scanWorkflowJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+func scanWorkflowRun(row rowScanner) (*models.WorkflowRun, error) {
+	run := &models.WorkflowRun{}
+	err := row.Scan(
+		&run.ID,
+		&run.RepositoryID,
+		&run.Number,
+		&run.Workflow,
+		&run.Name,
+		&run.Event,
+		&run.Ref,
+		&run.SHA,
+		&run.Status,
+		&run.Conclusion,
+		&run.TriggeredByID,
+		&run.StartedAt,
+		&run.FinishedAt,
+		&run.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
+
+func scanWorkflowJob(row rowScanner) (*models.WorkflowJob, error) {
+	job := &models.WorkflowJob{}
+	err := row.Scan(
+		&job.ID,
+		&job.RunID,
+		&job.RepositoryID,
+		&job.Name,
+		&job.Definition,
+		&job.Status,
+		&job.Conclusion,
+		&job.RunnerName,
+		&job.LogSize,
+		&job.HeartbeatAt,
+		&job.StartedAt,
+		&job.FinishedAt,
+		&job.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
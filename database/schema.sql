@@ -4,29 +4,201 @@ CREATE TABLE IF NOT EXISTS users (
     email TEXT NOT NULL UNIQUE,
     display_name TEXT NOT NULL,
     password TEXT,
-    github_user_id TEXT UNIQUE,
+    is_admin INTEGER NOT NULL DEFAULT 0,
+    suspended_at INTEGER,
     created_at INTEGER NOT NULL DEFAULT (unixepoch()),
     updated_at INTEGER NOT NULL DEFAULT (unixepoch())
 );
 
+-- Accounts at identity providers (GitHub or OpenID Connect) that users sign in with.
+-- subject is the provider's stable ID of the account.
+CREATE TABLE IF NOT EXISTS user_identities (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    provider TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE (provider, subject),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Signed in browser sessions. The cookie holds a random token, only its keyed hash is stored.
+CREATE TABLE IF NOT EXISTS sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    user_agent TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    last_seen_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    expires_at INTEGER NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- TOTP secret of a user. confirmed_at is NULL while enrollment hasn't been confirmed with a code.
+CREATE TABLE IF NOT EXISTS two_factor_credentials (
+    user_id INTEGER PRIMARY KEY,
+    secret TEXT NOT NULL,
+    confirmed_at INTEGER,
+    last_used_step INTEGER NOT NULL DEFAULT 0,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- One-time recovery codes, stored as sha256 hashes
+CREATE TABLE IF NOT EXISTS two_factor_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,
+    used_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Sign-ins that passed the password check and wait for a second factor
+CREATE TABLE IF NOT EXISTS two_factor_challenges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at INTEGER NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- WebAuthn credentials (passkeys and security keys) registered by users
+CREATE TABLE IF NOT EXISTS passkeys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    credential_id TEXT NOT NULL UNIQUE,
+    public_key BLOB NOT NULL,
+    sign_count INTEGER NOT NULL DEFAULT 0,
+    last_used_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Challenges of ongoing WebAuthn ceremonies, each can be used once
+CREATE TABLE IF NOT EXISTS passkey_challenges (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    ceremony TEXT NOT NULL CHECK(ceremony IN ('registration', 'authentication')),
+    challenge_hash TEXT NOT NULL UNIQUE,
+    expires_at INTEGER NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Email addresses of a user. The primary one is mirrored in users.email.
+-- Addresses of users. Unverified addresses don't belong to anyone yet, so several users can add
+-- the same one, and the first to verify it keeps it.
+CREATE TABLE IF NOT EXISTS user_emails (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    email TEXT NOT NULL COLLATE NOCASE,
+    is_primary INTEGER NOT NULL DEFAULT 0,
+    verified_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Email verification tokens, only the SHA-256 hash of a token is stored
+CREATE TABLE IF NOT EXISTS email_verification_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_email_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at INTEGER NOT NULL,
+    used_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_email_id) REFERENCES user_emails(id) ON DELETE CASCADE
+);
+
+-- OAuth applications registered by a user or an organization. Only the SHA-256 hash
+-- of the client secret is stored. redirect_uris holds one URI per line.
+CREATE TABLE IF NOT EXISTS oauth_applications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_user_id INTEGER,
+    owner_org_id INTEGER,
+    name TEXT NOT NULL,
+    homepage_url TEXT NOT NULL DEFAULT '',
+    redirect_uris TEXT NOT NULL DEFAULT '',
+    client_id TEXT NOT NULL UNIQUE,
+    client_secret_hash TEXT NOT NULL,
+    confidential INTEGER NOT NULL DEFAULT 1,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (owner_org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    CHECK ((owner_user_id IS NOT NULL AND owner_org_id IS NULL) OR (owner_user_id IS NULL AND owner_org_id IS NOT NULL))
+);
+
+-- Access tokens are personal access tokens, or were issued to the OAuth application in application_id
 CREATE TABLE IF NOT EXISTS access_tokens (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     user_id INTEGER NOT NULL,
     name TEXT NOT NULL,
     token_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL DEFAULT '',
+    expires_at INTEGER,
+    application_id INTEGER,
     last_used_at INTEGER,
     created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (application_id) REFERENCES oauth_applications(id) ON DELETE CASCADE
+);
+
+-- Repositories an access token is restricted to. A token without rows here can access all of them.
+CREATE TABLE IF NOT EXISTS access_token_repositories (
+    access_token_id INTEGER NOT NULL,
+    repository_id INTEGER NOT NULL,
+    PRIMARY KEY (access_token_id, repository_id),
+    FOREIGN KEY (access_token_id) REFERENCES access_tokens(id) ON DELETE CASCADE,
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE
+);
+
+-- OAuth authorization codes, exchanged once for tokens. code_challenge is the PKCE S256 challenge.
+CREATE TABLE IF NOT EXISTS oauth_authorization_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code_hash TEXT NOT NULL UNIQUE,
+    application_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    code_challenge TEXT NOT NULL,
+    expires_at INTEGER NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (application_id) REFERENCES oauth_applications(id) ON DELETE CASCADE,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
-CREATE TABLE IF NOT EXISTS device_auth_sessions (
-    id TEXT PRIMARY KEY,
-    code TEXT NOT NULL UNIQUE,
-    user_id INTEGER,
-    access_token TEXT,
-    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'confirmed', 'expired')),
+-- OAuth refresh tokens, each one is replaced by a new one when it is used
+CREATE TABLE IF NOT EXISTS oauth_refresh_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token_hash TEXT NOT NULL UNIQUE,
+    application_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    scopes TEXT NOT NULL,
+    expires_at INTEGER NOT NULL,
     created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (application_id) REFERENCES oauth_applications(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- OAuth device authorization grants (RFC 8628). application_id is NULL for the Hypercommit CLI.
+-- The device code is a secret of the device, so only its hash is stored.
+CREATE TABLE IF NOT EXISTS oauth_device_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    device_code_hash TEXT NOT NULL UNIQUE,
+    user_code TEXT NOT NULL UNIQUE,
+    application_id INTEGER,
+    scopes TEXT NOT NULL,
+    user_id INTEGER,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'approved', 'denied')),
+    last_polled_at INTEGER,
     expires_at INTEGER NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (application_id) REFERENCES oauth_applications(id) ON DELETE CASCADE,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 );
 
@@ -34,10 +206,22 @@ CREATE TABLE IF NOT EXISTS organizations (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     username TEXT NOT NULL UNIQUE,
     display_name TEXT NOT NULL,
+    require_two_factor INTEGER NOT NULL DEFAULT 0,
     created_at INTEGER NOT NULL DEFAULT (unixepoch()),
     updated_at INTEGER NOT NULL DEFAULT (unixepoch())
 );
 
+CREATE TABLE IF NOT EXISTS organization_members (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    organization_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK(role IN ('owner', 'member')),
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(organization_id, user_id)
+);
+
 CREATE TABLE IF NOT EXISTS repositories (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT NOT NULL,
@@ -85,14 +269,31 @@ CREATE TABLE IF NOT EXISTS tickets (
     author_id INTEGER NOT NULL,
     closed_at INTEGER,
     closed_by_id INTEGER,
+    milestone_id INTEGER,
     created_at INTEGER NOT NULL DEFAULT (unixepoch()),
     updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
     FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
     FOREIGN KEY (author_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (closed_by_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (milestone_id) REFERENCES milestones(id) ON DELETE SET NULL,
     UNIQUE(repository_id, number)
 );
 
+-- Milestones (defined per repository, used to group tickets for a release)
+CREATE TABLE IF NOT EXISTS milestones (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    description TEXT,
+    due_date INTEGER,
+    status TEXT NOT NULL DEFAULT 'open' CHECK(status IN ('open', 'closed')),
+    closed_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
+    UNIQUE(repository_id, title)
+);
+
 -- Ticket comments
 CREATE TABLE IF NOT EXISTS ticket_comments (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -157,17 +358,369 @@ CREATE TABLE IF NOT EXISTS ticket_reactions (
     UNIQUE(comment_id, user_id, emoji)
 );
 
+-- Ticket subscriptions, a row with subscribed = 0 records an explicit unsubscribe
+-- so that later activity does not silently subscribe the user again
+CREATE TABLE IF NOT EXISTS ticket_subscriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ticket_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    reason TEXT NOT NULL CHECK(reason IN ('author', 'comment', 'assigned', 'mention', 'manual')),
+    subscribed INTEGER NOT NULL DEFAULT 1,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    UNIQUE(ticket_id, user_id)
+);
+
+-- In-app notifications
+CREATE TABLE IF NOT EXISTS notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    ticket_id INTEGER NOT NULL,
+    actor_id INTEGER NOT NULL,
+    comment_id INTEGER,
+    reason TEXT NOT NULL CHECK(reason IN ('comment', 'mention', 'assigned', 'closed', 'reopened')),
+    read_at INTEGER,
+    done_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (ticket_id) REFERENCES tickets(id) ON DELETE CASCADE,
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES ticket_comments(id) ON DELETE CASCADE
+);
+
+-- Password reset tokens, only the SHA-256 hash of a token is stored
+CREATE TABLE IF NOT EXISTS password_reset_tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    expires_at INTEGER NOT NULL,
+    used_at INTEGER,
+    requested_ip TEXT,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Every password reset request, registered email or not, used for rate limiting
+CREATE TABLE IF NOT EXISTS password_reset_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+-- Accounts locked out after repeated failed sign-in attempts, kept as an audit trail
+CREATE TABLE IF NOT EXISTS auth_lockouts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    account TEXT NOT NULL,
+    ip_address TEXT NOT NULL,
+    failures INTEGER NOT NULL,
+    locked_until INTEGER NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- Security-relevant actions, who did them and to what. Names are copied so that
+-- events stay readable after the actor or target is deleted.
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    actor_id INTEGER,
+    actor_name TEXT NOT NULL DEFAULT '',
+    action TEXT NOT NULL,
+    target_type TEXT NOT NULL,
+    target_id INTEGER,
+    target_name TEXT NOT NULL DEFAULT '',
+    org_id INTEGER,
+    ip_address TEXT NOT NULL DEFAULT '',
+    metadata TEXT NOT NULL DEFAULT '{}',
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (actor_id) REFERENCES users(id) ON DELETE SET NULL,
+    FOREIGN KEY (org_id) REFERENCES organizations(id) ON DELETE CASCADE
+);
+
+-- Instance-wide settings changed by administrators, as key/value pairs
+CREATE TABLE IF NOT EXISTS instance_settings (
+    key TEXT PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+-- Outgoing email queue, delivered in the background with retries
+CREATE TABLE IF NOT EXISTS email_outbox (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    to_address TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    text_body TEXT NOT NULL,
+    html_body TEXT,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'sent', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    next_attempt_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    sent_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch())
+);
+
+-- Webhooks of a repository, or of an organization for all of its repositories. Events are space separated.
+CREATE TABLE IF NOT EXISTS webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER,
+    organization_id INTEGER,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL DEFAULT '',
+    content_type TEXT NOT NULL DEFAULT 'json' CHECK(content_type IN ('json', 'form')),
+    events TEXT NOT NULL DEFAULT '',
+    active INTEGER NOT NULL DEFAULT 1,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    CHECK ((repository_id IS NOT NULL AND organization_id IS NULL) OR (repository_id IS NULL AND organization_id IS NOT NULL))
+);
+
+-- Events queued for webhooks, delivered in the background with retries. The request and response of
+-- the latest attempt are kept for the delivery log.
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL,
+    guid TEXT NOT NULL,
+    event TEXT NOT NULL,
+    action TEXT,
+    payload TEXT NOT NULL,
+    redelivery INTEGER NOT NULL DEFAULT 0,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending', 'delivered', 'failed')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    request_headers TEXT,
+    response_status INTEGER,
+    response_headers TEXT,
+    response_body TEXT,
+    last_error TEXT,
+    duration_ms INTEGER,
+    delivered_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (webhook_id) REFERENCES webhooks(id) ON DELETE CASCADE
+);
+
+-- Results external systems such as CI report for commits, one per check (context) of a commit
+CREATE TABLE IF NOT EXISTS commit_statuses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER NOT NULL,
+    sha TEXT NOT NULL,
+    context TEXT NOT NULL,
+    state TEXT NOT NULL CHECK(state IN ('pending', 'success', 'failure', 'error')),
+    target_url TEXT,
+    description TEXT,
+    creator_id INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
+    FOREIGN KEY (creator_id) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE(repository_id, sha, context)
+);
+
+-- Executions of the workflows of .hypercommit/workflows, numbered per repository
+CREATE TABLE IF NOT EXISTS workflow_runs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER NOT NULL,
+    number INTEGER NOT NULL,
+    workflow TEXT NOT NULL,
+    name TEXT NOT NULL,
+    event TEXT NOT NULL CHECK(event IN ('push', 'tag', 'ticket')),
+    ref TEXT NOT NULL,
+    sha TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK(status IN ('queued', 'running', 'completed')),
+    conclusion TEXT CHECK(conclusion IN ('success', 'failure', 'cancelled')),
+    triggered_by_id INTEGER,
+    started_at INTEGER,
+    finished_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
+    FOREIGN KEY (triggered_by_id) REFERENCES users(id) ON DELETE SET NULL,
+    UNIQUE(repository_id, number)
+);
+
+-- Jobs of workflow runs, claimed and executed by runners. The log grows while the job runs.
+CREATE TABLE IF NOT EXISTS workflow_jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    run_id INTEGER NOT NULL,
+    repository_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    definition TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued' CHECK(status IN ('queued', 'running', 'completed')),
+    conclusion TEXT CHECK(conclusion IN ('success', 'failure', 'cancelled')),
+    runner_name TEXT,
+    log BLOB NOT NULL DEFAULT X'',
+    -- Runners check in while they execute the job, jobs whose runner stopped doing so are failed
+    heartbeat_at INTEGER,
+    started_at INTEGER,
+    finished_at INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    FOREIGN KEY (run_id) REFERENCES workflow_runs(id) ON DELETE CASCADE
+);
+
+-- Secrets of a repository, or of an organization for all of its repositories, that workflow jobs see as
+-- environment variables. value is encrypted with AES-GCM, the nonce followed by the ciphertext.
+CREATE TABLE IF NOT EXISTS secrets (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER,
+    organization_id INTEGER,
+    name TEXT NOT NULL,
+    value BLOB NOT NULL,
+    creator_id INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(repository_id, name),
+    UNIQUE(organization_id, name),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
+    FOREIGN KEY (organization_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (creator_id) REFERENCES users(id) ON DELETE SET NULL,
+    CHECK ((repository_id IS NOT NULL AND organization_id IS NULL) OR (repository_id IS NULL AND organization_id IS NOT NULL))
+);
+
+-- Tags of repositories served as Go module versions. Versions are immutable once served, the zip is
+-- built on first download and kept on disk, zip_hash is its go.sum checksum.
+CREATE TABLE IF NOT EXISTS go_module_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER NOT NULL,
+    version TEXT NOT NULL,
+    commit_sha TEXT NOT NULL,
+    go_mod TEXT NOT NULL,
+    commit_time INTEGER NOT NULL,
+    zip_hash TEXT,
+    zip_size INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(repository_id, version),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE
+);
+
+-- Container images of repositories, served with the OCI distribution protocol. Blob files are stored
+-- once on disk by digest, registry_blobs links them to the repositories they were pushed to.
+-- image is the path of the image below the repository, empty for the image named after it.
+CREATE TABLE IF NOT EXISTS registry_blobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER NOT NULL,
+    digest TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(repository_id, digest),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS registry_manifests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER NOT NULL,
+    image TEXT NOT NULL,
+    digest TEXT NOT NULL,
+    media_type TEXT NOT NULL,
+    content BLOB NOT NULL,
+    subject_digest TEXT,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(repository_id, image, digest),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS registry_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    repository_id INTEGER NOT NULL,
+    image TEXT NOT NULL,
+    name TEXT NOT NULL,
+    manifest_id INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(repository_id, image, name),
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE CASCADE,
+    FOREIGN KEY (manifest_id) REFERENCES registry_manifests(id) ON DELETE CASCADE
+);
+
+-- Packages of users and organizations, published with the npm registry API or the raw file API.
+-- Names are unique per owner and type. A package linked to a repository has its visibility and
+-- permissions, unlinked packages are private to their owner.
+CREATE TABLE IF NOT EXISTS packages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL CHECK(type IN ('npm', 'raw')),
+    name TEXT NOT NULL,
+    owner_user_id INTEGER,
+    owner_org_id INTEGER,
+    repository_id INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(owner_user_id, type, name),
+    UNIQUE(owner_org_id, type, name),
+    FOREIGN KEY (owner_user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (owner_org_id) REFERENCES organizations(id) ON DELETE CASCADE,
+    FOREIGN KEY (repository_id) REFERENCES repositories(id) ON DELETE SET NULL,
+    CHECK ((owner_user_id IS NOT NULL AND owner_org_id IS NULL) OR (owner_user_id IS NULL AND owner_org_id IS NOT NULL))
+);
+
+-- metadata is the package.json of npm versions as published, empty for raw versions
+CREATE TABLE IF NOT EXISTS package_versions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    package_id INTEGER NOT NULL,
+    version TEXT NOT NULL,
+    metadata TEXT NOT NULL DEFAULT '',
+    publisher_id INTEGER,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(package_id, version),
+    FOREIGN KEY (package_id) REFERENCES packages(id) ON DELETE CASCADE,
+    FOREIGN KEY (publisher_id) REFERENCES users(id) ON DELETE SET NULL
+);
+
+-- Files of package versions, stored on disk under the package and file IDs
+CREATE TABLE IF NOT EXISTS package_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    version_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    sha1 TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    sha512 TEXT NOT NULL,
+    created_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(version_id, name),
+    FOREIGN KEY (version_id) REFERENCES package_versions(id) ON DELETE CASCADE
+);
+
+-- npm dist-tags, such as latest, pointing to versions of a package
+CREATE TABLE IF NOT EXISTS package_dist_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    package_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    version_id INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL DEFAULT (unixepoch()),
+    UNIQUE(package_id, name),
+    FOREIGN KEY (package_id) REFERENCES packages(id) ON DELETE CASCADE,
+    FOREIGN KEY (version_id) REFERENCES package_versions(id) ON DELETE CASCADE
+);
+
 CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);
 CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
 
+CREATE INDEX IF NOT EXISTS idx_user_identities_user ON user_identities(user_id);
+
+CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_id);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires ON sessions(expires_at);
+
+CREATE INDEX IF NOT EXISTS idx_two_factor_recovery_codes_user ON two_factor_recovery_codes(user_id);
+CREATE INDEX IF NOT EXISTS idx_two_factor_challenges_expires ON two_factor_challenges(expires_at);
+CREATE INDEX IF NOT EXISTS idx_passkeys_user ON passkeys(user_id);
+CREATE INDEX IF NOT EXISTS idx_passkey_challenges_expires ON passkey_challenges(expires_at);
+
+CREATE INDEX IF NOT EXISTS idx_user_emails_user ON user_emails(user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_emails_user_email ON user_emails(user_id, email);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_emails_verified ON user_emails(email) WHERE verified_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_email_verification_tokens_email ON email_verification_tokens(user_email_id);
+
 CREATE INDEX IF NOT EXISTS idx_access_tokens_user ON access_tokens(user_id);
 CREATE INDEX IF NOT EXISTS idx_access_tokens_token_hash ON access_tokens(token_hash);
 
-CREATE INDEX IF NOT EXISTS idx_device_auth_sessions_code ON device_auth_sessions(code);
-CREATE INDEX IF NOT EXISTS idx_device_auth_sessions_status ON device_auth_sessions(status);
-CREATE INDEX IF NOT EXISTS idx_device_auth_sessions_expires_at ON device_auth_sessions(expires_at);
+CREATE INDEX IF NOT EXISTS idx_oauth_applications_owner_user ON oauth_applications(owner_user_id);
+CREATE INDEX IF NOT EXISTS idx_oauth_applications_owner_org ON oauth_applications(owner_org_id);
+CREATE INDEX IF NOT EXISTS idx_oauth_authorization_codes_expires_at ON oauth_authorization_codes(expires_at);
+CREATE INDEX IF NOT EXISTS idx_oauth_refresh_tokens_application_user ON oauth_refresh_tokens(application_id, user_id);
+CREATE INDEX IF NOT EXISTS idx_oauth_device_codes_expires_at ON oauth_device_codes(expires_at);
 
 CREATE INDEX IF NOT EXISTS idx_organizations_username ON organizations(username);
+CREATE INDEX IF NOT EXISTS idx_organization_members_user ON organization_members(user_id);
 
 CREATE INDEX IF NOT EXISTS idx_repositories_owner_user ON repositories(owner_user_id);
 CREATE INDEX IF NOT EXISTS idx_repositories_owner_org ON repositories(owner_org_id);
@@ -192,6 +745,9 @@ CREATE INDEX IF NOT EXISTS idx_tickets_closed_by ON tickets(closed_by_id);
 CREATE INDEX IF NOT EXISTS idx_tickets_created_at ON tickets(created_at);
 CREATE INDEX IF NOT EXISTS idx_tickets_updated_at ON tickets(updated_at);
 
+CREATE INDEX IF NOT EXISTS idx_milestones_repository ON milestones(repository_id);
+CREATE INDEX IF NOT EXISTS idx_milestones_status ON milestones(status);
+
 CREATE INDEX IF NOT EXISTS idx_ticket_comments_ticket ON ticket_comments(ticket_id);
 CREATE INDEX IF NOT EXISTS idx_ticket_comments_author ON ticket_comments(author_id);
 CREATE INDEX IF NOT EXISTS idx_ticket_comments_created_at ON ticket_comments(created_at);
@@ -209,6 +765,36 @@ CREATE INDEX IF NOT EXISTS idx_ticket_reactions_comment ON ticket_reactions(comm
 CREATE INDEX IF NOT EXISTS idx_ticket_reactions_user ON ticket_reactions(user_id);
 CREATE INDEX IF NOT EXISTS idx_ticket_reactions_emoji ON ticket_reactions(emoji);
 
+CREATE INDEX IF NOT EXISTS idx_ticket_subscriptions_ticket ON ticket_subscriptions(ticket_id);
+CREATE INDEX IF NOT EXISTS idx_ticket_subscriptions_user ON ticket_subscriptions(user_id);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_user ON notifications(user_id);
+CREATE INDEX IF NOT EXISTS idx_notifications_user_read ON notifications(user_id, read_at);
+CREATE INDEX IF NOT EXISTS idx_notifications_ticket ON notifications(ticket_id);
+
+CREATE INDEX IF NOT EXISTS idx_password_reset_tokens_user ON password_reset_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_password_reset_requests_email ON password_reset_requests(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_password_reset_requests_ip ON password_reset_requests(ip_address, created_at);
+CREATE INDEX IF NOT EXISTS idx_auth_lockouts_user ON auth_lockouts(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor ON audit_events(actor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_events_org ON audit_events(org_id, created_at);
+
+CREATE INDEX IF NOT EXISTS idx_email_outbox_due ON email_outbox(status, next_attempt_at);
+
+CREATE INDEX IF NOT EXISTS idx_webhooks_repository ON webhooks(repository_id);
+CREATE INDEX IF NOT EXISTS idx_webhooks_organization ON webhooks(organization_id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook_id, id);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+
+CREATE INDEX IF NOT EXISTS idx_workflow_jobs_run ON workflow_jobs(run_id);
+CREATE INDEX IF NOT EXISTS idx_workflow_jobs_status ON workflow_jobs(status, id);
+
+CREATE INDEX IF NOT EXISTS idx_registry_manifests_subject ON registry_manifests(repository_id, image, subject_digest);
+CREATE INDEX IF NOT EXISTS idx_registry_tags_manifest ON registry_tags(manifest_id);
+CREATE INDEX IF NOT EXISTS idx_packages_repository ON packages(repository_id);
+CREATE INDEX IF NOT EXISTS idx_package_dist_tags_version ON package_dist_tags(version_id);
+
 CREATE TRIGGER IF NOT EXISTS update_users_timestamp
 AFTER UPDATE ON users
 BEGIN
@@ -233,6 +819,18 @@ BEGIN
     UPDATE tickets SET updated_at = unixepoch() WHERE id = NEW.id;
 END;
 
+CREATE TRIGGER IF NOT EXISTS update_milestones_timestamp
+AFTER UPDATE ON milestones
+BEGIN
+    UPDATE milestones SET updated_at = unixepoch() WHERE id = NEW.id;
+END;
+
+CREATE TRIGGER IF NOT EXISTS update_ticket_subscriptions_timestamp
+AFTER UPDATE ON ticket_subscriptions
+BEGIN
+    UPDATE ticket_subscriptions SET updated_at = unixepoch() WHERE id = NEW.id;
+END;
+
 CREATE TRIGGER IF NOT EXISTS update_ticket_comments_timestamp
 AFTER UPDATE ON ticket_comments
 BEGIN
@@ -3,6 +3,8 @@ package env
 import (
 	"log/slog"
 	"os"
+	"strconv"
+	"time"
 )
 
 func GetVar(key, fallback string) string {
@@ -12,3 +14,35 @@ func GetVar(key, fallback string) string {
 	slog.Warn("env var undefined, using fallback", "key", key, "fallback", fallback)
 	return fallback
 }
+
+// GetInt returns the integer value of key, or fallback if it is undefined or invalid
+func GetInt(key string, fallback int) int {
+	value, found := os.LookupEnv(key)
+	if !found {
+		slog.Warn("env var undefined, using fallback", "key", key, "fallback", fallback)
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		slog.Warn("env var is not an integer, using fallback", "key", key, "value", value, "fallback", fallback)
+		return fallback
+	}
+	return n
+}
+
+// GetDuration returns the duration value of key, such as "90s" or "1h", or fallback if it is undefined or invalid
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	value, found := os.LookupEnv(key)
+	if !found {
+		slog.Warn("env var undefined, using fallback", "key", key, "fallback", fallback)
+		return fallback
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		slog.Warn("env var is not a duration, using fallback", "key", key, "value", value, "fallback", fallback)
+		return fallback
+	}
+	return d
+}
@@ -1,6 +1,11 @@
 package httperror
 
-import "fmt"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+)
 
 type HTTPError struct {
 	StatusCode int
@@ -30,3 +35,23 @@ func Unauthorized(message string) error {
 func Forbidden(message string) error {
 	return HTTPError{StatusCode: 403, Message: message}
 }
+
+// WriteJSON responds with err as {"error": {"status": ..., "message": ...}}. Errors that
+// aren't an HTTPError become an internal server error, their message isn't shown.
+func WriteJSON(w http.ResponseWriter, err error) {
+	var httpErr HTTPError
+	if !errors.As(err, &httpErr) {
+		httpErr = HTTPError{StatusCode: http.StatusInternalServerError, Message: "internal server error"}
+	}
+
+	body := map[string]any{
+		"error": map[string]any{
+			"status":  httpErr.StatusCode,
+			"message": httpErr.Message,
+		},
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(httpErr.StatusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
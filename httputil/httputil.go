@@ -1,6 +1,12 @@
 package httputil
 
-import "net/http"
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
 
 // IsHTTPS checks if the request is over HTTPS, either directly or via a proxy
 func IsHTTPS(r *http.Request) bool {
@@ -13,3 +19,19 @@ func IsHTTPS(r *http.Request) bool {
 	proto := r.Header.Get("X-Forwarded-Proto")
 	return proto == "https"
 }
+
+// ClientIP returns the IP address of the client without the port.
+// The RealIP middleware has already replaced RemoteAddr with the address forwarded by a trusted proxy.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// SetRetryAfter tells the client how many seconds to wait before retrying, rounded up
+func SetRetryAfter(w http.ResponseWriter, wait time.Duration) {
+	seconds := int64(math.Ceil(wait.Seconds()))
+	w.Header().Set("Retry-After", strconv.FormatInt(max(seconds, 1), 10))
+}
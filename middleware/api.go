@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httperror"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+const ContextKeyAccessToken contextKey = "accessToken"
+
+// APIAuth authenticates API requests with the access token of an "Authorization: Bearer" header.
+// Session cookies are ignored, as the API isn't protected against CSRF. Requests without a token
+// are anonymous and only see public data. Tokens need the api scope.
+func APIAuth(accessTokens services.AccessTokenService, users repositories.UsersRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ContextKeyUser, (*models.User)(nil))
+
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			scheme, rawToken, _ := strings.Cut(header, " ")
+			if !strings.EqualFold(scheme, "Bearer") {
+				writeAPIUnauthorized(w, "authorization must be a bearer access token")
+				return
+			}
+
+			token, err := accessTokens.Authenticate(strings.TrimSpace(rawToken))
+			if err != nil {
+				httperror.WriteJSON(w, err)
+				return
+			}
+			if token == nil {
+				writeAPIUnauthorized(w, "invalid or expired access token")
+				return
+			}
+
+			if !accessTokens.HasScope(token, models.ScopeAPI) {
+				httperror.WriteJSON(w, httperror.Forbidden("access token is missing the "+models.ScopeAPI+" scope"))
+				return
+			}
+
+			user, err := users.FindByID(token.UserID)
+			if err != nil {
+				httperror.WriteJSON(w, err)
+				return
+			}
+			if user == nil {
+				writeAPIUnauthorized(w, "invalid or expired access token")
+				return
+			}
+			if user.SuspendedAt != nil {
+				httperror.WriteJSON(w, httperror.Forbidden("this account has been suspended"))
+				return
+			}
+
+			ctx = context.WithValue(ctx, ContextKeyUser, user)
+			ctx = context.WithValue(ctx, ContextKeyAccessToken, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetAccessTokenFromContext returns the token an API request was authenticated with
+func GetAccessTokenFromContext(r *http.Request) *models.AccessToken {
+	token, ok := r.Context().Value(ContextKeyAccessToken).(*models.AccessToken)
+	if !ok {
+		return nil
+	}
+	return token
+}
+
+func writeAPIUnauthorized(w http.ResponseWriter, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="Hypercommit", error="invalid_token"`)
+	httperror.WriteJSON(w, httperror.Unauthorized(message))
+}
@@ -73,3 +73,16 @@ func GetFlashFromContext(r *http.Request) *services.FlashMessage {
 	}
 	return flash
 }
+
+// RequireAdmin hides the routes it guards from everyone but instance administrators.
+// It runs after Auth, which makes sure there is a signed in user.
+func RequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := GetUserFromContext(r)
+		if user == nil || !user.IsAdmin {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,184 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/httputil"
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+const (
+	ContextKeyCSRFToken contextKey = "csrfToken"
+
+	// CookieNameCSRF holds a random value that CSRF tokens are derived from before the user signs in
+	CookieNameCSRF = "hypercommit_csrf"
+	// CSRFFieldName is the form field, and CSRFHeaderName the header for scripts, that carry the token
+	CSRFFieldName  = "csrf_token"
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// csrfExemptSuffixes are endpoints that authenticate every request with credentials
+// in the Authorization header rather than cookies, so they can't be forged by another site
+var csrfExemptSuffixes = []string{
+	"/git-upload-pack",
+	"/git-receive-pack",
+}
+
+// csrfExemptPrefixes are API routes and the container registry, authenticated with tokens
+var csrfExemptPrefixes = []string{
+	"/api/",
+	"/v2/",
+}
+
+// csrfExemptPaths are the OAuth endpoints called by clients, which authenticate
+// with their client credentials or a device code and never with cookies
+var csrfExemptPaths = []string{
+	"/oauth/token",
+	"/oauth/revoke",
+	"/oauth/introspect",
+	"/oauth/device/code",
+}
+
+// CSRF rejects state-changing requests that don't carry the CSRF token of the session,
+// or that come from another origin. The token is derived from the session cookie, or from
+// an anonymous cookie for forms used before signing in, so it changes with every session.
+// It stores the token in the request context for ui.CSRFField.
+func CSRF(signingSecret, publicURL string) func(http.Handler) http.Handler {
+	trustedOrigin := ""
+	if u, err := url.Parse(publicURL); err == nil && u.Host != "" {
+		trustedOrigin = u.Scheme + "://" + u.Host
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := csrfKey(w, r)
+			token := csrfToken(signingSecret, key)
+
+			if !isSafeMethod(r.Method) && !isCSRFExempt(r.URL.Path) {
+				if !hasTrustedOrigin(r, trustedOrigin) {
+					slog.Warn("csrf origin mismatch", "path", r.URL.Path, "origin", r.Header.Get("Origin"), "referer", r.Header.Get("Referer"))
+					http.Error(w, "Cross-origin request rejected", http.StatusForbidden)
+					return
+				}
+
+				submitted := r.Header.Get(CSRFHeaderName)
+				if submitted == "" {
+					submitted = r.PostFormValue(CSRFFieldName)
+				}
+				if !hmac.Equal([]byte(submitted), []byte(token)) {
+					slog.Warn("csrf token mismatch", "path", r.URL.Path)
+					http.Error(w, "Invalid or missing CSRF token. Reload the page and try again.", http.StatusForbidden)
+					return
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), ContextKeyCSRFToken, token)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetCSRFToken returns the CSRF token to embed in forms
+func GetCSRFToken(r *http.Request) string {
+	token, ok := r.Context().Value(ContextKeyCSRFToken).(string)
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// csrfKey returns the secret value the token is derived from: the session cookie of signed in
+// users, otherwise the anonymous CSRF cookie, which is created if needed
+func csrfKey(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(services.CookieNameSession); err == nil && cookie.Value != "" {
+		return "session:" + cookie.Value
+	}
+
+	if cookie, err := r.Cookie(CookieNameCSRF); err == nil && cookie.Value != "" {
+		return "anonymous:" + cookie.Value
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	value := base64.RawURLEncoding.EncodeToString(b)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieNameCSRF,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   httputil.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return "anonymous:" + value
+}
+
+func csrfToken(signingSecret, key string) string {
+	mac := hmac.New(sha256.New, []byte(signingSecret))
+	mac.Write([]byte("csrf:" + key))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// hasTrustedOrigin checks the Origin header, or the Referer if there is none, against our own origin.
+// Requests without either are let through, the token check still applies to them.
+func hasTrustedOrigin(r *http.Request, trustedOrigin string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		referer := r.Header.Get("Referer")
+		if referer == "" {
+			return true
+		}
+		u, err := url.Parse(referer)
+		if err != nil {
+			return false
+		}
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	scheme := "http"
+	if httputil.IsHTTPS(r) {
+		scheme = "https"
+	}
+
+	return origin == scheme+"://"+r.Host || (trustedOrigin != "" && origin == trustedOrigin)
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func isCSRFExempt(path string) bool {
+	if slices.Contains(csrfExemptPaths, path) {
+		return true
+	}
+
+	for _, prefix := range csrfExemptPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+
+	for _, suffix := range csrfExemptSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+// GoImport answers the ?go-get=1 requests the go command sends for import paths under
+// /{owner}/{repo} with the go-import meta tag, so that packages of repositories import as
+// <host>/{owner}/{repo}/... It answers whether or not the repository exists, private repositories
+// aren't revealed and git asks for credentials itself.
+func GoImport(publicURL string) func(http.Handler) http.Handler {
+	prefix := services.GoImportPrefix(publicURL)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+			if r.Method != http.MethodGet || r.URL.Query().Get("go-get") != "1" || len(segments) < 2 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			root := segments[0] + "/" + strings.TrimSuffix(segments[1], ".git")
+			importPath := html.EscapeString(prefix + "/" + root)
+			repoURL := html.EscapeString(publicURL + "/" + root)
+
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprintf(w, `<!DOCTYPE html>
+<html>
+<head>
+<meta name="go-import" content="%s git %s.git">
+<meta name="go-source" content="%s %s %s/tree/HEAD{/dir} %s/tree/HEAD{/dir}/{file}">
+</head>
+<body>go get %s</body>
+</html>
+`, importPath, repoURL, importPath, repoURL, repoURL, repoURL, importPath)
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/hypercommithq/hypercommit/services"
+)
+
+const ContextKeyIdentityProviders contextKey = "identityProviders"
+
+// InjectIdentityProviders stores the configured identity providers in the request
+// context so the sign-in and sign-up pages can show a button for each of them.
+func InjectIdentityProviders(providers []services.IdentityProvider) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), ContextKeyIdentityProviders, providers)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func GetIdentityProvidersFromContext(r *http.Request) []services.IdentityProvider {
+	providers, ok := r.Context().Value(ContextKeyIdentityProviders).([]services.IdentityProvider)
+	if !ok {
+		return nil
+	}
+	return providers
+}
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const ContextKeyUnreadNotifications contextKey = "unreadNotifications"
+
+// InjectUnreadNotifications stores the signed in user's unread notification count
+// in the request context so layouts can show it in the header.
+// It must run after InjectUser.
+func InjectUnreadNotifications(notifications repositories.NotificationsRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUserFromContext(r)
+			if user != nil {
+				count, err := notifications.CountUnread(user.ID)
+				if err != nil {
+					slog.Error("failed to count unread notifications", "error", err)
+				} else {
+					ctx := context.WithValue(r.Context(), ContextKeyUnreadNotifications, count)
+					r = r.WithContext(ctx)
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func GetUnreadNotificationsFromContext(r *http.Request) int64 {
+	count, ok := r.Context().Value(ContextKeyUnreadNotifications).(int64)
+	if !ok {
+		return 0
+	}
+	return count
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// RealIP replaces RemoteAddr with the address of the client when the request comes from one of the
+// trusted proxies, taken from X-Forwarded-For or X-Real-IP. Anyone can send these headers, so they
+// are ignored from other peers. X-Forwarded-For is read from the right, skipping trusted proxies,
+// as its leftmost entries are what the client made up.
+func RealIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		for _, prefix := range trustedProxies {
+			if prefix.Contains(addr.Unmap()) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(trustedProxies) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			peer, err := netip.ParseAddr(host)
+			if err != nil || !trusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			client := peer
+			if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+				hops := strings.Split(strings.Join(forwarded, ","), ",")
+				for i := len(hops) - 1; i >= 0; i-- {
+					addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+					if err != nil {
+						break
+					}
+					client = addr
+					if !trusted(addr) {
+						break
+					}
+				}
+			} else if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+				client = addr
+			}
+
+			r.RemoteAddr = client.Unmap().String()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
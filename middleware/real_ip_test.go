@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8"), netip.MustParsePrefix("::1/128")}
+
+	for _, tc := range []struct {
+		name       string
+		trusted    []netip.Prefix
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{
+			name:       "no trusted proxies",
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "203.0.113.7:1234",
+		},
+		{
+			name:       "untrusted peer",
+			trusted:    trusted,
+			remoteAddr: "203.0.113.7:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1", "X-Real-IP": "198.51.100.2"},
+			want:       "203.0.113.7:1234",
+		},
+		{
+			name:       "trusted proxy",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "client prepends a made up address",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Forwarded-For": "192.0.2.99, 198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "chain of trusted proxies",
+			trusted:    trusted,
+			remoteAddr: "[::1]:1234",
+			headers:    map[string]string{"X-Forwarded-For": "198.51.100.1, 10.1.2.3"},
+			want:       "198.51.100.1",
+		},
+		{
+			name:       "X-Real-IP of a trusted proxy",
+			trusted:    trusted,
+			remoteAddr: "10.0.0.2:1234",
+			headers:    map[string]string{"X-Real-IP": "198.51.100.1"},
+			want:       "198.51.100.1",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var got string
+			handler := RealIP(tc.trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = tc.remoteAddr
+			for key, value := range tc.headers {
+				r.Header.Set(key, value)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tc.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+document.addEventListener("DOMContentLoaded", () => {
+  const tokenInput = document.getElementById("api-docs-token");
+  if (!tokenInput) return;
+
+  tokenInput.value = sessionStorage.getItem("api-docs-token") || "";
+  tokenInput.addEventListener("input", () => {
+    sessionStorage.setItem("api-docs-token", tokenInput.value);
+  });
+
+  document.querySelectorAll("form[data-api-docs-method]").forEach((form) => {
+    form.addEventListener("submit", async (e) => {
+      e.preventDefault();
+
+      const result = form.querySelector("[data-api-docs-result]");
+      result.classList.remove("hidden");
+      result.textContent = "Sending…";
+
+      try {
+        const response = await send(form, tokenInput.value.trim());
+        result.textContent = await describe(response);
+      } catch (err) {
+        result.textContent = err.message;
+      }
+    });
+  });
+
+  function send(form, token) {
+    let path = form.dataset.apiDocsPath;
+    const query = new URLSearchParams();
+
+    form.querySelectorAll("[data-api-docs-in]").forEach((input) => {
+      const value = input.value.trim();
+      if (input.dataset.apiDocsIn === "path") {
+        path = path.replace(`{${input.name}}`, encodeURIComponent(value));
+      } else if (value !== "") {
+        query.set(input.name, value);
+      }
+    });
+    if (query.size > 0) {
+      path += "?" + query.toString();
+    }
+
+    const headers = { Accept: "application/json" };
+    if (token !== "") {
+      headers.Authorization = `Bearer ${token}`;
+    }
+
+    const options = { method: form.dataset.apiDocsMethod, headers };
+    const body = form.querySelector("textarea[name=body]");
+    if (body) {
+      headers["Content-Type"] = "application/json";
+      options.body = body.value;
+    }
+
+    return fetch(path, options);
+  }
+
+  async function describe(response) {
+    let text = `${response.status} ${response.statusText}`;
+    const link = response.headers.get("Link");
+    if (link) {
+      text += `\nLink: ${link}`;
+    }
+
+    const body = await response.text();
+    if (body !== "") {
+      try {
+        text += "\n\n" + JSON.stringify(JSON.parse(body), null, 2);
+      } catch {
+        text += "\n\n" + body;
+      }
+    }
+    return text;
+  }
+});
@@ -0,0 +1,104 @@
+// Runs WebAuthn ceremonies for forms marked with data-passkey="register" or data-passkey="authenticate".
+// The options are fetched from the URL in data-passkey-options, and the resulting credential is put
+// in the form's "credential" field before the form is submitted.
+document.addEventListener("DOMContentLoaded", () => {
+  const forms = document.querySelectorAll("form[data-passkey]");
+
+  forms.forEach((form) => {
+    if (!window.PublicKeyCredential) {
+      showPasskeyError(form, "Your browser doesn't support passkeys.");
+      form.querySelectorAll("button").forEach((button) => (button.disabled = true));
+      return;
+    }
+
+    form.addEventListener("submit", async (e) => {
+      e.preventDefault();
+      showPasskeyError(form, "");
+
+      try {
+        const response = await fetch(form.dataset.passkeyOptions, {
+          method: "POST",
+          credentials: "same-origin",
+          headers: { "X-CSRF-Token": form.querySelector('input[name="csrf_token"]').value },
+        });
+        if (!response.ok) {
+          throw new Error("Failed to start the passkey ceremony");
+        }
+        const options = await response.json();
+
+        let credential;
+        if (form.dataset.passkey === "register") {
+          options.challenge = fromBase64URL(options.challenge);
+          options.user.id = fromBase64URL(options.user.id);
+          options.excludeCredentials = options.excludeCredentials.map(toDescriptor);
+          credential = await navigator.credentials.create({ publicKey: options });
+        } else {
+          options.challenge = fromBase64URL(options.challenge);
+          options.allowCredentials = options.allowCredentials.map(toDescriptor);
+          credential = await navigator.credentials.get({ publicKey: options });
+        }
+
+        form.querySelector('input[name="credential"]').value = JSON.stringify(encodeCredential(credential));
+        form.submit();
+      } catch (err) {
+        if (err.name === "NotAllowedError") {
+          showPasskeyError(form, "The passkey request was cancelled or timed out.");
+        } else if (err.name === "InvalidStateError") {
+          showPasskeyError(form, "This passkey is already registered.");
+        } else {
+          showPasskeyError(form, err.message);
+        }
+      }
+    });
+  });
+});
+
+function showPasskeyError(form, message) {
+  const error = form.querySelector("[data-passkey-error]");
+  if (!error) return;
+
+  error.textContent = message;
+  error.classList.toggle("hidden", message === "");
+}
+
+function toDescriptor(descriptor) {
+  return { type: descriptor.type, id: fromBase64URL(descriptor.id) };
+}
+
+function encodeCredential(credential) {
+  const response = credential.response;
+  const encoded = {
+    id: credential.id,
+    rawId: toBase64URL(credential.rawId),
+    type: credential.type,
+    response: {
+      clientDataJSON: toBase64URL(response.clientDataJSON),
+    },
+  };
+
+  if (response.attestationObject) {
+    encoded.response.attestationObject = toBase64URL(response.attestationObject);
+  }
+  if (response.authenticatorData) {
+    encoded.response.authenticatorData = toBase64URL(response.authenticatorData);
+    encoded.response.signature = toBase64URL(response.signature);
+    if (response.userHandle) {
+      encoded.response.userHandle = toBase64URL(response.userHandle);
+    }
+  }
+
+  return encoded;
+}
+
+function fromBase64URL(value) {
+  const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+  const padded = base64 + "=".repeat((4 - (base64.length % 4)) % 4);
+  return Uint8Array.from(atob(padded), (c) => c.charCodeAt(0));
+}
+
+function toBase64URL(buffer) {
+  const bytes = new Uint8Array(buffer);
+  let binary = "";
+  bytes.forEach((b) => (binary += String.fromCharCode(b)));
+  return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
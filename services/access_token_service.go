@@ -0,0 +1,88 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+// AccessTokenPrefix starts every personal access token, so leaked tokens are easy to spot
+const AccessTokenPrefix = "hc_pat_"
+
+// AccessTokenService creates personal access tokens and checks what they may be used for
+type AccessTokenService interface {
+	Create(userID int64, name string, scopes []string, expiresAt *int64, repositoryIDs []int64) (string, *models.AccessToken, error)
+	Authenticate(rawToken string) (*models.AccessToken, error)
+	HasScope(token *models.AccessToken, scope string) bool
+	AllowsRepository(token *models.AccessToken, repositoryID int64) bool
+}
+
+type accessTokenService struct {
+	tokens repositories.AccessTokensRepository
+}
+
+func NewAccessTokenService(tokens repositories.AccessTokensRepository) AccessTokenService {
+	return &accessTokenService{tokens: tokens}
+}
+
+// Create generates a new token and returns it in plain text. Only its hash is stored,
+// so the plain text can't be shown again.
+func (s *accessTokenService) Create(userID int64, name string, scopes []string, expiresAt *int64, repositoryIDs []int64) (string, *models.AccessToken, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, err
+	}
+	rawToken := AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	token, err := s.tokens.Create(userID, name, hashAccessToken(rawToken), scopes, expiresAt, repositoryIDs)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return rawToken, token, nil
+}
+
+// Authenticate returns the token for the given plain text, or nil if it is unknown or expired.
+// Tokens created before the prefix was introduced are still accepted.
+func (s *accessTokenService) Authenticate(rawToken string) (*models.AccessToken, error) {
+	if rawToken == "" {
+		return nil, nil
+	}
+
+	token, err := s.tokens.FindByTokenHash(hashAccessToken(rawToken))
+	if err != nil || token == nil {
+		return nil, err
+	}
+
+	if token.ExpiresAt != nil && time.Now().Unix() >= *token.ExpiresAt {
+		return nil, nil
+	}
+
+	// Update last used timestamp (ignore errors as this is not critical)
+	_ = s.tokens.UpdateLastUsed(token.ID)
+
+	return token, nil
+}
+
+// HasScope reports whether the token grants scope. repo:write implies repo:read.
+func (s *accessTokenService) HasScope(token *models.AccessToken, scope string) bool {
+	if slices.Contains(token.Scopes, scope) {
+		return true
+	}
+	return scope == models.ScopeRepoRead && slices.Contains(token.Scopes, models.ScopeRepoWrite)
+}
+
+// AllowsRepository reports whether the token may be used for the repository
+func (s *accessTokenService) AllowsRepository(token *models.AccessToken, repositoryID int64) bool {
+	return len(token.RepositoryIDs) == 0 || slices.Contains(token.RepositoryIDs, repositoryID)
+}
+
+func hashAccessToken(rawToken string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(rawToken)))
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httputil"
+)
+
+// AuditTarget is what an audited action was done to
+type AuditTarget struct {
+	Type  string
+	ID    *int64
+	Name  string
+	OrgID *int64 // the organization the target belongs to, if any
+}
+
+func UserAuditTarget(user *models.User) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetUser, ID: &user.ID, Name: user.Username}
+}
+
+// LoginAuditTarget is the target of a failed sign-in with an identifier that matches no user
+func LoginAuditTarget(login string) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetUser, Name: login}
+}
+
+func RepositoryAuditTarget(owner string, repo *models.Repository) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetRepository, ID: &repo.ID, Name: owner + "/" + repo.Name, OrgID: repo.OwnerOrgID}
+}
+
+func AccessTokenAuditTarget(token *models.AccessToken) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetAccessToken, ID: &token.ID, Name: token.Name}
+}
+
+func OAuthApplicationAuditTarget(application *models.OAuthApplication) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetOAuthApplication, ID: &application.ID, Name: application.Name, OrgID: application.OwnerOrgID}
+}
+
+// WebhookAuditTarget is named after the URL of the webhook, orgID is the organization owning the
+// webhook or its repository
+func WebhookAuditTarget(webhook *models.Webhook, orgID *int64) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetWebhook, ID: &webhook.ID, Name: webhook.URL, OrgID: orgID}
+}
+
+func OrganizationAuditTarget(org *models.Organization) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetOrganization, ID: &org.ID, Name: org.Username, OrgID: &org.ID}
+}
+
+// InstanceAuditTarget is the target of changes to the settings of the whole instance
+func InstanceAuditTarget() AuditTarget {
+	return AuditTarget{Type: models.AuditTargetInstance, Name: "instance"}
+}
+
+// AuditService records security-relevant actions in the audit log
+type AuditService interface {
+	// Record records that actor, nil if anonymous, did action to target from the client of r.
+	// The action already happened, so failing to record it is logged rather than returned.
+	Record(r *http.Request, actor *models.User, action string, target AuditTarget, metadata map[string]string)
+}
+
+type auditService struct {
+	events repositories.AuditEventsRepository
+}
+
+func NewAuditService(events repositories.AuditEventsRepository) AuditService {
+	return &auditService{events: events}
+}
+
+func (s *auditService) Record(r *http.Request, actor *models.User, action string, target AuditTarget, metadata map[string]string) {
+	event := &models.AuditEvent{
+		Action:     action,
+		TargetType: target.Type,
+		TargetID:   target.ID,
+		TargetName: target.Name,
+		OrgID:      target.OrgID,
+		IPAddress:  httputil.ClientIP(r),
+		Metadata:   metadata,
+	}
+	if actor != nil {
+		event.ActorID = &actor.ID
+		event.ActorName = actor.Username
+	}
+
+	if _, err := s.events.Create(event); err != nil {
+		slog.Error("failed to record audit event", "action", action, "target", target.Name, "error", err)
+	}
+}
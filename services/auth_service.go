@@ -2,13 +2,12 @@ package services
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
-	"crypto/subtle"
+	"encoding/base64"
 	"encoding/hex"
-	"fmt"
+	"errors"
 	"net/http"
-	"strconv"
-	"strings"
 	"time"
 
 	"golang.org/x/crypto/bcrypt"
@@ -19,25 +18,40 @@ import (
 )
 
 const (
-	CookieNameUserID = "hypercommit_user_id"
+	CookieNameSession = "hypercommit_session"
 )
 
+const (
+	// sessionIdleTimeout is how long a session stays valid without being used
+	sessionIdleTimeout = 30 * 24 * time.Hour
+	// sessionTouchInterval throttles how often activity on a session is written to the database
+	sessionTouchInterval = 5 * time.Minute
+	// sessionUserAgentMaxLength bounds the stored user agent
+	sessionUserAgentMaxLength = 512
+)
+
+// ErrNotSignedIn is returned when a request has no valid session
+var ErrNotSignedIn = errors.New("not signed in")
+
 type AuthService interface {
 	HashPassword(password string) (string, error)
 	CheckPassword(password, hash string) bool
-	SetUserCookie(w http.ResponseWriter, r *http.Request, userID int64)
+	StartSession(w http.ResponseWriter, r *http.Request, userID int64) error
 	GetUserFromCookie(r *http.Request) (*models.User, error)
-	ClearUserCookie(w http.ResponseWriter)
+	CurrentSession(r *http.Request) (*models.Session, error)
+	EndSession(w http.ResponseWriter, r *http.Request) error
 }
 
 type authService struct {
 	users         repositories.UsersRepository
+	sessions      repositories.SessionsRepository
 	signingSecret string
 }
 
-func NewAuthService(users repositories.UsersRepository, signingSecret string) AuthService {
+func NewAuthService(users repositories.UsersRepository, sessions repositories.SessionsRepository, signingSecret string) AuthService {
 	return &authService{
 		users:         users,
+		sessions:      sessions,
 		signingSecret: signingSecret,
 	}
 }
@@ -52,83 +66,113 @@ func (s *authService) CheckPassword(password, hash string) bool {
 	return err == nil
 }
 
-func (s *authService) signCookieValue(userID int64) string {
-	timestamp := time.Now().Unix()
-	payload := fmt.Sprintf("%d|%d", userID, timestamp)
-
+// hashSessionToken keys the hash with the signing secret, so a leaked sessions table
+// alone can't be used to forge cookies
+func (s *authService) hashSessionToken(token string) string {
 	mac := hmac.New(sha256.New, []byte(s.signingSecret))
-	mac.Write([]byte(payload))
-	signature := hex.EncodeToString(mac.Sum(nil))
-
-	return fmt.Sprintf("%s|%s", payload, signature)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
-func (s *authService) verifyCookieValue(signedValue string) (int64, error) {
-	parts := strings.Split(signedValue, "|")
-	if len(parts) != 3 {
-		return 0, fmt.Errorf("invalid cookie format")
+// StartSession creates a session for the user and sets its cookie
+func (s *authService) StartSession(w http.ResponseWriter, r *http.Request, userID int64) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
 	}
+	token := base64.RawURLEncoding.EncodeToString(b)
 
-	userIDStr, timestampStr, providedSig := parts[0], parts[1], parts[2]
-
-	payload := fmt.Sprintf("%s|%s", userIDStr, timestampStr)
-	mac := hmac.New(sha256.New, []byte(s.signingSecret))
-	mac.Write([]byte(payload))
-	expectedSig := hex.EncodeToString(mac.Sum(nil))
-
-	if subtle.ConstantTimeCompare([]byte(providedSig), []byte(expectedSig)) != 1 {
-		return 0, fmt.Errorf("invalid signature")
+	userAgent := r.UserAgent()
+	if len(userAgent) > sessionUserAgentMaxLength {
+		userAgent = userAgent[:sessionUserAgentMaxLength]
 	}
 
-	userID, err := strconv.ParseInt(userIDStr, 10, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid user ID")
+	now := time.Now()
+	if err := s.sessions.DeleteExpired(now.Unix()); err != nil {
+		return err
 	}
 
-	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+	_, err := s.sessions.Create(userID, s.hashSessionToken(token), userAgent, httputil.ClientIP(r), now.Add(sessionIdleTimeout).Unix())
 	if err != nil {
-		return 0, fmt.Errorf("invalid timestamp")
-	}
-
-	if time.Now().Unix()-timestamp > 86400*365 {
-		return 0, fmt.Errorf("cookie expired")
+		return err
 	}
 
-	return userID, nil
-}
-
-func (s *authService) SetUserCookie(w http.ResponseWriter, r *http.Request, userID int64) {
-	signedValue := s.signCookieValue(userID)
+	// The cookie outlives the idle timeout, the session row decides whether it is still valid
 	http.SetCookie(w, &http.Cookie{
-		Name:     CookieNameUserID,
-		Value:    signedValue,
+		Name:     CookieNameSession,
+		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
 		Secure:   httputil.IsHTTPS(r),
 		SameSite: http.SameSiteLaxMode,
 		MaxAge:   86400 * 365,
 	})
+
+	return nil
 }
 
+// CurrentSession returns the unexpired session of the request, or nil
+func (s *authService) CurrentSession(r *http.Request) (*models.Session, error) {
+	cookie, err := r.Cookie(CookieNameSession)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+
+	session, err := s.sessions.FindByTokenHash(s.hashSessionToken(cookie.Value))
+	if err != nil || session == nil {
+		return nil, err
+	}
+
+	if time.Now().Unix() >= session.ExpiresAt {
+		return nil, nil
+	}
+
+	return session, nil
+}
+
+// GetUserFromCookie returns the signed in user, or ErrNotSignedIn. Every use of a session extends it.
 func (s *authService) GetUserFromCookie(r *http.Request) (*models.User, error) {
-	cookie, err := r.Cookie(CookieNameUserID)
+	session, err := s.CurrentSession(r)
 	if err != nil {
 		return nil, err
 	}
+	if session == nil {
+		return nil, ErrNotSignedIn
+	}
+
+	now := time.Now()
+	ip := httputil.ClientIP(r)
+	if now.Unix()-session.LastSeenAt >= int64(sessionTouchInterval.Seconds()) || ip != session.IPAddress {
+		if err := s.sessions.Touch(session.ID, ip, now.Add(sessionIdleTimeout).Unix()); err != nil {
+			return nil, err
+		}
+	}
 
-	userID, err := s.verifyCookieValue(cookie.Value)
+	user, err := s.users.FindByID(session.UserID)
 	if err != nil {
 		return nil, err
 	}
+	// Suspended users keep no access through sessions that were started before
+	if user == nil || user.SuspendedAt != nil {
+		return nil, ErrNotSignedIn
+	}
 
-	return s.users.FindByID(userID)
+	return user, nil
 }
 
-func (s *authService) ClearUserCookie(w http.ResponseWriter) {
+// EndSession deletes the session of the request and clears its cookie
+func (s *authService) EndSession(w http.ResponseWriter, r *http.Request) error {
 	http.SetCookie(w, &http.Cookie{
-		Name:   CookieNameUserID,
+		Name:   CookieNameSession,
 		Value:  "",
 		Path:   "/",
 		MaxAge: -1,
 	})
+
+	session, err := s.CurrentSession(r)
+	if err != nil || session == nil {
+		return err
+	}
+
+	return s.sessions.Delete(session.ID)
 }
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httputil"
+)
+
+// AuthThrottleLimits configures AuthThrottleService, see the fields of config.Config with the same names
+type AuthThrottleLimits struct {
+	IPRateLimit         int
+	AccountRateLimit    int
+	DeviceCodeRateLimit int
+	LockoutThreshold    int
+	LockoutDuration     time.Duration
+	LockoutMaxDuration  time.Duration
+}
+
+// AuthThrottleService slows down password guessing. Failed attempts take tokens from buckets
+// of the client IP and of the account, and every LockoutThreshold consecutive failures lock
+// the account for twice as long as the previous lockout. Successful attempts reset the count.
+//
+// Accounts are identified by user when the identifier matches one, so a lockout applies to
+// sign-in and git alike, and by the identifier itself otherwise.
+type AuthThrottleService interface {
+	// Check reports how long the client has to wait before attempting to authenticate as the account
+	Check(r *http.Request, user *models.User, login string) time.Duration
+	Failure(r *http.Request, user *models.User, login string) error
+	Success(user *models.User, login string)
+	// CheckDeviceCode takes a token from the bucket of the client IP for starting a device authorization
+	CheckDeviceCode(r *http.Request) time.Duration
+}
+
+type authThrottleService struct {
+	limiter  RateLimiter
+	lockouts repositories.AuthLockoutsRepository
+	limits   AuthThrottleLimits
+}
+
+func NewAuthThrottleService(limiter RateLimiter, lockouts repositories.AuthLockoutsRepository, limits AuthThrottleLimits) AuthThrottleService {
+	return &authThrottleService{
+		limiter:  limiter,
+		lockouts: lockouts,
+		limits:   limits,
+	}
+}
+
+func (s *authThrottleService) Check(r *http.Request, user *models.User, login string) time.Duration {
+	account := accountKey(user, login)
+
+	return max(
+		s.limiter.LockedFor(account),
+		s.limiter.Wait(ipKey("auth", r), PerMinute(s.limits.IPRateLimit)),
+		s.limiter.Wait(account, PerMinute(s.limits.AccountRateLimit)),
+	)
+}
+
+func (s *authThrottleService) Failure(r *http.Request, user *models.User, login string) error {
+	account := accountKey(user, login)
+	ip := httputil.ClientIP(r)
+
+	s.limiter.Take(ipKey("auth", r), PerMinute(s.limits.IPRateLimit))
+	s.limiter.Take(account, PerMinute(s.limits.AccountRateLimit))
+
+	failures := s.limiter.AddFailure(account)
+	if s.limits.LockoutThreshold <= 0 || failures%s.limits.LockoutThreshold != 0 {
+		return nil
+	}
+
+	duration := s.lockoutDuration(failures / s.limits.LockoutThreshold)
+	s.limiter.Lock(account, duration)
+	slog.Warn("account locked after failed authentication attempts", "account", account, "ip", ip, "failures", failures, "duration", duration)
+
+	var userID *int64
+	if user != nil {
+		userID = &user.ID
+	}
+
+	_, err := s.lockouts.Create(userID, account, ip, failures, time.Now().Add(duration).Unix())
+	return err
+}
+
+func (s *authThrottleService) Success(user *models.User, login string) {
+	s.limiter.ResetFailures(accountKey(user, login))
+}
+
+func (s *authThrottleService) CheckDeviceCode(r *http.Request) time.Duration {
+	_, wait := s.limiter.Take(ipKey("device-code", r), PerMinute(s.limits.DeviceCodeRateLimit))
+	return wait
+}
+
+// lockoutDuration doubles the lockout duration for every lockout after the first, up to the maximum
+func (s *authThrottleService) lockoutDuration(lockouts int) time.Duration {
+	duration := s.limits.LockoutDuration
+	for i := 1; i < lockouts && duration < s.limits.LockoutMaxDuration; i++ {
+		duration *= 2
+	}
+
+	return min(duration, s.limits.LockoutMaxDuration)
+}
+
+func accountKey(user *models.User, login string) string {
+	if user != nil {
+		return fmt.Sprintf("user:%d", user.ID)
+	}
+	return "login:" + strings.ToLower(strings.TrimSpace(login))
+}
+
+func ipKey(action string, r *http.Request) string {
+	return action + ":ip:" + httputil.ClientIP(r)
+}
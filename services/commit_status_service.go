@@ -0,0 +1,55 @@
+package services
+
+import (
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+// CombinedStatus sums up the statuses reported for a commit
+type CombinedStatus struct {
+	SHA string
+	// State is failure once a check failed or errored, pending while one hasn't finished and success
+	// when all of them succeeded. It is empty if nothing reported a status for the commit.
+	State    string
+	Statuses []*models.CommitStatus
+}
+
+type CommitStatusService interface {
+	// Combined returns the combined status of a commit of the repository
+	Combined(repositoryID int64, sha string) (*CombinedStatus, error)
+}
+
+type commitStatusService struct {
+	statuses repositories.CommitStatusesRepository
+}
+
+func NewCommitStatusService(statuses repositories.CommitStatusesRepository) CommitStatusService {
+	return &commitStatusService{statuses: statuses}
+}
+
+func (s *commitStatusService) Combined(repositoryID int64, sha string) (*CombinedStatus, error) {
+	statuses, err := s.statuses.FindByRepositoryAndSHA(repositoryID, sha)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CombinedStatus{SHA: sha, State: combineCommitStates(statuses), Statuses: statuses}, nil
+}
+
+// combineCommitStates returns the state of a commit with the statuses, the worst one wins
+func combineCommitStates(statuses []*models.CommitStatus) string {
+	if len(statuses) == 0 {
+		return ""
+	}
+
+	state := models.CommitStateSuccess
+	for _, status := range statuses {
+		switch status.State {
+		case models.CommitStateFailure, models.CommitStateError:
+			return models.CommitStateFailure
+		case models.CommitStatePending:
+			state = models.CommitStatePending
+		}
+	}
+	return state
+}
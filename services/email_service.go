@@ -0,0 +1,136 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+//go:embed emails
+var emailTemplatesFS embed.FS
+
+// TicketNotificationEmail describes a single ticket notification sent by email
+type TicketNotificationEmail struct {
+	Repository   string // owner/name
+	TicketNumber int64
+	TicketTitle  string
+	Summary      string // e.g. "alice commented on this ticket"
+	Body         string // comment body, may be empty
+	Reason       string // why the user receives this, e.g. "you were mentioned"
+	URL          string
+}
+
+// EmailService renders templated emails and queues them in the outbox.
+// Delivery happens in the background through an OutboxWorker, so sending never blocks a request.
+type EmailService interface {
+	SendPasswordReset(to, username, resetURL string, expiresIn time.Duration) error
+	SendEmailVerification(to, username, email, verifyURL string, expiresIn time.Duration) error
+	SendTicketNotification(to string, notification TicketNotificationEmail) error
+}
+
+type emailService struct {
+	outbox    repositories.EmailOutboxRepository
+	publicURL string
+	html      map[string]*htmltemplate.Template
+	text      map[string]*texttemplate.Template
+}
+
+func NewEmailService(outbox repositories.EmailOutboxRepository, publicURL string) (EmailService, error) {
+	s := &emailService{
+		outbox:    outbox,
+		publicURL: publicURL,
+		html:      make(map[string]*htmltemplate.Template),
+		text:      make(map[string]*texttemplate.Template),
+	}
+
+	for _, name := range []string{"password_reset", "email_verification", "ticket_notification"} {
+		// Each page is parsed on top of its own copy of the layout, so it can override blocks like "footer"
+		htmlTemplate, err := htmltemplate.ParseFS(emailTemplatesFS, "emails/layout.html", "emails/"+name+".html")
+		if err != nil {
+			return nil, fmt.Errorf("parse %s.html: %w", name, err)
+		}
+		textTemplate, err := texttemplate.ParseFS(emailTemplatesFS, "emails/"+name+".txt")
+		if err != nil {
+			return nil, fmt.Errorf("parse %s.txt: %w", name, err)
+		}
+
+		s.html[name] = htmlTemplate
+		s.text[name] = textTemplate
+	}
+
+	return s, nil
+}
+
+func (s *emailService) SendPasswordReset(to, username, resetURL string, expiresIn time.Duration) error {
+	return s.enqueue(to, "Reset your Hypercommit password", "password_reset", map[string]any{
+		"Username":  username,
+		"URL":       resetURL,
+		"ExpiresIn": formatDuration(expiresIn),
+	})
+}
+
+func (s *emailService) SendEmailVerification(to, username, email, verifyURL string, expiresIn time.Duration) error {
+	return s.enqueue(to, "Verify your email address", "email_verification", map[string]any{
+		"Username":  username,
+		"Email":     email,
+		"URL":       verifyURL,
+		"ExpiresIn": formatDuration(expiresIn),
+	})
+}
+
+func (s *emailService) SendTicketNotification(to string, notification TicketNotificationEmail) error {
+	subject := fmt.Sprintf("[%s] %s (#%d)", notification.Repository, notification.TicketTitle, notification.TicketNumber)
+	return s.enqueue(to, subject, "ticket_notification", map[string]any{
+		"Repository":   notification.Repository,
+		"TicketNumber": notification.TicketNumber,
+		"TicketTitle":  notification.TicketTitle,
+		"Summary":      notification.Summary,
+		"Body":         notification.Body,
+		"Reason":       notification.Reason,
+		"URL":          notification.URL,
+		"InboxURL":     s.publicURL + "/notifications",
+	})
+}
+
+func (s *emailService) enqueue(to, subject, name string, data map[string]any) error {
+	data["Subject"] = subject
+
+	var textBody bytes.Buffer
+	if err := s.text[name].Execute(&textBody, data); err != nil {
+		return fmt.Errorf("render %s.txt: %w", name, err)
+	}
+
+	var htmlBody bytes.Buffer
+	if err := s.html[name].ExecuteTemplate(&htmlBody, "layout", data); err != nil {
+		return fmt.Errorf("render %s.html: %w", name, err)
+	}
+	html := htmlBody.String()
+
+	_, err := s.outbox.Enqueue(to, subject, strings.TrimSpace(textBody.String())+"\n", &html)
+	return err
+}
+
+// formatDuration renders durations like "1 hour" or "30 minutes" for email copy
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return pluralize(int64(d/(24*time.Hour)), "day")
+	case d >= time.Hour && d%time.Hour == 0:
+		return pluralize(int64(d/time.Hour), "hour")
+	default:
+		return pluralize(int64(d/time.Minute), "minute")
+	}
+}
+
+func pluralize(n int64, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/services/smtptest"
+)
+
+const testMailFrom = "Hypercommit <noreply@example.com>"
+
+// newTestMailer starts an SMTP server and returns an email service whose outbox it receives
+func newTestMailer(t *testing.T, outbox repositories.EmailOutboxRepository) (EmailService, OutboxWorker, *smtptest.Server) {
+	t.Helper()
+
+	server, err := smtptest.NewServer()
+	if err != nil {
+		t.Fatalf("start smtp server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	emailService, err := NewEmailService(outbox, "https://hypercommit.test")
+	if err != nil {
+		t.Fatalf("create email service: %v", err)
+	}
+	worker := NewOutboxWorker(outbox, NewSMTPMailer(server.Host(), server.Port(), "", "", testMailFrom))
+
+	return emailService, worker, server
+}
+
+// deliverOne delivers the outbox and returns the only message the server received, with its plain
+// text body
+func deliverOne(t *testing.T, worker OutboxWorker, server *smtptest.Server) (smtptest.Message, *mail.Message, string) {
+	t.Helper()
+
+	if err := worker.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("deliver outbox: %v", err)
+	}
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+
+	msg, err := mail.ReadMessage(strings.NewReader(messages[0].Data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("parse content type: %v", err)
+	}
+
+	parts := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := parts.NextPart()
+		if err != nil {
+			t.Fatalf("no text/plain part: %v", err)
+		}
+		if strings.HasPrefix(part.Header.Get("Content-Type"), "text/plain") {
+			text, err := io.ReadAll(part)
+			if err != nil {
+				t.Fatalf("read text part: %v", err)
+			}
+			return messages[0], msg, string(text)
+		}
+	}
+}
+
+var tokenURLPattern = regexp.MustCompile(`https://hypercommit\.test/[a-z-]+\?token=\S+`)
+
+// linkToken returns the token of the first link in the text
+func linkToken(t *testing.T, text string) string {
+	t.Helper()
+
+	link := tokenURLPattern.FindString(text)
+	if link == "" {
+		t.Fatalf("no link in email:\n%s", text)
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		t.Fatalf("parse link: %v", err)
+	}
+	return parsed.Query().Get("token")
+}
+
+func TestPasswordResetEmailIsDelivered(t *testing.T) {
+	db := newTestDB(t)
+	emailService, worker, server := newTestMailer(t, repositories.NewEmailOutboxRepository(db.DB))
+
+	resetURL := "https://hypercommit.test/reset-password?token=abc"
+	if err := emailService.SendPasswordReset("Alice <alice@example.com>", "alice", resetURL, time.Hour); err != nil {
+		t.Fatalf("send password reset: %v", err)
+	}
+
+	received, msg, text := deliverOne(t, worker, server)
+	if received.From != "noreply@example.com" {
+		t.Errorf("envelope sender = %q, want noreply@example.com", received.From)
+	}
+	if len(received.To) != 1 || received.To[0] != "alice@example.com" {
+		t.Errorf("envelope recipients = %v, want [alice@example.com]", received.To)
+	}
+	if subject := msg.Header.Get("Subject"); subject != "Reset your Hypercommit password" {
+		t.Errorf("subject = %q", subject)
+	}
+	if !strings.Contains(text, resetURL) {
+		t.Errorf("text body doesn't contain the reset link:\n%s", text)
+	}
+	if !strings.Contains(text, "1 hour") {
+		t.Errorf("text body doesn't say when the link expires:\n%s", text)
+	}
+
+	// Delivered emails aren't sent again
+	if err := worker.DeliverDue(context.Background()); err != nil {
+		t.Fatalf("deliver outbox: %v", err)
+	}
+	if n := len(server.Messages()); n != 1 {
+		t.Errorf("got %d messages after a second pass, want 1", n)
+	}
+}
+
+func TestEmailVerificationLinkVerifiesTheAddress(t *testing.T) {
+	db := newTestDB(t)
+	users := repositories.NewUsersRepository(db.DB)
+	userEmails := repositories.NewUserEmailsRepository(db.DB)
+	emailService, worker, server := newTestMailer(t, repositories.NewEmailOutboxRepository(db.DB))
+	verification := NewEmailVerificationService(userEmails, emailService, "https://hypercommit.test")
+
+	user, err := users.Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	userEmail, err := userEmails.Create(user.ID, "alice@work.example.com", false, false)
+	if err != nil {
+		t.Fatalf("create user email: %v", err)
+	}
+
+	if err := verification.SendVerification(user, userEmail); err != nil {
+		t.Fatalf("send verification: %v", err)
+	}
+
+	received, msg, text := deliverOne(t, worker, server)
+	if len(received.To) != 1 || received.To[0] != "alice@work.example.com" {
+		t.Errorf("envelope recipients = %v, want [alice@work.example.com]", received.To)
+	}
+	if subject := msg.Header.Get("Subject"); subject != "Verify your email address" {
+		t.Errorf("subject = %q", subject)
+	}
+
+	token := linkToken(t, text)
+	if verified, err := verification.Verify("wrong" + token); err != nil || verified != nil {
+		t.Fatalf("Verify(wrong token) = %v, %v, want nil", verified, err)
+	}
+
+	verified, err := verification.Verify(token)
+	if err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if verified == nil || verified.ID != userEmail.ID || verified.VerifiedAt == nil {
+		t.Fatalf("Verify() = %+v, want the address verified", verified)
+	}
+
+	// Links work once
+	if again, err := verification.Verify(token); err != nil || again != nil {
+		t.Errorf("second Verify() = %v, %v, want nil", again, err)
+	}
+}
+
+func TestEmailVerificationIsRateLimited(t *testing.T) {
+	db := newTestDB(t)
+	users := repositories.NewUsersRepository(db.DB)
+	userEmails := repositories.NewUserEmailsRepository(db.DB)
+	emailService, _, _ := newTestMailer(t, repositories.NewEmailOutboxRepository(db.DB))
+	verification := NewEmailVerificationService(userEmails, emailService, "https://hypercommit.test")
+
+	user, err := users.Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	userEmail, err := userEmails.Create(user.ID, "alice@work.example.com", false, false)
+	if err != nil {
+		t.Fatalf("create user email: %v", err)
+	}
+
+	for i := range emailVerificationMaxPerHour {
+		if err := verification.SendVerification(user, userEmail); err != nil {
+			t.Fatalf("send verification %d: %v", i+1, err)
+		}
+	}
+	if err := verification.SendVerification(user, userEmail); err != ErrTooManyVerificationEmails {
+		t.Errorf("send verification past the limit = %v, want ErrTooManyVerificationEmails", err)
+	}
+}
+
+func TestVerifyingAnEmailRemovesUnverifiedClaims(t *testing.T) {
+	db := newTestDB(t)
+	users := repositories.NewUsersRepository(db.DB)
+	userEmails := repositories.NewUserEmailsRepository(db.DB)
+	emailService, worker, server := newTestMailer(t, repositories.NewEmailOutboxRepository(db.DB))
+	verification := NewEmailVerificationService(userEmails, emailService, "https://hypercommit.test")
+
+	mallory, err := users.Create("mallory", "mallory@example.com", "Mallory", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	alice, err := users.Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	// Both add the address, only Alice can read the link
+	squatted, err := userEmails.Create(mallory.ID, "alice@work.example.com", false, false)
+	if err != nil {
+		t.Fatalf("create user email: %v", err)
+	}
+	claimed, err := userEmails.Create(alice.ID, "Alice@Work.example.com", false, false)
+	if err != nil {
+		t.Fatalf("add an address another user added without verifying it: %v", err)
+	}
+	if err := verification.SendVerification(alice, claimed); err != nil {
+		t.Fatalf("send verification: %v", err)
+	}
+	_, _, text := deliverOne(t, worker, server)
+
+	if verified, err := verification.Verify(linkToken(t, text)); err != nil || verified == nil {
+		t.Fatalf("Verify() = %v, %v", verified, err)
+	}
+	if userEmail, err := userEmails.FindByID(squatted.ID); err != nil || userEmail != nil {
+		t.Errorf("unverified claim = %+v, %v, want it removed", userEmail, err)
+	}
+	if owner, err := userEmails.FindVerifiedByEmail("alice@work.example.com"); err != nil || owner == nil || owner.UserID != alice.ID {
+		t.Errorf("FindVerifiedByEmail() = %+v, %v, want Alice's address", owner, err)
+	}
+}
+
+func TestLogMailerLeavesBodiesOut(t *testing.T) {
+	var logs bytes.Buffer
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	err := NewLogMailer().Send(context.Background(), Message{
+		To:      "alice@example.com",
+		Subject: "Reset your password",
+		Text:    "https://example.com/auth/reset-password?token=secret-token",
+		HTML:    `<a href="https://example.com/auth/reset-password?token=secret-token">Reset</a>`,
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	if !strings.Contains(logs.String(), "alice@example.com") || !strings.Contains(logs.String(), "Reset your password") {
+		t.Errorf("log %q doesn't name the recipient and subject", logs.String())
+	}
+	if strings.Contains(logs.String(), "secret-token") {
+		t.Errorf("log %q holds the body", logs.String())
+	}
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const (
+	// emailVerificationTTL is how long a verification link stays valid
+	emailVerificationTTL = 24 * time.Hour
+	// emailVerificationMaxPerHour bounds how many verification emails a single address receives per hour
+	emailVerificationMaxPerHour = 3
+)
+
+// ErrTooManyVerificationEmails is returned when an address was sent too many verification links recently
+var ErrTooManyVerificationEmails = errors.New("too many verification emails")
+
+// EmailVerificationService sends verification links for user emails and redeems them
+type EmailVerificationService interface {
+	SendVerification(user *models.User, userEmail *models.UserEmail) error
+	Verify(rawToken string) (*models.UserEmail, error)
+}
+
+type emailVerificationService struct {
+	userEmails   repositories.UserEmailsRepository
+	emailService EmailService
+	publicURL    string
+}
+
+func NewEmailVerificationService(userEmails repositories.UserEmailsRepository, emailService EmailService, publicURL string) EmailVerificationService {
+	return &emailVerificationService{
+		userEmails:   userEmails,
+		emailService: emailService,
+		publicURL:    publicURL,
+	}
+}
+
+func (s *emailVerificationService) SendVerification(user *models.User, userEmail *models.UserEmail) error {
+	since := time.Now().Add(-time.Hour).Unix()
+	count, err := s.userEmails.CountVerificationTokensSince(userEmail.ID, since)
+	if err != nil {
+		return err
+	}
+	if count >= emailVerificationMaxPerHour {
+		return ErrTooManyVerificationEmails
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	rawToken := base64.RawURLEncoding.EncodeToString(b)
+
+	expiresAt := time.Now().Add(emailVerificationTTL).Unix()
+	if _, err := s.userEmails.CreateVerificationToken(userEmail.ID, hashVerificationToken(rawToken), expiresAt); err != nil {
+		return err
+	}
+
+	verifyURL := s.publicURL + "/verify-email?token=" + url.QueryEscape(rawToken)
+	return s.emailService.SendEmailVerification(userEmail.Email, user.Username, userEmail.Email, verifyURL, emailVerificationTTL)
+}
+
+// Verify redeems a verification token and returns the verified email, or nil if the token is invalid
+func (s *emailVerificationService) Verify(rawToken string) (*models.UserEmail, error) {
+	if rawToken == "" {
+		return nil, nil
+	}
+
+	token, err := s.userEmails.FindValidVerificationToken(hashVerificationToken(rawToken), time.Now().Unix())
+	if err != nil || token == nil {
+		return nil, err
+	}
+
+	consumed, err := s.userEmails.ConsumeVerificationToken(token.ID)
+	if err != nil || !consumed {
+		return nil, err
+	}
+
+	// The address may have been removed since the link was sent
+	userEmail, err := s.userEmails.FindByID(token.UserEmailID)
+	if err != nil || userEmail == nil {
+		return nil, err
+	}
+
+	// or verified by another user, who keeps it
+	owner, err := s.userEmails.FindVerifiedByEmail(userEmail.Email)
+	if err != nil {
+		return nil, err
+	}
+	if owner != nil && owner.ID != userEmail.ID {
+		return nil, nil
+	}
+
+	if err := s.userEmails.MarkVerified(userEmail.ID); err != nil {
+		return nil, err
+	}
+
+	return s.userEmails.FindByID(userEmail.ID)
+}
+
+func hashVerificationToken(rawToken string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(rawToken)))
+}
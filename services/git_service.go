@@ -2,11 +2,18 @@ package services
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
 )
 
 type TreeEntry struct {
@@ -16,12 +23,34 @@ type TreeEntry struct {
 	Mode string
 }
 
+// Commit is a commit as listed by ListCommits
+type Commit struct {
+	ID          string
+	Message     string
+	AuthorName  string
+	AuthorEmail string
+	Timestamp   int64
+}
+
 type GitService interface {
 	ListBranches(repoPath string) ([]string, error)
 	GetDefaultBranch(repoPath string) (string, error)
 	ListTree(repoPath, ref, path string) ([]TreeEntry, error)
 	GetFileContent(repoPath, ref, path string) ([]byte, error)
 	IsFile(repoPath, ref, path string) (bool, error)
+	RepositoryPath(repo *models.Repository) string
+	InitRepository(repo *models.Repository) error
+	// ListRefs returns the object ID of every branch and tag by full ref name
+	ListRefs(repoPath string) (map[string]string, error)
+	// ListCommits returns up to limit commits reachable from to but from none of exclude, newest first
+	ListCommits(repoPath, to string, exclude []string, limit int) ([]Commit, error)
+	// ResolveCommit returns the ID of the commit a branch, tag or commit ID names, empty if there is none
+	ResolveCommit(repoPath, ref string) (string, error)
+	// Archive writes the files of the commit to w as a tar archive
+	Archive(repoPath, commit string, w io.Writer) error
+	// ArchiveZip writes the files of the commit to w as a zip archive, with the line endings committed
+	ArchiveZip(repoPath, commit string, w io.Writer) error
+	DiskUsage(path string) (int64, error)
 }
 
 type gitService struct {
@@ -208,3 +237,194 @@ func (s *gitService) IsFile(repoPath, ref, path string) (bool, error) {
 	objectType := strings.TrimSpace(out.String())
 	return objectType == "blob", nil
 }
+
+// RepositoryPath returns where the bare repository is stored on disk
+func (s *gitService) RepositoryPath(repo *models.Repository) string {
+	var ownerIDForPath string
+	if repo.OwnerUserID != nil {
+		ownerIDForPath = fmt.Sprintf("%d", *repo.OwnerUserID)
+	} else if repo.OwnerOrgID != nil {
+		ownerIDForPath = fmt.Sprintf("org_%d", *repo.OwnerOrgID)
+	}
+
+	return filepath.Join(s.reposBasePath, ownerIDForPath, fmt.Sprintf("%d", repo.ID))
+}
+
+// InitRepository creates the bare repository on disk that accepts pushes over HTTP
+func (s *gitService) InitRepository(repo *models.Repository) error {
+	repoPath := s.RepositoryPath(repo)
+	if err := os.MkdirAll(repoPath, 0755); err != nil {
+		return fmt.Errorf("failed to create repository directory: %w", err)
+	}
+
+	cmd := exec.Command("git", "init", "--bare")
+	cmd.Dir = repoPath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to initialize repository: %w (output: %s)", err, out)
+	}
+
+	cmd = exec.Command("git", "config", "http.receivepack", "true")
+	cmd.Dir = repoPath
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to configure repository: %w (output: %s)", err, out)
+	}
+
+	return nil
+}
+
+// DiskUsage returns the total size of the files under path, 0 if it doesn't exist
+func (s *gitService) DiskUsage(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.Type().IsRegular() {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+
+	return size, err
+}
+
+func (s *gitService) ListRefs(repoPath string) (map[string]string, error) {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := exec.Command("git", "for-each-ref", "--format=%(objectname) %(refname)", "refs/heads/", "refs/tags/")
+	cmd.Dir = absPath
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to list refs: %w (output: %s)", err, out.String())
+	}
+
+	refs := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		objectID, ref, ok := strings.Cut(line, " ")
+		if ok {
+			refs[ref] = objectID
+		}
+	}
+	return refs, nil
+}
+
+func (s *gitService) ListCommits(repoPath, to string, exclude []string, limit int) ([]Commit, error) {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fields are separated by NUL and commits by the record separator, neither appears in messages
+	args := []string{"log", "--format=%H%x00%an%x00%ae%x00%at%x00%B%x1e", "-n", strconv.Itoa(limit), to}
+	if len(exclude) > 0 {
+		args = append(args, "--not")
+		args = append(args, exclude...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = absPath
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w (output: %s)", err, stderr.String())
+	}
+
+	var commits []Commit
+	for _, record := range strings.Split(out.String(), "\x1e") {
+		fields := strings.SplitN(strings.TrimLeft(record, "\n"), "\x00", 5)
+		if len(fields) < 5 {
+			continue
+		}
+		timestamp, _ := strconv.ParseInt(fields[3], 10, 64)
+		commits = append(commits, Commit{
+			ID:          fields[0],
+			AuthorName:  fields[1],
+			AuthorEmail: fields[2],
+			Timestamp:   timestamp,
+			Message:     strings.TrimSpace(fields[4]),
+		})
+	}
+	return commits, nil
+}
+
+func (s *gitService) ResolveCommit(repoPath, ref string) (string, error) {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return "", err
+	}
+
+	// The ref can't be taken for an option, --end-of-options ends them
+	cmd := exec.Command("git", "rev-parse", "--verify", "--quiet", "--end-of-options", ref+"^{commit}")
+	cmd.Dir = absPath
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() == 0 {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to resolve commit: %w (output: %s)", err, stderr.String())
+	}
+
+	return strings.TrimSpace(out.String()), nil
+}
+
+func (s *gitService) Archive(repoPath, commit string, w io.Writer) error {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command("git", "archive", "--format=tar", "--end-of-options", commit)
+	cmd.Dir = absPath
+
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to archive commit: %w (output: %s)", err, stderr.String())
+	}
+
+	return nil
+}
+
+func (s *gitService) ArchiveZip(repoPath, commit string, w io.Writer) error {
+	absPath, err := filepath.Abs(repoPath)
+	if err != nil {
+		return err
+	}
+
+	// Line endings are left alone whatever the configuration, as the go command does
+	cmd := exec.Command("git", "-c", "core.autocrlf=input", "-c", "core.eol=lf", "archive", "--format=zip", "--end-of-options", commit)
+	cmd.Dir = absPath
+
+	var stderr bytes.Buffer
+	cmd.Stdout = w
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to archive commit: %w (output: %s)", err, stderr.String())
+	}
+
+	return nil
+}
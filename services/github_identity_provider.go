@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/github"
+)
+
+type GitHubUser struct {
+	ID        int64  `json:"id"`
+	Login     string `json:"login"`
+	Email     string `json:"email"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url"`
+	// EmailVerified is true if GitHub reports Email as verified
+	EmailVerified bool `json:"-"`
+}
+
+type githubIdentityProvider struct {
+	config *oauth2.Config
+}
+
+// NewGitHubIdentityProvider signs users in with their GitHub account
+func NewGitHubIdentityProvider(clientID, clientSecret, callbackURL string) IdentityProvider {
+	return &githubIdentityProvider{
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  callbackURL,
+			Scopes:       []string{"user:email", "read:user"},
+			Endpoint:     github.Endpoint,
+		},
+	}
+}
+
+func (s *githubIdentityProvider) ID() string {
+	return "github"
+}
+
+func (s *githubIdentityProvider) Name() string {
+	return "GitHub"
+}
+
+func (s *githubIdentityProvider) AuthURL(ctx context.Context, state, verifier, nonce string) (string, error) {
+	return s.config.AuthCodeURL(state, oauth2.AccessTypeOnline, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+func (s *githubIdentityProvider) Identify(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error) {
+	token, err := s.config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	user, err := s.getUserInfo(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	name := user.Name
+	if name == "" {
+		name = user.Login
+	}
+
+	return &ExternalIdentity{
+		Subject:       strconv.FormatInt(user.ID, 10),
+		Username:      user.Login,
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		Name:          name,
+	}, nil
+}
+
+func (s *githubIdentityProvider) getUserInfo(ctx context.Context, token *oauth2.Token) (*GitHubUser, error) {
+	client := s.config.Client(ctx, token)
+
+	// Get user info
+	resp, err := client.Get("https://api.github.com/user")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user info: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var user GitHubUser
+	if err := json.Unmarshal(body, &user); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal user info: %w", err)
+	}
+
+	// The public profile doesn't say whether the email is verified, so always check the emails endpoint
+	emailsResp, err := client.Get("https://api.github.com/user/emails")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user emails: %w", err)
+	}
+	defer emailsResp.Body.Close()
+
+	emailsBody, err := io.ReadAll(emailsResp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read emails response: %w", err)
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := json.Unmarshal(emailsBody, &emails); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal emails: %w", err)
+	}
+
+	// Prefer the public email if it is verified, then the primary verified email
+	publicEmail := user.Email
+	for _, email := range emails {
+		if email.Email == publicEmail && email.Verified {
+			user.EmailVerified = true
+			break
+		}
+	}
+	if !user.EmailVerified {
+		for _, email := range emails {
+			if email.Primary && email.Verified {
+				user.Email = email.Email
+				user.EmailVerified = true
+				break
+			}
+		}
+	}
+
+	return &user, nil
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+	modzip "golang.org/x/mod/zip"
+)
+
+var (
+	ErrGoModulePathMismatch = errors.New("the go.mod file of the version declares another module path")
+	ErrInvalidGoModuleZip   = errors.New("the version can't be packed as a module zip")
+)
+
+// GoImportPrefix returns the import path prefix of the repositories of the instance at publicURL,
+// such as hypercommit.example for https://hypercommit.example
+// Import paths can't have a port, so modules are only served from public URLs without one.
+func GoImportPrefix(publicURL string) string {
+	_, prefix, found := strings.Cut(publicURL, "://")
+	if !found {
+		prefix = publicURL
+	}
+	return strings.TrimSuffix(prefix, "/")
+}
+
+// GoModuleService serves the semantic version tags of repositories as versions of their Go module,
+// for the GOPROXY protocol. The module at the root of repository owner/repo has the path
+// <prefix>/owner/repo, or <prefix>/owner/repo/vN from major version 2 on.
+type GoModuleService interface {
+	// ParsePath returns the owner and name of the repository of a module path, ok is false if the
+	// module isn't hosted here
+	ParsePath(modulePath string) (owner, repo string, ok bool)
+	// Versions lists the versions of the module, oldest first
+	Versions(repo *models.Repository, modulePath string) ([]string, error)
+	// Latest returns the highest release of the module, or its highest pre-release if it has no
+	// releases, nil if it has no versions
+	Latest(repo *models.Repository, modulePath string) (*models.GoModuleVersion, error)
+	// Version returns the version of the module, nil if there is no such tag. It fails with
+	// ErrGoModulePathMismatch if the tag isn't a version of the module.
+	Version(repo *models.Repository, modulePath, version string) (*models.GoModuleVersion, error)
+	// Zip returns the path of the module zip of the version, building it on first use. It fails with
+	// ErrInvalidGoModuleZip if the files of the version don't make a valid module.
+	Zip(repo *models.Repository, modulePath string, version *models.GoModuleVersion) (string, error)
+	// DeleteRepository forgets the versions of a repository being deleted and removes their zips
+	DeleteRepository(repo *models.Repository) error
+}
+
+type goModuleService struct {
+	versions  repositories.GoModuleVersionsRepository
+	git       GitService
+	prefix    string
+	cachePath string
+}
+
+func NewGoModuleService(versions repositories.GoModuleVersionsRepository, git GitService, publicURL, cachePath string) GoModuleService {
+	return &goModuleService{
+		versions:  versions,
+		git:       git,
+		prefix:    GoImportPrefix(publicURL),
+		cachePath: cachePath,
+	}
+}
+
+func (s *goModuleService) ParsePath(modulePath string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(modulePath, s.prefix+"/")
+	if !ok {
+		return "", "", false
+	}
+	rest, _, ok = module.SplitPathVersion(rest)
+	if !ok {
+		return "", "", false
+	}
+
+	// Only modules at the root of repositories are served
+	owner, repo, ok := strings.Cut(rest, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return "", "", false
+	}
+	return owner, repo, true
+}
+
+func (s *goModuleService) Versions(repo *models.Repository, modulePath string) ([]string, error) {
+	_, pathMajor, _ := module.SplitPathVersion(modulePath)
+
+	refs, err := s.git.ListRefs(s.git.RepositoryPath(repo))
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for ref := range refs {
+		version, ok := strings.CutPrefix(ref, "refs/tags/")
+		if ok && isModuleVersion(version, pathMajor) {
+			versions = append(versions, version)
+		}
+	}
+	semver.Sort(versions)
+	return versions, nil
+}
+
+func (s *goModuleService) Latest(repo *models.Repository, modulePath string) (*models.GoModuleVersion, error) {
+	versions, err := s.Versions(repo, modulePath)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+
+	latest := versions[len(versions)-1]
+	for i := len(versions) - 1; i >= 0; i-- {
+		if semver.Prerelease(versions[i]) == "" {
+			latest = versions[i]
+			break
+		}
+	}
+	return s.Version(repo, modulePath, latest)
+}
+
+func (s *goModuleService) Version(repo *models.Repository, modulePath, version string) (*models.GoModuleVersion, error) {
+	_, pathMajor, _ := module.SplitPathVersion(modulePath)
+	if !isModuleVersion(version, pathMajor) {
+		return nil, nil
+	}
+
+	// Versions served before stay as they were
+	cached, err := s.versions.FindByVersion(repo.ID, version)
+	if err != nil {
+		return nil, err
+	}
+	if cached != nil {
+		if modfile.ModulePath([]byte(cached.GoMod)) != modulePath {
+			return nil, ErrGoModulePathMismatch
+		}
+		return cached, nil
+	}
+
+	repoPath := s.git.RepositoryPath(repo)
+	commit, err := s.git.ResolveCommit(repoPath, "refs/tags/"+version)
+	if err != nil || commit == "" {
+		return nil, err
+	}
+
+	goMod, err := s.git.GetFileContent(repoPath, commit, "go.mod")
+	if err != nil {
+		// Repositories without go.mod are modules of their path until v1, as for the go command
+		if pathMajor != "" {
+			return nil, ErrGoModulePathMismatch
+		}
+		goMod = []byte("module " + modfile.AutoQuote(modulePath) + "\n")
+	}
+	if modfile.ModulePath(goMod) != modulePath {
+		return nil, ErrGoModulePathMismatch
+	}
+
+	commits, err := s.git.ListCommits(repoPath, commit, nil, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("commit %s of tag %s not found", commit, version)
+	}
+
+	return s.versions.Create(&models.GoModuleVersion{
+		RepositoryID: repo.ID,
+		Version:      version,
+		CommitSHA:    commit,
+		GoMod:        string(goMod),
+		CommitTime:   commits[0].Timestamp,
+	})
+}
+
+func (s *goModuleService) Zip(repo *models.Repository, modulePath string, version *models.GoModuleVersion) (string, error) {
+	zipPath := filepath.Join(s.cachePath, strconv.FormatInt(repo.ID, 10), version.Version+".zip")
+	if version.ZipHash != nil {
+		if _, err := os.Stat(zipPath); err == nil {
+			return zipPath, nil
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(zipPath), 0o755); err != nil {
+		return "", err
+	}
+	hash, size, err := s.buildZip(repo, module.Version{Path: modulePath, Version: version.Version}, version.CommitSHA, zipPath)
+	if err != nil {
+		return "", err
+	}
+
+	// A rebuilt zip has to be the zip served before, or go.sum files out there stop matching it
+	if version.ZipHash != nil && *version.ZipHash != hash {
+		slog.Error("rebuilt Go module zip differs from the one served before", "repository", repo.ID, "version", version.Version, "hash", hash, "expected", *version.ZipHash)
+		os.Remove(zipPath)
+		return "", ErrInvalidGoModuleZip
+	}
+	if version.ZipHash == nil {
+		if err := s.versions.UpdateZip(version.ID, hash, size); err != nil {
+			return "", err
+		}
+		version.ZipHash, version.ZipSize = &hash, &size
+	}
+
+	return zipPath, nil
+}
+
+// buildZip packs the files of the commit as the module zip of the version at zipPath, returning its
+// checksum and size
+func (s *goModuleService) buildZip(repo *models.Repository, version module.Version, commit, zipPath string) (string, int64, error) {
+	dir := filepath.Dir(zipPath)
+
+	archive, err := os.CreateTemp(dir, "archive-*.zip")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	if err := s.git.ArchiveZip(s.git.RepositoryPath(repo), commit, archive); err != nil {
+		return "", 0, err
+	}
+	info, err := archive.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	files, err := zip.NewReader(archive, info.Size())
+	if err != nil {
+		return "", 0, err
+	}
+
+	var moduleFiles []modzip.File
+	for _, file := range files.File {
+		if !strings.HasSuffix(file.Name, "/") {
+			moduleFiles = append(moduleFiles, archiveFile{file})
+		}
+	}
+
+	out, err := os.CreateTemp(dir, "module-*.zip")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if err := modzip.Create(out, version, moduleFiles); err != nil {
+		return "", 0, fmt.Errorf("%w: %v", ErrInvalidGoModuleZip, err)
+	}
+	size, err := out.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := out.Close(); err != nil {
+		return "", 0, err
+	}
+
+	hash, err := dirhash.HashZip(out.Name(), dirhash.Hash1)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(out.Name(), zipPath); err != nil {
+		return "", 0, err
+	}
+	return hash, size, nil
+}
+
+func (s *goModuleService) DeleteRepository(repo *models.Repository) error {
+	if err := s.versions.DeleteAllByRepository(repo.ID); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(s.cachePath, strconv.FormatInt(repo.ID, 10)))
+}
+
+// isModuleVersion reports whether version is a canonical semantic version of a module whose path
+// ends in pathMajor, such as /v2
+func isModuleVersion(version, pathMajor string) bool {
+	return semver.IsValid(version) && semver.Canonical(version) == version && module.CheckPathMajor(version, pathMajor) == nil
+}
+
+// archiveFile is a file of a git archive as a file of a module zip
+type archiveFile struct {
+	file *zip.File
+}
+
+func (f archiveFile) Path() string {
+	return f.file.Name
+}
+
+func (f archiveFile) Lstat() (os.FileInfo, error) {
+	return f.file.FileInfo(), nil
+}
+
+func (f archiveFile) Open() (io.ReadCloser, error) {
+	return f.file.Open()
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database"
+)
+
+// newTestDB opens an empty in-memory database with the full schema
+func newTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.New(":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// commitFiles commits the files, by path, to the main branch of the bare repository at repoPath and
+// returns the ID of the commit
+func commitFiles(t *testing.T, repoPath string, files map[string]string) string {
+	t.Helper()
+
+	work := t.TempDir()
+	git := func(args ...string) string {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=Alice", "-c", "user.email=alice@example.com", "-c", "commit.gpgsign=false"}, args...)...)
+		cmd.Dir = work
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "--quiet", "--initial-branch=main")
+	for name, content := range files {
+		path := filepath.Join(work, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("create %s: %v", filepath.Dir(name), err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	git("add", ".")
+	git("commit", "--quiet", "-m", "Add files")
+	git("push", "--quiet", repoPath, "HEAD:refs/heads/main")
+	return git("rev-parse", "HEAD")
+}
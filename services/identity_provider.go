@@ -0,0 +1,36 @@
+package services
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrIdentityNotAllowed is returned by Identify when the provider's policy keeps the account from signing in
+var ErrIdentityNotAllowed = errors.New("identity is not allowed to sign in")
+
+// ExternalIdentity is who signed in at an identity provider
+type ExternalIdentity struct {
+	// Subject is the provider's stable ID of the account, usernames and emails can change
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Name          string
+	Groups        []string
+	// IsAdmin is set by providers that manage who administers the instance, nil otherwise
+	IsAdmin *bool
+}
+
+// IdentityProvider signs users in with their account at another service, using the
+// OAuth 2.0 authorization code flow with PKCE
+type IdentityProvider interface {
+	// ID identifies the provider in URLs and in the identities linked to users
+	ID() string
+	// Name is shown on the sign-in button
+	Name() string
+	// AuthURL returns where to send the user to sign in. verifier is the PKCE code verifier
+	// and nonce is bound to the ID token by OpenID Connect providers.
+	AuthURL(ctx context.Context, state, verifier, nonce string) (string, error)
+	// Identify exchanges the authorization code and returns who signed in
+	Identify(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error)
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// headerSanitizer strips line breaks so header values can't inject additional headers
+var headerSanitizer = strings.NewReplacer("\r", "", "\n", "")
+
+// Message is a single email with a plain text and an optional HTML body
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers messages synchronously. Handlers should not call it directly,
+// they enqueue messages through the EmailService outbox instead.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Bytes renders the message as an RFC 5322 message with a multipart/alternative body
+func (m Message) Bytes(from string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	messageID, err := newMessageID(from)
+	if err != nil {
+		return nil, err
+	}
+
+	headers := []struct{ key, value string }{
+		{"From", from},
+		{"To", m.To},
+		{"Subject", mime.QEncoding.Encode("utf-8", m.Subject)},
+		{"Date", time.Now().UTC().Format(time.RFC1123Z)},
+		{"Message-ID", messageID},
+		{"MIME-Version", "1.0"},
+	}
+	for _, header := range headers {
+		fmt.Fprintf(&buf, "%s: %s\r\n", header.key, headerSanitizer.Replace(header.value))
+	}
+
+	writer := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", writer.Boundary())
+
+	parts := []struct{ contentType, body string }{{"text/plain; charset=utf-8", m.Text}}
+	if m.HTML != "" {
+		parts = append(parts, struct{ contentType, body string }{"text/html; charset=utf-8", m.HTML})
+	}
+
+	for _, part := range parts {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func newMessageID(from string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	domain := "localhost"
+	if addr, err := mail.ParseAddress(from); err == nil {
+		if _, d, ok := strings.Cut(addr.Address, "@"); ok {
+			domain = d
+		}
+	}
+
+	return "<" + hex.EncodeToString(b) + "@" + domain + ">", nil
+}
+
+// envelopeAddress extracts the bare address from "Name <address>"
+func envelopeAddress(address string) (string, error) {
+	parsed, err := mail.ParseAddress(address)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", address, err)
+	}
+	return parsed.Address, nil
+}
+
+type smtpMailer struct {
+	addr     string
+	host     string
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer sends mail through an SMTP server. STARTTLS is used when the
+// server offers it, and authentication only when a username is configured.
+func NewSMTPMailer(host, port, username, password, from string) Mailer {
+	return &smtpMailer{
+		addr:     net.JoinHostPort(host, port),
+		host:     host,
+		username: username,
+		password: password,
+		from:     from,
+	}
+}
+
+func (m *smtpMailer) Send(ctx context.Context, msg Message) error {
+	data, err := msg.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	sender, err := envelopeAddress(m.from)
+	if err != nil {
+		return err
+	}
+	recipient, err := envelopeAddress(msg.To)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+
+	// smtp.SendMail has no context support, run it in the background and give up when ctx is done
+	done := make(chan error, 1)
+	go func() {
+		done <- smtp.SendMail(m.addr, auth, sender, []string{recipient}, data)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type fileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer writes every message as an .eml file into dir, useful in development
+func NewFileMailer(dir, from string) Mailer {
+	return &fileMailer{
+		dir:  dir,
+		from: from,
+	}
+}
+
+func (m *fileMailer) Send(ctx context.Context, msg Message) error {
+	data, err := msg.Bytes(m.from)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o755); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().UTC().Format("20060102T150405"), hex.EncodeToString(suffix))
+	path := filepath.Join(m.dir, name)
+
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return err
+	}
+
+	slog.Info("email written to file", "to", msg.To, "subject", msg.Subject, "path", path)
+	return nil
+}
+
+type logMailer struct{}
+
+// NewLogMailer delivers nothing, it only logs the recipient and subject of messages. Bodies are
+// left out as they carry sign-in links and tokens; use the file mailer to read them in development.
+func NewLogMailer() Mailer {
+	return &logMailer{}
+}
+
+func (m *logMailer) Send(ctx context.Context, msg Message) error {
+	slog.Warn("email not delivered, no mail transport is configured", "to", msg.To, "subject", msg.Subject)
+	return nil
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+// mentionRegex matches @username not preceded by a word character, so e-mail addresses are ignored
+var mentionRegex = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9][A-Za-z0-9_.-]*[A-Za-z0-9_]|[A-Za-z0-9])`)
+
+type NotificationService interface {
+	TicketOpened(ticket *models.Ticket, author *models.User)
+	TicketAssigned(ticket *models.Ticket, assigneeID int64, actor *models.User)
+	CommentCreated(ticket *models.Ticket, comment *models.TicketComment, actor *models.User)
+	TicketStatusChanged(ticket *models.Ticket, actor *models.User)
+}
+
+type notificationService struct {
+	notifications repositories.NotificationsRepository
+	users         repositories.UsersRepository
+	userEmails    repositories.UserEmailsRepository
+	repos         repositories.RepositoriesRepository
+	orgs          repositories.OrganizationsRepository
+	orgMembers    repositories.OrganizationMembersRepository
+	contributors  repositories.ContributorsRepository
+	twoFactor     TwoFactorService
+	emails        EmailService
+	publicURL     string
+}
+
+func NewNotificationService(
+	notifications repositories.NotificationsRepository,
+	users repositories.UsersRepository,
+	userEmails repositories.UserEmailsRepository,
+	repos repositories.RepositoriesRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	twoFactor TwoFactorService,
+	emails EmailService,
+	publicURL string,
+) NotificationService {
+	return &notificationService{
+		notifications: notifications,
+		users:         users,
+		userEmails:    userEmails,
+		repos:         repos,
+		orgs:          orgs,
+		orgMembers:    orgMembers,
+		contributors:  contributors,
+		twoFactor:     twoFactor,
+		emails:        emails,
+		publicURL:     publicURL,
+	}
+}
+
+// TicketOpened subscribes the author and notifies everyone mentioned in the ticket
+func (s *notificationService) TicketOpened(ticket *models.Ticket, author *models.User) {
+	repo := s.repository(ticket)
+	if repo == nil {
+		return
+	}
+
+	s.subscribe(ticket.ID, author.ID, "author")
+
+	body := ticket.Title
+	if ticket.Body != nil {
+		body += "\n" + *ticket.Body
+	}
+	for _, userID := range s.mentionedUserIDs(repo, body, author.ID) {
+		s.subscribe(ticket.ID, userID, "mention")
+		s.notify(repo, userID, ticket, author, nil, "mention")
+	}
+}
+
+func (s *notificationService) TicketAssigned(ticket *models.Ticket, assigneeID int64, actor *models.User) {
+	repo := s.repository(ticket)
+	if repo == nil {
+		return
+	}
+	assignee, err := s.users.FindByID(assigneeID)
+	if err != nil || assignee == nil || !s.canRead(repo, assignee) {
+		return
+	}
+
+	s.subscribe(ticket.ID, assigneeID, "assigned")
+	if assigneeID != actor.ID {
+		s.notify(repo, assigneeID, ticket, actor, nil, "assigned")
+	}
+}
+
+// CommentCreated subscribes the commenter and any mentioned users, then notifies all subscribers.
+// Mentioned users get a "mention" notification instead of a plain "comment" one.
+func (s *notificationService) CommentCreated(ticket *models.Ticket, comment *models.TicketComment, actor *models.User) {
+	repo := s.repository(ticket)
+	if repo == nil {
+		return
+	}
+
+	s.subscribe(ticket.ID, actor.ID, "comment")
+
+	mentioned := make(map[int64]bool)
+	for _, userID := range s.mentionedUserIDs(repo, comment.Body, actor.ID) {
+		s.subscribe(ticket.ID, userID, "mention")
+		mentioned[userID] = true
+	}
+
+	subscriberIDs, err := s.notifications.FindSubscriberIDs(ticket.ID)
+	if err != nil {
+		slog.Error("failed to fetch ticket subscribers", "error", err, "ticket_id", ticket.ID)
+		return
+	}
+
+	for _, userID := range subscriberIDs {
+		if userID == actor.ID {
+			continue
+		}
+		reason := "comment"
+		if mentioned[userID] {
+			reason = "mention"
+		}
+		s.notify(repo, userID, ticket, actor, comment, reason)
+	}
+}
+
+// TicketStatusChanged notifies subscribers that the ticket was closed or reopened
+func (s *notificationService) TicketStatusChanged(ticket *models.Ticket, actor *models.User) {
+	repo := s.repository(ticket)
+	if repo == nil {
+		return
+	}
+
+	reason := "reopened"
+	if ticket.Status == "closed" {
+		reason = "closed"
+	}
+
+	subscriberIDs, err := s.notifications.FindSubscriberIDs(ticket.ID)
+	if err != nil {
+		slog.Error("failed to fetch ticket subscribers", "error", err, "ticket_id", ticket.ID)
+		return
+	}
+
+	for _, userID := range subscriberIDs {
+		if userID != actor.ID {
+			s.notify(repo, userID, ticket, actor, nil, reason)
+		}
+	}
+}
+
+func (s *notificationService) subscribe(ticketID, userID int64, reason string) {
+	if err := s.notifications.Subscribe(ticketID, userID, reason); err != nil {
+		slog.Error("failed to subscribe to ticket", "error", err, "ticket_id", ticketID, "user_id", userID)
+	}
+}
+
+// notify creates an in-app notification and emails it to the verified primary address of the
+// recipient. Users who can't read the repository, e.g. subscribers who lost access, are skipped.
+func (s *notificationService) notify(repo *models.Repository, userID int64, ticket *models.Ticket, actor *models.User, comment *models.TicketComment, reason string) {
+	recipient, err := s.users.FindByID(userID)
+	if err != nil || recipient == nil || !s.canRead(repo, recipient) {
+		return
+	}
+
+	var commentID *int64
+	if comment != nil {
+		commentID = &comment.ID
+	}
+
+	if _, err := s.notifications.Create(userID, ticket.ID, actor.ID, commentID, reason); err != nil {
+		slog.Error("failed to create notification", "error", err, "ticket_id", ticket.ID, "user_id", userID)
+		return
+	}
+
+	address, err := s.userEmails.FindVerifiedPrimaryByUser(userID)
+	if err != nil {
+		slog.Error("failed to find notification email address", "error", err, "user_id", userID)
+		return
+	}
+	if address == nil {
+		return
+	}
+
+	repoName := s.repositoryFullName(repo)
+	if repoName == "" {
+		return
+	}
+
+	email := TicketNotificationEmail{
+		Repository:   repoName,
+		TicketNumber: ticket.Number,
+		TicketTitle:  ticket.Title,
+		URL:          fmt.Sprintf("%s/%s/tickets/%d", s.publicURL, repoName, ticket.Number),
+	}
+	if comment != nil {
+		email.Body = comment.Body
+	}
+
+	switch reason {
+	case "mention":
+		email.Summary = actor.Username + " mentioned you on this ticket."
+		email.Reason = "you were mentioned"
+	case "assigned":
+		email.Summary = actor.Username + " assigned this ticket to you."
+		email.Reason = "you were assigned"
+	case "closed":
+		email.Summary = actor.Username + " closed this ticket."
+		email.Reason = "you are subscribed to this ticket"
+	case "reopened":
+		email.Summary = actor.Username + " reopened this ticket."
+		email.Reason = "you are subscribed to this ticket"
+	default:
+		email.Summary = actor.Username + " commented on this ticket."
+		email.Reason = "you are subscribed to this ticket"
+	}
+
+	if err := s.emails.SendTicketNotification(address.Email, email); err != nil {
+		slog.Error("failed to queue notification email", "error", err, "ticket_id", ticket.ID, "user_id", userID)
+	}
+}
+
+// repository returns the repository of the ticket, nil if it can't be found
+func (s *notificationService) repository(ticket *models.Ticket) *models.Repository {
+	repo, err := s.repos.FindByID(ticket.RepositoryID)
+	if err != nil {
+		slog.Error("failed to find ticket repository", "error", err, "ticket_id", ticket.ID)
+	}
+	return repo
+}
+
+// canRead reports whether the user may read the repository: anyone may read public ones, owners,
+// organization members and contributors private ones, as long as they have two-factor
+// authentication if the organization requires it. Suspended users read nothing.
+func (s *notificationService) canRead(repo *models.Repository, user *models.User) bool {
+	if user.SuspendedAt != nil {
+		return false
+	}
+	if repo.Visibility == "public" || (repo.OwnerUserID != nil && *repo.OwnerUserID == user.ID) {
+		return true
+	}
+
+	member := false
+	if repo.OwnerOrgID != nil {
+		membership, err := s.orgMembers.FindByOrganizationAndUser(*repo.OwnerOrgID, user.ID)
+		if err != nil {
+			slog.Error("failed to find organization membership", "error", err, "repository_id", repo.ID, "user_id", user.ID)
+			return false
+		}
+		member = membership != nil
+	}
+	if !member {
+		contributor, err := s.contributors.FindByRepositoryAndUser(repo.ID, user.ID)
+		if err != nil {
+			slog.Error("failed to find contributor", "error", err, "repository_id", repo.ID, "user_id", user.ID)
+			return false
+		}
+		member = contributor != nil
+	}
+	if !member || repo.OwnerOrgID == nil {
+		return member
+	}
+
+	org, err := s.orgs.FindByID(*repo.OwnerOrgID)
+	if err != nil || org == nil {
+		return false
+	}
+	if !org.RequireTwoFactor {
+		return true
+	}
+	enabled, err := s.twoFactor.IsEnabled(user.ID)
+	if err != nil {
+		slog.Error("failed to check two-factor authentication", "error", err, "user_id", user.ID)
+	}
+	return enabled
+}
+
+// repositoryFullName returns "owner/name" for a repository, or an empty string if it can't be resolved
+func (s *notificationService) repositoryFullName(repo *models.Repository) string {
+	if repo.OwnerUserID != nil {
+		owner, err := s.users.FindByID(*repo.OwnerUserID)
+		if err == nil && owner != nil {
+			return owner.Username + "/" + repo.Name
+		}
+	} else if repo.OwnerOrgID != nil {
+		owner, err := s.orgs.FindByID(*repo.OwnerOrgID)
+		if err == nil && owner != nil {
+			return owner.Username + "/" + repo.Name
+		}
+	}
+
+	return ""
+}
+
+// mentionedUserIDs resolves the @mentions in text to existing users who can read the repository,
+// skipping the author
+func (s *notificationService) mentionedUserIDs(repo *models.Repository, text string, authorID int64) []int64 {
+	var userIDs []int64
+	for _, username := range parseMentions(text) {
+		user, err := s.users.FindByUsername(username)
+		if err != nil || user == nil || user.ID == authorID || !s.canRead(repo, user) {
+			continue
+		}
+		userIDs = append(userIDs, user.ID)
+	}
+	return userIDs
+}
+
+// parseMentions returns the distinct usernames mentioned in text, in order of appearance
+func parseMentions(text string) []string {
+	seen := make(map[string]bool)
+	var usernames []string
+	for _, match := range mentionRegex.FindAllStringSubmatch(text, -1) {
+		key := strings.ToLower(match[1])
+		if !seen[key] {
+			seen[key] = true
+			usernames = append(usernames, match[1])
+		}
+	}
+	return usernames
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+func TestNotificationsOnlyReachUsersWhoCanReadTheRepository(t *testing.T) {
+	db := newTestDB(t)
+	users := repositories.NewUsersRepository(db.DB)
+	userEmails := repositories.NewUserEmailsRepository(db.DB)
+	repos := repositories.NewRepositoriesRepository(db.DB)
+	tickets := repositories.NewTicketsRepository(db.DB)
+	emails, err := NewEmailService(repositories.NewEmailOutboxRepository(db.DB), "http://localhost:8080")
+	if err != nil {
+		t.Fatalf("email service: %v", err)
+	}
+	notifier := NewNotificationService(
+		repositories.NewNotificationsRepository(db.DB),
+		users,
+		userEmails,
+		repos,
+		repositories.NewOrganizationsRepository(db.DB),
+		repositories.NewOrganizationMembersRepository(db.DB),
+		repositories.NewContributorsRepository(db.DB),
+		NewTwoFactorService(repositories.NewTwoFactorRepository(db.DB)),
+		emails,
+		"http://localhost:8080",
+	)
+
+	accounts := map[string]*models.User{}
+	for _, username := range []string{"alice", "reader", "unverified", "outsider"} {
+		user, err := users.Create(username, username+"@example.com", username, "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		if _, err := userEmails.Create(user.ID, user.Email, true, username != "unverified"); err != nil {
+			t.Fatalf("create email: %v", err)
+		}
+		accounts[username] = user
+	}
+	// A verified address that isn't the primary one doesn't get mail either
+	if _, err := userEmails.Create(accounts["unverified"].ID, "backup@example.com", false, true); err != nil {
+		t.Fatalf("create email: %v", err)
+	}
+
+	repo, err := repos.CreateForUser(accounts["alice"].ID, "secret", "private", "main", nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+	for _, username := range []string{"reader", "unverified"} {
+		mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'read')`, repo.ID, accounts[username].ID)
+	}
+
+	body := "Ping @reader @unverified @outsider"
+	ticket, err := tickets.Create(repo.ID, accounts["alice"].ID, "Secret plans", &body)
+	if err != nil {
+		t.Fatalf("create ticket: %v", err)
+	}
+	notifier.TicketOpened(ticket, accounts["alice"])
+	comment, err := tickets.CreateComment(ticket.ID, accounts["alice"].ID, "@outsider have a look")
+	if err != nil {
+		t.Fatalf("create comment: %v", err)
+	}
+	notifier.CommentCreated(ticket, comment, accounts["alice"])
+	notifier.TicketAssigned(ticket, accounts["outsider"].ID, accounts["alice"])
+
+	// A subscriber who lost access isn't notified anymore
+	mustExec(t, db, `INSERT OR IGNORE INTO ticket_subscriptions (ticket_id, user_id, reason) VALUES (?, ?, 'manual')`, ticket.ID, accounts["outsider"].ID)
+	ticket.Status = "closed"
+	notifier.TicketStatusChanged(ticket, accounts["alice"])
+
+	for _, tt := range []struct {
+		username      string
+		subscriptions int
+		notifications int
+	}{
+		{"reader", 1, 3},
+		{"unverified", 1, 3},
+		{"outsider", 1, 0},
+	} {
+		userID := accounts[tt.username].ID
+		if got := countRows(t, db, "ticket_subscriptions", "user_id = ?", userID); got != tt.subscriptions {
+			t.Errorf("%s has %d subscriptions, want %d", tt.username, got, tt.subscriptions)
+		}
+		if got := countRows(t, db, "notifications", "user_id = ?", userID); got != tt.notifications {
+			t.Errorf("%s has %d notifications, want %d", tt.username, got, tt.notifications)
+		}
+	}
+	if got := countRows(t, db, "notifications", "user_id = ? AND reason = 'mention'", accounts["reader"].ID); got != 1 {
+		t.Errorf("reader has %d mention notifications, want 1", got)
+	}
+
+	if got := countRows(t, db, "email_outbox", "to_address = ?", "reader@example.com"); got != 3 {
+		t.Errorf("reader got %d emails, want 3", got)
+	}
+	if got := countRows(t, db, "email_outbox", "to_address <> ?", "reader@example.com"); got != 0 {
+		t.Errorf("%d emails went to users without access or a verified primary address", got)
+	}
+}
@@ -0,0 +1,612 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+// OAuthCLIClientID is the client ID of the Hypercommit CLI. It is built in rather than registered,
+// and gets personal access tokens that don't expire because Git stores them as passwords.
+const OAuthCLIClientID = "hypercommit-cli"
+
+// OAuthDeviceCodeInterval is how many seconds devices wait between polls for their tokens
+const OAuthDeviceCodeInterval = 5
+
+// Prefixes of the secrets handed out to OAuth applications, so leaked ones are easy to spot
+const (
+	oauthAccessTokenPrefix  = "hc_oat_"
+	oauthRefreshTokenPrefix = "hc_ort_"
+	oauthClientSecretPrefix = "hc_ocs_"
+)
+
+const (
+	oauthAccessTokenTTL       = time.Hour
+	oauthRefreshTokenTTL      = 30 * 24 * time.Hour
+	oauthAuthorizationCodeTTL = 10 * time.Minute
+	OAuthDeviceCodeTTL        = 10 * time.Minute
+)
+
+// OAuth error codes of RFC 6749 and RFC 8628
+const (
+	OAuthErrorInvalidRequest       = "invalid_request"
+	OAuthErrorInvalidClient        = "invalid_client"
+	OAuthErrorInvalidGrant         = "invalid_grant"
+	OAuthErrorUnauthorizedClient   = "unauthorized_client"
+	OAuthErrorUnsupportedGrantType = "unsupported_grant_type"
+	OAuthErrorInvalidScope         = "invalid_scope"
+	OAuthErrorAccessDenied         = "access_denied"
+	OAuthErrorAuthorizationPending = "authorization_pending"
+	OAuthErrorSlowDown             = "slow_down"
+	OAuthErrorExpiredToken         = "expired_token"
+)
+
+// oauthCLIApplication is the built-in application of the Hypercommit CLI. Its ID is 0,
+// grants to it are stored without application.
+var oauthCLIApplication = &models.OAuthApplication{
+	Name:     "Hypercommit CLI",
+	ClientID: OAuthCLIClientID,
+}
+
+// OAuthError is an error response of the token endpoint, RFC 6749 section 5.2
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthTokenResponse is a successful response of the token endpoint
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+	// Username is who the token acts for. It isn't part of OAuth, clients need it as Git username.
+	Username string `json:"username"`
+}
+
+// OAuthIntrospection is the response of the introspection endpoint, RFC 7662
+type OAuthIntrospection struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	ClientID  string `json:"client_id,omitempty"`
+	Username  string `json:"username,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	Subject   string `json:"sub,omitempty"`
+}
+
+// OAuthService implements Hypercommit's OAuth 2.0 authorization server: the authorization code
+// grant with PKCE, refresh tokens, the device authorization grant, revocation and introspection.
+// Access tokens are access tokens like personal ones, with the same scopes.
+type OAuthService interface {
+	// FindClient returns the application with the client ID, the CLI included, or nil
+	FindClient(clientID string) (*models.OAuthApplication, error)
+	// AuthenticateClient returns the client of a token, revocation or introspection request.
+	// Confidential clients authenticate with HTTP Basic or client_secret in the form,
+	// public clients only send their client_id.
+	AuthenticateClient(r *http.Request) (*models.OAuthApplication, error)
+	IsCLI(application *models.OAuthApplication) bool
+	// NewClientCredentials generates the client ID and secret of a new application
+	NewClientCredentials() (clientID, clientSecret, clientSecretHash string, err error)
+	// NewClientSecret generates a secret to replace the one of an application
+	NewClientSecret() (clientSecret, clientSecretHash string, err error)
+	// ParseScopes parses a space-separated scope parameter into access token scopes
+	ParseScopes(scope string) ([]string, error)
+	// CreateAuthorizationCode issues a code for the user's consent. codeChallenge is the PKCE S256 challenge.
+	CreateAuthorizationCode(application *models.OAuthApplication, user *models.User, redirectURI string, scopes []string, codeChallenge string) (string, error)
+	ExchangeAuthorizationCode(application *models.OAuthApplication, code, redirectURI, codeVerifier string) (*OAuthTokenResponse, error)
+	// Refresh exchanges a refresh token for new tokens. scope may narrow the scopes of the access token.
+	Refresh(application *models.OAuthApplication, refreshToken, scope string) (*OAuthTokenResponse, error)
+	// CreateDeviceCode starts a device authorization grant and returns the device code
+	CreateDeviceCode(application *models.OAuthApplication, scopes []string) (string, *models.OAuthDeviceCode, error)
+	ExchangeDeviceCode(application *models.OAuthApplication, deviceCode string) (*OAuthTokenResponse, error)
+	// Revoke revokes an access or refresh token issued to the application. Unknown tokens are ignored.
+	Revoke(application *models.OAuthApplication, token string) error
+	Introspect(application *models.OAuthApplication, token string) (*OAuthIntrospection, error)
+	// RevokeAuthorization revokes every token the user granted the application
+	RevokeAuthorization(applicationID, userID int64) error
+}
+
+type oauthService struct {
+	applications   repositories.OAuthApplicationsRepository
+	codes          repositories.OAuthAuthorizationCodesRepository
+	refreshTokens  repositories.OAuthRefreshTokensRepository
+	deviceCodes    repositories.OAuthDeviceCodesRepository
+	accessTokens   repositories.AccessTokensRepository
+	users          repositories.UsersRepository
+	personalTokens AccessTokenService
+}
+
+func NewOAuthService(
+	applications repositories.OAuthApplicationsRepository,
+	codes repositories.OAuthAuthorizationCodesRepository,
+	refreshTokens repositories.OAuthRefreshTokensRepository,
+	deviceCodes repositories.OAuthDeviceCodesRepository,
+	accessTokens repositories.AccessTokensRepository,
+	users repositories.UsersRepository,
+	personalTokens AccessTokenService,
+) OAuthService {
+	return &oauthService{
+		applications:   applications,
+		codes:          codes,
+		refreshTokens:  refreshTokens,
+		deviceCodes:    deviceCodes,
+		accessTokens:   accessTokens,
+		users:          users,
+		personalTokens: personalTokens,
+	}
+}
+
+func (s *oauthService) FindClient(clientID string) (*models.OAuthApplication, error) {
+	if clientID == OAuthCLIClientID {
+		return oauthCLIApplication, nil
+	}
+	return s.applications.FindByClientID(clientID)
+}
+
+func (s *oauthService) AuthenticateClient(r *http.Request) (*models.OAuthApplication, error) {
+	clientID, clientSecret, basic := r.BasicAuth()
+	if !basic {
+		clientID = r.PostFormValue("client_id")
+		clientSecret = r.PostFormValue("client_secret")
+	}
+	if clientID == "" {
+		return nil, oauthError(OAuthErrorInvalidClient, "client_id is required")
+	}
+
+	application, err := s.FindClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if application == nil {
+		return nil, oauthError(OAuthErrorInvalidClient, "unknown client")
+	}
+
+	if application.Confidential {
+		secretHash := hashAccessToken(clientSecret)
+		if clientSecret == "" || subtle.ConstantTimeCompare([]byte(secretHash), []byte(application.ClientSecretHash)) != 1 {
+			return nil, oauthError(OAuthErrorInvalidClient, "invalid client credentials")
+		}
+	}
+
+	return application, nil
+}
+
+func (s *oauthService) IsCLI(application *models.OAuthApplication) bool {
+	return application.ClientID == OAuthCLIClientID
+}
+
+func (s *oauthService) NewClientCredentials() (string, string, string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	clientID := fmt.Sprintf("%x", b)
+
+	clientSecret, clientSecretHash, err := s.NewClientSecret()
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return clientID, clientSecret, clientSecretHash, nil
+}
+
+func (s *oauthService) NewClientSecret() (string, string, error) {
+	clientSecret, err := randomOAuthToken(oauthClientSecretPrefix)
+	if err != nil {
+		return "", "", err
+	}
+	return clientSecret, hashAccessToken(clientSecret), nil
+}
+
+func (s *oauthService) ParseScopes(scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return nil, oauthError(OAuthErrorInvalidScope, "scope is required")
+	}
+
+	for _, name := range requested {
+		if !slices.Contains(models.AccessTokenScopes, name) {
+			return nil, oauthError(OAuthErrorInvalidScope, fmt.Sprintf("unknown scope %q", name))
+		}
+	}
+
+	// In the usual order and without duplicates
+	var scopes []string
+	for _, name := range models.AccessTokenScopes {
+		if slices.Contains(requested, name) {
+			scopes = append(scopes, name)
+		}
+	}
+	return scopes, nil
+}
+
+func (s *oauthService) CreateAuthorizationCode(application *models.OAuthApplication, user *models.User, redirectURI string, scopes []string, codeChallenge string) (string, error) {
+	code, err := randomOAuthToken("")
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	if err := s.codes.DeleteExpired(now.Unix()); err != nil {
+		return "", err
+	}
+
+	expiresAt := now.Add(oauthAuthorizationCodeTTL).Unix()
+	if _, err := s.codes.Create(hashAccessToken(code), application.ID, user.ID, redirectURI, scopes, codeChallenge, expiresAt); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+func (s *oauthService) ExchangeAuthorizationCode(application *models.OAuthApplication, code, redirectURI, codeVerifier string) (*OAuthTokenResponse, error) {
+	if code == "" {
+		return nil, oauthError(OAuthErrorInvalidRequest, "code is required")
+	}
+	if codeVerifier == "" {
+		return nil, oauthError(OAuthErrorInvalidRequest, "code_verifier is required")
+	}
+
+	// The code is used up even if the request turns out to be invalid
+	authorization, err := s.codes.Consume(hashAccessToken(code))
+	if err != nil {
+		return nil, err
+	}
+	if authorization == nil || authorization.ApplicationID != application.ID || time.Now().Unix() >= authorization.ExpiresAt {
+		return nil, oauthError(OAuthErrorInvalidGrant, "invalid or expired code")
+	}
+	if redirectURI != authorization.RedirectURI {
+		return nil, oauthError(OAuthErrorInvalidGrant, "redirect_uri doesn't match the authorization request")
+	}
+
+	challenge := sha256.Sum256([]byte(codeVerifier))
+	if base64.RawURLEncoding.EncodeToString(challenge[:]) != authorization.CodeChallenge {
+		return nil, oauthError(OAuthErrorInvalidGrant, "code_verifier doesn't match the code challenge")
+	}
+
+	user, err := s.activeUser(authorization.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(application, user, authorization.Scopes, authorization.Scopes)
+}
+
+func (s *oauthService) Refresh(application *models.OAuthApplication, refreshToken, scope string) (*OAuthTokenResponse, error) {
+	if refreshToken == "" {
+		return nil, oauthError(OAuthErrorInvalidRequest, "refresh_token is required")
+	}
+
+	tokenHash := hashAccessToken(refreshToken)
+	grant, err := s.refreshTokens.FindByTokenHash(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if grant == nil || grant.ApplicationID != application.ID || time.Now().Unix() >= grant.ExpiresAt {
+		return nil, oauthError(OAuthErrorInvalidGrant, "invalid or expired refresh token")
+	}
+
+	// The access token may get fewer scopes, the new refresh token keeps those of the grant
+	scopes := grant.Scopes
+	if scope != "" {
+		scopes, err = s.ParseScopes(scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range scopes {
+			if !slices.Contains(grant.Scopes, name) {
+				return nil, oauthError(OAuthErrorInvalidScope, fmt.Sprintf("scope %q wasn't granted", name))
+			}
+		}
+	}
+
+	user, err := s.activeUser(grant.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refresh tokens are rotated, a token that was already used is gone
+	consumed, err := s.refreshTokens.Consume(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if consumed == nil {
+		return nil, oauthError(OAuthErrorInvalidGrant, "invalid or expired refresh token")
+	}
+
+	return s.issueTokens(application, user, scopes, grant.Scopes)
+}
+
+func (s *oauthService) CreateDeviceCode(application *models.OAuthApplication, scopes []string) (string, *models.OAuthDeviceCode, error) {
+	deviceCode, err := randomOAuthToken("")
+	if err != nil {
+		return "", nil, err
+	}
+
+	userCode, err := generateUserCode()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	if err := s.deviceCodes.DeleteExpired(now.Unix()); err != nil {
+		return "", nil, err
+	}
+
+	var applicationID *int64
+	if !s.IsCLI(application) {
+		applicationID = &application.ID
+	}
+
+	created, err := s.deviceCodes.Create(hashAccessToken(deviceCode), userCode, applicationID, scopes, now.Add(OAuthDeviceCodeTTL).Unix())
+	if err != nil {
+		return "", nil, err
+	}
+
+	return deviceCode, created, nil
+}
+
+func (s *oauthService) ExchangeDeviceCode(application *models.OAuthApplication, deviceCode string) (*OAuthTokenResponse, error) {
+	if deviceCode == "" {
+		return nil, oauthError(OAuthErrorInvalidRequest, "device_code is required")
+	}
+
+	grant, err := s.deviceCodes.FindByDeviceCodeHash(hashAccessToken(deviceCode))
+	if err != nil {
+		return nil, err
+	}
+	if grant == nil || !s.deviceCodeBelongsTo(grant, application) {
+		return nil, oauthError(OAuthErrorInvalidGrant, "invalid device code")
+	}
+
+	now := time.Now().Unix()
+	if now >= grant.ExpiresAt {
+		if err := s.deviceCodes.Delete(grant.ID); err != nil {
+			return nil, err
+		}
+		return nil, oauthError(OAuthErrorExpiredToken, "the device code has expired")
+	}
+
+	switch grant.Status {
+	case models.OAuthDeviceCodeDenied:
+		if err := s.deviceCodes.Delete(grant.ID); err != nil {
+			return nil, err
+		}
+		return nil, oauthError(OAuthErrorAccessDenied, "the user denied the request")
+
+	case models.OAuthDeviceCodePending:
+		// Timestamps are in seconds, so a poll up to a second early is still in time
+		tooSoon := grant.LastPolledAt != nil && now-*grant.LastPolledAt < OAuthDeviceCodeInterval-1
+		if err := s.deviceCodes.UpdateLastPolled(grant.ID); err != nil {
+			return nil, err
+		}
+		if tooSoon {
+			return nil, oauthError(OAuthErrorSlowDown, "")
+		}
+		return nil, oauthError(OAuthErrorAuthorizationPending, "")
+	}
+
+	// Approved, the tokens are only handed out once
+	if err := s.deviceCodes.Delete(grant.ID); err != nil {
+		return nil, err
+	}
+
+	user, err := s.activeUser(*grant.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.IsCLI(application) {
+		tokenName := fmt.Sprintf("CLI Device Auth - %s", time.Now().Format("2006-01-02 15:04:05"))
+		rawToken, _, err := s.personalTokens.Create(user.ID, tokenName, grant.Scopes, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &OAuthTokenResponse{
+			AccessToken: rawToken,
+			TokenType:   "bearer",
+			Scope:       strings.Join(grant.Scopes, " "),
+			Username:    user.Username,
+		}, nil
+	}
+
+	return s.issueTokens(application, user, grant.Scopes, grant.Scopes)
+}
+
+func (s *oauthService) Revoke(application *models.OAuthApplication, token string) error {
+	tokenHash := hashAccessToken(token)
+
+	accessToken, err := s.accessTokens.FindByTokenHash(tokenHash)
+	if err != nil {
+		return err
+	}
+	if accessToken != nil && s.accessTokenBelongsTo(accessToken, application) {
+		return s.accessTokens.Delete(accessToken.ID)
+	}
+
+	// A revoked refresh token takes the access tokens of the grant along
+	refreshToken, err := s.refreshTokens.FindByTokenHash(tokenHash)
+	if err != nil {
+		return err
+	}
+	if refreshToken != nil && refreshToken.ApplicationID == application.ID {
+		return s.RevokeAuthorization(application.ID, refreshToken.UserID)
+	}
+
+	return nil
+}
+
+func (s *oauthService) Introspect(application *models.OAuthApplication, token string) (*OAuthIntrospection, error) {
+	inactive := &OAuthIntrospection{Active: false}
+	tokenHash := hashAccessToken(token)
+	now := time.Now().Unix()
+
+	accessToken, err := s.accessTokens.FindByTokenHash(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if accessToken != nil {
+		if !s.accessTokenBelongsTo(accessToken, application) || (accessToken.ExpiresAt != nil && now >= *accessToken.ExpiresAt) {
+			return inactive, nil
+		}
+		return s.introspection(application, accessToken.UserID, "bearer", accessToken.Scopes, accessToken.ExpiresAt, accessToken.CreatedAt)
+	}
+
+	refreshToken, err := s.refreshTokens.FindByTokenHash(tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if refreshToken != nil {
+		if refreshToken.ApplicationID != application.ID || now >= refreshToken.ExpiresAt {
+			return inactive, nil
+		}
+		return s.introspection(application, refreshToken.UserID, "", refreshToken.Scopes, &refreshToken.ExpiresAt, refreshToken.CreatedAt)
+	}
+
+	return inactive, nil
+}
+
+func (s *oauthService) RevokeAuthorization(applicationID, userID int64) error {
+	if err := s.refreshTokens.DeleteByApplicationAndUser(applicationID, userID); err != nil {
+		return err
+	}
+	return s.accessTokens.DeleteByApplicationAndUser(applicationID, userID)
+}
+
+func (s *oauthService) introspection(application *models.OAuthApplication, userID int64, tokenType string, scopes []string, expiresAt *int64, issuedAt int64) (*OAuthIntrospection, error) {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.SuspendedAt != nil {
+		return &OAuthIntrospection{Active: false}, nil
+	}
+
+	introspection := &OAuthIntrospection{
+		Active:    true,
+		Scope:     strings.Join(scopes, " "),
+		ClientID:  application.ClientID,
+		Username:  user.Username,
+		TokenType: tokenType,
+		IssuedAt:  issuedAt,
+		Subject:   strconv.FormatInt(user.ID, 10),
+	}
+	if expiresAt != nil {
+		introspection.ExpiresAt = *expiresAt
+	}
+	return introspection, nil
+}
+
+// issueTokens creates an access token with accessScopes and a refresh token with refreshScopes
+func (s *oauthService) issueTokens(application *models.OAuthApplication, user *models.User, accessScopes, refreshScopes []string) (*OAuthTokenResponse, error) {
+	accessToken, err := randomOAuthToken(oauthAccessTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := randomOAuthToken(oauthRefreshTokenPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if err := s.refreshTokens.DeleteExpired(now.Unix()); err != nil {
+		return nil, err
+	}
+
+	_, err = s.accessTokens.CreateForApplication(user.ID, application.ID, application.Name, hashAccessToken(accessToken), accessScopes, now.Add(oauthAccessTokenTTL).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.refreshTokens.Create(hashAccessToken(refreshToken), application.ID, user.ID, refreshScopes, now.Add(oauthRefreshTokenTTL).Unix())
+	if err != nil {
+		return nil, err
+	}
+
+	return &OAuthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "bearer",
+		ExpiresIn:    int64(oauthAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(accessScopes, " "),
+		Username:     user.Username,
+	}, nil
+}
+
+// activeUser returns the user a grant is for, unless they were deleted or suspended since
+func (s *oauthService) activeUser(userID int64) (*models.User, error) {
+	user, err := s.users.FindByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.SuspendedAt != nil {
+		return nil, oauthError(OAuthErrorInvalidGrant, "the user can't sign in")
+	}
+	return user, nil
+}
+
+// accessTokenBelongsTo reports whether the token was issued to the application. The CLI's tokens are personal ones.
+func (s *oauthService) accessTokenBelongsTo(token *models.AccessToken, application *models.OAuthApplication) bool {
+	if s.IsCLI(application) {
+		return token.ApplicationID == nil
+	}
+	return token.ApplicationID != nil && *token.ApplicationID == application.ID
+}
+
+func (s *oauthService) deviceCodeBelongsTo(grant *models.OAuthDeviceCode, application *models.OAuthApplication) bool {
+	if s.IsCLI(application) {
+		return grant.ApplicationID == nil
+	}
+	return grant.ApplicationID != nil && *grant.ApplicationID == application.ID
+}
+
+func randomOAuthToken(prefix string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// generateUserCode generates the code users type in to approve a device, like "ABCD-2345"
+func generateUserCode() (string, error) {
+	const charset = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // Exclude ambiguous characters
+	const codeLength = 8
+
+	code := make([]byte, codeLength)
+	for i := range code {
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		code[i] = charset[num.Int64()]
+	}
+
+	// Format as XXXX-XXXX
+	return fmt.Sprintf("%s-%s", string(code[:4]), string(code[4:])), nil
+}
@@ -0,0 +1,621 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcClockSkew is how much the clocks of the identity provider and ours may differ
+	oidcClockSkew = time.Minute
+	// oidcKeysRefreshInterval bounds how often the signing keys are fetched again for an unknown key ID
+	oidcKeysRefreshInterval = time.Minute
+	// oidcDiscoveryTTL is how long the discovery document is cached
+	oidcDiscoveryTTL = time.Hour
+)
+
+var errInvalidIDToken = errors.New("invalid ID token")
+
+// OIDCProviderConfig configures an OpenID Connect identity provider.
+// The claims are names of ID token or userinfo claims, nested claims are separated by dots.
+type OIDCProviderConfig struct {
+	ID           string
+	Name         string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+
+	UsernameClaim string
+	EmailClaim    string
+	GroupsClaim   string
+	// AllowedGroups lets only members of one of them sign in, if set
+	AllowedGroups []string
+	// AdminGroups makes the members of one of them administrators of the instance, and
+	// everyone else not, every time they sign in. Administrators aren't managed if it is empty.
+	AdminGroups []string
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+type oidcProvider struct {
+	config OIDCProviderConfig
+	client *http.Client
+
+	mu           sync.Mutex
+	discovery    *oidcDiscovery
+	discoveredAt time.Time
+	keys         map[string]crypto.PublicKey
+	keysAt       time.Time
+}
+
+// NewOIDCProvider signs users in with a generic OpenID Connect identity provider. The discovery
+// document and signing keys are fetched when first needed, so the server starts while it is down.
+func NewOIDCProvider(config OIDCProviderConfig) IdentityProvider {
+	return &oidcProvider{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (p *oidcProvider) ID() string {
+	return p.config.ID
+}
+
+func (p *oidcProvider) Name() string {
+	return p.config.Name
+}
+
+func (p *oidcProvider) AuthURL(ctx context.Context, state, verifier, nonce string) (string, error) {
+	oauthConfig, err := p.oauthConfig(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return oauthConfig.AuthCodeURL(state,
+		oauth2.S256ChallengeOption(verifier),
+		oauth2.SetAuthURLParam("nonce", nonce),
+	), nil
+}
+
+func (p *oidcProvider) Identify(ctx context.Context, code, verifier, nonce string) (*ExternalIdentity, error) {
+	oauthConfig, err := p.oauthConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, p.client)
+	token, err := oauthConfig.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, fmt.Errorf("%w: missing from token response", errInvalidIDToken)
+	}
+
+	claims, err := p.verifyIDToken(ctx, rawIDToken, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	// Some providers only put the profile in the userinfo response
+	if p.missingClaims(claims) {
+		if err := p.mergeUserinfo(ctx, oauthConfig, token, claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.identity(claims)
+}
+
+func (p *oidcProvider) oauthConfig(ctx context.Context) (*oauth2.Config, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauth2.Config{
+		ClientID:     p.config.ClientID,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectURL,
+		Scopes:       p.config.Scopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  discovery.AuthorizationEndpoint,
+			TokenURL: discovery.TokenEndpoint,
+		},
+	}, nil
+}
+
+// discover returns the provider's discovery document, which says where its endpoints and keys are
+func (p *oidcProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil && time.Since(p.discoveredAt) < oidcDiscoveryTTL {
+		return p.discovery, nil
+	}
+
+	discoveryURL := strings.TrimSuffix(p.config.Issuer, "/") + "/.well-known/openid-configuration"
+	var discovery oidcDiscovery
+	if err := p.getJSON(ctx, discoveryURL, &discovery); err != nil {
+		return nil, fmt.Errorf("failed to fetch discovery document of %s: %w", p.config.ID, err)
+	}
+
+	// The document must belong to the configured issuer, ID tokens are checked against it
+	if discovery.Issuer != p.config.Issuer {
+		return nil, fmt.Errorf("discovery document of %s is for issuer %q", p.config.ID, discovery.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document of %s is missing endpoints", p.config.ID)
+	}
+
+	p.discovery = &discovery
+	p.discoveredAt = time.Now()
+	return p.discovery, nil
+}
+
+// key returns the signing key with kid, fetching the keys again if it is unknown.
+// A token without kid can be verified if the provider has a single key.
+func (p *oidcProvider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+
+	if time.Since(p.keysAt) < oidcKeysRefreshInterval {
+		return nil, fmt.Errorf("%w: unknown signing key %q", errInvalidIDToken, kid)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := p.getJSON(ctx, discovery.JWKSURI, &set); err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys of %s: %w", p.config.ID, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Keys of types we don't support can't have signed tokens we accept
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	p.keys = keys
+	p.keysAt = time.Now()
+
+	if key := p.findKey(kid); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: unknown signing key %q", errInvalidIDToken, kid)
+}
+
+// findKey looks up a cached key. The caller holds the lock.
+func (p *oidcProvider) findKey(kid string) crypto.PublicKey {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key
+		}
+	}
+	return p.keys[kid]
+}
+
+// verifyIDToken checks the signature and claims of an ID token and returns its claims
+func (p *oidcProvider) verifyIDToken(ctx context.Context, rawIDToken, nonce string) (map[string]any, error) {
+	parts := strings.Split(rawIDToken, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed", errInvalidIDToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidIDToken, err)
+	}
+	// Tokens signed otherwise aren't worth fetching the keys for
+	if _, ok := jwsAlgorithms[header.Alg]; !ok {
+		return nil, fmt.Errorf("%w: unsupported algorithm %q", errInvalidIDToken, header.Alg)
+	}
+
+	key, err := p.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", errInvalidIDToken)
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidIDToken, err)
+	}
+
+	var claims map[string]any
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidIDToken, err)
+	}
+
+	if iss, _ := claims["iss"].(string); iss != p.config.Issuer {
+		return nil, fmt.Errorf("%w: issued by %q", errInvalidIDToken, iss)
+	}
+	audiences := claimStrings(claims, "aud")
+	if !slices.Contains(audiences, p.config.ClientID) {
+		return nil, fmt.Errorf("%w: not issued for this client", errInvalidIDToken)
+	}
+	if azp, ok := claims["azp"].(string); ok && azp != p.config.ClientID {
+		return nil, fmt.Errorf("%w: authorized party is %q", errInvalidIDToken, azp)
+	}
+
+	now := time.Now()
+	exp, ok := claims["exp"].(json.Number)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing expiry", errInvalidIDToken)
+	}
+	if expiresAt, err := exp.Int64(); err != nil || now.Add(-oidcClockSkew).Unix() >= expiresAt {
+		return nil, fmt.Errorf("%w: expired", errInvalidIDToken)
+	}
+	if iat, ok := claims["iat"].(json.Number); ok {
+		if issuedAt, err := iat.Int64(); err != nil || issuedAt > now.Add(oidcClockSkew).Unix() {
+			return nil, fmt.Errorf("%w: issued in the future", errInvalidIDToken)
+		}
+	}
+
+	// The nonce ties the token to the sign-in that was started in this browser
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", errInvalidIDToken)
+	}
+
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%w: missing subject", errInvalidIDToken)
+	}
+
+	return claims, nil
+}
+
+func (p *oidcProvider) missingClaims(claims map[string]any) bool {
+	for _, name := range []string{p.config.UsernameClaim, p.config.EmailClaim, p.config.GroupsClaim} {
+		if name != "" && claimValue(claims, name) == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeUserinfo adds the claims of the userinfo response that the ID token doesn't have
+func (p *oidcProvider) mergeUserinfo(ctx context.Context, oauthConfig *oauth2.Config, token *oauth2.Token, claims map[string]any) error {
+	discovery, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	if discovery.UserinfoEndpoint == "" {
+		return nil
+	}
+
+	client := oauthConfig.Client(ctx, token)
+	resp, err := client.Get(discovery.UserinfoEndpoint)
+	if err != nil {
+		return fmt.Errorf("failed to get userinfo: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get userinfo: status %d", resp.StatusCode)
+	}
+
+	var userinfo map[string]any
+	decoder := json.NewDecoder(io.LimitReader(resp.Body, 1<<20))
+	decoder.UseNumber()
+	if err := decoder.Decode(&userinfo); err != nil {
+		return fmt.Errorf("failed to decode userinfo: %w", err)
+	}
+
+	// A response about someone else must not be mixed into the token's claims
+	if sub, _ := userinfo["sub"].(string); sub != claims["sub"] {
+		return fmt.Errorf("userinfo subject %q doesn't match the ID token", sub)
+	}
+
+	for name, value := range userinfo {
+		if _, ok := claims[name]; !ok {
+			claims[name] = value
+		}
+	}
+	return nil
+}
+
+// identity maps the claims to an identity and applies the group policy
+func (p *oidcProvider) identity(claims map[string]any) (*ExternalIdentity, error) {
+	identity := &ExternalIdentity{
+		Subject: claims["sub"].(string),
+		Name:    claimString(claims, "name"),
+	}
+
+	if p.config.UsernameClaim != "" {
+		identity.Username = claimString(claims, p.config.UsernameClaim)
+	}
+	if p.config.EmailClaim != "" {
+		identity.Email = claimString(claims, p.config.EmailClaim)
+		identity.EmailVerified = claimBool(claims, "email_verified")
+	}
+	if p.config.GroupsClaim != "" {
+		identity.Groups = claimStrings(claims, p.config.GroupsClaim)
+	}
+
+	// Without a username claim, the local part of the email is the next best thing
+	if identity.Username == "" {
+		identity.Username, _, _ = strings.Cut(identity.Email, "@")
+	}
+	if identity.Name == "" {
+		identity.Name = identity.Username
+	}
+
+	if len(p.config.AllowedGroups) > 0 && !inAnyGroup(identity.Groups, p.config.AllowedGroups) {
+		return nil, ErrIdentityNotAllowed
+	}
+	if len(p.config.AdminGroups) > 0 {
+		isAdmin := inAnyGroup(identity.Groups, p.config.AdminGroups)
+		identity.IsAdmin = &isAdmin
+	}
+
+	return identity, nil
+}
+
+func (p *oidcProvider) getJSON(ctx context.Context, url string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(v)
+}
+
+func inAnyGroup(groups, wanted []string) bool {
+	for _, group := range groups {
+		if slices.Contains(wanted, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// claimValue looks up a claim, following dots into nested objects such as "realm_access.roles"
+func claimValue(claims map[string]any, name string) any {
+	if value, ok := claims[name]; ok {
+		return value
+	}
+
+	var value any = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		value = object[part]
+	}
+	return value
+}
+
+func claimString(claims map[string]any, name string) string {
+	value, _ := claimValue(claims, name).(string)
+	return value
+}
+
+// claimBool reads a boolean claim, some providers send "true" as a string
+func claimBool(claims map[string]any, name string) bool {
+	switch value := claimValue(claims, name).(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	}
+	return false
+}
+
+// claimStrings reads a claim that is a list of strings, or a single string
+func claimStrings(claims map[string]any, name string) []string {
+	switch value := claimValue(claims, name).(type) {
+	case string:
+		return []string{value}
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func decodeJWTPart(part string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return errors.New("malformed encoding")
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return errors.New("malformed JSON")
+	}
+	return nil
+}
+
+// jwsAlgorithm is an algorithm ID tokens may be signed with. curve is set for ECDSA, whose
+// algorithms each name the curve of the key.
+type jwsAlgorithm struct {
+	hash  crypto.Hash
+	curve elliptic.Curve
+}
+
+// jwsAlgorithms are the asymmetric algorithms OpenID Connect providers sign ID tokens with.
+// Symmetric algorithms and "none" are refused.
+var jwsAlgorithms = map[string]jwsAlgorithm{
+	"RS256": {hash: crypto.SHA256},
+	"RS384": {hash: crypto.SHA384},
+	"RS512": {hash: crypto.SHA512},
+	"ES256": {hash: crypto.SHA256, curve: elliptic.P256()},
+	"ES384": {hash: crypto.SHA384, curve: elliptic.P384()},
+	"ES512": {hash: crypto.SHA512, curve: elliptic.P521()},
+}
+
+// jwsMinRSAKeyBits is the smallest RSA key accepted for signatures
+const jwsMinRSAKeyBits = 2048
+
+// verifyJWTSignature checks a JWS signature made with one of jwsAlgorithms. The key has to be of
+// the algorithm's type, on its curve for ECDSA.
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	algorithm, ok := jwsAlgorithms[alg]
+	if !ok {
+		return fmt.Errorf("unsupported algorithm %q", alg)
+	}
+
+	var h hash.Hash
+	switch algorithm.hash {
+	case crypto.SHA256:
+		h = sha256.New()
+	case crypto.SHA384:
+		h = sha512.New384()
+	case crypto.SHA512:
+		h = sha512.New()
+	}
+	h.Write([]byte(signed))
+	digest := h.Sum(nil)
+
+	if algorithm.curve == nil {
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("algorithm %q doesn't match the key", alg)
+		}
+		if rsaKey.N.BitLen() < jwsMinRSAKeyBits {
+			return fmt.Errorf("RSA key of %d bits is too small", rsaKey.N.BitLen())
+		}
+		return rsa.VerifyPKCS1v15(rsaKey, algorithm.hash, digest, signature)
+	}
+
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok || ecKey.Curve != algorithm.curve {
+		return fmt.Errorf("algorithm %q doesn't match the key", alg)
+	}
+	// JWS encodes the signature as r and s of the curve's size, not as ASN.1
+	size := (ecKey.Curve.Params().BitSize + 7) / 8
+	if len(signature) != 2*size {
+		return errors.New("malformed signature")
+	}
+	r := new(big.Int).SetBytes(signature[:size])
+	s := new(big.Int).SetBytes(signature[size:])
+	if !ecdsa.Verify(ecKey, digest, r, s) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
@@ -0,0 +1,319 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubOIDCServer is an OpenID Connect identity provider whose token endpoint answers every code with
+// idToken, and whose discovery document and keys tests can change
+type stubOIDCServer struct {
+	*httptest.Server
+	// issuer is what the discovery document claims, the server's URL unless changed
+	issuer  string
+	keys    []jsonWebKey
+	idToken string
+	// jwksRequests counts how often the keys were fetched
+	jwksRequests int
+}
+
+func newStubOIDCServer(t *testing.T) *stubOIDCServer {
+	t.Helper()
+
+	s := &stubOIDCServer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(oidcDiscovery{
+			Issuer:                s.issuer,
+			AuthorizationEndpoint: s.URL + "/authorize",
+			TokenEndpoint:         s.URL + "/token",
+			JWKSURI:               s.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		s.jwksRequests++
+		json.NewEncoder(w).Encode(map[string]any{"keys": s.keys})
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     s.idToken,
+		})
+	})
+	s.Server = httptest.NewServer(mux)
+	s.issuer = s.URL
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *stubOIDCServer) publishRSA(kid string, key *rsa.PublicKey) {
+	s.keys = append(s.keys, jsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Use: "sig",
+		N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	})
+}
+
+func (s *stubOIDCServer) publishEC(kid string, key *ecdsa.PublicKey) {
+	size := (key.Curve.Params().BitSize + 7) / 8
+	s.keys = append(s.keys, jsonWebKey{
+		Kty: "EC",
+		Kid: kid,
+		Use: "sig",
+		Crv: key.Curve.Params().Name,
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, size))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, size))),
+	})
+}
+
+// identify signs in with the stub, which hands out idToken
+func (s *stubOIDCServer) identify(idToken string) (*ExternalIdentity, error) {
+	s.idToken = idToken
+	provider := NewOIDCProvider(OIDCProviderConfig{
+		ID:            "stub",
+		Name:          "Stub",
+		Issuer:        s.URL,
+		ClientID:      "hypercommit",
+		ClientSecret:  "secret",
+		RedirectURL:   "http://localhost/auth/stub/callback",
+		Scopes:        []string{"openid", "email", "profile"},
+		UsernameClaim: "preferred_username",
+		EmailClaim:    "email",
+	})
+	return provider.Identify(context.Background(), "code", "verifier", "nonce")
+}
+
+// idTokenClaims are the claims of a valid ID token for the stub's sign-in
+func (s *stubOIDCServer) idTokenClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":                s.URL,
+		"aud":                "hypercommit",
+		"sub":                "1234",
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"nonce":              "nonce",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+	}
+}
+
+// signJWT signs claims with key as alg. A nil key leaves the signature empty.
+func signJWT(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]any) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	if key == nil {
+		return signed + "."
+	}
+
+	hashFunc := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}[alg[len(alg)-3:]]
+	var digest []byte
+	switch hashFunc {
+	case crypto.SHA256:
+		sum := sha256.Sum256([]byte(signed))
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384([]byte(signed))
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512([]byte(signed))
+		digest = sum[:]
+	}
+
+	var signature []byte
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, hashFunc, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestOIDCSignInVerifiesTheIDToken(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smallRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ec384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newServer := func(t *testing.T) *stubOIDCServer {
+		s := newStubOIDCServer(t)
+		s.publishRSA("rsa", &rsaKey.PublicKey)
+		s.publishRSA("small", &smallRSAKey.PublicKey)
+		s.publishEC("ec", &ecKey.PublicKey)
+		s.publishEC("ec384", &ec384Key.PublicKey)
+		return s
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		for _, tc := range []struct {
+			alg, kid string
+			key      crypto.Signer
+		}{
+			{"RS256", "rsa", rsaKey},
+			{"RS512", "rsa", rsaKey},
+			{"ES256", "ec", ecKey},
+			{"ES384", "ec384", ec384Key},
+		} {
+			s := newServer(t)
+			identity, err := s.identify(signJWT(t, tc.alg, tc.kid, tc.key, s.idTokenClaims()))
+			if err != nil {
+				t.Fatalf("%s: identify: %v", tc.alg, err)
+			}
+			if identity.Subject != "1234" || identity.Username != "alice" || identity.Email != "alice@example.com" || !identity.EmailVerified {
+				t.Errorf("%s: identity = %+v", tc.alg, identity)
+			}
+		}
+	})
+
+	rejected := []struct {
+		name   string
+		token  func(s *stubOIDCServer) string
+		detail string
+	}{
+		{"bad signature", func(s *stubOIDCServer) string {
+			return signJWT(t, "RS256", "rsa", otherRSAKey, s.idTokenClaims())
+		}, "crypto/rsa: verification error"},
+		{"unknown key", func(s *stubOIDCServer) string {
+			return signJWT(t, "RS256", "rotated", otherRSAKey, s.idTokenClaims())
+		}, `unknown signing key "rotated"`},
+		{"wrong audience", func(s *stubOIDCServer) string {
+			claims := s.idTokenClaims()
+			claims["aud"] = "another-client"
+			return signJWT(t, "RS256", "rsa", rsaKey, claims)
+		}, "not issued for this client"},
+		{"wrong issuer", func(s *stubOIDCServer) string {
+			claims := s.idTokenClaims()
+			claims["iss"] = "https://attacker.example.com"
+			return signJWT(t, "RS256", "rsa", rsaKey, claims)
+		}, `issued by "https://attacker.example.com"`},
+		{"expired", func(s *stubOIDCServer) string {
+			claims := s.idTokenClaims()
+			claims["exp"] = time.Now().Add(-time.Hour).Unix()
+			return signJWT(t, "RS256", "rsa", rsaKey, claims)
+		}, "expired"},
+		{"nonce of another sign-in", func(s *stubOIDCServer) string {
+			claims := s.idTokenClaims()
+			claims["nonce"] = "other"
+			return signJWT(t, "RS256", "rsa", rsaKey, claims)
+		}, "nonce mismatch"},
+		{"alg none", func(s *stubOIDCServer) string {
+			return signJWT(t, "none", "rsa", nil, s.idTokenClaims())
+		}, `unsupported algorithm "none"`},
+		{"short alg", func(s *stubOIDCServer) string {
+			return signJWT(t, "x", "rsa", nil, s.idTokenClaims())
+		}, `unsupported algorithm "x"`},
+		{"symmetric alg", func(s *stubOIDCServer) string {
+			return signJWT(t, "HS256", "rsa", nil, s.idTokenClaims())
+		}, `unsupported algorithm "HS256"`},
+		{"RSA key for ECDSA", func(s *stubOIDCServer) string {
+			return signJWT(t, "ES256", "rsa", ecKey, s.idTokenClaims())
+		}, `algorithm "ES256" doesn't match the key`},
+		{"curve of another alg", func(s *stubOIDCServer) string {
+			return signJWT(t, "ES384", "ec", ecKey, s.idTokenClaims())
+		}, `algorithm "ES384" doesn't match the key`},
+		{"small RSA key", func(s *stubOIDCServer) string {
+			return signJWT(t, "RS256", "small", smallRSAKey, s.idTokenClaims())
+		}, "RSA key of 1024 bits is too small"},
+	}
+	for _, tc := range rejected {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newServer(t)
+			_, err := s.identify(tc.token(s))
+			if !errors.Is(err, errInvalidIDToken) {
+				t.Fatalf("identify: err = %v, want an invalid ID token", err)
+			}
+			if got := err.Error(); got != errInvalidIDToken.Error()+": "+tc.detail {
+				t.Errorf("identify: err = %q, want %q", got, tc.detail)
+			}
+		})
+	}
+}
+
+func TestOIDCRefusesTheDiscoveryDocumentOfAnotherIssuer(t *testing.T) {
+	s := newStubOIDCServer(t)
+	s.issuer = "https://attacker.example.com"
+
+	if _, err := s.identify(""); err == nil {
+		t.Fatal("identify succeeded with the discovery document of another issuer")
+	}
+}
+
+func TestOIDCFetchesTheKeysOnlyForSupportedAlgorithms(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newStubOIDCServer(t)
+	// Keys for encryption can't sign ID tokens
+	s.publishEC("ec", &key.PublicKey)
+	s.keys[0].Use = "enc"
+
+	if _, err := s.identify(signJWT(t, "HS256", "ec", nil, s.idTokenClaims())); !errors.Is(err, errInvalidIDToken) {
+		t.Fatalf("identify: err = %v, want an invalid ID token", err)
+	}
+	if s.jwksRequests != 0 {
+		t.Errorf("keys fetched %d times for a token with an unsupported algorithm", s.jwksRequests)
+	}
+
+	if _, err := s.identify(signJWT(t, "ES256", "ec", key, s.idTokenClaims())); !errors.Is(err, errInvalidIDToken) {
+		t.Fatalf("identify: err = %v, want an invalid ID token for a key not meant for signing", err)
+	}
+	if s.jwksRequests != 1 {
+		t.Errorf("keys fetched %d times, want 1", s.jwksRequests)
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const (
+	// outboxMaxAttempts is how often delivery of a single email is tried before giving up
+	outboxMaxAttempts = 8
+	// outboxBatchSize bounds how many emails a single pass delivers
+	outboxBatchSize = 20
+	// outboxSendTimeout bounds a single delivery attempt
+	outboxSendTimeout = 30 * time.Second
+)
+
+// OutboxWorker delivers queued emails through a Mailer, retrying failures with exponential backoff
+type OutboxWorker interface {
+	Run(ctx context.Context, interval time.Duration)
+	DeliverDue(ctx context.Context) error
+}
+
+type outboxWorker struct {
+	outbox repositories.EmailOutboxRepository
+	mailer Mailer
+}
+
+func NewOutboxWorker(outbox repositories.EmailOutboxRepository, mailer Mailer) OutboxWorker {
+	return &outboxWorker{
+		outbox: outbox,
+		mailer: mailer,
+	}
+}
+
+// Run delivers due emails every interval until ctx is cancelled
+func (w *outboxWorker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.DeliverDue(ctx); err != nil {
+			slog.Error("failed to deliver outbox", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue makes one pass over the outbox, sending every email whose next attempt is due
+func (w *outboxWorker) DeliverDue(ctx context.Context) error {
+	for {
+		emails, err := w.outbox.FindDue(time.Now().Unix(), outboxBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(emails) == 0 {
+			return nil
+		}
+
+		for _, email := range emails {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.deliver(ctx, email)
+		}
+
+		if len(emails) < outboxBatchSize {
+			return nil
+		}
+	}
+}
+
+func (w *outboxWorker) deliver(ctx context.Context, email *models.OutboxEmail) {
+	msg := Message{
+		To:      email.ToAddress,
+		Subject: email.Subject,
+		Text:    email.TextBody,
+	}
+	if email.HTMLBody != nil {
+		msg.HTML = *email.HTMLBody
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, outboxSendTimeout)
+	err := w.mailer.Send(sendCtx, msg)
+	cancel()
+
+	if err == nil {
+		if err := w.outbox.MarkSent(email.ID); err != nil {
+			slog.Error("failed to mark email as sent", "error", err, "email_id", email.ID)
+		}
+		return
+	}
+
+	attempt := email.Attempts + 1
+	if attempt >= outboxMaxAttempts {
+		slog.Error("giving up on email", "error", err, "email_id", email.ID, "attempts", attempt)
+		if err := w.outbox.MarkFailed(email.ID, err.Error()); err != nil {
+			slog.Error("failed to mark email as failed", "error", err, "email_id", email.ID)
+		}
+		return
+	}
+
+	nextAttemptAt := time.Now().Add(outboxBackoff(attempt)).Unix()
+	slog.Warn("email delivery failed, will retry", "error", err, "email_id", email.ID, "attempts", attempt)
+	if err := w.outbox.MarkAttemptFailed(email.ID, err.Error(), nextAttemptAt); err != nil {
+		slog.Error("failed to reschedule email", "error", err, "email_id", email.ID)
+	}
+}
+
+// outboxBackoff doubles the delay after every failed attempt: 1m, 2m, 4m, ... capped at 6h
+func outboxBackoff(attempt int64) time.Duration {
+	delay := time.Minute << (attempt - 1)
+	if delay <= 0 || delay > 6*time.Hour {
+		return 6 * time.Hour
+	}
+	return delay
+}
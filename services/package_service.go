@@ -0,0 +1,651 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"golang.org/x/mod/semver"
+)
+
+// PackageMaxFileSize limits the files of package versions, npm tarballs included
+const PackageMaxFileSize = 256 << 20
+
+var (
+	ErrInvalidPackageName     = errors.New("invalid package name")
+	ErrInvalidPackageVersion  = errors.New("invalid package version")
+	ErrInvalidPackageFileName = errors.New("file names consist of letters, digits, ., _ and -, and don't start with .")
+	ErrInvalidNPMPublish      = errors.New("invalid npm publish request")
+	ErrPackageVersionExists   = errors.New("the package version already exists")
+	ErrPackageFileExists      = errors.New("the file already exists in the package version")
+	ErrPackageFileTooLarge    = errors.New("the file is larger than 256 MiB")
+)
+
+var (
+	// npmNameRegex matches the names npm accepts for new packages, optionally with a scope
+	npmNameRegex = regexp.MustCompile(`^(?:@[a-z0-9][a-z0-9._~-]*/)?[a-z0-9][a-z0-9._~-]*$`)
+	npmTagRegex  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	// rawNameRegex matches the names of raw packages and files, and raw versions
+	rawNameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._+-]*$`)
+)
+
+// NPMPublish is a version published with npm publish
+type NPMPublish struct {
+	Name    string
+	Version string
+	// Metadata is the package.json of the version
+	Metadata json.RawMessage
+	// DistTags are the dist-tags to point to the version, latest unless npm publish was given --tag
+	DistTags []string
+	Tarball  []byte
+}
+
+// npmPublishJSON is the document npm publish sends, it holds a single version with its tarball
+type npmPublishJSON struct {
+	Name        string                     `json:"name"`
+	DistTags    map[string]string          `json:"dist-tags"`
+	Versions    map[string]json.RawMessage `json:"versions"`
+	Attachments map[string]struct {
+		Data string `json:"data"`
+	} `json:"_attachments"`
+}
+
+// NPMPackument is the document of an npm package the npm client installs from
+type NPMPackument struct {
+	ID       string                     `json:"_id"`
+	Rev      string                     `json:"_rev"`
+	Name     string                     `json:"name"`
+	DistTags map[string]string          `json:"dist-tags"`
+	Versions map[string]json.RawMessage `json:"versions"`
+	Time     map[string]string          `json:"time"`
+}
+
+// npmDistJSON is where the npm client downloads a version from, and how it checks the download
+type npmDistJSON struct {
+	Tarball   string `json:"tarball"`
+	Shasum    string `json:"shasum"`
+	Integrity string `json:"integrity"`
+}
+
+// PackageVersionFiles is a version of a package with its files
+type PackageVersionFiles struct {
+	Version *models.PackageVersion
+	Files   []*models.PackageFile
+}
+
+// PackageService stores the packages of users and organizations. The files of a package are stored
+// on disk in a directory named after the package ID. A package linked to a repository has the
+// visibility and permissions of the repository, it is linked to the repository of the same name of
+// its owner when it's created.
+type PackageService interface {
+	// ValidName reports whether name is a valid name for a package of the type
+	ValidName(packageType, name string) bool
+
+	// Create creates a package of a user or an organization, linked to the owner's repository of the
+	// same name if there is one
+	Create(ownerUserID, ownerOrgID *int64, packageType, name string) (*models.Package, error)
+	// Link links the package to a repository of its owner, or unlinks it if repo is nil
+	Link(pkg *models.Package, repo *models.Repository) error
+	// Delete deletes the package with its versions and files
+	Delete(pkg *models.Package) error
+
+	// ParseNPMPublish reads the document of npm publish for the package name
+	ParseNPMPublish(name string, body []byte) (*NPMPublish, error)
+	// PublishNPM stores a version of an npm package and points its dist-tags to it
+	PublishNPM(pkg *models.Package, publisher *models.User, publish *NPMPublish) (*models.PackageVersion, error)
+	// NPMPackument returns the document of an npm package, with tarball URLs below tarballBase
+	NPMPackument(pkg *models.Package, tarballBase string) (*NPMPackument, error)
+	// NPMTarballName is the file name of the tarball of a version of an npm package
+	NPMTarballName(name, version string) string
+	// SetDistTag points a dist-tag of an npm package to a version
+	SetDistTag(pkg *models.Package, tag, version string) error
+	DeleteDistTag(pkg *models.Package, tag string) error
+	DistTags(pkg *models.Package) (map[string]string, error)
+
+	// Versions returns the versions of the package, most recent first, with their files
+	Versions(pkg *models.Package) ([]PackageVersionFiles, error)
+	// UploadFile stores a file of a version of a raw package, creating the version if needed
+	UploadFile(pkg *models.Package, publisher *models.User, version, name string, content io.Reader) (*models.PackageFile, error)
+	// FindFile returns a file of a version of the package, nil if there is no such file
+	FindFile(pkg *models.Package, version, name string) (*models.PackageFile, error)
+	OpenFile(pkg *models.Package, file *models.PackageFile) (*os.File, error)
+	// DeleteFile deletes a file of a version, and the version along with its last file
+	DeleteFile(pkg *models.Package, version, name string) error
+	// DeleteVersion deletes a version of the package with its files and dist-tags
+	DeleteVersion(pkg *models.Package, version string) error
+
+	// UnlinkRepository unlinks the packages of a repository being deleted, they become private to
+	// their owner
+	UnlinkRepository(repo *models.Repository) error
+}
+
+type packageService struct {
+	packages    repositories.PackagesRepository
+	versions    repositories.PackageVersionsRepository
+	files       repositories.PackageFilesRepository
+	distTags    repositories.PackageDistTagsRepository
+	repos       repositories.RepositoriesRepository
+	storagePath string
+	// mu serializes publishing, so a version or a file is only stored once
+	mu sync.Mutex
+}
+
+func NewPackageService(
+	packages repositories.PackagesRepository,
+	versions repositories.PackageVersionsRepository,
+	files repositories.PackageFilesRepository,
+	distTags repositories.PackageDistTagsRepository,
+	repos repositories.RepositoriesRepository,
+	storagePath string,
+) PackageService {
+	return &packageService{
+		packages:    packages,
+		versions:    versions,
+		files:       files,
+		distTags:    distTags,
+		repos:       repos,
+		storagePath: storagePath,
+	}
+}
+
+func (s *packageService) ValidName(packageType, name string) bool {
+	switch packageType {
+	case models.PackageTypeNPM:
+		return len(name) <= 214 && npmNameRegex.MatchString(name)
+	case models.PackageTypeRaw:
+		return len(name) <= 100 && rawNameRegex.MatchString(name)
+	}
+	return false
+}
+
+func (s *packageService) validVersion(packageType, version string) bool {
+	if packageType == models.PackageTypeNPM {
+		// semver also accepts the shorthands v1 and v1.2, npm versions have all three numbers
+		core, _, _ := strings.Cut(strings.SplitN(version, "+", 2)[0], "-")
+		return semver.IsValid("v"+version) && strings.Count(core, ".") == 2
+	}
+	return len(version) <= 100 && rawNameRegex.MatchString(version)
+}
+
+func (s *packageService) Create(ownerUserID, ownerOrgID *int64, packageType, name string) (*models.Package, error) {
+	if !s.ValidName(packageType, name) {
+		return nil, ErrInvalidPackageName
+	}
+
+	// Scoped npm packages are linked to the repository named after the package without its scope
+	repoName := name
+	if i := strings.LastIndex(repoName, "/"); i >= 0 {
+		repoName = repoName[i+1:]
+	}
+	var repo *models.Repository
+	var err error
+	if ownerUserID != nil {
+		repo, err = s.repos.FindByUserAndName(*ownerUserID, repoName)
+	} else {
+		repo, err = s.repos.FindByOrgAndName(*ownerOrgID, repoName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pkg := &models.Package{
+		Type:        packageType,
+		Name:        name,
+		OwnerUserID: ownerUserID,
+		OwnerOrgID:  ownerOrgID,
+	}
+	if repo != nil {
+		pkg.RepositoryID = &repo.ID
+	}
+	return s.packages.Create(pkg)
+}
+
+func (s *packageService) Link(pkg *models.Package, repo *models.Repository) error {
+	if repo == nil {
+		pkg.RepositoryID = nil
+	} else {
+		pkg.RepositoryID = &repo.ID
+	}
+	return s.packages.SetRepository(pkg.ID, pkg.RepositoryID)
+}
+
+func (s *packageService) Delete(pkg *models.Package) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.distTags.DeleteAllByPackage(pkg.ID); err != nil {
+		return err
+	}
+	if err := s.files.DeleteAllByPackage(pkg.ID); err != nil {
+		return err
+	}
+	if err := s.versions.DeleteAllByPackage(pkg.ID); err != nil {
+		return err
+	}
+	if err := s.packages.Delete(pkg.ID); err != nil {
+		return err
+	}
+	return os.RemoveAll(s.packagePath(pkg))
+}
+
+func (s *packageService) ParseNPMPublish(name string, body []byte) (*NPMPublish, error) {
+	var document npmPublishJSON
+	if err := json.Unmarshal(body, &document); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNPMPublish, err)
+	}
+	if document.Name != name {
+		return nil, fmt.Errorf("%w: the name doesn't match the URL", ErrInvalidNPMPublish)
+	}
+	if len(document.Versions) != 1 || len(document.Attachments) != 1 {
+		return nil, fmt.Errorf("%w: a single version with its tarball has to be published", ErrInvalidNPMPublish)
+	}
+
+	publish := &NPMPublish{Name: name}
+	for version, metadata := range document.Versions {
+		publish.Version = version
+		publish.Metadata = metadata
+	}
+	if !s.validVersion(models.PackageTypeNPM, publish.Version) {
+		return nil, fmt.Errorf("%w: %s isn't a semantic version", ErrInvalidPackageVersion, publish.Version)
+	}
+	var manifest struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := json.Unmarshal(publish.Metadata, &manifest); err != nil || manifest.Name != name || manifest.Version != publish.Version {
+		return nil, fmt.Errorf("%w: the package.json of the version doesn't match it", ErrInvalidNPMPublish)
+	}
+
+	for tag, version := range document.DistTags {
+		if version != publish.Version {
+			continue
+		}
+		if !npmTagRegex.MatchString(tag) || s.validVersion(models.PackageTypeNPM, tag) {
+			return nil, fmt.Errorf("%w: %s isn't a valid dist-tag", ErrInvalidNPMPublish, tag)
+		}
+		publish.DistTags = append(publish.DistTags, tag)
+	}
+
+	for _, attachment := range document.Attachments {
+		tarball, err := base64.StdEncoding.DecodeString(attachment.Data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: the tarball isn't base64 encoded", ErrInvalidNPMPublish)
+		}
+		if len(tarball) > PackageMaxFileSize {
+			return nil, ErrPackageFileTooLarge
+		}
+		publish.Tarball = tarball
+	}
+	return publish, nil
+}
+
+func (s *packageService) PublishNPM(pkg *models.Package, publisher *models.User, publish *NPMPublish) (*models.PackageVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.versions.FindByPackageAndVersion(pkg.ID, publish.Version)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrPackageVersionExists
+	}
+
+	tempPath, file, err := s.writeTemp(bytes.NewReader(publish.Tarball))
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempPath)
+
+	version, err := s.versions.Create(&models.PackageVersion{
+		PackageID:   pkg.ID,
+		Version:     publish.Version,
+		Metadata:    string(publish.Metadata),
+		PublisherID: &publisher.ID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	file.VersionID = version.ID
+	file.Name = s.NPMTarballName(pkg.Name, publish.Version)
+	if err := s.storeFile(pkg, tempPath, file); err != nil {
+		return nil, err
+	}
+
+	for _, tag := range publish.DistTags {
+		if err := s.distTags.Set(pkg.ID, tag, version.ID); err != nil {
+			return nil, err
+		}
+	}
+	return version, s.packages.Touch(pkg.ID)
+}
+
+func (s *packageService) NPMPackument(pkg *models.Package, tarballBase string) (*NPMPackument, error) {
+	versions, err := s.versions.FindAllByPackage(pkg.ID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.files.FindAllByPackage(pkg.ID)
+	if err != nil {
+		return nil, err
+	}
+	distTags, err := s.DistTags(pkg)
+	if err != nil {
+		return nil, err
+	}
+	tarballs := make(map[int64]*models.PackageFile, len(files))
+	for _, file := range files {
+		tarballs[file.VersionID] = file
+	}
+
+	packument := &NPMPackument{
+		ID:       pkg.Name,
+		Rev:      fmt.Sprintf("%d-%d", len(versions), pkg.UpdatedAt),
+		Name:     pkg.Name,
+		DistTags: distTags,
+		Versions: make(map[string]json.RawMessage, len(versions)),
+		Time: map[string]string{
+			"created":  time.Unix(pkg.CreatedAt, 0).UTC().Format(time.RFC3339),
+			"modified": time.Unix(pkg.UpdatedAt, 0).UTC().Format(time.RFC3339),
+		},
+	}
+	for _, version := range versions {
+		tarball := tarballs[version.ID]
+		if tarball == nil {
+			continue
+		}
+
+		// The version is served as published, with the dist of this registry
+		var manifest map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(version.Metadata), &manifest); err != nil {
+			return nil, err
+		}
+		sha512Sum, err := hex.DecodeString(tarball.SHA512)
+		if err != nil {
+			return nil, err
+		}
+		dist, err := json.Marshal(npmDistJSON{
+			Tarball:   tarballBase + "/" + pkg.Name + "/-/" + tarball.Name,
+			Shasum:    tarball.SHA1,
+			Integrity: "sha512-" + base64.StdEncoding.EncodeToString(sha512Sum),
+		})
+		if err != nil {
+			return nil, err
+		}
+		manifest["dist"] = dist
+		content, err := json.Marshal(manifest)
+		if err != nil {
+			return nil, err
+		}
+		packument.Versions[version.Version] = content
+		packument.Time[version.Version] = time.Unix(version.CreatedAt, 0).UTC().Format(time.RFC3339)
+	}
+	return packument, nil
+}
+
+func (s *packageService) NPMTarballName(name, version string) string {
+	if i := strings.LastIndex(name, "/"); i >= 0 {
+		name = name[i+1:]
+	}
+	return name + "-" + version + ".tgz"
+}
+
+func (s *packageService) SetDistTag(pkg *models.Package, tag, version string) error {
+	if !npmTagRegex.MatchString(tag) || s.validVersion(models.PackageTypeNPM, tag) {
+		return fmt.Errorf("%w: %s isn't a valid dist-tag", ErrInvalidNPMPublish, tag)
+	}
+	packageVersion, err := s.versions.FindByPackageAndVersion(pkg.ID, version)
+	if err != nil {
+		return err
+	}
+	if packageVersion == nil {
+		return fmt.Errorf("%w: %s isn't a version of the package", ErrInvalidPackageVersion, version)
+	}
+	return s.distTags.Set(pkg.ID, tag, packageVersion.ID)
+}
+
+func (s *packageService) DeleteDistTag(pkg *models.Package, tag string) error {
+	return s.distTags.Delete(pkg.ID, tag)
+}
+
+func (s *packageService) DistTags(pkg *models.Package) (map[string]string, error) {
+	tags, err := s.distTags.FindAllByPackage(pkg.ID)
+	if err != nil {
+		return nil, err
+	}
+	distTags := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		distTags[tag.Name] = tag.Version
+	}
+	return distTags, nil
+}
+
+func (s *packageService) Versions(pkg *models.Package) ([]PackageVersionFiles, error) {
+	versions, err := s.versions.FindAllByPackage(pkg.ID)
+	if err != nil {
+		return nil, err
+	}
+	files, err := s.files.FindAllByPackage(pkg.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	filesByVersion := make(map[int64][]*models.PackageFile, len(versions))
+	for _, file := range files {
+		filesByVersion[file.VersionID] = append(filesByVersion[file.VersionID], file)
+	}
+	result := make([]PackageVersionFiles, 0, len(versions))
+	for _, version := range versions {
+		result = append(result, PackageVersionFiles{Version: version, Files: filesByVersion[version.ID]})
+	}
+	return result, nil
+}
+
+func (s *packageService) UploadFile(pkg *models.Package, publisher *models.User, version, name string, content io.Reader) (*models.PackageFile, error) {
+	if !s.validVersion(pkg.Type, version) {
+		return nil, ErrInvalidPackageVersion
+	}
+	if len(name) > 255 || !rawNameRegex.MatchString(name) {
+		return nil, ErrInvalidPackageFileName
+	}
+
+	// The upload is written before taking the lock, it may take a while
+	tempPath, file, err := s.writeTemp(content)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tempPath)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	packageVersion, err := s.versions.FindByPackageAndVersion(pkg.ID, version)
+	if err != nil {
+		return nil, err
+	}
+	if packageVersion == nil {
+		packageVersion, err = s.versions.Create(&models.PackageVersion{
+			PackageID:   pkg.ID,
+			Version:     version,
+			PublisherID: &publisher.ID,
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		existing, err := s.files.FindByVersionAndName(packageVersion.ID, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrPackageFileExists
+		}
+	}
+
+	file.VersionID = packageVersion.ID
+	file.Name = name
+	if err := s.storeFile(pkg, tempPath, file); err != nil {
+		return nil, err
+	}
+	return file, s.packages.Touch(pkg.ID)
+}
+
+func (s *packageService) FindFile(pkg *models.Package, version, name string) (*models.PackageFile, error) {
+	packageVersion, err := s.versions.FindByPackageAndVersion(pkg.ID, version)
+	if err != nil || packageVersion == nil {
+		return nil, err
+	}
+	return s.files.FindByVersionAndName(packageVersion.ID, name)
+}
+
+func (s *packageService) OpenFile(pkg *models.Package, file *models.PackageFile) (*os.File, error) {
+	return os.Open(s.filePath(pkg, file.ID))
+}
+
+func (s *packageService) DeleteFile(pkg *models.Package, version, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	packageVersion, err := s.versions.FindByPackageAndVersion(pkg.ID, version)
+	if err != nil {
+		return err
+	}
+	if packageVersion == nil {
+		return os.ErrNotExist
+	}
+	file, err := s.files.FindByVersionAndName(packageVersion.ID, name)
+	if err != nil {
+		return err
+	}
+	if file == nil {
+		return os.ErrNotExist
+	}
+
+	if err := s.files.Delete(file.ID); err != nil {
+		return err
+	}
+	if err := os.Remove(s.filePath(pkg, file.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	remaining, err := s.files.CountByVersion(packageVersion.ID)
+	if err != nil || remaining > 0 {
+		return err
+	}
+	return s.deleteVersion(pkg, packageVersion)
+}
+
+func (s *packageService) DeleteVersion(pkg *models.Package, version string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	packageVersion, err := s.versions.FindByPackageAndVersion(pkg.ID, version)
+	if err != nil {
+		return err
+	}
+	if packageVersion == nil {
+		return os.ErrNotExist
+	}
+	return s.deleteVersion(pkg, packageVersion)
+}
+
+func (s *packageService) deleteVersion(pkg *models.Package, version *models.PackageVersion) error {
+	files, err := s.files.FindAllByPackage(pkg.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.distTags.DeleteAllByVersion(version.ID); err != nil {
+		return err
+	}
+	if err := s.files.DeleteAllByVersion(version.ID); err != nil {
+		return err
+	}
+	if err := s.versions.Delete(version.ID); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if file.VersionID != version.ID {
+			continue
+		}
+		if err := os.Remove(s.filePath(pkg, file.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *packageService) UnlinkRepository(repo *models.Repository) error {
+	return s.packages.UnlinkRepository(repo.ID)
+}
+
+// writeTemp writes content to a temporary file and returns its path along with its size and digests
+func (s *packageService) writeTemp(content io.Reader) (string, *models.PackageFile, error) {
+	dir := filepath.Join(s.storagePath, "tmp")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", nil, err
+	}
+	temp, err := os.CreateTemp(dir, "upload-")
+	if err != nil {
+		return "", nil, err
+	}
+
+	sha1Hash, sha256Hash, sha512Hash := sha1.New(), sha256.New(), sha512.New()
+	size, err := io.Copy(io.MultiWriter(temp, sha1Hash, sha256Hash, sha512Hash), io.LimitReader(content, PackageMaxFileSize+1))
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > PackageMaxFileSize {
+		err = ErrPackageFileTooLarge
+	}
+	if err != nil {
+		os.Remove(temp.Name())
+		return "", nil, err
+	}
+
+	return temp.Name(), &models.PackageFile{
+		Size:   size,
+		SHA1:   hex.EncodeToString(sha1Hash.Sum(nil)),
+		SHA256: hex.EncodeToString(sha256Hash.Sum(nil)),
+		SHA512: hex.EncodeToString(sha512Hash.Sum(nil)),
+	}, nil
+}
+
+// storeFile records a file of a version and moves its temporary file in place
+func (s *packageService) storeFile(pkg *models.Package, tempPath string, file *models.PackageFile) error {
+	created, err := s.files.Create(file)
+	if err != nil {
+		return err
+	}
+	*file = *created
+
+	path := s.filePath(pkg, file.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+func (s *packageService) packagePath(pkg *models.Package) string {
+	return filepath.Join(s.storagePath, strconv.FormatInt(pkg.ID, 10))
+}
+
+func (s *packageService) filePath(pkg *models.Package, fileID int64) string {
+	return filepath.Join(s.packagePath(pkg), strconv.FormatInt(fileID, 10))
+}
@@ -0,0 +1,389 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const (
+	testPasskeyOrigin = "https://hypercommit.test"
+	testPasskeyRPID   = "hypercommit.test"
+)
+
+// softAuthenticator is a software WebAuthn authenticator holding a single credential, answering
+// ceremonies the way a browser passes them on
+type softAuthenticator struct {
+	credentialID []byte
+	signer       crypto.Signer
+	signCount    uint32
+	userHandle   []byte
+
+	// Where the browser says the ceremony happens and which relying party the authenticator signs for
+	origin string
+	rpID   string
+}
+
+func newSoftAuthenticator(t *testing.T, rsaKey bool) *softAuthenticator {
+	t.Helper()
+
+	var signer crypto.Signer
+	var err error
+	if rsaKey {
+		signer, err = rsa.GenerateKey(rand.Reader, 2048)
+	} else {
+		signer, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+
+	credentialID := make([]byte, 16)
+	rand.Read(credentialID)
+
+	return &softAuthenticator{
+		credentialID: credentialID,
+		signer:       signer,
+		origin:       testPasskeyOrigin,
+		rpID:         testPasskeyRPID,
+	}
+}
+
+// coseKey encodes the public key of the credential
+func (a *softAuthenticator) coseKey(t *testing.T) []byte {
+	t.Helper()
+
+	var key map[int]any
+	switch public := a.signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		x, y := make([]byte, 32), make([]byte, 32)
+		public.X.FillBytes(x)
+		public.Y.FillBytes(y)
+		key = map[int]any{1: 2, 3: coseAlgES256, -1: 1, -2: x, -3: y}
+	case *rsa.PublicKey:
+		e := make([]byte, 4)
+		binary.BigEndian.PutUint32(e, uint32(public.E))
+		key = map[int]any{1: 3, 3: coseAlgRS256, -1: public.N.Bytes(), -2: e[1:]}
+	}
+
+	encoded, err := cbor.Marshal(key)
+	if err != nil {
+		t.Fatalf("encode COSE key: %v", err)
+	}
+	return encoded
+}
+
+func (a *softAuthenticator) alg() int {
+	if _, ok := a.signer.Public().(*rsa.PublicKey); ok {
+		return coseAlgRS256
+	}
+	return coseAlgES256
+}
+
+func (a *softAuthenticator) sign(t *testing.T, authData, clientDataJSON []byte) []byte {
+	t.Helper()
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := a.signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return signature
+}
+
+func (a *softAuthenticator) authData(flags byte, attestedData []byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, flags)
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return append(data, attestedData...)
+}
+
+func (a *softAuthenticator) clientData(typ, challenge string) []byte {
+	clientDataJSON, _ := json.Marshal(passkeyClientData{Type: typ, Challenge: challenge, Origin: a.origin})
+	return clientDataJSON
+}
+
+// register answers navigator.credentials.create with the given attestation format, "none" or
+// "packed" self attestation
+func (a *softAuthenticator) register(t *testing.T, options *PasskeyCreationOptions, format string) string {
+	t.Helper()
+
+	userHandle, err := decodeBase64URL(options.User.ID)
+	if err != nil {
+		t.Fatalf("decode user handle: %v", err)
+	}
+	a.userHandle = userHandle
+
+	attestedData := make([]byte, 16) // AAGUID
+	attestedData = binary.BigEndian.AppendUint16(attestedData, uint16(len(a.credentialID)))
+	attestedData = append(attestedData, a.credentialID...)
+	attestedData = append(attestedData, a.coseKey(t)...)
+
+	authData := a.authData(authDataFlagUserPresent|authDataFlagUserVerified|authDataFlagAttestedData, attestedData)
+	clientDataJSON := a.clientData("webauthn.create", options.Challenge)
+
+	statement := map[string]any{}
+	if format == "packed" {
+		statement = map[string]any{"alg": a.alg(), "sig": a.sign(t, authData, clientDataJSON)}
+	}
+	attestationObject, err := cbor.Marshal(map[string]any{"fmt": format, "attStmt": statement, "authData": authData})
+	if err != nil {
+		t.Fatalf("encode attestation: %v", err)
+	}
+
+	return a.credentialJSON(t, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestationObject),
+	})
+}
+
+// assert answers navigator.credentials.get, counting the signature
+func (a *softAuthenticator) assert(t *testing.T, options *PasskeyRequestOptions) string {
+	t.Helper()
+
+	a.signCount++
+	authData := a.authData(authDataFlagUserPresent|authDataFlagUserVerified, nil)
+	clientDataJSON := a.clientData("webauthn.get", options.Challenge)
+
+	return a.credentialJSON(t, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(a.sign(t, authData, clientDataJSON)),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+func (a *softAuthenticator) credentialJSON(t *testing.T, response map[string]string) string {
+	t.Helper()
+
+	id := base64.RawURLEncoding.EncodeToString(a.credentialID)
+	credential, err := json.Marshal(map[string]any{"id": id, "rawId": id, "type": "public-key", "response": response})
+	if err != nil {
+		t.Fatalf("encode credential: %v", err)
+	}
+	return string(credential)
+}
+
+func newTestPasskeyService(t *testing.T) (PasskeyService, *models.User) {
+	t.Helper()
+
+	db := newTestDB(t)
+	user, err := repositories.NewUsersRepository(db.DB).Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+
+	service, err := NewPasskeyService(repositories.NewPasskeysRepository(db.DB), testPasskeyOrigin)
+	if err != nil {
+		t.Fatalf("create passkey service: %v", err)
+	}
+	return service, user
+}
+
+// registerPasskey registers the authenticator's credential for the user
+func registerPasskey(t *testing.T, service PasskeyService, user *models.User, authenticator *softAuthenticator, format string) *models.Passkey {
+	t.Helper()
+
+	options, err := service.RegistrationOptions(user)
+	if err != nil {
+		t.Fatalf("registration options: %v", err)
+	}
+	passkey, err := service.FinishRegistration(user, "Laptop", authenticator.register(t, options, format))
+	if err != nil {
+		t.Fatalf("finish registration: %v", err)
+	}
+	return passkey
+}
+
+func TestPasskeyRegistrationAndSignIn(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		rsaKey bool
+		format string
+	}{
+		{"ES256 without attestation", false, "none"},
+		{"ES256 with self attestation", false, "packed"},
+		{"RS256 with self attestation", true, "packed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			service, user := newTestPasskeyService(t)
+			authenticator := newSoftAuthenticator(t, tc.rsaKey)
+
+			registered := registerPasskey(t, service, user, authenticator, tc.format)
+			if registered.UserID != user.ID || registered.Name != "Laptop" {
+				t.Fatalf("registered passkey = %+v", registered)
+			}
+
+			// Passwordless sign-in, the authenticator tells whose passkey it is
+			options, err := service.AuthenticationOptions(nil)
+			if err != nil {
+				t.Fatalf("authentication options: %v", err)
+			}
+			passkey, err := service.FinishAuthentication(nil, authenticator.assert(t, options))
+			if err != nil {
+				t.Fatalf("sign in: %v", err)
+			}
+			if passkey.ID != registered.ID || passkey.SignCount != 1 {
+				t.Errorf("signed in with %+v, want passkey %d with sign count 1", passkey, registered.ID)
+			}
+
+			// Second factor of the user
+			options, err = service.AuthenticationOptions(&user.ID)
+			if err != nil {
+				t.Fatalf("authentication options: %v", err)
+			}
+			if len(options.AllowCredentials) != 1 || options.AllowCredentials[0].ID != registered.CredentialID {
+				t.Errorf("allowed credentials = %+v, want the registered passkey", options.AllowCredentials)
+			}
+			if _, err := service.FinishAuthentication(&user.ID, authenticator.assert(t, options)); err != nil {
+				t.Fatalf("second factor: %v", err)
+			}
+		})
+	}
+}
+
+func TestPasskeyRegistrationIsRejected(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		change func(a *softAuthenticator)
+	}{
+		{"wrong origin", func(a *softAuthenticator) { a.origin = "https://evil.test" }},
+		{"wrong relying party", func(a *softAuthenticator) { a.rpID = "evil.test" }},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			service, user := newTestPasskeyService(t)
+			authenticator := newSoftAuthenticator(t, false)
+			tc.change(authenticator)
+
+			options, err := service.RegistrationOptions(user)
+			if err != nil {
+				t.Fatalf("registration options: %v", err)
+			}
+			_, err = service.FinishRegistration(user, "Laptop", authenticator.register(t, options, "none"))
+			if !errors.Is(err, ErrInvalidPasskey) {
+				t.Errorf("FinishRegistration() = %v, want ErrInvalidPasskey", err)
+			}
+		})
+	}
+}
+
+func TestPasskeyCannotBeRegisteredTwice(t *testing.T) {
+	service, user := newTestPasskeyService(t)
+	authenticator := newSoftAuthenticator(t, false)
+	registerPasskey(t, service, user, authenticator, "none")
+
+	options, err := service.RegistrationOptions(user)
+	if err != nil {
+		t.Fatalf("registration options: %v", err)
+	}
+	if len(options.ExcludeCredentials) != 1 {
+		t.Errorf("excluded credentials = %+v, want the registered passkey", options.ExcludeCredentials)
+	}
+	_, err = service.FinishRegistration(user, "Again", authenticator.register(t, options, "none"))
+	if !errors.Is(err, ErrPasskeyExists) {
+		t.Errorf("FinishRegistration() = %v, want ErrPasskeyExists", err)
+	}
+}
+
+func TestPasskeySignInIsRejected(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		// prepare changes the authenticator before it answers, tamper changes its answer
+		prepare func(a *softAuthenticator)
+		tamper  func(credential string) string
+	}{
+		{name: "wrong origin", prepare: func(a *softAuthenticator) { a.origin = "https://evil.test" }},
+		{name: "wrong relying party", prepare: func(a *softAuthenticator) { a.rpID = "evil.test" }},
+		// A clone of the authenticator that signed fewer times than the original
+		{name: "sign count regression", prepare: func(a *softAuthenticator) { a.signCount = 0 }},
+		{name: "bad signature", tamper: func(credential string) string {
+			var decoded map[string]any
+			json.Unmarshal([]byte(credential), &decoded)
+			response := decoded["response"].(map[string]any)
+			signature, _ := decodeBase64URL(response["signature"].(string))
+			signature[len(signature)-1] ^= 0xff
+			response["signature"] = base64.RawURLEncoding.EncodeToString(signature)
+			tampered, _ := json.Marshal(decoded)
+			return string(tampered)
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			service, user := newTestPasskeyService(t)
+			authenticator := newSoftAuthenticator(t, false)
+			registerPasskey(t, service, user, authenticator, "none")
+
+			// A first sign-in sets the counter
+			options, err := service.AuthenticationOptions(nil)
+			if err != nil {
+				t.Fatalf("authentication options: %v", err)
+			}
+			if _, err := service.FinishAuthentication(nil, authenticator.assert(t, options)); err != nil {
+				t.Fatalf("sign in: %v", err)
+			}
+
+			options, err = service.AuthenticationOptions(nil)
+			if err != nil {
+				t.Fatalf("authentication options: %v", err)
+			}
+			if tc.prepare != nil {
+				tc.prepare(authenticator)
+			}
+			credential := authenticator.assert(t, options)
+			if tc.tamper != nil {
+				credential = tc.tamper(credential)
+			}
+
+			_, err = service.FinishAuthentication(nil, credential)
+			if !errors.Is(err, ErrInvalidPasskey) {
+				t.Errorf("FinishAuthentication() = %v, want ErrInvalidPasskey", err)
+			}
+		})
+	}
+}
+
+func TestPasskeyChallengeIsUsedOnce(t *testing.T) {
+	service, user := newTestPasskeyService(t)
+	authenticator := newSoftAuthenticator(t, false)
+	registerPasskey(t, service, user, authenticator, "none")
+
+	options, err := service.AuthenticationOptions(nil)
+	if err != nil {
+		t.Fatalf("authentication options: %v", err)
+	}
+	credential := authenticator.assert(t, options)
+	if _, err := service.FinishAuthentication(nil, credential); err != nil {
+		t.Fatalf("sign in: %v", err)
+	}
+	if _, err := service.FinishAuthentication(nil, credential); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("replayed FinishAuthentication() = %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeySecondFactorOfAnotherUserIsRejected(t *testing.T) {
+	service, user := newTestPasskeyService(t)
+	authenticator := newSoftAuthenticator(t, false)
+	registerPasskey(t, service, user, authenticator, "none")
+
+	otherUserID := user.ID + 1
+	options, err := service.AuthenticationOptions(&otherUserID)
+	if err != nil {
+		t.Fatalf("authentication options: %v", err)
+	}
+	if _, err := service.FinishAuthentication(&otherUserID, authenticator.assert(t, options)); !errors.Is(err, ErrInvalidPasskey) {
+		t.Errorf("FinishAuthentication() = %v, want ErrInvalidPasskey", err)
+	}
+}
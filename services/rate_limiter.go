@@ -0,0 +1,189 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimiterSweepInterval is how often idle entries are dropped from memory
+	rateLimiterSweepInterval = time.Minute
+	// failureMemory is how long consecutive failures are remembered after the last one
+	failureMemory = 24 * time.Hour
+)
+
+// RateLimit is a token bucket holding up to Burst tokens, refilled with PerMinute tokens a minute
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// PerMinute returns a rate limit of n a minute that allows bursts of n
+func PerMinute(n int) RateLimit {
+	return RateLimit{PerMinute: n, Burst: n}
+}
+
+// RateLimiter keeps token buckets and failure counters by key. The in-memory implementation
+// works for a single process, a shared backend can implement it for several instances.
+type RateLimiter interface {
+	// Take takes a token from the bucket of key. If it is empty, it reports how long until it refills.
+	Take(key string, limit RateLimit) (bool, time.Duration)
+	// Wait reports how long until the bucket of key has a token, without taking it
+	Wait(key string, limit RateLimit) time.Duration
+
+	// AddFailure counts a failure of key and returns the number of consecutive failures
+	AddFailure(key string) int
+	ResetFailures(key string)
+	Lock(key string, duration time.Duration)
+	// LockedFor reports how long key stays locked
+	LockedFor(key string) time.Duration
+}
+
+type rateLimiterEntry struct {
+	tokens      float64
+	refilledAt  time.Time
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+type memoryRateLimiter struct {
+	mu        sync.Mutex
+	entries   map[string]*rateLimiterEntry
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryRateLimiter() RateLimiter {
+	return &memoryRateLimiter{
+		entries: make(map[string]*rateLimiterEntry),
+		now:     time.Now,
+	}
+}
+
+func (l *memoryRateLimiter) Take(key string, limit RateLimit) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry := l.entry(key)
+	l.refill(entry, limit)
+	if entry.tokens >= 1 {
+		entry.tokens--
+		return true, 0
+	}
+
+	return false, untilToken(entry, limit)
+}
+
+func (l *memoryRateLimiter) Wait(key string, limit RateLimit) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Keys without an entry have a full bucket. Waiting doesn't store one, so checking arbitrary keys,
+	// like made up account names, doesn't fill memory.
+	entry, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+
+	l.refill(entry, limit)
+	if entry.tokens >= 1 {
+		return 0
+	}
+
+	return untilToken(entry, limit)
+}
+
+func (l *memoryRateLimiter) AddFailure(key string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	entry := l.entry(key)
+	if now.Sub(entry.lastFailure) > failureMemory {
+		entry.failures = 0
+	}
+	entry.failures++
+	entry.lastFailure = now
+
+	return entry.failures
+}
+
+func (l *memoryRateLimiter) ResetFailures(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if entry, ok := l.entries[key]; ok {
+		entry.failures = 0
+		entry.lockedUntil = time.Time{}
+	}
+}
+
+func (l *memoryRateLimiter) Lock(key string, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.entry(key).lockedUntil = l.now().Add(duration)
+}
+
+func (l *memoryRateLimiter) LockedFor(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		return 0
+	}
+
+	return max(entry.lockedUntil.Sub(l.now()), 0)
+}
+
+// entry returns the entry of key, creating it with a full bucket. The caller holds the lock.
+func (l *memoryRateLimiter) entry(key string) *rateLimiterEntry {
+	l.sweep()
+
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &rateLimiterEntry{tokens: math.Inf(1), refilledAt: l.now()}
+		l.entries[key] = entry
+	}
+	return entry
+}
+
+// refill adds the tokens accumulated since the bucket was last used
+func (l *memoryRateLimiter) refill(entry *rateLimiterEntry, limit RateLimit) {
+	now := l.now()
+
+	elapsed := now.Sub(entry.refilledAt).Minutes()
+	entry.tokens = math.Min(float64(limit.Burst), entry.tokens+elapsed*float64(limit.PerMinute))
+	entry.refilledAt = now
+}
+
+// sweep drops entries that hold no state anymore: a full bucket, no lock and no failures to remember.
+// The caller holds the lock.
+func (l *memoryRateLimiter) sweep() {
+	now := l.now()
+	if now.Sub(l.lastSweep) < rateLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, entry := range l.entries {
+		idle := now.Sub(entry.refilledAt) > time.Hour
+		unlocked := now.After(entry.lockedUntil)
+		forgotten := entry.failures == 0 || now.Sub(entry.lastFailure) > failureMemory
+		if idle && unlocked && forgotten {
+			delete(l.entries, key)
+		}
+	}
+}
+
+func untilToken(entry *rateLimiterEntry, limit RateLimit) time.Duration {
+	if limit.PerMinute <= 0 {
+		return time.Hour
+	}
+
+	missing := 1 - entry.tokens
+	return time.Duration(missing / float64(limit.PerMinute) * float64(time.Minute))
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTakesTokens(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	limiter := NewMemoryRateLimiter().(*memoryRateLimiter)
+	limiter.now = func() time.Time { return now }
+	limit := PerMinute(2)
+
+	for i := range 2 {
+		if ok, _ := limiter.Take("key", limit); !ok {
+			t.Fatalf("take %d was refused", i+1)
+		}
+	}
+	ok, wait := limiter.Take("key", limit)
+	if ok || wait != 30*time.Second {
+		t.Fatalf("Take() on an empty bucket = %v, %v, want false, 30s", ok, wait)
+	}
+	if wait := limiter.Wait("key", limit); wait != 30*time.Second {
+		t.Errorf("Wait() = %v, want 30s", wait)
+	}
+
+	now = now.Add(30 * time.Second)
+	if ok, _ := limiter.Take("key", limit); !ok {
+		t.Error("take after the refill was refused")
+	}
+}
+
+func TestAuthThrottleCheckDoesNotStoreUnknownAccounts(t *testing.T) {
+	limiter := NewMemoryRateLimiter().(*memoryRateLimiter)
+	throttle := NewAuthThrottleService(limiter, nil, AuthThrottleLimits{
+		IPRateLimit:      20,
+		AccountRateLimit: 10,
+	})
+
+	r := httptest.NewRequest("POST", "/auth/sign-in", nil)
+	for i := range 1000 {
+		if wait := throttle.Check(r, nil, fmt.Sprintf("random-%d", i)); wait != 0 {
+			t.Fatalf("Check() = %v, want 0", wait)
+		}
+	}
+
+	if n := len(limiter.entries); n != 0 {
+		t.Errorf("the limiter holds %d entries after checks alone, want 0", n)
+	}
+}
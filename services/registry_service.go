@@ -0,0 +1,683 @@
+package services
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const (
+	// RegistryTokenLifetime is how long the tokens of the registry token endpoint are valid, clients
+	// ask for a new one when it expires
+	RegistryTokenLifetime = 5 * time.Minute
+	// RegistryMaxManifestSize limits the manifests clients can push
+	RegistryMaxManifestSize = 4 << 20
+
+	// registryUploadLifetime is how long unfinished blob uploads are kept after their last chunk
+	registryUploadLifetime = 24 * time.Hour
+)
+
+// Media types of the manifests the registry accepts
+const (
+	RegistryMediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	RegistryMediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	RegistryMediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	RegistryMediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// Error codes of the OCI distribution specification
+const (
+	RegistryErrorBlobUnknown         = "BLOB_UNKNOWN"
+	RegistryErrorBlobUploadInvalid   = "BLOB_UPLOAD_INVALID"
+	RegistryErrorBlobUploadUnknown   = "BLOB_UPLOAD_UNKNOWN"
+	RegistryErrorDigestInvalid       = "DIGEST_INVALID"
+	RegistryErrorManifestBlobUnknown = "MANIFEST_BLOB_UNKNOWN"
+	RegistryErrorManifestInvalid     = "MANIFEST_INVALID"
+	RegistryErrorManifestUnknown     = "MANIFEST_UNKNOWN"
+	RegistryErrorNameInvalid         = "NAME_INVALID"
+	RegistryErrorNameUnknown         = "NAME_UNKNOWN"
+	RegistryErrorSizeInvalid         = "SIZE_INVALID"
+	RegistryErrorTagInvalid          = "TAG_INVALID"
+	RegistryErrorUnauthorized        = "UNAUTHORIZED"
+	RegistryErrorDenied              = "DENIED"
+	RegistryErrorUnsupported         = "UNSUPPORTED"
+)
+
+// Actions of registry tokens
+const (
+	RegistryActionPull   = "pull"
+	RegistryActionPush   = "push"
+	RegistryActionDelete = "delete"
+)
+
+var (
+	// registryNameComponentRegex matches a component of an image name, names are lower case
+	registryNameComponentRegex = regexp.MustCompile(`^[a-z0-9]+(?:(?:\.|_|__|-+)[a-z0-9]+)*$`)
+	registryTagRegex           = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+	registryDigestRegex        = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	registryUploadIDRegex      = regexp.MustCompile(`^[a-f0-9]{32}$`)
+)
+
+// RegistryError is an error response of the registry, with a code of the OCI distribution
+// specification
+type RegistryError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RegistryError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func registryError(code, message string) *RegistryError {
+	return &RegistryError{Code: code, Message: message}
+}
+
+// RegistryDescriptor describes content of the registry, as in manifests
+type RegistryDescriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// registryManifestJSON holds the fields of image manifests and indexes the registry reads
+type registryManifestJSON struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     string               `json:"mediaType"`
+	ArtifactType  string               `json:"artifactType"`
+	Config        *RegistryDescriptor  `json:"config"`
+	Layers        []RegistryDescriptor `json:"layers"`
+	Manifests     []RegistryDescriptor `json:"manifests"`
+	Subject       *RegistryDescriptor  `json:"subject"`
+	Annotations   map[string]string    `json:"annotations"`
+}
+
+// RegistryAccess is what a registry token grants on an image, in the format of the Docker token
+// specification
+type RegistryAccess struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Actions []string `json:"actions"`
+}
+
+// RegistryToken is a short-lived token of the registry token endpoint. It grants its user the
+// actions of Access, as far as the user still may do them.
+type RegistryToken struct {
+	// UserID is 0 for tokens of anonymous users
+	UserID    int64            `json:"sub,omitempty"`
+	Access    []RegistryAccess `json:"access"`
+	ExpiresAt int64            `json:"exp"`
+}
+
+// Allows reports whether the token grants the action on the image
+func (t *RegistryToken) Allows(name, action string) bool {
+	for _, access := range t.Access {
+		if access.Type == "repository" && access.Name == name && slices.Contains(access.Actions, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// RegistryService stores the container images of repositories for the OCI distribution protocol.
+// Images are named <owner>/<repo>, or <owner>/<repo>/<path> for more images of a repository, in
+// lower case. Blobs are stored once on disk by digest and linked to the repositories they were pushed
+// to, they are removed when no repository links them anymore.
+type RegistryService interface {
+	// ParseName returns the owner and name of the repository of an image name and the path of the
+	// image below it, ok is false if the name isn't valid
+	ParseName(name string) (owner, repo, image string, ok bool)
+
+	// IssueToken returns a registry token granting the user, 0 for anonymous, the access
+	IssueToken(userID int64, access []RegistryAccess) (string, error)
+	// ParseToken returns the registry token of rawToken, nil if it isn't a valid and unexpired one
+	ParseToken(rawToken string) *RegistryToken
+
+	// FindBlob returns the blob of the repository, nil if it has no such blob
+	FindBlob(repo *models.Repository, digest string) (*models.RegistryBlob, error)
+	OpenBlob(blob *models.RegistryBlob) (*os.File, error)
+	// MountBlob links a blob of another repository to the repository, it returns nil if the other
+	// repository has no such blob
+	MountBlob(repo, from *models.Repository, digest string) (*models.RegistryBlob, error)
+	DeleteBlob(repo *models.Repository, digest string) error
+
+	// StartUpload starts a blob upload to the repository and returns its ID
+	StartUpload(repo *models.Repository) (string, error)
+	// UploadSize returns how many bytes were uploaded so far
+	UploadSize(repo *models.Repository, id string) (int64, error)
+	// AppendUpload appends a chunk to the upload and returns its new size. Uploads growing past the
+	// maximum blob size are cancelled with SIZE_INVALID. The chunk has to start at
+	// offset, unless offset is negative.
+	AppendUpload(repo *models.Repository, id string, offset int64, chunk io.Reader) (int64, error)
+	// FinishUpload checks the upload against its digest and stores it as a blob of the repository
+	FinishUpload(repo *models.Repository, id, digest string) (*models.RegistryBlob, error)
+	CancelUpload(repo *models.Repository, id string) error
+	// ExpireUploads removes the uploads of every repository that nothing was appended to for a day
+	ExpireUploads() error
+
+	// PutManifest stores the manifest of the image under reference, a tag or its digest. The blobs
+	// and manifests it refers to have to be in the repository, except for its subject.
+	PutManifest(repo *models.Repository, image, reference, mediaType string, content []byte) (*models.RegistryManifest, error)
+	// FindManifest returns the manifest of the image by tag or digest, nil if there is none
+	FindManifest(repo *models.Repository, image, reference string) (*models.RegistryManifest, error)
+	// DeleteManifest deletes a tag, or a manifest by digest along with its tags
+	DeleteManifest(repo *models.Repository, image, reference string) error
+	// Tags lists up to limit tags of the image in lexical order after last, all of them if limit is
+	// negative
+	Tags(repo *models.Repository, image, last string, limit int) ([]string, error)
+	// Referrers returns the descriptors of the manifests of the image whose subject is digest,
+	// optionally only those of an artifact type
+	Referrers(repo *models.Repository, image, digest, artifactType string) ([]RegistryDescriptor, error)
+
+	// DeleteRepository deletes the images of a repository being deleted
+	DeleteRepository(repo *models.Repository) error
+}
+
+type registryService struct {
+	blobs       repositories.RegistryBlobsRepository
+	manifests   repositories.RegistryManifestsRepository
+	tags        repositories.RegistryTagsRepository
+	tokenKey    []byte
+	storagePath string
+	maxBlobSize int64
+	// mu serializes linking and unlinking blobs with writing and removing their files, so a blob
+	// isn't removed while it is pushed again
+	mu sync.Mutex
+}
+
+func NewRegistryService(
+	blobs repositories.RegistryBlobsRepository,
+	manifests repositories.RegistryManifestsRepository,
+	tags repositories.RegistryTagsRepository,
+	signingSecret string,
+	storagePath string,
+	maxBlobSize int64,
+) (RegistryService, error) {
+	tokenKey, err := hkdf.Key(sha256.New, []byte(signingSecret), nil, "hypercommit registry tokens", 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &registryService{
+		blobs:       blobs,
+		manifests:   manifests,
+		tags:        tags,
+		tokenKey:    tokenKey,
+		storagePath: storagePath,
+		maxBlobSize: maxBlobSize,
+	}, nil
+}
+
+func (s *registryService) ParseName(name string) (string, string, string, bool) {
+	components := strings.Split(name, "/")
+	if len(components) < 2 || len(name) > 255 {
+		return "", "", "", false
+	}
+	for _, component := range components {
+		if !registryNameComponentRegex.MatchString(component) {
+			return "", "", "", false
+		}
+	}
+	return components[0], components[1], strings.Join(components[2:], "/"), true
+}
+
+func (s *registryService) IssueToken(userID int64, access []RegistryAccess) (string, error) {
+	if access == nil {
+		access = []RegistryAccess{}
+	}
+	payload, err := json.Marshal(&RegistryToken{
+		UserID:    userID,
+		Access:    access,
+		ExpiresAt: time.Now().Add(RegistryTokenLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + s.signToken(encoded), nil
+}
+
+func (s *registryService) ParseToken(rawToken string) *RegistryToken {
+	encoded, signature, found := strings.Cut(rawToken, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(s.signToken(encoded))) {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil
+	}
+
+	token := &RegistryToken{}
+	if err := json.Unmarshal(payload, token); err != nil || time.Now().Unix() >= token.ExpiresAt {
+		return nil
+	}
+	return token
+}
+
+func (s *registryService) signToken(encoded string) string {
+	mac := hmac.New(sha256.New, s.tokenKey)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *registryService) FindBlob(repo *models.Repository, digest string) (*models.RegistryBlob, error) {
+	if !registryDigestRegex.MatchString(digest) {
+		return nil, nil
+	}
+	return s.blobs.FindByDigest(repo.ID, digest)
+}
+
+func (s *registryService) OpenBlob(blob *models.RegistryBlob) (*os.File, error) {
+	return os.Open(s.blobPath(blob.Digest))
+}
+
+func (s *registryService) MountBlob(repo, from *models.Repository, digest string) (*models.RegistryBlob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blob, err := s.FindBlob(from, digest)
+	if err != nil || blob == nil {
+		return nil, err
+	}
+	return s.blobs.Link(repo.ID, blob.Digest, blob.Size)
+}
+
+func (s *registryService) DeleteBlob(repo *models.Repository, digest string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.blobs.Delete(repo.ID, digest); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return registryError(RegistryErrorBlobUnknown, "blob unknown to registry")
+		}
+		return err
+	}
+	return s.removeUnlinkedBlob(digest)
+}
+
+// removeUnlinkedBlob removes the file of the blob if no repository links it anymore, s.mu has to be
+// held
+func (s *registryService) removeUnlinkedBlob(digest string) error {
+	count, err := s.blobs.CountByDigest(digest)
+	if err != nil || count > 0 {
+		return err
+	}
+	if err := os.Remove(s.blobPath(digest)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *registryService) StartUpload(repo *models.Repository) (string, error) {
+	dir := s.uploadsPath(repo)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+
+	file, err := os.OpenFile(filepath.Join(dir, id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	return id, file.Close()
+}
+
+func (s *registryService) ExpireUploads() error {
+	repoDirs, err := os.ReadDir(filepath.Join(s.storagePath, "uploads"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	for _, repoDir := range repoDirs {
+		dir := filepath.Join(s.storagePath, "uploads", repoDir.Name())
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err == nil && uploadExpired(info) {
+				if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// uploadExpired reports whether the upload was abandoned, appending a chunk keeps it alive
+func uploadExpired(info os.FileInfo) bool {
+	return time.Since(info.ModTime()) > registryUploadLifetime
+}
+
+func (s *registryService) UploadSize(repo *models.Repository, id string) (int64, error) {
+	path, err := s.uploadPath(repo, id)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return 0, registryError(RegistryErrorBlobUploadUnknown, "blob upload unknown to registry")
+		}
+		return 0, err
+	}
+	// Expired uploads are gone even if they weren't removed yet
+	if uploadExpired(info) {
+		os.Remove(path)
+		return 0, registryError(RegistryErrorBlobUploadUnknown, "blob upload unknown to registry")
+	}
+	return info.Size(), nil
+}
+
+func (s *registryService) AppendUpload(repo *models.Repository, id string, offset int64, chunk io.Reader) (int64, error) {
+	size, err := s.UploadSize(repo, id)
+	if err != nil {
+		return 0, err
+	}
+	if offset >= 0 && offset != size {
+		return 0, registryError(RegistryErrorBlobUploadInvalid, fmt.Sprintf("the chunk starts at %d but the upload has %d bytes", offset, size))
+	}
+
+	path, _ := s.uploadPath(repo, id)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	// Copy one byte past the maximum to tell uploads that reach it from those that exceed it
+	written, err := io.Copy(file, io.LimitReader(chunk, s.maxBlobSize-size+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size+written > s.maxBlobSize {
+		os.Remove(path)
+		return 0, registryError(RegistryErrorSizeInvalid, fmt.Sprintf("blobs are at most %d bytes", s.maxBlobSize))
+	}
+	return size + written, err
+}
+
+func (s *registryService) FinishUpload(repo *models.Repository, id, digest string) (*models.RegistryBlob, error) {
+	path, err := s.uploadPath(repo, id)
+	if err != nil {
+		return nil, err
+	}
+	if !registryDigestRegex.MatchString(digest) {
+		return nil, registryError(RegistryErrorDigestInvalid, "the digest has to be a sha256 digest, such as sha256:<64 hex digits>")
+	}
+	if _, err := s.UploadSize(repo, id); err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, registryError(RegistryErrorBlobUploadUnknown, "blob upload unknown to registry")
+		}
+		return nil, err
+	}
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	if actual := "sha256:" + hex.EncodeToString(hash.Sum(nil)); actual != digest {
+		os.Remove(path)
+		return nil, registryError(RegistryErrorDigestInvalid, "the digest of the upload is "+actual)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	blobPath := s.blobPath(digest)
+	if _, err := os.Stat(blobPath); err == nil {
+		os.Remove(path)
+	} else {
+		if err := os.MkdirAll(filepath.Dir(blobPath), 0o755); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(path, blobPath); err != nil {
+			return nil, err
+		}
+	}
+	return s.blobs.Link(repo.ID, digest, size)
+}
+
+func (s *registryService) CancelUpload(repo *models.Repository, id string) error {
+	path, err := s.uploadPath(repo, id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return registryError(RegistryErrorBlobUploadUnknown, "blob upload unknown to registry")
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *registryService) PutManifest(repo *models.Repository, image, reference, mediaType string, content []byte) (*models.RegistryManifest, error) {
+	sum := sha256.Sum256(content)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	isDigest := strings.Contains(reference, ":")
+	if isDigest && reference != digest {
+		return nil, registryError(RegistryErrorDigestInvalid, "the digest of the manifest is "+digest)
+	}
+	if !isDigest && !registryTagRegex.MatchString(reference) {
+		return nil, registryError(RegistryErrorTagInvalid, "tags consist of up to 128 letters, digits, _, . and -, and don't start with . or -")
+	}
+
+	manifest := &registryManifestJSON{}
+	if err := json.Unmarshal(content, manifest); err != nil {
+		return nil, registryError(RegistryErrorManifestInvalid, "the manifest isn't valid JSON: "+err.Error())
+	}
+	if manifest.SchemaVersion != 2 {
+		return nil, registryError(RegistryErrorManifestInvalid, "only manifests of schema version 2 are supported")
+	}
+	if mediaType == "" {
+		mediaType = manifest.MediaType
+	}
+	if manifest.MediaType != "" && manifest.MediaType != mediaType {
+		return nil, registryError(RegistryErrorManifestInvalid, "the media type of the manifest doesn't match its Content-Type")
+	}
+
+	switch mediaType {
+	case RegistryMediaTypeOCIManifest, RegistryMediaTypeDockerManifest:
+		if manifest.Config == nil {
+			return nil, registryError(RegistryErrorManifestInvalid, "the manifest has no config")
+		}
+		for _, descriptor := range append([]RegistryDescriptor{*manifest.Config}, manifest.Layers...) {
+			// Foreign layers are downloaded from elsewhere and never pushed
+			if strings.Contains(descriptor.MediaType, "foreign") || strings.Contains(descriptor.MediaType, "nondistributable") {
+				continue
+			}
+			blob, err := s.FindBlob(repo, descriptor.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if blob == nil {
+				return nil, registryError(RegistryErrorManifestBlobUnknown, "blob "+descriptor.Digest+" of the manifest is unknown to the registry")
+			}
+		}
+	case RegistryMediaTypeOCIIndex, RegistryMediaTypeDockerList:
+		for _, descriptor := range manifest.Manifests {
+			child, err := s.manifests.FindByDigest(repo.ID, image, descriptor.Digest)
+			if err != nil {
+				return nil, err
+			}
+			if child == nil {
+				return nil, registryError(RegistryErrorManifestBlobUnknown, "manifest "+descriptor.Digest+" of the index is unknown to the registry")
+			}
+		}
+	default:
+		return nil, registryError(RegistryErrorManifestInvalid, "unsupported manifest media type "+strconv.Quote(mediaType))
+	}
+
+	var subjectDigest *string
+	if manifest.Subject != nil {
+		if !registryDigestRegex.MatchString(manifest.Subject.Digest) {
+			return nil, registryError(RegistryErrorManifestInvalid, "the digest of the subject is invalid")
+		}
+		subjectDigest = &manifest.Subject.Digest
+	}
+
+	created, err := s.manifests.Create(&models.RegistryManifest{
+		RepositoryID:  repo.ID,
+		Image:         image,
+		Digest:        digest,
+		MediaType:     mediaType,
+		Content:       content,
+		SubjectDigest: subjectDigest,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !isDigest {
+		if err := s.tags.Set(repo.ID, image, reference, created.ID); err != nil {
+			return nil, err
+		}
+	}
+	return created, nil
+}
+
+func (s *registryService) FindManifest(repo *models.Repository, image, reference string) (*models.RegistryManifest, error) {
+	if strings.Contains(reference, ":") {
+		return s.manifests.FindByDigest(repo.ID, image, reference)
+	}
+	return s.manifests.FindByTag(repo.ID, image, reference)
+}
+
+func (s *registryService) DeleteManifest(repo *models.Repository, image, reference string) error {
+	if !strings.Contains(reference, ":") {
+		if err := s.tags.Delete(repo.ID, image, reference); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return registryError(RegistryErrorManifestUnknown, "manifest unknown to registry")
+			}
+			return err
+		}
+		return nil
+	}
+
+	manifest, err := s.manifests.FindByDigest(repo.ID, image, reference)
+	if err != nil {
+		return err
+	}
+	if manifest == nil {
+		return registryError(RegistryErrorManifestUnknown, "manifest unknown to registry")
+	}
+	if err := s.tags.DeleteAllByManifest(manifest.ID); err != nil {
+		return err
+	}
+	return s.manifests.Delete(manifest.ID)
+}
+
+func (s *registryService) Tags(repo *models.Repository, image, last string, limit int) ([]string, error) {
+	return s.tags.FindNames(repo.ID, image, last, limit)
+}
+
+func (s *registryService) Referrers(repo *models.Repository, image, digest, artifactType string) ([]RegistryDescriptor, error) {
+	manifests, err := s.manifests.FindAllBySubject(repo.ID, image, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	descriptors := []RegistryDescriptor{}
+	for _, manifest := range manifests {
+		content := &registryManifestJSON{}
+		if err := json.Unmarshal(manifest.Content, content); err != nil {
+			return nil, err
+		}
+
+		// Images without an artifact type are typed by their config
+		descriptorType := content.ArtifactType
+		if descriptorType == "" && content.Config != nil {
+			descriptorType = content.Config.MediaType
+		}
+		if artifactType != "" && descriptorType != artifactType {
+			continue
+		}
+
+		descriptors = append(descriptors, RegistryDescriptor{
+			MediaType:    manifest.MediaType,
+			Digest:       manifest.Digest,
+			Size:         int64(len(manifest.Content)),
+			ArtifactType: descriptorType,
+			Annotations:  content.Annotations,
+		})
+	}
+	return descriptors, nil
+}
+
+func (s *registryService) DeleteRepository(repo *models.Repository) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	digests, err := s.blobs.FindAllDigestsByRepository(repo.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.blobs.DeleteAllByRepository(repo.ID); err != nil {
+		return err
+	}
+	if err := s.tags.DeleteAllByRepository(repo.ID); err != nil {
+		return err
+	}
+	if err := s.manifests.DeleteAllByRepository(repo.ID); err != nil {
+		return err
+	}
+
+	for _, digest := range digests {
+		if err := s.removeUnlinkedBlob(digest); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(s.uploadsPath(repo))
+}
+
+// blobPath is where the blob of a sha256 digest is stored, in a directory per first byte
+func (s *registryService) blobPath(digest string) string {
+	hexDigest := strings.TrimPrefix(digest, "sha256:")
+	return filepath.Join(s.storagePath, "blobs", "sha256", hexDigest[:2], hexDigest)
+}
+
+func (s *registryService) uploadsPath(repo *models.Repository) string {
+	return filepath.Join(s.storagePath, "uploads", strconv.FormatInt(repo.ID, 10))
+}
+
+func (s *registryService) uploadPath(repo *models.Repository, id string) (string, error) {
+	if !registryUploadIDRegex.MatchString(id) {
+		return "", registryError(RegistryErrorBlobUploadUnknown, "blob upload unknown to registry")
+	}
+	return filepath.Join(s.uploadsPath(repo), id), nil
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+func newTestRegistryService(t *testing.T, maxBlobSize int64) (RegistryService, string) {
+	t.Helper()
+
+	db := newTestDB(t)
+	dir := t.TempDir()
+	registry, err := NewRegistryService(
+		repositories.NewRegistryBlobsRepository(db.DB),
+		repositories.NewRegistryManifestsRepository(db.DB),
+		repositories.NewRegistryTagsRepository(db.DB),
+		"secret",
+		dir,
+		maxBlobSize,
+	)
+	if err != nil {
+		t.Fatalf("registry service: %v", err)
+	}
+	return registry, dir
+}
+
+// registryErrorCode returns the OCI error code of err, empty if it isn't a registry error
+func registryErrorCode(err error) string {
+	var registryErr *RegistryError
+	if errors.As(err, &registryErr) {
+		return registryErr.Code
+	}
+	return ""
+}
+
+func TestUploadsAreLimitedToTheMaximumBlobSize(t *testing.T) {
+	registry, _ := newTestRegistryService(t, 10)
+	repo := &models.Repository{ID: 1}
+
+	id, err := registry.StartUpload(repo)
+	if err != nil {
+		t.Fatalf("start upload: %v", err)
+	}
+	if size, err := registry.AppendUpload(repo, id, 0, strings.NewReader("0123456789")); err != nil || size != 10 {
+		t.Fatalf("append up to the maximum = %d, %v, want 10 bytes", size, err)
+	}
+	if _, err := registry.FinishUpload(repo, id, "sha256:84d89877f0d4041efb6bf91a16f0248f2fd573e6af05c19f96bedb9f882f7882"); err != nil {
+		t.Fatalf("finish upload at the maximum: %v", err)
+	}
+
+	// Monolithic uploads and chunks that grow the upload past the maximum are both refused
+	for _, chunks := range [][]string{{"0123456789a"}, {"012345", "6789a"}} {
+		id, err := registry.StartUpload(repo)
+		if err != nil {
+			t.Fatalf("start upload: %v", err)
+		}
+		var offset int64
+		for _, chunk := range chunks {
+			offset, err = registry.AppendUpload(repo, id, offset, strings.NewReader(chunk))
+		}
+		if code := registryErrorCode(err); code != RegistryErrorSizeInvalid {
+			t.Errorf("chunks %q: error %v, want %s", chunks, err, RegistryErrorSizeInvalid)
+		}
+		if _, err := registry.UploadSize(repo, id); registryErrorCode(err) != RegistryErrorBlobUploadUnknown {
+			t.Errorf("chunks %q: upload still exists after exceeding the maximum", chunks)
+		}
+	}
+}
+
+func TestAbandonedUploadsExpire(t *testing.T) {
+	registry, dir := newTestRegistryService(t, 1<<20)
+	repo := &models.Repository{ID: 1}
+
+	abandoned, err := registry.StartUpload(repo)
+	if err != nil {
+		t.Fatalf("start upload: %v", err)
+	}
+	swept, err := registry.StartUpload(&models.Repository{ID: 2})
+	if err != nil {
+		t.Fatalf("start upload: %v", err)
+	}
+	active, err := registry.StartUpload(repo)
+	if err != nil {
+		t.Fatalf("start upload: %v", err)
+	}
+
+	longAgo := time.Now().Add(-registryUploadLifetime - time.Minute)
+	for _, path := range []string{filepath.Join(dir, "uploads", "1", abandoned), filepath.Join(dir, "uploads", "2", swept)} {
+		if err := os.Chtimes(path, longAgo, longAgo); err != nil {
+			t.Fatalf("age upload: %v", err)
+		}
+	}
+
+	// Expired uploads can't be continued even before they're swept
+	if _, err := registry.AppendUpload(repo, abandoned, 0, strings.NewReader("layer")); registryErrorCode(err) != RegistryErrorBlobUploadUnknown {
+		t.Errorf("append to an expired upload: error %v, want %s", err, RegistryErrorBlobUploadUnknown)
+	}
+
+	if err := registry.ExpireUploads(); err != nil {
+		t.Fatalf("expire uploads: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "uploads", "2", swept)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("the abandoned upload of another repository wasn't removed: %v", err)
+	}
+	if size, err := registry.AppendUpload(repo, active, 0, strings.NewReader("layer")); err != nil || size != 5 {
+		t.Errorf("append to an active upload = %d, %v, want 5 bytes", size, err)
+	}
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+// RepositoryDeletionService deletes repositories, and users with the repositories they own, along with
+// everything that belongs to them in the database and on disk
+type RepositoryDeletionService interface {
+	// Delete deletes the repository with its git data, webhooks, secrets, Go module versions, container
+	// images, tickets and workflow runs, and unlinks its packages. actor is who deleted it.
+	Delete(repo *models.Repository, actor *models.User) error
+	// DeleteUser deletes the user with the repositories and packages they own, and returns how many
+	// repositories were deleted. actor is who deleted the user.
+	DeleteUser(user *models.User, actor *models.User) (int, error)
+}
+
+type repositoryDeletionService struct {
+	repos          repositories.RepositoriesRepository
+	users          repositories.UsersRepository
+	webhooks       repositories.WebhooksRepository
+	secrets        repositories.SecretsRepository
+	packages       repositories.PackagesRepository
+	git            GitService
+	goModules      GoModuleService
+	registry       RegistryService
+	packageService PackageService
+	webhookSvc     WebhookService
+}
+
+func NewRepositoryDeletionService(
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	webhooks repositories.WebhooksRepository,
+	secrets repositories.SecretsRepository,
+	packages repositories.PackagesRepository,
+	git GitService,
+	goModules GoModuleService,
+	registry RegistryService,
+	packageService PackageService,
+	webhookSvc WebhookService,
+) RepositoryDeletionService {
+	return &repositoryDeletionService{
+		repos:          repos,
+		users:          users,
+		webhooks:       webhooks,
+		secrets:        secrets,
+		packages:       packages,
+		git:            git,
+		goModules:      goModules,
+		registry:       registry,
+		packageService: packageService,
+		webhookSvc:     webhookSvc,
+	}
+}
+
+func (s *repositoryDeletionService) Delete(repo *models.Repository, actor *models.User) error {
+	if err := s.repos.Delete(repo.ID); err != nil {
+		return err
+	}
+
+	// The organization's webhooks hear about the deletion, the repository's own go with it. The
+	// repository row is gone, so what fails from here on is logged rather than returned.
+	s.webhookSvc.Repository(repo, "deleted", actor)
+	if err := s.webhooks.DeleteAllByRepository(repo.ID); err != nil {
+		slog.Error("failed to delete repository webhooks", "error", err, "repository_id", repo.ID)
+	}
+	if err := s.secrets.DeleteAllByRepository(repo.ID); err != nil {
+		slog.Error("failed to delete repository secrets", "error", err, "repository_id", repo.ID)
+	}
+	if err := s.goModules.DeleteRepository(repo); err != nil {
+		slog.Error("failed to delete repository Go module versions", "error", err, "repository_id", repo.ID)
+	}
+	if err := s.registry.DeleteRepository(repo); err != nil {
+		slog.Error("failed to delete repository container images", "error", err, "repository_id", repo.ID)
+	}
+	if err := s.packageService.UnlinkRepository(repo); err != nil {
+		slog.Error("failed to unlink repository packages", "error", err, "repository_id", repo.ID)
+	}
+	if err := os.RemoveAll(s.git.RepositoryPath(repo)); err != nil {
+		slog.Error("failed to delete repository directory", "error", err, "repository_id", repo.ID)
+	}
+	return nil
+}
+
+func (s *repositoryDeletionService) DeleteUser(user *models.User, actor *models.User) (int, error) {
+	repos, err := s.repos.FindAllByUser(user.ID)
+	if err != nil {
+		return 0, err
+	}
+	for i, repo := range repos {
+		if err := s.Delete(repo, actor); err != nil {
+			return i, fmt.Errorf("failed to delete repository %d: %w", repo.ID, err)
+		}
+	}
+
+	packages, err := s.packages.FindAllByUser(user.ID)
+	if err != nil {
+		return len(repos), err
+	}
+	for _, pkg := range packages {
+		if err := s.packageService.Delete(pkg); err != nil {
+			return len(repos), fmt.Errorf("failed to delete package %d: %w", pkg.ID, err)
+		}
+	}
+
+	return len(repos), s.users.Delete(user.ID)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+func newTestRepositoryDeletionService(t *testing.T, db *database.DB, dir string) (RepositoryDeletionService, GitService, PackageService) {
+	t.Helper()
+
+	repos := repositories.NewRepositoriesRepository(db.DB)
+	users := repositories.NewUsersRepository(db.DB)
+	webhooks := repositories.NewWebhooksRepository(db.DB)
+	packages := repositories.NewPackagesRepository(db.DB)
+	git := NewGitService(filepath.Join(dir, "repos"))
+	registry, err := NewRegistryService(
+		repositories.NewRegistryBlobsRepository(db.DB),
+		repositories.NewRegistryManifestsRepository(db.DB),
+		repositories.NewRegistryTagsRepository(db.DB),
+		"secret",
+		filepath.Join(dir, "registry"),
+		1<<20,
+	)
+	if err != nil {
+		t.Fatalf("registry service: %v", err)
+	}
+	packageService := NewPackageService(
+		packages,
+		repositories.NewPackageVersionsRepository(db.DB),
+		repositories.NewPackageFilesRepository(db.DB),
+		repositories.NewPackageDistTagsRepository(db.DB),
+		repos,
+		filepath.Join(dir, "packages"),
+	)
+
+	deletion := NewRepositoryDeletionService(
+		repos,
+		users,
+		webhooks,
+		repositories.NewSecretsRepository(db.DB),
+		packages,
+		git,
+		NewGoModuleService(repositories.NewGoModuleVersionsRepository(db.DB), git, "http://localhost", filepath.Join(dir, "gomod")),
+		registry,
+		packageService,
+		NewWebhookService(webhooks, repositories.NewWebhookDeliveriesRepository(db.DB), repos, users, repositories.NewOrganizationsRepository(db.DB), repositories.NewInstanceSettingsRepository(db.DB), git, "http://localhost"),
+	)
+	return deletion, git, packageService
+}
+
+// countRows returns how many rows of table match where
+func countRows(t *testing.T, db *database.DB, table, where string, args ...any) int {
+	t.Helper()
+
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM `+table+` WHERE `+where, args...).Scan(&count); err != nil {
+		t.Fatalf("count %s: %v", table, err)
+	}
+	return count
+}
+
+func mustExec(t *testing.T, db *database.DB, query string, args ...any) {
+	t.Helper()
+
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+func TestDeleteUserLeavesNothingBehind(t *testing.T) {
+	db := newTestDB(t)
+	dir := t.TempDir()
+	deletion, git, packageService := newTestRepositoryDeletionService(t, db, dir)
+
+	users := repositories.NewUsersRepository(db.DB)
+	repos := repositories.NewRepositoriesRepository(db.DB)
+	alice, err := users.Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	bob, err := users.Create("bob", "bob@example.com", "Bob", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	repo, err := repos.CreateForUser(alice.ID, "app", "public", "main", nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+	if err := git.InitRepository(repo); err != nil {
+		t.Fatalf("init repository: %v", err)
+	}
+	other, err := repos.CreateForUser(bob.ID, "lib", "public", "main", nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+
+	mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'write')`, repo.ID, bob.ID)
+	mustExec(t, db, `INSERT INTO stars (repository_id, user_id) VALUES (?, ?)`, repo.ID, bob.ID)
+	mustExec(t, db, `INSERT INTO tickets (repository_id, number, title, author_id) VALUES (?, 1, 'Bug', ?)`, repo.ID, bob.ID)
+	mustExec(t, db, `INSERT INTO ticket_comments (ticket_id, author_id, body) VALUES ((SELECT id FROM tickets WHERE repository_id = ?), ?, 'Same here')`, repo.ID, bob.ID)
+	mustExec(t, db, `INSERT INTO notifications (user_id, ticket_id, actor_id, reason) VALUES (?, (SELECT id FROM tickets WHERE repository_id = ?), ?, 'comment')`, bob.ID, repo.ID, alice.ID)
+	mustExec(t, db, `INSERT INTO commit_statuses (repository_id, sha, state, context, creator_id) VALUES (?, 'abc', 'success', 'ci', ?)`, repo.ID, alice.ID)
+	mustExec(t, db, `INSERT INTO workflow_runs (repository_id, number, workflow, name, event, ref, sha, triggered_by_id) VALUES (?, 1, 'ci.yml', 'CI', 'push', 'refs/heads/main', 'abc', ?)`, repo.ID, alice.ID)
+	mustExec(t, db, `INSERT INTO workflow_jobs (run_id, repository_id, name, definition) VALUES ((SELECT id FROM workflow_runs WHERE repository_id = ?), ?, 'test', '{}')`, repo.ID, repo.ID)
+	mustExec(t, db, `INSERT INTO webhooks (repository_id, url) VALUES (?, 'http://example.com/hook')`, repo.ID)
+	mustExec(t, db, `INSERT INTO secrets (repository_id, name, value, creator_id) VALUES (?, 'TOKEN', x'00', ?)`, repo.ID, alice.ID)
+
+	// Alice pushed to Bob's repository, the run stays with Bob's repository
+	mustExec(t, db, `INSERT INTO workflow_runs (repository_id, number, workflow, name, event, ref, sha, triggered_by_id) VALUES (?, 1, 'ci.yml', 'CI', 'push', 'refs/heads/main', 'def', ?)`, other.ID, alice.ID)
+
+	pkg, err := packageService.Create(&alice.ID, nil, models.PackageTypeRaw, "tools")
+	if err != nil {
+		t.Fatalf("create package: %v", err)
+	}
+	if _, err := packageService.UploadFile(pkg, alice, "1.0.0", "tool.tar.gz", strings.NewReader("tool")); err != nil {
+		t.Fatalf("upload package file: %v", err)
+	}
+
+	deleted, err := deletion.DeleteUser(alice, alice)
+	if err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("deleted %d repositories, want 1", deleted)
+	}
+
+	for _, table := range []string{"contributors", "stars", "tickets", "commit_statuses", "workflow_runs", "workflow_jobs", "webhooks", "secrets"} {
+		if n := countRows(t, db, table, `repository_id = ?`, repo.ID); n != 0 {
+			t.Errorf("%d rows of the deleted repository left in %s", n, table)
+		}
+	}
+	if n := countRows(t, db, "ticket_comments", `ticket_id NOT IN (SELECT id FROM tickets)`); n != 0 {
+		t.Errorf("%d comments of deleted tickets left", n)
+	}
+	if n := countRows(t, db, "notifications", `ticket_id NOT IN (SELECT id FROM tickets)`); n != 0 {
+		t.Errorf("%d notifications of deleted tickets left", n)
+	}
+	if n := countRows(t, db, "packages", `owner_user_id = ?`, alice.ID); n != 0 {
+		t.Errorf("%d packages of the deleted user left", n)
+	}
+	if n := countRows(t, db, "package_files", `1 = 1`); n != 0 {
+		t.Errorf("%d package files left", n)
+	}
+	if n := countRows(t, db, "workflow_runs", `repository_id = ? AND triggered_by_id IS NULL`, other.ID); n != 1 {
+		t.Errorf("the run Alice triggered in another repository was not kept without her")
+	}
+	if n := countRows(t, db, "users", `id = ?`, alice.ID); n != 0 {
+		t.Errorf("user was not deleted")
+	}
+
+	if _, err := os.Stat(git.RepositoryPath(repo)); !os.IsNotExist(err) {
+		t.Errorf("repository directory left on disk: %v", err)
+	}
+	entries, err := os.ReadDir(filepath.Join(dir, "packages"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("read package storage: %v", err)
+	}
+	for _, entry := range entries {
+		if err := filepath.WalkDir(filepath.Join(dir, "packages", entry.Name()), func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				t.Errorf("package file left on disk: %s", path)
+			}
+			return err
+		}); err != nil {
+			t.Fatalf("walk package storage: %v", err)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const (
+	maxSecretNameLength = 100
+	// MaxSecretValueSize bounds the values of secrets, they end up in the environment of jobs
+	MaxSecretValueSize = 48 << 10
+)
+
+// secretNameRegex matches the names of secrets, which name environment variables
+var secretNameRegex = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+
+var (
+	ErrInvalidSecretName  = errors.New("names consist of letters, digits and _, and don't start with a digit or HYPERCOMMIT_")
+	ErrInvalidSecretValue = errors.New("values must be 1 byte to 48 KiB")
+)
+
+// SecretAuditTarget is named after the secret and its repository or organization, scope is the
+// full name of the repository or the name of the organization
+func SecretAuditTarget(secret *models.Secret, scope string, orgID *int64) AuditTarget {
+	return AuditTarget{Type: models.AuditTargetSecret, ID: &secret.ID, Name: scope + "/" + secret.Name, OrgID: orgID}
+}
+
+// SecretService encrypts the secrets of workflows and decrypts them for the jobs that see them
+type SecretService interface {
+	// NormalizeName upper-cases a secret name, failing with ErrInvalidSecretName if it isn't valid
+	NormalizeName(name string) (string, error)
+	// Seal encrypts value as the value of the secret, which must have its name and owner set
+	Seal(secret *models.Secret, value string) error
+	// ForJob returns the secrets the job sees as environment variables, the secrets of the organization
+	// owning the repository overridden by those of the repository. Jobs of pushes only see them while
+	// the user who pushed may still write to the repository. Handing them to the runner is recorded in
+	// the audit log.
+	ForJob(r *http.Request, run *models.WorkflowRun, job *models.WorkflowJob, runner string) (map[string]string, error)
+}
+
+type secretService struct {
+	secrets      repositories.SecretsRepository
+	repos        repositories.RepositoriesRepository
+	users        repositories.UsersRepository
+	orgs         repositories.OrganizationsRepository
+	orgMembers   repositories.OrganizationMembersRepository
+	contributors repositories.ContributorsRepository
+	twoFactor    TwoFactorService
+	audit        AuditService
+	aead         cipher.AEAD
+}
+
+// NewSecretService derives the AES-256 key encrypting secrets from key
+func NewSecretService(
+	secrets repositories.SecretsRepository,
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	orgMembers repositories.OrganizationMembersRepository,
+	contributors repositories.ContributorsRepository,
+	twoFactor TwoFactorService,
+	audit AuditService,
+	key string,
+) (SecretService, error) {
+	derived, err := hkdf.Key(sha256.New, []byte(key), nil, "hypercommit workflow secrets", 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &secretService{
+		secrets:      secrets,
+		repos:        repos,
+		users:        users,
+		orgs:         orgs,
+		orgMembers:   orgMembers,
+		contributors: contributors,
+		twoFactor:    twoFactor,
+		audit:        audit,
+		aead:         aead,
+	}, nil
+}
+
+func (s *secretService) NormalizeName(name string) (string, error) {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	if len(name) > maxSecretNameLength || !secretNameRegex.MatchString(name) || strings.HasPrefix(name, "HYPERCOMMIT_") {
+		return "", ErrInvalidSecretName
+	}
+	return name, nil
+}
+
+func (s *secretService) Seal(secret *models.Secret, value string) error {
+	if value == "" || len(value) > MaxSecretValueSize {
+		return ErrInvalidSecretValue
+	}
+
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	secret.EncryptedValue = s.aead.Seal(nonce, nonce, []byte(value), secretAdditionalData(secret))
+	return nil
+}
+
+// open decrypts the value of the secret
+func (s *secretService) open(secret *models.Secret) (string, error) {
+	nonceSize := s.aead.NonceSize()
+	if len(secret.EncryptedValue) < nonceSize {
+		return "", fmt.Errorf("secret %d is corrupted", secret.ID)
+	}
+
+	nonce, ciphertext := secret.EncryptedValue[:nonceSize], secret.EncryptedValue[nonceSize:]
+	value, err := s.aead.Open(nil, nonce, ciphertext, secretAdditionalData(secret))
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt secret %d, was the secrets key changed? %w", secret.ID, err)
+	}
+	return string(value), nil
+}
+
+// secretAdditionalData binds the ciphertext to the owner and name of the secret, so a value can't be
+// moved to another secret in the database
+func secretAdditionalData(secret *models.Secret) []byte {
+	if secret.RepositoryID != nil {
+		return []byte("repository:" + strconv.FormatInt(*secret.RepositoryID, 10) + ":" + secret.Name)
+	}
+	if secret.OrganizationID != nil {
+		return []byte("organization:" + strconv.FormatInt(*secret.OrganizationID, 10) + ":" + secret.Name)
+	}
+	return []byte(":" + secret.Name)
+}
+
+func (s *secretService) ForJob(r *http.Request, run *models.WorkflowRun, job *models.WorkflowJob, runner string) (map[string]string, error) {
+	repo, err := s.repos.FindByID(job.RepositoryID)
+	if err != nil || repo == nil {
+		return nil, err
+	}
+
+	// Ticket runs execute the default branch, push and tag runs what was pushed. That code is only
+	// trusted with the secrets while whoever pushed it may still write to the repository.
+	if run.Event != "ticket" {
+		writer, err := s.canWrite(repo, run.TriggeredByID)
+		if err != nil {
+			return nil, err
+		}
+		if !writer {
+			slog.Warn("withholding secrets from a run pushed by someone who can't write to the repository", "repository_id", repo.ID, "run", run.Number)
+			return nil, nil
+		}
+	}
+
+	var secrets []*models.Secret
+	if repo.OwnerOrgID != nil {
+		secrets, err = s.secrets.FindAllByOrganization(*repo.OwnerOrgID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	repoSecrets, err := s.secrets.FindAllByRepository(repo.ID)
+	if err != nil {
+		return nil, err
+	}
+	secrets = append(secrets, repoSecrets...)
+	if len(secrets) == 0 {
+		return nil, nil
+	}
+
+	values := make(map[string]string, len(secrets))
+	for _, secret := range secrets {
+		value, err := s.open(secret)
+		if err != nil {
+			return nil, err
+		}
+		values[secret.Name] = value
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	s.audit.Record(r, nil, models.AuditSecretAccess, RepositoryAuditTarget(s.ownerName(repo), repo), map[string]string{
+		"secrets": strings.Join(names, " "),
+		"run":     strconv.FormatInt(run.Number, 10),
+		"job":     job.Name,
+		"runner":  runner,
+	})
+
+	return values, nil
+}
+
+// canWrite reports whether the user may push to the repository, with the rules of git and the API:
+// owners and organization members may, contributors with the write or admin role too, as long as
+// they have two-factor authentication if the organization requires it
+func (s *secretService) canWrite(repo *models.Repository, userID *int64) (bool, error) {
+	if userID == nil {
+		return false, nil
+	}
+	user, err := s.users.FindByID(*userID)
+	if err != nil || user == nil || user.SuspendedAt != nil {
+		return false, err
+	}
+	if repo.OwnerUserID != nil && *repo.OwnerUserID == user.ID {
+		return true, nil
+	}
+
+	writer := false
+	if repo.OwnerOrgID != nil {
+		membership, err := s.orgMembers.FindByOrganizationAndUser(*repo.OwnerOrgID, user.ID)
+		if err != nil {
+			return false, err
+		}
+		writer = membership != nil
+	}
+	if !writer {
+		contributor, err := s.contributors.FindByRepositoryAndUser(repo.ID, user.ID)
+		if err != nil {
+			return false, err
+		}
+		writer = contributor != nil && (contributor.Role == "write" || contributor.Role == "admin")
+	}
+	if !writer || repo.OwnerOrgID == nil {
+		return writer, nil
+	}
+
+	org, err := s.orgs.FindByID(*repo.OwnerOrgID)
+	if err != nil || org == nil {
+		return false, err
+	}
+	if !org.RequireTwoFactor {
+		return true, nil
+	}
+	return s.twoFactor.IsEnabled(user.ID)
+}
+
+// ownerName returns the username of the user or organization owning the repository
+func (s *secretService) ownerName(repo *models.Repository) string {
+	if repo.OwnerUserID != nil {
+		if user, err := s.users.FindByID(*repo.OwnerUserID); err == nil && user != nil {
+			return user.Username
+		}
+	} else if repo.OwnerOrgID != nil {
+		if org, err := s.orgs.FindByID(*repo.OwnerOrgID); err == nil && org != nil {
+			return org.Username
+		}
+	}
+	return ""
+}
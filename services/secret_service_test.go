@@ -0,0 +1,130 @@
+package services
+
+import (
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+func TestSecretsForJobOnlyReachPushesOfWriters(t *testing.T) {
+	db := newTestDB(t)
+	secretsRepo := repositories.NewSecretsRepository(db.DB)
+	users := repositories.NewUsersRepository(db.DB)
+	repos := repositories.NewRepositoriesRepository(db.DB)
+	runs := repositories.NewWorkflowRunsRepository(db.DB)
+	secrets, err := NewSecretService(
+		secretsRepo,
+		repos,
+		users,
+		repositories.NewOrganizationsRepository(db.DB),
+		repositories.NewOrganizationMembersRepository(db.DB),
+		repositories.NewContributorsRepository(db.DB),
+		NewTwoFactorService(repositories.NewTwoFactorRepository(db.DB)),
+		NewAuditService(repositories.NewAuditEventsRepository(db.DB)),
+		"secrets key",
+	)
+	if err != nil {
+		t.Fatalf("secret service: %v", err)
+	}
+
+	accounts := map[string]int64{}
+	for _, username := range []string{"owner", "member", "writer", "reader", "outsider", "suspended", "insecure"} {
+		user, err := users.Create(username, username+"@example.com", username, "hash")
+		if err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+		accounts[username] = user.ID
+	}
+	mustExec(t, db, `UPDATE users SET suspended_at = unixepoch() WHERE id = ?`, accounts["suspended"])
+	for _, username := range []string{"owner", "member"} {
+		mustExec(t, db, `INSERT INTO two_factor_credentials (user_id, secret, confirmed_at) VALUES (?, 'secret', unixepoch())`, accounts[username])
+	}
+
+	mustExec(t, db, `INSERT INTO organizations (username, display_name) VALUES ('acme', 'Acme')`)
+	mustExec(t, db, `INSERT INTO organizations (username, display_name, require_two_factor) VALUES ('secure', 'Secure', 1)`)
+	for _, org := range []string{"acme", "secure"} {
+		for _, member := range []string{"owner", "member", "suspended", "insecure"} {
+			role := models.OrganizationRoleMember
+			if member == "owner" {
+				role = models.OrganizationRoleOwner
+			}
+			mustExec(t, db, `INSERT INTO organization_members (organization_id, user_id, role) VALUES ((SELECT id FROM organizations WHERE username = ?), ?, ?)`, org, accounts[member], role)
+		}
+	}
+
+	newRepo := func(org string) *models.Repository {
+		t.Helper()
+
+		var orgID int64
+		if err := db.QueryRow(`SELECT id FROM organizations WHERE username = ?`, org).Scan(&orgID); err != nil {
+			t.Fatalf("find organization: %v", err)
+		}
+		repo, err := repos.CreateForOrg(orgID, "app", "private", "main", nil)
+		if err != nil {
+			t.Fatalf("create repository: %v", err)
+		}
+		mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'write')`, repo.ID, accounts["writer"])
+		mustExec(t, db, `INSERT INTO contributors (repository_id, user_id, role) VALUES (?, ?, 'read')`, repo.ID, accounts["reader"])
+
+		for _, secret := range []*models.Secret{
+			{OrganizationID: &orgID, Name: "REGISTRY_TOKEN"},
+			{RepositoryID: &repo.ID, Name: "DEPLOY_KEY"},
+		} {
+			if err := secrets.Seal(secret, secret.Name+" value"); err != nil {
+				t.Fatalf("seal secret: %v", err)
+			}
+			if _, err := secretsRepo.Create(secret); err != nil {
+				t.Fatalf("create secret: %v", err)
+			}
+		}
+		return repo
+	}
+	acme, secure := newRepo("acme"), newRepo("secure")
+	all := map[string]string{"REGISTRY_TOKEN": "REGISTRY_TOKEN value", "DEPLOY_KEY": "DEPLOY_KEY value"}
+
+	for _, tt := range []struct {
+		name  string
+		repo  *models.Repository
+		event string
+		// triggeredBy is who pushed, nobody if empty
+		triggeredBy string
+		want        map[string]string
+	}{
+		{"organization owner", acme, "push", "owner", all},
+		{"organization member", acme, "push", "member", all},
+		{"write contributor", acme, "tag", "writer", all},
+		{"read contributor", acme, "push", "reader", nil},
+		{"non-member", acme, "push", "outsider", nil},
+		{"suspended member", acme, "push", "suspended", nil},
+		{"deleted pusher", acme, "push", "", nil},
+		{"ticket opened by a non-member", acme, "ticket", "outsider", all},
+		{"member with two-factor authentication", secure, "push", "member", all},
+		{"member without required two-factor authentication", secure, "push", "insecure", nil},
+	} {
+		run := &models.WorkflowRun{RepositoryID: tt.repo.ID, Workflow: "ci.yml", Name: "CI", Event: tt.event, Ref: "refs/heads/main", SHA: "abc"}
+		if tt.triggeredBy != "" {
+			id := accounts[tt.triggeredBy]
+			run.TriggeredByID = &id
+		}
+		run, err := runs.CreateRun(run)
+		if err != nil {
+			t.Fatalf("create run: %v", err)
+		}
+		job, err := runs.CreateJob(&models.WorkflowJob{RunID: run.ID, RepositoryID: tt.repo.ID, Name: "test", Definition: "{}"})
+		if err != nil {
+			t.Fatalf("create job: %v", err)
+		}
+
+		got, err := secrets.ForJob(httptest.NewRequest(http.MethodPost, "/api/runner/jobs/claim", nil), run, job, "runner")
+		if err != nil {
+			t.Fatalf("%s: secrets for job: %v", tt.name, err)
+		}
+		if !maps.Equal(got, tt.want) {
+			t.Errorf("%s: job got secrets %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Package smtptest provides an in-process SMTP server that records every message
+// it receives, so code sending mail can be exercised without a real mail server.
+package smtptest
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+)
+
+// Message is an email as received by the server
+type Message struct {
+	From string
+	To   []string
+	Data string // raw message, including headers
+}
+
+type Server struct {
+	// Addr is the host:port the server listens on
+	Addr string
+
+	listener net.Listener
+	mu       sync.Mutex
+	messages []Message
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server listening on a random local port.
+// Callers should Close it when done.
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		Addr:     listener.Addr().String(),
+		listener: listener,
+	}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Host and Port split Addr for use with services.NewSMTPMailer
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+func (s *Server) Port() string {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	return port
+}
+
+// Messages returns a copy of all messages received so far
+func (s *Server) Messages() []Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	messages := make([]Message, len(s.messages))
+	copy(messages, s.messages)
+	return messages
+}
+
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle speaks just enough SMTP for net/smtp: EHLO, AUTH PLAIN (any credentials
+// are accepted), MAIL, RCPT, DATA, RSET, NOOP and QUIT
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	reply := func(line string) bool {
+		return text.PrintfLine("%s", line) == nil
+	}
+
+	if !reply("220 smtptest ready") {
+		return
+	}
+
+	var current Message
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			if text.PrintfLine("250-smtptest") != nil || !reply("250 AUTH PLAIN") {
+				return
+			}
+		case "HELO":
+			reply("250 smtptest")
+		case "AUTH":
+			reply("235 authentication successful")
+		case "MAIL":
+			current = Message{From: extractAddress(arg)}
+			reply("250 ok")
+		case "RCPT":
+			current.To = append(current.To, extractAddress(arg))
+			reply("250 ok")
+		case "DATA":
+			if !reply("354 end data with <CR><LF>.<CR><LF>") {
+				return
+			}
+			lines, err := text.ReadDotLines()
+			if err != nil {
+				return
+			}
+			current.Data = strings.Join(lines, "\r\n")
+
+			s.mu.Lock()
+			s.messages = append(s.messages, current)
+			s.mu.Unlock()
+
+			current = Message{}
+			reply("250 ok")
+		case "RSET":
+			current = Message{}
+			reply("250 ok")
+		case "NOOP":
+			reply("250 ok")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+// extractAddress turns "FROM:<a@b.c>" or "TO:<a@b.c> SIZE=1" into "a@b.c"
+func extractAddress(arg string) string {
+	start := strings.Index(arg, "<")
+	end := strings.Index(arg, ">")
+	if start == -1 || end < start {
+		return ""
+	}
+	return arg[start+1 : end]
+}
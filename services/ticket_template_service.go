@@ -0,0 +1,182 @@
+package services
+
+import (
+	"bufio"
+	"path"
+	"sort"
+	"strings"
+)
+
+const TicketTemplatesPath = ".hypercommit/TICKET_TEMPLATE"
+
+type TicketTemplate struct {
+	File      string // file name inside TicketTemplatesPath, used as identifier
+	Name      string
+	About     string
+	Title     string
+	Labels    []string
+	Assignees []string
+	Body      string
+}
+
+type TicketTemplateService interface {
+	ListTemplates(repoPath, ref string) ([]TicketTemplate, error)
+	FindTemplate(repoPath, ref, file string) (*TicketTemplate, error)
+}
+
+type ticketTemplateService struct {
+	gitService GitService
+}
+
+func NewTicketTemplateService(gitService GitService) TicketTemplateService {
+	return &ticketTemplateService{
+		gitService: gitService,
+	}
+}
+
+// ListTemplates returns the ticket templates defined on the given ref, sorted by name.
+// A repository without a template directory simply has no templates.
+func (s *ticketTemplateService) ListTemplates(repoPath, ref string) ([]TicketTemplate, error) {
+	entries, err := s.gitService.ListTree(repoPath, ref, TicketTemplatesPath)
+	if err != nil {
+		return []TicketTemplate{}, nil
+	}
+
+	templates := make([]TicketTemplate, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Type != "blob" || !strings.HasSuffix(strings.ToLower(entry.Name), ".md") {
+			continue
+		}
+
+		content, err := s.gitService.GetFileContent(repoPath, ref, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		templates = append(templates, parseTicketTemplate(entry.Name, string(content)))
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return strings.ToLower(templates[i].Name) < strings.ToLower(templates[j].Name)
+	})
+
+	return templates, nil
+}
+
+// FindTemplate returns a single template by file name, or nil if it does not exist
+func (s *ticketTemplateService) FindTemplate(repoPath, ref, file string) (*TicketTemplate, error) {
+	// Only plain file names are accepted, templates can't live outside the template directory
+	if file == "" || file != path.Base(file) || !strings.HasSuffix(strings.ToLower(file), ".md") {
+		return nil, nil
+	}
+
+	content, err := s.gitService.GetFileContent(repoPath, ref, TicketTemplatesPath+"/"+file)
+	if err != nil {
+		return nil, nil
+	}
+
+	template := parseTicketTemplate(file, string(content))
+	return &template, nil
+}
+
+// parseTicketTemplate splits a template into its YAML front-matter and markdown body.
+// Only the small YAML subset used by templates is supported: scalar values,
+// inline lists ([a, b]) and block lists (- a).
+func parseTicketTemplate(file, content string) TicketTemplate {
+	template := TicketTemplate{
+		File: file,
+		Name: strings.TrimSuffix(file, path.Ext(file)),
+	}
+
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	if !strings.HasPrefix(content, "---\n") {
+		template.Body = content
+		return template
+	}
+
+	frontMatter, body, found := strings.Cut(content[len("---\n"):], "\n---")
+	if !found {
+		template.Body = content
+		return template
+	}
+	// Drop the remainder of the closing delimiter line
+	if _, rest, ok := strings.Cut(body, "\n"); ok {
+		template.Body = strings.TrimLeft(rest, "\n")
+	}
+
+	var currentKey string
+	scanner := bufio.NewScanner(strings.NewReader(frontMatter))
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		// Block list item belonging to the previous key
+		if strings.HasPrefix(trimmed, "- ") && currentKey != "" {
+			template.appendList(currentKey, unquoteYAML(strings.TrimSpace(trimmed[2:])))
+			continue
+		}
+
+		key, value, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			continue
+		}
+		currentKey = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch currentKey {
+		case "name":
+			template.Name = unquoteYAML(value)
+		case "about":
+			template.About = unquoteYAML(value)
+		case "title":
+			template.Title = unquoteYAML(value)
+		case "labels", "assignees":
+			for _, item := range splitYAMLList(value) {
+				template.appendList(currentKey, item)
+			}
+		}
+	}
+
+	return template
+}
+
+func (t *TicketTemplate) appendList(key, value string) {
+	if value == "" {
+		return
+	}
+
+	switch key {
+	case "labels":
+		t.Labels = append(t.Labels, value)
+	case "assignees":
+		t.Assignees = append(t.Assignees, strings.TrimPrefix(value, "@"))
+	}
+}
+
+// splitYAMLList handles both `[a, b]` and the comma separated shorthand `a, b`
+func splitYAMLList(value string) []string {
+	value = strings.TrimSuffix(strings.TrimPrefix(value, "["), "]")
+	if strings.TrimSpace(value) == "" {
+		return nil
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = unquoteYAML(strings.TrimSpace(item)); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func unquoteYAML(value string) string {
+	if len(value) >= 2 {
+		if (value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'') {
+			return value[1 : len(value)-1]
+		}
+	}
+	return value
+}
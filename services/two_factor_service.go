@@ -0,0 +1,306 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+	"github.com/hypercommithq/hypercommit/httputil"
+)
+
+const (
+	CookieNameTwoFactorChallenge = "hypercommit_two_factor"
+)
+
+const (
+	// totpPeriod and totpDigits match what authenticator apps expect by default
+	totpPeriod = 30
+	totpDigits = 6
+	// totpSkew is how many periods before and after now are accepted, to allow for clock drift
+	totpSkew = 1
+	// recoveryCodeCount is how many recovery codes a user gets
+	recoveryCodeCount = 10
+	// twoFactorChallengeTTL is how long a user has to enter the code after the password
+	twoFactorChallengeTTL = 5 * time.Minute
+	// maxTwoFactorAttempts bounds how many codes can be tried for a single sign-in
+	maxTwoFactorAttempts = 5
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnrolling   = errors.New("two-factor enrollment has not been started")
+	ErrInvalidTwoFactorCode    = errors.New("invalid two-factor code")
+)
+
+// TwoFactorService handles TOTP enrollment, recovery codes and the second step of sign-in
+type TwoFactorService interface {
+	IsEnabled(userID int64) (bool, error)
+	BeginEnrollment(userID int64) (*models.TwoFactorCredential, error)
+	ProvisioningURI(user *models.User, secret string) string
+	ConfirmEnrollment(userID int64, code string) ([]string, error)
+	Verify(userID int64, code string) (bool, error)
+	Disable(userID int64) error
+	RegenerateRecoveryCodes(userID int64) ([]string, error)
+	RemainingRecoveryCodes(userID int64) (int, error)
+
+	BeginChallenge(w http.ResponseWriter, r *http.Request, userID int64) error
+	PendingChallenge(r *http.Request) (*models.TwoFactorChallenge, error)
+	FailChallenge(challenge *models.TwoFactorChallenge) (bool, error)
+	EndChallenge(w http.ResponseWriter, r *http.Request, challenge *models.TwoFactorChallenge) error
+}
+
+type twoFactorService struct {
+	twoFactor repositories.TwoFactorRepository
+}
+
+func NewTwoFactorService(twoFactor repositories.TwoFactorRepository) TwoFactorService {
+	return &twoFactorService{twoFactor: twoFactor}
+}
+
+func (s *twoFactorService) IsEnabled(userID int64) (bool, error) {
+	credential, err := s.twoFactor.FindCredential(userID)
+	if err != nil {
+		return false, err
+	}
+
+	return credential != nil && credential.ConfirmedAt != nil, nil
+}
+
+// BeginEnrollment returns the unconfirmed secret of the user, generating one if needed.
+// An existing one is reused so reloading the setup page doesn't invalidate a scanned QR code.
+func (s *twoFactorService) BeginEnrollment(userID int64) (*models.TwoFactorCredential, error) {
+	credential, err := s.twoFactor.FindCredential(userID)
+	if err != nil {
+		return nil, err
+	}
+	if credential != nil {
+		if credential.ConfirmedAt != nil {
+			return nil, ErrTwoFactorAlreadyEnabled
+		}
+		return credential, nil
+	}
+
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+
+	return s.twoFactor.SaveCredential(userID, secret)
+}
+
+// ProvisioningURI returns the otpauth:// URI authenticator apps read from the QR code
+func (s *twoFactorService) ProvisioningURI(user *models.User, secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", "Hypercommit")
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprintf("%d", totpDigits))
+	params.Set("period", fmt.Sprintf("%d", totpPeriod))
+
+	label := url.PathEscape("Hypercommit:" + user.Username)
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
+
+// ConfirmEnrollment enables two-factor authentication once the user proved their app
+// generates valid codes. It returns the recovery codes in plain text, they can't be shown again.
+func (s *twoFactorService) ConfirmEnrollment(userID int64, code string) ([]string, error) {
+	credential, err := s.twoFactor.FindCredential(userID)
+	if err != nil {
+		return nil, err
+	}
+	if credential == nil {
+		return nil, ErrTwoFactorNotEnrolling
+	}
+	if credential.ConfirmedAt != nil {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	step, ok := matchTOTP(credential.Secret, normalizeTwoFactorCode(code), time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	if err := s.twoFactor.ConfirmCredential(userID, step); err != nil {
+		return nil, err
+	}
+
+	return s.RegenerateRecoveryCodes(userID)
+}
+
+// Verify checks a code from the authenticator app or an unused recovery code.
+// Each code is only accepted once.
+func (s *twoFactorService) Verify(userID int64, code string) (bool, error) {
+	credential, err := s.twoFactor.FindCredential(userID)
+	if err != nil || credential == nil || credential.ConfirmedAt == nil {
+		return false, err
+	}
+
+	code = normalizeTwoFactorCode(code)
+
+	if len(code) == totpDigits {
+		step, ok := matchTOTP(credential.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		return s.twoFactor.UseStep(userID, step)
+	}
+
+	return s.twoFactor.UseRecoveryCode(userID, hashRecoveryCode(code))
+}
+
+func (s *twoFactorService) Disable(userID int64) error {
+	return s.twoFactor.DeleteCredential(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes of the user and returns the new ones in plain text
+func (s *twoFactorService) RegenerateRecoveryCodes(userID int64) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		raw := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))[:10]
+
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		hashes = append(hashes, hashRecoveryCode(raw))
+	}
+
+	if err := s.twoFactor.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func (s *twoFactorService) RemainingRecoveryCodes(userID int64) (int, error) {
+	return s.twoFactor.CountUnusedRecoveryCodes(userID)
+}
+
+// BeginChallenge remembers that the user passed the first factor and sets the challenge cookie
+func (s *twoFactorService) BeginChallenge(w http.ResponseWriter, r *http.Request, userID int64) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	now := time.Now()
+	if err := s.twoFactor.DeleteExpiredChallenges(now.Unix()); err != nil {
+		return err
+	}
+
+	if _, err := s.twoFactor.CreateChallenge(userID, hashChallengeToken(token), now.Add(twoFactorChallengeTTL).Unix()); err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieNameTwoFactorChallenge,
+		Value:    token,
+		Path:     "/auth",
+		HttpOnly: true,
+		Secure:   httputil.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(twoFactorChallengeTTL.Seconds()),
+	})
+
+	return nil
+}
+
+// PendingChallenge returns the unexpired challenge of the request, or nil
+func (s *twoFactorService) PendingChallenge(r *http.Request) (*models.TwoFactorChallenge, error) {
+	cookie, err := r.Cookie(CookieNameTwoFactorChallenge)
+	if err != nil || cookie.Value == "" {
+		return nil, nil
+	}
+
+	return s.twoFactor.FindValidChallenge(hashChallengeToken(cookie.Value), time.Now().Unix())
+}
+
+// FailChallenge records a wrong code. It reports false once the challenge has run out of
+// attempts, the challenge is deleted then and the user has to start over.
+func (s *twoFactorService) FailChallenge(challenge *models.TwoFactorChallenge) (bool, error) {
+	if challenge.Attempts+1 >= maxTwoFactorAttempts {
+		return false, s.twoFactor.DeleteChallenge(challenge.ID)
+	}
+
+	return true, s.twoFactor.IncrementChallengeAttempts(challenge.ID)
+}
+
+// EndChallenge deletes the challenge and clears its cookie
+func (s *twoFactorService) EndChallenge(w http.ResponseWriter, r *http.Request, challenge *models.TwoFactorChallenge) error {
+	http.SetCookie(w, &http.Cookie{
+		Name:   CookieNameTwoFactorChallenge,
+		Value:  "",
+		Path:   "/auth",
+		MaxAge: -1,
+	})
+
+	if challenge == nil {
+		return nil
+	}
+
+	return s.twoFactor.DeleteChallenge(challenge.ID)
+}
+
+// matchTOTP returns the time step the code belongs to, accepting a small clock drift
+func matchTOTP(secret, code string, now time.Time) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// totpCode computes the code for a time step as specified in RFC 6238
+func totpCode(key []byte, step int64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// normalizeTwoFactorCode strips the separators people type or paste along with a code
+func normalizeTwoFactorCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer(" ", "", "-", "").Replace(code)
+}
+
+func hashRecoveryCode(code string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(code)))
+}
+
+func hashChallengeToken(token string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(token)))
+}
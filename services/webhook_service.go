@@ -0,0 +1,506 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const (
+	// webhookZeroID is the object ID of a ref that doesn't exist, before it's created or after it's deleted
+	webhookZeroID = "0000000000000000000000000000000000000000"
+	// webhookPushCommitsLimit bounds how many commits a push payload lists
+	webhookPushCommitsLimit = 20
+)
+
+var (
+	ErrWebhookURLInvalid      = errors.New("webhook URL must be an http or https URL")
+	ErrWebhookURLUnresolvable = errors.New("webhook URL host could not be resolved")
+	ErrWebhookURLPrivate      = errors.New("webhook URL resolves to a private network address")
+)
+
+// WebhookService queues the deliveries of webhooks subscribed to an event. Failures are logged, they
+// never fail the request that caused the event.
+type WebhookService interface {
+	// Push queues a push event for every ref that differs between the snapshots of ListRefs taken
+	// around a push
+	Push(repo *models.Repository, pusher *models.User, before, after map[string]string)
+	// Ticket queues a ticket event, action is opened, edited, closed or reopened
+	Ticket(repo *models.Repository, ticket *models.Ticket, action string, actor *models.User)
+	// TicketComment queues a ticket_comment event, action is created, edited or deleted
+	TicketComment(repo *models.Repository, ticket *models.Ticket, comment *models.TicketComment, action string, actor *models.User)
+	// Star queues a star event, action is created or deleted
+	Star(repo *models.Repository, action string, actor *models.User)
+	// Repository queues a repository event, action is created or deleted. Deleted repositories only
+	// notify the webhooks of their organization.
+	Repository(repo *models.Repository, action string, actor *models.User)
+	// RepositoryUpdated queues the repository events describing how previous became repo: renamed,
+	// publicized, privatized and edited for the other settings
+	RepositoryUpdated(previous, repo *models.Repository, actor *models.User)
+	// Ping queues a ping to check that the webhook is reachable
+	Ping(webhook *models.Webhook, actor *models.User)
+	// Redeliver queues the payload of a delivery again, under the same GUID
+	Redeliver(delivery *models.WebhookDelivery) (*models.WebhookDelivery, error)
+	// ValidateURL checks that webhooks may be delivered to rawURL. Private network addresses are
+	// refused unless an administrator allows them.
+	ValidateURL(ctx context.Context, rawURL string) error
+}
+
+type webhookService struct {
+	webhooks         repositories.WebhooksRepository
+	deliveries       repositories.WebhookDeliveriesRepository
+	repos            repositories.RepositoriesRepository
+	users            repositories.UsersRepository
+	orgs             repositories.OrganizationsRepository
+	instanceSettings repositories.InstanceSettingsRepository
+	git              GitService
+	publicURL        string
+}
+
+func NewWebhookService(
+	webhooks repositories.WebhooksRepository,
+	deliveries repositories.WebhookDeliveriesRepository,
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	instanceSettings repositories.InstanceSettingsRepository,
+	git GitService,
+	publicURL string,
+) WebhookService {
+	return &webhookService{
+		webhooks:         webhooks,
+		deliveries:       deliveries,
+		repos:            repos,
+		users:            users,
+		orgs:             orgs,
+		instanceSettings: instanceSettings,
+		git:              git,
+		publicURL:        publicURL,
+	}
+}
+
+type webhookUserPayload struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+	HTMLURL  string `json:"html_url"`
+}
+
+type webhookRepositoryPayload struct {
+	ID            int64   `json:"id"`
+	Name          string  `json:"name"`
+	FullName      string  `json:"full_name"`
+	Owner         string  `json:"owner"`
+	Description   *string `json:"description"`
+	Visibility    string  `json:"visibility"`
+	DefaultBranch string  `json:"default_branch"`
+	HTMLURL       string  `json:"html_url"`
+	CloneURL      string  `json:"clone_url"`
+	CreatedAt     string  `json:"created_at"`
+	UpdatedAt     string  `json:"updated_at"`
+}
+
+type webhookTicketPayload struct {
+	Number    int64   `json:"number"`
+	Title     string  `json:"title"`
+	Body      *string `json:"body"`
+	State     string  `json:"state"`
+	Author    string  `json:"author"`
+	HTMLURL   string  `json:"html_url"`
+	ClosedAt  *string `json:"closed_at"`
+	CreatedAt string  `json:"created_at"`
+	UpdatedAt string  `json:"updated_at"`
+}
+
+type webhookCommentPayload struct {
+	ID        int64  `json:"id"`
+	Body      string `json:"body"`
+	Author    string `json:"author"`
+	CreatedAt string `json:"created_at"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+type webhookCommitPayload struct {
+	ID        string `json:"id"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+	URL       string `json:"url"`
+	Author    struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+}
+
+type webhookHookPayload struct {
+	ID          int64    `json:"id"`
+	Type        string   `json:"type"`
+	Events      []string `json:"events"`
+	Active      bool     `json:"active"`
+	URL         string   `json:"url"`
+	ContentType string   `json:"content_type"`
+}
+
+func (s *webhookService) Push(repo *models.Repository, pusher *models.User, before, after map[string]string) {
+	// Commits that were reachable before the push aren't new, whichever ref they were on
+	var known []string
+	for _, objectID := range before {
+		if !slices.Contains(known, objectID) {
+			known = append(known, objectID)
+		}
+	}
+
+	refs := make([]string, 0, len(after))
+	for ref := range after {
+		refs = append(refs, ref)
+	}
+	for ref := range before {
+		if _, ok := after[ref]; !ok {
+			refs = append(refs, ref)
+		}
+	}
+	slices.Sort(refs)
+
+	for _, ref := range refs {
+		previous, ok := before[ref]
+		if !ok {
+			previous = webhookZeroID
+		}
+		current, ok := after[ref]
+		if !ok {
+			current = webhookZeroID
+		}
+		if previous == current {
+			continue
+		}
+
+		s.dispatch(repo, models.WebhookEventPush, "", pusher, func(payload map[string]any, fullName string) {
+			commits := []webhookCommitPayload{}
+			if current != webhookZeroID {
+				listed, err := s.git.ListCommits(s.git.RepositoryPath(repo), current, known, webhookPushCommitsLimit)
+				if err != nil {
+					slog.Error("failed to list pushed commits", "error", err, "repository_id", repo.ID, "ref", ref)
+				}
+				// Payloads list commits oldest first
+				for i := len(listed) - 1; i >= 0; i-- {
+					commits = append(commits, s.commitPayload(fullName, listed[i]))
+				}
+			}
+
+			payload["ref"] = ref
+			payload["before"] = previous
+			payload["after"] = current
+			payload["created"] = previous == webhookZeroID
+			payload["deleted"] = current == webhookZeroID
+			payload["commits"] = commits
+			if len(commits) > 0 {
+				payload["head_commit"] = commits[len(commits)-1]
+			} else {
+				payload["head_commit"] = nil
+			}
+			payload["pusher"] = s.userPayload(pusher)
+		})
+	}
+}
+
+func (s *webhookService) Ticket(repo *models.Repository, ticket *models.Ticket, action string, actor *models.User) {
+	s.dispatch(repo, models.WebhookEventTicket, action, actor, func(payload map[string]any, fullName string) {
+		payload["ticket"] = s.ticketPayload(fullName, ticket)
+	})
+}
+
+func (s *webhookService) TicketComment(repo *models.Repository, ticket *models.Ticket, comment *models.TicketComment, action string, actor *models.User) {
+	s.dispatch(repo, models.WebhookEventTicketComment, action, actor, func(payload map[string]any, fullName string) {
+		payload["ticket"] = s.ticketPayload(fullName, ticket)
+		payload["comment"] = webhookCommentPayload{
+			ID:        comment.ID,
+			Body:      comment.Body,
+			Author:    s.username(comment.AuthorID),
+			CreatedAt: webhookTime(comment.CreatedAt),
+			UpdatedAt: webhookTime(comment.UpdatedAt),
+		}
+	})
+}
+
+func (s *webhookService) Star(repo *models.Repository, action string, actor *models.User) {
+	s.dispatch(repo, models.WebhookEventStar, action, actor, nil)
+}
+
+func (s *webhookService) Repository(repo *models.Repository, action string, actor *models.User) {
+	s.dispatch(repo, models.WebhookEventRepository, action, actor, nil)
+}
+
+func (s *webhookService) RepositoryUpdated(previous, repo *models.Repository, actor *models.User) {
+	if previous.Name != repo.Name {
+		s.dispatch(repo, models.WebhookEventRepository, "renamed", actor, func(payload map[string]any, _ string) {
+			payload["changes"] = map[string]any{"name": map[string]string{"from": previous.Name}}
+		})
+	}
+
+	if previous.Visibility != repo.Visibility {
+		action := "privatized"
+		if repo.Visibility == "public" {
+			action = "publicized"
+		}
+		s.dispatch(repo, models.WebhookEventRepository, action, actor, nil)
+	}
+
+	changes := map[string]any{}
+	if previous.DefaultBranch != repo.DefaultBranch {
+		changes["default_branch"] = map[string]string{"from": previous.DefaultBranch}
+	}
+	if !equalStringPtr(previous.Description, repo.Description) {
+		changes["description"] = map[string]*string{"from": previous.Description}
+	}
+	if len(changes) > 0 {
+		s.dispatch(repo, models.WebhookEventRepository, "edited", actor, func(payload map[string]any, _ string) {
+			payload["changes"] = changes
+		})
+	}
+}
+
+func (s *webhookService) Ping(webhook *models.Webhook, actor *models.User) {
+	payload := map[string]any{
+		"hook_id": webhook.ID,
+		"sender":  s.userPayload(actor),
+	}
+
+	hook := webhookHookPayload{
+		ID:          webhook.ID,
+		Events:      webhook.Events,
+		Active:      webhook.Active,
+		URL:         webhook.URL,
+		ContentType: webhook.ContentType,
+	}
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+
+	if webhook.OrganizationID != nil {
+		hook.Type = "Organization"
+		org, err := s.orgs.FindByID(*webhook.OrganizationID)
+		if err != nil || org == nil {
+			slog.Error("failed to find organization of webhook", "error", err, "webhook_id", webhook.ID)
+			return
+		}
+		payload["organization"] = s.organizationPayload(org)
+	} else {
+		hook.Type = "Repository"
+	}
+	payload["hook"] = hook
+
+	if webhook.RepositoryID != nil {
+		repo, err := s.repos.FindByID(*webhook.RepositoryID)
+		if err != nil || repo == nil {
+			slog.Error("failed to find repository of webhook", "error", err, "webhook_id", webhook.ID)
+			return
+		}
+		repoPayload, org := s.repositoryPayload(repo)
+		payload["repository"] = repoPayload
+		if org != nil {
+			payload["organization"] = s.organizationPayload(org)
+		}
+	}
+
+	s.enqueue(webhook, models.WebhookEventPing, "", payload)
+}
+
+func (s *webhookService) Redeliver(delivery *models.WebhookDelivery) (*models.WebhookDelivery, error) {
+	return s.deliveries.Enqueue(delivery.WebhookID, delivery.GUID, delivery.Event, delivery.Action, delivery.Payload, true)
+}
+
+func (s *webhookService) ValidateURL(ctx context.Context, rawURL string) error {
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		return ErrWebhookURLInvalid
+	}
+
+	settings, err := s.instanceSettings.Find()
+	if err != nil {
+		return err
+	}
+	if settings.WebhooksAllowPrivateNetworks {
+		return nil
+	}
+
+	lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupNetIP(lookupCtx, "ip", target.Hostname())
+	if err != nil {
+		return ErrWebhookURLUnresolvable
+	}
+	for _, addr := range addrs {
+		if !webhookAddressAllowed(addr) {
+			return ErrWebhookURLPrivate
+		}
+	}
+
+	return nil
+}
+
+// dispatch queues event for the active webhooks of the repository and of its organization that are
+// subscribed to it. extend adds the fields of the event to the common payload.
+func (s *webhookService) dispatch(repo *models.Repository, event, action string, actor *models.User, extend func(payload map[string]any, fullName string)) {
+	webhooks, err := s.webhooks.FindActiveForRepository(repo.ID, repo.OwnerOrgID)
+	if err != nil {
+		slog.Error("failed to find webhooks", "error", err, "repository_id", repo.ID)
+		return
+	}
+
+	var subscribed []*models.Webhook
+	for _, webhook := range webhooks {
+		// The webhooks of a deleted repository go with it
+		if event == models.WebhookEventRepository && action == "deleted" && webhook.RepositoryID != nil {
+			continue
+		}
+		if webhook.Subscribes(event) {
+			subscribed = append(subscribed, webhook)
+		}
+	}
+	if len(subscribed) == 0 {
+		return
+	}
+
+	repoPayload, org := s.repositoryPayload(repo)
+	payload := map[string]any{
+		"repository": repoPayload,
+		"sender":     s.userPayload(actor),
+	}
+	if action != "" {
+		payload["action"] = action
+	}
+	if org != nil {
+		payload["organization"] = s.organizationPayload(org)
+	}
+	if extend != nil {
+		extend(payload, repoPayload.FullName)
+	}
+
+	for _, webhook := range subscribed {
+		s.enqueue(webhook, event, action, payload)
+	}
+}
+
+func (s *webhookService) enqueue(webhook *models.Webhook, event, action string, payload map[string]any) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		slog.Error("failed to encode webhook payload", "error", err, "webhook_id", webhook.ID, "event", event)
+		return
+	}
+
+	var actionPtr *string
+	if action != "" {
+		actionPtr = &action
+	}
+
+	if _, err := s.deliveries.Enqueue(webhook.ID, uuid.NewString(), event, actionPtr, string(body), false); err != nil {
+		slog.Error("failed to queue webhook delivery", "error", err, "webhook_id", webhook.ID, "event", event)
+	}
+}
+
+// repositoryPayload describes repo, along with the organization owning it if any
+func (s *webhookService) repositoryPayload(repo *models.Repository) (webhookRepositoryPayload, *models.Organization) {
+	var owner string
+	var org *models.Organization
+	if repo.OwnerUserID != nil {
+		owner = s.username(*repo.OwnerUserID)
+	} else if repo.OwnerOrgID != nil {
+		found, err := s.orgs.FindByID(*repo.OwnerOrgID)
+		if err != nil {
+			slog.Error("failed to find organization", "error", err, "org_id", *repo.OwnerOrgID)
+		} else if found != nil {
+			owner = found.Username
+			org = found
+		}
+	}
+
+	fullName := owner + "/" + repo.Name
+	return webhookRepositoryPayload{
+		ID:            repo.ID,
+		Name:          repo.Name,
+		FullName:      fullName,
+		Owner:         owner,
+		Description:   repo.Description,
+		Visibility:    repo.Visibility,
+		DefaultBranch: repo.DefaultBranch,
+		HTMLURL:       s.publicURL + "/" + fullName,
+		CloneURL:      s.publicURL + "/" + fullName,
+		CreatedAt:     webhookTime(repo.CreatedAt),
+		UpdatedAt:     webhookTime(repo.UpdatedAt),
+	}, org
+}
+
+func (s *webhookService) organizationPayload(org *models.Organization) webhookUserPayload {
+	return webhookUserPayload{
+		ID:       org.ID,
+		Username: org.Username,
+		HTMLURL:  s.publicURL + "/" + org.Username,
+	}
+}
+
+func (s *webhookService) userPayload(user *models.User) webhookUserPayload {
+	return webhookUserPayload{
+		ID:       user.ID,
+		Username: user.Username,
+		HTMLURL:  s.publicURL + "/" + user.Username,
+	}
+}
+
+func (s *webhookService) ticketPayload(fullName string, ticket *models.Ticket) webhookTicketPayload {
+	payload := webhookTicketPayload{
+		Number:    ticket.Number,
+		Title:     ticket.Title,
+		Body:      ticket.Body,
+		State:     ticket.Status,
+		Author:    s.username(ticket.AuthorID),
+		HTMLURL:   fmt.Sprintf("%s/%s/tickets/%d", s.publicURL, fullName, ticket.Number),
+		CreatedAt: webhookTime(ticket.CreatedAt),
+		UpdatedAt: webhookTime(ticket.UpdatedAt),
+	}
+	if ticket.ClosedAt != nil {
+		closedAt := webhookTime(*ticket.ClosedAt)
+		payload.ClosedAt = &closedAt
+	}
+	return payload
+}
+
+func (s *webhookService) commitPayload(fullName string, commit Commit) webhookCommitPayload {
+	payload := webhookCommitPayload{
+		ID:        commit.ID,
+		Message:   commit.Message,
+		Timestamp: webhookTime(commit.Timestamp),
+		URL:       s.publicURL + "/" + fullName + "/tree/" + commit.ID,
+	}
+	payload.Author.Name = commit.AuthorName
+	payload.Author.Email = commit.AuthorEmail
+	return payload
+}
+
+// username returns the username of a user, or an empty string if it can't be found
+func (s *webhookService) username(id int64) string {
+	user, err := s.users.FindByID(id)
+	if err != nil || user == nil {
+		return ""
+	}
+	return user.Username
+}
+
+func equalStringPtr(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func webhookTime(unix int64) string {
+	return time.Unix(unix, 0).UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,302 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const (
+	// webhookMaxAttempts is how often a delivery is tried before giving up
+	webhookMaxAttempts = 8
+	// webhookBatchSize bounds how many deliveries a single pass sends
+	webhookBatchSize = 20
+	// webhookSendTimeout bounds a single delivery attempt, receivers are expected to answer quickly
+	webhookSendTimeout = 10 * time.Second
+	// webhookResponseBodyLimit bounds how much of a response body the delivery log keeps
+	webhookResponseBodyLimit = 64 << 10
+)
+
+// webhookBlockedPrefixes are the ranges net/netip doesn't classify that webhooks still mustn't reach
+var webhookBlockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+}
+
+var errWebhookAddressBlocked = errors.New("webhook target is a private network address")
+
+// WebhookWorker sends queued webhook deliveries, retrying failures with exponential backoff
+type WebhookWorker interface {
+	Run(ctx context.Context, interval time.Duration)
+	DeliverDue(ctx context.Context) error
+}
+
+type webhookWorker struct {
+	webhooks         repositories.WebhooksRepository
+	deliveries       repositories.WebhookDeliveriesRepository
+	instanceSettings repositories.InstanceSettingsRepository
+	// client refuses to connect to private network addresses, privateClient is used once an
+	// administrator allows them
+	client        *http.Client
+	privateClient *http.Client
+}
+
+func NewWebhookWorker(
+	webhooks repositories.WebhooksRepository,
+	deliveries repositories.WebhookDeliveriesRepository,
+	instanceSettings repositories.InstanceSettingsRepository,
+) WebhookWorker {
+	return &webhookWorker{
+		webhooks:         webhooks,
+		deliveries:       deliveries,
+		instanceSettings: instanceSettings,
+		client:           newWebhookClient(false),
+		privateClient:    newWebhookClient(true),
+	}
+}
+
+// newWebhookClient returns a client that neither uses a proxy nor follows redirects, so the address
+// it dials is the one that was checked
+func newWebhookClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		// Checking the address being dialed rather than the one resolved when the webhook was
+		// saved also covers names that resolve differently later
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !webhookAddressAllowed(addrPort.Addr()) {
+				return errWebhookAddressBlocked
+			}
+			return nil
+		}
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     30 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Run sends due deliveries every interval until ctx is cancelled
+func (w *webhookWorker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := w.DeliverDue(ctx); err != nil {
+			slog.Error("failed to send webhook deliveries", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverDue makes one pass over the queue, sending every delivery whose next attempt is due
+func (w *webhookWorker) DeliverDue(ctx context.Context) error {
+	for {
+		deliveries, err := w.deliveries.FindDue(time.Now().Unix(), webhookBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		settings, err := w.instanceSettings.Find()
+		if err != nil {
+			return err
+		}
+		client := w.client
+		if settings.WebhooksAllowPrivateNetworks {
+			client = w.privateClient
+		}
+
+		for _, delivery := range deliveries {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			w.deliver(ctx, client, delivery)
+		}
+
+		if len(deliveries) < webhookBatchSize {
+			return nil
+		}
+	}
+}
+
+func (w *webhookWorker) deliver(ctx context.Context, client *http.Client, delivery *models.WebhookDelivery) {
+	webhook, err := w.webhooks.FindByID(delivery.WebhookID)
+	if err != nil {
+		slog.Error("failed to find webhook", "error", err, "delivery_id", delivery.ID)
+		return
+	}
+
+	var attempt *models.WebhookAttempt
+	switch {
+	case webhook == nil:
+		attempt = &models.WebhookAttempt{Error: "webhook was deleted"}
+	case !webhook.Active:
+		attempt = &models.WebhookAttempt{Error: "webhook is inactive"}
+	default:
+		attempt = w.send(ctx, client, webhook, delivery)
+	}
+
+	if attempt.Error == "" {
+		if err := w.deliveries.RecordAttempt(delivery.ID, attempt, "delivered", delivery.NextAttemptAt); err != nil {
+			slog.Error("failed to mark webhook delivery as delivered", "error", err, "delivery_id", delivery.ID)
+		}
+		return
+	}
+
+	attempts := delivery.Attempts + 1
+	if webhook == nil || !webhook.Active || attempts >= webhookMaxAttempts {
+		slog.Warn("giving up on webhook delivery", "error", attempt.Error, "delivery_id", delivery.ID, "attempts", attempts)
+		if err := w.deliveries.RecordAttempt(delivery.ID, attempt, "failed", delivery.NextAttemptAt); err != nil {
+			slog.Error("failed to mark webhook delivery as failed", "error", err, "delivery_id", delivery.ID)
+		}
+		return
+	}
+
+	// Webhooks are retried on the same schedule as emails
+	nextAttemptAt := time.Now().Add(outboxBackoff(attempts)).Unix()
+	slog.Warn("webhook delivery failed, will retry", "error", attempt.Error, "delivery_id", delivery.ID, "attempts", attempts)
+	if err := w.deliveries.RecordAttempt(delivery.ID, attempt, "pending", nextAttemptAt); err != nil {
+		slog.Error("failed to reschedule webhook delivery", "error", err, "delivery_id", delivery.ID)
+	}
+}
+
+// send posts the payload of delivery to the webhook once and records what was exchanged
+func (w *webhookWorker) send(ctx context.Context, client *http.Client, webhook *models.Webhook, delivery *models.WebhookDelivery) *models.WebhookAttempt {
+	body := []byte(delivery.Payload)
+	contentType := "application/json"
+	if webhook.ContentType == models.WebhookContentTypeForm {
+		body = []byte("payload=" + url.QueryEscape(delivery.Payload))
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	sendCtx, cancel := context.WithTimeout(ctx, webhookSendTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(sendCtx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return &models.WebhookAttempt{Error: err.Error()}
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "Hypercommit-Webhooks")
+	req.Header.Set("X-Hypercommit-Event", delivery.Event)
+	req.Header.Set("X-Hypercommit-Delivery", delivery.GUID)
+	req.Header.Set("X-Hypercommit-Hook-ID", strconv.FormatInt(webhook.ID, 10))
+	if webhook.Secret != "" {
+		req.Header.Set("X-Hypercommit-Signature-256", WebhookSignature(webhook.Secret, body))
+	}
+
+	attempt := &models.WebhookAttempt{RequestHeaders: formatWebhookHeaders(req.Header)}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		attempt.DurationMS = time.Since(start).Milliseconds()
+		if errors.Is(err, errWebhookAddressBlocked) {
+			attempt.Error = errWebhookAddressBlocked.Error()
+		} else {
+			attempt.Error = err.Error()
+		}
+		return attempt
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(io.LimitReader(resp.Body, webhookResponseBodyLimit))
+	attempt.DurationMS = time.Since(start).Milliseconds()
+
+	status := int64(resp.StatusCode)
+	responseHeaders := formatWebhookHeaders(resp.Header)
+	attempt.ResponseStatus = &status
+	attempt.ResponseHeaders = &responseHeaders
+	if len(responseBody) > 0 {
+		text := strings.ToValidUTF8(string(responseBody), "�")
+		attempt.ResponseBody = &text
+	}
+
+	switch {
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		attempt.Error = fmt.Sprintf("receiver answered %s", resp.Status)
+	case err != nil:
+		attempt.Error = fmt.Sprintf("failed to read response: %v", err)
+	}
+	return attempt
+}
+
+// WebhookSignature is the X-Hypercommit-Signature-256 header of a payload, receivers recompute it with
+// the secret to check that the payload comes from us
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookAddressAllowed reports whether webhooks may connect to addr without an administrator
+// allowing private networks
+func webhookAddressAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+	for _, prefix := range webhookBlockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatWebhookHeaders renders headers one per line, sorted, for the delivery log
+func formatWebhookHeaders(header http.Header) string {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range header[key] {
+			b.WriteString(key + ": " + value + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,348 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// WorkflowsPath is the directory of a repository holding its workflows, one .yml file each
+const WorkflowsPath = ".hypercommit/workflows"
+
+const (
+	// defaultJobTimeoutMinutes applies to jobs and steps that don't set timeout_minutes
+	defaultJobTimeoutMinutes = 60
+	maxJobTimeoutMinutes     = 6 * 60
+	// maxMatrixJobs bounds how many jobs the matrix of a single job expands to
+	maxMatrixJobs = 64
+)
+
+// workflowTicketActions are the ticket actions workflows can run on
+var workflowTicketActions = []string{"opened", "closed", "reopened"}
+
+// workflowNameRegex matches job IDs and matrix keys, which also name environment variables
+var workflowNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// Workflow is a workflow file of WorkflowsPath:
+//
+//	name: CI
+//	on:
+//	  push:
+//	    branches: [main, "release/*"]
+//	  tag:
+//	    tags: ["v*"]
+//	  ticket:
+//	    types: [opened]
+//	env:
+//	  GOFLAGS: -mod=readonly
+//	jobs:
+//	  test:
+//	    timeout_minutes: 10
+//	    matrix:
+//	      go: ["1.24", "1.25"]
+//	    steps:
+//	      - name: Test
+//	        run: go test ./...
+//
+// "on" also takes a single event or a list of events, which then match every branch, tag or ticket
+// action. Matrix jobs see their combination as MATRIX_<KEY> environment variables.
+type Workflow struct {
+	Name string                        `yaml:"name"`
+	On   WorkflowTriggers              `yaml:"on"`
+	Env  map[string]string             `yaml:"env"`
+	Jobs map[string]*WorkflowJobConfig `yaml:"jobs"`
+}
+
+// WorkflowTriggers are the events a workflow runs on, nil for events it ignores
+type WorkflowTriggers struct {
+	Push   *WorkflowBranchFilter
+	Tag    *WorkflowTagFilter
+	Ticket *WorkflowTicketFilter
+}
+
+// WorkflowBranchFilter limits push events to branches matching one of the path.Match patterns,
+// every branch matches if there are none
+type WorkflowBranchFilter struct {
+	Branches []string `yaml:"branches"`
+}
+
+// WorkflowTagFilter limits tag events to tags matching one of the path.Match patterns, every tag
+// matches if there are none
+type WorkflowTagFilter struct {
+	Tags []string `yaml:"tags"`
+}
+
+// WorkflowTicketFilter limits ticket events to the actions in Types, all of them if it is empty
+type WorkflowTicketFilter struct {
+	Types []string `yaml:"types"`
+}
+
+type WorkflowJobConfig struct {
+	Name           string               `yaml:"name"`
+	TimeoutMinutes int                  `yaml:"timeout_minutes"`
+	Env            map[string]string    `yaml:"env"`
+	Matrix         map[string][]string  `yaml:"matrix"`
+	Steps          []WorkflowStepConfig `yaml:"steps"`
+}
+
+type WorkflowStepConfig struct {
+	Name           string            `yaml:"name"`
+	Run            string            `yaml:"run"`
+	Env            map[string]string `yaml:"env"`
+	TimeoutMinutes int               `yaml:"timeout_minutes"`
+}
+
+// WorkflowJobSpec is a job as runners execute it, with the matrix expanded and the environment of
+// the workflow merged in. It is stored as JSON with the job.
+type WorkflowJobSpec struct {
+	Name           string             `json:"name"`
+	TimeoutSeconds int64              `json:"timeout_seconds"`
+	Env            map[string]string  `json:"env"`
+	Steps          []WorkflowStepSpec `json:"steps"`
+}
+
+type WorkflowStepSpec struct {
+	Name           string            `json:"name"`
+	Run            string            `json:"run"`
+	Env            map[string]string `json:"env"`
+	TimeoutSeconds int64             `json:"timeout_seconds"`
+}
+
+func (t *WorkflowTriggers) UnmarshalYAML(value *yaml.Node) error {
+	var events []string
+	switch value.Kind {
+	case yaml.ScalarNode:
+		events = []string{value.Value}
+	case yaml.SequenceNode:
+		if err := value.Decode(&events); err != nil {
+			return err
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			event, filter := value.Content[i].Value, value.Content[i+1]
+			var err error
+			switch event {
+			case "push":
+				t.Push = &WorkflowBranchFilter{}
+				err = filter.Decode(t.Push)
+			case "tag":
+				t.Tag = &WorkflowTagFilter{}
+				err = filter.Decode(t.Tag)
+			case "ticket":
+				t.Ticket = &WorkflowTicketFilter{}
+				err = filter.Decode(t.Ticket)
+			default:
+				return fmt.Errorf("line %d: unknown event %q, expected push, tag or ticket", value.Content[i].Line, event)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("line %d: on must list events", value.Line)
+	}
+
+	for _, event := range events {
+		switch event {
+		case "push":
+			t.Push = &WorkflowBranchFilter{}
+		case "tag":
+			t.Tag = &WorkflowTagFilter{}
+		case "ticket":
+			t.Ticket = &WorkflowTicketFilter{}
+		default:
+			return fmt.Errorf("line %d: unknown event %q, expected push, tag or ticket", value.Line, event)
+		}
+	}
+	return nil
+}
+
+// ParseWorkflow reads a workflow file, file is its path and names the workflow if it has no name
+func ParseWorkflow(file string, data []byte) (*Workflow, error) {
+	workflow := &Workflow{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(workflow); err != nil {
+		return nil, err
+	}
+
+	if workflow.Name == "" {
+		workflow.Name = strings.TrimSuffix(path.Base(file), path.Ext(file))
+	}
+	if workflow.On.Push == nil && workflow.On.Tag == nil && workflow.On.Ticket == nil {
+		return nil, errors.New("the workflow has no events in on")
+	}
+	if workflow.On.Ticket != nil {
+		for _, action := range workflow.On.Ticket.Types {
+			if !slices.Contains(workflowTicketActions, action) {
+				return nil, fmt.Errorf("unknown ticket type %q, expected opened, closed or reopened", action)
+			}
+		}
+	}
+	if len(workflow.Jobs) == 0 {
+		return nil, errors.New("the workflow has no jobs")
+	}
+
+	for _, id := range workflow.JobIDs() {
+		job := workflow.Jobs[id]
+		if !workflowNameRegex.MatchString(id) {
+			return nil, fmt.Errorf("job %q: IDs consist of letters, digits, _ and -", id)
+		}
+		if job == nil || len(job.Steps) == 0 {
+			return nil, fmt.Errorf("job %q has no steps", id)
+		}
+		if job.TimeoutMinutes < 0 || job.TimeoutMinutes > maxJobTimeoutMinutes {
+			return nil, fmt.Errorf("job %q: timeout_minutes must be at most %d", id, maxJobTimeoutMinutes)
+		}
+
+		combinations := 1
+		for key, values := range job.Matrix {
+			if !workflowNameRegex.MatchString(key) {
+				return nil, fmt.Errorf("job %q: matrix key %q consists of letters, digits, _ and -", id, key)
+			}
+			if len(values) == 0 {
+				return nil, fmt.Errorf("job %q: matrix key %q has no values", id, key)
+			}
+			combinations *= len(values)
+			if combinations > maxMatrixJobs {
+				return nil, fmt.Errorf("job %q: the matrix expands to more than %d jobs", id, maxMatrixJobs)
+			}
+		}
+
+		for i, step := range job.Steps {
+			if strings.TrimSpace(step.Run) == "" {
+				return nil, fmt.Errorf("job %q: step %d has nothing to run", id, i+1)
+			}
+			if step.TimeoutMinutes < 0 || step.TimeoutMinutes > maxJobTimeoutMinutes {
+				return nil, fmt.Errorf("job %q: step %d: timeout_minutes must be at most %d", id, i+1, maxJobTimeoutMinutes)
+			}
+		}
+	}
+
+	return workflow, nil
+}
+
+// JobIDs returns the IDs of the jobs in a stable order
+func (w *Workflow) JobIDs() []string {
+	ids := make([]string, 0, len(w.Jobs))
+	for id := range w.Jobs {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// MatchesPush reports whether the workflow runs when the branch is pushed to
+func (w *Workflow) MatchesPush(branch string) bool {
+	return w.On.Push != nil && matchesAny(w.On.Push.Branches, branch)
+}
+
+// MatchesTag reports whether the workflow runs when the tag is pushed
+func (w *Workflow) MatchesTag(tag string) bool {
+	return w.On.Tag != nil && matchesAny(w.On.Tag.Tags, tag)
+}
+
+// MatchesTicket reports whether the workflow runs for the action on a ticket, such as opened
+func (w *Workflow) MatchesTicket(action string) bool {
+	return w.On.Ticket != nil && (len(w.On.Ticket.Types) == 0 || slices.Contains(w.On.Ticket.Types, action))
+}
+
+// matchesAny reports whether name matches one of the patterns, or whether there are no patterns
+func matchesAny(patterns []string, name string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ExpandJob returns the jobs runners execute for the job, one per combination of its matrix
+func (w *Workflow) ExpandJob(id string) []WorkflowJobSpec {
+	job := w.Jobs[id]
+	name := job.Name
+	if name == "" {
+		name = id
+	}
+	timeout := job.TimeoutMinutes
+	if timeout == 0 {
+		timeout = defaultJobTimeoutMinutes
+	}
+
+	keys := make([]string, 0, len(job.Matrix))
+	for key := range job.Matrix {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	// Start with the empty combination and extend it with the values of every key
+	combinations := []map[string]string{{}}
+	for _, key := range keys {
+		var extended []map[string]string
+		for _, combination := range combinations {
+			for _, value := range job.Matrix[key] {
+				next := map[string]string{key: value}
+				for k, v := range combination {
+					next[k] = v
+				}
+				extended = append(extended, next)
+			}
+		}
+		combinations = extended
+	}
+
+	specs := make([]WorkflowJobSpec, 0, len(combinations))
+	for _, combination := range combinations {
+		spec := WorkflowJobSpec{
+			Name:           name,
+			TimeoutSeconds: int64(timeout) * 60,
+			Env:            map[string]string{},
+		}
+		for k, v := range w.Env {
+			spec.Env[k] = v
+		}
+		for k, v := range job.Env {
+			spec.Env[k] = v
+		}
+
+		if len(keys) > 0 {
+			values := make([]string, 0, len(keys))
+			for _, key := range keys {
+				values = append(values, combination[key])
+				spec.Env["MATRIX_"+strings.ToUpper(strings.ReplaceAll(key, "-", "_"))] = combination[key]
+			}
+			spec.Name += " (" + strings.Join(values, ", ") + ")"
+		}
+
+		for i, step := range job.Steps {
+			stepName := step.Name
+			if stepName == "" {
+				stepName = fmt.Sprintf("Step %d", i+1)
+			}
+			stepTimeout := int64(step.TimeoutMinutes) * 60
+			if stepTimeout == 0 || stepTimeout > spec.TimeoutSeconds {
+				stepTimeout = spec.TimeoutSeconds
+			}
+			spec.Steps = append(spec.Steps, WorkflowStepSpec{
+				Name:           stepName,
+				Run:            step.Run,
+				Env:            step.Env,
+				TimeoutSeconds: stepTimeout,
+			})
+		}
+
+		specs = append(specs, spec)
+	}
+
+	return specs
+}
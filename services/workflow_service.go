@@ -0,0 +1,439 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const (
+	// workflowStaleAfter is how long a runner may stay silent before its job is given up on. Runners
+	// send logs or heartbeats far more often than that.
+	workflowStaleAfter = 5 * time.Minute
+	// maxWorkflowLogSize bounds the log of a single job, output past it is dropped
+	maxWorkflowLogSize = 4 << 20
+)
+
+// WorkflowService runs the workflows of repositories: it queues runs for the events workflows
+// subscribe to, hands their jobs to runners and keeps runs and commit statuses up to date as jobs
+// progress. Queueing failures are logged, they never fail the request that caused the event.
+type WorkflowService interface {
+	// Push queues the push and tag workflows matching the refs that a push created or updated, with
+	// the snapshots of ListRefs taken around the push
+	Push(repo *models.Repository, pusher *models.User, before, after map[string]string)
+	// Ticket queues the ticket workflows of the default branch for the action, opened, closed or
+	// reopened
+	Ticket(repo *models.Repository, ticket *models.Ticket, action string, actor *models.User)
+	// Claim hands the oldest queued job to a runner, along with its run. It returns nil if no job is
+	// queued. Jobs whose runner stopped responding fail first.
+	Claim(runnerName string) (*models.WorkflowJob, *models.WorkflowRun, error)
+	// AppendLog adds output to the log of a running job. It reports false once the job stopped
+	// running, which tells the runner to stop.
+	AppendLog(job *models.WorkflowJob, chunk []byte) (bool, error)
+	// Finish completes a running job with the conclusion the runner came to
+	Finish(job *models.WorkflowJob, conclusion string) error
+	// Cancel completes the jobs of the run that haven't completed yet as cancelled
+	Cancel(run *models.WorkflowRun) error
+}
+
+type workflowService struct {
+	runs      repositories.WorkflowRunsRepository
+	statuses  repositories.CommitStatusesRepository
+	repos     repositories.RepositoriesRepository
+	users     repositories.UsersRepository
+	orgs      repositories.OrganizationsRepository
+	git       GitService
+	publicURL string
+}
+
+func NewWorkflowService(
+	runs repositories.WorkflowRunsRepository,
+	statuses repositories.CommitStatusesRepository,
+	repos repositories.RepositoriesRepository,
+	users repositories.UsersRepository,
+	orgs repositories.OrganizationsRepository,
+	git GitService,
+	publicURL string,
+) WorkflowService {
+	return &workflowService{
+		runs:      runs,
+		statuses:  statuses,
+		repos:     repos,
+		users:     users,
+		orgs:      orgs,
+		git:       git,
+		publicURL: publicURL,
+	}
+}
+
+// workflowFile is a parsed workflow along with the path it was read from
+type workflowFile struct {
+	path     string
+	workflow *Workflow
+}
+
+func (s *workflowService) Push(repo *models.Repository, pusher *models.User, before, after map[string]string) {
+	for ref, sha := range after {
+		if before[ref] == sha {
+			continue
+		}
+
+		var event, name string
+		switch {
+		case strings.HasPrefix(ref, "refs/heads/"):
+			event, name = "push", strings.TrimPrefix(ref, "refs/heads/")
+		case strings.HasPrefix(ref, "refs/tags/"):
+			event, name = "tag", strings.TrimPrefix(ref, "refs/tags/")
+		default:
+			continue
+		}
+
+		// Tags may point at annotated tag objects, workflows run on the commit
+		commit, err := s.git.ResolveCommit(s.git.RepositoryPath(repo), sha)
+		if err != nil || commit == "" {
+			continue
+		}
+
+		for _, file := range s.workflows(repo, commit) {
+			if (event == "push" && file.workflow.MatchesPush(name)) || (event == "tag" && file.workflow.MatchesTag(name)) {
+				s.enqueue(repo, file, event, ref, commit, pusher)
+			}
+		}
+	}
+}
+
+func (s *workflowService) Ticket(repo *models.Repository, ticket *models.Ticket, action string, actor *models.User) {
+	if !slices.Contains(workflowTicketActions, action) {
+		return
+	}
+
+	ref := "refs/heads/" + repo.DefaultBranch
+	commit, err := s.git.ResolveCommit(s.git.RepositoryPath(repo), ref)
+	if err != nil || commit == "" {
+		return
+	}
+
+	for _, file := range s.workflows(repo, commit) {
+		if file.workflow.MatchesTicket(action) {
+			s.enqueue(repo, file, "ticket", ref, commit, actor, "HYPERCOMMIT_TICKET="+strconv.FormatInt(ticket.Number, 10), "HYPERCOMMIT_TICKET_ACTION="+action)
+		}
+	}
+}
+
+// workflows returns the workflows of the repository at the commit. Invalid workflows are logged and
+// left out.
+func (s *workflowService) workflows(repo *models.Repository, commit string) []workflowFile {
+	repoPath := s.git.RepositoryPath(repo)
+	entries, err := s.git.ListTree(repoPath, commit, WorkflowsPath)
+	if err != nil {
+		return nil
+	}
+
+	var files []workflowFile
+	for _, entry := range entries {
+		lower := strings.ToLower(entry.Name)
+		if entry.Type != "blob" || (!strings.HasSuffix(lower, ".yml") && !strings.HasSuffix(lower, ".yaml")) {
+			continue
+		}
+
+		content, err := s.git.GetFileContent(repoPath, commit, entry.Path)
+		if err != nil {
+			slog.Error("failed to read workflow", "error", err, "repository_id", repo.ID, "path", entry.Path)
+			continue
+		}
+
+		workflow, err := ParseWorkflow(entry.Path, content)
+		if err != nil {
+			slog.Warn("skipping invalid workflow", "error", err, "repository_id", repo.ID, "path", entry.Path, "commit", commit)
+			continue
+		}
+
+		files = append(files, workflowFile{path: entry.Path, workflow: workflow})
+	}
+
+	return files
+}
+
+// enqueue queues a run of the workflow with its jobs, env holds extra KEY=value pairs for the
+// environment of the jobs
+func (s *workflowService) enqueue(repo *models.Repository, file workflowFile, event, ref, commit string, actor *models.User, env ...string) {
+	run := &models.WorkflowRun{
+		RepositoryID: repo.ID,
+		Workflow:     file.path,
+		Name:         file.workflow.Name,
+		Event:        event,
+		Ref:          ref,
+		SHA:          commit,
+	}
+	if actor != nil {
+		run.TriggeredByID = &actor.ID
+	}
+
+	run, err := s.runs.CreateRun(run)
+	if err != nil {
+		slog.Error("failed to queue workflow run", "error", err, "repository_id", repo.ID, "path", file.path)
+		return
+	}
+
+	fullName := s.fullName(repo)
+	for _, id := range file.workflow.JobIDs() {
+		for _, spec := range file.workflow.ExpandJob(id) {
+			spec.Env["CI"] = "true"
+			spec.Env["HYPERCOMMIT_REPOSITORY"] = fullName
+			spec.Env["HYPERCOMMIT_EVENT"] = event
+			spec.Env["HYPERCOMMIT_REF"] = ref
+			spec.Env["HYPERCOMMIT_SHA"] = commit
+			spec.Env["HYPERCOMMIT_RUN_NUMBER"] = strconv.FormatInt(run.Number, 10)
+			spec.Env["HYPERCOMMIT_JOB"] = spec.Name
+			for _, pair := range env {
+				key, value, _ := strings.Cut(pair, "=")
+				spec.Env[key] = value
+			}
+
+			definition, err := json.Marshal(spec)
+			if err != nil {
+				slog.Error("failed to encode workflow job", "error", err, "run_id", run.ID)
+				continue
+			}
+
+			job, err := s.runs.CreateJob(&models.WorkflowJob{
+				RunID:        run.ID,
+				RepositoryID: repo.ID,
+				Name:         spec.Name,
+				Definition:   string(definition),
+			})
+			if err != nil {
+				slog.Error("failed to queue workflow job", "error", err, "run_id", run.ID)
+				continue
+			}
+			s.reportStatus(run, job, fullName)
+		}
+	}
+}
+
+func (s *workflowService) Claim(runnerName string) (*models.WorkflowJob, *models.WorkflowRun, error) {
+	if err := s.reap(); err != nil {
+		return nil, nil, err
+	}
+
+	job, err := s.runs.ClaimJob(runnerName)
+	if err != nil || job == nil {
+		return nil, nil, err
+	}
+
+	run, err := s.runs.FindRunByID(job.RunID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if run == nil {
+		return nil, nil, fmt.Errorf("workflow run %d of job %d not found", job.RunID, job.ID)
+	}
+	if err := s.updateRun(run); err != nil {
+		return nil, nil, err
+	}
+
+	s.reportJobStatus(run, job)
+	return job, run, nil
+}
+
+// reap fails the running jobs whose runner hasn't checked in for workflowStaleAfter
+func (s *workflowService) reap() error {
+	jobs, err := s.runs.FindStaleJobs(time.Now().Add(-workflowStaleAfter).Unix())
+	if err != nil {
+		return err
+	}
+
+	for _, job := range jobs {
+		if _, err := s.runs.AppendLog(job.ID, []byte("\nThe runner stopped responding, giving up on the job.\n")); err != nil {
+			return err
+		}
+		if err := s.Finish(job, models.WorkflowConclusionFailure); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *workflowService) AppendLog(job *models.WorkflowJob, chunk []byte) (bool, error) {
+	// The size in job may be stale, the log is only ever appended to so it can only be larger
+	current, err := s.runs.FindJobByID(job.ID)
+	if err != nil || current == nil {
+		return false, err
+	}
+
+	remaining := maxWorkflowLogSize - current.LogSize
+	if remaining <= 0 {
+		chunk = []byte{}
+	} else if int64(len(chunk)) > remaining {
+		chunk = append(chunk[:remaining:remaining], "\nThe log is too large, further output is dropped.\n"...)
+	}
+
+	return s.runs.AppendLog(job.ID, chunk)
+}
+
+func (s *workflowService) Finish(job *models.WorkflowJob, conclusion string) error {
+	finished, err := s.runs.FinishJob(job.ID, conclusion)
+	if err != nil || !finished {
+		return err
+	}
+	job.Status = models.WorkflowStatusCompleted
+	job.Conclusion = &conclusion
+
+	run, err := s.runs.FindRunByID(job.RunID)
+	if err != nil || run == nil {
+		return err
+	}
+	if err := s.updateRun(run); err != nil {
+		return err
+	}
+
+	s.reportJobStatus(run, job)
+	return nil
+}
+
+func (s *workflowService) Cancel(run *models.WorkflowRun) error {
+	jobs, err := s.runs.FindJobsByRun(run.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.runs.CancelJobs(run.ID); err != nil {
+		return err
+	}
+	if err := s.updateRun(run); err != nil {
+		return err
+	}
+
+	fullName := s.fullName(s.repository(run.RepositoryID))
+	cancelled := models.WorkflowConclusionCancelled
+	for _, job := range jobs {
+		if job.Status == models.WorkflowStatusCompleted {
+			continue
+		}
+		job.Status = models.WorkflowStatusCompleted
+		job.Conclusion = &cancelled
+		s.reportStatus(run, job, fullName)
+	}
+	return nil
+}
+
+// updateRun derives the status of the run from its jobs: it completes with the jobs, failing if one
+// failed and being cancelled if one was, and runs as soon as one of them started
+func (s *workflowService) updateRun(run *models.WorkflowRun) error {
+	jobs, err := s.runs.FindJobsByRun(run.ID)
+	if err != nil {
+		return err
+	}
+
+	status := models.WorkflowStatusCompleted
+	started, failed, cancelled := false, false, false
+	for _, job := range jobs {
+		if job.Status != models.WorkflowStatusCompleted {
+			status = models.WorkflowStatusRunning
+		}
+		if job.Status != models.WorkflowStatusQueued {
+			started = true
+		}
+		if job.Conclusion != nil {
+			failed = failed || *job.Conclusion == models.WorkflowConclusionFailure
+			cancelled = cancelled || *job.Conclusion == models.WorkflowConclusionCancelled
+		}
+	}
+	if !started {
+		status = models.WorkflowStatusQueued
+	}
+
+	var conclusion *string
+	if status == models.WorkflowStatusCompleted {
+		result := models.WorkflowConclusionSuccess
+		if failed {
+			result = models.WorkflowConclusionFailure
+		} else if cancelled {
+			result = models.WorkflowConclusionCancelled
+		}
+		conclusion = &result
+	}
+
+	if err := s.runs.UpdateRunStatus(run.ID, status, conclusion); err != nil {
+		return err
+	}
+	run.Status = status
+	run.Conclusion = conclusion
+	return nil
+}
+
+func (s *workflowService) reportJobStatus(run *models.WorkflowRun, job *models.WorkflowJob) {
+	s.reportStatus(run, job, s.fullName(s.repository(run.RepositoryID)))
+}
+
+// reportStatus records the state of the job as a commit status of the commit it runs on. Ticket runs
+// don't test a commit of their own and report nothing.
+func (s *workflowService) reportStatus(run *models.WorkflowRun, job *models.WorkflowJob, fullName string) {
+	if run.Event == "ticket" || fullName == "" {
+		return
+	}
+
+	state, description := models.CommitStatePending, "Queued"
+	switch {
+	case job.Status == models.WorkflowStatusRunning:
+		description = "Running"
+	case job.Conclusion != nil && *job.Conclusion == models.WorkflowConclusionSuccess:
+		state, description = models.CommitStateSuccess, "Succeeded"
+	case job.Conclusion != nil && *job.Conclusion == models.WorkflowConclusionFailure:
+		state, description = models.CommitStateFailure, "Failed"
+	case job.Conclusion != nil:
+		state, description = models.CommitStateError, "Cancelled"
+	}
+
+	targetURL := fmt.Sprintf("%s/%s/runs/%d", s.publicURL, fullName, run.Number)
+	_, err := s.statuses.Upsert(&models.CommitStatus{
+		RepositoryID: run.RepositoryID,
+		SHA:          run.SHA,
+		Context:      run.Name + " / " + job.Name,
+		State:        state,
+		TargetURL:    &targetURL,
+		Description:  &description,
+	})
+	if err != nil {
+		slog.Error("failed to report workflow job status", "error", err, "run_id", run.ID, "job_id", job.ID)
+	}
+}
+
+// repository returns the repository with the ID, nil if it can't be found
+func (s *workflowService) repository(id int64) *models.Repository {
+	repo, err := s.repos.FindByID(id)
+	if err != nil {
+		slog.Error("failed to find repository", "error", err, "repository_id", id)
+		return nil
+	}
+	return repo
+}
+
+// fullName returns owner/name of the repository, or an empty string if its owner can't be found
+func (s *workflowService) fullName(repo *models.Repository) string {
+	if repo == nil {
+		return ""
+	}
+
+	var owner string
+	if repo.OwnerUserID != nil {
+		if user, err := s.users.FindByID(*repo.OwnerUserID); err == nil && user != nil {
+			owner = user.Username
+		}
+	} else if repo.OwnerOrgID != nil {
+		if org, err := s.orgs.FindByID(*repo.OwnerOrgID); err == nil && org != nil {
+			owner = org.Username
+		}
+	}
+	if owner == "" {
+		return ""
+	}
+	return owner + "/" + repo.Name
+}
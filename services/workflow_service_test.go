@@ -0,0 +1,382 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hypercommithq/hypercommit/database"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/database/repositories"
+)
+
+const testWorkflows = WorkflowsPath + "/"
+
+// workflowTest is a workflow service with a repository of Alice's whose main branch holds workflows
+type workflowTest struct {
+	db        *database.DB
+	workflows WorkflowService
+	runs      repositories.WorkflowRunsRepository
+	statuses  repositories.CommitStatusesRepository
+	alice     *models.User
+	repo      *models.Repository
+	commit    string
+}
+
+func newWorkflowTest(t *testing.T, files map[string]string) *workflowTest {
+	t.Helper()
+
+	db := newTestDB(t)
+	runs := repositories.NewWorkflowRunsRepository(db.DB)
+	statuses := repositories.NewCommitStatusesRepository(db.DB)
+	repos := repositories.NewRepositoriesRepository(db.DB)
+	users := repositories.NewUsersRepository(db.DB)
+	git := NewGitService(filepath.Join(t.TempDir(), "repos"))
+
+	alice, err := users.Create("alice", "alice@example.com", "Alice", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	repo, err := repos.CreateForUser(alice.ID, "app", "public", "main", nil)
+	if err != nil {
+		t.Fatalf("create repository: %v", err)
+	}
+	if err := git.InitRepository(repo); err != nil {
+		t.Fatalf("init repository: %v", err)
+	}
+
+	return &workflowTest{
+		db:        db,
+		workflows: NewWorkflowService(runs, statuses, repos, users, repositories.NewOrganizationsRepository(db.DB), git, "http://localhost"),
+		runs:      runs,
+		statuses:  statuses,
+		alice:     alice,
+		repo:      repo,
+		commit:    commitFiles(t, git.RepositoryPath(repo), files),
+	}
+}
+
+// push tells the service that the refs were pushed to the commit
+func (w *workflowTest) push(refs ...string) {
+	after := map[string]string{}
+	for _, ref := range refs {
+		after[ref] = w.commit
+	}
+	w.workflows.Push(w.repo, w.alice, map[string]string{}, after)
+}
+
+func (w *workflowTest) latestRuns(t *testing.T) []*models.WorkflowRun {
+	t.Helper()
+
+	runs, err := w.runs.FindRunsByRepository(w.repo.ID, 100)
+	if err != nil {
+		t.Fatalf("find runs: %v", err)
+	}
+	return runs
+}
+
+func (w *workflowTest) jobs(t *testing.T, run *models.WorkflowRun) []*models.WorkflowJob {
+	t.Helper()
+
+	jobs, err := w.runs.FindJobsByRun(run.ID)
+	if err != nil {
+		t.Fatalf("find jobs: %v", err)
+	}
+	return jobs
+}
+
+func (w *workflowTest) run(t *testing.T, id int64) *models.WorkflowRun {
+	t.Helper()
+
+	run, err := w.runs.FindRunByID(id)
+	if err != nil || run == nil {
+		t.Fatalf("find run %d: %v", id, err)
+	}
+	return run
+}
+
+func (w *workflowTest) job(t *testing.T, id int64) *models.WorkflowJob {
+	t.Helper()
+
+	job, err := w.runs.FindJobByID(id)
+	if err != nil || job == nil {
+		t.Fatalf("find job %d: %v", id, err)
+	}
+	return job
+}
+
+// commitStates returns the state of each commit status of the commit by context
+func (w *workflowTest) commitStates(t *testing.T) map[string]string {
+	t.Helper()
+
+	statuses, err := w.statuses.FindByRepositoryAndSHA(w.repo.ID, w.commit)
+	if err != nil {
+		t.Fatalf("find commit statuses: %v", err)
+	}
+	states := map[string]string{}
+	for _, status := range statuses {
+		states[status.Context] = status.State
+	}
+	return states
+}
+
+func conclusion(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
+
+const singleJobWorkflow = `
+name: CI
+on: push
+jobs:
+  test:
+    steps:
+      - run: make test
+`
+
+func TestWorkflowPushQueuesMatchingWorkflows(t *testing.T) {
+	w := newWorkflowTest(t, map[string]string{
+		testWorkflows + "ci.yml": `
+name: CI
+on:
+  push:
+    branches: [main]
+jobs:
+  test:
+    matrix:
+      go: ["1.24", "1.25"]
+    steps:
+      - run: go test ./...
+`,
+		testWorkflows + "release.yaml": "name: Release\non:\n  tag:\n    tags: [\"v*\"]\njobs:\n  publish:\n    steps:\n      - run: make publish\n",
+		testWorkflows + "triage.yml":   "name: Triage\non:\n  ticket:\n    types: [opened]\njobs:\n  label:\n    steps:\n      - run: make label\n",
+		testWorkflows + "broken.yml":   "name: Broken\non: push\n",
+		testWorkflows + "README.md":    "Workflows of the app\n",
+	})
+
+	w.push("refs/heads/main")
+
+	runs := w.latestRuns(t)
+	if len(runs) != 1 {
+		t.Fatalf("queued %d runs, want the CI run only", len(runs))
+	}
+	run := runs[0]
+	if run.Name != "CI" || run.Workflow != testWorkflows+"ci.yml" || run.Event != "push" || run.Ref != "refs/heads/main" || run.SHA != w.commit || run.Status != models.WorkflowStatusQueued {
+		t.Errorf("run = %+v", run)
+	}
+	if run.TriggeredByID == nil || *run.TriggeredByID != w.alice.ID {
+		t.Errorf("run was triggered by %v, want Alice", run.TriggeredByID)
+	}
+
+	jobs := w.jobs(t, run)
+	if len(jobs) != 2 || jobs[0].Name != "test (1.24)" || jobs[1].Name != "test (1.25)" {
+		t.Fatalf("queued jobs %+v, want one per Go version", jobs)
+	}
+	var spec WorkflowJobSpec
+	if err := json.Unmarshal([]byte(jobs[1].Definition), &spec); err != nil {
+		t.Fatalf("decode job definition: %v", err)
+	}
+	for key, want := range map[string]string{
+		"CI":                     "true",
+		"MATRIX_GO":              "1.25",
+		"HYPERCOMMIT_REPOSITORY": "alice/app",
+		"HYPERCOMMIT_EVENT":      "push",
+		"HYPERCOMMIT_REF":        "refs/heads/main",
+		"HYPERCOMMIT_SHA":        w.commit,
+		"HYPERCOMMIT_RUN_NUMBER": "1",
+		"HYPERCOMMIT_JOB":        "test (1.25)",
+	} {
+		if spec.Env[key] != want {
+			t.Errorf("%s = %q, want %q", key, spec.Env[key], want)
+		}
+	}
+
+	states := w.commitStates(t)
+	if states["CI / test (1.24)"] != models.CommitStatePending || states["CI / test (1.25)"] != models.CommitStatePending {
+		t.Errorf("commit statuses = %v, want both jobs pending", states)
+	}
+
+	// Refs that didn't change and branches the workflow leaves out queue nothing
+	w.workflows.Push(w.repo, w.alice, map[string]string{"refs/heads/main": w.commit}, map[string]string{"refs/heads/main": w.commit})
+	w.push("refs/heads/feature")
+	if n := len(w.latestRuns(t)); n != 1 {
+		t.Errorf("%d runs after pushes no workflow runs on, want 1", n)
+	}
+
+	w.push("refs/tags/v1.0.0")
+	runs = w.latestRuns(t)
+	if len(runs) != 2 || runs[0].Name != "Release" || runs[0].Event != "tag" || runs[0].Number != 2 {
+		t.Errorf("tag queued %+v, want the Release run", runs[0])
+	}
+
+	w.workflows.Ticket(w.repo, &models.Ticket{Number: 7}, "closed", w.alice)
+	w.workflows.Ticket(w.repo, &models.Ticket{Number: 7}, "opened", w.alice)
+	runs = w.latestRuns(t)
+	if len(runs) != 3 || runs[0].Name != "Triage" || runs[0].Event != "ticket" {
+		t.Fatalf("opening a ticket queued %+v, want the Triage run", runs[0])
+	}
+	if err := json.Unmarshal([]byte(w.jobs(t, runs[0])[0].Definition), &spec); err != nil {
+		t.Fatalf("decode job definition: %v", err)
+	}
+	if spec.Env["HYPERCOMMIT_TICKET"] != "7" || spec.Env["HYPERCOMMIT_TICKET_ACTION"] != "opened" {
+		t.Errorf("ticket job env = %v", spec.Env)
+	}
+	if _, ok := w.commitStates(t)["Triage / label"]; ok {
+		t.Error("a ticket run reported a commit status")
+	}
+}
+
+func TestWorkflowRunnerProtocol(t *testing.T) {
+	w := newWorkflowTest(t, map[string]string{
+		testWorkflows + "ci.yml": "name: CI\non: push\njobs:\n  test:\n    steps:\n      - run: make test\n  lint:\n    steps:\n      - run: make lint\n",
+	})
+	w.push("refs/heads/main")
+
+	lint, run, err := w.workflows.Claim("runner-1")
+	if err != nil || lint == nil {
+		t.Fatalf("claim: %v, %v", lint, err)
+	}
+	if lint.Name != "lint" || lint.Status != models.WorkflowStatusRunning || lint.RunnerName == nil || *lint.RunnerName != "runner-1" {
+		t.Errorf("claimed %+v, want lint running on runner-1", lint)
+	}
+	if run.Status != models.WorkflowStatusRunning || w.run(t, run.ID).Status != models.WorkflowStatusRunning {
+		t.Errorf("run is %s once a job runs", run.Status)
+	}
+
+	test, _, err := w.workflows.Claim("runner-2")
+	if err != nil || test == nil || test.Name != "test" {
+		t.Fatalf("second claim: %v, %v", test, err)
+	}
+	if job, _, err := w.workflows.Claim("runner-3"); err != nil || job != nil {
+		t.Fatalf("claimed %v, %v from an empty queue", job, err)
+	}
+
+	running, err := w.workflows.AppendLog(lint, []byte("vet ok\n"))
+	if err != nil || !running {
+		t.Fatalf("append log: %v, %v", running, err)
+	}
+	if log, err := w.runs.ReadLog(lint.ID, 0); err != nil || string(log) != "vet ok\n" {
+		t.Errorf("log = %q, %v", log, err)
+	}
+
+	if err := w.workflows.Finish(lint, models.WorkflowConclusionSuccess); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if status := w.run(t, run.ID).Status; status != models.WorkflowStatusRunning {
+		t.Errorf("run is %s while a job still runs", status)
+	}
+	if err := w.workflows.Finish(test, models.WorkflowConclusionFailure); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	run = w.run(t, run.ID)
+	if run.Status != models.WorkflowStatusCompleted || conclusion(run.Conclusion) != models.WorkflowConclusionFailure || run.FinishedAt == nil {
+		t.Errorf("run = %s/%s, want completed with failure", run.Status, conclusion(run.Conclusion))
+	}
+	states := w.commitStates(t)
+	if states["CI / lint"] != models.CommitStateSuccess || states["CI / test"] != models.CommitStateFailure {
+		t.Errorf("commit statuses = %v", states)
+	}
+
+	// Runners of completed jobs are told to stop, and can't change how the job ended
+	if running, err := w.workflows.AppendLog(lint, []byte("late\n")); err != nil || running {
+		t.Errorf("appending to a completed job = %v, %v", running, err)
+	}
+	if err := w.workflows.Finish(w.job(t, lint.ID), models.WorkflowConclusionFailure); err != nil {
+		t.Fatalf("finish again: %v", err)
+	}
+	if got := conclusion(w.job(t, lint.ID).Conclusion); got != models.WorkflowConclusionSuccess {
+		t.Errorf("finishing twice changed the conclusion to %s", got)
+	}
+}
+
+func TestWorkflowCancel(t *testing.T) {
+	w := newWorkflowTest(t, map[string]string{testWorkflows + "ci.yml": singleJobWorkflow + "  lint:\n    steps:\n      - run: make lint\n"})
+	w.push("refs/heads/main")
+
+	job, run, err := w.workflows.Claim("runner-1")
+	if err != nil || job == nil {
+		t.Fatalf("claim: %v, %v", job, err)
+	}
+	if err := w.workflows.Cancel(run); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	for _, job := range w.jobs(t, run) {
+		if job.Status != models.WorkflowStatusCompleted || conclusion(job.Conclusion) != models.WorkflowConclusionCancelled {
+			t.Errorf("job %s is %s/%s, want cancelled", job.Name, job.Status, conclusion(job.Conclusion))
+		}
+	}
+	if run := w.run(t, run.ID); conclusion(run.Conclusion) != models.WorkflowConclusionCancelled {
+		t.Errorf("run concluded %s, want cancelled", conclusion(run.Conclusion))
+	}
+	if running, err := w.workflows.AppendLog(job, []byte("still going\n")); err != nil || running {
+		t.Errorf("the runner of a cancelled job was not told to stop: %v, %v", running, err)
+	}
+	if next, _, err := w.workflows.Claim("runner-2"); err != nil || next != nil {
+		t.Errorf("claimed %v, %v after the run was cancelled", next, err)
+	}
+	for context, state := range w.commitStates(t) {
+		if state != models.CommitStateError {
+			t.Errorf("%s is %s after cancelling, want error", context, state)
+		}
+	}
+}
+
+func TestWorkflowClaimGivesUpOnSilentRunners(t *testing.T) {
+	w := newWorkflowTest(t, map[string]string{testWorkflows + "ci.yml": singleJobWorkflow})
+	w.push("refs/heads/main")
+
+	job, run, err := w.workflows.Claim("runner-1")
+	if err != nil || job == nil {
+		t.Fatalf("claim: %v, %v", job, err)
+	}
+	mustExec(t, w.db, `UPDATE workflow_jobs SET heartbeat_at = unixepoch() - 600 WHERE id = ?`, job.ID)
+
+	if next, _, err := w.workflows.Claim("runner-2"); err != nil || next != nil {
+		t.Fatalf("claim: %v, %v", next, err)
+	}
+
+	job = w.job(t, job.ID)
+	if conclusion(job.Conclusion) != models.WorkflowConclusionFailure {
+		t.Errorf("the silent runner's job concluded %q, want failure", conclusion(job.Conclusion))
+	}
+	if log, err := w.runs.ReadLog(job.ID, 0); err != nil || !strings.Contains(string(log), "stopped responding") {
+		t.Errorf("log = %q, %v", log, err)
+	}
+	if run := w.run(t, run.ID); run.Status != models.WorkflowStatusCompleted {
+		t.Errorf("run is %s, want completed", run.Status)
+	}
+}
+
+func TestWorkflowLogIsBounded(t *testing.T) {
+	w := newWorkflowTest(t, map[string]string{testWorkflows + "ci.yml": singleJobWorkflow})
+	w.push("refs/heads/main")
+
+	job, _, err := w.workflows.Claim("runner-1")
+	if err != nil || job == nil {
+		t.Fatalf("claim: %v, %v", job, err)
+	}
+
+	chunk := bytes.Repeat([]byte("x"), maxWorkflowLogSize/2+1)
+	for range 3 {
+		if running, err := w.workflows.AppendLog(job, chunk); err != nil || !running {
+			t.Fatalf("append log: %v, %v", running, err)
+		}
+	}
+
+	log, err := w.runs.ReadLog(job.ID, 0)
+	if err != nil {
+		t.Fatalf("read log: %v", err)
+	}
+	if !bytes.HasPrefix(log, bytes.Repeat([]byte("x"), maxWorkflowLogSize)) || !strings.HasSuffix(string(log), "further output is dropped.\n") {
+		t.Errorf("log of %d bytes doesn't end at the limit with a note", len(log))
+	}
+	if len(log) > maxWorkflowLogSize+100 {
+		t.Errorf("log grew to %d bytes", len(log))
+	}
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"maps"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseWorkflow(t *testing.T) {
+	workflow, err := ParseWorkflow(".hypercommit/workflows/ci.yml", []byte(`
+on:
+  push:
+    branches: [main, "release/*"]
+  tag:
+    tags: ["v*"]
+  ticket:
+    types: [opened]
+env:
+  GOFLAGS: -mod=readonly
+jobs:
+  test:
+    steps:
+      - run: go test ./...
+  lint:
+    steps:
+      - run: go vet ./...
+`))
+	if err != nil {
+		t.Fatalf("parse workflow: %v", err)
+	}
+
+	if workflow.Name != "ci" {
+		t.Errorf("name = %q, want the file name ci", workflow.Name)
+	}
+	if got := workflow.JobIDs(); !reflect.DeepEqual(got, []string{"lint", "test"}) {
+		t.Errorf("job IDs = %v, want them sorted", got)
+	}
+
+	for _, tt := range []struct {
+		match func(string) bool
+		name  string
+		want  bool
+	}{
+		{workflow.MatchesPush, "main", true},
+		{workflow.MatchesPush, "release/1.0", true},
+		{workflow.MatchesPush, "release/1.0/fix", false},
+		{workflow.MatchesPush, "feature", false},
+		{workflow.MatchesTag, "v1.0.0", true},
+		{workflow.MatchesTag, "nightly", false},
+		{workflow.MatchesTicket, "opened", true},
+		{workflow.MatchesTicket, "closed", false},
+	} {
+		if got := tt.match(tt.name); got != tt.want {
+			t.Errorf("matches %q = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseWorkflowEventLists(t *testing.T) {
+	for _, on := range []string{"push", "[push, ticket]"} {
+		workflow, err := ParseWorkflow("ci.yml", []byte("name: CI\non: "+on+"\njobs:\n  test:\n    steps:\n      - run: make\n"))
+		if err != nil {
+			t.Fatalf("on: %s: %v", on, err)
+		}
+		if workflow.Name != "CI" {
+			t.Errorf("on: %s: name = %q, want CI", on, workflow.Name)
+		}
+		if !workflow.MatchesPush("any/branch") {
+			t.Errorf("on: %s doesn't match every branch", on)
+		}
+		if workflow.MatchesTag("v1") {
+			t.Errorf("on: %s matches tags", on)
+		}
+	}
+
+	workflow, err := ParseWorkflow("ci.yml", []byte("on: [ticket]\njobs:\n  test:\n    steps:\n      - run: make\n"))
+	if err != nil {
+		t.Fatalf("parse workflow: %v", err)
+	}
+	for _, action := range workflowTicketActions {
+		if !workflow.MatchesTicket(action) {
+			t.Errorf("on: [ticket] doesn't match %s", action)
+		}
+	}
+}
+
+func TestParseWorkflowRejectsInvalidWorkflows(t *testing.T) {
+	steps := "    steps:\n      - run: make\n"
+	for _, tt := range []struct {
+		name     string
+		workflow string
+		err      string
+	}{
+		{"no events", "jobs:\n  test:\n" + steps, "no events"},
+		{"unknown event", "on: deploy\njobs:\n  test:\n" + steps, `unknown event "deploy"`},
+		{"unknown event in a mapping", "on:\n  deploy: {}\njobs:\n  test:\n" + steps, `unknown event "deploy"`},
+		{"unknown ticket type", "on:\n  ticket:\n    types: [edited]\njobs:\n  test:\n" + steps, `unknown ticket type "edited"`},
+		{"unknown field", "on: push\nservices: {}\njobs:\n  test:\n" + steps, "field services not found"},
+		{"no jobs", "on: push\n", "no jobs"},
+		{"invalid job ID", "on: push\njobs:\n  \"a b\":\n" + steps, `job "a b"`},
+		{"no steps", "on: push\njobs:\n  test:\n    name: Test\n", `job "test" has no steps`},
+		{"empty step", "on: push\njobs:\n  test:\n    steps:\n      - name: Nothing\n", "step 1 has nothing to run"},
+		{"job timeout", "on: push\njobs:\n  test:\n    timeout_minutes: 361\n" + steps, "timeout_minutes must be at most 360"},
+		{"step timeout", "on: push\njobs:\n  test:\n    steps:\n      - run: make\n        timeout_minutes: -1\n", "step 1: timeout_minutes"},
+		{"invalid matrix key", "on: push\njobs:\n  test:\n    matrix:\n      \"go version\": [\"1.25\"]\n" + steps, `matrix key "go version"`},
+		{"empty matrix key", "on: push\njobs:\n  test:\n    matrix:\n      go: []\n" + steps, `matrix key "go" has no values`},
+		{"large matrix", "on: push\njobs:\n  test:\n    matrix:\n      a: [1, 2, 3, 4, 5, 6, 7, 8, 9]\n      b: [1, 2, 3, 4, 5, 6, 7, 8]\n" + steps, "more than 64 jobs"},
+	} {
+		_, err := ParseWorkflow("ci.yml", []byte(tt.workflow))
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error = %v, want one containing %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestExpandJob(t *testing.T) {
+	workflow, err := ParseWorkflow("ci.yml", []byte(`
+on: push
+env:
+  SHARED: workflow
+  LEVEL: workflow
+jobs:
+  test:
+    name: Test
+    timeout_minutes: 10
+    env:
+      LEVEL: job
+    matrix:
+      go: ["1.24", "1.25"]
+      os-name: [linux, darwin]
+    steps:
+      - run: go test ./...
+      - name: Race
+        run: go test -race ./...
+        timeout_minutes: 20
+        env:
+          CGO_ENABLED: "1"
+  lint:
+    steps:
+      - run: go vet ./...
+        timeout_minutes: 5
+`))
+	if err != nil {
+		t.Fatalf("parse workflow: %v", err)
+	}
+
+	specs := workflow.ExpandJob("test")
+	var names []string
+	for _, spec := range specs {
+		names = append(names, spec.Name)
+	}
+	want := []string{"Test (1.24, linux)", "Test (1.24, darwin)", "Test (1.25, linux)", "Test (1.25, darwin)"}
+	if !reflect.DeepEqual(names, want) {
+		t.Fatalf("expanded to %v, want %v", names, want)
+	}
+
+	spec := specs[1]
+	wantEnv := map[string]string{"SHARED": "workflow", "LEVEL": "job", "MATRIX_GO": "1.24", "MATRIX_OS_NAME": "darwin"}
+	if !maps.Equal(spec.Env, wantEnv) {
+		t.Errorf("env = %v, want %v", spec.Env, wantEnv)
+	}
+	if spec.TimeoutSeconds != 600 {
+		t.Errorf("job timeout = %ds, want 600s", spec.TimeoutSeconds)
+	}
+	if spec.Steps[0].Name != "Step 1" || spec.Steps[0].TimeoutSeconds != 600 {
+		t.Errorf("first step = %q with %ds, want Step 1 with the job's 600s", spec.Steps[0].Name, spec.Steps[0].TimeoutSeconds)
+	}
+	if spec.Steps[1].Name != "Race" || spec.Steps[1].TimeoutSeconds != 600 || spec.Steps[1].Env["CGO_ENABLED"] != "1" {
+		t.Errorf("second step = %+v, want Race bounded by the job's 600s", spec.Steps[1])
+	}
+
+	// Expanding again must not share environments between the jobs
+	specs[0].Env["SHARED"] = "changed"
+	if workflow.ExpandJob("test")[0].Env["SHARED"] != "workflow" || workflow.Env["SHARED"] != "workflow" {
+		t.Error("jobs share their environment with the workflow")
+	}
+
+	lint := workflow.ExpandJob("lint")
+	if len(lint) != 1 || lint[0].Name != "lint" || lint[0].TimeoutSeconds != defaultJobTimeoutMinutes*60 || lint[0].Steps[0].TimeoutSeconds != 300 {
+		t.Errorf("lint = %+v, want a single job named after its ID with the default timeout", lint)
+	}
+}
@@ -1,6 +1,7 @@
 package components
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/hypercommithq/hypercommit/database/models"
@@ -10,7 +11,8 @@ import (
 )
 
 type HeaderData struct {
-	User *models.User
+	User                *models.User
+	UnreadNotifications int64
 }
 
 func Header(data *HeaderData, children ...html.Node) html.Node {
@@ -38,16 +40,17 @@ func Header(data *HeaderData, children ...html.Node) html.Node {
 			attr.Class("flex flex-wrap items-center gap-4"),
 			html.IfElsef(
 				data.User != nil,
-				func() html.Node { return loggedInActions(data.User) },
+				func() html.Node { return loggedInActions(data.User, data.UnreadNotifications) },
 				func() html.Node { return loggedOutActions() },
 			),
 		),
 	)
 }
 
-func loggedInActions(user *models.User) html.Node {
+func loggedInActions(user *models.User, unreadNotifications int64) html.Node {
 	return html.Div(
 		attr.Class("flex flex-wrap items-center gap-4"),
+		notificationsLink(unreadNotifications),
 		createNewDropdown(),
 		userAccountDropdown(user),
 	)
@@ -116,6 +119,37 @@ func loggedOutActions() html.Node {
 	)
 }
 
+// notificationsLink links to the inbox, with a badge when there are unread notifications
+func notificationsLink(unread int64) html.Node {
+	label := "Notifications"
+	if unread > 0 {
+		label = fmt.Sprintf("Notifications (%d unread)", unread)
+	}
+
+	return html.A(
+		attr.Href("/notifications"),
+		attr.AriaLabel(label),
+		attr.DataTooltip(label),
+		attr.DataSide("bottom"),
+		attr.Class("btn-icon-ghost relative"),
+		ui.SVGIcon(ui.IconBell, ""),
+		html.If(
+			unread > 0,
+			html.Span(
+				attr.Class("absolute -top-1 -right-1 min-w-4 h-4 px-1 rounded-full bg-blue-600 text-white text-[10px] leading-4 text-center font-medium"),
+				html.Text(unreadBadgeText(unread)),
+			),
+		),
+	)
+}
+
+func unreadBadgeText(unread int64) string {
+	if unread > 99 {
+		return "99+"
+	}
+	return fmt.Sprintf("%d", unread)
+}
+
 func createNewDropdown() html.Node {
 	return html.Div(
 		attr.Class("dropdown-menu"),
@@ -214,6 +248,13 @@ func userAccountDropdown(user *models.User) html.Node {
 						ui.SVGIcon(ui.IconSettings, ""),
 						html.Text("Settings"),
 					),
+					html.If(user.IsAdmin, html.A(
+						attr.Href("/admin"),
+						attr.Role("menuitem"),
+						attr.Class("cursor-pointer"),
+						ui.SVGIcon(ui.IconShield, ""),
+						html.Text("Site administration"),
+					)),
 					html.Hr(attr.Role("separator")),
 					html.A(
 						attr.Href("https://x.com/hypercommit2099"),
@@ -276,12 +317,13 @@ func getUserInitials(user *models.User) string {
 }
 
 type MainHeaderData struct {
-	User  *models.User
-	Class string
+	User                *models.User
+	UnreadNotifications int64
+	Class               string
 }
 
 func MainHeader(data *MainHeaderData) html.Node {
-	return Header(&HeaderData{User: data.User}, html.A(
+	return Header(&HeaderData{User: data.User, UnreadNotifications: data.UnreadNotifications}, html.A(
 		attr.Class("btn-ghost "+data.Class),
 		attr.Href("/explore/repositories"),
 		html.Text("Explore"),
@@ -0,0 +1,61 @@
+package layouts
+
+import (
+	"net/http"
+
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/libhtml/attr"
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/views/components"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+)
+
+type adminLayout struct {
+	title      string
+	children   []html.Node
+	user       *models.User
+	currentTab string
+}
+
+// AdminLayoutOptions picks the tab of the site administration area that is shown
+type AdminLayoutOptions struct {
+	CurrentTab string
+}
+
+func Admin(r *http.Request, title string, opts AdminLayoutOptions, children ...html.Node) adminLayout {
+	return adminLayout{
+		title:      title,
+		children:   children,
+		user:       middleware.GetUserFromContext(r),
+		currentTab: opts.CurrentTab,
+	}
+}
+
+func (b adminLayout) Render(w http.ResponseWriter, r *http.Request) error {
+	bodyChildren := []html.Node{
+		attr.Class("bg-neutral-50 text-neutral-900"),
+		// Add toaster container for toast notifications early in DOM
+		html.Div(
+			attr.Id("toaster"),
+			attr.Class("toaster"),
+		),
+		components.MainHeader(&components.MainHeaderData{User: b.user, UnreadNotifications: middleware.GetUnreadNotificationsFromContext(r), Class: "!bg-accent"}),
+		html.Div(
+			attr.Class("bg-background border-b px-4 pt-2 flex flex-wrap items-center gap-4"),
+			ui.AdminTabs(ui.AdminTabsProps{
+				CurrentTab: b.currentTab,
+			}),
+		),
+	}
+	bodyChildren = append(bodyChildren, b.children...)
+
+	doc := html.Document(
+		html.HTML(
+			attr.Lang("en"),
+			components.Head(b.title),
+			html.Body(bodyChildren...),
+		),
+	)
+	return doc.Render(w, r)
+}
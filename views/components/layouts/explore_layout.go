@@ -40,7 +40,7 @@ func (b exploreLayout) Render(w http.ResponseWriter, r *http.Request) error {
 			attr.Id("toaster"),
 			attr.Class("toaster"),
 		),
-		components.MainHeader(&components.MainHeaderData{User: b.user, Class: "!bg-accent"}),
+		components.MainHeader(&components.MainHeaderData{User: b.user, UnreadNotifications: middleware.GetUnreadNotificationsFromContext(r), Class: "!bg-accent"}),
 		html.Div(
 			attr.Class("bg-background border-b px-4 pt-2 flex flex-wrap items-center gap-4"),
 			ui.ExploreTabs(ui.ExploreTabsProps{
@@ -33,7 +33,7 @@ func (b mainLayout) Render(w http.ResponseWriter, r *http.Request) error {
 			attr.Id("toaster"),
 			attr.Class("toaster"),
 		),
-		components.MainHeader(&components.MainHeaderData{User: b.user}),
+		components.MainHeader(&components.MainHeaderData{User: b.user, UnreadNotifications: middleware.GetUnreadNotificationsFromContext(r)}),
 	}
 	bodyChildren = append(bodyChildren, b.children...)
 
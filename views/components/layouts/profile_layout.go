@@ -52,12 +52,13 @@ func (b profileLayout) Render(w http.ResponseWriter, r *http.Request) error {
 			attr.Class("toaster"),
 		),
 		components.ProfileHeader(&components.ProfileHeaderData{
-			User:         b.user,
-			Username:     b.username,
-			DisplayName:  b.displayName,
-			IsOrg:        b.isOrg,
-			CurrentTab:   b.currentTab,
-			ShowSettings: b.showSettings,
+			User:                b.user,
+			UnreadNotifications: middleware.GetUnreadNotificationsFromContext(r),
+			Username:            b.username,
+			DisplayName:         b.displayName,
+			IsOrg:               b.isOrg,
+			CurrentTab:          b.currentTab,
+			ShowSettings:        b.showSettings,
 		}),
 	}
 	bodyChildren = append(bodyChildren, b.children...)
@@ -66,18 +66,19 @@ func (b repositoryLayout) Render(w http.ResponseWriter, r *http.Request) error {
 			attr.Id("toaster"),
 			attr.Class("toaster"),
 		),
-		components.RepositoryHeader(&components.RepositoryHeaderData{
-			User:          b.user,
-			OwnerUsername: b.ownerUsername,
-			RepoName:      b.repoName,
-			IsPublic:      b.isPublic,
-			CurrentTab:    b.currentTab,
-			ShowSettings:  b.showSettings,
-			StarCount:     b.starCount,
-			HasStarred:    b.hasStarred,
-			DefaultBranch: b.defaultBranch,
-			CloneURL:      b.cloneURL,
-			RepositoryURL: b.repositoryURL,
+		components.RepositoryHeader(r, &components.RepositoryHeaderData{
+			User:                b.user,
+			UnreadNotifications: middleware.GetUnreadNotificationsFromContext(r),
+			OwnerUsername:       b.ownerUsername,
+			RepoName:            b.repoName,
+			IsPublic:            b.isPublic,
+			CurrentTab:          b.currentTab,
+			ShowSettings:        b.showSettings,
+			StarCount:           b.starCount,
+			HasStarred:          b.hasStarred,
+			DefaultBranch:       b.defaultBranch,
+			CloneURL:            b.cloneURL,
+			RepositoryURL:       b.repositoryURL,
 		}),
 	}
 	bodyChildren = append(bodyChildren, b.children...)
@@ -8,12 +8,13 @@ import (
 )
 
 type ProfileHeaderData struct {
-	User         *models.User
-	Username     string
-	DisplayName  string
-	IsOrg        bool
-	CurrentTab   string
-	ShowSettings bool
+	User                *models.User
+	UnreadNotifications int64
+	Username            string
+	DisplayName         string
+	IsOrg               bool
+	CurrentTab          string
+	ShowSettings        bool
 }
 
 func ProfileHeader(data *ProfileHeaderData) html.Node {
@@ -50,7 +51,7 @@ func ProfileHeader(data *ProfileHeaderData) html.Node {
 				attr.Class("flex flex-wrap items-center gap-4"),
 				html.IfElsef(
 					data.User != nil,
-					func() html.Node { return profileLoggedInActions(data.User) },
+					func() html.Node { return profileLoggedInActions(data.User, data.UnreadNotifications) },
 					func() html.Node { return loggedOutActions() },
 				),
 			),
@@ -70,9 +71,10 @@ func ProfileHeader(data *ProfileHeaderData) html.Node {
 	)
 }
 
-func profileLoggedInActions(user *models.User) html.Node {
+func profileLoggedInActions(user *models.User, unreadNotifications int64) html.Node {
 	return html.Div(
 		attr.Class("flex flex-wrap items-center gap-4"),
+		notificationsLink(unreadNotifications),
 		createNewDropdown(),
 		userAccountDropdown(user),
 	)
@@ -2,6 +2,7 @@ package components
 
 import (
 	"fmt"
+	"net/http"
 
 	"github.com/hypercommithq/hypercommit/database/models"
 	"github.com/hypercommithq/libhtml"
@@ -10,20 +11,21 @@ import (
 )
 
 type RepositoryHeaderData struct {
-	User          *models.User
-	OwnerUsername string
-	RepoName      string
-	IsPublic      bool
-	CurrentTab    string
-	ShowSettings  bool
-	StarCount     int64
-	HasStarred    bool
-	DefaultBranch string
-	CloneURL      string
-	RepositoryURL string
+	User                *models.User
+	UnreadNotifications int64
+	OwnerUsername       string
+	RepoName            string
+	IsPublic            bool
+	CurrentTab          string
+	ShowSettings        bool
+	StarCount           int64
+	HasStarred          bool
+	DefaultBranch       string
+	CloneURL            string
+	RepositoryURL       string
 }
 
-func RepositoryHeader(data *RepositoryHeaderData) html.Node {
+func RepositoryHeader(r *http.Request, data *RepositoryHeaderData) html.Node {
 	if data == nil {
 		data = &RepositoryHeaderData{}
 	}
@@ -73,7 +75,7 @@ func RepositoryHeader(data *RepositoryHeaderData) html.Node {
 				attr.Class("flex flex-wrap items-center gap-4"),
 				html.IfElsef(
 					data.User != nil,
-					func() html.Node { return repositoryLoggedInActions(data.User) },
+					func() html.Node { return repositoryLoggedInActions(data.User, data.UnreadNotifications) },
 					func() html.Node { return loggedOutActions() },
 				),
 			),
@@ -103,16 +105,17 @@ func RepositoryHeader(data *RepositoryHeaderData) html.Node {
 						CloneURL:      data.CloneURL,
 						RepositoryURL: data.RepositoryURL,
 					}),
-					starButton(data),
+					starButton(r, data),
 				),
 			),
 		),
 	)
 }
 
-func repositoryLoggedInActions(user *models.User) html.Node {
+func repositoryLoggedInActions(user *models.User, unreadNotifications int64) html.Node {
 	return html.Div(
 		attr.Class("flex flex-wrap items-center gap-4"),
+		notificationsLink(unreadNotifications),
 		createNewDropdown(),
 		userAccountDropdown(user),
 	)
@@ -125,11 +128,12 @@ func visibilityText(isPublic bool) string {
 	return "Private"
 }
 
-func starButton(data *RepositoryHeaderData) html.Node {
+func starButton(r *http.Request, data *RepositoryHeaderData) html.Node {
 	if data.HasStarred {
 		return html.Element("form",
 			attr.Method("post"),
 			attr.Action("/"+data.OwnerUsername+"/"+data.RepoName+"/unstar"),
+			ui.CSRFField(r),
 			html.Element("button",
 				attr.Type("submit"),
 				attr.Class("btn-outline inline-flex items-center gap-2"),
@@ -145,6 +149,7 @@ func starButton(data *RepositoryHeaderData) html.Node {
 	return html.Element("form",
 		attr.Method("post"),
 		attr.Action("/"+data.OwnerUsername+"/"+data.RepoName+"/star"),
+		ui.CSRFField(r),
 		html.Element("button",
 			attr.Type("submit"),
 			attr.Class("btn-outline inline-flex items-center gap-2"),
@@ -0,0 +1,67 @@
+package ui
+
+import (
+	"github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type AdminTabsProps struct {
+	CurrentTab string
+}
+
+func AdminTabs(props AdminTabsProps) html.Node {
+	tabs := []html.Node{
+		adminTab(
+			"",
+			"overview",
+			props.CurrentTab,
+			IconLayoutGrid,
+			"Overview",
+		),
+		adminTab(
+			"/users",
+			"users",
+			props.CurrentTab,
+			IconUser,
+			"Users",
+		),
+		adminTab(
+			"/repositories",
+			"repositories",
+			props.CurrentTab,
+			IconRepository,
+			"Repositories",
+		),
+	}
+
+	return html.Nav(
+		attr.Class("flex flex-wrap items-center gap-4"),
+		html.Group(tabs...),
+	)
+}
+
+func adminTab(path, tab, currentTab string, icon Icon, label string) html.Node {
+	isActive := tab == currentTab
+
+	spanClasses := "btn-ghost inline-flex items-center gap-2"
+	if isActive {
+		spanClasses += " font-medium"
+	} else {
+		spanClasses += " text-muted-foreground"
+	}
+
+	borderClass := "border-transparent"
+	if isActive {
+		borderClass = "border-zinc-900"
+	}
+
+	return html.A(
+		attr.Href("/admin"+path),
+		attr.Class("inline-flex pb-2 border-b-2 transition-colors "+borderClass),
+		html.Element("span",
+			attr.Class(spanClasses),
+			smallSVGIcon(icon, ""),
+			html.Text(label),
+		),
+	)
+}
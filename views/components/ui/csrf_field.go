@@ -0,0 +1,18 @@
+package ui
+
+import (
+	"net/http"
+
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// CSRFField is the hidden input with the CSRF token that every POST form has to include
+func CSRFField(r *http.Request) html.Node {
+	return html.Input(
+		attr.Type("hidden"),
+		attr.Name(middleware.CSRFFieldName),
+		attr.Value(middleware.GetCSRFToken(r)),
+	)
+}
@@ -0,0 +1,32 @@
+package ui
+
+import (
+	"net/http"
+
+	"github.com/hypercommithq/hypercommit/middleware"
+	"github.com/hypercommithq/hypercommit/services"
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// ExternalAuthButtons renders a "Continue with ..." button for every configured identity provider
+func ExternalAuthButtons(r *http.Request) html.Node {
+	return html.For(middleware.GetIdentityProvidersFromContext(r), func(provider services.IdentityProvider) html.Node {
+		icon := IconBuilding
+		if provider.ID() == "github" {
+			icon = IconGitHub
+		}
+
+		return html.A(
+			attr.Href("/auth/"+provider.ID()),
+			attr.Class("btn-outline w-full flex items-center justify-center gap-2"),
+			SVGIcon(icon, "h-5 w-5"),
+			html.Text("Continue with "+provider.Name()),
+		)
+	})
+}
+
+// HasExternalAuth reports whether any identity provider is configured
+func HasExternalAuth(r *http.Request) bool {
+	return len(middleware.GetIdentityProvidersFromContext(r)) > 0
+}
@@ -49,6 +49,11 @@ const (
 	IconEye          Icon = "eye"
 	IconEdit         Icon = "edit"
 	IconShield       Icon = "shield"
+	IconFlag         Icon = "flag"
+	IconBell         Icon = "bell"
+	IconBellOff      Icon = "bell-off"
+	IconPlay         Icon = "play"
+	IconPackage      Icon = "package"
 )
 
 func SVGIcon(icon Icon, class string) html.Node {
@@ -309,6 +314,10 @@ func SVGIcon(icon Icon, class string) html.Node {
 			html.Element("circle", attr.Cx("12"), attr.Cy("12"), attr.R("10")),
 			html.Element("circle", attr.Cx("12"), attr.Cy("12"), attr.R("1"), attr.Fill("currentColor")),
 		}
+	case IconPlay:
+		paths = []html.Node{
+			html.Element("polygon", attr.Points("6 3 20 12 6 21 6 3")),
+		}
 	case IconEye:
 		paths = []html.Node{
 			html.Element("path", attr.D("M2 12s3-7 10-7 10 7 10 7-3 7-10 7-10-7-10-7Z")),
@@ -323,6 +332,30 @@ func SVGIcon(icon Icon, class string) html.Node {
 		paths = []html.Node{
 			html.Element("path", attr.D("M20 13c0 5-3.5 7.5-7.66 8.95a1 1 0 0 1-.67-.01C7.5 20.5 4 18 4 13V6a1 1 0 0 1 1-1c2 0 4.5-1.2 6.24-2.72a1.17 1.17 0 0 1 1.52 0C14.51 3.81 17 5 19 5a1 1 0 0 1 1 1z")),
 		}
+	case IconFlag:
+		paths = []html.Node{
+			html.Element("path", attr.D("M4 15s1-1 4-1 5 2 8 2 4-1 4-1V3s-1 1-4 1-5-2-8-2-4 1-4 1z")),
+			html.Element("line", attr.X1("4"), attr.X2("4"), attr.Y1("22"), attr.Y2("15")),
+		}
+	case IconBell:
+		paths = []html.Node{
+			html.Element("path", attr.D("M6 8a6 6 0 0 1 12 0c0 7 3 9 3 9H3s3-2 3-9")),
+			html.Element("path", attr.D("M10.3 21a1.94 1.94 0 0 0 3.4 0")),
+		}
+	case IconBellOff:
+		paths = []html.Node{
+			html.Element("path", attr.D("M8.7 3A6 6 0 0 1 18 8a21.3 21.3 0 0 0 .6 5")),
+			html.Element("path", attr.D("M17 17H3s3-2 3-9a4.67 4.67 0 0 1 .3-1.7")),
+			html.Element("path", attr.D("M10.3 21a1.94 1.94 0 0 0 3.4 0")),
+			html.Element("path", attr.D("m2 2 20 20")),
+		}
+	case IconPackage:
+		paths = []html.Node{
+			html.Element("path", attr.D("M11 21.73a2 2 0 0 0 2 0l7-4A2 2 0 0 0 21 16V8a2 2 0 0 0-1-1.73l-7-4a2 2 0 0 0-2 0l-7 4A2 2 0 0 0 3 8v8a2 2 0 0 0 1 1.73z")),
+			html.Element("path", attr.D("M12 22V12")),
+			html.Element("path", attr.D("m3.3 7 7.703 4.734a2 2 0 0 0 1.994 0L20.7 7")),
+			html.Element("path", attr.D("m7.5 4.27 9 5.15")),
+		}
 	}
 
 	return html.Element("svg", append(svgAttrs, paths...)...)
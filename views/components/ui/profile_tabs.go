@@ -30,6 +30,14 @@ func ProfileTabs(props ProfileTabsProps) html.Node {
 		),
 	}
 
+	tabs = append(tabs, profileTab(
+		props.Username,
+		"packages",
+		props.CurrentTab,
+		IconPackage,
+		"Packages",
+	))
+
 	// Only show stars tab for users, not organizations
 	if !props.IsOrg {
 		tabs = append(tabs, profileTab(
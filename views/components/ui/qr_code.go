@@ -0,0 +1,49 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/libhtml/attr"
+	"github.com/skip2/go-qrcode"
+)
+
+// QRCode renders content as an inline SVG QR code. The code is always drawn dark on light,
+// some scanners can't read inverted codes.
+func QRCode(content string, class string) html.Node {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return html.Text("")
+	}
+
+	bitmap := code.Bitmap()
+	size := len(bitmap)
+
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	return html.Element("svg",
+		attr.Xmlns("http://www.w3.org/2000/svg"),
+		attr.ViewBox(fmt.Sprintf("0 0 %d %d", size, size)),
+		attr.Attribute{Key: "shape-rendering", Value: "crispEdges"},
+		attr.Attribute{Key: "role", Value: "img"},
+		attr.Attribute{Key: "aria-label", Value: "QR code"},
+		attr.Class(class),
+		html.Element("rect",
+			attr.Width("100%"),
+			attr.Height("100%"),
+			attr.Fill("#ffffff"),
+		),
+		html.Element("path",
+			attr.Attribute{Key: "d", Value: path.String()},
+			attr.Fill("#000000"),
+		),
+	)
+}
@@ -42,6 +42,15 @@ func RepositoryTabs(props RepositoryTabsProps) html.Node {
 			IconCircle,
 			"Tickets",
 		),
+		repositoryTab(
+			props.OwnerUsername,
+			props.RepoName,
+			props.DefaultBranch,
+			"runs",
+			props.CurrentTab,
+			IconPlay,
+			"Runs",
+		),
 	}
 
 	if props.ShowSettings {
@@ -161,6 +170,10 @@ func smallSVGIcon(icon Icon, class string) html.Node {
 			html.Element("circle", attr.Cx("12"), attr.Cy("12"), attr.R("10")),
 			html.Element("circle", attr.Cx("12"), attr.Cy("12"), attr.R("1"), attr.Fill("currentColor")),
 		}
+	case IconPlay:
+		paths = []html.Node{
+			html.Element("polygon", attr.Points("6 3 20 12 6 21 6 3")),
+		}
 	}
 
 	return html.Element("svg", append(svgAttrs, paths...)...)
@@ -0,0 +1,398 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type AdminOverviewData struct {
+	Stats    *models.SystemStats
+	Settings *models.InstanceSettings
+	// DatabaseSize and RepositoryStorage are in bytes
+	DatabaseSize      int64
+	RepositoryStorage int64
+	Success           string
+}
+
+type AdminUsersData struct {
+	CurrentUser *models.User
+	Users       []*models.User
+	Query       string
+	// Truncated is whether more users match than are shown
+	Truncated bool
+	Success   string
+	Error     string
+}
+
+type AdminRepository struct {
+	Repository    *models.Repository
+	OwnerUsername string
+	// DiskUsage is the size of the repository on disk in bytes
+	DiskUsage int64
+}
+
+type AdminRepositoriesData struct {
+	Repositories []AdminRepository
+	TotalSize    int64
+}
+
+func AdminOverview(r *http.Request, data *AdminOverviewData) html.Node {
+	stats := []struct {
+		label string
+		value string
+	}{
+		{"Users", strconv.Itoa(data.Stats.Users)},
+		{"Administrators", strconv.Itoa(data.Stats.Admins)},
+		{"Suspended users", strconv.Itoa(data.Stats.SuspendedUsers)},
+		{"Organizations", strconv.Itoa(data.Stats.Organizations)},
+		{"Repositories", strconv.Itoa(data.Stats.Repositories)},
+		{"Tickets", strconv.Itoa(data.Stats.Tickets)},
+		{"Database size", formatBytes(data.DatabaseSize)},
+		{"Repository storage", formatBytes(data.RepositoryStorage)},
+	}
+
+	statNodes := make([]html.Node, 0, len(stats))
+	for _, stat := range stats {
+		statNodes = append(statNodes, html.Div(
+			attr.Class("p-4 bg-muted rounded-lg space-y-1"),
+			html.P(
+				attr.Class("text-sm text-muted-foreground"),
+				html.Text(stat.label),
+			),
+			html.P(
+				attr.Class("font-semibold text-2xl"),
+				html.Text(stat.value),
+			),
+		))
+	}
+
+	return layouts.Admin(r,
+		"Site administration - Hypercommit",
+		layouts.AdminLayoutOptions{
+			CurrentTab: "overview",
+		},
+		html.Main(
+			attr.Class("w-full mx-auto max-w-7xl px-4 py-8 space-y-6"),
+			html.H1(
+				attr.Class("font-semibold text-2xl"),
+				html.Text("Site administration"),
+			),
+			adminNotice(data.Success, ""),
+			ui.Card(ui.CardProps{
+				Title:       "System",
+				Description: "What is stored on this instance",
+				Content: html.Div(
+					attr.Class("grid grid-cols-2 md:grid-cols-4 gap-4"),
+					html.Group(statNodes...),
+				),
+			}),
+			ui.Card(ui.CardProps{
+				Title:       "Instance settings",
+				Description: "Settings that apply to everyone on this instance",
+				Content: html.Form(
+					attr.Method("POST"),
+					attr.Action("/admin/settings"),
+					attr.Class("space-y-4"),
+					ui.CSRFField(r),
+					html.Label(
+						attr.For("sign_up_disabled"),
+						attr.Class("flex items-start gap-2 text-sm"),
+						html.Input(
+							attr.Type("checkbox"),
+							attr.Id("sign_up_disabled"),
+							attr.Name("sign_up_disabled"),
+							attr.Value("1"),
+							attr.Class("input mt-0.5"),
+							html.If(data.Settings.SignUpDisabled, attr.Checked()),
+						),
+						html.Span(
+							html.Text("Disable sign-up"),
+						),
+					),
+					html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.Text("Nobody can create an account, with a password or with GitHub. Existing users can still sign in."),
+					),
+					html.Label(
+						attr.For("webhooks_allow_private_networks"),
+						attr.Class("flex items-start gap-2 text-sm"),
+						html.Input(
+							attr.Type("checkbox"),
+							attr.Id("webhooks_allow_private_networks"),
+							attr.Name("webhooks_allow_private_networks"),
+							attr.Value("1"),
+							attr.Class("input mt-0.5"),
+							html.If(data.Settings.WebhooksAllowPrivateNetworks, attr.Checked()),
+						),
+						html.Span(
+							html.Text("Allow webhooks to private networks"),
+						),
+					),
+					html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.Text("Webhooks may target loopback and private network addresses, such as services running next to this instance. Anyone who manages a webhook can then reach them."),
+					),
+					html.Div(
+						attr.Class("flex justify-end"),
+						ui.Button(
+							ui.ButtonProps{
+								Variant: ui.ButtonPrimary,
+								Type:    "submit",
+							},
+							html.Text("Save"),
+						),
+					),
+				),
+			}),
+		),
+	)
+}
+
+func AdminUsers(r *http.Request, data *AdminUsersData) html.Node {
+	return layouts.Admin(r,
+		"Users - Site administration - Hypercommit",
+		layouts.AdminLayoutOptions{
+			CurrentTab: "users",
+		},
+		html.Main(
+			attr.Class("w-full mx-auto max-w-7xl px-4 py-8 space-y-6"),
+			html.H1(
+				attr.Class("font-semibold text-2xl"),
+				html.Text("Users"),
+			),
+			adminNotice(data.Success, data.Error),
+			ui.Card(ui.CardProps{
+				Title:       "Users",
+				Description: "Everyone with an account on this instance",
+				Content: html.Div(
+					attr.Class("space-y-4"),
+					html.Form(
+						attr.Method("GET"),
+						attr.Action("/admin/users"),
+						attr.Class("grid grid-cols-1 sm:grid-cols-[1fr_auto] gap-4 items-end"),
+						ui.FormField(ui.FormFieldProps{
+							Label:       "Search",
+							Id:          "admin-user-query",
+							Name:        "q",
+							Type:        "text",
+							Placeholder: "Username, name or email",
+							Icon:        ui.IconUser,
+							Value:       data.Query,
+						}),
+						ui.Button(
+							ui.ButtonProps{
+								Variant: ui.ButtonPrimary,
+								Type:    "submit",
+							},
+							html.Text("Search"),
+						),
+					),
+					html.IfElse(len(data.Users) > 0,
+						html.Div(
+							attr.Class("space-y-2"),
+							html.For(data.Users, func(user *models.User) html.Node {
+								return adminUserItem(r, data, user)
+							}),
+						),
+						html.P(
+							attr.Class("text-sm text-muted-foreground"),
+							html.Text("No users match the search."),
+						),
+					),
+					html.If(data.Truncated, html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.Text("Only the most recent users are shown. Search to find the others."),
+					)),
+				),
+			}),
+			html.Element("script",
+				html.Text(`
+					(function() {
+						document.querySelectorAll('[data-confirm]').forEach(function(form) {
+							form.addEventListener('submit', function(e) {
+								if (!confirm(form.getAttribute('data-confirm'))) {
+									e.preventDefault();
+								}
+							});
+						});
+					})();
+				`),
+			),
+		),
+	)
+}
+
+func adminUserItem(r *http.Request, data *AdminUsersData, user *models.User) html.Node {
+	base := fmt.Sprintf("/admin/users/%d", user.ID)
+	isSelf := user.ID == data.CurrentUser.ID
+
+	details := user.Email + " • joined " + formatTimestamp(user.CreatedAt)
+	if user.SuspendedAt != nil {
+		details += " • suspended " + formatTimestamp(*user.SuspendedAt)
+	}
+
+	actions := []html.Node{
+		html.IfElse(user.IsAdmin,
+			adminUserAction(r, data, base+"/demote", "Remove administrator", ui.ButtonOutline, "Remove administrator rights from @"+user.Username+"?"),
+			adminUserAction(r, data, base+"/promote", "Make administrator", ui.ButtonOutline, "Make @"+user.Username+" an administrator of this instance?"),
+		),
+		adminUserAction(r, data, base+"/reset-password", "Reset password", ui.ButtonOutline, "Sign @"+user.Username+" out everywhere and email them a link to choose a new password?"),
+		html.IfElse(user.SuspendedAt != nil,
+			adminUserAction(r, data, base+"/unsuspend", "Unsuspend", ui.ButtonOutline, ""),
+			adminUserAction(r, data, base+"/suspend", "Suspend", ui.ButtonOutline, "Suspend @"+user.Username+"? They are signed out and can't sign in until they are unsuspended."),
+		),
+		adminUserAction(r, data, base+"/delete", "Delete", ui.ButtonDestructive, "Delete @"+user.Username+" and all repositories they own? This action cannot be undone."),
+	}
+
+	return html.Div(
+		attr.Class("p-3 bg-muted rounded-lg flex flex-wrap items-center justify-between gap-4"),
+		html.Div(
+			attr.Class("space-y-1 min-w-0"),
+			html.Div(
+				attr.Class("flex flex-wrap items-center gap-2"),
+				html.A(
+					attr.Href("/"+user.Username),
+					attr.Class("font-medium text-sm text-foreground hover:underline"),
+					html.Text(user.DisplayName),
+				),
+				html.Span(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("@"+user.Username),
+				),
+				html.If(user.IsAdmin, ui.Badge(ui.BadgeProps{Variant: ui.BadgePrimary}, html.Text("Admin"))),
+				html.If(user.SuspendedAt != nil, ui.Badge(ui.BadgeProps{Variant: ui.BadgeDestructive}, html.Text("Suspended"))),
+				html.If(isSelf, ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text("You"))),
+			),
+			html.P(
+				attr.Class("text-xs text-muted-foreground break-all"),
+				html.Text(details),
+			),
+		),
+		html.If(!isSelf, html.Div(
+			attr.Class("flex flex-wrap gap-2"),
+			html.Group(actions...),
+		)),
+	)
+}
+
+// adminUserAction is a button posting to action, which asks for confirmation first unless confirmation is empty
+func adminUserAction(r *http.Request, data *AdminUsersData, action, label string, variant ui.ButtonVariant, confirmation string) html.Node {
+	return html.Form(
+		attr.Method("POST"),
+		attr.Action(action),
+		html.If(confirmation != "", attr.Attribute{Key: "data-confirm", Value: confirmation}),
+		ui.CSRFField(r),
+		html.Input(
+			attr.Type("hidden"),
+			attr.Name("q"),
+			attr.Value(data.Query),
+		),
+		ui.Button(
+			ui.ButtonProps{
+				Variant: variant,
+				Size:    ui.ButtonSmall,
+				Type:    "submit",
+			},
+			html.Text(label),
+		),
+	)
+}
+
+func AdminRepositories(r *http.Request, data *AdminRepositoriesData) html.Node {
+	return layouts.Admin(r,
+		"Repositories - Site administration - Hypercommit",
+		layouts.AdminLayoutOptions{
+			CurrentTab: "repositories",
+		},
+		html.Main(
+			attr.Class("w-full mx-auto max-w-7xl px-4 py-8 space-y-6"),
+			html.H1(
+				attr.Class("font-semibold text-2xl"),
+				html.Text("Repositories"),
+			),
+			ui.Card(ui.CardProps{
+				Title:       "Repositories",
+				Description: fmt.Sprintf("%d repositories using %s on disk, largest first", len(data.Repositories), formatBytes(data.TotalSize)),
+				Content: html.IfElse(len(data.Repositories) > 0,
+					html.Div(
+						attr.Class("space-y-2"),
+						html.For(data.Repositories, func(repo AdminRepository) html.Node {
+							return adminRepositoryItem(repo)
+						}),
+					),
+					html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.Text("There are no repositories yet."),
+					),
+				),
+			}),
+		),
+	)
+}
+
+func adminRepositoryItem(repo AdminRepository) html.Node {
+	fullName := repo.OwnerUsername + "/" + repo.Repository.Name
+
+	return html.Div(
+		attr.Class("p-3 bg-muted rounded-lg flex flex-wrap items-center justify-between gap-4"),
+		html.Div(
+			attr.Class("space-y-1 min-w-0"),
+			html.Div(
+				attr.Class("flex flex-wrap items-center gap-2"),
+				html.A(
+					attr.Href("/"+fullName),
+					attr.Class("font-medium text-sm text-foreground hover:underline"),
+					html.Text(fullName),
+				),
+				html.IfElse(repo.Repository.Visibility == "public",
+					ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text("Public")),
+					ui.Badge(ui.BadgeProps{Variant: ui.BadgeSecondary}, html.Text("Private")),
+				),
+			),
+			html.P(
+				attr.Class("text-xs text-muted-foreground"),
+				html.Text("Created "+formatTimestamp(repo.Repository.CreatedAt)),
+			),
+		),
+		html.Span(
+			attr.Class("text-sm font-mono text-muted-foreground"),
+			html.Text(formatBytes(repo.DiskUsage)),
+		),
+	)
+}
+
+// adminNotice shows the outcome of the last action, if any
+func adminNotice(success, failure string) html.Node {
+	return html.Group(
+		html.If(success != "", html.Div(
+			attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+			html.Text(success),
+		)),
+		html.If(failure != "", html.Div(
+			attr.Class("p-3 rounded-lg bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 text-red-800 dark:text-red-200 text-sm"),
+			html.Text(failure),
+		)),
+	)
+}
+
+// formatBytes formats a size in bytes with binary units
+func formatBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,210 @@
+package pages
+
+import (
+	"net/http"
+	"strconv"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type APIDocsData struct {
+	// SpecURL is where the OpenAPI document is served
+	SpecURL  string
+	Sections []APIDocsSection
+}
+
+type APIDocsSection struct {
+	Name        string
+	Description string
+	Endpoints   []APIDocsEndpoint
+}
+
+type APIDocsEndpoint struct {
+	ID              string
+	Method          string
+	Path            string
+	Summary         string
+	Description     string
+	PathParameters  []string
+	QueryParameters []string
+	Status          int
+	// RequestExample and ResponseExample are JSON documents, empty if there is no body
+	RequestExample  string
+	ResponseExample string
+}
+
+// apiDocsMethodVariants tells HTTP methods apart at a glance
+var apiDocsMethodVariants = map[string]ui.BadgeVariant{
+	http.MethodGet:    ui.BadgeSecondary,
+	http.MethodPost:   ui.BadgePrimary,
+	http.MethodPut:    ui.BadgePrimary,
+	http.MethodPatch:  ui.BadgeOutline,
+	http.MethodDelete: ui.BadgeDestructive,
+}
+
+func APIDocs(r *http.Request, data *APIDocsData) html.Node {
+	return layouts.Main(r,
+		"REST API - Hypercommit",
+		html.Main(
+			attr.Class("w-full mx-auto max-w-5xl px-4 py-8 space-y-8"),
+			html.Div(
+				attr.Class("space-y-2"),
+				html.H1(
+					attr.Class("text-xl font-medium"),
+					html.Text("REST API"),
+				),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Authenticate with a personal access token or an OAuth access token that has the api scope. Lists are paginated with per_page and the cursor of the Link header. The "),
+					html.A(
+						attr.Href(data.SpecURL),
+						attr.Class("underline"),
+						html.Text("OpenAPI document"),
+					),
+					html.Text(" describes every endpoint for code generators."),
+				),
+			),
+			html.Div(
+				attr.Class("space-y-2"),
+				ui.LabelFor("api-docs-token", html.Text("Access token")),
+				html.Input(
+					attr.Type("password"),
+					attr.Id("api-docs-token"),
+					attr.Placeholder("Leave empty to try the API anonymously"),
+					attr.Class("input w-full font-mono text-sm"),
+				),
+				html.P(
+					attr.Class("text-xs text-muted-foreground"),
+					html.Text("Requests you try below are sent from your browser with this token. It is kept in this tab only."),
+				),
+			),
+			html.For(data.Sections, func(section APIDocsSection) html.Node {
+				return html.Section(
+					attr.Id(section.Name),
+					attr.Class("space-y-4"),
+					html.Div(
+						html.H2(
+							attr.Class("text-lg font-medium capitalize"),
+							html.Text(section.Name),
+						),
+						html.P(
+							attr.Class("text-sm text-muted-foreground"),
+							html.Text(section.Description),
+						),
+					),
+					html.For(section.Endpoints, apiDocsEndpoint),
+				)
+			}),
+			html.Script(
+				attr.Src("/api-docs.js"),
+				attr.Defer(),
+			),
+		),
+	)
+}
+
+func apiDocsEndpoint(endpoint APIDocsEndpoint) html.Node {
+	return html.Div(
+		attr.Id(endpoint.ID),
+		attr.Class("border rounded-lg p-4 space-y-3"),
+		html.Div(
+			attr.Class("flex items-center gap-3"),
+			ui.Badge(ui.BadgeProps{Variant: apiDocsMethodVariants[endpoint.Method], Class: "font-mono"},
+				html.Text(endpoint.Method),
+			),
+			html.Element("code",
+				attr.Class("font-mono text-sm break-all"),
+				html.Text(endpoint.Path),
+			),
+		),
+		html.Div(
+			html.P(
+				attr.Class("text-sm font-medium"),
+				html.Text(endpoint.Summary),
+			),
+			html.P(
+				attr.Class("text-xs text-muted-foreground"),
+				html.Text(endpoint.Description),
+			),
+		),
+		html.If(endpoint.RequestExample != "",
+			apiDocsExample("Request body", endpoint.RequestExample),
+		),
+		html.If(endpoint.ResponseExample != "",
+			apiDocsExample("Response "+strconv.Itoa(endpoint.Status), endpoint.ResponseExample),
+		),
+		html.Details(
+			html.Summary(
+				attr.Class("text-sm cursor-pointer"),
+				html.Text("Try it"),
+			),
+			html.Form(
+				attr.Class("space-y-3 pt-3"),
+				attr.Attribute{Key: "data-api-docs-method", Value: endpoint.Method},
+				attr.Attribute{Key: "data-api-docs-path", Value: endpoint.Path},
+				html.For(endpoint.PathParameters, func(name string) html.Node {
+					return apiDocsParameter(endpoint.ID, name, "path", true)
+				}),
+				html.For(endpoint.QueryParameters, func(name string) html.Node {
+					return apiDocsParameter(endpoint.ID, name, "query", false)
+				}),
+				html.If(endpoint.RequestExample != "",
+					html.Textarea(
+						attr.Name("body"),
+						attr.AriaLabel("Request body"),
+						attr.Class("textarea min-h-[120px] w-full font-mono text-xs"),
+						html.Text(endpoint.RequestExample),
+					),
+				),
+				ui.Button(ui.ButtonProps{
+					Variant: ui.ButtonOutline,
+					Size:    ui.ButtonSmall,
+					Type:    "submit",
+				},
+					html.Text("Send request"),
+				),
+				html.Element("pre",
+					attr.Class("hidden bg-muted rounded-md p-3 text-xs font-mono overflow-x-auto"),
+					attr.Attribute{Key: "data-api-docs-result", Value: ""},
+				),
+			),
+		),
+	)
+}
+
+func apiDocsExample(title, example string) html.Node {
+	return html.Details(
+		html.Summary(
+			attr.Class("text-sm cursor-pointer"),
+			html.Text(title),
+		),
+		html.Element("pre",
+			attr.Class("bg-muted rounded-md p-3 mt-2 text-xs font-mono overflow-x-auto"),
+			html.Text(example),
+		),
+	)
+}
+
+// apiDocsParameter is an input of the try it form, in is where /api-docs.js puts its value
+func apiDocsParameter(endpointID, name, in string, required bool) html.Node {
+	id := endpointID + "-" + name
+	inputAttrs := []html.Node{
+		attr.Type("text"),
+		attr.Id(id),
+		attr.Name(name),
+		attr.Class("input font-mono text-sm"),
+		attr.Attribute{Key: "data-api-docs-in", Value: in},
+	}
+	if required {
+		inputAttrs = append(inputAttrs, attr.Required())
+	}
+
+	return html.Div(
+		attr.Class("grid grid-cols-[8rem_1fr] items-center gap-2"),
+		ui.LabelFor(id, html.Text(name)),
+		html.Input(inputAttrs...),
+	)
+}
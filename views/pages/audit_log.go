@@ -0,0 +1,231 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// auditActionLabels describes audit event actions for people
+var auditActionLabels = map[string]string{
+	models.AuditSignIn:                      "Signed in",
+	models.AuditSignInFailed:                "Failed to sign in",
+	models.AuditRepositoryCreate:            "Created repository",
+	models.AuditRepositoryUpdate:            "Updated repository settings",
+	models.AuditRepositoryVisibilityChange:  "Changed repository visibility",
+	models.AuditRepositoryDelete:            "Deleted repository",
+	models.AuditCollaboratorAdd:             "Added collaborator",
+	models.AuditCollaboratorRoleChange:      "Changed collaborator role",
+	models.AuditCollaboratorRemove:          "Removed collaborator",
+	models.AuditAccessTokenCreate:           "Created access token",
+	models.AuditAccessTokenDelete:           "Deleted access token",
+	models.AuditOAuthApplicationCreate:      "Registered OAuth application",
+	models.AuditOAuthApplicationUpdate:      "Updated OAuth application",
+	models.AuditOAuthApplicationSecretReset: "Reset OAuth application secret",
+	models.AuditOAuthApplicationDelete:      "Deleted OAuth application",
+	models.AuditOAuthAuthorize:              "Authorized OAuth application",
+	models.AuditOAuthRevoke:                 "Revoked OAuth application",
+	models.AuditWebhookCreate:               "Created webhook",
+	models.AuditWebhookUpdate:               "Updated webhook",
+	models.AuditWebhookDelete:               "Deleted webhook",
+	models.AuditSecretCreate:                "Created secret",
+	models.AuditSecretUpdate:                "Updated secret",
+	models.AuditSecretDelete:                "Deleted secret",
+	models.AuditSecretAccess:                "Handed secrets to a workflow job",
+	models.AuditOrganizationCreate:          "Created organization",
+	models.AuditOrganizationUpdate:          "Updated organization settings",
+	models.AuditMemberAdd:                   "Added member",
+	models.AuditMemberRemove:                "Removed member",
+	models.AuditAdminUserSuspend:            "Suspended user",
+	models.AuditAdminUserUnsuspend:          "Unsuspended user",
+	models.AuditAdminUserPasswordReset:      "Forced password reset",
+	models.AuditAdminUserDelete:             "Deleted user",
+	models.AuditAdminUserPromote:            "Made user an administrator",
+	models.AuditAdminUserDemote:             "Removed administrator",
+	models.AuditAdminSettingsUpdate:         "Updated instance settings",
+}
+
+type OrganizationAuditLogData struct {
+	Organization *models.Organization
+	Events       []*models.AuditEvent
+	Action       string
+	Actor        string
+	// Truncated is whether there are more matching events than shown, they are all in the exports
+	Truncated bool
+}
+
+func OrganizationAuditLog(r *http.Request, data *OrganizationAuditLogData) html.Node {
+	base := "/" + data.Organization.Username + "/settings/audit-log"
+
+	actionOptions := []ui.SelectOption{{Value: "", Label: "All actions", Selected: data.Action == ""}}
+	for _, action := range models.AuditActions {
+		actionOptions = append(actionOptions, ui.SelectOption{
+			Value:    action,
+			Label:    auditActionLabels[action],
+			Selected: data.Action == action,
+		})
+	}
+
+	return layouts.Profile(r,
+		data.Organization.DisplayName+" audit log - Hypercommit",
+		layouts.ProfileLayoutOptions{
+			Username:     data.Organization.Username,
+			DisplayName:  data.Organization.DisplayName,
+			IsOrg:        true,
+			CurrentTab:   "settings",
+			ShowSettings: true,
+		},
+		html.Main(
+			attr.Class("w-full mx-auto max-w-7xl px-4 py-8 space-y-6"),
+			html.Div(
+				attr.Class("flex flex-wrap items-center justify-between gap-4"),
+				html.H1(
+					attr.Class("font-semibold text-2xl"),
+					html.Text("Audit log"),
+				),
+				html.Div(
+					attr.Class("flex gap-2"),
+					html.A(
+						attr.Href(base+"?"+auditLogQuery(data, "csv")),
+						attr.Class("btn-outline"),
+						html.Text("Export CSV"),
+					),
+					html.A(
+						attr.Href(base+"?"+auditLogQuery(data, "json")),
+						attr.Class("btn-outline"),
+						html.Text("Export JSON"),
+					),
+				),
+			),
+			ui.Card(ui.CardProps{
+				Title:       "Events",
+				Description: "Security-relevant actions in the organization and its repositories",
+				Content: html.Div(
+					attr.Class("space-y-4"),
+					html.Form(
+						attr.Method("GET"),
+						attr.Action(base),
+						attr.Class("grid grid-cols-1 sm:grid-cols-[1fr_1fr_auto] gap-4 items-end"),
+						ui.Select(ui.SelectProps{
+							Id:      "audit-action",
+							Name:    "action",
+							Label:   "Action",
+							Class:   "sm:w-full !mb-0",
+							Options: actionOptions,
+						}),
+						ui.FormField(ui.FormFieldProps{
+							Label:       "Actor",
+							Id:          "audit-actor",
+							Name:        "actor",
+							Type:        "text",
+							Placeholder: "Username",
+							Icon:        ui.IconUser,
+							Value:       data.Actor,
+						}),
+						ui.Button(
+							ui.ButtonProps{
+								Variant: ui.ButtonPrimary,
+								Type:    "submit",
+							},
+							html.Text("Filter"),
+						),
+					),
+					html.IfElse(len(data.Events) > 0,
+						html.Div(
+							attr.Class("space-y-2"),
+							html.Group(auditEventList(data.Events)...),
+						),
+						html.P(
+							attr.Class("text-sm text-muted-foreground"),
+							html.Text("No events match the filters."),
+						),
+					),
+					html.If(data.Truncated, html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.Text("Only the most recent events are shown. Export the log to get all of them."),
+					)),
+				),
+			}),
+		),
+	)
+}
+
+func auditLogQuery(data *OrganizationAuditLogData, format string) string {
+	query := url.Values{}
+	if data.Action != "" {
+		query.Set("action", data.Action)
+	}
+	if data.Actor != "" {
+		query.Set("actor", data.Actor)
+	}
+	query.Set("format", format)
+	return query.Encode()
+}
+
+func auditEventList(events []*models.AuditEvent) []html.Node {
+	nodes := make([]html.Node, 0, len(events))
+	for _, event := range events {
+		nodes = append(nodes, auditEventItem(event))
+	}
+	return nodes
+}
+
+func auditEventItem(event *models.AuditEvent) html.Node {
+	label, ok := auditActionLabels[event.Action]
+	if !ok {
+		label = event.Action
+	}
+
+	actor := "Someone"
+	if event.ActorName != "" {
+		actor = "@" + event.ActorName
+	}
+
+	details := actor + " • " + formatTimestamp(event.CreatedAt)
+	if event.IPAddress != "" {
+		details += " • " + event.IPAddress
+	}
+
+	return html.Div(
+		attr.Class("p-3 bg-muted rounded-lg space-y-1"),
+		html.Div(
+			attr.Class("flex flex-wrap items-center gap-2"),
+			html.Span(
+				attr.Class("font-medium text-sm text-foreground"),
+				html.Text(label),
+			),
+			html.If(event.TargetName != "", ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text(event.TargetName))),
+			html.If(event.Action == models.AuditSignInFailed, ui.Badge(ui.BadgeProps{Variant: ui.BadgeDestructive}, html.Text("Failed"))),
+		),
+		html.P(
+			attr.Class("text-xs text-muted-foreground"),
+			html.Text(details),
+		),
+		html.If(len(event.Metadata) > 0, html.P(
+			attr.Class("text-xs text-muted-foreground font-mono break-all"),
+			html.Text(formatAuditMetadata(event.Metadata)),
+		)),
+	)
+}
+
+// formatAuditMetadata lists metadata as key=value pairs sorted by key
+func formatAuditMetadata(metadata map[string]string) string {
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, key+"="+metadata[key])
+	}
+	return strings.Join(pairs, " ")
+}
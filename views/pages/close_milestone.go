@@ -0,0 +1,96 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type CloseMilestoneData struct {
+	User          *models.User
+	Repository    *models.Repository
+	OwnerUsername string
+	Milestone     *models.Milestone
+	OpenTickets   int64
+	Targets       []*models.Milestone
+	StarCount     int64
+	HasStarred    bool
+	CloneURL      string
+	RepositoryURL string
+}
+
+func CloseMilestone(r *http.Request, data *CloseMilestoneData) html.Node {
+	if data == nil {
+		data = &CloseMilestoneData{}
+	}
+
+	baseURL := "/" + data.OwnerUsername + "/" + data.Repository.Name
+
+	// Offer to keep the open tickets, detach them, or move them to another open milestone
+	options := []ui.SelectOption{
+		{Value: "keep", Label: "Keep them on this milestone", Selected: true},
+		{Value: "none", Label: "Remove them from any milestone"},
+	}
+	for _, target := range data.Targets {
+		options = append(options, ui.SelectOption{
+			Value: fmt.Sprintf("%d", target.ID),
+			Label: "Move them to " + target.Title,
+		})
+	}
+
+	return layouts.Repository(r,
+		"Close milestone - "+data.OwnerUsername+"/"+data.Repository.Name,
+		layouts.RepositoryLayoutOptions{
+			OwnerUsername: data.OwnerUsername,
+			RepoName:      data.Repository.Name,
+			CurrentTab:    "tickets",
+			IsPublic:      data.Repository.Visibility == "public",
+			ShowSettings:  true,
+			StarCount:     data.StarCount,
+			HasStarred:    data.HasStarred,
+			DefaultBranch: data.Repository.DefaultBranch,
+			CloneURL:      data.CloneURL,
+			RepositoryURL: data.RepositoryURL,
+		},
+		html.Main(
+			attr.Class("container mx-auto px-4 py-8 max-w-3xl"),
+			ui.Card(ui.CardProps{
+				Title:       "Close " + data.Milestone.Title,
+				Description: fmt.Sprintf("This milestone still has %d open ticket(s).", data.OpenTickets),
+				Content: html.Form(
+					attr.Method("post"),
+					attr.Action(fmt.Sprintf("%s/milestones/%d/close", baseURL, data.Milestone.ID)),
+					attr.Class("space-y-4"),
+					ui.CSRFField(r),
+					html.If(
+						data.OpenTickets > 0,
+						ui.Select(ui.SelectProps{
+							Id:      "move_to",
+							Name:    "move_to",
+							Label:   "Open tickets",
+							Options: options,
+						}),
+					),
+					html.Div(
+						attr.Class("flex gap-3"),
+						html.Button(
+							attr.Type("submit"),
+							attr.Class("btn-primary"),
+							html.Text("Close milestone"),
+						),
+						html.A(
+							attr.Href(baseURL+"/milestones"),
+							attr.Class("btn-outline"),
+							html.Text("Cancel"),
+						),
+					),
+				),
+			}),
+		),
+	)
+}
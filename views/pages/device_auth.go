@@ -11,10 +11,14 @@ import (
 )
 
 type DeviceAuthData struct {
-	User    *models.User
-	Code    string
-	Success bool
-	Error   string
+	User *models.User
+	Code string
+	// Application and Scopes are set once a valid code was entered
+	Application *models.OAuthApplication
+	Scopes      []string
+	Success     bool
+	Denied      bool
+	Error       string
 }
 
 func DeviceAuth(r *http.Request, data *DeviceAuthData) html.Node {
@@ -44,6 +48,49 @@ func DeviceAuth(r *http.Request, data *DeviceAuthData) html.Node {
 				html.Text("Your device has been successfully authenticated. You can now close this window and return to your terminal."),
 			),
 		)
+	} else if data.Denied {
+		content = html.Div(
+			attr.Class("max-w-md mx-auto text-center"),
+			html.H2(
+				attr.Class("text-2xl font-semibold mb-3"),
+				html.Text("Request Denied"),
+			),
+			html.P(
+				attr.Class("text-muted-foreground mb-6 text-pretty"),
+				html.Text(data.Application.Name+" didn't get access to your account. You can close this window."),
+			),
+		)
+	} else if data.User != nil && data.Application != nil {
+		// Show what the device gets access to
+		content = html.Div(
+			attr.Class("max-w-md mx-auto"),
+			html.Div(
+				attr.Class("text-center mb-8"),
+				html.H2(
+					attr.Class("text-2xl font-semibold mb-3"),
+					html.Text("Authorize "+data.Application.Name),
+				),
+				html.P(
+					attr.Class("text-muted-foreground"),
+					html.Text("A device showing the code "),
+					html.Span(attr.Class("font-mono font-semibold text-foreground"), html.Text(data.Code)),
+					html.Text(" wants to access your account "),
+					html.Span(
+						attr.Class("font-semibold text-foreground"),
+						html.Text("@"+data.User.Username),
+					),
+				),
+			),
+			oauthScopeList(data.Scopes),
+			html.Form(
+				attr.Method("POST"),
+				attr.Action("/auth/device/confirm"),
+				attr.Class("flex gap-3 mt-6"),
+				ui.CSRFField(r),
+				html.Input(attr.Type("hidden"), attr.Name("code"), attr.Value(data.Code)),
+				oauthDecisionButtons(),
+			),
+		)
 	} else if data.User != nil {
 		// Show confirmation form
 		content = html.Div(
@@ -52,7 +99,7 @@ func DeviceAuth(r *http.Request, data *DeviceAuthData) html.Node {
 				attr.Class("text-center mb-8"),
 				html.H2(
 					attr.Class("text-2xl font-semibold mb-3"),
-					html.Text("Authenticate Hypercommit CLI"),
+					html.Text("Connect a Device"),
 				),
 				html.P(
 					attr.Class("text-muted-foreground"),
@@ -70,8 +117,8 @@ func DeviceAuth(r *http.Request, data *DeviceAuthData) html.Node {
 			)),
 
 			html.Form(
-				attr.Method("POST"),
-				attr.Action("/auth/device/confirm"),
+				attr.Method("GET"),
+				attr.Action("/auth/device"),
 				attr.Class("space-y-6"),
 
 				html.Div(
@@ -79,7 +126,7 @@ func DeviceAuth(r *http.Request, data *DeviceAuthData) html.Node {
 					html.Label(
 						attr.For("code"),
 						attr.Class("label text-center block"),
-						html.Text("Enter the code shown in your terminal:"),
+						html.Text("Enter the code shown on your device:"),
 					),
 					html.Input(
 						attr.Type("text"),
@@ -103,7 +150,7 @@ func DeviceAuth(r *http.Request, data *DeviceAuthData) html.Node {
 					Variant: ui.ButtonPrimary,
 					Type:    "submit",
 					Class:   "flex-1 w-full",
-				}, html.Text("Confirm")),
+				}, html.Text("Continue")),
 			),
 
 			// JavaScript to format code input
@@ -0,0 +1,82 @@
+package pages
+
+import (
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type ForgotPasswordData struct {
+	Email string
+	Error string
+	// Sent is true once a request was accepted. The page never says whether the email is registered.
+	Sent bool
+}
+
+func ForgotPassword(r *http.Request, data *ForgotPasswordData) html.Node {
+	if data == nil {
+		data = &ForgotPasswordData{}
+	}
+
+	return layouts.Main(r,
+		"Forgot password",
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] flex flex-col items-center justify-center w-full mx-auto max-w-xs space-y-6 py-6 px-4 sm:px-0"),
+			html.H2(
+				attr.Class("font-medium text-xl text-center"),
+				html.Text("Reset your password"),
+			),
+			html.If(data.Sent, ui.Alert(ui.AlertProps{
+				Variant:     ui.AlertDefault,
+				Icon:        ui.SVGIcon(ui.IconMail, "h-4 w-4"),
+				Title:       "Check your email",
+				Description: "If an account exists for that email address, we sent a link to reset its password. The link expires in 1 hour.",
+			})),
+			html.If(data.Error != "", ui.Alert(ui.AlertProps{
+				Variant:     ui.AlertDestructive,
+				Icon:        ui.SVGIcon(ui.IconAlertCircle, "h-4 w-4"),
+				Title:       "Error",
+				Description: data.Error,
+			})),
+			html.If(!data.Sent, html.Form(
+				attr.Method("POST"),
+				attr.Action("/forgot-password"),
+				attr.Class("w-full space-y-4"),
+				ui.CSRFField(r),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Enter the email address of your account and we will send you a link to reset your password."),
+				),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "Email Address",
+					Id:          "email",
+					Name:        "email",
+					Type:        "email",
+					Placeholder: "john@doe.com",
+					Icon:        ui.IconMail,
+					Required:    true,
+					Value:       data.Email,
+				}),
+				ui.Button(
+					ui.ButtonProps{
+						Variant: ui.ButtonPrimary,
+						Type:    "submit",
+						Class:   "w-full",
+					},
+					html.Text("Send reset link"),
+				),
+			)),
+			html.P(
+				attr.Class("text-sm text-center text-muted-foreground"),
+				html.A(
+					attr.Href("/auth/sign-in"),
+					attr.Class("underline underline-offset-4 hover:text-foreground"),
+					html.Text("Back to sign in"),
+				),
+			),
+		),
+	)
+}
@@ -0,0 +1,128 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type MilestoneFormData struct {
+	User          *models.User
+	Repository    *models.Repository
+	OwnerUsername string
+	Milestone     *models.Milestone // nil when creating a new milestone
+	Title         string
+	Description   string
+	DueDate       string
+	TitleError    string
+	DueDateError  string
+	StarCount     int64
+	HasStarred    bool
+	CloneURL      string
+	RepositoryURL string
+}
+
+func MilestoneForm(r *http.Request, data *MilestoneFormData) html.Node {
+	if data == nil {
+		data = &MilestoneFormData{}
+	}
+
+	baseURL := "/" + data.OwnerUsername + "/" + data.Repository.Name
+
+	heading := "New milestone"
+	action := baseURL + "/milestones/new"
+	submitLabel := "Create milestone"
+	if data.Milestone != nil {
+		heading = "Edit milestone"
+		action = fmt.Sprintf("%s/milestones/%d/edit", baseURL, data.Milestone.ID)
+		submitLabel = "Save changes"
+	}
+
+	return layouts.Repository(r,
+		heading+" - "+data.OwnerUsername+"/"+data.Repository.Name,
+		layouts.RepositoryLayoutOptions{
+			OwnerUsername: data.OwnerUsername,
+			RepoName:      data.Repository.Name,
+			CurrentTab:    "tickets",
+			IsPublic:      data.Repository.Visibility == "public",
+			ShowSettings:  true,
+			StarCount:     data.StarCount,
+			HasStarred:    data.HasStarred,
+			DefaultBranch: data.Repository.DefaultBranch,
+			CloneURL:      data.CloneURL,
+			RepositoryURL: data.RepositoryURL,
+		},
+		html.Main(
+			attr.Class("container mx-auto px-4 py-8 max-w-7xl"),
+			html.Div(
+				attr.Class("space-y-6"),
+				html.H1(
+					attr.Class("text-2xl font-semibold"),
+					html.Text(heading),
+				),
+
+				html.Form(
+					attr.Method("post"),
+					attr.Action(action),
+					attr.Class("space-y-6"),
+					ui.CSRFField(r),
+
+					ui.FormField(ui.FormFieldProps{
+						Label:       "Title",
+						Id:          "title",
+						Name:        "title",
+						Type:        "text",
+						Placeholder: "v1.2",
+						Required:    true,
+						Value:       data.Title,
+						Error:       data.TitleError,
+					}),
+
+					ui.FormField(ui.FormFieldProps{
+						Label: "Due date (optional)",
+						Id:    "due_date",
+						Name:  "due_date",
+						Type:  "date",
+						Value: data.DueDate,
+						Error: data.DueDateError,
+					}),
+
+					html.Div(
+						attr.Class("space-y-2"),
+						html.Label(
+							attr.For("description"),
+							attr.Class("label"),
+							html.Text("Description"),
+						),
+						html.Textarea(
+							attr.Id("description"),
+							attr.Name("description"),
+							attr.Class("textarea min-h-[120px]"),
+							attr.Placeholder("What should ship in this milestone?"),
+							html.Text(data.Description),
+						),
+					),
+
+					html.Div(
+						attr.Class("flex gap-3"),
+						html.Button(
+							attr.Type("submit"),
+							attr.Class("btn-primary"),
+							html.Text(submitLabel),
+						),
+						html.A(
+							attr.Href(baseURL+"/milestones"),
+							attr.Class("btn-outline"),
+							html.Text("Cancel"),
+						),
+					),
+				),
+			),
+		),
+	)
+}
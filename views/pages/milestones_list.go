@@ -0,0 +1,274 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type MilestoneProgress struct {
+	Milestone     *models.Milestone
+	OpenTickets   int64
+	ClosedTickets int64
+}
+
+// Percent returns the share of closed tickets, rounded down
+func (p MilestoneProgress) Percent() int64 {
+	total := p.OpenTickets + p.ClosedTickets
+	if total == 0 {
+		return 0
+	}
+	return p.ClosedTickets * 100 / total
+}
+
+type MilestonesListData struct {
+	User          *models.User
+	Repository    *models.Repository
+	OwnerUsername string
+	Milestones    []MilestoneProgress
+	StatusFilter  string
+	OpenCount     int64
+	ClosedCount   int64
+	CanManage     bool
+	StarCount     int64
+	HasStarred    bool
+	CloneURL      string
+	RepositoryURL string
+}
+
+func MilestonesList(r *http.Request, data *MilestonesListData) html.Node {
+	if data == nil {
+		data = &MilestonesListData{}
+	}
+
+	baseURL := "/" + data.OwnerUsername + "/" + data.Repository.Name
+
+	return layouts.Repository(r,
+		"Milestones - "+data.OwnerUsername+"/"+data.Repository.Name,
+		layouts.RepositoryLayoutOptions{
+			OwnerUsername: data.OwnerUsername,
+			RepoName:      data.Repository.Name,
+			CurrentTab:    "tickets",
+			IsPublic:      data.Repository.Visibility == "public",
+			ShowSettings:  data.CanManage,
+			StarCount:     data.StarCount,
+			HasStarred:    data.HasStarred,
+			DefaultBranch: data.Repository.DefaultBranch,
+			CloneURL:      data.CloneURL,
+			RepositoryURL: data.RepositoryURL,
+		},
+		html.Main(
+			attr.Class("container mx-auto px-4 py-8 max-w-7xl"),
+			html.Div(
+				attr.Class("space-y-6"),
+				// Header with New Milestone button
+				html.Div(
+					attr.Class("flex justify-between items-center"),
+					html.H1(
+						attr.Class("text-2xl font-semibold"),
+						html.Text("Milestones"),
+					),
+					html.Div(
+						attr.Class("flex items-center gap-2"),
+						html.A(
+							attr.Href(baseURL+"/tickets"),
+							attr.Class("btn-outline"),
+							html.Text("Tickets"),
+						),
+						html.If(
+							data.CanManage,
+							html.A(
+								attr.Href(baseURL+"/milestones/new"),
+								attr.Class("btn-primary inline-flex items-center gap-2"),
+								ui.SVGIcon(ui.IconPlus, "size-4"),
+								html.Text("New milestone"),
+							),
+						),
+					),
+				),
+
+				ui.Card(ui.CardProps{
+					Class: "!pt-1",
+					Content: html.Div(
+						attr.Class("space-y-4"),
+						// Filter tabs
+						html.Div(
+							attr.Class("flex flex-wrap items-center gap-4 -mx-6 px-6 border-b"),
+							milestoneFilterTab("open", data.StatusFilter, data.OpenCount, baseURL),
+							milestoneFilterTab("closed", data.StatusFilter, data.ClosedCount, baseURL),
+						),
+
+						html.Div(
+							attr.Class("-mx-6 -mb-6"),
+							renderMilestonesList(r, data),
+						),
+					),
+				}),
+			),
+		),
+	)
+}
+
+func milestoneFilterTab(status, currentStatus string, count int64, baseURL string) html.Node {
+	isActive := status == currentStatus
+	href := baseURL + "/milestones?status=" + status
+
+	spanClasses := "btn-ghost inline-flex items-center gap-2"
+	if isActive {
+		spanClasses += " font-medium"
+	} else {
+		spanClasses += " text-muted-foreground"
+	}
+
+	borderClass := "border-transparent"
+	if isActive {
+		borderClass = "border-zinc-900"
+	}
+
+	return html.A(
+		attr.Href(href),
+		attr.Class("inline-flex mt-2 pb-2 border-b-2 transition-colors "+borderClass),
+		html.Span(
+			attr.Class(spanClasses),
+			html.Text(fmt.Sprintf("%s (%d)", capitalizeFirst(status), count)),
+		),
+	)
+}
+
+func renderMilestonesList(r *http.Request, data *MilestonesListData) html.Node {
+	if len(data.Milestones) == 0 {
+		return html.Div(
+			attr.Class("py-8"),
+			ui.EmptyState(ui.EmptyStateProps{
+				Icon:        ui.SVGIcon(ui.IconCircle, "size-6"),
+				Title:       fmt.Sprintf("No %s milestones", data.StatusFilter),
+				Description: "Milestones group tickets that should ship together.",
+				ShowAction:  false,
+			}),
+		)
+	}
+
+	items := make([]html.Node, len(data.Milestones))
+	for i, progress := range data.Milestones {
+		items[i] = renderMilestoneItem(r, data, progress)
+	}
+
+	return html.Div(
+		attr.Class("divide-y"),
+		html.Group(items...),
+	)
+}
+
+func renderMilestoneItem(r *http.Request, data *MilestonesListData, progress MilestoneProgress) html.Node {
+	milestone := progress.Milestone
+	baseURL := "/" + data.OwnerUsername + "/" + data.Repository.Name
+	milestoneURL := fmt.Sprintf("%s/milestones/%d", baseURL, milestone.ID)
+	ticketsURL := baseURL + "/tickets?q=" + url.QueryEscape(fmt.Sprintf("milestone:%q", milestone.Title))
+
+	return html.Div(
+		attr.Class("p-4 flex flex-col sm:flex-row gap-4"),
+		html.Div(
+			attr.Class("flex-1 min-w-0 space-y-1"),
+			html.A(
+				attr.Href(ticketsURL),
+				attr.Class("font-medium text-lg text-foreground hover:text-primary"),
+				html.Text(milestone.Title),
+			),
+			html.Div(
+				attr.Class("text-sm text-muted-foreground"),
+				html.Text(milestoneDueText(milestone)),
+			),
+			html.If(
+				milestone.Description != nil && *milestone.Description != "",
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text(stringValue(milestone.Description)),
+				),
+			),
+		),
+		html.Div(
+			attr.Class("sm:w-80 space-y-2"),
+			html.Div(
+				attr.Class("h-2 w-full rounded-full bg-muted overflow-hidden"),
+				html.Div(
+					attr.Class("h-full bg-green-600"),
+					attr.Attribute{Key: "style", Value: fmt.Sprintf("width: %d%%", progress.Percent())},
+				),
+			),
+			html.Div(
+				attr.Class("flex items-center gap-4 text-sm text-muted-foreground"),
+				html.Span(html.Text(fmt.Sprintf("%d%% complete", progress.Percent()))),
+				html.Span(html.Text(fmt.Sprintf("%d open", progress.OpenTickets))),
+				html.Span(html.Text(fmt.Sprintf("%d closed", progress.ClosedTickets))),
+			),
+			html.If(
+				data.CanManage,
+				html.Div(
+					attr.Class("flex items-center gap-2"),
+					html.A(
+						attr.Href(milestoneURL+"/edit"),
+						attr.Class("btn-sm-outline"),
+						html.Text("Edit"),
+					),
+					html.IfElse(
+						milestone.Status == "open",
+						html.A(
+							attr.Href(milestoneURL+"/close"),
+							attr.Class("btn-sm-outline"),
+							html.Text("Close"),
+						),
+						html.Form(
+							attr.Method("post"),
+							attr.Action(milestoneURL+"/reopen"),
+							ui.CSRFField(r),
+							html.Button(
+								attr.Type("submit"),
+								attr.Class("btn-sm-outline"),
+								html.Text("Reopen"),
+							),
+						),
+					),
+					html.Form(
+						attr.Method("post"),
+						attr.Action(milestoneURL+"/delete"),
+						ui.CSRFField(r),
+						html.Button(
+							attr.Type("submit"),
+							attr.Class("btn-sm-destructive"),
+							html.Text("Delete"),
+						),
+					),
+				),
+			),
+		),
+	)
+}
+
+func milestoneDueText(milestone *models.Milestone) string {
+	if milestone.Status == "closed" && milestone.ClosedAt != nil {
+		return "Closed " + formatTime(*milestone.ClosedAt)
+	}
+	if milestone.DueDate == nil {
+		return "No due date"
+	}
+
+	due := time.Unix(*milestone.DueDate, 0).UTC()
+	if due.Before(time.Now().UTC().Truncate(24 * time.Hour)) {
+		return "Overdue, was due by " + due.Format("Jan 2, 2006")
+	}
+	return "Due by " + due.Format("Jan 2, 2006")
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -33,6 +33,7 @@ func NewOrganization(r *http.Request, data *NewOrganizationData) html.Node {
 				attr.Method("POST"),
 				attr.Action("/organizations/new"),
 				attr.Class("space-y-6 w-full"),
+				ui.CSRFField(r),
 				ui.FormField(ui.FormFieldProps{
 					Label:       "Organization username",
 					Id:          "username",
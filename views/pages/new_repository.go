@@ -49,6 +49,7 @@ func NewRepository(r *http.Request, data *NewRepositoryData) html.Node {
 				attr.Method("POST"),
 				attr.Action("/repositories/new"),
 				attr.Class("space-y-4 w-full"),
+				ui.CSRFField(r),
 
 				ownerSelector(data.User, data.Organizations, defaultOwner),
 				ui.FormField(ui.FormFieldProps{
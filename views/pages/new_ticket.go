@@ -1,10 +1,13 @@
 package pages
 
 import (
+	"fmt"
 	"net/http"
+	"net/url"
 
 	html "github.com/hypercommithq/libhtml"
 	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/services"
 	"github.com/hypercommithq/hypercommit/views/components/layouts"
 	"github.com/hypercommithq/hypercommit/views/components/ui"
 	"github.com/hypercommithq/libhtml/attr"
@@ -16,6 +19,10 @@ type NewTicketData struct {
 	OwnerUsername string
 	Title         string
 	Body          string
+	Templates     []services.TicketTemplate // shown as a chooser before the form
+	Template      *services.TicketTemplate  // template the form was prefilled from
+	MilestoneID   *int64
+	Milestones    []*models.Milestone
 	TitleError    string
 	BodyError     string
 	CanManage     bool
@@ -30,6 +37,13 @@ func NewTicket(r *http.Request, data *NewTicketData) html.Node {
 		data = &NewTicketData{}
 	}
 
+	var content html.Node
+	if len(data.Templates) > 0 {
+		content = ticketTemplateChooser(data)
+	} else {
+		content = newTicketForm(r, data)
+	}
+
 	return layouts.Repository(r,
 		"New ticket - "+data.OwnerUsername+"/"+data.Repository.Name,
 		layouts.RepositoryLayoutOptions{
@@ -53,61 +67,157 @@ func NewTicket(r *http.Request, data *NewTicketData) html.Node {
 					html.Text("New ticket"),
 				),
 
-				html.Form(
-					attr.Method("post"),
-					attr.Action("/"+data.OwnerUsername+"/"+data.Repository.Name+"/tickets/new"),
-					attr.Class("space-y-6"),
-
-					// Title field
-					ui.FormField(ui.FormFieldProps{
-						Label:       "Title",
-						Id:          "title",
-						Name:        "title",
-						Type:        "text",
-						Placeholder: "Brief description of the issue",
-						Icon:        ui.IconCircle,
-						Required:    true,
-						Value:       data.Title,
-						Error:       data.TitleError,
-					}),
-
-					// Body field
-					html.Div(
-						attr.Class("space-y-2"),
-						html.Label(
-							attr.For("body"),
-							attr.Class("label"),
-							html.Text("Description"),
-						),
-						html.Textarea(
-							attr.Id("body"),
-							attr.Name("body"),
-							attr.Class("textarea min-h-[200px]"),
-							attr.Placeholder("Provide more details about the issue..."),
-							html.Text(data.Body),
-						),
-						html.If(data.BodyError != "", html.P(
-							attr.Class("text-sm text-destructive"),
-							html.Text(data.BodyError),
-						)),
-					),
+				content,
+			),
+		),
+	)
+}
+
+func milestoneSelect(id string, selectedID *int64, milestones []*models.Milestone) html.Node {
+	options := []ui.SelectOption{
+		{Value: "", Label: "No milestone", Selected: selectedID == nil, Icon: ui.IconFlag},
+	}
+	for _, milestone := range milestones {
+		options = append(options, ui.SelectOption{
+			Value:    fmt.Sprintf("%d", milestone.ID),
+			Label:    milestone.Title,
+			Selected: selectedID != nil && *selectedID == milestone.ID,
+			Icon:     ui.IconFlag,
+		})
+	}
+
+	return ui.Select(ui.SelectProps{
+		Id:      id,
+		Name:    "milestone_id",
+		Label:   "Milestone",
+		Options: options,
+	})
+}
+
+func newTicketForm(r *http.Request, data *NewTicketData) html.Node {
+	return html.Form(
+		attr.Method("post"),
+		attr.Action("/"+data.OwnerUsername+"/"+data.Repository.Name+"/tickets/new"),
+		attr.Class("space-y-6"),
+		ui.CSRFField(r),
+
+		// Template the form was prefilled from, its labels and assignees are applied on creation
+		html.If(
+			data.Template != nil,
+			html.Input(
+				attr.Type("hidden"),
+				attr.Name("template"),
+				attr.Value(templateFile(data.Template)),
+			),
+		),
+
+		// Title field
+		ui.FormField(ui.FormFieldProps{
+			Label:       "Title",
+			Id:          "title",
+			Name:        "title",
+			Type:        "text",
+			Placeholder: "Brief description of the issue",
+			Icon:        ui.IconCircle,
+			Required:    true,
+			Value:       data.Title,
+			Error:       data.TitleError,
+		}),
+
+		// Body field
+		html.Div(
+			attr.Class("space-y-2"),
+			html.Label(
+				attr.For("body"),
+				attr.Class("label"),
+				html.Text("Description"),
+			),
+			html.Textarea(
+				attr.Id("body"),
+				attr.Name("body"),
+				attr.Class("textarea min-h-[200px]"),
+				attr.Placeholder("Provide more details about the issue..."),
+				html.Text(data.Body),
+			),
+			html.If(data.BodyError != "", html.P(
+				attr.Class("text-sm text-destructive"),
+				html.Text(data.BodyError),
+			)),
+		),
 
-					// Submit button
-					html.Div(
-						attr.Class("flex gap-3"),
-						html.Button(
-							attr.Type("submit"),
-							attr.Class("btn-primary"),
-							html.Text("Create ticket"),
-						),
-						html.A(
-							attr.Href("/"+data.OwnerUsername+"/"+data.Repository.Name+"/tickets"),
-							attr.Class("btn-outline"),
-							html.Text("Cancel"),
-						),
+		// Milestone field (only offered to repository managers)
+		html.If(
+			data.CanManage && len(data.Milestones) > 0,
+			milestoneSelect("milestone_id", data.MilestoneID, data.Milestones),
+		),
+
+		// Submit button
+		html.Div(
+			attr.Class("flex gap-3"),
+			html.Button(
+				attr.Type("submit"),
+				attr.Class("btn-primary"),
+				html.Text("Create ticket"),
+			),
+			html.A(
+				attr.Href("/"+data.OwnerUsername+"/"+data.Repository.Name+"/tickets"),
+				attr.Class("btn-outline"),
+				html.Text("Cancel"),
+			),
+		),
+	)
+}
+
+func ticketTemplateChooser(data *NewTicketData) html.Node {
+	newTicketURL := "/" + data.OwnerUsername + "/" + data.Repository.Name + "/tickets/new"
+
+	items := make([]html.Node, len(data.Templates))
+	for i, template := range data.Templates {
+		items[i] = html.Div(
+			attr.Class("p-4 flex items-center justify-between gap-4"),
+			html.Div(
+				attr.Class("min-w-0 space-y-1"),
+				html.Div(
+					attr.Class("font-medium"),
+					html.Text(template.Name),
+				),
+				html.If(
+					template.About != "",
+					html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.Text(template.About),
 					),
 				),
 			),
+			html.A(
+				attr.Href(newTicketURL+"?template="+url.QueryEscape(template.File)),
+				attr.Class("btn-sm-primary shrink-0"),
+				html.Text("Get started"),
+			),
+		)
+	}
+
+	return ui.Card(ui.CardProps{
+		Title:       "Choose a template",
+		Description: "Templates help reporters include the details maintainers need.",
+		Content: html.Div(
+			attr.Class("space-y-4"),
+			html.Div(
+				attr.Class("-mx-6 border-y divide-y"),
+				html.Group(items...),
+			),
+			html.A(
+				attr.Href(newTicketURL+"?blank=1"),
+				attr.Class("btn-outline"),
+				html.Text("Open a blank ticket"),
+			),
 		),
-	)
+	})
+}
+
+func templateFile(template *services.TicketTemplate) string {
+	if template == nil {
+		return ""
+	}
+	return template.File
 }
@@ -0,0 +1,223 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type NotificationItem struct {
+	Notification   *models.Notification
+	Ticket         *models.Ticket
+	Actor          *models.User
+	RepositoryName string // owner/name
+}
+
+type NotificationsData struct {
+	User        *models.User
+	Items       []NotificationItem
+	Filter      string // 'unread', 'all' or 'done'
+	UnreadCount int64
+}
+
+func Notifications(r *http.Request, data *NotificationsData) html.Node {
+	if data == nil {
+		data = &NotificationsData{}
+	}
+
+	return layouts.Main(r,
+		"Notifications",
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] w-full mx-auto max-w-5xl space-y-6 py-8 px-4"),
+			html.Div(
+				attr.Class("flex justify-between items-center"),
+				html.H1(
+					attr.Class("font-semibold text-2xl"),
+					html.Text("Notifications"),
+				),
+				html.If(
+					data.UnreadCount > 0,
+					html.Form(
+						attr.Method("post"),
+						attr.Action("/notifications/read-all"),
+						ui.CSRFField(r),
+						filterInput(data.Filter),
+						html.Button(
+							attr.Type("submit"),
+							attr.Class("btn-outline inline-flex items-center gap-2"),
+							ui.SVGIcon(ui.IconCheck, "size-4"),
+							html.Text("Mark all as read"),
+						),
+					),
+				),
+			),
+
+			ui.Card(ui.CardProps{
+				Class: "!pt-1",
+				Content: html.Div(
+					attr.Class("space-y-4"),
+					html.Div(
+						attr.Class("flex flex-wrap items-center gap-4 -mx-6 px-6 border-b"),
+						notificationFilterTab("unread", fmt.Sprintf("Unread (%d)", data.UnreadCount), data.Filter),
+						notificationFilterTab("all", "All", data.Filter),
+						notificationFilterTab("done", "Done", data.Filter),
+					),
+					html.Div(
+						attr.Class("-mx-6 -mb-6"),
+						renderNotificationsList(r, data),
+					),
+				),
+			}),
+		),
+	)
+}
+
+func notificationFilterTab(filter, label, currentFilter string) html.Node {
+	isActive := filter == currentFilter
+
+	spanClasses := "btn-ghost inline-flex items-center gap-2"
+	if isActive {
+		spanClasses += " font-medium"
+	} else {
+		spanClasses += " text-muted-foreground"
+	}
+
+	borderClass := "border-transparent"
+	if isActive {
+		borderClass = "border-zinc-900"
+	}
+
+	return html.A(
+		attr.Href("/notifications?filter="+filter),
+		attr.Class("inline-flex mt-2 pb-2 border-b-2 transition-colors "+borderClass),
+		html.Span(
+			attr.Class(spanClasses),
+			html.Text(label),
+		),
+	)
+}
+
+func renderNotificationsList(r *http.Request, data *NotificationsData) html.Node {
+	if len(data.Items) == 0 {
+		return html.Div(
+			attr.Class("py-8"),
+			ui.EmptyState(ui.EmptyStateProps{
+				Icon:        ui.SVGIcon(ui.IconBell, "size-6"),
+				Title:       "All caught up",
+				Description: "Subscribe to tickets or get @mentioned to receive notifications here.",
+				ShowAction:  false,
+			}),
+		)
+	}
+
+	items := make([]html.Node, len(data.Items))
+	for i, item := range data.Items {
+		items[i] = renderNotificationItem(r, item, data.Filter)
+	}
+
+	return html.Div(
+		attr.Class("divide-y"),
+		html.Group(items...),
+	)
+}
+
+func renderNotificationItem(r *http.Request, item NotificationItem, filter string) html.Node {
+	notification := item.Notification
+	notificationURL := fmt.Sprintf("/notifications/%d", notification.ID)
+	isUnread := notification.ReadAt == nil && notification.DoneAt == nil
+
+	titleClass := "text-foreground hover:text-primary"
+	if isUnread {
+		titleClass += " font-semibold"
+	}
+
+	return html.Div(
+		attr.Class("p-4 flex items-start gap-3"),
+		html.Div(
+			attr.Class("mt-1.5 size-2 shrink-0 rounded-full "+notificationDotClass(isUnread)),
+		),
+		html.Div(
+			attr.Class("flex-1 min-w-0 space-y-1"),
+			html.Div(
+				attr.Class("text-sm text-muted-foreground"),
+				html.Text(fmt.Sprintf("%s #%d", item.RepositoryName, item.Ticket.Number)),
+			),
+			html.A(
+				attr.Href(notificationURL),
+				attr.Class(titleClass),
+				html.Text(item.Ticket.Title),
+			),
+			html.Div(
+				attr.Class("text-sm text-muted-foreground"),
+				html.Text(notificationSummary(item)+" "+formatTime(notification.CreatedAt)),
+			),
+		),
+		html.Div(
+			attr.Class("flex items-center gap-2 shrink-0"),
+			html.If(
+				isUnread,
+				notificationAction(r, notificationURL+"/read", "Mark as read", filter),
+			),
+			html.If(
+				notification.DoneAt == nil,
+				notificationAction(r, notificationURL+"/done", "Done", filter),
+			),
+			notificationAction(r, notificationURL+"/unsubscribe", "Unsubscribe", filter),
+		),
+	)
+}
+
+func notificationAction(r *http.Request, action, label, filter string) html.Node {
+	return html.Form(
+		attr.Method("post"),
+		attr.Action(action),
+		ui.CSRFField(r),
+		filterInput(filter),
+		html.Button(
+			attr.Type("submit"),
+			attr.Class("btn-sm-outline"),
+			html.Text(label),
+		),
+	)
+}
+
+// filterInput keeps the active inbox filter across form submissions
+func filterInput(filter string) html.Node {
+	return html.Input(
+		attr.Type("hidden"),
+		attr.Name("filter"),
+		attr.Value(filter),
+	)
+}
+
+func notificationDotClass(isUnread bool) string {
+	if isUnread {
+		return "bg-blue-600"
+	}
+	return "bg-transparent"
+}
+
+func notificationSummary(item NotificationItem) string {
+	actor := "Someone"
+	if item.Actor != nil {
+		actor = item.Actor.Username
+	}
+
+	switch item.Notification.Reason {
+	case "mention":
+		return actor + " mentioned you"
+	case "assigned":
+		return actor + " assigned you"
+	case "closed":
+		return actor + " closed this ticket"
+	case "reopened":
+		return actor + " reopened this ticket"
+	default:
+		return actor + " commented"
+	}
+}
@@ -0,0 +1,359 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type OAuthApplicationsData struct {
+	// Organization is set when managing the applications of an organization
+	Organization *models.Organization
+	Applications []*models.OAuthApplication
+	// Authorized are the applications that hold tokens of the user
+	Authorized []*models.OAuthApplication
+	Success    string
+}
+
+func OAuthApplications(r *http.Request, data *OAuthApplicationsData) html.Node {
+	base := oauthApplicationsBase(data.Organization)
+
+	return oauthApplicationsLayout(r, data.Organization, "OAuth applications",
+		html.Div(
+			attr.Class("flex flex-wrap items-center justify-between gap-4"),
+			html.H1(
+				attr.Class("font-semibold text-2xl"),
+				html.Text("OAuth applications"),
+			),
+			html.A(
+				attr.Href(base+"/new"),
+				attr.Class("btn-primary"),
+				html.Text("New application"),
+			),
+		),
+		html.If(data.Success != "", html.Div(
+			attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+			html.Text(data.Success),
+		)),
+		ui.Card(ui.CardProps{
+			Title:       "Registered applications",
+			Description: "Applications that let people sign in and act on Hypercommit with their account",
+			Content: html.IfElse(len(data.Applications) > 0,
+				html.Div(
+					attr.Class("space-y-2"),
+					html.For(data.Applications, func(application *models.OAuthApplication) html.Node {
+						return html.Div(
+							attr.Class("flex items-center justify-between gap-4 p-3 rounded-lg border"),
+							html.Div(
+								html.A(
+									attr.Href(fmt.Sprintf("%s/%d", base, application.ID)),
+									attr.Class("font-medium hover:underline"),
+									html.Text(application.Name),
+								),
+								html.P(
+									attr.Class("text-xs text-muted-foreground font-mono"),
+									html.Text(application.ClientID),
+								),
+							),
+							html.A(
+								attr.Href(fmt.Sprintf("%s/%d", base, application.ID)),
+								attr.Class("btn-outline"),
+								html.Text("Edit"),
+							),
+						)
+					}),
+				),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("No applications registered yet."),
+				),
+			),
+		}),
+		html.If(data.Organization == nil, html.Div(
+			attr.Id("authorized"),
+			ui.Card(ui.CardProps{
+				Title:       "Authorized applications",
+				Description: "Applications you gave access to your account",
+				Content: html.IfElse(len(data.Authorized) > 0,
+					html.Div(
+						attr.Class("space-y-2"),
+						html.For(data.Authorized, func(application *models.OAuthApplication) html.Node {
+							return html.Div(
+								attr.Class("flex items-center justify-between gap-4 p-3 rounded-lg border"),
+								html.Div(
+									html.P(attr.Class("font-medium"), html.Text(application.Name)),
+									html.If(application.HomepageURL != "", html.P(
+										attr.Class("text-xs text-muted-foreground"),
+										html.Text(application.HomepageURL),
+									)),
+								),
+								html.Form(
+									attr.Method("POST"),
+									attr.Action(fmt.Sprintf("/settings/applications/authorized/%d/revoke", application.ID)),
+									ui.CSRFField(r),
+									ui.Button(ui.ButtonProps{
+										Variant: ui.ButtonDestructive,
+										Type:    "submit",
+									}, html.Text("Revoke")),
+								),
+							)
+						}),
+					),
+					html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.Text("You haven't authorized any applications."),
+					),
+				),
+			}),
+		)),
+	)
+}
+
+type OAuthApplicationFormData struct {
+	Organization *models.Organization
+	// Application is nil when registering a new one
+	Application  *models.OAuthApplication
+	Name         string
+	HomepageURL  string
+	RedirectURIs string // one per line
+	Confidential bool
+	// NewClientSecret is shown once after it was generated
+	NewClientSecret string
+	Success         string
+	Error           string
+}
+
+func OAuthApplicationForm(r *http.Request, data *OAuthApplicationFormData) html.Node {
+	base := oauthApplicationsBase(data.Organization)
+	action := base + "/new"
+	title := "New OAuth application"
+	if data.Application != nil {
+		action = fmt.Sprintf("%s/%d", base, data.Application.ID)
+		title = data.Application.Name
+	}
+
+	return oauthApplicationsLayout(r, data.Organization, title,
+		html.Div(
+			attr.Class("flex flex-wrap items-center justify-between gap-4"),
+			html.H1(
+				attr.Class("font-semibold text-2xl"),
+				html.Text(title),
+			),
+			html.A(
+				attr.Href(base),
+				attr.Class("btn-outline"),
+				html.Text("All applications"),
+			),
+		),
+		html.If(data.Success != "", html.Div(
+			attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+			html.Text(data.Success),
+		)),
+		html.If(data.Application != nil, oauthApplicationCredentials(r, action, data)),
+		ui.Card(ui.CardProps{
+			Title:       "Application details",
+			Description: "Shown to people when the application asks for access",
+			Content: html.Form(
+				attr.Method("POST"),
+				attr.Action(action),
+				attr.Class("space-y-4"),
+				ui.CSRFField(r),
+				html.If(data.Error != "", html.Div(
+					attr.Class("p-3 rounded-lg bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 text-red-800 dark:text-red-200 text-sm"),
+					html.Text(data.Error),
+				)),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "Application name",
+					Id:          "name",
+					Name:        "name",
+					Type:        "text",
+					Placeholder: "My Application",
+					Icon:        ui.IconLayoutGrid,
+					Required:    true,
+					Value:       data.Name,
+				}),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "Homepage URL",
+					Id:          "homepage_url",
+					Name:        "homepage_url",
+					Type:        "url",
+					Placeholder: "https://example.com",
+					Icon:        ui.IconGlobe,
+					Value:       data.HomepageURL,
+				}),
+				html.Div(
+					attr.Class("space-y-2"),
+					html.Label(
+						attr.For("redirect_uris"),
+						attr.Class("label"),
+						html.Text("Redirect URIs"),
+					),
+					html.Textarea(
+						attr.Id("redirect_uris"),
+						attr.Name("redirect_uris"),
+						attr.Required(),
+						attr.Placeholder("https://example.com/oauth/callback"),
+						attr.Class("textarea min-h-[90px] w-full font-mono text-sm"),
+						html.Text(data.RedirectURIs),
+					),
+					html.P(
+						attr.Class("text-xs text-muted-foreground"),
+						html.Text("One per line. Use https, http://127.0.0.1 for apps on the user's computer, or a scheme like com.example.app for mobile apps."),
+					),
+				),
+				html.IfElse(data.Application == nil,
+					html.Label(
+						attr.For("confidential"),
+						attr.Class("flex items-start gap-2 text-sm"),
+						html.Input(
+							attr.Type("checkbox"),
+							attr.Id("confidential"),
+							attr.Name("confidential"),
+							attr.Class("input mt-0.5"),
+							html.If(data.Confidential, attr.Checked()),
+						),
+						html.Span(
+							html.Span(attr.Class("font-medium"), html.Text("Confidential")),
+							html.Span(attr.Class("text-muted-foreground"), html.Text(" - the application runs on a server and can keep a client secret. Leave it unchecked for mobile, desktop and browser apps.")),
+						),
+					),
+					html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.IfElse(data.Confidential,
+							html.Text("This is a confidential application, it authenticates with its client secret."),
+							html.Text("This is a public application, it has no client secret and authenticates with PKCE only."),
+						),
+					),
+				),
+				html.Div(
+					attr.Class("flex justify-end"),
+					ui.Button(ui.ButtonProps{
+						Variant: ui.ButtonPrimary,
+						Type:    "submit",
+					}, html.IfElse(data.Application == nil, html.Text("Register application"), html.Text("Save changes"))),
+				),
+			),
+		}),
+		html.If(data.Application != nil, ui.Card(ui.CardProps{
+			Title:       "Delete application",
+			Description: "Revokes every token issued to the application. This can't be undone.",
+			Content: html.Form(
+				attr.Method("POST"),
+				attr.Action(action+"/delete"),
+				attr.Class("flex justify-end"),
+				attr.Attribute{Key: "data-confirm", Value: "Are you sure you want to delete this application? Everyone who authorized it loses access."},
+				ui.CSRFField(r),
+				ui.Button(ui.ButtonProps{
+					Variant: ui.ButtonDestructive,
+					Type:    "submit",
+				}, html.Text("Delete application")),
+			),
+		})),
+		html.Element("script",
+			html.Text(`
+				(function() {
+					document.querySelectorAll('[data-confirm]').forEach(function(form) {
+						form.addEventListener('submit', function(e) {
+							if (!confirm(form.getAttribute('data-confirm'))) {
+								e.preventDefault();
+							}
+						});
+					});
+				})();
+			`),
+		),
+	)
+}
+
+func oauthApplicationCredentials(r *http.Request, action string, data *OAuthApplicationFormData) html.Node {
+	if data.Application == nil {
+		return html.Group()
+	}
+
+	return ui.Card(ui.CardProps{
+		Title:       "Credentials",
+		Description: "Your application identifies itself with these when it requests tokens",
+		Content: html.Div(
+			attr.Class("space-y-4"),
+			html.Div(
+				attr.Class("space-y-2"),
+				html.Label(attr.For("client_id"), attr.Class("label"), html.Text("Client ID")),
+				html.Input(
+					attr.Type("text"),
+					attr.Id("client_id"),
+					attr.Readonly(),
+					attr.Value(data.Application.ClientID),
+					attr.Class("input font-mono text-sm w-full"),
+				),
+			),
+			html.If(data.NewClientSecret != "", html.Div(
+				attr.Class("p-4 rounded-lg bg-yellow-50 dark:bg-yellow-900/20 border border-yellow-200 dark:border-yellow-800 space-y-2"),
+				html.P(
+					attr.Class("text-sm font-medium text-yellow-800 dark:text-yellow-200"),
+					html.Text("Make sure to copy the client secret now. You won't be able to see it again!"),
+				),
+				html.Input(
+					attr.Type("text"),
+					attr.Readonly(),
+					attr.Value(data.NewClientSecret),
+					attr.Class("input font-mono text-sm w-full"),
+				),
+			)),
+			html.If(data.Application.Confidential, html.Form(
+				attr.Method("POST"),
+				attr.Action(action+"/reset-secret"),
+				attr.Class("flex items-center justify-between gap-4"),
+				attr.Attribute{Key: "data-confirm", Value: "Reset the client secret? The current one stops working right away."},
+				ui.CSRFField(r),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Lost the client secret, or think it leaked? Generate a new one."),
+				),
+				ui.Button(ui.ButtonProps{
+					Variant: ui.ButtonOutline,
+					Type:    "submit",
+				}, html.Text("Reset secret")),
+			)),
+		),
+	})
+}
+
+func oauthApplicationsBase(org *models.Organization) string {
+	if org != nil {
+		return "/" + org.Username + "/settings/applications"
+	}
+	return "/settings/applications"
+}
+
+// oauthApplicationsLayout shows the pages in the organization's settings or in the user's
+func oauthApplicationsLayout(r *http.Request, org *models.Organization, title string, children ...html.Node) html.Node {
+	if org != nil {
+		return layouts.Profile(r,
+			title+" - "+org.DisplayName+" - Hypercommit",
+			layouts.ProfileLayoutOptions{
+				Username:     org.Username,
+				DisplayName:  org.DisplayName,
+				IsOrg:        true,
+				CurrentTab:   "settings",
+				ShowSettings: true,
+			},
+			html.Main(
+				attr.Class("w-full mx-auto max-w-7xl px-4 py-8 space-y-6"),
+				html.Group(children...),
+			),
+		)
+	}
+
+	return layouts.Main(r,
+		title,
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] w-full mx-auto max-w-7xl space-y-6 py-8 px-4"),
+			html.Group(children...),
+		),
+	)
+}
@@ -0,0 +1,149 @@
+package pages
+
+import (
+	"net/http"
+	"net/url"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// oauthAuthorizeParams are the parameters of the authorization request passed on with the user's decision
+var oauthAuthorizeParams = []string{"response_type", "client_id", "redirect_uri", "scope", "state", "code_challenge", "code_challenge_method"}
+
+type OAuthAuthorizeData struct {
+	User        *models.User
+	Application *models.OAuthApplication
+	// Owner is the username of the user or organization that registered the application
+	Owner       string
+	Scopes      []string
+	RedirectURI string
+	Params      url.Values
+}
+
+func OAuthAuthorize(r *http.Request, data *OAuthAuthorizeData) html.Node {
+	var content html.Node
+
+	if data.User == nil {
+		// Show sign-in prompt
+		content = html.Div(
+			attr.Class("max-w-md mx-auto text-center"),
+			html.Div(
+				attr.Class("mb-6"),
+				ui.SVGIcon(ui.IconLock, "size-12 text-muted-foreground mx-auto"),
+			),
+			html.H2(
+				attr.Class("text-2xl font-semibold mb-3"),
+				html.Text("Sign In Required"),
+			),
+			html.P(
+				attr.Class("text-muted-foreground mb-6"),
+				html.Text("You need to be signed in to authorize "+data.Application.Name+". Come back to this page once you are."),
+			),
+			ui.Button(ui.ButtonProps{
+				Variant: ui.ButtonPrimary,
+			},
+				html.Element("a",
+					attr.Href("/auth/sign-in"),
+					html.Text("Sign In"),
+				),
+			),
+		)
+	} else {
+		hiddenFields := make([]html.Node, 0, len(oauthAuthorizeParams))
+		for _, name := range oauthAuthorizeParams {
+			if value := data.Params.Get(name); value != "" {
+				hiddenFields = append(hiddenFields, html.Input(attr.Type("hidden"), attr.Name(name), attr.Value(value)))
+			}
+		}
+
+		content = html.Div(
+			attr.Class("max-w-md mx-auto"),
+			html.Div(
+				attr.Class("text-center mb-8"),
+				html.H2(
+					attr.Class("text-2xl font-semibold mb-3"),
+					html.Text("Authorize "+data.Application.Name),
+				),
+				html.P(
+					attr.Class("text-muted-foreground"),
+					html.Text("An application by "),
+					html.Span(attr.Class("font-semibold text-foreground"), html.Text("@"+data.Owner)),
+					html.Text(" wants to access your account "),
+					html.Span(attr.Class("font-semibold text-foreground"), html.Text("@"+data.User.Username)),
+				),
+				html.If(data.Application.HomepageURL != "", html.P(
+					attr.Class("text-sm mt-2"),
+					html.A(
+						attr.Href(data.Application.HomepageURL),
+						attr.Class("underline underline-offset-4"),
+						attr.Rel("noopener noreferrer"),
+						attr.Target("_blank"),
+						html.Text(data.Application.HomepageURL),
+					),
+				)),
+			),
+			oauthScopeList(data.Scopes),
+			html.Form(
+				attr.Method("POST"),
+				attr.Action("/oauth/authorize"),
+				attr.Class("flex gap-3 mt-6"),
+				ui.CSRFField(r),
+				html.Group(hiddenFields...),
+				oauthDecisionButtons(),
+			),
+			html.P(
+				attr.Class("text-xs text-muted-foreground text-center mt-4"),
+				html.Text("You'll be sent to "+data.RedirectURI+". You can revoke access at any time in your settings."),
+			),
+		)
+	}
+
+	return layouts.Main(r,
+		"Authorize "+data.Application.Name,
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] flex items-center justify-center px-4 py-8"),
+			content,
+		),
+	)
+}
+
+// oauthScopeList describes what an application gets access to
+func oauthScopeList(scopes []string) html.Node {
+	return html.Div(
+		attr.Class("rounded-lg border divide-y"),
+		html.For(scopes, func(scope string) html.Node {
+			return html.Div(
+				attr.Class("flex items-start gap-3 p-3 text-sm"),
+				ui.SVGIcon(ui.IconCheck, "size-4 mt-0.5 text-emerald-600 flex-shrink-0"),
+				html.Div(
+					html.P(attr.Class("font-medium"), html.Text(accessTokenScopeDescriptions[scope])),
+					html.P(attr.Class("font-mono text-xs text-muted-foreground"), html.Text(scope)),
+				),
+			)
+		}),
+	)
+}
+
+// oauthDecisionButtons submit a consent form with decision set to "deny" or "approve"
+func oauthDecisionButtons() html.Node {
+	return html.Group(
+		html.Element("button",
+			attr.Type("submit"),
+			attr.Name("decision"),
+			attr.Value("deny"),
+			attr.Class("btn-outline flex-1"),
+			html.Text("Deny"),
+		),
+		html.Element("button",
+			attr.Type("submit"),
+			attr.Name("decision"),
+			attr.Value("approve"),
+			attr.Class("btn-primary flex-1"),
+			html.Text("Approve"),
+		),
+	)
+}
@@ -0,0 +1,221 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// OrganizationMemberData is a member of an organization as listed in its settings
+type OrganizationMemberData struct {
+	User             *models.User
+	Role             string
+	TwoFactorEnabled bool
+}
+
+type OrganizationSettingsData struct {
+	Organization *models.Organization
+	Members      []OrganizationMemberData
+	// UserTwoFactorEnabled is whether the signed in owner uses two-factor authentication,
+	// they can't require it from others otherwise
+	UserTwoFactorEnabled bool
+	Success              string
+	Error                string
+}
+
+func OrganizationSettings(r *http.Request, data *OrganizationSettingsData) html.Node {
+	if data == nil || data.Organization == nil {
+		data = &OrganizationSettingsData{Organization: &models.Organization{}}
+	}
+
+	base := "/" + data.Organization.Username + "/settings"
+
+	return layouts.Profile(r,
+		data.Organization.DisplayName+" settings - Hypercommit",
+		layouts.ProfileLayoutOptions{
+			Username:     data.Organization.Username,
+			DisplayName:  data.Organization.DisplayName,
+			IsOrg:        true,
+			CurrentTab:   "settings",
+			ShowSettings: true,
+		},
+		html.Main(
+			attr.Class("w-full mx-auto max-w-7xl px-4 py-8 space-y-6"),
+			html.Div(
+				attr.Class("flex flex-wrap items-center justify-between gap-4"),
+				html.H1(
+					attr.Class("font-semibold text-2xl"),
+					html.Text("Organization settings"),
+				),
+				html.Div(
+					attr.Class("flex gap-2"),
+					html.A(
+						attr.Href(base+"/applications"),
+						attr.Class("btn-outline"),
+						html.Text("OAuth applications"),
+					),
+					html.A(
+						attr.Href(base+"/secrets"),
+						attr.Class("btn-outline"),
+						html.Text("Secrets"),
+					),
+					html.A(
+						attr.Href(base+"/hooks"),
+						attr.Class("btn-outline"),
+						html.Text("Webhooks"),
+					),
+					html.A(
+						attr.Href(base+"/audit-log"),
+						attr.Class("btn-outline"),
+						html.Text("Audit log"),
+					),
+				),
+			),
+			html.If(data.Success != "", html.Div(
+				attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+				html.Text(data.Success),
+			)),
+			html.If(data.Error != "", html.Div(
+				attr.Class("p-3 rounded-lg bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 text-red-800 dark:text-red-200 text-sm"),
+				html.Text(data.Error),
+			)),
+
+			// Security Card
+			ui.Card(ui.CardProps{
+				Title:       "Security",
+				Description: "Requirements for members and collaborators of the organization's repositories",
+				Content: html.Form(
+					attr.Method("POST"),
+					attr.Action(base),
+					attr.Class("space-y-4"),
+					ui.CSRFField(r),
+					html.Label(
+						attr.For("require_two_factor"),
+						attr.Class("flex items-start gap-2 text-sm"),
+						html.Input(
+							attr.Type("checkbox"),
+							attr.Id("require_two_factor"),
+							attr.Name("require_two_factor"),
+							attr.Value("1"),
+							attr.Class("input mt-0.5"),
+							html.If(data.Organization.RequireTwoFactor, attr.Checked()),
+							html.If(!data.UserTwoFactorEnabled && !data.Organization.RequireTwoFactor, attr.Disabled()),
+						),
+						html.Span(
+							html.Text("Require two-factor authentication for everyone in the organization"),
+						),
+					),
+					html.P(
+						attr.Class("text-sm text-muted-foreground"),
+						html.IfElse(data.UserTwoFactorEnabled || data.Organization.RequireTwoFactor,
+							html.Text("Members and collaborators who don't use two-factor authentication are removed when you turn this on."),
+							html.Text("Enable two-factor authentication for your own account before requiring it from others."),
+						),
+					),
+					html.Div(
+						attr.Class("flex justify-end"),
+						ui.Button(
+							ui.ButtonProps{
+								Variant: ui.ButtonPrimary,
+								Type:    "submit",
+							},
+							html.Text("Save"),
+						),
+					),
+				),
+			}),
+
+			// Members Card
+			ui.Card(ui.CardProps{
+				Title:       "Members",
+				Description: "Owners can change the organization's settings",
+				Content: html.Div(
+					attr.Class("space-y-4"),
+					html.Div(
+						attr.Class("space-y-2"),
+						html.For(data.Members, func(member OrganizationMemberData) html.Node {
+							return organizationMemberItem(r, base, member)
+						}),
+					),
+					html.Form(
+						attr.Method("POST"),
+						attr.Action(base+"/members"),
+						attr.Class("grid grid-cols-1 sm:grid-cols-[1fr_auto_auto] gap-4 items-end pt-4 border-t"),
+						ui.CSRFField(r),
+						ui.FormField(ui.FormFieldProps{
+							Label:       "Add Member",
+							Id:          "member-username",
+							Name:        "username",
+							Type:        "text",
+							Placeholder: "Username",
+							Icon:        ui.IconUser,
+							Required:    true,
+						}),
+						ui.Select(ui.SelectProps{
+							Id:       "member-role",
+							Name:     "role",
+							Label:    "Role",
+							Required: true,
+							Class:    "sm:w-full !mb-0",
+							Options: []ui.SelectOption{
+								{Value: models.OrganizationRoleMember, Label: "Member", Selected: true, Icon: ui.IconUser},
+								{Value: models.OrganizationRoleOwner, Label: "Owner", Icon: ui.IconShield},
+							},
+						}),
+						ui.Button(
+							ui.ButtonProps{
+								Variant: ui.ButtonPrimary,
+								Type:    "submit",
+							},
+							html.Text("Add"),
+						),
+					),
+				),
+			}),
+		),
+	)
+}
+
+func organizationMemberItem(r *http.Request, base string, member OrganizationMemberData) html.Node {
+	return html.Div(
+		attr.Class("flex items-center justify-between gap-2 p-3 bg-muted rounded-lg"),
+		html.Div(
+			attr.Class("flex flex-wrap items-center gap-2 min-w-0"),
+			html.A(
+				attr.Href("/"+member.User.Username),
+				attr.Class("font-medium text-sm text-foreground truncate hover:underline"),
+				html.Text(member.User.DisplayName),
+			),
+			html.Span(
+				attr.Class("text-sm text-muted-foreground"),
+				html.Text("@"+member.User.Username),
+			),
+			html.IfElse(member.Role == models.OrganizationRoleOwner,
+				ui.Badge(ui.BadgeProps{Variant: ui.BadgePrimary}, html.Text("Owner")),
+				ui.Badge(ui.BadgeProps{Variant: ui.BadgeSecondary}, html.Text("Member")),
+			),
+			html.IfElse(member.TwoFactorEnabled,
+				ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text("2FA")),
+				ui.Badge(ui.BadgeProps{Variant: ui.BadgeDestructive}, html.Text("No 2FA")),
+			),
+		),
+		html.Form(
+			attr.Method("POST"),
+			attr.Action(fmt.Sprintf("%s/members/%d/remove", base, member.User.ID)),
+			attr.Class("inline shrink-0"),
+			ui.CSRFField(r),
+			html.Element("button",
+				attr.Type("submit"),
+				attr.Class("btn-icon-ghost text-destructive hover:text-destructive"),
+				attr.DataTooltip("Remove member"),
+				attr.DataSide("left"),
+				ui.SVGIcon(ui.IconTrash, "text-destructive"),
+			),
+		),
+	)
+}
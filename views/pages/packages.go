@@ -0,0 +1,363 @@
+package pages
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/services"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// PackagesProfile is what the profile layout shows of the user or organization owning packages
+type PackagesProfile struct {
+	Username     string
+	DisplayName  string
+	IsOrg        bool
+	ShowSettings bool
+}
+
+// PackageListItem is a package with the repository it is linked to, if any
+type PackageListItem struct {
+	Package    *models.Package
+	Repository *models.Repository
+}
+
+type OwnerPackagesData struct {
+	User     *models.User
+	Profile  PackagesProfile
+	Packages []PackageListItem
+	Success  string
+}
+
+type ShowPackageData struct {
+	User       *models.User
+	Profile    PackagesProfile
+	Package    *models.Package
+	Repository *models.Repository
+	Versions   []services.PackageVersionFiles
+	// DistTags maps the dist-tags of npm packages to their versions
+	DistTags map[string]string
+	// RegistryURL is the URL of the owner's packages of the type, for clients
+	RegistryURL string
+	CanAdmin    bool
+	// Repositories are those the package can be linked to, for administrators
+	Repositories []*models.Repository
+	Success      string
+}
+
+func OwnerPackages(r *http.Request, data *OwnerPackagesData) html.Node {
+	return packagesLayout(r, data.Profile, data.Profile.DisplayName+" - Packages - Hypercommit",
+		html.If(data.Success != "", packagesNotice(data.Success)),
+		html.IfElse(len(data.Packages) > 0,
+			html.Div(
+				attr.Class("space-y-4"),
+				html.H2(
+					attr.Class("text-xl font-medium"),
+					html.Text("Packages"),
+				),
+				html.Div(
+					attr.Class("grid grid-cols-1 md:grid-cols-2 lg:grid-cols-3 gap-4"),
+					html.For(data.Packages, func(item PackageListItem) html.Node {
+						return packageCard(data.Profile.Username, item)
+					}),
+				),
+			),
+			ui.EmptyState(ui.EmptyStateProps{
+				Icon:        ui.SVGIcon(ui.IconPackage, "size-6"),
+				Title:       "No packages yet",
+				Description: "Packages published with npm or the raw file API show up here.",
+				ShowAction:  false,
+			}),
+		),
+	)
+}
+
+func packageCard(owner string, item PackageListItem) html.Node {
+	description := "Not linked to a repository"
+	if item.Repository != nil {
+		description = "Linked to " + owner + "/" + item.Repository.Name
+	}
+
+	return html.A(
+		attr.Href(packageURL(owner, item.Package)),
+		attr.Class("card hover:opacity-70 transition-opacity"),
+		html.Element("header",
+			attr.Class("flex flex-col flex-wrap gap-4"),
+			html.Div(
+				attr.Class("flex items-center justify-between gap-2"),
+				html.Div(
+					attr.Class("flex items-center gap-2"),
+					html.Element("span",
+						attr.Class("badge-outline"),
+						html.Text(packageVisibility(item.Repository)),
+					),
+					html.Element("span",
+						attr.Class("badge-secondary"),
+						html.Text(item.Package.Type),
+					),
+				),
+				html.Span(
+					attr.Class("text-muted-foreground text-sm"),
+					html.Text("Updated "+formatTime(item.Package.UpdatedAt)),
+				),
+			),
+			html.H2(
+				attr.Class("break-all"),
+				html.Text(item.Package.Name),
+			),
+			html.P(
+				attr.Class("text-sm text-muted-foreground"),
+				html.Text(description),
+			),
+		),
+	)
+}
+
+func ShowPackage(r *http.Request, data *ShowPackageData) html.Node {
+	pkg := data.Package
+	base := packageURL(data.Profile.Username, pkg)
+
+	return packagesLayout(r, data.Profile, pkg.Name+" - Packages - Hypercommit",
+		html.If(data.Success != "", packagesNotice(data.Success)),
+		html.Div(
+			attr.Class("space-y-2"),
+			html.Div(
+				attr.Class("flex flex-wrap items-center gap-3"),
+				ui.SVGIcon(ui.IconPackage, "size-6"),
+				html.H2(
+					attr.Class("text-2xl font-semibold break-all"),
+					html.Text(pkg.Name),
+				),
+				html.Element("span",
+					attr.Class("badge-outline"),
+					html.Text(packageVisibility(data.Repository)),
+				),
+				html.Element("span",
+					attr.Class("badge-secondary"),
+					html.Text(pkg.Type),
+				),
+			),
+			html.IfElse(data.Repository != nil,
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Linked to "),
+					html.A(
+						attr.Href("/"+data.Profile.Username+"/"+repositoryName(data.Repository)),
+						attr.Class("text-primary hover:underline"),
+						html.Text(data.Profile.Username+"/"+repositoryName(data.Repository)),
+					),
+					html.Text(", whose visibility and permissions the package has."),
+				),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Not linked to a repository, only its owner can see it."),
+				),
+			),
+		),
+		ui.Card(ui.CardProps{
+			Title:       "Installation",
+			Description: "Sign in with an access token with the repo:read scope for private packages, and the repo:write scope to publish.",
+			Content: html.Element("pre",
+				attr.Class("bg-muted rounded-md p-3 text-xs font-mono overflow-x-auto"),
+				html.Text(packageInstructions(data)),
+			),
+		}),
+		html.If(len(data.DistTags) > 0, ui.Card(ui.CardProps{
+			Title:       "Dist-tags",
+			Description: "npm install " + pkg.Name + "@<tag> installs the version of a tag",
+			Content: html.Div(
+				attr.Class("flex flex-wrap gap-2"),
+				html.For(slices.Sorted(maps.Keys(data.DistTags)), func(tag string) html.Node {
+					return ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text(tag+": "+data.DistTags[tag]))
+				}),
+			),
+		})),
+		ui.Card(ui.CardProps{
+			Title:       "Versions",
+			Description: "Most recent first",
+			Content: html.IfElse(len(data.Versions) > 0,
+				html.Div(
+					attr.Class("space-y-2"),
+					html.For(data.Versions, func(version services.PackageVersionFiles) html.Node {
+						return packageVersion(data, version)
+					}),
+				),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("No versions yet."),
+				),
+			),
+		}),
+		html.If(data.CanAdmin, ui.Card(ui.CardProps{
+			Title:       "Linked repository",
+			Description: "The package has the visibility and permissions of its repository. Unlinked packages are private to their owner.",
+			Content: html.Form(
+				attr.Method("POST"),
+				attr.Action(base+"/repository"),
+				attr.Class("flex flex-wrap items-end gap-2"),
+				ui.CSRFField(r),
+				ui.Select(ui.SelectProps{
+					Id:      "repository",
+					Name:    "repository",
+					Label:   "Repository",
+					Class:   "!mb-0 w-64",
+					Options: packageRepositoryOptions(data),
+				}),
+				ui.Button(ui.ButtonProps{
+					Variant: ui.ButtonOutline,
+					Type:    "submit",
+				}, html.Text("Save")),
+			),
+		})),
+		html.If(data.CanAdmin, ui.Card(ui.CardProps{
+			Title:       "Delete package",
+			Description: "Deletes every version and file of the package. Clients installing it will fail.",
+			Content: html.Form(
+				attr.Method("POST"),
+				attr.Action(base+"/delete"),
+				attr.Attribute{Key: "data-confirm", Value: "Are you sure you want to delete the package " + pkg.Name + "?"},
+				ui.CSRFField(r),
+				ui.Button(ui.ButtonProps{
+					Variant: ui.ButtonDestructive,
+					Type:    "submit",
+				}, html.Text("Delete package")),
+			),
+		})),
+	)
+}
+
+func packageVersion(data *ShowPackageData, version services.PackageVersionFiles) html.Node {
+	return html.Div(
+		attr.Class("p-3 rounded-lg border space-y-2"),
+		html.Div(
+			attr.Class("flex items-center justify-between gap-4"),
+			html.P(
+				attr.Class("font-medium font-mono text-sm break-all"),
+				html.Text(version.Version.Version),
+			),
+			html.P(
+				attr.Class("text-xs text-muted-foreground"),
+				html.Text("Published "+formatTime(version.Version.CreatedAt)),
+			),
+		),
+		html.For(version.Files, func(file *models.PackageFile) html.Node {
+			return html.Div(
+				attr.Class("flex items-center justify-between gap-4 text-sm"),
+				html.A(
+					attr.Href(packageFileURL(data, version.Version.Version, file)),
+					attr.Class("text-primary hover:underline font-mono break-all"),
+					html.Text(file.Name),
+				),
+				html.Span(
+					attr.Class("text-xs text-muted-foreground font-mono"),
+					attr.Attribute{Key: "title", Value: "sha256:" + file.SHA256},
+					html.Text(formatBytes(file.Size)+" · sha256:"+file.SHA256[:12]),
+				),
+			)
+		}),
+	)
+}
+
+// packageInstructions tells how to install the package, and to authenticate for private ones
+func packageInstructions(data *ShowPackageData) string {
+	pkg := data.Package
+	registry := data.RegistryURL + "/"
+	authority := strings.TrimPrefix(strings.TrimPrefix(registry, "https:"), "http:")
+
+	if pkg.Type == models.PackageTypeNPM {
+		var b strings.Builder
+		b.WriteString("# .npmrc\n")
+		if scope, _, scoped := strings.Cut(pkg.Name, "/"); scoped {
+			b.WriteString(scope + ":registry=" + registry + "\n")
+		}
+		b.WriteString(authority + ":_authToken=<access token>\n\n")
+		if strings.HasPrefix(pkg.Name, "@") {
+			b.WriteString("npm install " + pkg.Name)
+		} else {
+			b.WriteString("npm install " + pkg.Name + " --registry=" + registry)
+		}
+		return b.String()
+	}
+
+	version, file := "<version>", "<file>"
+	if len(data.Versions) > 0 && len(data.Versions[0].Files) > 0 {
+		version, file = data.Versions[0].Version.Version, data.Versions[0].Files[0].Name
+	}
+	url := registry + pkg.Name + "/" + version + "/" + file
+	return "# Download a file\n" +
+		"curl -fLO -H \"Authorization: Bearer <access token>\" " + url + "\n\n" +
+		"# Upload a file\n" +
+		"curl -f -H \"Authorization: Bearer <access token>\" --upload-file " + file + " " + url
+}
+
+func packageRepositoryOptions(data *ShowPackageData) []ui.SelectOption {
+	options := []ui.SelectOption{{Value: "", Label: "Not linked", Selected: data.Repository == nil}}
+	for _, repo := range data.Repositories {
+		options = append(options, ui.SelectOption{
+			Value:    repo.Name,
+			Label:    repo.Name,
+			Selected: data.Repository != nil && data.Repository.ID == repo.ID,
+		})
+	}
+	return options
+}
+
+// packageFileURL downloads a file, with the session of the signed in user
+func packageFileURL(data *ShowPackageData, version string, file *models.PackageFile) string {
+	registry := "/api/packages/" + data.Profile.Username + "/" + data.Package.Type + "/" + data.Package.Name
+	if data.Package.Type == models.PackageTypeNPM {
+		return registry + "/-/" + file.Name
+	}
+	return registry + "/" + version + "/" + file.Name
+}
+
+func packageURL(owner string, pkg *models.Package) string {
+	return "/" + owner + "/packages/" + pkg.Type + "/" + pkg.Name
+}
+
+func packageVisibility(repo *models.Repository) string {
+	if repo != nil && repo.Visibility == "public" {
+		return "Public"
+	}
+	return "Private"
+}
+
+func repositoryName(repo *models.Repository) string {
+	if repo == nil {
+		return ""
+	}
+	return repo.Name
+}
+
+func packagesNotice(message string) html.Node {
+	return html.Div(
+		attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+		html.Text(message),
+	)
+}
+
+// packagesLayout renders the packages tab of a profile
+func packagesLayout(r *http.Request, profile PackagesProfile, title string, children ...html.Node) html.Node {
+	return layouts.Profile(r,
+		title,
+		layouts.ProfileLayoutOptions{
+			Username:     profile.Username,
+			DisplayName:  profile.DisplayName,
+			IsOrg:        profile.IsOrg,
+			CurrentTab:   "packages",
+			ShowSettings: profile.ShowSettings,
+		},
+		html.Main(
+			attr.Class("w-full mx-auto max-w-7xl px-4 py-8"),
+			html.Div(
+				append([]html.Node{attr.Class("space-y-6")}, children...)...,
+			),
+		),
+	)
+}
@@ -0,0 +1,36 @@
+package pages
+
+import (
+	"net/http"
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// passkeyForm is a form that runs a WebAuthn ceremony with /passkeys.js before it is submitted.
+// ceremony is "register" or "authenticate", optionsURL returns the options for the browser.
+func passkeyForm(r *http.Request, action, optionsURL, ceremony, class string, children ...html.Node) html.Node {
+	return html.Group(
+		html.Form(
+			attr.Method("POST"),
+			attr.Action(action),
+			attr.Class(class),
+			attr.Attribute{Key: "data-passkey", Value: ceremony},
+			attr.Attribute{Key: "data-passkey-options", Value: optionsURL},
+			ui.CSRFField(r),
+			html.Input(
+				attr.Type("hidden"),
+				attr.Name("credential"),
+			),
+			html.Group(children...),
+			html.P(
+				attr.Class("hidden text-sm text-destructive"),
+				attr.Attribute{Key: "data-passkey-error", Value: ""},
+			),
+		),
+		html.Script(
+			attr.Src("/passkeys.js"),
+			attr.Defer(),
+		),
+	)
+}
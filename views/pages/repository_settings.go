@@ -73,9 +73,25 @@ func RepositorySettings(r *http.Request, data *RepositorySettingsData) html.Node
 		},
 		html.Main(
 			attr.Class("w-full mx-auto max-w-7xl space-y-6 py-8 px-4"),
-			html.H1(
-				attr.Class("font-semibold text-2xl mb-6"),
-				html.Text("Repository Settings"),
+			html.Div(
+				attr.Class("flex flex-wrap items-center justify-between gap-4 mb-6"),
+				html.H1(
+					attr.Class("font-semibold text-2xl"),
+					html.Text("Repository Settings"),
+				),
+				html.Div(
+					attr.Class("flex gap-2"),
+					html.A(
+						attr.Href("/"+data.OwnerUsername+"/"+data.Repository.Name+"/settings/secrets"),
+						attr.Class("btn-outline"),
+						html.Text("Secrets"),
+					),
+					html.A(
+						attr.Href("/"+data.OwnerUsername+"/"+data.Repository.Name+"/settings/hooks"),
+						attr.Class("btn-outline"),
+						html.Text("Webhooks"),
+					),
+				),
 			),
 
 			// General Settings Card
@@ -94,6 +110,7 @@ func RepositorySettings(r *http.Request, data *RepositorySettingsData) html.Node
 						attr.Action("/"+data.OwnerUsername+"/"+data.Repository.Name+"/settings/general"),
 						attr.Class("space-y-4"),
 						attr.Attribute{Key: "data-original-name", Value: data.Repository.Name},
+						ui.CSRFField(r),
 						ui.FormField(ui.FormFieldProps{
 							Label:       "Repository Name",
 							Id:          "name",
@@ -246,6 +263,7 @@ func RepositorySettings(r *http.Request, data *RepositorySettingsData) html.Node
 										attr.Method("POST"),
 										attr.Action("/"+data.OwnerUsername+"/"+data.Repository.Name+"/settings/collaborators/update"),
 										attr.Class("flex items-center gap-2"),
+										ui.CSRFField(r),
 										html.Input(
 											attr.Type("hidden"),
 											attr.Name("user_id"),
@@ -273,6 +291,7 @@ func RepositorySettings(r *http.Request, data *RepositorySettingsData) html.Node
 									html.Form(
 										attr.Method("POST"),
 										attr.Action("/"+data.OwnerUsername+"/"+data.Repository.Name+"/settings/collaborators/remove"),
+										ui.CSRFField(r),
 										html.Input(
 											attr.Type("hidden"),
 											attr.Name("user_id"),
@@ -305,6 +324,7 @@ func RepositorySettings(r *http.Request, data *RepositorySettingsData) html.Node
 							attr.Method("POST"),
 							attr.Action("/"+data.OwnerUsername+"/"+data.Repository.Name+"/settings/collaborators/add"),
 							attr.Class("space-y-4"),
+							ui.CSRFField(r),
 							html.Div(
 								attr.Class("grid grid-cols-1 sm:grid-cols-[1fr_auto_auto] gap-4 justify-end items-end"),
 								ui.FormField(ui.FormFieldProps{
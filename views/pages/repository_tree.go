@@ -25,6 +25,11 @@ type RepositoryTreeData struct {
 	CurrentPath   string
 	Entries       []services.TreeEntry
 	IsEmpty       bool
+	// HeadCommit is the latest commit of the branch, nil if it couldn't be read
+	HeadCommit *services.Commit
+	// HeadCommitAuthor is the user who verified the author email of HeadCommit, if any
+	HeadCommitAuthor *models.User
+	HeadStatus       *services.CombinedStatus
 }
 
 func RepositoryTree(r *http.Request, data *RepositoryTreeData) html.Node {
@@ -89,6 +94,8 @@ func renderTreeContent(data *RepositoryTreeData) html.Node {
 		branchSelector,
 		// Breadcrumb
 		breadcrumb,
+		// Latest commit
+		renderHeadCommit(data),
 		// File list
 		fileList,
 	)
@@ -156,6 +163,81 @@ func renderBranchSelector(data *RepositoryTreeData) html.Node {
 	)
 }
 
+func renderHeadCommit(data *RepositoryTreeData) html.Node {
+	if data.HeadCommit == nil {
+		return html.Div()
+	}
+
+	commit := data.HeadCommit
+	subject, _, _ := strings.Cut(commit.Message, "\n")
+
+	return html.Div(
+		attr.Class("border rounded-sm bg-card px-3 py-2 flex items-center gap-3 text-sm"),
+		html.IfElse(data.HeadCommitAuthor != nil,
+			html.A(
+				attr.Href("/"+headCommitAuthorUsername(data)),
+				attr.Class("font-medium shrink-0 hover:underline"),
+				html.Text(commit.AuthorName),
+			),
+			html.Span(
+				attr.Class("font-medium shrink-0"),
+				html.Text(commit.AuthorName),
+			),
+		),
+		html.Span(
+			attr.Class("text-muted-foreground truncate"),
+			html.Text(subject),
+		),
+		commitStatusIcon(data.HeadStatus),
+		html.Span(
+			attr.Class("ml-auto shrink-0 font-mono text-xs text-muted-foreground"),
+			html.Text(commit.ID[:min(7, len(commit.ID))]),
+		),
+		html.Span(
+			attr.Class("shrink-0 text-xs text-muted-foreground"),
+			html.Text(formatTime(commit.Timestamp)),
+		),
+	)
+}
+
+// headCommitAuthorUsername returns the username of the author of the head commit, empty if unknown
+func headCommitAuthorUsername(data *RepositoryTreeData) string {
+	if data.HeadCommitAuthor == nil {
+		return ""
+	}
+	return data.HeadCommitAuthor.Username
+}
+
+// commitStatusIcon shows the combined status of a commit, nothing if no status was reported for it
+func commitStatusIcon(status *services.CombinedStatus) html.Node {
+	if status == nil || status.State == "" {
+		return html.Group()
+	}
+
+	succeeded := 0
+	for _, s := range status.Statuses {
+		if s.State == models.CommitStateSuccess {
+			succeeded++
+		}
+	}
+	summary := fmt.Sprintf("%d of %d checks succeeded", succeeded, len(status.Statuses))
+
+	icon, class := ui.IconCircle, "text-amber-500"
+	switch status.State {
+	case models.CommitStateSuccess:
+		icon, class = ui.IconCheck, "text-emerald-600"
+	case models.CommitStateFailure:
+		icon, class = ui.IconX, "text-red-600"
+	}
+
+	return html.Span(
+		attr.Class("inline-flex shrink-0 "+class),
+		attr.Attribute{Key: "data-tooltip", Value: summary},
+		attr.Attribute{Key: "aria-label", Value: summary},
+		ui.SVGIcon(icon, "size-4"),
+	)
+}
+
 func renderPathBreadcrumb(data *RepositoryTreeData) html.Node {
 	if data.CurrentPath == "" {
 		return html.Div()
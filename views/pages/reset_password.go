@@ -0,0 +1,103 @@
+package pages
+
+import (
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type ResetPasswordData struct {
+	Token                string
+	InvalidToken         bool
+	PasswordError        string
+	ConfirmPasswordError string
+	RevokeAccessTokens   bool
+}
+
+func ResetPassword(r *http.Request, data *ResetPasswordData) html.Node {
+	if data == nil {
+		data = &ResetPasswordData{}
+	}
+
+	return layouts.Main(r,
+		"Reset password",
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] flex flex-col items-center justify-center w-full mx-auto max-w-xs space-y-6 py-6 px-4 sm:px-0"),
+			html.H2(
+				attr.Class("font-medium text-xl text-center"),
+				html.Text("Choose a new password"),
+			),
+			html.If(data.InvalidToken, html.Div(
+				attr.Class("w-full space-y-4"),
+				ui.Alert(ui.AlertProps{
+					Variant:     ui.AlertDestructive,
+					Icon:        ui.SVGIcon(ui.IconAlertCircle, "h-4 w-4"),
+					Title:       "Invalid link",
+					Description: "This password reset link is invalid, has expired or was already used.",
+				}),
+				html.A(
+					attr.Href("/forgot-password"),
+					attr.Class("btn-primary w-full"),
+					html.Text("Request a new link"),
+				),
+			)),
+			html.If(!data.InvalidToken, html.Form(
+				attr.Method("POST"),
+				attr.Action("/reset-password"),
+				attr.Class("w-full space-y-4"),
+				ui.CSRFField(r),
+				html.Input(
+					attr.Type("hidden"),
+					attr.Name("token"),
+					attr.Value(data.Token),
+				),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "New Password",
+					Id:          "password",
+					Name:        "password",
+					Type:        "password",
+					Placeholder: "••••••••••••••••",
+					Icon:        ui.IconLock,
+					Required:    true,
+					Error:       data.PasswordError,
+				}),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "Confirm Password",
+					Id:          "confirm_password",
+					Name:        "confirm_password",
+					Type:        "password",
+					Placeholder: "••••••••••••••••",
+					Icon:        ui.IconLock,
+					Required:    true,
+					Error:       data.ConfirmPasswordError,
+				}),
+				html.Label(
+					attr.For("revoke_access_tokens"),
+					attr.Class("flex items-start gap-2 text-sm"),
+					html.Input(
+						attr.Type("checkbox"),
+						attr.Id("revoke_access_tokens"),
+						attr.Name("revoke_access_tokens"),
+						attr.Value("1"),
+						attr.Class("input mt-0.5"),
+						html.If(data.RevokeAccessTokens, attr.Checked()),
+					),
+					html.Span(
+						html.Text("Also revoke all personal access tokens. You will be signed out of all sessions either way."),
+					),
+				),
+				ui.Button(
+					ui.ButtonProps{
+						Variant: ui.ButtonPrimary,
+						Type:    "submit",
+						Class:   "w-full",
+					},
+					html.Text("Reset password"),
+				),
+			)),
+		),
+	)
+}
@@ -0,0 +1,159 @@
+package pages
+
+import (
+	"net/http"
+	"strconv"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// SecretScope is the repository or the organization whose secrets are shown. It has the fields of
+// WebhookScope, whose layout the pages share.
+type SecretScope struct {
+	// Organization is set for the secrets of an organization
+	Organization *models.Organization
+	// Repository is set for the secrets of a repository, along with what its layout shows
+	Repository    *models.Repository
+	OwnerUsername string
+	StarCount     int64
+	HasStarred    bool
+}
+
+// Base is the URL of the list of secrets
+func (s SecretScope) Base() string {
+	if s.Repository != nil {
+		return "/" + s.OwnerUsername + "/" + s.Repository.Name + "/settings/secrets"
+	}
+	return "/" + s.Organization.Username + "/settings/secrets"
+}
+
+type SecretsData struct {
+	Scope   SecretScope
+	Secrets []*models.Secret
+	// Inherited are the secrets of the organization owning the repository
+	Inherited []*models.Secret
+	// Name is the name submitted with an error
+	Name    string
+	Error   string
+	Success string
+}
+
+func Secrets(r *http.Request, data *SecretsData) html.Node {
+	description := "Workflow jobs of this repository see secrets as environment variables. Values can't be viewed once saved, and are masked in logs."
+	if data.Scope.Organization != nil {
+		description = "Workflow jobs of every repository of the organization see secrets as environment variables, unless the repository has a secret of the same name. Values can't be viewed once saved, and are masked in logs."
+	}
+
+	return webhooksLayout(r, WebhookScope(data.Scope), "Secrets",
+		html.H1(
+			attr.Class("font-semibold text-2xl"),
+			html.Text("Secrets"),
+		),
+		html.If(data.Success != "", html.Div(
+			attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+			html.Text(data.Success),
+		)),
+		ui.Card(ui.CardProps{
+			Title:       "Secrets",
+			Description: description,
+			Content: html.IfElse(len(data.Secrets) > 0,
+				html.Div(
+					attr.Class("space-y-2"),
+					html.For(data.Secrets, func(secret *models.Secret) html.Node {
+						return html.Div(
+							attr.Class("flex items-center justify-between gap-4 p-3 rounded-lg border"),
+							html.Div(
+								attr.Class("min-w-0 space-y-1"),
+								html.P(
+									attr.Class("font-medium font-mono text-sm break-all"),
+									html.Text(secret.Name),
+								),
+								html.P(
+									attr.Class("text-xs text-muted-foreground"),
+									html.Text("Updated "+formatTime(secret.UpdatedAt)),
+								),
+							),
+							html.Form(
+								attr.Method("POST"),
+								attr.Action(data.Scope.Base()+"/"+strconv.FormatInt(secret.ID, 10)+"/delete"),
+								attr.Attribute{Key: "data-confirm", Value: "Are you sure you want to delete the secret " + secret.Name + "?"},
+								ui.CSRFField(r),
+								ui.Button(ui.ButtonProps{
+									Variant: ui.ButtonDestructive,
+									Type:    "submit",
+								}, html.Text("Delete")),
+							),
+						)
+					}),
+				),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("No secrets yet."),
+				),
+			),
+		}),
+		html.If(len(data.Inherited) > 0, ui.Card(ui.CardProps{
+			Title:       "Organization secrets",
+			Description: "Secrets of the organization this repository sees as well. A secret of the repository with the same name takes precedence.",
+			Content: html.Div(
+				attr.Class("flex flex-wrap gap-2"),
+				html.For(data.Inherited, func(secret *models.Secret) html.Node {
+					return ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text(secret.Name))
+				}),
+			),
+		})),
+		ui.Card(ui.CardProps{
+			Title:       "Add or update a secret",
+			Description: "Saving a secret with the name of an existing one replaces its value",
+			Content: html.Form(
+				attr.Method("POST"),
+				attr.Action(data.Scope.Base()),
+				attr.Class("space-y-4"),
+				attr.Attribute{Key: "autocomplete", Value: "off"},
+				ui.CSRFField(r),
+				html.If(data.Error != "", html.Div(
+					attr.Class("p-3 rounded-lg bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 text-red-800 dark:text-red-200 text-sm"),
+					html.Text(data.Error),
+				)),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "Name",
+					Id:          "name",
+					Name:        "name",
+					Type:        "text",
+					Placeholder: "DEPLOY_TOKEN",
+					Required:    true,
+					Value:       data.Name,
+					Class:       "font-mono",
+				}),
+				html.Div(
+					attr.Class("space-y-2"),
+					html.Label(
+						attr.For("value"),
+						attr.Class("label"),
+						html.Text("Value"),
+					),
+					html.Textarea(
+						attr.Id("value"),
+						attr.Name("value"),
+						attr.Required(),
+						attr.Class("textarea min-h-[90px] w-full font-mono text-sm"),
+					),
+					html.P(
+						attr.Class("text-xs text-muted-foreground"),
+						html.Text("Names consist of letters, digits and _, and are upper-cased. Values are encrypted and at most 48 KiB."),
+					),
+				),
+				html.Div(
+					attr.Class("flex justify-end"),
+					ui.Button(ui.ButtonProps{
+						Variant: ui.ButtonPrimary,
+						Type:    "submit",
+					}, html.Text("Save secret")),
+				),
+			),
+		}),
+	)
+}
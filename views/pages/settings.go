@@ -3,6 +3,7 @@ package pages
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	html "github.com/hypercommithq/libhtml"
@@ -12,6 +13,12 @@ import (
 	"github.com/hypercommithq/libhtml/attr"
 )
 
+// TokenRepositoryOption is a repository an access token can be restricted to
+type TokenRepositoryOption struct {
+	ID       int64
+	FullName string // owner/name
+}
+
 type SettingsData struct {
 	User                 *models.User
 	DisplayNameError     string
@@ -27,6 +34,21 @@ type SettingsData struct {
 	NewAccessToken       string
 	AccessTokenSuccess   string
 	AccessTokenError     string
+	TokenRepositories    []TokenRepositoryOption
+	Emails               []*models.UserEmail
+	EmailSuccess         string
+	EmailError           string
+	Sessions             []*models.Session
+	CurrentSessionID     int64
+	SessionSuccess       string
+	TwoFactorEnabled     bool
+	RecoveryCodesLeft    int
+	TwoFactorSuccess     string
+	TwoFactorError       string
+	Passkeys             []*models.Passkey
+	PasskeySuccess       string
+	PasskeyError         string
+	SecurityLog          []*models.AuditEvent
 }
 
 func Settings(r *http.Request, data *SettingsData) html.Node {
@@ -69,6 +91,7 @@ func Settings(r *http.Request, data *SettingsData) html.Node {
 						attr.Action("/settings/general"),
 						attr.Class("space-y-4"),
 						attr.Attribute{Key: "data-original-username", Value: data.User.Username},
+						ui.CSRFField(r),
 						ui.FormField(ui.FormFieldProps{
 							Label:       "Display Name",
 							Id:          "display_name",
@@ -105,6 +128,55 @@ func Settings(r *http.Request, data *SettingsData) html.Node {
 				),
 			}),
 
+			// Emails Card
+			html.Div(
+				attr.Id("emails"),
+				ui.Card(ui.CardProps{
+					Title:       "Emails",
+					Description: "Verified email addresses are used to attribute your commits to your account",
+					Content: html.Div(
+						attr.Class("space-y-4"),
+						html.If(data.EmailSuccess != "", html.Div(
+							attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+							html.Text(data.EmailSuccess),
+						)),
+						html.If(data.EmailError != "", html.Div(
+							attr.Class("p-3 rounded-lg bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 text-red-800 dark:text-red-200 text-sm"),
+							html.Text(data.EmailError),
+						)),
+						html.If(len(data.Emails) > 0, html.Div(
+							attr.Class("space-y-2"),
+							html.Group(userEmailList(r, data.Emails)...),
+						)),
+						html.Form(
+							attr.Method("POST"),
+							attr.Action("/settings/emails"),
+							attr.Class("space-y-4"),
+							ui.CSRFField(r),
+							ui.FormField(ui.FormFieldProps{
+								Label:       "Add Email Address",
+								Id:          "new_email",
+								Name:        "email",
+								Type:        "email",
+								Placeholder: "john@doe.com",
+								Icon:        ui.IconMail,
+								Required:    true,
+							}),
+							html.Div(
+								attr.Class("flex justify-end"),
+								ui.Button(
+									ui.ButtonProps{
+										Variant: ui.ButtonPrimary,
+										Type:    "submit",
+									},
+									html.Text("Add Email"),
+								),
+							),
+						),
+					),
+				}),
+			),
+
 			// Password Settings Card
 			ui.Card(ui.CardProps{
 				Title:       "Password",
@@ -119,6 +191,7 @@ func Settings(r *http.Request, data *SettingsData) html.Node {
 						attr.Method("POST"),
 						attr.Action("/settings/password"),
 						attr.Class("space-y-4"),
+						ui.CSRFField(r),
 						ui.FormField(ui.FormFieldProps{
 							Label:       "Current Password",
 							Id:          "current_password",
@@ -163,6 +236,149 @@ func Settings(r *http.Request, data *SettingsData) html.Node {
 				),
 			}),
 
+			// Two-Factor Authentication Card
+			html.Div(
+				attr.Id("two-factor"),
+				ui.Card(ui.CardProps{
+					Title:       "Two-Factor Authentication",
+					Description: "Require a code from an authenticator app in addition to your password when signing in",
+					Content: html.Div(
+						attr.Class("space-y-4"),
+						html.If(data.TwoFactorSuccess != "", html.Div(
+							attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+							html.Text(data.TwoFactorSuccess),
+						)),
+						html.If(data.TwoFactorError != "", html.Div(
+							attr.Class("p-3 rounded-lg bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 text-red-800 dark:text-red-200 text-sm"),
+							html.Text(data.TwoFactorError),
+						)),
+						html.IfElse(data.TwoFactorEnabled,
+							twoFactorEnabledSettings(r, data),
+							html.Div(
+								attr.Class("flex items-center justify-between gap-2"),
+								html.P(
+									attr.Class("text-sm text-muted-foreground"),
+									html.Text("Two-factor authentication is not enabled."),
+								),
+								html.A(
+									attr.Href("/settings/two-factor"),
+									attr.Class("btn-primary"),
+									html.Text("Enable"),
+								),
+							),
+						),
+					),
+				}),
+			),
+
+			// Passkeys Card
+			html.Div(
+				attr.Id("passkeys"),
+				ui.Card(ui.CardProps{
+					Title:       "Passkeys",
+					Description: "Sign in without a password using your device's screen lock or a security key",
+					Content: html.Div(
+						attr.Class("space-y-4"),
+						html.If(data.PasskeySuccess != "", html.Div(
+							attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+							html.Text(data.PasskeySuccess),
+						)),
+						html.If(data.PasskeyError != "", html.Div(
+							attr.Class("p-3 rounded-lg bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 text-red-800 dark:text-red-200 text-sm"),
+							html.Text(data.PasskeyError),
+						)),
+						html.IfElse(len(data.Passkeys) > 0,
+							html.Div(
+								attr.Class("space-y-2"),
+								html.Group(passkeyList(r, data.Passkeys)...),
+							),
+							html.P(
+								attr.Class("text-sm text-muted-foreground"),
+								html.Text("You don't have any passkeys yet."),
+							),
+						),
+						html.If(data.TwoFactorEnabled, html.P(
+							attr.Class("text-sm text-muted-foreground"),
+							html.Text("Your passkeys can also be used instead of an authentication code after signing in with your password."),
+						)),
+						passkeyForm(r, "/settings/passkeys", "/settings/passkeys/options", "register",
+							"flex flex-col sm:flex-row sm:items-end gap-2 pt-4 border-t",
+							html.Div(
+								attr.Class("flex-1"),
+								ui.FormField(ui.FormFieldProps{
+									Label:       "Passkey Name",
+									Id:          "passkey-name",
+									Name:        "name",
+									Type:        "text",
+									Placeholder: "e.g., MacBook Touch ID",
+									Icon:        ui.IconShield,
+									Required:    true,
+								}),
+							),
+							ui.Button(
+								ui.ButtonProps{
+									Variant: ui.ButtonPrimary,
+									Type:    "submit",
+								},
+								html.Text("Add passkey"),
+							),
+						),
+					),
+				}),
+			),
+
+			// Sessions Card
+			html.Div(
+				attr.Id("sessions"),
+				ui.Card(ui.CardProps{
+					Title:       "Sessions",
+					Description: "Devices that are signed in to your account",
+					Content: html.Div(
+						attr.Class("space-y-4"),
+						html.If(data.SessionSuccess != "", html.Div(
+							attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+							html.Text(data.SessionSuccess),
+						)),
+						html.If(len(data.Sessions) > 0, html.Div(
+							attr.Class("space-y-2"),
+							html.Group(sessionList(r, data.Sessions, data.CurrentSessionID)...),
+						)),
+						html.Form(
+							attr.Method("POST"),
+							attr.Action("/settings/sessions/revoke-all"),
+							attr.Class("flex justify-end"),
+							ui.CSRFField(r),
+							ui.Button(
+								ui.ButtonProps{
+									Variant: ui.ButtonDestructive,
+									Type:    "submit",
+								},
+								html.Text("Sign out everywhere"),
+							),
+						),
+					),
+				}),
+			),
+
+			// Security Log Card
+			html.Div(
+				attr.Id("security-log"),
+				ui.Card(ui.CardProps{
+					Title:       "Security log",
+					Description: "Recent sign-ins and security-relevant changes made by or to your account",
+					Content: html.IfElse(len(data.SecurityLog) > 0,
+						html.Div(
+							attr.Class("space-y-2"),
+							html.Group(auditEventList(data.SecurityLog)...),
+						),
+						html.P(
+							attr.Class("text-sm text-muted-foreground"),
+							html.Text("No events yet."),
+						),
+					),
+				}),
+			),
+
 			// Access Tokens Card
 			html.Div(
 				attr.Id("access-tokens"),
@@ -224,30 +440,7 @@ func Settings(r *http.Request, data *SettingsData) html.Node {
 								),
 							),
 						)),
-						html.Form(
-							attr.Method("POST"),
-							attr.Action("/settings/access-tokens"),
-							attr.Class("space-y-4"),
-							ui.FormField(ui.FormFieldProps{
-								Label:       "Token Name",
-								Id:          "token_name",
-								Name:        "name",
-								Type:        "text",
-								Placeholder: "My Token",
-								Icon:        ui.IconLock,
-								Required:    true,
-							}),
-							html.Div(
-								attr.Class("flex justify-end"),
-								ui.Button(
-									ui.ButtonProps{
-										Variant: ui.ButtonPrimary,
-										Type:    "submit",
-									},
-									html.Text("Generate Token"),
-								),
-							),
-						),
+						accessTokenForm(r, data.TokenRepositories),
 						html.If(len(data.AccessTokens) > 0, html.Div(
 							attr.Class("space-y-2 mt-6"),
 							html.H3(
@@ -256,13 +449,34 @@ func Settings(r *http.Request, data *SettingsData) html.Node {
 							),
 							html.Div(
 								attr.Class("space-y-2"),
-								html.Group(accessTokenList(data.AccessTokens)...),
+								html.Group(accessTokenList(r, data.AccessTokens, data.TokenRepositories)...),
 							),
 						)),
 					),
 				}),
 			),
 
+			// OAuth Applications Card
+			html.Div(
+				attr.Id("applications"),
+				ui.Card(ui.CardProps{
+					Title:       "OAuth Applications",
+					Description: "Applications you registered, and the ones you gave access to your account",
+					Content: html.Div(
+						attr.Class("flex items-center justify-between gap-2"),
+						html.P(
+							attr.Class("text-sm text-muted-foreground"),
+							html.Text("Register applications that sign in with Hypercommit, or revoke access you granted."),
+						),
+						html.A(
+							attr.Href("/settings/applications"),
+							attr.Class("btn-outline"),
+							html.Text("Manage"),
+						),
+					),
+				}),
+			),
+
 			// JavaScript for username change confirmation and token copying
 			html.Element("script",
 				html.Text(`
@@ -341,6 +555,22 @@ func Settings(r *http.Request, data *SettingsData) html.Node {
 							}
 						};
 
+						// Show the date input for custom expirations and the repository list for restricted tokens
+						const expiration = document.getElementById('token_expiration');
+						const expiresOn = document.getElementById('token_expires_on');
+						if (expiration && expiresOn) {
+							expiration.addEventListener('change', function() {
+								expiresOn.classList.toggle('hidden', expiration.value !== 'custom');
+								expiresOn.required = expiration.value === 'custom';
+							});
+						}
+						const tokenRepositories = document.getElementById('token_repositories');
+						document.querySelectorAll('input[name="repository_access"]').forEach(function(radio) {
+							radio.addEventListener('change', function() {
+								tokenRepositories.classList.toggle('hidden', radio.value !== 'selected' || !radio.checked);
+							});
+						});
+
 						// Handle token deletion confirmation
 						document.querySelectorAll('[data-delete-token]').forEach(function(btn) {
 							btn.addEventListener('click', function(e) {
@@ -357,32 +587,218 @@ func Settings(r *http.Request, data *SettingsData) html.Node {
 	)
 }
 
-func accessTokenList(tokens []*models.AccessToken) []html.Node {
+var accessTokenScopeDescriptions = map[string]string{
+	models.ScopeRepoRead:  "Clone and pull repositories",
+	models.ScopeRepoWrite: "Push to repositories, includes repo:read",
+	models.ScopeTickets:   "Read and write tickets",
+	models.ScopeAdminOrg:  "Manage organizations",
+	models.ScopeAPI:       "Use the API",
+}
+
+func accessTokenForm(r *http.Request, repositories []TokenRepositoryOption) html.Node {
+	scopeOptions := make([]html.Node, 0, len(models.AccessTokenScopes))
+	for _, scope := range models.AccessTokenScopes {
+		id := "scope_" + strings.ReplaceAll(scope, ":", "_")
+		scopeOptions = append(scopeOptions, html.Label(
+			attr.For(id),
+			attr.Class("flex items-start gap-2 text-sm"),
+			html.Input(
+				attr.Type("checkbox"),
+				attr.Id(id),
+				attr.Name("scopes"),
+				attr.Value(scope),
+				attr.Class("input mt-0.5"),
+				html.If(scope == models.ScopeRepoRead, attr.Checked()),
+			),
+			html.Span(
+				html.Span(attr.Class("font-mono"), html.Text(scope)),
+				html.Span(attr.Class("text-muted-foreground"), html.Text(" - "+accessTokenScopeDescriptions[scope])),
+			),
+		))
+	}
+
+	repositoryOptions := make([]html.Node, 0, len(repositories))
+	for _, repo := range repositories {
+		id := fmt.Sprintf("token_repository_%d", repo.ID)
+		repositoryOptions = append(repositoryOptions, html.Label(
+			attr.For(id),
+			attr.Class("flex items-center gap-2 text-sm"),
+			html.Input(
+				attr.Type("checkbox"),
+				attr.Id(id),
+				attr.Name("repository_ids"),
+				attr.Value(fmt.Sprintf("%d", repo.ID)),
+				attr.Class("input"),
+			),
+			html.Span(attr.Class("font-mono"), html.Text(repo.FullName)),
+		))
+	}
+
+	return html.Form(
+		attr.Method("POST"),
+		attr.Action("/settings/access-tokens"),
+		attr.Class("space-y-4"),
+		ui.CSRFField(r),
+		ui.FormField(ui.FormFieldProps{
+			Label:       "Token Name",
+			Id:          "token_name",
+			Name:        "name",
+			Type:        "text",
+			Placeholder: "My Token",
+			Icon:        ui.IconLock,
+			Required:    true,
+		}),
+		html.Div(
+			attr.Class("space-y-2"),
+			html.Label(
+				attr.For("token_expiration"),
+				attr.Class("label"),
+				html.Text("Expiration"),
+			),
+			html.Div(
+				attr.Class("flex gap-2"),
+				html.Element("select",
+					attr.Id("token_expiration"),
+					attr.Name("expiration"),
+					attr.Class("select"),
+					html.Option(attr.Value("7"), html.Text("7 days")),
+					html.Option(attr.Value("30"), attr.Selected(true), html.Text("30 days")),
+					html.Option(attr.Value("90"), html.Text("90 days")),
+					html.Option(attr.Value("365"), html.Text("1 year")),
+					html.Option(attr.Value("custom"), html.Text("Custom date")),
+					html.Option(attr.Value("never"), html.Text("No expiration")),
+				),
+				html.Input(
+					attr.Type("date"),
+					attr.Id("token_expires_on"),
+					attr.Name("expires_on"),
+					attr.Class("input hidden"),
+				),
+			),
+		),
+		html.Div(
+			attr.Class("space-y-2"),
+			html.Div(attr.Class("label"), html.Text("Scopes")),
+			html.Div(
+				attr.Class("space-y-2"),
+				html.Group(scopeOptions...),
+			),
+		),
+		html.Div(
+			attr.Class("space-y-2"),
+			html.Div(attr.Class("label"), html.Text("Repository access")),
+			html.Label(
+				attr.Class("flex items-center gap-2 text-sm"),
+				html.Input(
+					attr.Type("radio"),
+					attr.Name("repository_access"),
+					attr.Value("all"),
+					attr.Class("input"),
+					attr.Checked(),
+				),
+				html.Text("All repositories"),
+			),
+			html.Label(
+				attr.Class("flex items-center gap-2 text-sm"),
+				html.Input(
+					attr.Type("radio"),
+					attr.Name("repository_access"),
+					attr.Value("selected"),
+					attr.Class("input"),
+					html.If(len(repositories) == 0, attr.Disabled()),
+				),
+				html.Text("Only selected repositories"),
+			),
+			html.Div(
+				attr.Id("token_repositories"),
+				attr.Class("hidden ml-6 space-y-2 max-h-48 overflow-y-auto"),
+				html.Group(repositoryOptions...),
+			),
+		),
+		html.Div(
+			attr.Class("flex justify-end"),
+			ui.Button(
+				ui.ButtonProps{
+					Variant: ui.ButtonPrimary,
+					Type:    "submit",
+				},
+				html.Text("Generate Token"),
+			),
+		),
+	)
+}
+
+func accessTokenList(r *http.Request, tokens []*models.AccessToken, repositories []TokenRepositoryOption) []html.Node {
 	if tokens == nil || len(tokens) == 0 {
 		return []html.Node{}
 	}
 
+	repositoryNames := make(map[int64]string, len(repositories))
+	for _, repo := range repositories {
+		repositoryNames[repo.ID] = repo.FullName
+	}
+
 	nodes := make([]html.Node, 0, len(tokens))
 	for _, token := range tokens {
 		if token != nil {
-			nodes = append(nodes, accessTokenItem(token))
+			nodes = append(nodes, accessTokenItem(r, token, repositoryNames))
 		}
 	}
 	return nodes
 }
 
-func accessTokenItem(token *models.AccessToken) html.Node {
+func accessTokenItem(r *http.Request, token *models.AccessToken, repositoryNames map[int64]string) html.Node {
 	if token == nil {
 		return html.Div()
 	}
 
+	scopeBadges := make([]html.Node, 0, len(token.Scopes))
+	for _, scope := range token.Scopes {
+		scopeBadges = append(scopeBadges, ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline, Class: "font-mono"}, html.Text(scope)))
+	}
+
+	repositoryAccess := "All repositories"
+	if len(token.RepositoryIDs) > 0 {
+		names := make([]string, 0, len(token.RepositoryIDs))
+		for _, id := range token.RepositoryIDs {
+			if name, ok := repositoryNames[id]; ok {
+				names = append(names, name)
+			}
+		}
+		repositoryAccess = "Only " + strings.Join(names, ", ")
+		if len(names) < len(token.RepositoryIDs) {
+			repositoryAccess = fmt.Sprintf("Only %d repositories", len(token.RepositoryIDs))
+		}
+	}
+
+	expiration := "Never expires"
+	expired := false
+	if token.ExpiresAt != nil {
+		expiresAt := time.Unix(*token.ExpiresAt, 0)
+		expired = !time.Now().Before(expiresAt)
+		if expired {
+			expiration = "Expired on " + expiresAt.Format("Jan 2, 2006")
+		} else {
+			expiration = "Expires on " + expiresAt.Format("Jan 2, 2006")
+		}
+	}
+
 	return html.Div(
 		attr.Class("flex items-center justify-between p-3 bg-muted rounded-lg"),
 		html.Div(
 			attr.Class("flex-1"),
 			html.Div(
-				attr.Class("font-medium text-sm text-foreground"),
+				attr.Class("flex items-center gap-2 font-medium text-sm text-foreground"),
 				html.Text(token.Name),
+				html.If(expired, ui.Badge(ui.BadgeProps{Variant: ui.BadgeDestructive}, html.Text("Expired"))),
+			),
+			html.Div(
+				attr.Class("flex flex-wrap gap-1 mt-2"),
+				html.Group(scopeBadges...),
+			),
+			html.Div(
+				attr.Class("text-xs text-muted-foreground mt-2"),
+				html.Text(repositoryAccess+" • "+expiration),
 			),
 			html.Div(
 				attr.Class("text-xs text-muted-foreground mt-1"),
@@ -402,6 +818,7 @@ func accessTokenItem(token *models.AccessToken) html.Node {
 			attr.Method("POST"),
 			attr.Action("/settings/access-tokens/"+fmt.Sprintf("%d", token.ID)+"/delete"),
 			attr.Class("inline"),
+			ui.CSRFField(r),
 			html.Element("button",
 				attr.Type("submit"),
 				attr.Class("btn-icon-ghost text-destructive hover:text-destructive"),
@@ -415,6 +832,282 @@ func accessTokenItem(token *models.AccessToken) html.Node {
 	)
 }
 
+func userEmailList(r *http.Request, userEmails []*models.UserEmail) []html.Node {
+	nodes := make([]html.Node, 0, len(userEmails))
+	for _, userEmail := range userEmails {
+		nodes = append(nodes, userEmailItem(r, userEmail))
+	}
+	return nodes
+}
+
+func userEmailItem(r *http.Request, userEmail *models.UserEmail) html.Node {
+	action := fmt.Sprintf("/settings/emails/%d", userEmail.ID)
+	verified := userEmail.VerifiedAt != nil
+
+	return html.Div(
+		attr.Class("flex items-center justify-between gap-2 p-3 bg-muted rounded-lg"),
+		html.Div(
+			attr.Class("flex flex-wrap items-center gap-2 min-w-0"),
+			html.Span(
+				attr.Class("font-medium text-sm text-foreground truncate"),
+				html.Text(userEmail.Email),
+			),
+			html.If(userEmail.IsPrimary, ui.Badge(ui.BadgeProps{Variant: ui.BadgePrimary}, html.Text("Primary"))),
+			html.IfElse(verified,
+				ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text("Verified")),
+				ui.Badge(ui.BadgeProps{Variant: ui.BadgeDestructive}, html.Text("Unverified")),
+			),
+		),
+		html.Div(
+			attr.Class("flex items-center gap-1 shrink-0"),
+			html.If(!verified, html.Form(
+				attr.Method("POST"),
+				attr.Action(action+"/resend"),
+				attr.Class("inline"),
+				ui.CSRFField(r),
+				ui.Button(
+					ui.ButtonProps{
+						Variant: ui.ButtonOutline,
+						Size:    ui.ButtonSmall,
+						Type:    "submit",
+					},
+					html.Text("Resend verification"),
+				),
+			)),
+			html.If(verified && !userEmail.IsPrimary, html.Form(
+				attr.Method("POST"),
+				attr.Action(action+"/primary"),
+				attr.Class("inline"),
+				ui.CSRFField(r),
+				ui.Button(
+					ui.ButtonProps{
+						Variant: ui.ButtonOutline,
+						Size:    ui.ButtonSmall,
+						Type:    "submit",
+					},
+					html.Text("Make primary"),
+				),
+			)),
+			html.If(!userEmail.IsPrimary, html.Form(
+				attr.Method("POST"),
+				attr.Action(action+"/delete"),
+				attr.Class("inline"),
+				ui.CSRFField(r),
+				html.Element("button",
+					attr.Type("submit"),
+					attr.Class("btn-icon-ghost text-destructive hover:text-destructive"),
+					attr.DataTooltip("Remove email"),
+					attr.DataSide("left"),
+					ui.SVGIcon(ui.IconTrash, "text-destructive"),
+				),
+			)),
+		),
+	)
+}
+
+func twoFactorEnabledSettings(r *http.Request, data *SettingsData) html.Node {
+	return html.Div(
+		attr.Class("space-y-4"),
+		html.Div(
+			attr.Class("flex flex-wrap items-center gap-2"),
+			ui.Badge(ui.BadgeProps{Variant: ui.BadgePrimary}, html.Text("Enabled")),
+			html.Span(
+				attr.Class("text-sm text-muted-foreground"),
+				html.Text(fmt.Sprintf("%d of 10 recovery codes left", data.RecoveryCodesLeft)),
+			),
+		),
+		twoFactorConfirmForm(r, data.User, "/settings/two-factor/recovery-codes", ui.ButtonOutline, "Regenerate recovery codes"),
+		twoFactorConfirmForm(r, data.User, "/settings/two-factor/disable", ui.ButtonDestructive, "Disable two-factor authentication"),
+	)
+}
+
+// twoFactorConfirmForm asks for the password, or a code for accounts without one, before changing two-factor settings
+func twoFactorConfirmForm(r *http.Request, user *models.User, action string, variant ui.ButtonVariant, label string) html.Node {
+	field := ui.FormFieldProps{
+		Label:       "Password",
+		Name:        "password",
+		Type:        "password",
+		Placeholder: "••••••••••••••••",
+		Icon:        ui.IconLock,
+		Required:    true,
+	}
+	if user == nil || user.Password == nil {
+		field = ui.FormFieldProps{
+			Label:       "Authentication Code",
+			Name:        "code",
+			Type:        "text",
+			Placeholder: "123456",
+			Icon:        ui.IconShield,
+			Required:    true,
+		}
+	}
+	field.Id = strings.ReplaceAll(strings.TrimPrefix(action, "/settings/"), "/", "_") + "_" + field.Name
+
+	return html.Form(
+		attr.Method("POST"),
+		attr.Action(action),
+		attr.Class("flex flex-col sm:flex-row sm:items-end gap-2"),
+		ui.CSRFField(r),
+		html.Div(
+			attr.Class("flex-1"),
+			ui.FormField(field),
+		),
+		ui.Button(
+			ui.ButtonProps{
+				Variant: variant,
+				Type:    "submit",
+			},
+			html.Text(label),
+		),
+	)
+}
+
+func passkeyList(r *http.Request, passkeys []*models.Passkey) []html.Node {
+	nodes := make([]html.Node, 0, len(passkeys))
+	for _, passkey := range passkeys {
+		nodes = append(nodes, passkeyItem(r, passkey))
+	}
+	return nodes
+}
+
+func passkeyItem(r *http.Request, passkey *models.Passkey) html.Node {
+	lastUsed := "Never used"
+	if passkey.LastUsedAt != nil {
+		lastUsed = "Last used " + formatTimestamp(*passkey.LastUsedAt)
+	}
+
+	return html.Div(
+		attr.Class("flex items-center justify-between gap-2 p-3 bg-muted rounded-lg"),
+		html.Div(
+			attr.Class("min-w-0 space-y-1"),
+			html.P(
+				attr.Class("font-medium text-sm text-foreground truncate"),
+				html.Text(passkey.Name),
+			),
+			html.P(
+				attr.Class("text-xs text-muted-foreground"),
+				html.Text("Added "+formatTimestamp(passkey.CreatedAt)+" • "+lastUsed),
+			),
+		),
+		html.Form(
+			attr.Method("POST"),
+			attr.Action(fmt.Sprintf("/settings/passkeys/%d/delete", passkey.ID)),
+			attr.Class("inline shrink-0"),
+			ui.CSRFField(r),
+			html.Element("button",
+				attr.Type("submit"),
+				attr.Class("btn-icon-ghost text-destructive hover:text-destructive"),
+				attr.DataTooltip("Delete passkey"),
+				attr.DataSide("left"),
+				ui.SVGIcon(ui.IconTrash, "text-destructive"),
+			),
+		),
+	)
+}
+
+func sessionList(r *http.Request, sessions []*models.Session, currentSessionID int64) []html.Node {
+	nodes := make([]html.Node, 0, len(sessions))
+	for _, session := range sessions {
+		nodes = append(nodes, sessionItem(r, session, session.ID == currentSessionID))
+	}
+	return nodes
+}
+
+func sessionItem(r *http.Request, session *models.Session, current bool) html.Node {
+	details := "Signed in " + formatTimestamp(session.CreatedAt)
+	if session.IPAddress != "" {
+		details = session.IPAddress + " • " + details
+	}
+
+	return html.Div(
+		attr.Class("flex items-center justify-between gap-2 p-3 bg-muted rounded-lg"),
+		html.Div(
+			attr.Class("min-w-0 space-y-1"),
+			html.Div(
+				attr.Class("flex flex-wrap items-center gap-2"),
+				html.Span(
+					attr.Class("font-medium text-sm text-foreground truncate"),
+					attr.Attribute{Key: "title", Value: session.UserAgent},
+					html.Text(describeUserAgent(session.UserAgent)),
+				),
+				html.If(current, ui.Badge(ui.BadgeProps{Variant: ui.BadgePrimary}, html.Text("This device"))),
+			),
+			html.P(
+				attr.Class("text-xs text-muted-foreground"),
+				html.Text(details),
+			),
+			html.P(
+				attr.Class("text-xs text-muted-foreground"),
+				html.IfElse(current,
+					html.Text("Active now"),
+					html.Text("Last active "+formatTimestamp(session.LastSeenAt)),
+				),
+			),
+		),
+		html.Form(
+			attr.Method("POST"),
+			attr.Action(fmt.Sprintf("/settings/sessions/%d/revoke", session.ID)),
+			attr.Class("inline shrink-0"),
+			ui.CSRFField(r),
+			ui.Button(
+				ui.ButtonProps{
+					Variant: ui.ButtonOutline,
+					Size:    ui.ButtonSmall,
+					Type:    "submit",
+				},
+				html.IfElse(current, html.Text("Sign out"), html.Text("Revoke")),
+			),
+		),
+	)
+}
+
+// describeUserAgent turns a user agent into something like "Firefox on macOS"
+func describeUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return "Unknown device"
+	}
+
+	browser := ""
+	switch {
+	case strings.Contains(userAgent, "Edg/"):
+		browser = "Edge"
+	case strings.Contains(userAgent, "OPR/"):
+		browser = "Opera"
+	case strings.Contains(userAgent, "Firefox/"):
+		browser = "Firefox"
+	case strings.Contains(userAgent, "Chrome/"):
+		browser = "Chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		browser = "Safari"
+	case strings.HasPrefix(userAgent, "curl/"):
+		browser = "curl"
+	}
+
+	platform := ""
+	switch {
+	case strings.Contains(userAgent, "iPhone"), strings.Contains(userAgent, "iPad"):
+		platform = "iOS"
+	case strings.Contains(userAgent, "Android"):
+		platform = "Android"
+	case strings.Contains(userAgent, "Windows"):
+		platform = "Windows"
+	case strings.Contains(userAgent, "Mac OS X"):
+		platform = "macOS"
+	case strings.Contains(userAgent, "Linux"):
+		platform = "Linux"
+	}
+
+	switch {
+	case browser != "" && platform != "":
+		return browser + " on " + platform
+	case browser != "":
+		return browser
+	case platform != "":
+		return platform
+	}
+	return userAgent
+}
+
 func formatTimestamp(timestamp int64) string {
 	// Convert Unix timestamp to a human-readable format
 	// For now, just return a simple representation
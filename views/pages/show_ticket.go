@@ -3,6 +3,7 @@ package pages
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 
 	html "github.com/hypercommithq/libhtml"
 	"github.com/hypercommithq/hypercommit/database/models"
@@ -19,6 +20,11 @@ type ShowTicketData struct {
 	Author         *models.User
 	Comments       []*models.TicketComment
 	CommentAuthors map[int64]*models.User
+	Milestone      *models.Milestone
+	Milestones     []*models.Milestone
+	Labels         []*models.TicketLabel
+	Assignees      []*models.User
+	Subscribed     bool
 	CanManage      bool
 	StarCount      int64
 	HasStarred     bool
@@ -66,11 +72,27 @@ func ShowTicket(r *http.Request, data *ShowTicketData) html.Node {
 							attr.Class("mt-2 flex items-center gap-2"),
 							statusBadge(data.Ticket.Status),
 							html.Text(fmt.Sprintf("opened %s", formatTime(data.Ticket.CreatedAt))),
+							html.Iff(data.Milestone != nil, func() html.Node {
+								return html.A(
+									attr.Href(ticketMilestoneURL(data)),
+									attr.Class("inline-flex items-center gap-1 text-sm text-muted-foreground hover:text-primary"),
+									ui.SVGIcon(ui.IconFlag, "size-3"),
+									html.Text(data.Milestone.Title),
+								)
+							}),
+						),
+						html.If(
+							len(data.Labels) > 0 || len(data.Assignees) > 0,
+							ticketLabelsAndAssignees(data),
 						),
 					),
 					html.If(
 						data.User != nil,
-						closeReopenButton(data),
+						html.Div(
+							attr.Class("flex items-center gap-2"),
+							subscriptionButton(r, data),
+							closeReopenButton(r, data),
+						),
 					),
 				),
 
@@ -100,13 +122,19 @@ func ShowTicket(r *http.Request, data *ShowTicketData) html.Node {
 					),
 				),
 
+				// Milestone assignment
+				html.If(
+					data.CanManage,
+					milestoneForm(r, data),
+				),
+
 				// Comments
 				renderComments(data),
 
 				// Comment form
 				html.If(
 					data.User != nil,
-					commentForm(data),
+					commentForm(r, data),
 				),
 			),
 		),
@@ -131,11 +159,12 @@ func statusBadge(status string) html.Node {
 	)
 }
 
-func closeReopenButton(data *ShowTicketData) html.Node {
+func closeReopenButton(r *http.Request, data *ShowTicketData) html.Node {
 	if data.Ticket.Status == "open" {
 		return html.Form(
 			attr.Method("post"),
 			attr.Action(fmt.Sprintf("/%s/%s/tickets/%d/close", data.OwnerUsername, data.Repository.Name, data.Ticket.Number)),
+			ui.CSRFField(r),
 			html.Button(
 				attr.Type("submit"),
 				attr.Class("btn-outline"),
@@ -147,6 +176,7 @@ func closeReopenButton(data *ShowTicketData) html.Node {
 	return html.Form(
 		attr.Method("post"),
 		attr.Action(fmt.Sprintf("/%s/%s/tickets/%d/reopen", data.OwnerUsername, data.Repository.Name, data.Ticket.Number)),
+		ui.CSRFField(r),
 		html.Button(
 			attr.Type("submit"),
 			attr.Class("btn-outline"),
@@ -155,6 +185,34 @@ func closeReopenButton(data *ShowTicketData) html.Node {
 	)
 }
 
+func subscriptionButton(r *http.Request, data *ShowTicketData) html.Node {
+	action := "subscribe"
+	icon := ui.IconBell
+	label := "Subscribe"
+	if data.Subscribed {
+		action = "unsubscribe"
+		icon = ui.IconBellOff
+		label = "Unsubscribe"
+	}
+
+	return html.Form(
+		attr.Method("post"),
+		attr.Action(fmt.Sprintf("/%s/%s/tickets/%d/subscription", data.OwnerUsername, data.Repository.Name, data.Ticket.Number)),
+		ui.CSRFField(r),
+		html.Input(
+			attr.Type("hidden"),
+			attr.Name("action"),
+			attr.Value(action),
+		),
+		html.Button(
+			attr.Type("submit"),
+			attr.Class("btn-outline"),
+			ui.SVGIcon(icon, "size-4"),
+			html.Text(label),
+		),
+	)
+}
+
 func renderComments(data *ShowTicketData) html.Node {
 	if len(data.Comments) == 0 {
 		return html.Div()
@@ -200,13 +258,14 @@ func renderComment(comment *models.TicketComment, author *models.User) html.Node
 	)
 }
 
-func commentForm(data *ShowTicketData) html.Node {
+func commentForm(r *http.Request, data *ShowTicketData) html.Node {
 	return html.Div(
 		attr.Class("border rounded-sm p-6 bg-card"),
 		html.Form(
 			attr.Method("post"),
 			attr.Action(fmt.Sprintf("/%s/%s/tickets/%d/comments", data.OwnerUsername, data.Repository.Name, data.Ticket.Number)),
 			attr.Class("space-y-4"),
+			ui.CSRFField(r),
 			html.Textarea(
 				attr.Name("body"),
 				attr.Id("comment-body"),
@@ -225,3 +284,74 @@ func commentForm(data *ShowTicketData) html.Node {
 		),
 	)
 }
+
+func ticketLabelsAndAssignees(data *ShowTicketData) html.Node {
+	labels := make([]html.Node, len(data.Labels))
+	for i, label := range data.Labels {
+		labels[i] = html.Span(
+			attr.Class("badge-outline inline-flex items-center gap-1"),
+			html.Span(
+				attr.Class("size-2 rounded-full"),
+				attr.Attribute{Key: "style", Value: "background-color: " + label.Color},
+			),
+			html.Text(label.Name),
+		)
+	}
+
+	assignees := make([]html.Node, len(data.Assignees))
+	for i, assignee := range data.Assignees {
+		assignees[i] = html.A(
+			attr.Href("/"+assignee.Username),
+			attr.Class("inline-flex items-center gap-1 hover:text-primary"),
+			ui.SVGIcon(ui.IconUser, "size-3"),
+			html.Text(assignee.Username),
+		)
+	}
+
+	return html.Div(
+		attr.Class("mt-2 flex flex-wrap items-center gap-2 text-sm text-muted-foreground"),
+		html.Group(labels...),
+		html.If(
+			len(assignees) > 0,
+			html.Span(html.Text("Assigned to")),
+		),
+		html.Group(assignees...),
+	)
+}
+
+func ticketMilestoneURL(data *ShowTicketData) string {
+	return "/" + data.OwnerUsername + "/" + data.Repository.Name + "/tickets?q=" + url.QueryEscape(fmt.Sprintf("milestone:%q", data.Milestone.Title))
+}
+
+func milestoneForm(r *http.Request, data *ShowTicketData) html.Node {
+	var selectedID *int64
+	milestones := data.Milestones
+	if data.Milestone != nil {
+		selectedID = &data.Milestone.ID
+
+		// Keep a closed milestone selectable so saving doesn't silently drop it
+		found := false
+		for _, milestone := range milestones {
+			if milestone.ID == data.Milestone.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			milestones = append([]*models.Milestone{data.Milestone}, milestones...)
+		}
+	}
+
+	return html.Form(
+		attr.Method("post"),
+		attr.Action(fmt.Sprintf("/%s/%s/tickets/%d/milestone", data.OwnerUsername, data.Repository.Name, data.Ticket.Number)),
+		attr.Class("flex items-end gap-3"),
+		ui.CSRFField(r),
+		milestoneSelect("ticket-milestone", selectedID, milestones),
+		html.Button(
+			attr.Type("submit"),
+			attr.Class("btn-outline"),
+			html.Text("Set milestone"),
+		),
+	)
+}
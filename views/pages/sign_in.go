@@ -10,7 +10,8 @@ import (
 )
 
 type SignInData struct {
-	Error string
+	Error  string
+	Notice string
 }
 
 func SignIn(r *http.Request, data *SignInData) html.Node {
@@ -32,6 +33,12 @@ func SignIn(r *http.Request, data *SignInData) html.Node {
 				Title:       "Hypercommit is in early development.",
 				Description: "Please reach out to the team if you encounter any issues.",
 			}),
+			html.If(data.Notice != "", ui.Alert(ui.AlertProps{
+				Variant:     ui.AlertDefault,
+				Icon:        ui.SVGIcon(ui.IconCheck, "h-4 w-4"),
+				Title:       "Success",
+				Description: data.Notice,
+			})),
 			html.If(data.Error != "", ui.Alert(ui.AlertProps{
 				Variant:     ui.AlertDestructive,
 				Icon:        ui.SVGIcon(ui.IconAlertCircle, "h-4 w-4"),
@@ -40,7 +47,19 @@ func SignIn(r *http.Request, data *SignInData) html.Node {
 			})),
 			html.Div(
 				attr.Class("w-full space-y-4"),
-				ui.GitHubAuthButton(),
+				ui.ExternalAuthButtons(r),
+				passkeyForm(r, "/auth/passkey", "/auth/passkey/options", "authenticate",
+					"space-y-2",
+					ui.Button(
+						ui.ButtonProps{
+							Variant: ui.ButtonOutline,
+							Type:    "submit",
+							Class:   "w-full",
+						},
+						ui.SVGIcon(ui.IconShield, "size-4"),
+						html.Text("Sign in with a passkey"),
+					),
+				),
 				html.Div(
 					attr.Class("relative"),
 					html.Div(
@@ -59,6 +78,7 @@ func SignIn(r *http.Request, data *SignInData) html.Node {
 					attr.Method("POST"),
 					attr.Action("/auth/sign-in"),
 					attr.Class("space-y-4"),
+					ui.CSRFField(r),
 					ui.FormField(ui.FormFieldProps{
 						Label:       "Email Address",
 						Id:          "email",
@@ -77,6 +97,14 @@ func SignIn(r *http.Request, data *SignInData) html.Node {
 						Icon:        ui.IconLock,
 						Required:    true,
 					}),
+					html.Div(
+						attr.Class("text-right text-sm"),
+						html.A(
+							attr.Href("/forgot-password"),
+							attr.Class("text-muted-foreground underline-offset-4 hover:underline hover:text-foreground"),
+							html.Text("Forgot password?"),
+						),
+					),
 					ui.Button(
 						ui.ButtonProps{
 							Variant: ui.ButtonPrimary,
@@ -0,0 +1,86 @@
+package pages
+
+import (
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type SignInTwoFactorData struct {
+	Error string
+	// HasPasskeys offers the user's passkeys as an alternative to a code
+	HasPasskeys bool
+}
+
+func SignInTwoFactor(r *http.Request, data *SignInTwoFactorData) html.Node {
+	if data == nil {
+		data = &SignInTwoFactorData{}
+	}
+
+	return layouts.Main(r,
+		"Two-factor authentication",
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] flex flex-col items-center justify-center w-full mx-auto max-w-xs space-y-6 py-6 px-4 sm:px-0"),
+			html.H2(
+				attr.Class("font-medium text-xl text-center"),
+				html.Text("Two-factor authentication"),
+			),
+			html.If(data.Error != "", ui.Alert(ui.AlertProps{
+				Variant:     ui.AlertDestructive,
+				Icon:        ui.SVGIcon(ui.IconAlertCircle, "h-4 w-4"),
+				Title:       "Error",
+				Description: data.Error,
+			})),
+			html.Form(
+				attr.Method("POST"),
+				attr.Action("/auth/sign-in/two-factor"),
+				attr.Class("w-full space-y-4"),
+				ui.CSRFField(r),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "Authentication Code",
+					Id:          "code",
+					Name:        "code",
+					Type:        "text",
+					Placeholder: "123456",
+					Icon:        ui.IconShield,
+					Required:    true,
+				}),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Enter the code from your authenticator app. If you lost access to it, enter one of your recovery codes instead."),
+				),
+				ui.Button(
+					ui.ButtonProps{
+						Variant: ui.ButtonPrimary,
+						Type:    "submit",
+						Class:   "w-full",
+					},
+					html.Text("Verify"),
+				),
+			),
+			html.If(data.HasPasskeys, passkeyForm(r, "/auth/sign-in/two-factor/passkey", "/auth/sign-in/two-factor/passkey/options", "authenticate",
+				"w-full space-y-2",
+				ui.Button(
+					ui.ButtonProps{
+						Variant: ui.ButtonOutline,
+						Type:    "submit",
+						Class:   "w-full",
+					},
+					ui.SVGIcon(ui.IconShield, "size-4"),
+					html.Text("Use a passkey"),
+				),
+			)),
+			html.P(
+				attr.Class("text-sm text-center text-muted-foreground"),
+				html.A(
+					attr.Href("/auth/sign-in"),
+					attr.Class("underline-offset-4 hover:underline hover:text-foreground"),
+					html.Text("Back to sign in"),
+				),
+			),
+		),
+	)
+}
@@ -17,6 +17,8 @@ type SignUpData struct {
 	DisplayName      string
 	Username         string
 	Email            string
+	// Disabled is set when an administrator closed sign-up on this instance
+	Disabled bool
 }
 
 func SignUp(r *http.Request, data *SignUpData) html.Node {
@@ -38,10 +40,16 @@ func SignUp(r *http.Request, data *SignUpData) html.Node {
 				Title:       "Hypercommit is in early development.",
 				Description: "Please reach out to the team if you encounter any issues.",
 			}),
-			html.Div(
+			html.If(data.Disabled, ui.Alert(ui.AlertProps{
+				Variant:     ui.AlertDestructive,
+				Icon:        ui.SVGIcon(ui.IconInfo, "h-4 w-4"),
+				Title:       "Sign-up is disabled.",
+				Description: "New accounts can't be created on this instance. Ask an administrator to create one for you.",
+			})),
+			html.If(!data.Disabled, html.Div(
 				attr.Class("w-full space-y-4"),
-				ui.GitHubAuthButton(),
-				html.Div(
+				ui.ExternalAuthButtons(r),
+				html.If(ui.HasExternalAuth(r), html.Div(
 					attr.Class("relative"),
 					html.Div(
 						attr.Class("absolute inset-0 flex items-center"),
@@ -54,11 +62,12 @@ func SignUp(r *http.Request, data *SignUpData) html.Node {
 							html.Text("Or continue with"),
 						),
 					),
-				),
+				)),
 				html.Form(
 					attr.Method("POST"),
 					attr.Action("/auth/sign-up"),
 					attr.Class("space-y-4"),
+					ui.CSRFField(r),
 					ui.FormField(ui.FormFieldProps{
 						Label:       "Display Name",
 						Id:          "display_name",
@@ -111,7 +120,7 @@ func SignUp(r *http.Request, data *SignUpData) html.Node {
 						html.Text("Submit"),
 					),
 				),
-			),
+			)),
 		),
 	)
 }
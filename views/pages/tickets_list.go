@@ -3,6 +3,7 @@ package pages
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	html "github.com/hypercommithq/libhtml"
@@ -17,6 +18,8 @@ type TicketsListData struct {
 	Repository    *models.Repository
 	OwnerUsername string
 	Tickets       []*models.Ticket
+	Milestones    map[int64]*models.Milestone
+	Query         string
 	StatusFilter  string
 	OpenCount     int64
 	ClosedCount   int64
@@ -57,14 +60,35 @@ func TicketsList(r *http.Request, data *TicketsListData) html.Node {
 						attr.Class("text-2xl font-semibold"),
 						html.Text("Tickets"),
 					),
-					html.If(
-						data.User != nil,
+					html.Div(
+						attr.Class("flex items-center gap-2"),
 						html.A(
-							attr.Href("/"+data.OwnerUsername+"/"+data.Repository.Name+"/tickets/new"),
-							attr.Class("btn-primary inline-flex items-center gap-2"),
-							ui.SVGIcon(ui.IconPlus, "size-4"),
-							html.Text("New ticket"),
+							attr.Href("/"+data.OwnerUsername+"/"+data.Repository.Name+"/milestones"),
+							attr.Class("btn-outline"),
+							html.Text("Milestones"),
 						),
+						html.If(
+							data.User != nil,
+							html.A(
+								attr.Href("/"+data.OwnerUsername+"/"+data.Repository.Name+"/tickets/new"),
+								attr.Class("btn-primary inline-flex items-center gap-2"),
+								ui.SVGIcon(ui.IconPlus, "size-4"),
+								html.Text("New ticket"),
+							),
+						),
+					),
+				),
+
+				// Search filters
+				html.Form(
+					attr.Method("get"),
+					attr.Action("/"+data.OwnerUsername+"/"+data.Repository.Name+"/tickets"),
+					html.Input(
+						attr.Type("search"),
+						attr.Name("q"),
+						attr.Class("input"),
+						attr.Placeholder(`Filter tickets, e.g. is:open milestone:"v1.2"`),
+						attr.Value(data.Query),
 					),
 				),
 
@@ -76,8 +100,8 @@ func TicketsList(r *http.Request, data *TicketsListData) html.Node {
 						// Filter tabs
 						html.Div(
 							attr.Class("flex flex-wrap items-center gap-4 -mx-6 px-6 border-b"),
-							filterTab("open", data.StatusFilter, data.OpenCount, data.OwnerUsername, data.Repository.Name),
-							filterTab("closed", data.StatusFilter, data.ClosedCount, data.OwnerUsername, data.Repository.Name),
+							filterTab("open", data.StatusFilter, data.OpenCount, data.OwnerUsername, data.Repository.Name, data.Query),
+							filterTab("closed", data.StatusFilter, data.ClosedCount, data.OwnerUsername, data.Repository.Name, data.Query),
 						),
 
 						// Tickets list
@@ -92,9 +116,12 @@ func TicketsList(r *http.Request, data *TicketsListData) html.Node {
 	)
 }
 
-func filterTab(status, currentStatus string, count int64, owner, repo string) html.Node {
+func filterTab(status, currentStatus string, count int64, owner, repo, query string) html.Node {
 	isActive := status == currentStatus
 	href := fmt.Sprintf("/%s/%s/tickets?status=%s", owner, repo, status)
+	if query != "" {
+		href += "&q=" + url.QueryEscape(query)
+	}
 
 	icon := ui.IconCircle
 	if status == "closed" {
@@ -139,7 +166,11 @@ func renderTicketsList(data *TicketsListData) html.Node {
 
 	ticketItems := make([]html.Node, len(data.Tickets))
 	for i, ticket := range data.Tickets {
-		ticketItems[i] = renderTicketItem(data.OwnerUsername, data.Repository.Name, ticket)
+		var milestone *models.Milestone
+		if ticket.MilestoneID != nil {
+			milestone = data.Milestones[*ticket.MilestoneID]
+		}
+		ticketItems[i] = renderTicketItem(data.OwnerUsername, data.Repository.Name, ticket, milestone)
 	}
 
 	return html.Div(
@@ -148,7 +179,7 @@ func renderTicketsList(data *TicketsListData) html.Node {
 	)
 }
 
-func renderTicketItem(owner, repo string, ticket *models.Ticket) html.Node {
+func renderTicketItem(owner, repo string, ticket *models.Ticket, milestone *models.Milestone) html.Node {
 	ticketURL := fmt.Sprintf("/%s/%s/tickets/%d", owner, repo, ticket.Number)
 
 	statusIcon := ui.IconCircle
@@ -177,8 +208,15 @@ func renderTicketItem(owner, repo string, ticket *models.Ticket) html.Node {
 					),
 				),
 				html.Div(
-					attr.Class("mt-1 text-sm text-muted-foreground"),
+					attr.Class("mt-1 flex items-center gap-3 text-sm text-muted-foreground"),
 					html.Text(fmt.Sprintf("#%d opened %s", ticket.Number, formatTime(ticket.CreatedAt))),
+					html.Iff(milestone != nil, func() html.Node {
+						return html.Span(
+							attr.Class("inline-flex items-center gap-1"),
+							ui.SVGIcon(ui.IconFlag, "size-3"),
+							html.Text(milestone.Title),
+						)
+					}),
 				),
 			),
 		),
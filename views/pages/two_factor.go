@@ -0,0 +1,153 @@
+package pages
+
+import (
+	"net/http"
+	"strings"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type TwoFactorSetupData struct {
+	Secret          string
+	ProvisioningURI string
+	Error           string
+}
+
+func TwoFactorSetup(r *http.Request, data *TwoFactorSetupData) html.Node {
+	if data == nil {
+		data = &TwoFactorSetupData{}
+	}
+
+	return layouts.Main(r,
+		"Enable two-factor authentication",
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] flex flex-col items-center justify-center w-full mx-auto max-w-sm space-y-6 py-6 px-4 sm:px-0"),
+			html.H2(
+				attr.Class("font-medium text-xl text-center"),
+				html.Text("Enable two-factor authentication"),
+			),
+			html.If(data.Error != "", ui.Alert(ui.AlertProps{
+				Variant:     ui.AlertDestructive,
+				Icon:        ui.SVGIcon(ui.IconAlertCircle, "h-4 w-4"),
+				Title:       "Error",
+				Description: data.Error,
+			})),
+			html.P(
+				attr.Class("text-sm text-muted-foreground"),
+				html.Text("Scan the QR code with an authenticator app such as 1Password, Google Authenticator or Authy."),
+			),
+			html.Div(
+				attr.Class("w-48 h-48 p-2 bg-white rounded-lg border"),
+				ui.QRCode(data.ProvisioningURI, "w-full h-full"),
+			),
+			html.Div(
+				attr.Class("w-full space-y-2"),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Can't scan it? Enter this key in your app instead:"),
+				),
+				html.Input(
+					attr.Type("text"),
+					attr.Readonly(),
+					attr.Value(formatTwoFactorSecret(data.Secret)),
+					attr.Class("input font-mono text-sm w-full"),
+				),
+			),
+			html.Form(
+				attr.Method("POST"),
+				attr.Action("/settings/two-factor"),
+				attr.Class("w-full space-y-4"),
+				ui.CSRFField(r),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "Authentication Code",
+					Id:          "code",
+					Name:        "code",
+					Type:        "text",
+					Placeholder: "123456",
+					Icon:        ui.IconShield,
+					Required:    true,
+				}),
+				ui.Button(
+					ui.ButtonProps{
+						Variant: ui.ButtonPrimary,
+						Type:    "submit",
+						Class:   "w-full",
+					},
+					html.Text("Enable"),
+				),
+			),
+			html.P(
+				attr.Class("text-sm text-center text-muted-foreground"),
+				html.A(
+					attr.Href("/settings#two-factor"),
+					attr.Class("underline-offset-4 hover:underline hover:text-foreground"),
+					html.Text("Cancel"),
+				),
+			),
+		),
+	)
+}
+
+type TwoFactorRecoveryCodesData struct {
+	RecoveryCodes []string
+	// JustEnabled is set when the codes are shown right after enrollment
+	JustEnabled bool
+}
+
+func TwoFactorRecoveryCodes(r *http.Request, data *TwoFactorRecoveryCodesData) html.Node {
+	if data == nil {
+		data = &TwoFactorRecoveryCodesData{}
+	}
+
+	codes := make([]html.Node, 0, len(data.RecoveryCodes))
+	for _, code := range data.RecoveryCodes {
+		codes = append(codes, html.Li(html.Text(code)))
+	}
+
+	return layouts.Main(r,
+		"Recovery codes",
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] flex flex-col items-center justify-center w-full mx-auto max-w-sm space-y-6 py-6 px-4 sm:px-0"),
+			html.H2(
+				attr.Class("font-medium text-xl text-center"),
+				html.Text("Recovery codes"),
+			),
+			html.If(data.JustEnabled, ui.Alert(ui.AlertProps{
+				Variant:     ui.AlertDefault,
+				Icon:        ui.SVGIcon(ui.IconCheck, "h-4 w-4"),
+				Title:       "Two-factor authentication enabled",
+				Description: "You will be asked for a code from your authenticator app when you sign in.",
+			})),
+			html.Div(
+				attr.Class("w-full p-4 rounded-lg bg-yellow-50 dark:bg-yellow-900/20 border border-yellow-200 dark:border-yellow-800 space-y-3"),
+				html.P(
+					attr.Class("text-sm font-medium text-yellow-800 dark:text-yellow-200"),
+					html.Text("Store these codes somewhere safe. Each of them can be used once to sign in if you lose access to your authenticator app. You won't be able to see them again!"),
+				),
+				html.Ul(
+					attr.Class("grid grid-cols-2 gap-2 font-mono text-sm text-foreground"),
+					html.Group(codes...),
+				),
+			),
+			html.A(
+				attr.Href("/settings#two-factor"),
+				attr.Class("btn-primary w-full"),
+				html.Text("I have saved my recovery codes"),
+			),
+		),
+	)
+}
+
+// formatTwoFactorSecret groups the secret in blocks of four so it is easier to type
+func formatTwoFactorSecret(secret string) string {
+	var blocks []string
+	for len(secret) > 4 {
+		blocks = append(blocks, secret[:4])
+		secret = secret[4:]
+	}
+	blocks = append(blocks, secret)
+	return strings.Join(blocks, " ")
+}
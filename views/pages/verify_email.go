@@ -0,0 +1,51 @@
+package pages
+
+import (
+	"net/http"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type VerifyEmailData struct {
+	// Email is the address that was just verified, empty if the link was invalid
+	Email string
+}
+
+func VerifyEmail(r *http.Request, data *VerifyEmailData) html.Node {
+	if data == nil {
+		data = &VerifyEmailData{}
+	}
+
+	return layouts.Main(r,
+		"Verify email",
+		html.Main(
+			attr.Class("min-h-[calc(100vh-61px)] flex flex-col items-center justify-center w-full mx-auto max-w-xs space-y-6 py-6 px-4 sm:px-0"),
+			html.H2(
+				attr.Class("font-medium text-xl text-center"),
+				html.Text("Verify your email"),
+			),
+			html.IfElse(data.Email != "",
+				ui.Alert(ui.AlertProps{
+					Variant:     ui.AlertDefault,
+					Icon:        ui.SVGIcon(ui.IconCheck, "h-4 w-4"),
+					Title:       "Email verified",
+					Description: data.Email + " has been verified.",
+				}),
+				ui.Alert(ui.AlertProps{
+					Variant:     ui.AlertDestructive,
+					Icon:        ui.SVGIcon(ui.IconAlertCircle, "h-4 w-4"),
+					Title:       "Invalid link",
+					Description: "This verification link is invalid, has expired or was already used. You can request a new one from your settings.",
+				}),
+			),
+			html.A(
+				attr.Href("/settings#emails"),
+				attr.Class("btn-primary w-full"),
+				html.Text("Go to settings"),
+			),
+		),
+	)
+}
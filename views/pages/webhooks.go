@@ -0,0 +1,606 @@
+package pages
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+// WebhookScope is the repository or the organization whose webhooks are shown
+type WebhookScope struct {
+	// Organization is set for the webhooks of an organization
+	Organization *models.Organization
+	// Repository is set for the webhooks of a repository, along with what its layout shows
+	Repository    *models.Repository
+	OwnerUsername string
+	StarCount     int64
+	HasStarred    bool
+}
+
+// Base is the URL of the list of webhooks
+func (s WebhookScope) Base() string {
+	if s.Repository != nil {
+		return "/" + s.OwnerUsername + "/" + s.Repository.Name + "/settings/hooks"
+	}
+	return "/" + s.Organization.Username + "/settings/hooks"
+}
+
+func (s WebhookScope) WebhookURL(webhook *models.Webhook) string {
+	return s.Base() + "/" + strconv.FormatInt(webhook.ID, 10)
+}
+
+func (s WebhookScope) DeliveryURL(webhook *models.Webhook, delivery *models.WebhookDelivery) string {
+	return s.WebhookURL(webhook) + "/deliveries/" + strconv.FormatInt(delivery.ID, 10)
+}
+
+type WebhooksData struct {
+	Scope    WebhookScope
+	Webhooks []*models.Webhook
+	Success  string
+}
+
+type WebhookFormData struct {
+	Scope WebhookScope
+	// Webhook is nil when creating one
+	Webhook     *models.Webhook
+	URL         string
+	ContentType string
+	Events      []string
+	Active      bool
+	Deliveries  []*models.WebhookDelivery
+	Error       string
+	Success     string
+}
+
+type WebhookDeliveryData struct {
+	Scope    WebhookScope
+	Webhook  *models.Webhook
+	Delivery *models.WebhookDelivery
+	Success  string
+}
+
+// webhookEventDescriptions explains when each event is sent
+var webhookEventDescriptions = map[string]string{
+	models.WebhookEventPush:          "Branches or tags are pushed, created or deleted",
+	models.WebhookEventTicket:        "Tickets are opened, edited, closed or reopened",
+	models.WebhookEventTicketComment: "Comments on tickets are created, edited or deleted",
+	models.WebhookEventStar:          "Repositories are starred or unstarred",
+	models.WebhookEventRepository:    "Repositories are created, renamed, edited, made public or private, or deleted",
+	models.WebhookEventRelease:       "Releases are published",
+}
+
+var webhookDeliveryStatusVariants = map[string]ui.BadgeVariant{
+	"pending":   ui.BadgeOutline,
+	"delivered": ui.BadgeSecondary,
+	"failed":    ui.BadgeDestructive,
+}
+
+func Webhooks(r *http.Request, data *WebhooksData) html.Node {
+	description := "Webhooks send a POST request to a URL of yours when something happens in this repository."
+	if data.Scope.Organization != nil {
+		description = "Webhooks send a POST request to a URL of yours when something happens in any repository of the organization."
+	}
+
+	return webhooksLayout(r, data.Scope, "Webhooks",
+		html.Div(
+			attr.Class("flex flex-wrap items-center justify-between gap-4"),
+			html.H1(
+				attr.Class("font-semibold text-2xl"),
+				html.Text("Webhooks"),
+			),
+			html.A(
+				attr.Href(data.Scope.Base()+"/new"),
+				attr.Class("btn-primary"),
+				html.Text("Add webhook"),
+			),
+		),
+		html.If(data.Success != "", html.Div(
+			attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+			html.Text(data.Success),
+		)),
+		ui.Card(ui.CardProps{
+			Title:       "Webhooks",
+			Description: description,
+			Content: html.IfElse(len(data.Webhooks) > 0,
+				html.Div(
+					attr.Class("space-y-2"),
+					html.For(data.Webhooks, func(webhook *models.Webhook) html.Node {
+						return html.Div(
+							attr.Class("flex items-center justify-between gap-4 p-3 rounded-lg border"),
+							html.Div(
+								attr.Class("min-w-0 space-y-1"),
+								html.A(
+									attr.Href(data.Scope.WebhookURL(webhook)),
+									attr.Class("font-medium font-mono text-sm hover:underline break-all"),
+									html.Text(webhook.URL),
+								),
+								html.P(
+									attr.Class("text-xs text-muted-foreground"),
+									html.Text(webhookEventsSummary(webhook.Events)),
+								),
+							),
+							html.Div(
+								attr.Class("flex items-center gap-2 shrink-0"),
+								html.If(!webhook.Active, ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text("Inactive"))),
+								html.A(
+									attr.Href(data.Scope.WebhookURL(webhook)),
+									attr.Class("btn-outline"),
+									html.Text("Edit"),
+								),
+							),
+						)
+					}),
+				),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("No webhooks yet."),
+				),
+			),
+		}),
+	)
+}
+
+func WebhookForm(r *http.Request, data *WebhookFormData) html.Node {
+	title := "Add webhook"
+	action := data.Scope.Base() + "/new"
+	if data.Webhook != nil {
+		title = "Edit webhook"
+		action = data.Scope.WebhookURL(data.Webhook)
+	}
+
+	secretPlaceholder := "Optional"
+	if data.Webhook != nil && data.Webhook.Secret != "" {
+		secretPlaceholder = "Leave empty to keep the current secret"
+	}
+
+	return webhooksLayout(r, data.Scope, title,
+		html.Div(
+			attr.Class("flex flex-wrap items-center justify-between gap-4"),
+			html.H1(
+				attr.Class("font-semibold text-2xl"),
+				html.Text(title),
+			),
+			html.A(
+				attr.Href(data.Scope.Base()),
+				attr.Class("btn-outline"),
+				html.Text("All webhooks"),
+			),
+		),
+		html.If(data.Success != "", html.Div(
+			attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+			html.Text(data.Success),
+		)),
+		ui.Card(ui.CardProps{
+			Title:       "Webhook",
+			Description: "Events are delivered in the background and retried with increasing delays when your URL doesn't answer with a 2xx status",
+			Content: html.Form(
+				attr.Method("POST"),
+				attr.Action(action),
+				attr.Class("space-y-4"),
+				ui.CSRFField(r),
+				html.If(data.Error != "", html.Div(
+					attr.Class("p-3 rounded-lg bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 text-red-800 dark:text-red-200 text-sm"),
+					html.Text(data.Error),
+				)),
+				ui.FormField(ui.FormFieldProps{
+					Label:       "Payload URL",
+					Id:          "url",
+					Name:        "url",
+					Type:        "url",
+					Placeholder: "https://example.com/webhooks",
+					Icon:        ui.IconGlobe,
+					Required:    true,
+					Value:       data.URL,
+				}),
+				ui.Select(ui.SelectProps{
+					Id:    "content_type",
+					Name:  "content_type",
+					Label: "Content type",
+					Options: []ui.SelectOption{
+						{Value: models.WebhookContentTypeJSON, Label: "application/json", Selected: data.ContentType == models.WebhookContentTypeJSON},
+						{Value: models.WebhookContentTypeForm, Label: "application/x-www-form-urlencoded", Selected: data.ContentType == models.WebhookContentTypeForm},
+					},
+				}),
+				html.Div(
+					attr.Class("space-y-2"),
+					html.Label(
+						attr.For("secret"),
+						attr.Class("label"),
+						html.Text("Secret"),
+					),
+					html.Input(
+						attr.Type("password"),
+						attr.Id("secret"),
+						attr.Name("secret"),
+						attr.Placeholder(secretPlaceholder),
+						attr.Attribute{Key: "autocomplete", Value: "new-password"},
+						attr.Class("input w-full font-mono text-sm"),
+					),
+					html.P(
+						attr.Class("text-xs text-muted-foreground"),
+						html.Text("Payloads are signed with it. Check the X-Hypercommit-Signature-256 header, the HMAC-SHA256 hex digest of the body prefixed with sha256=, to make sure they come from us."),
+					),
+					html.If(data.Webhook != nil && data.Webhook.Secret != "", html.Label(
+						attr.For("remove_secret"),
+						attr.Class("flex items-center gap-2 text-sm"),
+						html.Input(
+							attr.Type("checkbox"),
+							attr.Id("remove_secret"),
+							attr.Name("remove_secret"),
+							attr.Class("input"),
+						),
+						html.Span(html.Text("Remove the secret and stop signing payloads")),
+					)),
+				),
+				html.Element("fieldset",
+					attr.Class("space-y-2"),
+					html.Element("legend",
+						attr.Class("label"),
+						html.Text("Events"),
+					),
+					html.For(models.WebhookEvents, func(event string) html.Node {
+						return html.Label(
+							attr.For("event-"+event),
+							attr.Class("flex items-start gap-2 text-sm"),
+							html.Input(
+								attr.Type("checkbox"),
+								attr.Id("event-"+event),
+								attr.Name("events"),
+								attr.Value(event),
+								attr.Class("input mt-0.5"),
+								html.If(slices.Contains(data.Events, event), attr.Checked()),
+							),
+							html.Span(
+								html.Span(attr.Class("font-medium font-mono"), html.Text(event)),
+								html.Span(attr.Class("text-muted-foreground"), html.Text(" - "+webhookEventDescriptions[event])),
+							),
+						)
+					}),
+				),
+				html.Label(
+					attr.For("active"),
+					attr.Class("flex items-start gap-2 text-sm"),
+					html.Input(
+						attr.Type("checkbox"),
+						attr.Id("active"),
+						attr.Name("active"),
+						attr.Class("input mt-0.5"),
+						html.If(data.Active, attr.Checked()),
+					),
+					html.Span(
+						html.Span(attr.Class("font-medium"), html.Text("Active")),
+						html.Span(attr.Class("text-muted-foreground"), html.Text(" - events are only delivered to active webhooks")),
+					),
+				),
+				html.Div(
+					attr.Class("flex justify-end"),
+					ui.Button(ui.ButtonProps{
+						Variant: ui.ButtonPrimary,
+						Type:    "submit",
+					}, html.IfElse(data.Webhook == nil, html.Text("Add webhook"), html.Text("Save changes"))),
+				),
+			),
+		}),
+		html.If(data.Webhook != nil, webhookDeliveries(r, data)),
+		html.If(data.Webhook != nil, ui.Card(ui.CardProps{
+			Title:       "Delete webhook",
+			Description: "Stops deliveries and deletes the delivery log. This can't be undone.",
+			Content: html.Form(
+				attr.Method("POST"),
+				attr.Action(action+"/delete"),
+				attr.Class("flex justify-end"),
+				attr.Attribute{Key: "data-confirm", Value: "Are you sure you want to delete this webhook?"},
+				ui.CSRFField(r),
+				ui.Button(ui.ButtonProps{
+					Variant: ui.ButtonDestructive,
+					Type:    "submit",
+				}, html.Text("Delete webhook")),
+			),
+		})),
+		html.Element("script",
+			html.Text(`
+				(function() {
+					document.querySelectorAll('[data-confirm]').forEach(function(form) {
+						form.addEventListener('submit', function(e) {
+							if (!confirm(form.getAttribute('data-confirm'))) {
+								e.preventDefault();
+							}
+						});
+					});
+				})();
+			`),
+		),
+	)
+}
+
+func webhookDeliveries(r *http.Request, data *WebhookFormData) html.Node {
+	if data.Webhook == nil {
+		return html.Group()
+	}
+
+	return ui.Card(ui.CardProps{
+		Title:       "Recent deliveries",
+		Description: "The latest events sent to this webhook, open one to see the request and the response or to deliver it again",
+		Content: html.Div(
+			attr.Class("space-y-4"),
+			html.IfElse(len(data.Deliveries) > 0,
+				html.Div(
+					attr.Class("divide-y border rounded-lg"),
+					html.For(data.Deliveries, func(delivery *models.WebhookDelivery) html.Node {
+						return html.A(
+							attr.Href(data.Scope.DeliveryURL(data.Webhook, delivery)),
+							attr.Class("flex flex-wrap items-center justify-between gap-2 p-3 hover:bg-muted/50"),
+							html.Div(
+								attr.Class("flex items-center gap-3 min-w-0"),
+								webhookDeliveryStatus(delivery),
+								html.Span(
+									attr.Class("font-mono text-sm"),
+									html.Text(webhookDeliveryEvent(delivery)),
+								),
+								html.Span(
+									attr.Class("font-mono text-xs text-muted-foreground truncate"),
+									html.Text(delivery.GUID),
+								),
+								html.If(delivery.Redelivery, ui.Badge(ui.BadgeProps{Variant: ui.BadgeOutline}, html.Text("redelivery"))),
+							),
+							html.Span(
+								attr.Class("text-xs text-muted-foreground"),
+								html.Text(formatTime(delivery.CreatedAt)),
+							),
+						)
+					}),
+				),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Nothing was delivered yet."),
+				),
+			),
+			html.If(data.Webhook.Active, html.Form(
+				attr.Method("POST"),
+				attr.Action(data.Scope.WebhookURL(data.Webhook)+"/ping"),
+				attr.Class("flex items-center justify-between gap-4"),
+				ui.CSRFField(r),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("Check that your URL receives events."),
+				),
+				ui.Button(ui.ButtonProps{
+					Variant: ui.ButtonOutline,
+					Type:    "submit",
+				}, html.Text("Send ping")),
+			)),
+		),
+	})
+}
+
+func WebhookDelivery(r *http.Request, data *WebhookDeliveryData) html.Node {
+	delivery := data.Delivery
+
+	var responseStatus, duration string
+	if delivery.ResponseStatus != nil {
+		responseStatus = strconv.FormatInt(*delivery.ResponseStatus, 10)
+	}
+	if delivery.DurationMS != nil {
+		duration = strconv.FormatInt(*delivery.DurationMS, 10) + " ms"
+	}
+
+	return webhooksLayout(r, data.Scope, "Webhook delivery",
+		html.Div(
+			attr.Class("flex flex-wrap items-center justify-between gap-4"),
+			html.Div(
+				attr.Class("flex items-center gap-3"),
+				html.H1(
+					attr.Class("font-semibold text-2xl font-mono"),
+					html.Text(webhookDeliveryEvent(delivery)),
+				),
+				webhookDeliveryStatus(delivery),
+			),
+			html.Div(
+				attr.Class("flex gap-2"),
+				html.A(
+					attr.Href(data.Scope.WebhookURL(data.Webhook)),
+					attr.Class("btn-outline"),
+					html.Text("Back to webhook"),
+				),
+				html.Form(
+					attr.Method("POST"),
+					attr.Action(data.Scope.DeliveryURL(data.Webhook, delivery)+"/redeliver"),
+					ui.CSRFField(r),
+					ui.Button(ui.ButtonProps{
+						Variant: ui.ButtonPrimary,
+						Type:    "submit",
+					}, html.Text("Redeliver")),
+				),
+			),
+		),
+		html.If(data.Success != "", html.Div(
+			attr.Class("p-3 rounded-lg bg-emerald-50 dark:bg-emerald-900/20 border border-emerald-200 dark:border-emerald-800 text-emerald-800 dark:text-emerald-200 text-sm"),
+			html.Text(data.Success),
+		)),
+		ui.Card(ui.CardProps{
+			Title:       "Delivery",
+			Description: "Deliveries share their ID with the event they were redelivered from",
+			Content: html.Element("dl",
+				attr.Class("grid grid-cols-[10rem_1fr] gap-x-4 gap-y-2 text-sm"),
+				webhookDeliveryField("Delivery ID", delivery.GUID),
+				webhookDeliveryField("URL", data.Webhook.URL),
+				webhookDeliveryField("Queued", formatTime(delivery.CreatedAt)),
+				webhookDeliveryField("Attempts", strconv.FormatInt(delivery.Attempts, 10)),
+				html.If(delivery.Status == "pending" && delivery.Attempts > 0,
+					webhookDeliveryField("Next attempt", formatTimeUntil(delivery.NextAttemptAt)),
+				),
+				html.If(delivery.DeliveredAt != nil, webhookDeliveryField("Delivered", formatDeliveredAt(delivery.DeliveredAt))),
+				html.If(responseStatus != "", webhookDeliveryField("Response status", responseStatus)),
+				html.If(duration != "", webhookDeliveryField("Duration", duration)),
+				html.If(delivery.LastError != nil, webhookDeliveryField("Error", derefString(delivery.LastError))),
+			),
+		}),
+		ui.Card(ui.CardProps{
+			Title:       "Request",
+			Description: "What was sent on the latest attempt",
+			Content: html.Div(
+				attr.Class("space-y-4"),
+				html.If(delivery.RequestHeaders != nil, webhookDeliveryBlock("Headers", derefString(delivery.RequestHeaders))),
+				webhookDeliveryBlock("Payload", webhookPrettyPayload(delivery.Payload)),
+			),
+		}),
+		ui.Card(ui.CardProps{
+			Title:       "Response",
+			Description: "What your URL answered on the latest attempt",
+			Content: html.IfElse(delivery.ResponseStatus != nil,
+				html.Div(
+					attr.Class("space-y-4"),
+					webhookDeliveryBlock("Headers", derefString(delivery.ResponseHeaders)),
+					webhookDeliveryBlock("Body", derefString(delivery.ResponseBody)),
+				),
+				html.P(
+					attr.Class("text-sm text-muted-foreground"),
+					html.Text("No response was received."),
+				),
+			),
+		}),
+	)
+}
+
+func webhookDeliveryStatus(delivery *models.WebhookDelivery) html.Node {
+	return ui.Badge(ui.BadgeProps{Variant: webhookDeliveryStatusVariants[delivery.Status]}, html.Text(delivery.Status))
+}
+
+// webhookDeliveryEvent names the event of a delivery with its action, like ticket.opened
+func webhookDeliveryEvent(delivery *models.WebhookDelivery) string {
+	if delivery.Action != nil {
+		return delivery.Event + "." + *delivery.Action
+	}
+	return delivery.Event
+}
+
+func webhookDeliveryField(name, value string) html.Node {
+	return html.Group(
+		html.Element("dt",
+			attr.Class("text-muted-foreground"),
+			html.Text(name),
+		),
+		html.Element("dd",
+			attr.Class("font-mono break-all"),
+			html.Text(value),
+		),
+	)
+}
+
+func webhookDeliveryBlock(title, content string) html.Node {
+	if content == "" {
+		content = "(empty)"
+	}
+
+	return html.Div(
+		attr.Class("space-y-2"),
+		html.H3(
+			attr.Class("text-sm font-medium"),
+			html.Text(title),
+		),
+		html.Element("pre",
+			attr.Class("bg-muted rounded-md p-3 text-xs font-mono overflow-x-auto max-h-[32rem]"),
+			html.Text(content),
+		),
+	)
+}
+
+func webhookPrettyPayload(payload string) string {
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(payload), "", "  "); err != nil {
+		return payload
+	}
+	return out.String()
+}
+
+func webhookEventsSummary(events []string) string {
+	switch len(events) {
+	case 0:
+		return "No events"
+	case 1:
+		return "Sends " + events[0] + " events"
+	default:
+		return fmt.Sprintf("Sends %d kinds of events", len(events))
+	}
+}
+
+// formatDeliveredAt guards formatTime, nodes of html.If are built even when they aren't shown
+func formatDeliveredAt(deliveredAt *int64) string {
+	if deliveredAt == nil {
+		return ""
+	}
+	return formatTime(*deliveredAt)
+}
+
+// formatTimeUntil describes a time in the near future, like "in 4 minutes"
+func formatTimeUntil(unixTimestamp int64) string {
+	minutes := (unixTimestamp - time.Now().Unix() + 59) / 60
+	switch {
+	case minutes <= 0:
+		return "now"
+	case minutes == 1:
+		return "in 1 minute"
+	case minutes < 120:
+		return fmt.Sprintf("in %d minutes", minutes)
+	default:
+		return fmt.Sprintf("in %d hours", minutes/60)
+	}
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// webhooksLayout shows the pages in the settings of the repository or the organization
+func webhooksLayout(r *http.Request, scope WebhookScope, title string, children ...html.Node) html.Node {
+	if scope.Organization != nil {
+		return layouts.Profile(r,
+			title+" - "+scope.Organization.DisplayName+" - Hypercommit",
+			layouts.ProfileLayoutOptions{
+				Username:     scope.Organization.Username,
+				DisplayName:  scope.Organization.DisplayName,
+				IsOrg:        true,
+				CurrentTab:   "settings",
+				ShowSettings: true,
+			},
+			html.Main(
+				attr.Class("w-full mx-auto max-w-7xl px-4 py-8 space-y-6"),
+				html.Group(children...),
+			),
+		)
+	}
+
+	cloneURL := "https://" + r.Host + "/" + scope.OwnerUsername + "/" + scope.Repository.Name
+	return layouts.Repository(r,
+		title+" - "+scope.OwnerUsername+"/"+scope.Repository.Name,
+		layouts.RepositoryLayoutOptions{
+			OwnerUsername: scope.OwnerUsername,
+			RepoName:      scope.Repository.Name,
+			CurrentTab:    "settings",
+			IsPublic:      scope.Repository.Visibility == "public",
+			ShowSettings:  true,
+			StarCount:     scope.StarCount,
+			HasStarred:    scope.HasStarred,
+			DefaultBranch: scope.Repository.DefaultBranch,
+			CloneURL:      cloneURL,
+			RepositoryURL: cloneURL,
+		},
+		html.Main(
+			attr.Class("w-full mx-auto max-w-7xl space-y-6 py-8 px-4"),
+			html.Group(children...),
+		),
+	)
+}
@@ -0,0 +1,316 @@
+package pages
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	html "github.com/hypercommithq/libhtml"
+	"github.com/hypercommithq/hypercommit/database/models"
+	"github.com/hypercommithq/hypercommit/views/components/layouts"
+	"github.com/hypercommithq/hypercommit/views/components/ui"
+	"github.com/hypercommithq/libhtml/attr"
+)
+
+type WorkflowRunsData struct {
+	Repository    *models.Repository
+	OwnerUsername string
+	Runs          []*models.WorkflowRun
+	CanManage     bool
+	StarCount     int64
+	HasStarred    bool
+	CloneURL      string
+	RepositoryURL string
+}
+
+type WorkflowRunData struct {
+	Repository    *models.Repository
+	OwnerUsername string
+	Run           *models.WorkflowRun
+	Jobs          []*models.WorkflowJob
+	// TriggeredBy is the username of who caused the run, empty if it's unknown
+	TriggeredBy   string
+	CanCancel     bool
+	CanManage     bool
+	StarCount     int64
+	HasStarred    bool
+	CloneURL      string
+	RepositoryURL string
+}
+
+func WorkflowRuns(r *http.Request, data *WorkflowRunsData) html.Node {
+	base := "/" + data.OwnerUsername + "/" + data.Repository.Name + "/runs"
+
+	var content html.Node
+	if len(data.Runs) == 0 {
+		content = html.Div(
+			attr.Class("py-8"),
+			ui.EmptyState(ui.EmptyStateProps{
+				Icon:        ui.SVGIcon(ui.IconPlay, "size-6"),
+				Title:       "No workflow runs",
+				Description: "Add a workflow to .hypercommit/workflows to run it on pushes, tags or tickets.",
+				ShowAction:  false,
+			}),
+		)
+	} else {
+		items := make([]html.Node, len(data.Runs))
+		for i, run := range data.Runs {
+			items[i] = renderWorkflowRunItem(base, run)
+		}
+		content = html.Div(attr.Class("divide-y"), html.Group(items...))
+	}
+
+	return layouts.Repository(r,
+		"Runs - "+data.OwnerUsername+"/"+data.Repository.Name,
+		layouts.RepositoryLayoutOptions{
+			OwnerUsername: data.OwnerUsername,
+			RepoName:      data.Repository.Name,
+			CurrentTab:    "runs",
+			IsPublic:      data.Repository.Visibility == "public",
+			ShowSettings:  data.CanManage,
+			StarCount:     data.StarCount,
+			HasStarred:    data.HasStarred,
+			DefaultBranch: data.Repository.DefaultBranch,
+			CloneURL:      data.CloneURL,
+			RepositoryURL: data.RepositoryURL,
+		},
+		html.Main(
+			attr.Class("container mx-auto px-4 py-8 max-w-7xl"),
+			html.Div(
+				attr.Class("space-y-6"),
+				html.H1(
+					attr.Class("text-2xl font-semibold"),
+					html.Text("Workflow runs"),
+				),
+				ui.Card(ui.CardProps{
+					Class: "!py-0",
+					Content: html.Div(
+						attr.Class("-mx-6"),
+						content,
+					),
+				}),
+			),
+		),
+	)
+}
+
+func renderWorkflowRunItem(base string, run *models.WorkflowRun) html.Node {
+	return html.A(
+		attr.Href(fmt.Sprintf("%s/%d", base, run.Number)),
+		attr.Class("flex items-start gap-3 p-4 hover:bg-muted/50 transition-colors"),
+		html.Div(
+			attr.Class("flex-shrink-0 mt-1"),
+			workflowStatusIcon(run.Status, run.Conclusion, "size-5"),
+		),
+		html.Div(
+			attr.Class("flex-1 min-w-0"),
+			html.H3(
+				attr.Class("font-medium text-foreground"),
+				html.Text(fmt.Sprintf("%s #%d", run.Name, run.Number)),
+			),
+			html.Div(
+				attr.Class("mt-1 text-sm text-muted-foreground"),
+				html.Text(fmt.Sprintf("%s on %s at %s, %s", run.Event, shortRef(run.Ref), run.SHA[:min(7, len(run.SHA))], formatTime(run.CreatedAt))),
+			),
+		),
+		html.Span(
+			attr.Class("shrink-0 text-sm text-muted-foreground"),
+			html.Text(workflowStatusLabel(run.Status, run.Conclusion)),
+		),
+	)
+}
+
+func WorkflowRun(r *http.Request, data *WorkflowRunData) html.Node {
+	run := data.Run
+	runURL := fmt.Sprintf("/%s/%s/runs/%d", data.OwnerUsername, data.Repository.Name, run.Number)
+
+	summary := fmt.Sprintf("%s on %s at %s", run.Event, shortRef(run.Ref), run.SHA[:min(7, len(run.SHA))])
+	if data.TriggeredBy != "" {
+		summary += " by " + data.TriggeredBy
+	}
+	summary += ", " + formatTime(run.CreatedAt)
+
+	jobs := make([]html.Node, len(data.Jobs))
+	for i, job := range data.Jobs {
+		jobs[i] = renderWorkflowJob(runURL, job)
+	}
+
+	return layouts.Repository(r,
+		fmt.Sprintf("%s #%d - %s/%s", run.Name, run.Number, data.OwnerUsername, data.Repository.Name),
+		layouts.RepositoryLayoutOptions{
+			OwnerUsername: data.OwnerUsername,
+			RepoName:      data.Repository.Name,
+			CurrentTab:    "runs",
+			IsPublic:      data.Repository.Visibility == "public",
+			ShowSettings:  data.CanManage,
+			StarCount:     data.StarCount,
+			HasStarred:    data.HasStarred,
+			DefaultBranch: data.Repository.DefaultBranch,
+			CloneURL:      data.CloneURL,
+			RepositoryURL: data.RepositoryURL,
+		},
+		html.Main(
+			attr.Class("container mx-auto px-4 py-8 max-w-7xl"),
+			html.Div(
+				attr.Class("space-y-6"),
+				html.Div(
+					attr.Class("flex justify-between items-start gap-4"),
+					html.Div(
+						html.H1(
+							attr.Class("text-2xl font-semibold flex items-center gap-2"),
+							workflowStatusIcon(run.Status, run.Conclusion, "size-6"),
+							html.Text(fmt.Sprintf("%s #%d", run.Name, run.Number)),
+						),
+						html.P(
+							attr.Class("mt-1 text-sm text-muted-foreground"),
+							html.Text(summary+" · "+run.Workflow),
+						),
+					),
+					html.If(
+						data.CanCancel && run.Status != models.WorkflowStatusCompleted,
+						html.Form(
+							attr.Method("post"),
+							attr.Action(runURL+"/cancel"),
+							ui.CSRFField(r),
+							html.Button(
+								attr.Type("submit"),
+								attr.Class("btn-outline"),
+								html.Text("Cancel run"),
+							),
+						),
+					),
+				),
+				html.Group(jobs...),
+			),
+			workflowLogScript(),
+		),
+	)
+}
+
+func renderWorkflowJob(runURL string, job *models.WorkflowJob) html.Node {
+	logURL := fmt.Sprintf("%s/jobs/%d/log", runURL, job.ID)
+
+	details := workflowStatusLabel(job.Status, job.Conclusion)
+	if job.StartedAt != nil {
+		finished := time.Now().Unix()
+		if job.FinishedAt != nil {
+			finished = *job.FinishedAt
+		}
+		details += " in " + (time.Duration(finished-*job.StartedAt) * time.Second).String()
+	}
+	if job.RunnerName != nil {
+		details += " on " + *job.RunnerName
+	}
+
+	return ui.Card(ui.CardProps{
+		Header: html.Div(
+			attr.Class("flex items-center gap-2"),
+			workflowStatusIcon(job.Status, job.Conclusion, "size-5"),
+			html.H2(attr.Class("font-medium"), html.Text(job.Name)),
+			html.Span(
+				attr.Class("text-sm text-muted-foreground"),
+				attr.Attribute{Key: "data-job-details", Value: ""},
+				html.Text(details),
+			),
+			html.A(
+				attr.Href(logURL),
+				attr.Class("ml-auto text-sm text-muted-foreground hover:text-foreground"),
+				html.Text("Raw log"),
+			),
+		),
+		Content: html.Element("pre",
+			attr.Class("max-h-[32rem] overflow-auto rounded-md bg-zinc-950 p-4 font-mono text-xs text-zinc-100 whitespace-pre-wrap break-all"),
+			attr.Attribute{Key: "data-job-log", Value: logURL},
+			attr.Attribute{Key: "data-job-status", Value: job.Status},
+		),
+	})
+}
+
+// workflowLogScript loads the log of every job and keeps polling the logs of unfinished jobs for
+// new output. The log endpoint gives the offset to continue from and the status of the job.
+func workflowLogScript() html.Node {
+	return html.Script(
+		html.Text(`
+(function() {
+	document.querySelectorAll('[data-job-log]').forEach(function(pre) {
+		let offset = 0;
+		const poll = function() {
+			fetch(pre.dataset.jobLog + '?offset=' + offset, { credentials: 'same-origin' })
+				.then(function(response) {
+					if (!response.ok) {
+						throw new Error('failed to load log');
+					}
+					const status = response.headers.get('X-Job-Status');
+					offset = Number(response.headers.get('X-Log-Offset')) || offset;
+					return response.text().then(function(text) {
+						if (text) {
+							const follow = pre.scrollTop + pre.clientHeight >= pre.scrollHeight - 8;
+							pre.appendChild(document.createTextNode(text));
+							if (follow) {
+								pre.scrollTop = pre.scrollHeight;
+							}
+						}
+						if (status !== 'completed') {
+							setTimeout(poll, 2000);
+						} else if (pre.dataset.jobStatus !== 'completed') {
+							// The job finished while the page was open, show its outcome
+							window.location.reload();
+						}
+					});
+				})
+				.catch(function() {
+					setTimeout(poll, 10000);
+				});
+		};
+		poll();
+	});
+})();
+		`),
+	)
+}
+
+// workflowStatusIcon shows where a run or job stands: queued, running or how it completed
+func workflowStatusIcon(status string, conclusion *string, class string) html.Node {
+	icon, color := ui.IconCircle, "text-amber-500"
+	switch {
+	case status == models.WorkflowStatusRunning:
+		icon, class = ui.IconLoader, class+" animate-spin"
+	case conclusion != nil && *conclusion == models.WorkflowConclusionSuccess:
+		icon, color = ui.IconCheck, "text-emerald-600"
+	case conclusion != nil && *conclusion == models.WorkflowConclusionFailure:
+		icon, color = ui.IconX, "text-red-600"
+	case conclusion != nil:
+		icon, color = ui.IconX, "text-muted-foreground"
+	}
+
+	label := workflowStatusLabel(status, conclusion)
+	return html.Span(
+		attr.Class("inline-flex shrink-0 "+color),
+		attr.Attribute{Key: "data-tooltip", Value: label},
+		attr.Attribute{Key: "aria-label", Value: label},
+		ui.SVGIcon(icon, class),
+	)
+}
+
+func workflowStatusLabel(status string, conclusion *string) string {
+	switch {
+	case status == models.WorkflowStatusQueued:
+		return "Queued"
+	case status == models.WorkflowStatusRunning:
+		return "Running"
+	case conclusion != nil && *conclusion == models.WorkflowConclusionSuccess:
+		return "Succeeded"
+	case conclusion != nil && *conclusion == models.WorkflowConclusionFailure:
+		return "Failed"
+	}
+	return "Cancelled"
+}
+
+// shortRef strips refs/heads/ and refs/tags/ off a ref
+func shortRef(ref string) string {
+	if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+		return branch
+	}
+	return strings.TrimPrefix(ref, "refs/tags/")
+}